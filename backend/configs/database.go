@@ -57,6 +57,12 @@ func InitDatabase(config *DatabaseConfig) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to migrate database: %v", err)
 	}
 
+	// 补录已有物料使用的计量单位
+	err = seedMaterialUnits(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seed units of measure: %v", err)
+	}
+
 	log.Println("Database connected and migrated successfully")
 	return db, nil
 }
@@ -67,11 +73,81 @@ func autoMigrate(db *gorm.DB) error {
 		&models.User{},
 		&models.Product{},
 		&models.ProductionOrder{},
+		&models.ProductTransaction{},
+		&models.Customer{},
+		&models.SalesOrder{},
+		&models.SalesOrderLine{},
+		&models.Shipment{},
+		&models.ShipmentLine{},
+		&models.ShipmentSerial{},
+		&models.UnitOfMeasure{},
+		&models.UnitConversion{},
 		&models.Material{},
 		&models.MaterialTransaction{},
+		&models.MaterialCostLayer{},
+		&models.InventoryCount{},
+		&models.InventoryCountLine{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderLine{},
+		&models.Supplier{},
+		&models.SupplierContact{},
+		&models.SupplierMaterial{},
+		&models.MaterialQualityStandard{},
+		&models.IncomingInspection{},
+		&models.IncomingInspectionItem{},
+		&models.MaterialHold{},
+		&models.MaterialHoldLog{},
+		&models.ReplenishmentProposal{},
+		&models.MaterialRequisition{},
+		&models.MaterialRequisitionLine{},
+		&models.ProductDemand{},
+		&models.ProductMaterial{},
+		&models.MrpRun{},
+		&models.MrpPlannedOrder{},
+		&models.MrpPurchaseSuggestion{},
+		&models.LabelTemplate{},
 		&models.QualityStandard{},
 		&models.QualityInspection{},
+		&models.InspectionSheet{},
+		&models.DefectCode{},
+		&models.QualityInspectionDefect{},
+		&models.SamplingScheme{},
+		&models.InspectionLot{},
+		&models.NonconformanceReport{},
+		&models.NonconformanceReportLog{},
+		&models.QualityEvent{},
+		&models.QualityResultOverride{},
 		&models.Equipment{},
 		&models.MaintenanceRecord{},
 	)
 }
+
+// seedMaterialUnits 将已有物料使用但计量单位目录中没有的单位补录为计数单位，
+// 保证启用计量单位校验前录入的物料单位都能在目录中找到
+func seedMaterialUnits(db *gorm.DB) error {
+	var units []string
+	err := db.Model(&models.Material{}).
+		Distinct("unit").
+		Where("unit <> ''").
+		Where("unit NOT IN (?)", db.Unscoped().Model(&models.UnitOfMeasure{}).Select("code")).
+		Pluck("unit", &units).Error
+	if err != nil {
+		return err
+	}
+
+	for _, unit := range units {
+		uom := models.UnitOfMeasure{
+			Code:        unit,
+			Name:        unit,
+			Dimension:   "count",
+			Precision:   4,
+			Description: "根据已有物料单位自动补录，请核对量纲和精度",
+			IsActive:    true,
+		}
+		if err := db.Create(&uom).Error; err != nil {
+			return err
+		}
+		log.Printf("Seeded unit of measure %s from existing materials", unit)
+	}
+	return nil
+}
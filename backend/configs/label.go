@@ -0,0 +1,15 @@
+package configs
+
+// LabelConfig 标签打印配置
+type LabelConfig struct {
+	FontPath string // TrueType字体文件路径，用于在PNG和PDF标签上打印中文，未配置或加载失败时非ASCII字符以?代替
+	ZPLFont  string // 斑马打印机上已下载的字体（如 E:SIMSUN.TTF），用于打印中文，未配置时使用打印机内置字体0
+}
+
+// GetDefaultLabelConfig 获取默认标签打印配置
+func GetDefaultLabelConfig() *LabelConfig {
+	return &LabelConfig{
+		FontPath: "",
+		ZPLFont:  "",
+	}
+}
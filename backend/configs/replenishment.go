@@ -0,0 +1,19 @@
+package configs
+
+import "time"
+
+// ReplenishmentConfig 补货引擎配置
+type ReplenishmentConfig struct {
+	Enabled         bool          // 是否启用定时补货计算
+	Interval        time.Duration // 定时运行间隔
+	ConsumptionDays int           // 计算日均消耗的历史天数
+}
+
+// GetDefaultReplenishmentConfig 获取默认补货引擎配置
+func GetDefaultReplenishmentConfig() *ReplenishmentConfig {
+	return &ReplenishmentConfig{
+		Enabled:         true,
+		Interval:        24 * time.Hour,
+		ConsumptionDays: 30,
+	}
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CustomerController 客户控制器
+type CustomerController struct {
+	customerService *service.CustomerService
+}
+
+// NewCustomerController 创建客户控制器实例
+func NewCustomerController(customerService *service.CustomerService) *CustomerController {
+	return &CustomerController{
+		customerService: customerService,
+	}
+}
+
+// CreateCustomer 创建客户
+// @Summary 创建客户
+// @Description 创建客户
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param customer body service.CustomerRequest true "客户信息"
+// @Success 200 {object} response.Response{data=models.Customer}
+// @Failure 400 {object} response.Response
+// @Router /api/customers [post]
+func (c *CustomerController) CreateCustomer(ctx *gin.Context) {
+	var req service.CustomerRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	customer, err := c.customerService.CreateCustomer(&req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建客户成功", customer)
+}
+
+// GetCustomer 获取客户详情
+// @Summary 获取客户详情
+// @Description 根据ID获取客户详情
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param id path int true "客户ID"
+// @Success 200 {object} response.Response{data=models.Customer}
+// @Failure 404 {object} response.Response
+// @Router /api/customers/{id} [get]
+func (c *CustomerController) GetCustomer(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的客户ID")
+		return
+	}
+
+	customer, err := c.customerService.GetCustomer(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取客户详情成功", customer)
+}
+
+// GetCustomerList 获取客户列表
+// @Summary 获取客户列表
+// @Description 分页获取客户列表，支持按编码、名称搜索和按状态筛选
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param keyword query string false "编码或名称"
+// @Param status query int false "状态(1:启用 0:停用)"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/customers [get]
+func (c *CustomerController) GetCustomerList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	keyword := ctx.Query("keyword")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var status *int
+	if statusStr := ctx.Query("status"); statusStr != "" {
+		value, err := strconv.Atoi(statusStr)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的状态")
+			return
+		}
+		status = &value
+	}
+
+	customers, total, err := c.customerService.GetCustomerList(page, pageSize, keyword, status)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, customers, total, page, pageSize, "获取客户列表成功")
+}
+
+// UpdateCustomer 更新客户
+// @Summary 更新客户
+// @Description 更新客户信息
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param id path int true "客户ID"
+// @Param customer body service.CustomerRequest true "客户信息"
+// @Success 200 {object} response.Response{data=models.Customer}
+// @Failure 400 {object} response.Response
+// @Router /api/customers/{id} [put]
+func (c *CustomerController) UpdateCustomer(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的客户ID")
+		return
+	}
+
+	var req service.CustomerRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	customer, err := c.customerService.UpdateCustomer(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新客户成功", customer)
+}
+
+// DeleteCustomer 删除客户
+// @Summary 删除客户
+// @Description 删除没有未结销售订单的客户
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param id path int true "客户ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/customers/{id} [delete]
+func (c *CustomerController) DeleteCustomer(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的客户ID")
+		return
+	}
+
+	if err := c.customerService.DeleteCustomer(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除客户成功", nil)
+}
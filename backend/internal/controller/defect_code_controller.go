@@ -0,0 +1,295 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefectCodeController 缺陷代码控制器
+type DefectCodeController struct {
+	defectCodeService *service.DefectCodeService
+}
+
+// NewDefectCodeController 创建缺陷代码控制器实例
+func NewDefectCodeController(defectCodeService *service.DefectCodeService) *DefectCodeController {
+	return &DefectCodeController{
+		defectCodeService: defectCodeService,
+	}
+}
+
+// CreateDefectCode 创建缺陷代码
+// @Summary 创建缺陷代码
+// @Description 创建缺陷代码，指定上级代码时作为其下级分类
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param request body service.DefectCodeRequest true "缺陷代码信息"
+// @Success 200 {object} response.Response{data=service.DefectCodeResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/defect-codes [post]
+func (c *DefectCodeController) CreateDefectCode(ctx *gin.Context) {
+	var req service.DefectCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	defectCode, err := c.defectCodeService.CreateDefectCode(&req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建缺陷代码成功", defectCode)
+}
+
+// GetDefectCode 获取缺陷代码详情
+// @Summary 获取缺陷代码详情
+// @Description 获取缺陷代码详情
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param id path int true "缺陷代码ID"
+// @Success 200 {object} response.Response{data=service.DefectCodeResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/quality/defect-codes/{id} [get]
+func (c *DefectCodeController) GetDefectCode(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的缺陷代码ID")
+		return
+	}
+
+	defectCode, err := c.defectCodeService.GetDefectCode(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取缺陷代码详情成功", defectCode)
+}
+
+// GetDefectCodeList 获取缺陷代码列表
+// @Summary 获取缺陷代码列表
+// @Description 分页获取缺陷代码列表，支持按上级代码、严重度和状态筛选
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param keyword query string false "代码或名称"
+// @Param parent_id query int false "上级缺陷代码ID"
+// @Param severity query string false "严重度(critical/major/minor)"
+// @Param is_active query bool false "是否启用"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/quality/defect-codes [get]
+func (c *DefectCodeController) GetDefectCodeList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	keyword := ctx.Query("keyword")
+	severity := ctx.Query("severity")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var parentID uint
+	if idStr := ctx.Query("parent_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的上级缺陷代码ID")
+			return
+		}
+		parentID = uint(id)
+	}
+
+	isActive, ok := parseOptionalBool(ctx, "is_active")
+	if !ok {
+		return
+	}
+
+	defectCodes, total, err := c.defectCodeService.GetDefectCodeList(page, pageSize, keyword, parentID, severity, isActive)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, defectCodes, total, page, pageSize, "获取缺陷代码列表成功")
+}
+
+// GetDefectCodeTree 获取缺陷代码目录树
+// @Summary 获取缺陷代码目录树
+// @Description 按上下级关系获取缺陷代码目录
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param is_active query bool false "是否启用"
+// @Success 200 {object} response.Response{data=[]service.DefectCodeResponse}
+// @Router /api/quality/defect-codes/tree [get]
+func (c *DefectCodeController) GetDefectCodeTree(ctx *gin.Context) {
+	isActive, ok := parseOptionalBool(ctx, "is_active")
+	if !ok {
+		return
+	}
+
+	tree, err := c.defectCodeService.GetDefectCodeTree(isActive)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取缺陷代码目录成功", tree)
+}
+
+// UpdateDefectCode 更新缺陷代码
+// @Summary 更新缺陷代码
+// @Description 更新缺陷代码，调整上级代码时同步更新下级代码层级
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param id path int true "缺陷代码ID"
+// @Param request body service.DefectCodeRequest true "缺陷代码信息"
+// @Success 200 {object} response.Response{data=service.DefectCodeResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/defect-codes/{id} [put]
+func (c *DefectCodeController) UpdateDefectCode(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的缺陷代码ID")
+		return
+	}
+
+	var req service.DefectCodeRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	defectCode, err := c.defectCodeService.UpdateDefectCode(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新缺陷代码成功", defectCode)
+}
+
+// DeleteDefectCode 删除缺陷代码
+// @Summary 删除缺陷代码
+// @Description 删除没有下级代码且未被检测记录引用的缺陷代码
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param id path int true "缺陷代码ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/quality/defect-codes/{id} [delete]
+func (c *DefectCodeController) DeleteDefectCode(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的缺陷代码ID")
+		return
+	}
+
+	if err = c.defectCodeService.DeleteDefectCode(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除缺陷代码成功", nil)
+}
+
+// GetDefectPareto 获取缺陷排列图
+// @Summary 获取缺陷排列图
+// @Description 按缺陷代码、产品、生产设备或时间段汇总缺陷数量，按数量降序排列并计算累计占比
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param group_by query string false "分组方式(code/product/equipment/period)" default(code)
+// @Param period query string false "按时间段分组时的粒度(day/week/month)" default(week)
+// @Param level query int false "按缺陷代码分组时汇总到的层级，0为不汇总"
+// @Param product_id query int false "产品ID"
+// @Param equipment_id query int false "生产设备ID"
+// @Param defect_code_id query int false "缺陷代码ID，包含其下级代码"
+// @Param start_date query string false "开始日期(YYYY-MM-DD)，默认结束日期前30天"
+// @Param end_date query string false "结束日期(YYYY-MM-DD)，默认今天"
+// @Success 200 {object} response.Response{data=service.DefectParetoResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/defects/pareto [get]
+func (c *DefectCodeController) GetDefectPareto(ctx *gin.Context) {
+	query := service.DefectParetoQuery{
+		GroupBy: ctx.Query("group_by"),
+		Period:  ctx.Query("period"),
+	}
+
+	if levelStr := ctx.Query("level"); levelStr != "" {
+		level, err := strconv.Atoi(levelStr)
+		if err != nil || level < 0 {
+			response.Error(ctx, http.StatusBadRequest, "无效的汇总层级")
+			return
+		}
+		query.Level = level
+	}
+
+	if idStr := ctx.Query("product_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的产品ID")
+			return
+		}
+		query.ProductID = uint(id)
+	}
+	if idStr := ctx.Query("equipment_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的生产设备ID")
+			return
+		}
+		query.EquipmentID = uint(id)
+	}
+	if idStr := ctx.Query("defect_code_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的缺陷代码ID")
+			return
+		}
+		query.DefectCodeID = uint(id)
+	}
+
+	startDate, endDate, ok := parseSpcWindow(ctx)
+	if !ok {
+		return
+	}
+	query.StartDate = startDate
+	query.EndDate = endDate
+
+	pareto, err := c.defectCodeService.GetDefectPareto(&query)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取缺陷排列图成功", pareto)
+}
+
+// 辅助函数：解析可选的布尔查询参数，解析失败时返回错误响应
+func parseOptionalBool(ctx *gin.Context, name string) (*bool, bool) {
+	valueStr := ctx.Query(name)
+	if valueStr == "" {
+		return nil, true
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的启用状态")
+		return nil, false
+	}
+	return &value, true
+}
@@ -0,0 +1,321 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IncomingInspectionController 来料检验控制器
+type IncomingInspectionController struct {
+	incomingInspectionService *service.IncomingInspectionService
+}
+
+// NewIncomingInspectionController 创建来料检验控制器实例
+func NewIncomingInspectionController(incomingInspectionService *service.IncomingInspectionService) *IncomingInspectionController {
+	return &IncomingInspectionController{
+		incomingInspectionService: incomingInspectionService,
+	}
+}
+
+// CreateMaterialStandard 创建物料质量标准
+// @Summary 创建物料质量标准
+// @Description 创建来料检验使用的物料质量标准
+// @Tags 来料检验
+// @Accept json
+// @Produce json
+// @Param standard body service.MaterialQualityStandardRequest true "物料质量标准信息"
+// @Success 200 {object} response.Response{data=service.MaterialQualityStandardResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/material-standards [post]
+func (c *IncomingInspectionController) CreateMaterialStandard(ctx *gin.Context) {
+	var req service.MaterialQualityStandardRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	standard, err := c.incomingInspectionService.CreateMaterialStandard(&req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建物料质量标准成功", standard)
+}
+
+// GetMaterialStandard 获取物料质量标准详情
+// @Summary 获取物料质量标准详情
+// @Description 根据ID获取物料质量标准详情
+// @Tags 来料检验
+// @Accept json
+// @Produce json
+// @Param id path int true "物料质量标准ID"
+// @Success 200 {object} response.Response{data=service.MaterialQualityStandardResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/quality/material-standards/{id} [get]
+func (c *IncomingInspectionController) GetMaterialStandard(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的物料质量标准ID")
+		return
+	}
+
+	standard, err := c.incomingInspectionService.GetMaterialStandard(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取物料质量标准成功", standard)
+}
+
+// GetMaterialStandardList 获取物料质量标准列表
+// @Summary 获取物料质量标准列表
+// @Description 分页获取物料质量标准列表
+// @Tags 来料检验
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param material_id query int false "物料ID"
+// @Param is_active query bool false "是否启用"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/quality/material-standards [get]
+func (c *IncomingInspectionController) GetMaterialStandardList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	materialID, ok := parseMaterialIDQuery(ctx)
+	if !ok {
+		return
+	}
+
+	var isActive *bool
+	if isActiveStr := ctx.Query("is_active"); isActiveStr != "" {
+		value, err := strconv.ParseBool(isActiveStr)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的启用状态")
+			return
+		}
+		isActive = &value
+	}
+
+	standards, total, err := c.incomingInspectionService.GetMaterialStandardList(page, pageSize, materialID, isActive)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, standards, total, page, pageSize, "获取物料质量标准列表成功")
+}
+
+// UpdateMaterialStandard 更新物料质量标准
+// @Summary 更新物料质量标准
+// @Description 更新物料质量标准信息
+// @Tags 来料检验
+// @Accept json
+// @Produce json
+// @Param id path int true "物料质量标准ID"
+// @Param standard body service.MaterialQualityStandardRequest true "物料质量标准信息"
+// @Success 200 {object} response.Response{data=service.MaterialQualityStandardResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/material-standards/{id} [put]
+func (c *IncomingInspectionController) UpdateMaterialStandard(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的物料质量标准ID")
+		return
+	}
+
+	var req service.MaterialQualityStandardRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	standard, err := c.incomingInspectionService.UpdateMaterialStandard(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新物料质量标准成功", standard)
+}
+
+// DeleteMaterialStandard 删除物料质量标准
+// @Summary 删除物料质量标准
+// @Description 删除没有检验记录的物料质量标准
+// @Tags 来料检验
+// @Accept json
+// @Produce json
+// @Param id path int true "物料质量标准ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/quality/material-standards/{id} [delete]
+func (c *IncomingInspectionController) DeleteMaterialStandard(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的物料质量标准ID")
+		return
+	}
+
+	if err := c.incomingInspectionService.DeleteMaterialStandard(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除物料质量标准成功", nil)
+}
+
+// CreateInspection 创建来料检验单
+// @Summary 创建来料检验单
+// @Description 针对入库交易创建来料检验单，收货数量转入隔离库存；需要检验的物料在收货时会自动生成检验单
+// @Tags 来料检验
+// @Accept json
+// @Produce json
+// @Param inspection body service.IncomingInspectionRequest true "入库交易"
+// @Success 200 {object} response.Response{data=service.IncomingInspectionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/incoming-inspections [post]
+func (c *IncomingInspectionController) CreateInspection(ctx *gin.Context) {
+	var req service.IncomingInspectionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	inspection, err := c.incomingInspectionService.CreateInspection(&req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建来料检验单成功", inspection)
+}
+
+// GetInspection 获取来料检验单详情
+// @Summary 获取来料检验单详情
+// @Description 获取来料检验单及检验项目结果
+// @Tags 来料检验
+// @Accept json
+// @Produce json
+// @Param id path int true "来料检验单ID"
+// @Success 200 {object} response.Response{data=service.IncomingInspectionResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/quality/incoming-inspections/{id} [get]
+func (c *IncomingInspectionController) GetInspection(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的来料检验单ID")
+		return
+	}
+
+	inspection, err := c.incomingInspectionService.GetInspection(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取来料检验单成功", inspection)
+}
+
+// GetInspectionList 获取来料检验单列表
+// @Summary 获取来料检验单列表
+// @Description 分页获取来料检验单列表
+// @Tags 来料检验
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query string false "状态(pending/passed/failed)"
+// @Param material_id query int false "物料ID"
+// @Param supplier_id query int false "供应商ID"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/quality/incoming-inspections [get]
+func (c *IncomingInspectionController) GetInspectionList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	status := ctx.Query("status")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	materialID, ok := parseMaterialIDQuery(ctx)
+	if !ok {
+		return
+	}
+
+	var supplierID uint
+	if supplierIDStr := ctx.Query("supplier_id"); supplierIDStr != "" {
+		id, err := strconv.ParseUint(supplierIDStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的供应商ID")
+			return
+		}
+		supplierID = uint(id)
+	}
+
+	inspections, total, err := c.incomingInspectionService.GetInspectionList(page, pageSize, status, materialID, supplierID)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, inspections, total, page, pageSize, "获取来料检验单列表成功")
+}
+
+// RecordResult 录入来料检验结果
+// @Summary 录入来料检验结果
+// @Description 录入检验项目实测值，系统按物料质量标准判定结论；合格则解除隔离，不合格则整批退回供应商
+// @Tags 来料检验
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "来料检验单ID"
+// @Param result body service.IncomingInspectionResultRequest true "检验结果"
+// @Success 200 {object} response.Response{data=service.IncomingInspectionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/incoming-inspections/{id}/result [post]
+func (c *IncomingInspectionController) RecordResult(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的来料检验单ID")
+		return
+	}
+
+	var req service.IncomingInspectionResultRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	inspection, err := c.incomingInspectionService.RecordResult(uint(id), &req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "录入来料检验结果成功", inspection)
+}
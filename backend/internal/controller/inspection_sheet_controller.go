@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InspectionSheetController 检测单控制器
+type InspectionSheetController struct {
+	inspectionSheetService *service.InspectionSheetService
+}
+
+// NewInspectionSheetController 创建检测单控制器实例
+func NewInspectionSheetController(inspectionSheetService *service.InspectionSheetService) *InspectionSheetController {
+	return &InspectionSheetController{
+		inspectionSheetService: inspectionSheetService,
+	}
+}
+
+// CreateInspectionSheet 创建检测单
+// @Summary 创建检测单
+// @Description 一次抽样录入多个质量特性的测量值，系统逐项判定并由明细计算总体结论
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body service.InspectionSheetRequest true "检测单信息"
+// @Success 200 {object} response.Response{data=service.InspectionSheetResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/sheets [post]
+func (c *InspectionSheetController) CreateInspectionSheet(ctx *gin.Context) {
+	var req service.InspectionSheetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+	role, _ := ctx.Get("role")
+	roleStr, _ := role.(string)
+
+	sheet, err := c.inspectionSheetService.CreateInspectionSheet(&req, userID.(uint), roleStr)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建检测单成功", sheet)
+}
+
+// GetInspectionSheet 获取检测单详情
+// @Summary 获取检测单详情
+// @Description 获取检测单表头及各质量特性的检测明细
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param id path int true "检测单ID"
+// @Success 200 {object} response.Response{data=service.InspectionSheetResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/quality/sheets/{id} [get]
+func (c *InspectionSheetController) GetInspectionSheet(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的检测单ID")
+		return
+	}
+
+	sheet, err := c.inspectionSheetService.GetInspectionSheet(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取检测单详情成功", sheet)
+}
+
+// GetInspectionSheetList 获取检测单列表
+// @Summary 获取检测单列表
+// @Description 分页获取检测单列表，明细可通过质量检测列表按检测单筛选
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param production_order_id query int false "生产工单ID"
+// @Param inspector_id query int false "检测员ID"
+// @Param inspection_lot_id query int false "检验批ID"
+// @Param result query string false "总体结论(pass/fail)"
+// @Param keyword query string false "检测单号"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/quality/sheets [get]
+func (c *InspectionSheetController) GetInspectionSheetList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	result := ctx.Query("result")
+	keyword := ctx.Query("keyword")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var productionOrderID, inspectorID, inspectionLotID uint
+	if idStr := ctx.Query("production_order_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的生产工单ID")
+			return
+		}
+		productionOrderID = uint(id)
+	}
+	if idStr := ctx.Query("inspector_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的检测员ID")
+			return
+		}
+		inspectorID = uint(id)
+	}
+	if idStr := ctx.Query("inspection_lot_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的检验批ID")
+			return
+		}
+		inspectionLotID = uint(id)
+	}
+
+	sheets, total, err := c.inspectionSheetService.GetInspectionSheetList(page, pageSize, productionOrderID, inspectorID, inspectionLotID, result, keyword)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, sheets, total, page, pageSize, "获取检测单列表成功")
+}
+
+// UpdateInspectionSheet 更新检测单
+// @Summary 更新检测单
+// @Description 更新检测单表头和检测明细，明细按质量标准原位更新并重新判定各明细和总体结论，新增的明细及测量值变化的明细参与判异
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "检测单ID"
+// @Param request body service.InspectionSheetRequest true "检测单信息"
+// @Success 200 {object} response.Response{data=service.InspectionSheetResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/sheets/{id} [put]
+func (c *InspectionSheetController) UpdateInspectionSheet(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的检测单ID")
+		return
+	}
+
+	var req service.InspectionSheetRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+	role, _ := ctx.Get("role")
+	roleStr, _ := role.(string)
+
+	sheet, err := c.inspectionSheetService.UpdateInspectionSheet(uint(id), &req, userID.(uint), roleStr)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新检测单成功", sheet)
+}
+
+// DeleteInspectionSheet 删除检测单
+// @Summary 删除检测单
+// @Description 删除检测单及其检测明细
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param id path int true "检测单ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/quality/sheets/{id} [delete]
+func (c *InspectionSheetController) DeleteInspectionSheet(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的检测单ID")
+		return
+	}
+
+	if err = c.inspectionSheetService.DeleteInspectionSheet(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除检测单成功", nil)
+}
@@ -0,0 +1,257 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InventoryCountController 库存盘点控制器
+type InventoryCountController struct {
+	inventoryCountService *service.InventoryCountService
+}
+
+// NewInventoryCountController 创建库存盘点控制器实例
+func NewInventoryCountController(inventoryCountService *service.InventoryCountService) *InventoryCountController {
+	return &InventoryCountController{
+		inventoryCountService: inventoryCountService,
+	}
+}
+
+// CreateCount 创建盘点单
+// @Summary 创建盘点单
+// @Description 按物料、库位或物料类型创建盘点单，盘点期间冻结相关物料的收发
+// @Tags 库存盘点
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param count body service.InventoryCountRequest true "盘点范围"
+// @Success 200 {object} response.Response{data=service.InventoryCountResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/inventory/counts [post]
+func (c *InventoryCountController) CreateCount(ctx *gin.Context) {
+	var req service.InventoryCountRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	count, err := c.inventoryCountService.CreateCount(&req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建盘点单成功", count)
+}
+
+// GetCount 获取盘点单详情
+// @Summary 获取盘点单详情
+// @Description 获取盘点单及其明细，包含实盘数量与账面库存的差异
+// @Tags 库存盘点
+// @Accept json
+// @Produce json
+// @Param id path int true "盘点单ID"
+// @Success 200 {object} response.Response{data=service.InventoryCountResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/inventory/counts/{id} [get]
+func (c *InventoryCountController) GetCount(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的盘点单ID")
+		return
+	}
+
+	count, err := c.inventoryCountService.GetCount(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取盘点单详情成功", count)
+}
+
+// GetCountList 获取盘点单列表
+// @Summary 获取盘点单列表
+// @Description 分页获取盘点单列表，支持按状态筛选
+// @Tags 库存盘点
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query string false "状态(counting/approved/cancelled)"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/inventory/counts [get]
+func (c *InventoryCountController) GetCountList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	status := ctx.Query("status")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	counts, total, err := c.inventoryCountService.GetCountList(page, pageSize, status)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, counts, total, page, pageSize, "获取盘点单列表成功")
+}
+
+// RecordCounts 录入实盘数量
+// @Summary 录入实盘数量
+// @Description 批量录入盘点单中物料的实盘数量和差异原因
+// @Tags 库存盘点
+// @Accept json
+// @Produce json
+// @Param id path int true "盘点单ID"
+// @Param entries body service.InventoryCountEntryRequest true "实盘数量"
+// @Success 200 {object} response.Response{data=service.InventoryCountResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/inventory/counts/{id}/entries [put]
+func (c *InventoryCountController) RecordCounts(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的盘点单ID")
+		return
+	}
+
+	var req service.InventoryCountEntryRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	count, err := c.inventoryCountService.RecordCounts(uint(id), req.Entries)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "录入实盘数量成功", count)
+}
+
+// ImportCounts 导入实盘数量
+// @Summary 导入实盘数量
+// @Description 上传CSV文件导入实盘数量，列依次为物料编码、实盘数量、差异原因代码（可选）、备注（可选）
+// @Tags 库存盘点
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "盘点单ID"
+// @Param file formData file true "CSV文件"
+// @Success 200 {object} response.Response{data=service.InventoryCountResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/inventory/counts/{id}/import [post]
+func (c *InventoryCountController) ImportCounts(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的盘点单ID")
+		return
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "请上传CSV文件")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "读取上传文件失败")
+		return
+	}
+	defer file.Close()
+
+	count, err := c.inventoryCountService.ImportCounts(uint(id), file)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "导入实盘数量成功", count)
+}
+
+// ApproveCount 审核盘点单
+// @Summary 审核盘点单
+// @Description 审核盘点单，按差异生成盘盈/盘亏调整交易并解除物料冻结
+// @Tags 库存盘点
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "盘点单ID"
+// @Success 200 {object} response.Response{data=service.InventoryCountResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/inventory/counts/{id}/approve [post]
+func (c *InventoryCountController) ApproveCount(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的盘点单ID")
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	count, err := c.inventoryCountService.ApproveCount(uint(id), userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "审核盘点单成功", count)
+}
+
+// CancelCount 取消盘点单
+// @Summary 取消盘点单
+// @Description 取消盘点中的盘点单并解除物料冻结
+// @Tags 库存盘点
+// @Accept json
+// @Produce json
+// @Param id path int true "盘点单ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/inventory/counts/{id}/cancel [post]
+func (c *InventoryCountController) CancelCount(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的盘点单ID")
+		return
+	}
+
+	if err := c.inventoryCountService.CancelCount(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "取消盘点单成功", nil)
+}
+
+// GetCountReasonCodes 获取盘点差异原因代码
+// @Summary 获取盘点差异原因代码
+// @Description 获取盘点差异可用的原因代码及说明
+// @Tags 库存盘点
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=map[string]string}
+// @Router /api/inventory/counts/reason-codes [get]
+func (c *InventoryCountController) GetCountReasonCodes(ctx *gin.Context) {
+	response.SuccessWithMessage(ctx, "获取原因代码成功", c.inventoryCountService.GetCountReasonCodes())
+}
@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InventoryReportController 库存报表控制器
+type InventoryReportController struct {
+	inventoryReportService *service.InventoryReportService
+	costingService         *service.CostingService
+}
+
+// NewInventoryReportController 创建库存报表控制器实例
+func NewInventoryReportController(inventoryReportService *service.InventoryReportService, costingService *service.CostingService) *InventoryReportController {
+	return &InventoryReportController{
+		inventoryReportService: inventoryReportService,
+		costingService:         costingService,
+	}
+}
+
+// GetStockAsOf 获取指定日期库存
+// @Summary 获取指定日期库存
+// @Description 根据物料交易记录倒推指定日期日终的物料库存数量和金额
+// @Tags 库存报表
+// @Accept json
+// @Produce json
+// @Param date query string false "截止日期(YYYY-MM-DD)，默认为今天"
+// @Param material_id query int false "物料ID"
+// @Param type query string false "物料类型"
+// @Success 200 {object} response.Response{data=[]service.StockAsOfResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/inventory/stock [get]
+func (c *InventoryReportController) GetStockAsOf(ctx *gin.Context) {
+	stocks, ok := c.queryStockAsOf(ctx)
+	if !ok {
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取库存成功", stocks)
+}
+
+// ExportStockAsOf 导出指定日期库存
+// @Summary 导出指定日期库存
+// @Description 以CSV格式导出指定日期日终的物料库存
+// @Tags 库存报表
+// @Produce text/csv
+// @Param date query string false "截止日期(YYYY-MM-DD)，默认为今天"
+// @Param material_id query int false "物料ID"
+// @Param type query string false "物料类型"
+// @Success 200 {file} file
+// @Failure 400 {object} response.Response
+// @Router /api/inventory/stock/export [get]
+func (c *InventoryReportController) ExportStockAsOf(ctx *gin.Context) {
+	stocks, ok := c.queryStockAsOf(ctx)
+	if !ok {
+		return
+	}
+
+	header := []string{"截止日期", "物料编码", "物料名称", "物料类型", "单位", "库存数量", "单位成本", "库存金额"}
+	rows := make([][]string, 0, len(stocks))
+	for _, stock := range stocks {
+		rows = append(rows, []string{
+			stock.AsOf,
+			stock.MaterialCode,
+			stock.MaterialName,
+			stock.MaterialType,
+			stock.Unit,
+			formatQuantity(stock.Quantity),
+			strconv.FormatFloat(stock.UnitCost, 'f', 4, 64),
+			formatAmount(stock.Value),
+		})
+	}
+
+	response.CSV(ctx, fmt.Sprintf("stock_%s.csv", ctx.DefaultQuery("date", time.Now().Format("2006-01-02"))), header, rows)
+}
+
+// GetMovementReport 获取收发存报表
+// @Summary 获取收发存报表
+// @Description 按物料统计期间内的期初、入库、出库和期末数量及金额
+// @Tags 库存报表
+// @Accept json
+// @Produce json
+// @Param start_date query string true "开始日期(YYYY-MM-DD)"
+// @Param end_date query string true "结束日期(YYYY-MM-DD)"
+// @Param material_id query int false "物料ID"
+// @Param type query string false "物料类型"
+// @Success 200 {object} response.Response{data=[]service.StockMovementReport}
+// @Failure 400 {object} response.Response
+// @Router /api/inventory/movements [get]
+func (c *InventoryReportController) GetMovementReport(ctx *gin.Context) {
+	reports, ok := c.queryMovementReport(ctx)
+	if !ok {
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取收发存报表成功", reports)
+}
+
+// ExportMovementReport 导出收发存报表
+// @Summary 导出收发存报表
+// @Description 以CSV格式导出期间内的物料收发存报表
+// @Tags 库存报表
+// @Produce text/csv
+// @Param start_date query string true "开始日期(YYYY-MM-DD)"
+// @Param end_date query string true "结束日期(YYYY-MM-DD)"
+// @Param material_id query int false "物料ID"
+// @Param type query string false "物料类型"
+// @Success 200 {file} file
+// @Failure 400 {object} response.Response
+// @Router /api/inventory/movements/export [get]
+func (c *InventoryReportController) ExportMovementReport(ctx *gin.Context) {
+	reports, ok := c.queryMovementReport(ctx)
+	if !ok {
+		return
+	}
+
+	header := []string{"物料编码", "物料名称", "物料类型", "单位", "期初数量", "期初金额",
+		"入库数量", "入库金额", "生产退料数量", "生产退料金额", "盘盈数量", "盘盈金额", "收入合计数量", "收入合计金额",
+		"生产领用数量", "生产领用金额", "退供应商数量", "退供应商金额", "报废数量", "报废金额", "盘亏数量", "盘亏金额", "发出合计数量", "发出合计金额",
+		"净消耗数量", "净消耗金额", "期末数量", "期末金额"}
+	rows := make([][]string, 0, len(reports))
+	for _, report := range reports {
+		rows = append(rows, []string{
+			report.MaterialCode,
+			report.MaterialName,
+			report.MaterialType,
+			report.Unit,
+			formatQuantity(report.OpeningQuantity),
+			formatAmount(report.OpeningValue),
+			formatQuantity(report.StockInQuantity),
+			formatAmount(report.StockInValue),
+			formatQuantity(report.ProductionReturnQuantity),
+			formatAmount(report.ProductionReturnValue),
+			formatQuantity(report.AdjustInQuantity),
+			formatAmount(report.AdjustInValue),
+			formatQuantity(report.ReceiptQuantity),
+			formatAmount(report.ReceiptValue),
+			formatQuantity(report.ConsumptionQuantity),
+			formatAmount(report.ConsumptionValue),
+			formatQuantity(report.SupplierReturnQuantity),
+			formatAmount(report.SupplierReturnValue),
+			formatQuantity(report.ScrapQuantity),
+			formatAmount(report.ScrapValue),
+			formatQuantity(report.AdjustOutQuantity),
+			formatAmount(report.AdjustOutValue),
+			formatQuantity(report.IssueQuantity),
+			formatAmount(report.IssueValue),
+			formatQuantity(report.NetConsumptionQuantity),
+			formatAmount(report.NetConsumptionValue),
+			formatQuantity(report.ClosingQuantity),
+			formatAmount(report.ClosingValue),
+		})
+	}
+
+	filename := fmt.Sprintf("stock_movements_%s_%s.csv", ctx.Query("start_date"), ctx.Query("end_date"))
+	response.CSV(ctx, filename, header, rows)
+}
+
+// GetInventoryValuation 获取库存估值
+// @Summary 获取库存估值
+// @Description 按物料计价方法获取当前库存金额，并按物料类型汇总
+// @Tags 库存报表
+// @Accept json
+// @Produce json
+// @Param type query string false "物料类型"
+// @Success 200 {object} response.Response{data=service.InventoryValuationResponse}
+// @Router /api/inventory/valuation [get]
+func (c *InventoryReportController) GetInventoryValuation(ctx *gin.Context) {
+	valuation, err := c.costingService.GetInventoryValuation(ctx.Query("type"))
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取库存估值成功", valuation)
+}
+
+// GetCostLayers 获取物料成本层
+// @Summary 获取物料成本层
+// @Description 获取先进先出计价物料的剩余成本层
+// @Tags 库存报表
+// @Accept json
+// @Produce json
+// @Param material_id path int true "物料ID"
+// @Success 200 {object} response.Response{data=[]models.MaterialCostLayer}
+// @Failure 400 {object} response.Response
+// @Router /api/inventory/cost-layers/{material_id} [get]
+func (c *InventoryReportController) GetCostLayers(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("material_id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的物料ID")
+		return
+	}
+
+	layers, err := c.costingService.GetCostLayers(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取成本层成功", layers)
+}
+
+// 辅助函数：解析参数并查询指定日期库存
+func (c *InventoryReportController) queryStockAsOf(ctx *gin.Context) ([]service.StockAsOfResponse, bool) {
+	date := time.Now()
+	if dateStr := ctx.Query("date"); dateStr != "" {
+		parsedDate, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的日期格式")
+			return nil, false
+		}
+		date = parsedDate
+	}
+
+	materialID, ok := parseMaterialIDQuery(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	stocks, err := c.inventoryReportService.GetStockAsOf(date, materialID, ctx.Query("type"))
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	return stocks, true
+}
+
+// 辅助函数：解析参数并查询收发存报表
+func (c *InventoryReportController) queryMovementReport(ctx *gin.Context) ([]service.StockMovementReport, bool) {
+	startDate, err := time.ParseInLocation("2006-01-02", ctx.Query("start_date"), time.Local)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的开始日期格式")
+		return nil, false
+	}
+
+	endDate, err := time.ParseInLocation("2006-01-02", ctx.Query("end_date"), time.Local)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的结束日期格式")
+		return nil, false
+	}
+
+	materialID, ok := parseMaterialIDQuery(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	reports, err := c.inventoryReportService.GetMovementReport(startDate, endDate, materialID, ctx.Query("type"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	return reports, true
+}
+
+// 辅助函数：解析可选的物料ID查询参数
+func parseMaterialIDQuery(ctx *gin.Context) (uint, bool) {
+	materialIDStr := ctx.Query("material_id")
+	if materialIDStr == "" {
+		return 0, true
+	}
+
+	id, err := strconv.ParseUint(materialIDStr, 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的物料ID")
+		return 0, false
+	}
+
+	return uint(id), true
+}
+
+// 辅助函数：格式化金额，保留两位小数
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// 辅助函数：格式化数量，去掉多余的尾零
+func formatQuantity(quantity float64) string {
+	return strconv.FormatFloat(quantity, 'f', -1, 64)
+}
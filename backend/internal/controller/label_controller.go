@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LabelController 标签控制器
+type LabelController struct {
+	labelService *service.LabelService
+}
+
+// NewLabelController 创建标签控制器实例
+func NewLabelController(labelService *service.LabelService) *LabelController {
+	return &LabelController{
+		labelService: labelService,
+	}
+}
+
+// CreateTemplate 创建标签模板
+// @Summary 创建标签模板
+// @Description 创建物料、批次、生产工单或设备的标签模板，可配置尺寸、条码类型和打印字段
+// @Tags 标签打印
+// @Accept json
+// @Produce json
+// @Param template body service.LabelTemplateRequest true "标签模板信息"
+// @Success 200 {object} response.Response{data=service.LabelTemplateResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/labels/templates [post]
+func (c *LabelController) CreateTemplate(ctx *gin.Context) {
+	var req service.LabelTemplateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	template, err := c.labelService.CreateTemplate(&req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建标签模板成功", template)
+}
+
+// GetTemplate 获取标签模板详情
+// @Summary 获取标签模板详情
+// @Description 根据ID获取标签模板详情
+// @Tags 标签打印
+// @Accept json
+// @Produce json
+// @Param id path int true "标签模板ID"
+// @Success 200 {object} response.Response{data=service.LabelTemplateResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/labels/templates/{id} [get]
+func (c *LabelController) GetTemplate(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的标签模板ID")
+		return
+	}
+
+	template, err := c.labelService.GetTemplate(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取标签模板成功", template)
+}
+
+// GetTemplateList 获取标签模板列表
+// @Summary 获取标签模板列表
+// @Description 获取标签模板列表，可按对象类型筛选
+// @Tags 标签打印
+// @Accept json
+// @Produce json
+// @Param entity_type query string false "对象类型(material/lot/production_order/equipment)"
+// @Success 200 {object} response.Response{data=[]service.LabelTemplateResponse}
+// @Router /api/labels/templates [get]
+func (c *LabelController) GetTemplateList(ctx *gin.Context) {
+	templates, err := c.labelService.GetTemplateList(ctx.Query("entity_type"))
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取标签模板列表成功", templates)
+}
+
+// UpdateTemplate 更新标签模板
+// @Summary 更新标签模板
+// @Description 更新标签模板
+// @Tags 标签打印
+// @Accept json
+// @Produce json
+// @Param id path int true "标签模板ID"
+// @Param template body service.LabelTemplateRequest true "标签模板信息"
+// @Success 200 {object} response.Response{data=service.LabelTemplateResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/labels/templates/{id} [put]
+func (c *LabelController) UpdateTemplate(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的标签模板ID")
+		return
+	}
+
+	var req service.LabelTemplateRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	template, err := c.labelService.UpdateTemplate(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新标签模板成功", template)
+}
+
+// DeleteTemplate 删除标签模板
+// @Summary 删除标签模板
+// @Description 删除标签模板
+// @Tags 标签打印
+// @Accept json
+// @Produce json
+// @Param id path int true "标签模板ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/labels/templates/{id} [delete]
+func (c *LabelController) DeleteTemplate(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的标签模板ID")
+		return
+	}
+
+	if err := c.labelService.DeleteTemplate(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除标签模板成功", nil)
+}
+
+// GetLabelFields 获取标签可打印字段
+// @Summary 获取标签可打印字段
+// @Description 获取各对象类型可在标签上打印的字段
+// @Tags 标签打印
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response
+// @Router /api/labels/fields [get]
+func (c *LabelController) GetLabelFields(ctx *gin.Context) {
+	response.SuccessWithMessage(ctx, "获取标签字段成功", c.labelService.GetLabelFields())
+}
+
+// PrintLabels 打印标签
+// @Summary 打印标签
+// @Description 按模板生成标签文件：PNG（多张时为zip）、PDF（每页一张）或斑马打印机ZPL指令。条码内容为带前缀的编码：M:物料编码、L:批次号、PO:工单号、E:设备编码。批次标签可按采购订单批量打印并按包装数量拆分
+// @Tags 标签打印
+// @Accept json
+// @Produce octet-stream
+// @Param print body service.LabelPrintRequest true "打印信息"
+// @Success 200 {file} file
+// @Failure 400 {object} response.Response
+// @Router /api/labels/print [post]
+func (c *LabelController) PrintLabels(ctx *gin.Context) {
+	var req service.LabelPrintRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	document, err := c.labelService.PrintLabels(&req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.File(ctx, document.Filename, document.ContentType, document.Data)
+}
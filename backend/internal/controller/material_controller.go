@@ -171,7 +171,7 @@ func (c *MaterialController) DeleteMaterial(ctx *gin.Context) {
 
 // CreateTransaction 创建物料交易
 // @Summary 创建物料交易
-// @Description 创建物料入库或出库记录
+// @Description 创建物料交易：入库、生产领用、退供应商、生产退料（按原工单领用成本入账）、报废和盘盈盘亏调整，各类型按其规则校验原因代码；报废和盘盈盘亏调整仅管理员和主管可以手工登记，出库只能使用非限制库存
 // @Tags 物料管理
 // @Accept json
 // @Produce json
@@ -186,7 +186,10 @@ func (c *MaterialController) CreateTransaction(ctx *gin.Context) {
 		return
 	}
 
-	transaction, err := c.materialService.CreateTransaction(&req)
+	role, _ := ctx.Get("role")
+	roleStr, _ := role.(string)
+
+	transaction, err := c.materialService.CreateTransaction(&req, roleStr)
 	if err != nil {
 		response.Error(ctx, http.StatusBadRequest, err.Error())
 		return
@@ -204,7 +207,7 @@ func (c *MaterialController) CreateTransaction(ctx *gin.Context) {
 // @Param page query int false "页码" default(1)
 // @Param page_size query int false "每页数量" default(10)
 // @Param material_id query int false "物料ID"
-// @Param type query string false "交易类型(in/out)"
+// @Param type query string false "交易类型(in/out/return_supplier/return_production/scrap/adjust_in/adjust_out)"
 // @Success 200 {object} response.Response{data=response.PageResponse}
 // @Router /api/materials/transactions [get]
 func (c *MaterialController) GetTransactionList(ctx *gin.Context) {
@@ -239,6 +242,18 @@ func (c *MaterialController) GetTransactionList(ctx *gin.Context) {
 	response.SuccessWithPage(ctx, transactions, total, page, pageSize, "获取交易列表成功")
 }
 
+// GetTransactionTypes 获取物料交易类型
+// @Summary 获取物料交易类型
+// @Description 获取物料交易类型及各类型可选择的原因代码
+// @Tags 物料管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.TransactionTypeResponse}
+// @Router /api/materials/transaction-types [get]
+func (c *MaterialController) GetTransactionTypes(ctx *gin.Context) {
+	response.SuccessWithMessage(ctx, "获取交易类型成功", c.materialService.GetTransactionTypes())
+}
+
 // GetLowStockMaterials 获取低库存物料
 // @Summary 获取低库存物料
 // @Description 获取库存低于最小库存的物料列表
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaterialHoldController 物料冻结控制器
+type MaterialHoldController struct {
+	materialHoldService *service.MaterialHoldService
+}
+
+// NewMaterialHoldController 创建物料冻结控制器实例
+func NewMaterialHoldController(materialHoldService *service.MaterialHoldService) *MaterialHoldController {
+	return &MaterialHoldController{
+		materialHoldService: materialHoldService,
+	}
+}
+
+// CreateHold 冻结物料
+// @Summary 冻结物料
+// @Description 对物料的部分非限制库存或整个物料进行质量冻结，冻结库存不能发料
+// @Tags 物料冻结
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param hold body service.MaterialHoldRequest true "冻结信息"
+// @Success 200 {object} response.Response{data=service.MaterialHoldResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/material-holds [post]
+func (c *MaterialHoldController) CreateHold(ctx *gin.Context) {
+	var req service.MaterialHoldRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	hold, err := c.materialHoldService.CreateHold(&req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "冻结物料成功", hold)
+}
+
+// GetHold 获取物料冻结详情
+// @Summary 获取物料冻结详情
+// @Description 获取物料冻结及其操作记录
+// @Tags 物料冻结
+// @Accept json
+// @Produce json
+// @Param id path int true "物料冻结ID"
+// @Success 200 {object} response.Response{data=service.MaterialHoldResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/material-holds/{id} [get]
+func (c *MaterialHoldController) GetHold(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的物料冻结ID")
+		return
+	}
+
+	hold, err := c.materialHoldService.GetHold(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取物料冻结成功", hold)
+}
+
+// GetHoldList 获取物料冻结列表
+// @Summary 获取物料冻结列表
+// @Description 分页获取物料冻结列表
+// @Tags 物料冻结
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query string false "状态(active/released/scrapped/closed)"
+// @Param material_id query int false "物料ID"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/material-holds [get]
+func (c *MaterialHoldController) GetHoldList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	status := ctx.Query("status")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	materialID, ok := parseMaterialIDQuery(ctx)
+	if !ok {
+		return
+	}
+
+	holds, total, err := c.materialHoldService.GetHoldList(page, pageSize, status, materialID)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, holds, total, page, pageSize, "获取物料冻结列表成功")
+}
+
+// ReleaseHold 解除物料冻结
+// @Summary 解除物料冻结
+// @Description 解除冻结，部分冻结可按数量分次解除，数量为空时解除全部剩余冻结
+// @Tags 物料冻结
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "物料冻结ID"
+// @Param disposition body service.MaterialHoldDispositionRequest true "解除信息"
+// @Success 200 {object} response.Response{data=service.MaterialHoldResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/material-holds/{id}/release [post]
+func (c *MaterialHoldController) ReleaseHold(ctx *gin.Context) {
+	c.dispose(ctx, "解除物料冻结成功", c.materialHoldService.ReleaseHold)
+}
+
+// ScrapHold 报废冻结物料
+// @Summary 报废冻结物料
+// @Description 将冻结数量报废并生成报废出库交易，数量为空时报废全部剩余冻结
+// @Tags 物料冻结
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "物料冻结ID"
+// @Param disposition body service.MaterialHoldDispositionRequest true "报废信息"
+// @Success 200 {object} response.Response{data=service.MaterialHoldResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/material-holds/{id}/scrap [post]
+func (c *MaterialHoldController) ScrapHold(ctx *gin.Context) {
+	c.dispose(ctx, "报废冻结物料成功", c.materialHoldService.ScrapHold)
+}
+
+// 辅助函数：处理冻结处置请求
+func (c *MaterialHoldController) dispose(ctx *gin.Context, message string,
+	handler func(uint, *service.MaterialHoldDispositionRequest, uint) (*service.MaterialHoldResponse, error)) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的物料冻结ID")
+		return
+	}
+
+	// 请求体可为空，表示处置全部剩余冻结数量
+	var req service.MaterialHoldDispositionRequest
+	if ctx.Request.ContentLength > 0 {
+		if err = ctx.ShouldBindJSON(&req); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	hold, err := handler(uint(id), &req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, message, hold)
+}
@@ -0,0 +1,462 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MrpController MRP控制器
+type MrpController struct {
+	mrpService *service.MrpService
+}
+
+// NewMrpController 创建MRP控制器实例
+func NewMrpController(mrpService *service.MrpService) *MrpController {
+	return &MrpController{
+		mrpService: mrpService,
+	}
+}
+
+// CreateDemand 创建产品需求
+// @Summary 创建产品需求
+// @Description 登记产品在指定日期的预测或确定需求，作为MRP的需求来源
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param demand body service.ProductDemandRequest true "产品需求信息"
+// @Success 200 {object} response.Response{data=service.ProductDemandResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/mrp/demands [post]
+func (c *MrpController) CreateDemand(ctx *gin.Context) {
+	var req service.ProductDemandRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	demand, err := c.mrpService.CreateDemand(&req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建产品需求成功", demand)
+}
+
+// GetDemandList 获取产品需求列表
+// @Summary 获取产品需求列表
+// @Description 分页获取产品需求，可按产品、需求类型和需求日期范围筛选
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param product_id query int false "产品ID"
+// @Param type query string false "需求类型(forecast/firm)"
+// @Param start_date query string false "开始日期(YYYY-MM-DD)"
+// @Param end_date query string false "结束日期(YYYY-MM-DD)"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/mrp/demands [get]
+func (c *MrpController) GetDemandList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	demandType := ctx.Query("type")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var productID uint
+	if idStr := ctx.Query("product_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的产品ID")
+			return
+		}
+		productID = uint(id)
+	}
+
+	var startDate, endDate *time.Time
+	if dateStr := ctx.Query("start_date"); dateStr != "" {
+		parsedDate, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的开始日期格式")
+			return
+		}
+		startDate = &parsedDate
+	}
+	if dateStr := ctx.Query("end_date"); dateStr != "" {
+		parsedDate, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的结束日期格式")
+			return
+		}
+		endDate = &parsedDate
+	}
+
+	demands, total, err := c.mrpService.GetDemandList(page, pageSize, productID, demandType, startDate, endDate)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, demands, total, page, pageSize, "获取产品需求列表成功")
+}
+
+// UpdateDemand 更新产品需求
+// @Summary 更新产品需求
+// @Description 更新产品需求的产品、日期、数量和类型
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param id path int true "产品需求ID"
+// @Param demand body service.ProductDemandRequest true "产品需求信息"
+// @Success 200 {object} response.Response{data=service.ProductDemandResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/mrp/demands/{id} [put]
+func (c *MrpController) UpdateDemand(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的产品需求ID")
+		return
+	}
+
+	var req service.ProductDemandRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	demand, err := c.mrpService.UpdateDemand(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新产品需求成功", demand)
+}
+
+// DeleteDemand 删除产品需求
+// @Summary 删除产品需求
+// @Description 删除产品需求
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param id path int true "产品需求ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/mrp/demands/{id} [delete]
+func (c *MrpController) DeleteDemand(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的产品需求ID")
+		return
+	}
+
+	if err := c.mrpService.DeleteDemand(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除产品需求成功", nil)
+}
+
+// GetProductMaterials 获取产品物料消耗定额
+// @Summary 获取产品物料消耗定额
+// @Description 获取生产单位产品所消耗的物料及数量
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param product_id path int true "产品ID"
+// @Success 200 {object} response.Response{data=[]service.ProductMaterialResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/mrp/products/{product_id}/materials [get]
+func (c *MrpController) GetProductMaterials(ctx *gin.Context) {
+	productID, err := strconv.ParseUint(ctx.Param("product_id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的产品ID")
+		return
+	}
+
+	materials, err := c.mrpService.GetProductMaterials(uint(productID))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取产品物料消耗定额成功", materials)
+}
+
+// SetProductMaterials 设置产品物料消耗定额
+// @Summary 设置产品物料消耗定额
+// @Description 整体替换产品的物料消耗定额，MRP按定额将计划生产展开为物料需求
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param product_id path int true "产品ID"
+// @Param materials body service.ProductMaterialsRequest true "物料消耗定额"
+// @Success 200 {object} response.Response{data=[]service.ProductMaterialResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/mrp/products/{product_id}/materials [put]
+func (c *MrpController) SetProductMaterials(ctx *gin.Context) {
+	productID, err := strconv.ParseUint(ctx.Param("product_id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的产品ID")
+		return
+	}
+
+	var req service.ProductMaterialsRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	materials, err := c.mrpService.SetProductMaterials(uint(productID), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "设置产品物料消耗定额成功", materials)
+}
+
+// RunMrp 运行MRP
+// @Summary 运行MRP
+// @Description 按产品需求、成品库存和未完工工单计算计划生产订单，按物料消耗定额、库存、最小库存和采购在途计算采购建议，新运行取代之前的运行结果
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param run body service.MrpRunRequest false "运行参数"
+// @Success 200 {object} response.Response{data=service.MrpRunResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/mrp/runs [post]
+func (c *MrpController) RunMrp(ctx *gin.Context) {
+	var req service.MrpRunRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	run, err := c.mrpService.RunMrp(&req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "MRP运行完成", run)
+}
+
+// GetRun 获取MRP运行结果
+// @Summary 获取MRP运行结果
+// @Description 获取MRP运行的计划生产订单和采购建议
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param id path int true "MRP运行ID"
+// @Success 200 {object} response.Response{data=service.MrpRunResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/mrp/runs/{id} [get]
+func (c *MrpController) GetRun(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的MRP运行ID")
+		return
+	}
+
+	run, err := c.mrpService.GetRun(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取MRP运行结果成功", run)
+}
+
+// GetRunList 获取MRP运行记录列表
+// @Summary 获取MRP运行记录列表
+// @Description 分页获取MRP运行记录
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query string false "状态(active/superseded)"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/mrp/runs [get]
+func (c *MrpController) GetRunList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	status := ctx.Query("status")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	runs, total, err := c.mrpService.GetRunList(page, pageSize, status)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, runs, total, page, pageSize, "获取MRP运行记录列表成功")
+}
+
+// FirmPlannedOrder 确认计划生产订单
+// @Summary 确认计划生产订单
+// @Description 确认最新MRP运行的计划生产订单，生成待开工的生产工单，可调整数量和开工日期
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param id path int true "计划生产订单ID"
+// @Param firm body service.MrpFirmPlannedOrderRequest false "确认信息"
+// @Success 200 {object} response.Response{data=service.MrpPlannedOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/mrp/planned-orders/{id}/firm [post]
+func (c *MrpController) FirmPlannedOrder(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的计划生产订单ID")
+		return
+	}
+
+	var req service.MrpFirmPlannedOrderRequest
+	if ctx.Request.ContentLength > 0 {
+		if err = ctx.ShouldBindJSON(&req); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	planned, err := c.mrpService.FirmPlannedOrder(uint(id), &req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "确认计划生产订单成功", planned)
+}
+
+// CancelPlannedOrder 取消计划生产订单
+// @Summary 取消计划生产订单
+// @Description 取消未确认的计划生产订单
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param id path int true "计划生产订单ID"
+// @Success 200 {object} response.Response{data=service.MrpPlannedOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/mrp/planned-orders/{id}/cancel [post]
+func (c *MrpController) CancelPlannedOrder(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的计划生产订单ID")
+		return
+	}
+
+	planned, err := c.mrpService.CancelPlannedOrder(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "取消计划生产订单成功", planned)
+}
+
+// FirmPurchaseSuggestion 确认采购建议
+// @Summary 确认采购建议
+// @Description 确认最新MRP运行的采购建议，生成草稿采购订单，要求到货日期为需求日期
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param id path int true "采购建议ID"
+// @Param firm body service.MrpFirmPurchaseRequest false "确认信息"
+// @Success 200 {object} response.Response{data=service.MrpPurchaseSuggestionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/mrp/purchase-suggestions/{id}/firm [post]
+func (c *MrpController) FirmPurchaseSuggestion(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的采购建议ID")
+		return
+	}
+
+	var req service.MrpFirmPurchaseRequest
+	if ctx.Request.ContentLength > 0 {
+		if err = ctx.ShouldBindJSON(&req); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	suggestion, err := c.mrpService.FirmPurchaseSuggestion(uint(id), &req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "确认采购建议成功", suggestion)
+}
+
+// CancelPurchaseSuggestion 取消采购建议
+// @Summary 取消采购建议
+// @Description 取消未确认的采购建议
+// @Tags MRP
+// @Accept json
+// @Produce json
+// @Param id path int true "采购建议ID"
+// @Success 200 {object} response.Response{data=service.MrpPurchaseSuggestionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/mrp/purchase-suggestions/{id}/cancel [post]
+func (c *MrpController) CancelPurchaseSuggestion(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的采购建议ID")
+		return
+	}
+
+	suggestion, err := c.mrpService.CancelPurchaseSuggestion(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "取消采购建议成功", suggestion)
+}
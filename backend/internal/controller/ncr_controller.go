@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NcrController 不合格品报告控制器
+type NcrController struct {
+	ncrService *service.NcrService
+}
+
+// NewNcrController 创建不合格品报告控制器实例
+func NewNcrController(ncrService *service.NcrService) *NcrController {
+	return &NcrController{
+		ncrService: ncrService,
+	}
+}
+
+// CreateNcr 创建不合格品报告
+// @Summary 创建不合格品报告
+// @Description 手工创建不合格品报告，可关联不合格的检测记录、生产工单或产品；不合格的检测结果会自动创建报告
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body service.NcrRequest true "不合格品报告信息"
+// @Success 200 {object} response.Response{data=service.NcrResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/ncrs [post]
+func (c *NcrController) CreateNcr(ctx *gin.Context) {
+	var req service.NcrRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, roleStr, ok := ncrOperator(ctx)
+	if !ok {
+		return
+	}
+
+	ncr, err := c.ncrService.CreateNcr(&req, userID, roleStr)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建不合格品报告成功", ncr)
+}
+
+// GetNcr 获取不合格品报告详情
+// @Summary 获取不合格品报告详情
+// @Description 获取不合格品报告详情及处置、审批操作记录
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param id path int true "不合格品报告ID"
+// @Success 200 {object} response.Response{data=service.NcrResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/quality/ncrs/{id} [get]
+func (c *NcrController) GetNcr(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的不合格品报告ID")
+		return
+	}
+
+	ncr, err := c.ncrService.GetNcr(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取不合格品报告详情成功", ncr)
+}
+
+// GetNcrList 获取不合格品报告列表
+// @Summary 获取不合格品报告列表
+// @Description 分页获取不合格品报告列表，按生产工单筛选时包含其生成的返工工单
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query string false "状态(open/submitted/approved/cancelled)"
+// @Param disposition query string false "处置方式(scrap/rework/use_as_is/return_to_supplier)"
+// @Param source query string false "来源(manual/inspection/sheet/lot)"
+// @Param production_order_id query int false "生产工单ID"
+// @Param product_id query int false "产品ID"
+// @Param keyword query string false "报告编号或描述"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/quality/ncrs [get]
+func (c *NcrController) GetNcrList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	status := ctx.Query("status")
+	disposition := ctx.Query("disposition")
+	source := ctx.Query("source")
+	keyword := ctx.Query("keyword")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var productionOrderID, productID uint
+	if idStr := ctx.Query("production_order_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的生产工单ID")
+			return
+		}
+		productionOrderID = uint(id)
+	}
+	if idStr := ctx.Query("product_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的产品ID")
+			return
+		}
+		productID = uint(id)
+	}
+
+	ncrs, total, err := c.ncrService.GetNcrList(page, pageSize, status, disposition, source, productionOrderID, productID, keyword)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, ncrs, total, page, pageSize, "获取不合格品报告列表成功")
+}
+
+// UpdateNcr 修改不合格品报告
+// @Summary 修改不合格品报告
+// @Description 修改待处置的不合格品报告的涉及数量、描述和围堵措施，自动创建的报告不能修改来源关联
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "不合格品报告ID"
+// @Param request body service.NcrRequest true "不合格品报告信息"
+// @Success 200 {object} response.Response{data=service.NcrResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/ncrs/{id} [put]
+func (c *NcrController) UpdateNcr(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的不合格品报告ID")
+		return
+	}
+
+	var req service.NcrRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, roleStr, ok := ncrOperator(ctx)
+	if !ok {
+		return
+	}
+
+	ncr, err := c.ncrService.UpdateNcr(uint(id), &req, userID, roleStr)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "修改不合格品报告成功", ncr)
+}
+
+// SubmitNcrDisposition 提交处置
+// @Summary 提交处置
+// @Description 为待处置的不合格品报告提交处置方式（报废、返工、让步接收、退供应商），等待审批
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "不合格品报告ID"
+// @Param request body service.NcrDispositionRequest true "处置信息"
+// @Success 200 {object} response.Response{data=service.NcrResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/ncrs/{id}/disposition [post]
+func (c *NcrController) SubmitNcrDisposition(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的不合格品报告ID")
+		return
+	}
+
+	var req service.NcrDispositionRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, roleStr, ok := ncrOperator(ctx)
+	if !ok {
+		return
+	}
+
+	ncr, err := c.ncrService.SubmitNcrDisposition(uint(id), &req, userID, roleStr)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "提交处置成功", ncr)
+}
+
+// ApproveNcr 批准处置
+// @Summary 批准处置
+// @Description 批准已提交的处置，让步接收仅管理员可批准；返工处置批准后按涉及数量生成返工生产工单，返工工单不展开物料需求，报工不登记成品入库
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "不合格品报告ID"
+// @Param request body service.NcrApprovalRequest false "审批意见"
+// @Success 200 {object} response.Response{data=service.NcrResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/ncrs/{id}/approve [post]
+func (c *NcrController) ApproveNcr(ctx *gin.Context) {
+	c.review(ctx, "批准处置成功", c.ncrService.ApproveNcr)
+}
+
+// RejectNcr 驳回处置
+// @Summary 驳回处置
+// @Description 驳回已提交的处置，报告回到待处置状态，需填写审批意见
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "不合格品报告ID"
+// @Param request body service.NcrApprovalRequest true "审批意见"
+// @Success 200 {object} response.Response{data=service.NcrResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/ncrs/{id}/reject [post]
+func (c *NcrController) RejectNcr(ctx *gin.Context) {
+	c.review(ctx, "驳回处置成功", c.ncrService.RejectNcr)
+}
+
+// CancelNcr 取消不合格品报告
+// @Summary 取消不合格品报告
+// @Description 取消待处置或待审批的不合格品报告
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "不合格品报告ID"
+// @Param request body service.NcrApprovalRequest false "取消原因"
+// @Success 200 {object} response.Response{data=service.NcrResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/ncrs/{id}/cancel [post]
+func (c *NcrController) CancelNcr(ctx *gin.Context) {
+	c.review(ctx, "取消不合格品报告成功", c.ncrService.CancelNcr)
+}
+
+// 辅助函数：处理批准、驳回和取消请求
+func (c *NcrController) review(ctx *gin.Context, message string,
+	handler func(uint, *service.NcrApprovalRequest, uint, string) (*service.NcrResponse, error)) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的不合格品报告ID")
+		return
+	}
+
+	// 请求体可为空，驳回时由服务层校验审批意见
+	var req service.NcrApprovalRequest
+	if ctx.Request.ContentLength > 0 {
+		if err = ctx.ShouldBindJSON(&req); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	userID, roleStr, ok := ncrOperator(ctx)
+	if !ok {
+		return
+	}
+
+	ncr, err := handler(uint(id), &req, userID, roleStr)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, message, ncr)
+}
+
+// 辅助函数：获取当前操作人及角色，未登录时返回错误响应
+func ncrOperator(ctx *gin.Context) (uint, string, bool) {
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return 0, "", false
+	}
+	role, _ := ctx.Get("role")
+	roleStr, _ := role.(string)
+	return userID.(uint), roleStr, true
+}
@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProductStockController 成品库存控制器
+type ProductStockController struct {
+	productStockService *service.ProductStockService
+}
+
+// NewProductStockController 创建成品库存控制器实例
+func NewProductStockController(productStockService *service.ProductStockService) *ProductStockController {
+	return &ProductStockController{
+		productStockService: productStockService,
+	}
+}
+
+// CreateTransaction 登记成品交易
+// @Summary 登记成品交易
+// @Description 手工登记成品报废和盘点调整，生产入库由生产工单报工自动登记
+// @Tags 成品库存
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param transaction body service.ProductTransactionRequest true "成品交易信息"
+// @Success 200 {object} response.Response{data=service.ProductTransactionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/products/transactions [post]
+func (c *ProductStockController) CreateTransaction(ctx *gin.Context) {
+	var req service.ProductTransactionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	transaction, err := c.productStockService.CreateTransaction(&req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "登记成品交易成功", transaction)
+}
+
+// GetTransactionList 获取成品交易列表
+// @Summary 获取成品交易列表
+// @Description 分页获取成品库存交易流水
+// @Tags 成品库存
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param product_id query int false "产品ID"
+// @Param production_order_id query int false "生产工单ID"
+// @Param type query string false "交易类型(production_receipt/production_reversal/scrap/adjust_in/adjust_out)"
+// @Param lot_no query string false "成品批次号"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/products/transactions [get]
+func (c *ProductStockController) GetTransactionList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	transactionType := ctx.Query("type")
+	lotNo := ctx.Query("lot_no")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var productID uint
+	if idStr := ctx.Query("product_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的产品ID")
+			return
+		}
+		productID = uint(id)
+	}
+
+	var productionOrderID uint
+	if idStr := ctx.Query("production_order_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的生产工单ID")
+			return
+		}
+		productionOrderID = uint(id)
+	}
+
+	transactions, total, err := c.productStockService.GetTransactionList(page, pageSize, productID, productionOrderID, transactionType, lotNo)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, transactions, total, page, pageSize, "获取成品交易列表成功")
+}
+
+// GetTransactionTypes 获取成品交易类型
+// @Summary 获取成品交易类型
+// @Description 获取成品交易类型及可手工选择的原因代码
+// @Tags 成品库存
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]service.TransactionTypeResponse}
+// @Router /api/products/transaction-types [get]
+func (c *ProductStockController) GetTransactionTypes(ctx *gin.Context) {
+	response.SuccessWithMessage(ctx, "获取成品交易类型成功", c.productStockService.GetTransactionTypes())
+}
+
+// GetProductStock 获取产品成品库存
+// @Summary 获取产品成品库存
+// @Description 获取产品的成品库存及按批次的结存
+// @Tags 成品库存
+// @Accept json
+// @Produce json
+// @Param id path int true "产品ID"
+// @Success 200 {object} response.Response{data=service.ProductStockResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/products/{id}/stock [get]
+func (c *ProductStockController) GetProductStock(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的产品ID")
+		return
+	}
+
+	stock, err := c.productStockService.GetProductStock(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取成品库存成功", stock)
+}
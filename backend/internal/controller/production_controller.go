@@ -135,7 +135,13 @@ func (ctrl *ProductionController) UpdateProductionOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := ctrl.productionService.UpdateProductionOrder(uint(id), &req)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		response.Unauthorized(c, "用户未登录")
+		return
+	}
+
+	order, err := ctrl.productionService.UpdateProductionOrder(uint(id), &req, userID.(uint))
 	if err != nil {
 		response.BadRequest(c, err.Error())
 		return
@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PurchaseOrderController 采购订单控制器
+type PurchaseOrderController struct {
+	purchaseOrderService *service.PurchaseOrderService
+}
+
+// NewPurchaseOrderController 创建采购订单控制器实例
+func NewPurchaseOrderController(purchaseOrderService *service.PurchaseOrderService) *PurchaseOrderController {
+	return &PurchaseOrderController{
+		purchaseOrderService: purchaseOrderService,
+	}
+}
+
+// CreatePurchaseOrder 创建采购订单
+// @Summary 创建采购订单
+// @Description 创建草稿状态的采购订单及订单行
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param order body service.PurchaseOrderRequest true "采购订单信息"
+// @Success 200 {object} response.Response{data=service.PurchaseOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/purchase-orders [post]
+func (c *PurchaseOrderController) CreatePurchaseOrder(ctx *gin.Context) {
+	var req service.PurchaseOrderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	order, err := c.purchaseOrderService.CreatePurchaseOrder(&req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建采购订单成功", order)
+}
+
+// GetPurchaseOrder 获取采购订单详情
+// @Summary 获取采购订单详情
+// @Description 获取采购订单及订单行的收货情况
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "采购订单ID"
+// @Success 200 {object} response.Response{data=service.PurchaseOrderResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/purchase-orders/{id} [get]
+func (c *PurchaseOrderController) GetPurchaseOrder(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的采购订单ID")
+		return
+	}
+
+	order, err := c.purchaseOrderService.GetPurchaseOrder(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取采购订单详情成功", order)
+}
+
+// GetPurchaseOrderList 获取采购订单列表
+// @Summary 获取采购订单列表
+// @Description 分页获取采购订单列表，支持按状态筛选和按订单号、供应商搜索
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query string false "状态(draft/released/partial/received/closed/cancelled)"
+// @Param keyword query string false "订单号或供应商"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/purchase-orders [get]
+func (c *PurchaseOrderController) GetPurchaseOrderList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	status := ctx.Query("status")
+	keyword := ctx.Query("keyword")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	orders, total, err := c.purchaseOrderService.GetPurchaseOrderList(page, pageSize, status, keyword)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, orders, total, page, pageSize, "获取采购订单列表成功")
+}
+
+// UpdatePurchaseOrder 更新采购订单
+// @Summary 更新采购订单
+// @Description 更新草稿状态的采购订单，订单行整体替换
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "采购订单ID"
+// @Param order body service.PurchaseOrderRequest true "采购订单信息"
+// @Success 200 {object} response.Response{data=service.PurchaseOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/purchase-orders/{id} [put]
+func (c *PurchaseOrderController) UpdatePurchaseOrder(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的采购订单ID")
+		return
+	}
+
+	var req service.PurchaseOrderRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	order, err := c.purchaseOrderService.UpdatePurchaseOrder(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新采购订单成功", order)
+}
+
+// DeletePurchaseOrder 删除采购订单
+// @Summary 删除采购订单
+// @Description 删除草稿或已取消的采购订单
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "采购订单ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/purchase-orders/{id} [delete]
+func (c *PurchaseOrderController) DeletePurchaseOrder(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的采购订单ID")
+		return
+	}
+
+	if err := c.purchaseOrderService.DeletePurchaseOrder(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除采购订单成功", nil)
+}
+
+// ReleasePurchaseOrder 下达采购订单
+// @Summary 下达采购订单
+// @Description 下达草稿状态的采购订单，下达后可按订单行收货
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "采购订单ID"
+// @Success 200 {object} response.Response{data=service.PurchaseOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/purchase-orders/{id}/release [post]
+func (c *PurchaseOrderController) ReleasePurchaseOrder(ctx *gin.Context) {
+	c.changeStatus(ctx, c.purchaseOrderService.ReleasePurchaseOrder, "下达采购订单成功")
+}
+
+// ClosePurchaseOrder 关闭采购订单
+// @Summary 关闭采购订单
+// @Description 关闭采购订单，未收数量不再收货
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "采购订单ID"
+// @Success 200 {object} response.Response{data=service.PurchaseOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/purchase-orders/{id}/close [post]
+func (c *PurchaseOrderController) ClosePurchaseOrder(ctx *gin.Context) {
+	c.changeStatus(ctx, c.purchaseOrderService.ClosePurchaseOrder, "关闭采购订单成功")
+}
+
+// CancelPurchaseOrder 取消采购订单
+// @Summary 取消采购订单
+// @Description 取消尚未收货的采购订单
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param id path int true "采购订单ID"
+// @Success 200 {object} response.Response{data=service.PurchaseOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/purchase-orders/{id}/cancel [post]
+func (c *PurchaseOrderController) CancelPurchaseOrder(ctx *gin.Context) {
+	c.changeStatus(ctx, c.purchaseOrderService.CancelPurchaseOrder, "取消采购订单成功")
+}
+
+// GetOverdueLines 获取逾期采购订单行
+// @Summary 获取逾期采购订单行
+// @Description 获取要求到货日期已过但尚未收齐的采购订单行
+// @Tags 采购管理
+// @Accept json
+// @Produce json
+// @Param as_of query string false "统计日期(YYYY-MM-DD)，默认为今天"
+// @Param supplier query string false "供应商"
+// @Param material_id query int false "物料ID"
+// @Success 200 {object} response.Response{data=[]service.OverduePurchaseOrderLine}
+// @Failure 400 {object} response.Response
+// @Router /api/purchase-orders/overdue-lines [get]
+func (c *PurchaseOrderController) GetOverdueLines(ctx *gin.Context) {
+	asOf := time.Now()
+	if asOfStr := ctx.Query("as_of"); asOfStr != "" {
+		parsedDate, err := time.ParseInLocation("2006-01-02", asOfStr, time.Local)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的日期格式")
+			return
+		}
+		asOf = parsedDate
+	}
+
+	materialID, ok := parseMaterialIDQuery(ctx)
+	if !ok {
+		return
+	}
+
+	lines, err := c.purchaseOrderService.GetOverdueLines(asOf, ctx.Query("supplier"), materialID)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取逾期采购订单行成功", lines)
+}
+
+// 辅助函数：解析采购订单ID并执行状态变更
+func (c *PurchaseOrderController) changeStatus(ctx *gin.Context, action func(uint) (*service.PurchaseOrderResponse, error), message string) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的采购订单ID")
+		return
+	}
+
+	order, err := action(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, message, order)
+}
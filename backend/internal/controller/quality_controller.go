@@ -194,10 +194,11 @@ func (c *QualityController) DeleteQualityStandard(ctx *gin.Context) {
 
 // CreateQualityInspection 创建质量检测记录
 // @Summary 创建质量检测记录
-// @Description 创建新的质量检测记录
+// @Description 创建新的质量检测记录，检测结果由系统按质量标准的规格限判定；填写与判定不一致的结果视为人工改判，需管理员或主管权限并填写原因
 // @Tags 质量管理
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param inspection body service.QualityInspectionRequest true "质量检测信息"
 // @Success 200 {object} response.Response{data=service.QualityInspectionResponse}
 // @Failure 400 {object} response.Response
@@ -209,7 +210,15 @@ func (c *QualityController) CreateQualityInspection(ctx *gin.Context) {
 		return
 	}
 
-	inspection, err := c.qualityService.CreateQualityInspection(&req)
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+	role, _ := ctx.Get("role")
+	roleStr, _ := role.(string)
+
+	inspection, err := c.qualityService.CreateQualityInspection(&req, userID.(uint), roleStr)
 	if err != nil {
 		response.Error(ctx, http.StatusBadRequest, err.Error())
 		return
@@ -256,6 +265,7 @@ func (c *QualityController) GetQualityInspection(ctx *gin.Context) {
 // @Param production_order_id query int false "生产工单ID"
 // @Param quality_standard_id query int false "质量标准ID"
 // @Param inspector_id query int false "检测员ID"
+// @Param inspection_sheet_id query int false "检测单ID"
 // @Param result query string false "检测结果(pass/fail)"
 // @Success 200 {object} response.Response{data=response.PageResponse}
 // @Router /api/quality/inspections [get]
@@ -265,9 +275,10 @@ func (c *QualityController) GetQualityInspectionList(ctx *gin.Context) {
 	productionOrderIDStr := ctx.Query("production_order_id")
 	qualityStandardIDStr := ctx.Query("quality_standard_id")
 	inspectorIDStr := ctx.Query("inspector_id")
+	inspectionSheetIDStr := ctx.Query("inspection_sheet_id")
 	result := ctx.Query("result")
 
-	var productionOrderID, qualityStandardID, inspectorID uint
+	var productionOrderID, qualityStandardID, inspectorID, inspectionSheetID uint
 
 	if productionOrderIDStr != "" {
 		id, err := strconv.ParseUint(productionOrderIDStr, 10, 32)
@@ -296,6 +307,15 @@ func (c *QualityController) GetQualityInspectionList(ctx *gin.Context) {
 		inspectorID = uint(id)
 	}
 
+	if inspectionSheetIDStr != "" {
+		id, err := strconv.ParseUint(inspectionSheetIDStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的检测单ID")
+			return
+		}
+		inspectionSheetID = uint(id)
+	}
+
 	if page <= 0 {
 		page = 1
 	}
@@ -303,7 +323,7 @@ func (c *QualityController) GetQualityInspectionList(ctx *gin.Context) {
 		pageSize = 10
 	}
 
-	inspections, total, err := c.qualityService.GetQualityInspectionList(page, pageSize, productionOrderID, qualityStandardID, inspectorID, result)
+	inspections, total, err := c.qualityService.GetQualityInspectionList(page, pageSize, productionOrderID, qualityStandardID, inspectorID, inspectionSheetID, result)
 	if err != nil {
 		response.Error(ctx, http.StatusInternalServerError, err.Error())
 		return
@@ -314,7 +334,7 @@ func (c *QualityController) GetQualityInspectionList(ctx *gin.Context) {
 
 // GetQualityStatistics 获取质量统计数据
 // @Summary 获取质量统计数据
-// @Description 获取质量检测统计数据，支持时间范围和条件筛选
+// @Description 获取质量检测统计数据，支持时间范围和条件筛选，可按检测明细或检测单层级统计
 // @Tags 质量管理
 // @Accept json
 // @Produce json
@@ -322,6 +342,7 @@ func (c *QualityController) GetQualityInspectionList(ctx *gin.Context) {
 // @Param end_date query string false "结束日期(YYYY-MM-DD)"
 // @Param production_order_id query int false "生产工单ID"
 // @Param quality_standard_id query int false "质量标准ID"
+// @Param level query string false "统计层级(line:检测明细 sheet:检测单)" default(line)
 // @Success 200 {object} response.Response{data=service.QualityStatistics}
 // @Router /api/quality/statistics [get]
 func (c *QualityController) GetQualityStatistics(ctx *gin.Context) {
@@ -329,6 +350,12 @@ func (c *QualityController) GetQualityStatistics(ctx *gin.Context) {
 	endDateStr := ctx.Query("end_date")
 	productionOrderIDStr := ctx.Query("production_order_id")
 	qualityStandardIDStr := ctx.Query("quality_standard_id")
+	level := ctx.DefaultQuery("level", "line")
+
+	if level != "line" && level != "sheet" {
+		response.Error(ctx, http.StatusBadRequest, "无效的统计层级")
+		return
+	}
 
 	var startDate, endDate *time.Time
 	var productionOrderID, qualityStandardID uint
@@ -371,7 +398,7 @@ func (c *QualityController) GetQualityStatistics(ctx *gin.Context) {
 		qualityStandardID = uint(id)
 	}
 
-	statistics, err := c.qualityService.GetQualityStatistics(startDate, endDate, productionOrderID, qualityStandardID)
+	statistics, err := c.qualityService.GetQualityStatistics(startDate, endDate, productionOrderID, qualityStandardID, level)
 	if err != nil {
 		response.Error(ctx, http.StatusInternalServerError, err.Error())
 		return
@@ -400,10 +427,11 @@ func (c *QualityController) GetQualityStandardTypes(ctx *gin.Context) {
 
 // UpdateQualityInspection 更新质量检测
 // @Summary 更新质量检测
-// @Description 更新指定ID的质量检测信息
+// @Description 更新指定ID的质量检测信息，按更新后的测量值重新判定检测结果，测量值变化时重新判异；原记录经人工改判时不填结果则保留改判结果，撤销或变更改判需管理员或主管权限并填写原因
 // @Tags 质量管理
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path int true "质量检测ID"
 // @Param inspection body service.QualityInspectionRequest true "质量检测信息"
 // @Success 200 {object} response.Response{data=service.QualityInspectionResponse}
@@ -423,7 +451,15 @@ func (c *QualityController) UpdateQualityInspection(ctx *gin.Context) {
 		return
 	}
 
-	inspection, err := c.qualityService.UpdateQualityInspection(uint(id), &req)
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+	role, _ := ctx.Get("role")
+	roleStr, _ := role.(string)
+
+	inspection, err := c.qualityService.UpdateQualityInspection(uint(id), &req, userID.(uint), roleStr)
 	if err != nil {
 		response.Error(ctx, http.StatusBadRequest, err.Error())
 		return
@@ -432,6 +468,32 @@ func (c *QualityController) UpdateQualityInspection(ctx *gin.Context) {
 	response.SuccessWithMessage(ctx, "更新质量检测成功", inspection)
 }
 
+// GetResultOverrides 获取检测结果改判记录
+// @Summary 获取检测结果改判记录
+// @Description 获取质量检测记录的人工改判审计记录，包括系统判定结果、改判结果、原因和操作人；撤销改判时改判结果与系统判定结果相同
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param id path int true "质量检测ID"
+// @Success 200 {object} response.Response{data=[]models.QualityResultOverride}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/inspections/{id}/overrides [get]
+func (c *QualityController) GetResultOverrides(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的质量检测ID")
+		return
+	}
+
+	overrides, err := c.qualityService.GetResultOverrides(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取改判记录成功", overrides)
+}
+
 // DeleteQualityInspection 删除质量检测
 // @Summary 删除质量检测
 // @Description 删除指定ID的质量检测
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QualityEventController 质量异常事件控制器
+type QualityEventController struct {
+	qualityEventService *service.QualityEventService
+}
+
+// NewQualityEventController 创建质量异常事件控制器实例
+func NewQualityEventController(qualityEventService *service.QualityEventService) *QualityEventController {
+	return &QualityEventController{
+		qualityEventService: qualityEventService,
+	}
+}
+
+// GetQualityEventList 获取质量异常事件列表
+// @Summary 获取质量异常事件列表
+// @Description 分页获取测量值触发SPC判异规则产生的质量异常事件
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query string false "状态(open/acknowledged)"
+// @Param quality_standard_id query int false "质量标准ID"
+// @Param production_order_id query int false "生产工单ID"
+// @Param rule query int false "判异规则编号(1-8)"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/events [get]
+func (c *QualityEventController) GetQualityEventList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	status := ctx.Query("status")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var qualityStandardID, productionOrderID uint
+	if idStr := ctx.Query("quality_standard_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的质量标准ID")
+			return
+		}
+		qualityStandardID = uint(id)
+	}
+	if idStr := ctx.Query("production_order_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的生产工单ID")
+			return
+		}
+		productionOrderID = uint(id)
+	}
+
+	var rule int
+	if ruleStr := ctx.Query("rule"); ruleStr != "" {
+		parsed, err := strconv.Atoi(ruleStr)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的判异规则编号")
+			return
+		}
+		rule = parsed
+	}
+
+	events, total, err := c.qualityEventService.GetQualityEventList(page, pageSize, status, qualityStandardID, productionOrderID, rule)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, events, total, page, pageSize, "获取质量异常事件列表成功")
+}
+
+// GetQualityEvent 获取质量异常事件详情
+// @Summary 获取质量异常事件详情
+// @Description 获取质量异常事件详情，包括触发的规则、测量值和确认信息
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param id path int true "事件ID"
+// @Success 200 {object} response.Response{data=service.QualityEventResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/quality/events/{id} [get]
+func (c *QualityEventController) GetQualityEvent(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的事件ID")
+		return
+	}
+
+	event, err := c.qualityEventService.GetQualityEvent(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取质量异常事件详情成功", event)
+}
+
+// AcknowledgeQualityEvent 确认质量异常事件
+// @Summary 确认质量异常事件
+// @Description 确认待处理的质量异常事件并填写说明，确认后不再阻止相关生产工单完成
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "事件ID"
+// @Param request body service.AcknowledgeQualityEventRequest false "确认说明"
+// @Success 200 {object} response.Response{data=service.QualityEventResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/events/{id}/acknowledge [post]
+func (c *QualityEventController) AcknowledgeQualityEvent(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的事件ID")
+		return
+	}
+
+	var req service.AcknowledgeQualityEventRequest
+	if ctx.Request.ContentLength > 0 {
+		if err = ctx.ShouldBindJSON(&req); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	event, err := c.qualityEventService.AcknowledgeQualityEvent(uint(id), &req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "确认质量异常事件成功", event)
+}
+
+// GetSpcRules 获取判异规则
+// @Summary 获取判异规则
+// @Description 获取可在质量标准上配置的Nelson判异规则编号及说明
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=map[int]string}
+// @Router /api/quality/events/rules [get]
+func (c *QualityEventController) GetSpcRules(ctx *gin.Context) {
+	response.SuccessWithMessage(ctx, "获取判异规则成功", c.qualityEventService.GetSpcRuleOptions())
+}
@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReplenishmentController 补货控制器
+type ReplenishmentController struct {
+	replenishmentService *service.ReplenishmentService
+}
+
+// NewReplenishmentController 创建补货控制器实例
+func NewReplenishmentController(replenishmentService *service.ReplenishmentService) *ReplenishmentController {
+	return &ReplenishmentController{
+		replenishmentService: replenishmentService,
+	}
+}
+
+// RunReplenishment 运行补货计算
+// @Summary 运行补货计算
+// @Description 立即按最小/最大库存、日均消耗和采购提前期计算补货建议，待审核的建议按最新库存刷新
+// @Tags 补货管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=service.ReplenishmentRunResult}
+// @Failure 500 {object} response.Response
+// @Router /api/replenishment/run [post]
+func (c *ReplenishmentController) RunReplenishment(ctx *gin.Context) {
+	result, err := c.replenishmentService.RunReplenishment("manual")
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "补货计算完成", result)
+}
+
+// GetProposal 获取补货建议详情
+// @Summary 获取补货建议详情
+// @Description 根据ID获取补货建议详情
+// @Tags 补货管理
+// @Accept json
+// @Produce json
+// @Param id path int true "补货建议ID"
+// @Success 200 {object} response.Response{data=service.ReplenishmentProposalResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/replenishment/proposals/{id} [get]
+func (c *ReplenishmentController) GetProposal(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的补货建议ID")
+		return
+	}
+
+	proposal, err := c.replenishmentService.GetProposal(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取补货建议成功", proposal)
+}
+
+// GetProposalList 获取补货建议列表
+// @Summary 获取补货建议列表
+// @Description 分页获取补货建议列表
+// @Tags 补货管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query string false "状态(pending/approved/rejected/closed)"
+// @Param material_id query int false "物料ID"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/replenishment/proposals [get]
+func (c *ReplenishmentController) GetProposalList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	status := ctx.Query("status")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	materialID, ok := parseMaterialIDQuery(ctx)
+	if !ok {
+		return
+	}
+
+	proposals, total, err := c.replenishmentService.GetProposalList(page, pageSize, status, materialID)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, proposals, total, page, pageSize, "获取补货建议列表成功")
+}
+
+// ExportProposals 导出补货建议
+// @Summary 导出补货建议
+// @Description 以CSV格式导出补货建议
+// @Tags 补货管理
+// @Produce text/csv
+// @Param status query string false "状态(pending/approved/rejected/closed)"
+// @Param material_id query int false "物料ID"
+// @Success 200 {file} file
+// @Failure 400 {object} response.Response
+// @Router /api/replenishment/proposals/export [get]
+func (c *ReplenishmentController) ExportProposals(ctx *gin.Context) {
+	materialID, ok := parseMaterialIDQuery(ctx)
+	if !ok {
+		return
+	}
+
+	proposals, err := c.replenishmentService.ExportProposals(ctx.Query("status"), materialID)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	header := []string{"建议单号", "物料编码", "物料名称", "单位", "最小库存", "最大库存", "在库数量", "在途数量",
+		"日均消耗", "可供天数", "提前期(天)", "再订货点", "建议数量", "批准数量", "状态", "生成时间", "审核人", "审核意见"}
+	rows := make([][]string, 0, len(proposals))
+	for _, p := range proposals {
+		daysOfCover := ""
+		if p.DaysOfCover != nil {
+			daysOfCover = strconv.FormatFloat(*p.DaysOfCover, 'f', 2, 64)
+		}
+		rows = append(rows, []string{
+			p.ProposalNo,
+			p.MaterialCode,
+			p.MaterialName,
+			p.Unit,
+			formatQuantity(p.MinStock),
+			formatQuantity(p.MaxStock),
+			formatQuantity(p.OnHandStock),
+			formatQuantity(p.OpenOrderQuantity),
+			formatQuantity(p.AvgDailyConsumption),
+			daysOfCover,
+			strconv.Itoa(p.LeadTimeDays),
+			formatQuantity(p.ReorderPoint),
+			formatQuantity(p.ProposedQuantity),
+			formatQuantity(p.ApprovedQuantity),
+			p.Status,
+			p.GeneratedAt.Format("2006-01-02 15:04:05"),
+			p.ReviewerName,
+			p.Remark,
+		})
+	}
+
+	response.CSV(ctx, fmt.Sprintf("replenishment_%s.csv", time.Now().Format("20060102")), header, rows)
+}
+
+// ApproveProposal 批准补货建议
+// @Summary 批准补货建议
+// @Description 批准待审核的补货建议，可调整批准数量
+// @Tags 补货管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "补货建议ID"
+// @Param review body service.ReplenishmentReviewRequest false "审核信息"
+// @Success 200 {object} response.Response{data=service.ReplenishmentProposalResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/replenishment/proposals/{id}/approve [post]
+func (c *ReplenishmentController) ApproveProposal(ctx *gin.Context) {
+	c.review(ctx, "批准补货建议成功", c.replenishmentService.ApproveProposal)
+}
+
+// RejectProposal 驳回补货建议
+// @Summary 驳回补货建议
+// @Description 驳回待审核的补货建议
+// @Tags 补货管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "补货建议ID"
+// @Param review body service.ReplenishmentReviewRequest false "审核信息"
+// @Success 200 {object} response.Response{data=service.ReplenishmentProposalResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/replenishment/proposals/{id}/reject [post]
+func (c *ReplenishmentController) RejectProposal(ctx *gin.Context) {
+	c.review(ctx, "驳回补货建议成功", c.replenishmentService.RejectProposal)
+}
+
+// 辅助函数：处理补货建议审核请求
+func (c *ReplenishmentController) review(ctx *gin.Context, message string,
+	handler func(uint, *service.ReplenishmentReviewRequest, uint) (*service.ReplenishmentProposalResponse, error)) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的补货建议ID")
+		return
+	}
+
+	// 请求体可为空，表示按建议数量批准
+	var req service.ReplenishmentReviewRequest
+	if ctx.Request.ContentLength > 0 {
+		if err = ctx.ShouldBindJSON(&req); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	proposal, err := handler(uint(id), &req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, message, proposal)
+}
@@ -0,0 +1,316 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequisitionController 领料单控制器
+type RequisitionController struct {
+	requisitionService *service.RequisitionService
+}
+
+// NewRequisitionController 创建领料单控制器实例
+func NewRequisitionController(requisitionService *service.RequisitionService) *RequisitionController {
+	return &RequisitionController{
+		requisitionService: requisitionService,
+	}
+}
+
+// CreateRequisition 创建领料单
+// @Summary 创建领料单
+// @Description 为生产工单或成本中心申请领料，创建后待审批
+// @Tags 领料管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param requisition body service.RequisitionRequest true "领料单信息"
+// @Success 200 {object} response.Response{data=service.RequisitionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/requisitions [post]
+func (c *RequisitionController) CreateRequisition(ctx *gin.Context) {
+	var req service.RequisitionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	requisition, err := c.requisitionService.CreateRequisition(&req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建领料单成功", requisition)
+}
+
+// GetRequisition 获取领料单详情
+// @Summary 获取领料单详情
+// @Description 根据ID获取领料单详情，包含明细行和发料记录
+// @Tags 领料管理
+// @Accept json
+// @Produce json
+// @Param id path int true "领料单ID"
+// @Success 200 {object} response.Response{data=service.RequisitionResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/requisitions/{id} [get]
+func (c *RequisitionController) GetRequisition(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的领料单ID")
+		return
+	}
+
+	requisition, err := c.requisitionService.GetRequisition(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取领料单成功", requisition)
+}
+
+// GetRequisitionList 获取领料单列表
+// @Summary 获取领料单列表
+// @Description 分页获取领料单列表
+// @Tags 领料管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query string false "状态(pending/approved/partial/issued/closed/rejected/cancelled)"
+// @Param production_order_id query int false "生产工单ID"
+// @Param cost_center query string false "成本中心"
+// @Param requested_by query int false "申请人ID"
+// @Param keyword query string false "领料单号关键词"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/requisitions [get]
+func (c *RequisitionController) GetRequisitionList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	status := ctx.Query("status")
+	costCenter := ctx.Query("cost_center")
+	keyword := ctx.Query("keyword")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var productionOrderID uint
+	if idStr := ctx.Query("production_order_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的生产工单ID")
+			return
+		}
+		productionOrderID = uint(id)
+	}
+
+	var requestedBy uint
+	if idStr := ctx.Query("requested_by"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的申请人ID")
+			return
+		}
+		requestedBy = uint(id)
+	}
+
+	requisitions, total, err := c.requisitionService.GetRequisitionList(page, pageSize, status, costCenter, keyword, productionOrderID, requestedBy)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, requisitions, total, page, pageSize, "获取领料单列表成功")
+}
+
+// ApproveRequisition 批准领料单
+// @Summary 批准领料单
+// @Description 批准待审批的领料单，批准后仓库可以发料
+// @Tags 领料管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "领料单ID"
+// @Param review body service.RequisitionReviewRequest false "审批意见"
+// @Success 200 {object} response.Response{data=service.RequisitionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/requisitions/{id}/approve [post]
+func (c *RequisitionController) ApproveRequisition(ctx *gin.Context) {
+	c.review(ctx, "批准领料单成功", c.requisitionService.ApproveRequisition)
+}
+
+// RejectRequisition 驳回领料单
+// @Summary 驳回领料单
+// @Description 驳回待审批的领料单
+// @Tags 领料管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "领料单ID"
+// @Param review body service.RequisitionReviewRequest false "审批意见"
+// @Success 200 {object} response.Response{data=service.RequisitionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/requisitions/{id}/reject [post]
+func (c *RequisitionController) RejectRequisition(ctx *gin.Context) {
+	c.review(ctx, "驳回领料单成功", c.requisitionService.RejectRequisition)
+}
+
+// CancelRequisition 取消领料单
+// @Summary 取消领料单
+// @Description 取消尚未发料的领料单，仅申请人或管理员、主管可以取消
+// @Tags 领料管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "领料单ID"
+// @Success 200 {object} response.Response{data=service.RequisitionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/requisitions/{id}/cancel [post]
+func (c *RequisitionController) CancelRequisition(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的领料单ID")
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+	role, _ := ctx.Get("role")
+	roleStr, _ := role.(string)
+
+	requisition, err := c.requisitionService.CancelRequisition(uint(id), userID.(uint), roleStr)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "取消领料单成功", requisition)
+}
+
+// IssueRequisition 按领料单发料
+// @Summary 按领料单发料
+// @Description 按已批准的领料单发料，允许部分发料，生成的出库交易关联领料单
+// @Tags 领料管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "领料单ID"
+// @Param issue body service.RequisitionIssueRequest true "发料信息"
+// @Success 200 {object} response.Response{data=service.RequisitionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/requisitions/{id}/issue [post]
+func (c *RequisitionController) IssueRequisition(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的领料单ID")
+		return
+	}
+
+	var req service.RequisitionIssueRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	requisition, err := c.requisitionService.IssueRequisition(uint(id), &req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "发料成功", requisition)
+}
+
+// CloseRequisition 关闭领料单
+// @Summary 关闭领料单
+// @Description 关闭已批准或部分发料的领料单，剩余未发数量不再发料
+// @Tags 领料管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "领料单ID"
+// @Param review body service.RequisitionReviewRequest false "关闭说明"
+// @Success 200 {object} response.Response{data=service.RequisitionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/requisitions/{id}/close [post]
+func (c *RequisitionController) CloseRequisition(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的领料单ID")
+		return
+	}
+
+	var req service.RequisitionReviewRequest
+	if ctx.Request.ContentLength > 0 {
+		if err = ctx.ShouldBindJSON(&req); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	requisition, err := c.requisitionService.CloseRequisition(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "关闭领料单成功", requisition)
+}
+
+// 辅助函数：处理领料单审批请求
+func (c *RequisitionController) review(ctx *gin.Context, message string,
+	handler func(uint, *service.RequisitionReviewRequest, uint) (*service.RequisitionResponse, error)) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的领料单ID")
+		return
+	}
+
+	// 请求体可为空，表示不填写审批意见
+	var req service.RequisitionReviewRequest
+	if ctx.Request.ContentLength > 0 {
+		if err = ctx.ShouldBindJSON(&req); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	requisition, err := handler(uint(id), &req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, message, requisition)
+}
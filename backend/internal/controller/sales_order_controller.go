@@ -0,0 +1,342 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SalesOrderController 销售订单控制器
+type SalesOrderController struct {
+	salesOrderService *service.SalesOrderService
+}
+
+// NewSalesOrderController 创建销售订单控制器实例
+func NewSalesOrderController(salesOrderService *service.SalesOrderService) *SalesOrderController {
+	return &SalesOrderController{
+		salesOrderService: salesOrderService,
+	}
+}
+
+// CreateSalesOrder 创建销售订单
+// @Summary 创建销售订单
+// @Description 创建草稿状态的销售订单，订单行包含产品、数量和交货日期
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param order body service.SalesOrderRequest true "销售订单信息"
+// @Success 200 {object} response.Response{data=service.SalesOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/sales-orders [post]
+func (c *SalesOrderController) CreateSalesOrder(ctx *gin.Context) {
+	var req service.SalesOrderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	order, err := c.salesOrderService.CreateSalesOrder(&req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建销售订单成功", order)
+}
+
+// GetSalesOrder 获取销售订单详情
+// @Summary 获取销售订单详情
+// @Description 获取销售订单及订单行，订单行包含已下达和已生产数量
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param id path int true "销售订单ID"
+// @Success 200 {object} response.Response{data=service.SalesOrderResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/sales-orders/{id} [get]
+func (c *SalesOrderController) GetSalesOrder(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的销售订单ID")
+		return
+	}
+
+	order, err := c.salesOrderService.GetSalesOrder(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取销售订单详情成功", order)
+}
+
+// GetSalesOrderList 获取销售订单列表
+// @Summary 获取销售订单列表
+// @Description 分页获取销售订单列表
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query string false "状态(draft/confirmed/closed/cancelled)"
+// @Param customer_id query int false "客户ID"
+// @Param keyword query string false "订单号或客户采购单号"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/sales-orders [get]
+func (c *SalesOrderController) GetSalesOrderList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	status := ctx.Query("status")
+	keyword := ctx.Query("keyword")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var customerID uint
+	if idStr := ctx.Query("customer_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的客户ID")
+			return
+		}
+		customerID = uint(id)
+	}
+
+	orders, total, err := c.salesOrderService.GetSalesOrderList(page, pageSize, status, keyword, customerID)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, orders, total, page, pageSize, "获取销售订单列表成功")
+}
+
+// UpdateSalesOrder 更新销售订单
+// @Summary 更新销售订单
+// @Description 更新草稿状态的销售订单，订单行整体替换
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param id path int true "销售订单ID"
+// @Param order body service.SalesOrderRequest true "销售订单信息"
+// @Success 200 {object} response.Response{data=service.SalesOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/sales-orders/{id} [put]
+func (c *SalesOrderController) UpdateSalesOrder(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的销售订单ID")
+		return
+	}
+
+	var req service.SalesOrderRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	order, err := c.salesOrderService.UpdateSalesOrder(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新销售订单成功", order)
+}
+
+// DeleteSalesOrder 删除销售订单
+// @Summary 删除销售订单
+// @Description 删除草稿或已取消的销售订单
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param id path int true "销售订单ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/sales-orders/{id} [delete]
+func (c *SalesOrderController) DeleteSalesOrder(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的销售订单ID")
+		return
+	}
+
+	if err := c.salesOrderService.DeleteSalesOrder(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除销售订单成功", nil)
+}
+
+// ConfirmSalesOrder 确认销售订单
+// @Summary 确认销售订单
+// @Description 确认草稿状态的销售订单，确认后可按订单行下达生产工单
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param id path int true "销售订单ID"
+// @Success 200 {object} response.Response{data=service.SalesOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/sales-orders/{id}/confirm [post]
+func (c *SalesOrderController) ConfirmSalesOrder(ctx *gin.Context) {
+	c.changeStatus(ctx, c.salesOrderService.ConfirmSalesOrder, "确认销售订单成功")
+}
+
+// CloseSalesOrder 关闭销售订单
+// @Summary 关闭销售订单
+// @Description 关闭已确认的销售订单，未结订单行一并关闭
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param id path int true "销售订单ID"
+// @Success 200 {object} response.Response{data=service.SalesOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/sales-orders/{id}/close [post]
+func (c *SalesOrderController) CloseSalesOrder(ctx *gin.Context) {
+	c.changeStatus(ctx, c.salesOrderService.CloseSalesOrder, "关闭销售订单成功")
+}
+
+// CancelSalesOrder 取消销售订单
+// @Summary 取消销售订单
+// @Description 取消销售订单，存在未完工的关联生产工单时不能取消
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param id path int true "销售订单ID"
+// @Success 200 {object} response.Response{data=service.SalesOrderResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/sales-orders/{id}/cancel [post]
+func (c *SalesOrderController) CancelSalesOrder(ctx *gin.Context) {
+	c.changeStatus(ctx, c.salesOrderService.CancelSalesOrder, "取消销售订单成功")
+}
+
+// CreateProductionOrder 按销售订单行创建生产工单
+// @Summary 按销售订单行创建生产工单
+// @Description 为已确认销售订单的订单行下达生产工单，生产工单关联该订单行；未指定数量时取未计划数量，未指定计划完工日期时取交货日期
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param line_id path int true "销售订单行ID"
+// @Param production body service.SalesOrderProductionRequest false "生产工单信息"
+// @Success 200 {object} response.Response{data=models.ProductionOrder}
+// @Failure 400 {object} response.Response
+// @Router /api/sales-orders/lines/{line_id}/production-orders [post]
+func (c *SalesOrderController) CreateProductionOrder(ctx *gin.Context) {
+	lineID, err := strconv.ParseUint(ctx.Param("line_id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的销售订单行ID")
+		return
+	}
+
+	// 请求体可为空，表示按默认值下达
+	var req service.SalesOrderProductionRequest
+	if ctx.Request.ContentLength > 0 {
+		if err = ctx.ShouldBindJSON(&req); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	order, err := c.salesOrderService.CreateProductionOrder(uint(lineID), &req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "下达生产工单成功", order)
+}
+
+// GetFulfillment 获取销售订单交付情况
+// @Summary 获取销售订单交付情况
+// @Description 按已确认销售订单的未结订单行统计已计划、在制和已生产数量，并标记相对交货日期有风险的订单行：已逾期(overdue)、预警期内仍有未计划数量(unplanned)、工单计划完工晚于交货日期(late_schedule)、预警期内工单逾期未开工(not_started)
+// @Tags 销售管理
+// @Accept json
+// @Produce json
+// @Param customer_id query int false "客户ID"
+// @Param sales_order_id query int false "销售订单ID"
+// @Param horizon_days query int false "风险预警天数" default(7)
+// @Param at_risk query bool false "仅返回有风险的订单行"
+// @Success 200 {object} response.Response{data=[]service.SalesLineFulfillment}
+// @Failure 400 {object} response.Response
+// @Router /api/sales-orders/fulfillment [get]
+func (c *SalesOrderController) GetFulfillment(ctx *gin.Context) {
+	var customerID uint
+	if idStr := ctx.Query("customer_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的客户ID")
+			return
+		}
+		customerID = uint(id)
+	}
+
+	var salesOrderID uint
+	if idStr := ctx.Query("sales_order_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的销售订单ID")
+			return
+		}
+		salesOrderID = uint(id)
+	}
+
+	var horizonDays int
+	if daysStr := ctx.Query("horizon_days"); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days <= 0 {
+			response.Error(ctx, http.StatusBadRequest, "无效的风险预警天数")
+			return
+		}
+		horizonDays = days
+	}
+
+	atRiskOnly := ctx.Query("at_risk") == "true"
+
+	fulfillment, err := c.salesOrderService.GetFulfillment(customerID, salesOrderID, horizonDays, atRiskOnly)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取销售订单交付情况成功", fulfillment)
+}
+
+// 辅助函数：解析销售订单ID并执行状态变更
+func (c *SalesOrderController) changeStatus(ctx *gin.Context, action func(uint) (*service.SalesOrderResponse, error), message string) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的销售订单ID")
+		return
+	}
+
+	order, err := action(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, message, order)
+}
@@ -0,0 +1,434 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SamplingController 抽样检验控制器
+type SamplingController struct {
+	samplingService *service.SamplingService
+}
+
+// NewSamplingController 创建抽样检验控制器实例
+func NewSamplingController(samplingService *service.SamplingService) *SamplingController {
+	return &SamplingController{
+		samplingService: samplingService,
+	}
+}
+
+// CreateSamplingScheme 创建抽样方案
+// @Summary 创建抽样方案
+// @Description 按产品或质量特性配置AQL和检验水平的计数抽样方案，初始为正常检验
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body service.SamplingSchemeRequest true "抽样方案信息"
+// @Success 200 {object} response.Response{data=service.SamplingSchemeResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/sampling-schemes [post]
+func (c *SamplingController) CreateSamplingScheme(ctx *gin.Context) {
+	var req service.SamplingSchemeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	scheme, err := c.samplingService.CreateSamplingScheme(&req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建抽样方案成功", scheme)
+}
+
+// GetSamplingScheme 获取抽样方案详情
+// @Summary 获取抽样方案详情
+// @Description 获取抽样方案及当前检验严格度和转移状态
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param id path int true "抽样方案ID"
+// @Success 200 {object} response.Response{data=service.SamplingSchemeResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/quality/sampling-schemes/{id} [get]
+func (c *SamplingController) GetSamplingScheme(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的抽样方案ID")
+		return
+	}
+
+	scheme, err := c.samplingService.GetSamplingScheme(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取抽样方案详情成功", scheme)
+}
+
+// GetSamplingSchemeList 获取抽样方案列表
+// @Summary 获取抽样方案列表
+// @Description 分页获取抽样方案列表，支持按产品、质量特性、严格度和状态筛选
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param product_id query int false "产品ID"
+// @Param quality_standard_id query int false "质量标准ID"
+// @Param severity query string false "检验严格度(normal/tightened/reduced)"
+// @Param is_active query bool false "是否启用"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/quality/sampling-schemes [get]
+func (c *SamplingController) GetSamplingSchemeList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	severity := ctx.Query("severity")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var productID, qualityStandardID uint
+	if idStr := ctx.Query("product_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的产品ID")
+			return
+		}
+		productID = uint(id)
+	}
+	if idStr := ctx.Query("quality_standard_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的质量标准ID")
+			return
+		}
+		qualityStandardID = uint(id)
+	}
+
+	isActive, ok := parseOptionalBool(ctx, "is_active")
+	if !ok {
+		return
+	}
+
+	schemes, total, err := c.samplingService.GetSamplingSchemeList(page, pageSize, productID, qualityStandardID, severity, isActive)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, schemes, total, page, pageSize, "获取抽样方案列表成功")
+}
+
+// UpdateSamplingScheme 更新抽样方案
+// @Summary 更新抽样方案
+// @Description 更新抽样方案，已建检验批的样本量和接收数不受影响，已有检验批的方案不能修改产品；修改AQL、检验水平或判定依据后恢复正常检验并重新累计转移状态，撤销放宽批准时恢复正常检验
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "抽样方案ID"
+// @Param request body service.SamplingSchemeRequest true "抽样方案信息"
+// @Success 200 {object} response.Response{data=service.SamplingSchemeResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/sampling-schemes/{id} [put]
+func (c *SamplingController) UpdateSamplingScheme(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的抽样方案ID")
+		return
+	}
+
+	var req service.SamplingSchemeRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	scheme, err := c.samplingService.UpdateSamplingScheme(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新抽样方案成功", scheme)
+}
+
+// DeleteSamplingScheme 删除抽样方案
+// @Summary 删除抽样方案
+// @Description 删除没有检验批的抽样方案
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "抽样方案ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/quality/sampling-schemes/{id} [delete]
+func (c *SamplingController) DeleteSamplingScheme(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的抽样方案ID")
+		return
+	}
+
+	if err = c.samplingService.DeleteSamplingScheme(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除抽样方案成功", nil)
+}
+
+// SwitchSamplingSeverity 手动转移检验严格度
+// @Summary 手动转移检验严格度
+// @Description 手动转移抽样方案的检验严格度，暂停检验的方案在质量改进后只能恢复为加严检验
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "抽样方案ID"
+// @Param request body service.SwitchSamplingSeverityRequest true "目标严格度"
+// @Success 200 {object} response.Response{data=service.SamplingSchemeResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/sampling-schemes/{id}/switch [post]
+func (c *SamplingController) SwitchSamplingSeverity(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的抽样方案ID")
+		return
+	}
+
+	var req service.SwitchSamplingSeverityRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	scheme, err := c.samplingService.SwitchSamplingSeverity(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "转移检验严格度成功", scheme)
+}
+
+// GetSamplingPlan 查询抽样方案
+// @Summary 查询抽样方案
+// @Description 按批量查表返回样本量字码、样本量及接收数Ac和拒收数Re，不指定严格度时使用方案当前严格度
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param id path int true "抽样方案ID"
+// @Param lot_size query int true "批量"
+// @Param severity query string false "检验严格度(normal/tightened/reduced)"
+// @Success 200 {object} response.Response{data=service.SamplingPlanResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/sampling-schemes/{id}/plan [get]
+func (c *SamplingController) GetSamplingPlan(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的抽样方案ID")
+		return
+	}
+
+	lotSize, err := strconv.Atoi(ctx.Query("lot_size"))
+	if err != nil || lotSize <= 0 {
+		response.Error(ctx, http.StatusBadRequest, "无效的批量")
+		return
+	}
+
+	plan, err := c.samplingService.GetSamplingPlan(uint(id), lotSize, ctx.Query("severity"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "查询抽样方案成功", plan)
+}
+
+// CreateInspectionLot 创建检验批
+// @Summary 创建检验批
+// @Description 按抽样方案当前严格度和批量确定样本量及接收数、拒收数，样本以关联检验批的检测单录入
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body service.InspectionLotRequest true "检验批信息"
+// @Success 200 {object} response.Response{data=service.InspectionLotResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/lots [post]
+func (c *SamplingController) CreateInspectionLot(ctx *gin.Context) {
+	var req service.InspectionLotRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	lot, err := c.samplingService.CreateInspectionLot(&req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建检验批成功", lot)
+}
+
+// GetInspectionLot 获取检验批详情
+// @Summary 获取检验批详情
+// @Description 获取检验批详情，待判定的检验批实时统计已检样本数和不合格数
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param id path int true "检验批ID"
+// @Success 200 {object} response.Response{data=service.InspectionLotResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/quality/lots/{id} [get]
+func (c *SamplingController) GetInspectionLot(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的检验批ID")
+		return
+	}
+
+	lot, err := c.samplingService.GetInspectionLot(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取检验批详情成功", lot)
+}
+
+// GetInspectionLotList 获取检验批列表
+// @Summary 获取检验批列表
+// @Description 分页获取检验批列表，样本检测单可通过检测单列表按检验批筛选
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param sampling_scheme_id query int false "抽样方案ID"
+// @Param production_order_id query int false "生产工单ID"
+// @Param disposition query string false "判定结果(pending/accepted/rejected)"
+// @Param keyword query string false "检验批号"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/quality/lots [get]
+func (c *SamplingController) GetInspectionLotList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	disposition := ctx.Query("disposition")
+	keyword := ctx.Query("keyword")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var samplingSchemeID, productionOrderID uint
+	if idStr := ctx.Query("sampling_scheme_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的抽样方案ID")
+			return
+		}
+		samplingSchemeID = uint(id)
+	}
+	if idStr := ctx.Query("production_order_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的生产工单ID")
+			return
+		}
+		productionOrderID = uint(id)
+	}
+
+	lots, total, err := c.samplingService.GetInspectionLotList(page, pageSize, samplingSchemeID, productionOrderID, disposition, keyword)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, lots, total, page, pageSize, "获取检验批列表成功")
+}
+
+// DecideInspectionLot 判定检验批
+// @Summary 判定检验批
+// @Description 按已录入的样本检测结果判定检验批接收或不接收，并按转移规则更新抽样方案的检验严格度
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "检验批ID"
+// @Success 200 {object} response.Response{data=service.InspectionLotResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/lots/{id}/decide [post]
+func (c *SamplingController) DecideInspectionLot(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的检验批ID")
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	lot, err := c.samplingService.DecideInspectionLot(uint(id), userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "判定检验批成功", lot)
+}
+
+// DeleteInspectionLot 删除检验批
+// @Summary 删除检验批
+// @Description 删除待判定的检验批，其样本检测单保留并解除关联
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "检验批ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/quality/lots/{id} [delete]
+func (c *SamplingController) DeleteInspectionLot(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的检验批ID")
+		return
+	}
+
+	if err = c.samplingService.DeleteInspectionLot(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除检验批成功", nil)
+}
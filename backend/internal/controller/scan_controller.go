@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"net/http"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScanController 扫码控制器
+type ScanController struct {
+	scanService *service.ScanService
+}
+
+// NewScanController 创建扫码控制器实例
+func NewScanController(scanService *service.ScanService) *ScanController {
+	return &ScanController{
+		scanService: scanService,
+	}
+}
+
+// ResolveCode 解析扫描的条码
+// @Summary 解析扫描的条码
+// @Description 手持终端扫码后解析条码对应的物料、批次、生产工单、设备或用户工牌，并返回当前角色可执行的操作（发料、收货、报工、开始维护等）。条码前缀：M:物料编码、L:批次号、PO:工单号、E:设备编码、U:工牌号；不带前缀时按编码在所有类型中查找，匹配到多个对象时返回候选项
+// @Tags 扫码
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code path string true "条码内容"
+// @Success 200 {object} response.Response{data=service.ScanResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/scan/{code} [get]
+func (c *ScanController) ResolveCode(ctx *gin.Context) {
+	role, _ := ctx.Get("role")
+	roleStr, _ := role.(string)
+
+	result, err := c.scanService.ResolveCode(ctx.Param("code"), roleStr)
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if len(result.Candidates) > 0 {
+		response.SuccessWithMessage(ctx, "条码匹配到多个对象，请选择", result)
+		return
+	}
+	response.SuccessWithMessage(ctx, "解析条码成功", result)
+}
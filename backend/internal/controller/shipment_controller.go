@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ShipmentController 发货控制器
+type ShipmentController struct {
+	shipmentService *service.ShipmentService
+}
+
+// NewShipmentController 创建发货控制器实例
+func NewShipmentController(shipmentService *service.ShipmentService) *ShipmentController {
+	return &ShipmentController{
+		shipmentService: shipmentService,
+	}
+}
+
+// CreateShipment 创建发货单
+// @Summary 创建发货单
+// @Description 创建草稿状态的发货单，每行从一个生产工单发货，拣货数量不能超过工单的已生产未发货数量，返工工单不能发货，可填写批次号和序列号
+// @Tags 发货管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param shipment body service.ShipmentRequest true "发货单信息"
+// @Success 200 {object} response.Response{data=service.ShipmentResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/shipments [post]
+func (c *ShipmentController) CreateShipment(ctx *gin.Context) {
+	var req service.ShipmentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	shipment, err := c.shipmentService.CreateShipment(&req, userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建发货单成功", shipment)
+}
+
+// GetShipment 获取发货单详情
+// @Summary 获取发货单详情
+// @Description 获取发货单及发货行，发货行包含批次号和序列号
+// @Tags 发货管理
+// @Accept json
+// @Produce json
+// @Param id path int true "发货单ID"
+// @Success 200 {object} response.Response{data=service.ShipmentResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/shipments/{id} [get]
+func (c *ShipmentController) GetShipment(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的发货单ID")
+		return
+	}
+
+	shipment, err := c.shipmentService.GetShipment(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取发货单详情成功", shipment)
+}
+
+// GetShipmentList 获取发货单列表
+// @Summary 获取发货单列表
+// @Description 分页获取发货单列表
+// @Tags 发货管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query string false "状态(draft/shipped/cancelled)"
+// @Param customer_id query int false "客户ID"
+// @Param sales_order_id query int false "销售订单ID"
+// @Param keyword query string false "发货单号或运单号"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/shipments [get]
+func (c *ShipmentController) GetShipmentList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	status := ctx.Query("status")
+	keyword := ctx.Query("keyword")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var customerID, salesOrderID uint
+	if idStr := ctx.Query("customer_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的客户ID")
+			return
+		}
+		customerID = uint(id)
+	}
+	if idStr := ctx.Query("sales_order_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的销售订单ID")
+			return
+		}
+		salesOrderID = uint(id)
+	}
+
+	shipments, total, err := c.shipmentService.GetShipmentList(page, pageSize, status, keyword, customerID, salesOrderID)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, shipments, total, page, pageSize, "获取发货单列表成功")
+}
+
+// UpdateShipment 更新发货单
+// @Summary 更新发货单
+// @Description 更新草稿状态的发货单，发货行整体替换
+// @Tags 发货管理
+// @Accept json
+// @Produce json
+// @Param id path int true "发货单ID"
+// @Param shipment body service.ShipmentRequest true "发货单信息"
+// @Success 200 {object} response.Response{data=service.ShipmentResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/shipments/{id} [put]
+func (c *ShipmentController) UpdateShipment(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的发货单ID")
+		return
+	}
+
+	var req service.ShipmentRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	shipment, err := c.shipmentService.UpdateShipment(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新发货单成功", shipment)
+}
+
+// DeleteShipment 删除发货单
+// @Summary 删除发货单
+// @Description 删除草稿或已取消的发货单
+// @Tags 发货管理
+// @Accept json
+// @Produce json
+// @Param id path int true "发货单ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/shipments/{id} [delete]
+func (c *ShipmentController) DeleteShipment(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的发货单ID")
+		return
+	}
+
+	if err := c.shipmentService.DeleteShipment(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除发货单成功", nil)
+}
+
+// ShipShipment 确认发货
+// @Summary 确认发货
+// @Description 确认草稿发货单发货：累计生产工单和销售订单行的已发货数量并登记成品发货出库
+// @Tags 发货管理
+// @Accept json
+// @Produce json
+// @Param id path int true "发货单ID"
+// @Success 200 {object} response.Response{data=service.ShipmentResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/shipments/{id}/ship [post]
+func (c *ShipmentController) ShipShipment(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的发货单ID")
+		return
+	}
+
+	userID, exists := ctx.Get("user_id")
+	if !exists {
+		response.Unauthorized(ctx, "用户未登录")
+		return
+	}
+
+	shipment, err := c.shipmentService.ShipShipment(uint(id), userID.(uint))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "发货成功", shipment)
+}
+
+// CancelShipment 取消发货单
+// @Summary 取消发货单
+// @Description 取消草稿状态的发货单
+// @Tags 发货管理
+// @Accept json
+// @Produce json
+// @Param id path int true "发货单ID"
+// @Success 200 {object} response.Response{data=service.ShipmentResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/shipments/{id}/cancel [post]
+func (c *ShipmentController) CancelShipment(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的发货单ID")
+		return
+	}
+
+	shipment, err := c.shipmentService.CancelShipment(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "取消发货单成功", shipment)
+}
+
+// GetDeliveryNote 打印发货单
+// @Summary 打印发货单
+// @Description 生成A4发货单PDF，配置中文字体时以中文打印，否则以英文打印
+// @Tags 发货管理
+// @Produce application/pdf
+// @Param id path int true "发货单ID"
+// @Success 200 {file} file
+// @Failure 400 {object} response.Response
+// @Router /api/shipments/{id}/delivery-note [get]
+func (c *ShipmentController) GetDeliveryNote(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的发货单ID")
+		return
+	}
+
+	filename, data, err := c.shipmentService.GenerateDeliveryNote(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.File(ctx, filename, "application/pdf", data)
+}
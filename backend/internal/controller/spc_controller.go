@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpcController SPC统计过程控制控制器
+type SpcController struct {
+	spcService        *service.SpcService
+	capabilityService *service.CapabilityService
+}
+
+// NewSpcController 创建SPC控制器实例
+func NewSpcController(spcService *service.SpcService, capabilityService *service.CapabilityService) *SpcController {
+	return &SpcController{
+		spcService:        spcService,
+		capabilityService: capabilityService,
+	}
+}
+
+// GetSpcChart 获取SPC控制图
+// @Summary 获取SPC控制图
+// @Description 按质量标准和时间窗口计算均值-极差图、均值-标准差图或单值-移动极差图的数据点和控制限，均值图可按生产工单或时间段划分子组
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param quality_standard_id query int true "质量标准ID"
+// @Param chart_type query string false "控制图类型(xbar_r/xbar_s/imr)" default(xbar_r)
+// @Param subgroup_by query string false "子组划分方式(production_order/hour/day/week)，单值图忽略" default(production_order)
+// @Param start_date query string false "开始日期(YYYY-MM-DD)，默认为结束日期前30天"
+// @Param end_date query string false "结束日期(YYYY-MM-DD)，默认为今天"
+// @Success 200 {object} response.Response{data=service.SpcChartResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/spc [get]
+func (c *SpcController) GetSpcChart(ctx *gin.Context) {
+	standardID, err := strconv.ParseUint(ctx.Query("quality_standard_id"), 10, 32)
+	if err != nil || standardID == 0 {
+		response.Error(ctx, http.StatusBadRequest, "无效的质量标准ID")
+		return
+	}
+
+	query := service.SpcChartQuery{
+		QualityStandardID: uint(standardID),
+		ChartType:         ctx.DefaultQuery("chart_type", service.SpcChartXbarR),
+		SubgroupBy:        ctx.Query("subgroup_by"),
+	}
+
+	var ok bool
+	if query.StartDate, query.EndDate, ok = parseSpcWindow(ctx); !ok {
+		return
+	}
+
+	chart, err := c.spcService.GetSpcChart(&query)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取SPC控制图成功", chart)
+}
+
+// GetCapabilityReport 获取过程能力报表
+// @Summary 获取过程能力报表
+// @Description 以质量标准的最小值和最大值为规格限，按质量标准、产品和期间计算Cp、Cpk、Pp、Ppk，并给出样本量和正态性提示
+// @Tags 质量管理
+// @Accept json
+// @Produce json
+// @Param product_id query int false "产品ID"
+// @Param quality_standard_id query int false "质量标准ID"
+// @Param period query string false "统计期间(day/week/month)，不填为整个时间窗口"
+// @Param subgroup_by query string false "组内标准差的子组划分方式(production_order/hour/day/week)，不填按移动极差估计"
+// @Param start_date query string false "开始日期(YYYY-MM-DD)，默认为结束日期前30天"
+// @Param end_date query string false "结束日期(YYYY-MM-DD)，默认为今天"
+// @Success 200 {object} response.Response{data=[]service.CapabilityReport}
+// @Failure 400 {object} response.Response
+// @Router /api/quality/capability [get]
+func (c *SpcController) GetCapabilityReport(ctx *gin.Context) {
+	reports, ok := c.queryCapabilityReport(ctx)
+	if !ok {
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取过程能力报表成功", reports)
+}
+
+// ExportCapabilityReport 导出过程能力报表
+// @Summary 导出过程能力报表
+// @Description 以CSV格式导出过程能力报表
+// @Tags 质量管理
+// @Produce text/csv
+// @Param product_id query int false "产品ID"
+// @Param quality_standard_id query int false "质量标准ID"
+// @Param period query string false "统计期间(day/week/month)，不填为整个时间窗口"
+// @Param subgroup_by query string false "组内标准差的子组划分方式(production_order/hour/day/week)，不填按移动极差估计"
+// @Param start_date query string false "开始日期(YYYY-MM-DD)，默认为结束日期前30天"
+// @Param end_date query string false "结束日期(YYYY-MM-DD)，默认为今天"
+// @Success 200 {file} file
+// @Failure 400 {object} response.Response
+// @Router /api/quality/capability/export [get]
+func (c *SpcController) ExportCapabilityReport(ctx *gin.Context) {
+	reports, ok := c.queryCapabilityReport(ctx)
+	if !ok {
+		return
+	}
+
+	header := []string{"产品编码", "产品名称", "质量标准", "单位", "期间", "样本量", "规格下限", "规格上限", "目标值",
+		"均值", "最小值", "最大值", "组内标准差", "整体标准差", "Cp", "Cpk", "Pp", "Ppk", "AD统计量", "正态性P值", "提示"}
+	rows := make([][]string, 0, len(reports))
+	for _, report := range reports {
+		rows = append(rows, []string{
+			report.ProductCode,
+			report.ProductName,
+			report.QualityStandardName,
+			report.Unit,
+			report.Period,
+			strconv.Itoa(report.SampleSize),
+			formatOptionalValue(report.SpecLower),
+			formatOptionalValue(report.SpecUpper),
+			formatQuantity(report.Target),
+			formatQuantity(report.Mean),
+			formatQuantity(report.MinValue),
+			formatQuantity(report.MaxValue),
+			formatQuantity(report.SigmaWithin),
+			formatQuantity(report.SigmaOverall),
+			formatOptionalValue(report.Cp),
+			formatOptionalValue(report.Cpk),
+			formatOptionalValue(report.Pp),
+			formatOptionalValue(report.Ppk),
+			formatOptionalValue(report.ADStatistic),
+			formatOptionalValue(report.NormalityPValue),
+			strings.Join(report.Warnings, "；"),
+		})
+	}
+
+	response.CSV(ctx, fmt.Sprintf("capability_%s.csv", time.Now().Format("20060102")), header, rows)
+}
+
+// queryCapabilityReport 解析查询参数并获取过程能力报表
+func (c *SpcController) queryCapabilityReport(ctx *gin.Context) ([]service.CapabilityReport, bool) {
+	query := service.CapabilityQuery{
+		Period:     ctx.Query("period"),
+		SubgroupBy: ctx.Query("subgroup_by"),
+	}
+
+	if idStr := ctx.Query("product_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的产品ID")
+			return nil, false
+		}
+		query.ProductID = uint(id)
+	}
+	if idStr := ctx.Query("quality_standard_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的质量标准ID")
+			return nil, false
+		}
+		query.QualityStandardID = uint(id)
+	}
+
+	var ok bool
+	if query.StartDate, query.EndDate, ok = parseSpcWindow(ctx); !ok {
+		return nil, false
+	}
+
+	reports, err := c.capabilityService.GetCapabilityReport(&query)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	return reports, true
+}
+
+// 辅助函数：解析可选的开始和结束日期，结束日期包含当天
+func parseSpcWindow(ctx *gin.Context) (*time.Time, *time.Time, bool) {
+	var startDate, endDate *time.Time
+	if dateStr := ctx.Query("start_date"); dateStr != "" {
+		date, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的开始日期格式")
+			return nil, nil, false
+		}
+		startDate = &date
+	}
+	if dateStr := ctx.Query("end_date"); dateStr != "" {
+		date, err := time.ParseInLocation("2006-01-02", dateStr, time.Local)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的结束日期格式")
+			return nil, nil, false
+		}
+		date = date.AddDate(0, 0, 1)
+		endDate = &date
+	}
+	return startDate, endDate, true
+}
+
+// 辅助函数：格式化可为空的数值，为空时输出空字符串
+func formatOptionalValue(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
@@ -0,0 +1,326 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SupplierController 供应商控制器
+type SupplierController struct {
+	supplierService *service.SupplierService
+}
+
+// NewSupplierController 创建供应商控制器实例
+func NewSupplierController(supplierService *service.SupplierService) *SupplierController {
+	return &SupplierController{
+		supplierService: supplierService,
+	}
+}
+
+// CreateSupplier 创建供应商
+// @Summary 创建供应商
+// @Description 创建供应商及其联系人
+// @Tags 供应商管理
+// @Accept json
+// @Produce json
+// @Param supplier body service.SupplierRequest true "供应商信息"
+// @Success 200 {object} response.Response{data=service.SupplierResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/suppliers [post]
+func (c *SupplierController) CreateSupplier(ctx *gin.Context) {
+	var req service.SupplierRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	supplier, err := c.supplierService.CreateSupplier(&req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建供应商成功", supplier)
+}
+
+// GetSupplier 获取供应商详情
+// @Summary 获取供应商详情
+// @Description 获取供应商、联系人及合格物料清单
+// @Tags 供应商管理
+// @Accept json
+// @Produce json
+// @Param id path int true "供应商ID"
+// @Success 200 {object} response.Response{data=service.SupplierResponse}
+// @Failure 404 {object} response.Response
+// @Router /api/suppliers/{id} [get]
+func (c *SupplierController) GetSupplier(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的供应商ID")
+		return
+	}
+
+	supplier, err := c.supplierService.GetSupplier(uint(id))
+	if err != nil {
+		response.Error(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取供应商详情成功", supplier)
+}
+
+// GetSupplierList 获取供应商列表
+// @Summary 获取供应商列表
+// @Description 分页获取供应商列表，支持按编码、名称搜索和按状态筛选
+// @Tags 供应商管理
+// @Accept json
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param keyword query string false "编码或名称"
+// @Param status query int false "状态(1:合格 0:停用)"
+// @Success 200 {object} response.Response{data=response.PageResponse}
+// @Router /api/suppliers [get]
+func (c *SupplierController) GetSupplierList(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("page_size", "10"))
+	keyword := ctx.Query("keyword")
+
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
+
+	var status *int
+	if statusStr := ctx.Query("status"); statusStr != "" {
+		value, err := strconv.Atoi(statusStr)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的状态")
+			return
+		}
+		status = &value
+	}
+
+	suppliers, total, err := c.supplierService.GetSupplierList(page, pageSize, keyword, status)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithPage(ctx, suppliers, total, page, pageSize, "获取供应商列表成功")
+}
+
+// UpdateSupplier 更新供应商
+// @Summary 更新供应商
+// @Description 更新供应商信息，联系人整体替换
+// @Tags 供应商管理
+// @Accept json
+// @Produce json
+// @Param id path int true "供应商ID"
+// @Param supplier body service.SupplierRequest true "供应商信息"
+// @Success 200 {object} response.Response{data=service.SupplierResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/suppliers/{id} [put]
+func (c *SupplierController) UpdateSupplier(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的供应商ID")
+		return
+	}
+
+	var req service.SupplierRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	supplier, err := c.supplierService.UpdateSupplier(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新供应商成功", supplier)
+}
+
+// DeleteSupplier 删除供应商
+// @Summary 删除供应商
+// @Description 删除没有未结采购订单的供应商
+// @Tags 供应商管理
+// @Accept json
+// @Produce json
+// @Param id path int true "供应商ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/suppliers/{id} [delete]
+func (c *SupplierController) DeleteSupplier(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的供应商ID")
+		return
+	}
+
+	if err := c.supplierService.DeleteSupplier(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除供应商成功", nil)
+}
+
+// AddSupplierMaterial 添加供应商合格物料
+// @Summary 添加供应商合格物料
+// @Description 将物料加入供应商合格物料清单，清单内的物料才能向该供应商采购和收货
+// @Tags 供应商管理
+// @Accept json
+// @Produce json
+// @Param id path int true "供应商ID"
+// @Param material body service.SupplierMaterialRequest true "合格物料"
+// @Success 200 {object} response.Response{data=service.SupplierResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/suppliers/{id}/materials [post]
+func (c *SupplierController) AddSupplierMaterial(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的供应商ID")
+		return
+	}
+
+	var req service.SupplierMaterialRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	supplier, err := c.supplierService.AddSupplierMaterial(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "添加合格物料成功", supplier)
+}
+
+// RemoveSupplierMaterial 移除供应商合格物料
+// @Summary 移除供应商合格物料
+// @Description 将物料从供应商合格物料清单中移除
+// @Tags 供应商管理
+// @Accept json
+// @Produce json
+// @Param id path int true "供应商ID"
+// @Param material_id path int true "物料ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/suppliers/{id}/materials/{material_id} [delete]
+func (c *SupplierController) RemoveSupplierMaterial(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的供应商ID")
+		return
+	}
+
+	materialID, err := strconv.ParseUint(ctx.Param("material_id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的物料ID")
+		return
+	}
+
+	if err := c.supplierService.RemoveSupplierMaterial(uint(id), uint(materialID)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "移除合格物料成功", nil)
+}
+
+// GetScorecards 获取供应商绩效
+// @Summary 获取供应商绩效
+// @Description 按期间统计供应商收货数量和金额、不合格率（退货按原收货批次计入收货期间）以及相对物料标准单价的价格差异；可按年度和季度查询，也可指定起止日期
+// @Tags 供应商管理
+// @Accept json
+// @Produce json
+// @Param year query int false "年度"
+// @Param quarter query int false "季度(1-4)，与年度同时使用"
+// @Param start_date query string false "开始日期(YYYY-MM-DD)"
+// @Param end_date query string false "结束日期(YYYY-MM-DD)"
+// @Param supplier_id query int false "供应商ID"
+// @Success 200 {object} response.Response{data=service.SupplierScorecardResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/suppliers/scorecards [get]
+func (c *SupplierController) GetScorecards(ctx *gin.Context) {
+	startDate, endDate, ok := parseScorecardPeriod(ctx)
+	if !ok {
+		return
+	}
+
+	var supplierID uint
+	if supplierIDStr := ctx.Query("supplier_id"); supplierIDStr != "" {
+		id, err := strconv.ParseUint(supplierIDStr, 10, 32)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的供应商ID")
+			return
+		}
+		supplierID = uint(id)
+	}
+
+	scorecards, err := c.supplierService.GetScorecards(startDate, endDate, supplierID)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取供应商绩效成功", scorecards)
+}
+
+// 辅助函数：解析绩效统计期间，优先使用年度和季度，未指定时使用起止日期，均未指定时为当前季度
+func parseScorecardPeriod(ctx *gin.Context) (time.Time, time.Time, bool) {
+	yearStr, quarterStr := ctx.Query("year"), ctx.Query("quarter")
+	startStr, endStr := ctx.Query("start_date"), ctx.Query("end_date")
+
+	if yearStr == "" && quarterStr == "" && (startStr != "" || endStr != "") {
+		startDate, err := time.ParseInLocation("2006-01-02", startStr, time.Local)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的开始日期格式")
+			return time.Time{}, time.Time{}, false
+		}
+		endDate, err := time.ParseInLocation("2006-01-02", endStr, time.Local)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的结束日期格式")
+			return time.Time{}, time.Time{}, false
+		}
+		return startDate, endDate, true
+	}
+
+	now := time.Now()
+	year := now.Year()
+	quarter := (int(now.Month())-1)/3 + 1
+
+	if yearStr != "" {
+		value, err := strconv.Atoi(yearStr)
+		if err != nil || value < 2000 || value > 9999 {
+			response.Error(ctx, http.StatusBadRequest, "无效的年度")
+			return time.Time{}, time.Time{}, false
+		}
+		year = value
+	}
+	if quarterStr != "" {
+		value, err := strconv.Atoi(quarterStr)
+		if err != nil || value < 1 || value > 4 {
+			response.Error(ctx, http.StatusBadRequest, "季度必须为1-4")
+			return time.Time{}, time.Time{}, false
+		}
+		quarter = value
+	}
+
+	startDate := time.Date(year, time.Month((quarter-1)*3+1), 1, 0, 0, 0, 0, time.Local)
+	endDate := startDate.AddDate(0, 3, -1)
+	return startDate, endDate, true
+}
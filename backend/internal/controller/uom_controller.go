@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"mes-system/internal/service"
+	"mes-system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnitOfMeasureController 计量单位控制器
+type UnitOfMeasureController struct {
+	uomService *service.UnitOfMeasureService
+}
+
+// NewUnitOfMeasureController 创建计量单位控制器实例
+func NewUnitOfMeasureController(uomService *service.UnitOfMeasureService) *UnitOfMeasureController {
+	return &UnitOfMeasureController{
+		uomService: uomService,
+	}
+}
+
+// CreateUnit 创建计量单位
+// @Summary 创建计量单位
+// @Description 在计量单位目录中创建计量单位
+// @Tags 计量单位
+// @Accept json
+// @Produce json
+// @Param unit body service.UnitOfMeasureRequest true "计量单位信息"
+// @Success 200 {object} response.Response{data=service.UnitOfMeasureResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/uom/units [post]
+func (c *UnitOfMeasureController) CreateUnit(ctx *gin.Context) {
+	var req service.UnitOfMeasureRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	unit, err := c.uomService.CreateUnit(&req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建计量单位成功", unit)
+}
+
+// GetUnitList 获取计量单位列表
+// @Summary 获取计量单位列表
+// @Description 获取计量单位目录
+// @Tags 计量单位
+// @Accept json
+// @Produce json
+// @Param dimension query string false "量纲(count/mass/length/volume/area)"
+// @Param is_active query bool false "是否启用"
+// @Success 200 {object} response.Response{data=[]service.UnitOfMeasureResponse}
+// @Router /api/uom/units [get]
+func (c *UnitOfMeasureController) GetUnitList(ctx *gin.Context) {
+	dimension := ctx.Query("dimension")
+
+	var isActive *bool
+	if isActiveStr := ctx.Query("is_active"); isActiveStr != "" {
+		value, err := strconv.ParseBool(isActiveStr)
+		if err != nil {
+			response.Error(ctx, http.StatusBadRequest, "无效的启用状态")
+			return
+		}
+		isActive = &value
+	}
+
+	units, err := c.uomService.GetUnitList(dimension, isActive)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取计量单位列表成功", units)
+}
+
+// UpdateUnit 更新计量单位
+// @Summary 更新计量单位
+// @Description 更新计量单位，已被引用的单位不能修改编码和量纲
+// @Tags 计量单位
+// @Accept json
+// @Produce json
+// @Param id path int true "计量单位ID"
+// @Param unit body service.UnitOfMeasureRequest true "计量单位信息"
+// @Success 200 {object} response.Response{data=service.UnitOfMeasureResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/uom/units/{id} [put]
+func (c *UnitOfMeasureController) UpdateUnit(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的计量单位ID")
+		return
+	}
+
+	var req service.UnitOfMeasureRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	unit, err := c.uomService.UpdateUnit(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新计量单位成功", unit)
+}
+
+// DeleteUnit 删除计量单位
+// @Summary 删除计量单位
+// @Description 删除未被物料或换算关系引用的计量单位
+// @Tags 计量单位
+// @Accept json
+// @Produce json
+// @Param id path int true "计量单位ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/uom/units/{id} [delete]
+func (c *UnitOfMeasureController) DeleteUnit(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的计量单位ID")
+		return
+	}
+
+	if err := c.uomService.DeleteUnit(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除计量单位成功", nil)
+}
+
+// CreateConversion 创建计量单位换算
+// @Summary 创建计量单位换算
+// @Description 创建通用换算（同一量纲内）或物料专用换算（如 1箱=24个）
+// @Tags 计量单位
+// @Accept json
+// @Produce json
+// @Param conversion body service.UnitConversionRequest true "换算信息"
+// @Success 200 {object} response.Response{data=service.UnitConversionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/uom/conversions [post]
+func (c *UnitOfMeasureController) CreateConversion(ctx *gin.Context) {
+	var req service.UnitConversionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	conversion, err := c.uomService.CreateConversion(&req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "创建计量单位换算成功", conversion)
+}
+
+// GetConversionList 获取计量单位换算列表
+// @Summary 获取计量单位换算列表
+// @Description 获取计量单位换算，指定物料时返回该物料的专用换算和通用换算
+// @Tags 计量单位
+// @Accept json
+// @Produce json
+// @Param material_id query int false "物料ID"
+// @Success 200 {object} response.Response{data=[]service.UnitConversionResponse}
+// @Router /api/uom/conversions [get]
+func (c *UnitOfMeasureController) GetConversionList(ctx *gin.Context) {
+	materialID, ok := parseMaterialIDQuery(ctx)
+	if !ok {
+		return
+	}
+
+	conversions, err := c.uomService.GetConversionList(materialID)
+	if err != nil {
+		response.Error(ctx, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "获取计量单位换算列表成功", conversions)
+}
+
+// UpdateConversion 更新计量单位换算
+// @Summary 更新计量单位换算
+// @Description 更新计量单位换算系数
+// @Tags 计量单位
+// @Accept json
+// @Produce json
+// @Param id path int true "换算ID"
+// @Param conversion body service.UnitConversionRequest true "换算信息"
+// @Success 200 {object} response.Response{data=service.UnitConversionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/uom/conversions/{id} [put]
+func (c *UnitOfMeasureController) UpdateConversion(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的换算ID")
+		return
+	}
+
+	var req service.UnitConversionRequest
+	if err = ctx.ShouldBindJSON(&req); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	conversion, err := c.uomService.UpdateConversion(uint(id), &req)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "更新计量单位换算成功", conversion)
+}
+
+// DeleteConversion 删除计量单位换算
+// @Summary 删除计量单位换算
+// @Description 删除计量单位换算
+// @Tags 计量单位
+// @Accept json
+// @Produce json
+// @Param id path int true "换算ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Router /api/uom/conversions/{id} [delete]
+func (c *UnitOfMeasureController) DeleteConversion(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "无效的换算ID")
+		return
+	}
+
+	if err := c.uomService.DeleteConversion(uint(id)); err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "删除计量单位换算成功", nil)
+}
+
+// ConvertQuantity 换算物料数量
+// @Summary 换算物料数量
+// @Description 将指定单位的数量换算为物料基本单位数量
+// @Tags 计量单位
+// @Accept json
+// @Produce json
+// @Param material_id query int true "物料ID"
+// @Param quantity query number true "数量"
+// @Param unit query string false "录入单位，默认物料基本单位"
+// @Success 200 {object} response.Response{data=service.QuantityConversionResponse}
+// @Failure 400 {object} response.Response
+// @Router /api/uom/convert [get]
+func (c *UnitOfMeasureController) ConvertQuantity(ctx *gin.Context) {
+	materialID, ok := parseMaterialIDQuery(ctx)
+	if !ok {
+		return
+	}
+	if materialID == 0 {
+		response.Error(ctx, http.StatusBadRequest, "物料ID不能为空")
+		return
+	}
+
+	quantity, err := strconv.ParseFloat(ctx.Query("quantity"), 64)
+	if err != nil || quantity < 0 {
+		response.Error(ctx, http.StatusBadRequest, "无效的数量")
+		return
+	}
+
+	result, err := c.uomService.ConvertQuantity(materialID, quantity, ctx.Query("unit"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(ctx, "换算成功", result)
+}
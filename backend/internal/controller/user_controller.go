@@ -233,4 +233,39 @@ func (ctrl *UserController) RefreshToken(c *gin.Context) {
 	}
 
 	response.SuccessWithMessage(c, "刷新令牌成功", tokenData)
-}
\ No newline at end of file
+}
+
+// UpdateBadge 设置用户工牌号
+// @Summary 设置用户工牌号
+// @Description 设置或清除用户的工牌号，手持终端扫描工牌（U:工牌号）识别用户（仅管理员）
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "用户ID"
+// @Param request body service.UpdateBadgeRequest true "工牌号"
+// @Success 200 {object} response.Response{data=models.User} "设置成功"
+// @Failure 400 {object} response.Response "请求参数错误"
+// @Failure 403 {object} response.Response "权限不足"
+// @Router /users/{id}/badge [put]
+func (ctrl *UserController) UpdateBadge(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的用户ID")
+		return
+	}
+
+	var req service.UpdateBadgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	user, err := ctrl.userService.UpdateBadge(uint(id), &req)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	response.SuccessWithMessage(c, "设置工牌号成功", user)
+}
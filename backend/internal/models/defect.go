@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefectCode 缺陷代码，按上级代码组成多级分类目录
+type DefectCode struct {
+	ID          uint           `json:"id" gorm:"primarykey"`
+	Code        string         `json:"code" gorm:"uniqueIndex;size:50;not null"`
+	Name        string         `json:"name" gorm:"size:100;not null"`
+	ParentID    *uint          `json:"parent_id" gorm:"index"` // 上级缺陷代码，为空时为顶级分类
+	Parent      *DefectCode    `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
+	Level       int            `json:"level" gorm:"default:1"`                  // 层级，顶级为1
+	Severity    string         `json:"severity" gorm:"size:20;default:'minor'"` // critical:致命 major:严重 minor:轻微
+	Description string         `json:"description" gorm:"size:500"`
+	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// QualityInspectionDefect 质量检测记录的缺陷明细
+type QualityInspectionDefect struct {
+	ID                  uint       `json:"id" gorm:"primarykey"`
+	QualityInspectionID uint       `json:"quality_inspection_id" gorm:"index;not null"`
+	DefectCodeID        uint       `json:"defect_code_id" gorm:"index;not null"`
+	DefectCode          DefectCode `json:"defect_code" gorm:"foreignKey:DefectCodeID"`
+	Quantity            int        `json:"quantity" gorm:"not null"` // 缺陷数量
+	Remark              string     `json:"remark" gorm:"size:200"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (DefectCode) TableName() string {
+	return "defect_codes"
+}
+
+func (QualityInspectionDefect) TableName() string {
+	return "quality_inspection_defects"
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaterialQualityStandard 物料质量标准（来料检验使用）
+type MaterialQualityStandard struct {
+	ID          uint           `json:"id" gorm:"primarykey"`
+	MaterialID  uint           `json:"material_id" gorm:"index;not null"`
+	Material    Material       `json:"material" gorm:"foreignKey:MaterialID"`
+	Name        string         `json:"name" gorm:"size:100;not null"`
+	Type        string         `json:"type" gorm:"size:50;not null"`
+	MinValue    float64        `json:"min_value"`
+	MaxValue    float64        `json:"max_value"`
+	TargetValue float64        `json:"target_value"`
+	Unit        string         `json:"unit" gorm:"size:20"`
+	Description string         `json:"description" gorm:"type:text"`
+	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// IncomingInspection 来料检验单
+type IncomingInspection struct {
+	ID                  uint                     `json:"id" gorm:"primarykey"`
+	InspectionNo        string                   `json:"inspection_no" gorm:"uniqueIndex;size:50;not null"`
+	TransactionID       uint                     `json:"transaction_id" gorm:"uniqueIndex;not null"` // 被检验的入库交易
+	Transaction         MaterialTransaction      `json:"transaction" gorm:"foreignKey:TransactionID"`
+	MaterialID          uint                     `json:"material_id" gorm:"index;not null"`
+	Material            Material                 `json:"material" gorm:"foreignKey:MaterialID"`
+	SupplierID          *uint                    `json:"supplier_id" gorm:"index"`
+	Quantity            float64                  `json:"quantity" gorm:"type:decimal(16,4);not null"`      // 待检（隔离）数量
+	Status              string                   `json:"status" gorm:"size:20;default:'pending';not null"` // pending:待检 passed:合格 failed:不合格
+	InspectorID         *uint                    `json:"inspector_id"`
+	Inspector           *User                    `json:"inspector,omitempty" gorm:"foreignKey:InspectorID"`
+	InspectedAt         *time.Time               `json:"inspected_at"`
+	RejectTransactionID *uint                    `json:"reject_transaction_id"` // 不合格时生成的退供应商出库交易
+	Remark              string                   `json:"remark" gorm:"size:500"`
+	Items               []IncomingInspectionItem `json:"items" gorm:"foreignKey:InspectionID"`
+	CreatedAt           time.Time                `json:"created_at"`
+	UpdatedAt           time.Time                `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt           `json:"-" gorm:"index"`
+}
+
+// IncomingInspectionItem 来料检验项目结果
+type IncomingInspectionItem struct {
+	ID                        uint                    `json:"id" gorm:"primarykey"`
+	InspectionID              uint                    `json:"inspection_id" gorm:"index;not null"`
+	MaterialQualityStandardID uint                    `json:"material_quality_standard_id" gorm:"not null"`
+	MaterialQualityStandard   MaterialQualityStandard `json:"material_quality_standard" gorm:"foreignKey:MaterialQualityStandardID"`
+	ActualValue               float64                 `json:"actual_value"`
+	Result                    string                  `json:"result" gorm:"size:20;not null"` // pass, fail
+	Remark                    string                  `json:"remark" gorm:"size:500"`
+	CreatedAt                 time.Time               `json:"created_at"`
+	UpdatedAt                 time.Time               `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (MaterialQualityStandard) TableName() string {
+	return "material_quality_standards"
+}
+
+func (IncomingInspection) TableName() string {
+	return "incoming_inspections"
+}
+
+func (IncomingInspectionItem) TableName() string {
+	return "incoming_inspection_items"
+}
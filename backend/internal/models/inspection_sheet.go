@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InspectionSheet 检测单，一次抽样同时检测多个质量特性，明细为各特性的质量检测记录
+type InspectionSheet struct {
+	ID                uint                `json:"id" gorm:"primarykey"`
+	SheetNo           string              `json:"sheet_no" gorm:"uniqueIndex;size:50;not null"`
+	ProductionOrderID uint                `json:"production_order_id" gorm:"index;not null"`
+	ProductionOrder   ProductionOrder     `json:"production_order" gorm:"foreignKey:ProductionOrderID"`
+	InspectionLotID   *uint               `json:"inspection_lot_id" gorm:"index"` // 所属检验批，每张检测单为一个样本单位
+	SampleNo          int                 `json:"sample_no" gorm:"not null"`      // 样品序号
+	InspectorID       uint                `json:"inspector_id" gorm:"not null"`
+	Inspector         User                `json:"inspector" gorm:"foreignKey:InspectorID"`
+	EquipmentID       *uint               `json:"equipment_id" gorm:"index"` // 生产设备
+	Equipment         *Equipment          `json:"equipment,omitempty" gorm:"foreignKey:EquipmentID"`
+	InspectionTime    time.Time           `json:"inspection_time" gorm:"index;not null"`
+	Result            string              `json:"result" gorm:"size:20;not null"` // 总体结论，任一明细不合格即为 fail
+	LineCount         int                 `json:"line_count"`
+	FailedCount       int                 `json:"failed_count"`
+	Remark            string              `json:"remark" gorm:"size:500"`
+	CreatedBy         uint                `json:"created_by"`
+	Creator           User                `json:"creator" gorm:"foreignKey:CreatedBy"`
+	Lines             []QualityInspection `json:"lines,omitempty" gorm:"foreignKey:InspectionSheetID"`
+	CreatedAt         time.Time           `json:"created_at"`
+	UpdatedAt         time.Time           `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt      `json:"-" gorm:"index"`
+}
+
+// TableName 指定表名
+func (InspectionSheet) TableName() string {
+	return "inspection_sheets"
+}
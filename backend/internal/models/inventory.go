@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InventoryCount 盘点单
+type InventoryCount struct {
+	ID           uint                 `json:"id" gorm:"primarykey"`
+	CountNo      string               `json:"count_no" gorm:"uniqueIndex;size:50;not null"`
+	Locations    string               `json:"locations" gorm:"size:500"` // 盘点库位，多个以逗号分隔
+	MaterialType string               `json:"material_type" gorm:"size:50"`
+	Status       string               `json:"status" gorm:"size:20;default:'counting';not null"` // counting:盘点中 approved:已审核 cancelled:已取消
+	Remark       string               `json:"remark" gorm:"size:500"`
+	CreatedBy    uint                 `json:"created_by"`
+	Creator      User                 `json:"creator" gorm:"foreignKey:CreatedBy"`
+	ApprovedBy   *uint                `json:"approved_by"`
+	Approver     *User                `json:"approver,omitempty" gorm:"foreignKey:ApprovedBy"`
+	ApprovedAt   *time.Time           `json:"approved_at"`
+	Lines        []InventoryCountLine `json:"lines" gorm:"foreignKey:CountID"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt       `json:"-" gorm:"index"`
+}
+
+// InventoryCountLine 盘点明细
+type InventoryCountLine struct {
+	ID              uint           `json:"id" gorm:"primarykey"`
+	CountID         uint           `json:"count_id" gorm:"index;not null"`
+	MaterialID      uint           `json:"material_id" gorm:"not null"`
+	Material        Material       `json:"material" gorm:"foreignKey:MaterialID"`
+	FrozenQuantity  float64        `json:"frozen_quantity" gorm:"type:decimal(16,4)"`  // 冻结时的账面库存
+	CountedQuantity *float64       `json:"counted_quantity" gorm:"type:decimal(16,4)"` // 实盘数量，未录入时为空
+	ReasonCode      string         `json:"reason_code" gorm:"size:50"`
+	Remark          string         `json:"remark" gorm:"size:500"`
+	TransactionID   *uint          `json:"transaction_id"` // 审核后生成的调整交易
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName 指定表名
+func (InventoryCount) TableName() string {
+	return "inventory_counts"
+}
+
+func (InventoryCountLine) TableName() string {
+	return "inventory_count_lines"
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LabelTemplate 标签模板
+type LabelTemplate struct {
+	ID          uint           `json:"id" gorm:"primarykey"`
+	Code        string         `json:"code" gorm:"uniqueIndex;size:50;not null"`
+	Name        string         `json:"name" gorm:"size:100;not null"`
+	EntityType  string         `json:"entity_type" gorm:"size:30;index;not null"`   // material:物料 lot:批次 production_order:生产工单 equipment:设备
+	Symbology   string         `json:"symbology" gorm:"size:20;not null"`           // code128:一维码 qr:二维码
+	WidthMM     float64        `json:"width_mm" gorm:"type:decimal(8,2);not null"`  // 标签宽度（毫米）
+	HeightMM    float64        `json:"height_mm" gorm:"type:decimal(8,2);not null"` // 标签高度（毫米）
+	DPI         int            `json:"dpi" gorm:"default:203"`                      // 打印分辨率，用于PNG和ZPL
+	Fields      string         `json:"fields" gorm:"size:500"`                      // 标签上打印的字段，逗号分隔，按顺序打印，第一个字段为标题
+	ShowPayload bool           `json:"show_payload"`                                // 是否在条码下方打印条码内容
+	IsDefault   bool           `json:"is_default" gorm:"default:false"`             // 是否为该对象类型的默认模板
+	Description string         `json:"description" gorm:"type:text"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName 指定表名
+func (LabelTemplate) TableName() string {
+	return "label_templates"
+}
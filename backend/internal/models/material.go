@@ -7,36 +7,68 @@ import (
 
 // Material 物料信息
 type Material struct {
-	ID           uint           `json:"id" gorm:"primarykey"`
-	Code         string         `json:"code" gorm:"uniqueIndex;size:50;not null"`
-	Name         string         `json:"name" gorm:"size:100;not null"`
-	Type         string         `json:"type" gorm:"size:50"`           // 改为 Type，与服务层一致
-	Unit         string         `json:"unit" gorm:"size:20"`
-	Price        float64        `json:"price" gorm:"type:decimal(10,2)"`
-	MinStock     int            `json:"min_stock" gorm:"default:0"`
-	MaxStock     int            `json:"max_stock" gorm:"default:0"`
-	CurrentStock int            `json:"current_stock" gorm:"default:0"`
-	Description  string         `json:"description" gorm:"size:500"`    // 添加描述字段
-	Status       int            `json:"status" gorm:"default:1"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                 uint           `json:"id" gorm:"primarykey"`
+	Code               string         `json:"code" gorm:"uniqueIndex;size:50;not null"`
+	Name               string         `json:"name" gorm:"size:100;not null"`
+	Type               string         `json:"type" gorm:"size:50"`            // 改为 Type，与服务层一致
+	Unit               string         `json:"unit" gorm:"size:20"`            // 基本计量单位，对应计量单位编码
+	Location           string         `json:"location" gorm:"size:100;index"` // 存放库位
+	Price              float64        `json:"price" gorm:"type:decimal(16,4)"`
+	MinStock           float64        `json:"min_stock" gorm:"type:decimal(16,4);default:0"`
+	MaxStock           float64        `json:"max_stock" gorm:"type:decimal(16,4);default:0"`
+	LeadTimeDays       int            `json:"lead_time_days" gorm:"default:0"` // 采购提前期（天）
+	CurrentStock       float64        `json:"current_stock" gorm:"type:decimal(16,4);default:0"`
+	QuarantineStock    float64        `json:"quarantine_stock" gorm:"type:decimal(16,4);default:0"`   // 待检隔离库存，包含在当前库存中但不能发料
+	BlockedStock       float64        `json:"blocked_stock" gorm:"type:decimal(16,4);default:0"`      // 质量冻结库存，包含在当前库存中但不能发料
+	OnHold             bool           `json:"on_hold" gorm:"default:false"`                           // 是否存在整体冻结，整体冻结时全部库存不能发料
+	InspectionRequired bool           `json:"inspection_required" gorm:"default:false"`               // 收货是否需要来料检验
+	CostingMethod      string         `json:"costing_method" gorm:"size:20;default:'moving_average'"` // moving_average:移动加权平均 fifo:先进先出
+	AverageCost        float64        `json:"average_cost" gorm:"type:decimal(12,4);default:0"`       // 当前单位成本
+	StockValue         float64        `json:"stock_value" gorm:"type:decimal(14,2);default:0"`        // 当前库存金额
+	Description        string         `json:"description" gorm:"size:500"`                            // 添加描述字段
+	Status             int            `json:"status" gorm:"default:1"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // MaterialTransaction 物料出入库记录
 type MaterialTransaction struct {
+	ID                  uint           `json:"id" gorm:"primarykey"`
+	MaterialID          uint           `json:"material_id"`
+	Material            Material       `json:"material" gorm:"foreignKey:MaterialID"`
+	Type                string         `json:"type" gorm:"size:20;not null"` // in:入库 out:生产领用 return_supplier:退供应商 return_production:生产退料 scrap:报废 adjust_in:盘盈调整 adjust_out:盘亏调整
+	Quantity            float64        `json:"quantity" gorm:"type:decimal(16,4);not null"`
+	EnteredQuantity     float64        `json:"entered_quantity" gorm:"type:decimal(16,4)"`            // 录入数量（录入单位）
+	EnteredUnit         string         `json:"entered_unit" gorm:"size:20"`                           // 录入单位，Quantity 为换算后的基本单位数量
+	Price               float64        `json:"price" gorm:"type:decimal(16,4);default:0"`             // 添加单价字段
+	TotalAmount         float64        `json:"total_amount" gorm:"type:decimal(12,2);default:0"`      // 添加总金额字段
+	Supplier            string         `json:"supplier" gorm:"size:100"`                              // 添加供应商字段
+	SupplierID          *uint          `json:"supplier_id" gorm:"index"`                              // 关联供应商
+	RejectedQuantity    float64        `json:"rejected_quantity" gorm:"type:decimal(16,4);default:0"` // 收货时的不合格数量，Quantity 为合格入库数量
+	ProductionOrderID   *uint          `json:"production_order_id"`                                   // 添加生产工单ID字段
+	PurchaseOrderLineID *uint          `json:"purchase_order_line_id" gorm:"index"`                   // 关联的采购订单行（按采购订单收货时）
+	RequisitionID       *uint          `json:"requisition_id" gorm:"index"`                           // 关联的领料单（按领料单发料时）
+	RequisitionLineID   *uint          `json:"requisition_line_id"`                                   // 关联的领料单行
+	LotNo               string         `json:"lot_no" gorm:"size:50;index"`                           // 批次号，入库时生成，出库时可指定所用批次
+	ReasonCode          string         `json:"reason_code" gorm:"size:50"`                            // 原因代码
+	Remark              string         `json:"remark" gorm:"size:500"`                                // 改名为 Remark，与服务层一致
+	OperatorID          uint           `json:"operator_id"`
+	Operator            User           `json:"operator" gorm:"foreignKey:OperatorID"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// MaterialCostLayer 物料成本层（先进先出计价时使用）
+type MaterialCostLayer struct {
 	ID                uint           `json:"id" gorm:"primarykey"`
-	MaterialID        uint           `json:"material_id"`
-	Material          Material       `json:"material" gorm:"foreignKey:MaterialID"`
-	Type              string         `json:"type" gorm:"size:20;not null"` // in:入库 out:出库
-	Quantity          int            `json:"quantity" gorm:"not null"`
-	Price             float64        `json:"price" gorm:"type:decimal(10,2);default:0"` // 添加单价字段
-	TotalAmount       float64        `json:"total_amount" gorm:"type:decimal(12,2);default:0"` // 添加总金额字段
-	Supplier          string         `json:"supplier" gorm:"size:100"`      // 添加供应商字段
-	ProductionOrderID *uint          `json:"production_order_id"`           // 添加生产工单ID字段
-	Remark            string         `json:"remark" gorm:"size:500"`        // 改名为 Remark，与服务层一致
-	OperatorID        uint           `json:"operator_id"`
-	Operator          User           `json:"operator" gorm:"foreignKey:OperatorID"`
+	MaterialID        uint           `json:"material_id" gorm:"index;not null"`
+	TransactionID     *uint          `json:"transaction_id"` // 形成该成本层的入库交易，期初成本层为空
+	Quantity          float64        `json:"quantity" gorm:"type:decimal(16,4);not null"`
+	RemainingQuantity float64        `json:"remaining_quantity" gorm:"type:decimal(16,4);not null"`
+	UnitCost          float64        `json:"unit_cost" gorm:"type:decimal(12,4);not null"`
+	ReceivedAt        time.Time      `json:"received_at" gorm:"index"`
 	CreatedAt         time.Time      `json:"created_at"`
 	UpdatedAt         time.Time      `json:"updated_at"`
 	DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
@@ -49,4 +81,8 @@ func (Material) TableName() string {
 
 func (MaterialTransaction) TableName() string {
 	return "material_transactions"
+}
+
+func (MaterialCostLayer) TableName() string {
+	return "material_cost_layers"
 }
\ No newline at end of file
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaterialHold 物料质量冻结
+type MaterialHold struct {
+	ID               uint              `json:"id" gorm:"primarykey"`
+	HoldNo           string            `json:"hold_no" gorm:"uniqueIndex;size:50;not null"`
+	MaterialID       uint              `json:"material_id" gorm:"index;not null"`
+	Material         Material          `json:"material" gorm:"foreignKey:MaterialID"`
+	Quantity         float64           `json:"quantity" gorm:"type:decimal(16,4);not null"` // 冻结数量，0 表示整体冻结该物料
+	ReleasedQuantity float64           `json:"released_quantity" gorm:"type:decimal(16,4);default:0"`
+	ScrappedQuantity float64           `json:"scrapped_quantity" gorm:"type:decimal(16,4);default:0"`
+	Reason           string            `json:"reason" gorm:"size:500;not null"`
+	OwnerID          uint              `json:"owner_id" gorm:"not null"` // 责任人
+	Owner            User              `json:"owner" gorm:"foreignKey:OwnerID"`
+	Status           string            `json:"status" gorm:"size:20;default:'active';not null"` // active:冻结中 released:已解除 scrapped:已报废 closed:部分解除部分报废
+	CreatedBy        uint              `json:"created_by"`
+	Creator          User              `json:"creator" gorm:"foreignKey:CreatedBy"`
+	ClosedAt         *time.Time        `json:"closed_at"`
+	Logs             []MaterialHoldLog `json:"logs" gorm:"foreignKey:HoldID"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt    `json:"-" gorm:"index"`
+}
+
+// MaterialHoldLog 物料质量冻结操作记录
+type MaterialHoldLog struct {
+	ID            uint      `json:"id" gorm:"primarykey"`
+	HoldID        uint      `json:"hold_id" gorm:"index;not null"`
+	Action        string    `json:"action" gorm:"size:20;not null"` // hold:冻结 release:解除 scrap:报废
+	Quantity      float64   `json:"quantity" gorm:"type:decimal(16,4)"`
+	TransactionID *uint     `json:"transaction_id"` // 报废时生成的出库交易
+	OperatorID    uint      `json:"operator_id"`
+	Operator      User      `json:"operator" gorm:"foreignKey:OperatorID"`
+	Remark        string    `json:"remark" gorm:"size:500"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (MaterialHold) TableName() string {
+	return "material_holds"
+}
+
+func (MaterialHoldLog) TableName() string {
+	return "material_hold_logs"
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProductDemand 产品需求（预测或计划需求），MRP按需求日期展开
+type ProductDemand struct {
+	ID         uint           `json:"id" gorm:"primarykey"`
+	ProductID  uint           `json:"product_id" gorm:"index;not null"`
+	Product    Product        `json:"product" gorm:"foreignKey:ProductID"`
+	DemandDate time.Time      `json:"demand_date" gorm:"index;not null"`
+	Quantity   float64        `json:"quantity" gorm:"type:decimal(16,4);not null"`
+	Type       string         `json:"type" gorm:"size:20;default:'forecast';not null"` // forecast:预测 firm:确定需求
+	Remark     string         `json:"remark" gorm:"size:500"`
+	CreatedBy  uint           `json:"created_by"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// ProductMaterial 产品物料消耗定额，每生产一个单位产品消耗的物料数量
+type ProductMaterial struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	ProductID   uint      `json:"product_id" gorm:"uniqueIndex:idx_product_material;not null"`
+	MaterialID  uint      `json:"material_id" gorm:"uniqueIndex:idx_product_material;not null"`
+	Material    Material  `json:"material" gorm:"foreignKey:MaterialID"`
+	QuantityPer float64   `json:"quantity_per" gorm:"type:decimal(16,4);not null"` // 单位产品消耗数量（物料基本单位）
+	ScrapRate   float64   `json:"scrap_rate" gorm:"type:decimal(5,4);default:0"`   // 损耗率，如0.05表示5%
+	Remark      string    `json:"remark" gorm:"size:500"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// MrpRun MRP运行记录
+type MrpRun struct {
+	ID                      uint                    `json:"id" gorm:"primarykey"`
+	RunNo                   string                  `json:"run_no" gorm:"uniqueIndex;size:50;not null"`
+	HorizonDays             int                     `json:"horizon_days"`                                    // 计划展望期（天）
+	Status                  string                  `json:"status" gorm:"size:20;default:'active';not null"` // active:有效 superseded:已被新运行取代
+	PlannedOrderCount       int                     `json:"planned_order_count"`
+	PurchaseSuggestionCount int                     `json:"purchase_suggestion_count"`
+	Remark                  string                  `json:"remark" gorm:"size:500"`
+	CreatedBy               uint                    `json:"created_by"`
+	Creator                 User                    `json:"creator" gorm:"foreignKey:CreatedBy"`
+	PlannedOrders           []MrpPlannedOrder       `json:"planned_orders,omitempty" gorm:"foreignKey:RunID"`
+	PurchaseSuggestions     []MrpPurchaseSuggestion `json:"purchase_suggestions,omitempty" gorm:"foreignKey:RunID"`
+	CreatedAt               time.Time               `json:"created_at"`
+	UpdatedAt               time.Time               `json:"updated_at"`
+	DeletedAt               gorm.DeletedAt          `json:"-" gorm:"index"`
+}
+
+// MrpPlannedOrder MRP计划生产订单，确认后生成生产工单
+type MrpPlannedOrder struct {
+	ID                 uint             `json:"id" gorm:"primarykey"`
+	RunID              uint             `json:"run_id" gorm:"index;not null"`
+	ProductID          uint             `json:"product_id" gorm:"index;not null"`
+	Product            Product          `json:"product" gorm:"foreignKey:ProductID"`
+	GrossRequirement   float64          `json:"gross_requirement" gorm:"type:decimal(16,4)"`      // 需求日期的毛需求
+	ProjectedAvailable float64          `json:"projected_available" gorm:"type:decimal(16,4)"`    // 扣减前的预计可用量（库存 + 已到期的未完工工单）
+	NetRequirement     float64          `json:"net_requirement" gorm:"type:decimal(16,4)"`        // 净需求
+	Quantity           float64          `json:"quantity" gorm:"type:decimal(16,4);not null"`      // 计划生产数量
+	StartDate          time.Time        `json:"start_date"`                                       // 计划开工日期 = 需求日期 - 生产提前期
+	DueDate            time.Time        `json:"due_date" gorm:"index"`                            // 需求日期
+	PastDue            bool             `json:"past_due"`                                         // 计划开工日期已过
+	Status             string           `json:"status" gorm:"size:20;default:'planned';not null"` // planned:计划 firmed:已确认 cancelled:已取消
+	ProductionOrderID  *uint            `json:"production_order_id"`                              // 确认后生成的生产工单
+	ProductionOrder    *ProductionOrder `json:"production_order,omitempty" gorm:"foreignKey:ProductionOrderID"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+}
+
+// MrpPurchaseSuggestion MRP物料采购建议，确认后生成草稿采购订单
+type MrpPurchaseSuggestion struct {
+	ID                 uint           `json:"id" gorm:"primarykey"`
+	RunID              uint           `json:"run_id" gorm:"index;not null"`
+	MaterialID         uint           `json:"material_id" gorm:"index;not null"`
+	Material           Material       `json:"material" gorm:"foreignKey:MaterialID"`
+	GrossRequirement   float64        `json:"gross_requirement" gorm:"type:decimal(16,4)"`      // 需求日期的毛需求
+	ProjectedAvailable float64        `json:"projected_available" gorm:"type:decimal(16,4)"`    // 扣减前的预计可用量（可用库存 - 最小库存 + 已到期的采购在途）
+	NetRequirement     float64        `json:"net_requirement" gorm:"type:decimal(16,4)"`        // 净需求
+	Quantity           float64        `json:"quantity" gorm:"type:decimal(16,4);not null"`      // 建议采购数量
+	OrderDate          time.Time      `json:"order_date"`                                       // 建议下单日期 = 需求日期 - 采购提前期
+	NeedDate           time.Time      `json:"need_date" gorm:"index"`                           // 需求日期（要求到货日期）
+	PastDue            bool           `json:"past_due"`                                         // 建议下单日期已过
+	Status             string         `json:"status" gorm:"size:20;default:'planned';not null"` // planned:计划 firmed:已确认 cancelled:已取消
+	PurchaseOrderID    *uint          `json:"purchase_order_id"`                                // 确认后生成的采购订单
+	PurchaseOrder      *PurchaseOrder `json:"purchase_order,omitempty" gorm:"foreignKey:PurchaseOrderID"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ProductDemand) TableName() string {
+	return "product_demands"
+}
+
+func (ProductMaterial) TableName() string {
+	return "product_materials"
+}
+
+func (MrpRun) TableName() string {
+	return "mrp_runs"
+}
+
+func (MrpPlannedOrder) TableName() string {
+	return "mrp_planned_orders"
+}
+
+func (MrpPurchaseSuggestion) TableName() string {
+	return "mrp_purchase_suggestions"
+}
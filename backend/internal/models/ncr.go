@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NonconformanceReport 不合格品报告（NCR），手工创建或由不合格的检测记录、检测单、不接收的检验批自动创建
+type NonconformanceReport struct {
+	ID                  uint                      `json:"id" gorm:"primarykey"`
+	NcrNo               string                    `json:"ncr_no" gorm:"uniqueIndex;size:50;not null"`
+	Source              string                    `json:"source" gorm:"size:20;default:'manual';not null"` // manual:手工 inspection:质量检测 sheet:检测单 lot:检验批
+	AutoCreated         bool                      `json:"auto_created"`                                    // 由不合格检测结果自动创建
+	QualityInspectionID *uint                     `json:"quality_inspection_id" gorm:"index"`
+	InspectionSheetID   *uint                     `json:"inspection_sheet_id" gorm:"index"`
+	InspectionLotID     *uint                     `json:"inspection_lot_id" gorm:"index"`
+	ProductionOrderID   *uint                     `json:"production_order_id" gorm:"index"`
+	ProductionOrder     *ProductionOrder          `json:"production_order,omitempty" gorm:"foreignKey:ProductionOrderID"`
+	ProductID           uint                      `json:"product_id" gorm:"index;not null"`
+	Product             Product                   `json:"product" gorm:"foreignKey:ProductID"`
+	SupplierID          *uint                     `json:"supplier_id" gorm:"index"` // 退供应商处置时的责任供应商
+	Supplier            *Supplier                 `json:"supplier,omitempty" gorm:"foreignKey:SupplierID"`
+	AffectedQuantity    float64                   `json:"affected_quantity" gorm:"type:decimal(16,4);not null"`
+	Description         string                    `json:"description" gorm:"size:1000;not null"`
+	Containment         string                    `json:"containment" gorm:"size:1000"`                  // 围堵措施
+	Disposition         string                    `json:"disposition" gorm:"size:30"`                    // scrap:报废 rework:返工 use_as_is:让步接收 return_to_supplier:退供应商
+	DispositionRemark   string                    `json:"disposition_remark" gorm:"size:500"`            // 处置说明
+	Status              string                    `json:"status" gorm:"size:20;default:'open';not null"` // open:待处置 submitted:待审批 approved:已批准 cancelled:已取消
+	ReportedBy          uint                      `json:"reported_by"`                                   // 报告人，自动创建时为检测员
+	Reporter            User                      `json:"reporter" gorm:"foreignKey:ReportedBy"`
+	SubmittedBy         *uint                     `json:"submitted_by"`
+	SubmittedAt         *time.Time                `json:"submitted_at"`
+	ApprovedBy          *uint                     `json:"approved_by"`
+	Approver            *User                     `json:"approver,omitempty" gorm:"foreignKey:ApprovedBy"`
+	ApprovedAt          *time.Time                `json:"approved_at"`
+	ReworkOrderID       *uint                     `json:"rework_order_id" gorm:"index"` // 返工处置批准后生成的返工生产工单
+	ReworkOrder         *ProductionOrder          `json:"rework_order,omitempty" gorm:"foreignKey:ReworkOrderID"`
+	Logs                []NonconformanceReportLog `json:"logs" gorm:"foreignKey:NcrID"`
+	CreatedAt           time.Time                 `json:"created_at"`
+	UpdatedAt           time.Time                 `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt            `json:"-" gorm:"index"`
+}
+
+// NonconformanceReportLog 不合格品报告操作记录
+type NonconformanceReportLog struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	NcrID        uint      `json:"ncr_id" gorm:"index;not null"`
+	Action       string    `json:"action" gorm:"size:20;not null"` // create:创建 update:修改 submit:提交处置 approve:批准 reject:驳回 cancel:取消
+	Disposition  string    `json:"disposition" gorm:"size:30"`
+	OperatorID   uint      `json:"operator_id"`
+	Operator     User      `json:"operator" gorm:"foreignKey:OperatorID"`
+	OperatorRole string    `json:"operator_role" gorm:"size:20"`
+	Remark       string    `json:"remark" gorm:"size:500"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (NonconformanceReport) TableName() string {
+	return "nonconformance_reports"
+}
+
+func (NonconformanceReportLog) TableName() string {
+	return "nonconformance_report_logs"
+}
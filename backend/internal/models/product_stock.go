@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProductTransaction 成品库存交易
+type ProductTransaction struct {
+	ID                uint             `json:"id" gorm:"primarykey"`
+	ProductID         uint             `json:"product_id" gorm:"index;not null"`
+	Product           Product          `json:"product" gorm:"foreignKey:ProductID"`
+	Type              string           `json:"type" gorm:"size:20;not null"` // production_receipt:生产入库 production_reversal:生产入库冲回 scrap:报废 adjust_in:盘盈调整 adjust_out:盘亏调整 shipment:发货出库
+	Quantity          float64          `json:"quantity" gorm:"type:decimal(16,4);not null"`
+	ProductionOrderID *uint            `json:"production_order_id" gorm:"index"` // 关联的生产工单（生产入库和冲回时）
+	ProductionOrder   *ProductionOrder `json:"production_order,omitempty" gorm:"foreignKey:ProductionOrderID"`
+	ShipmentLineID    *uint            `json:"shipment_line_id" gorm:"index"` // 关联的发货单行（发货出库时）
+	LotNo             string           `json:"lot_no" gorm:"size:50;index"`   // 成品批次号，生产入库时生成，其他交易可指定所用批次
+	ReasonCode        string           `json:"reason_code" gorm:"size:50"`    // 原因代码
+	Remark            string           `json:"remark" gorm:"size:500"`
+	OperatorID        uint             `json:"operator_id"`
+	Operator          User             `json:"operator" gorm:"foreignKey:OperatorID"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt   `json:"-" gorm:"index"`
+}
+
+// TableName 指定表名
+func (ProductTransaction) TableName() string {
+	return "product_transactions"
+}
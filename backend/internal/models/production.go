@@ -7,35 +7,40 @@ import (
 
 // ProductionOrder 生产工单
 type ProductionOrder struct {
-	ID           uint           `json:"id" gorm:"primarykey"`
-	OrderNo      string         `json:"order_no" gorm:"uniqueIndex;size:50;not null"`
-	ProductID    uint           `json:"product_id"`
-	Product      Product        `json:"product" gorm:"foreignKey:ProductID"`
-	Quantity     int            `json:"quantity" gorm:"not null"`
-	Produced     int            `json:"produced" gorm:"default:0"`
-	Status       string         `json:"status" gorm:"size:20;default:'pending'"` // pending, processing, completed, cancelled
-	Priority     int            `json:"priority" gorm:"default:1"`
-	StartDate    *time.Time     `json:"start_date"`
-	EndDate      *time.Time     `json:"end_date"`
-	CreatedBy    uint           `json:"created_by"`
-	Creator      User           `json:"creator" gorm:"foreignKey:CreatedBy"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID               uint           `json:"id" gorm:"primarykey"`
+	OrderNo          string         `json:"order_no" gorm:"uniqueIndex;size:50;not null"`
+	ProductID        uint           `json:"product_id"`
+	Product          Product        `json:"product" gorm:"foreignKey:ProductID"`
+	Quantity         float64        `json:"quantity" gorm:"type:decimal(16,4);not null"`
+	Produced         float64        `json:"produced" gorm:"type:decimal(16,4);default:0"`
+	Shipped          float64        `json:"shipped" gorm:"type:decimal(16,4);default:0"`  // 已发货数量，不能超过已生产数量
+	Status           string         `json:"status" gorm:"size:20;default:'pending'"`      // pending, processing, completed, cancelled
+	OrderType        string         `json:"order_type" gorm:"size:20;default:'standard'"` // standard:标准生产 rework:不合格品返工
+	Priority         int            `json:"priority" gorm:"default:1"`
+	SalesOrderLineID *uint          `json:"sales_order_line_id" gorm:"index"` // 按订单生产时关联的销售订单行
+	StartDate        *time.Time     `json:"start_date"`
+	EndDate          *time.Time     `json:"end_date"`
+	CreatedBy        uint           `json:"created_by"`
+	Creator          User           `json:"creator" gorm:"foreignKey:CreatedBy"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // Product 产品信息
 type Product struct {
-	ID          uint           `json:"id" gorm:"primarykey"`
-	Code        string         `json:"code" gorm:"uniqueIndex;size:50;not null"`
-	Name        string         `json:"name" gorm:"size:100;not null"`
-	Description string         `json:"description" gorm:"type:text"`
-	Unit        string         `json:"unit" gorm:"size:20"`
-	Price       float64        `json:"price" gorm:"type:decimal(10,2)"`
-	Status      int            `json:"status" gorm:"default:1"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint           `json:"id" gorm:"primarykey"`
+	Code         string         `json:"code" gorm:"uniqueIndex;size:50;not null"`
+	Name         string         `json:"name" gorm:"size:100;not null"`
+	Description  string         `json:"description" gorm:"type:text"`
+	Unit         string         `json:"unit" gorm:"size:20"`
+	Price        float64        `json:"price" gorm:"type:decimal(10,2)"`
+	CurrentStock float64        `json:"current_stock" gorm:"type:decimal(16,4);default:0"` // 成品库存，只能通过成品库存交易变更
+	LeadTimeDays int            `json:"lead_time_days" gorm:"default:0"`                   // 生产提前期（天），MRP按此倒排计划开工日期
+	Status       int            `json:"status" gorm:"default:1"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // TableName 指定表名
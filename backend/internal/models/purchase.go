@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PurchaseOrder 采购订单
+type PurchaseOrder struct {
+	ID          uint                `json:"id" gorm:"primarykey"`
+	OrderNo     string              `json:"order_no" gorm:"uniqueIndex;size:50;not null"`
+	SupplierID  *uint               `json:"supplier_id" gorm:"index"`
+	Supplier    string              `json:"supplier" gorm:"size:100;not null"` // 下单时的供应商名称
+	OrderDate   time.Time           `json:"order_date"`
+	Status      string              `json:"status" gorm:"size:20;default:'draft';not null"` // draft:草稿 released:已下达 partial:部分收货 received:已收货 closed:已关闭 cancelled:已取消
+	TotalAmount float64             `json:"total_amount" gorm:"type:decimal(14,2);default:0"`
+	Remark      string              `json:"remark" gorm:"size:500"`
+	CreatedBy   uint                `json:"created_by"`
+	Creator     User                `json:"creator" gorm:"foreignKey:CreatedBy"`
+	Lines       []PurchaseOrderLine `json:"lines" gorm:"foreignKey:PurchaseOrderID"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt      `json:"-" gorm:"index"`
+}
+
+// PurchaseOrderLine 采购订单行
+type PurchaseOrderLine struct {
+	ID               uint           `json:"id" gorm:"primarykey"`
+	PurchaseOrderID  uint           `json:"purchase_order_id" gorm:"index;not null"`
+	LineNo           int            `json:"line_no" gorm:"not null"`
+	MaterialID       uint           `json:"material_id" gorm:"not null"`
+	Material         Material       `json:"material" gorm:"foreignKey:MaterialID"`
+	Quantity         float64        `json:"quantity" gorm:"type:decimal(16,4);not null"`
+	ReceivedQuantity float64        `json:"received_quantity" gorm:"type:decimal(16,4);default:0"`
+	Price            float64        `json:"price" gorm:"type:decimal(10,2);default:0"`
+	DueDate          time.Time      `json:"due_date" gorm:"index"`
+	Status           string         `json:"status" gorm:"size:20;default:'open';not null"` // open:未结 received:已收齐 closed:已关闭
+	Remark           string         `json:"remark" gorm:"size:500"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName 指定表名
+func (PurchaseOrder) TableName() string {
+	return "purchase_orders"
+}
+
+func (PurchaseOrderLine) TableName() string {
+	return "purchase_order_lines"
+}
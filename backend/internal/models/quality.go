@@ -7,38 +7,65 @@ import (
 
 // QualityStandard 质量标准
 type QualityStandard struct {
-	ID          uint           `json:"id" gorm:"primarykey"`
-	ProductID   uint           `json:"product_id" gorm:"not null"`
-	Product     Product        `json:"product" gorm:"foreignKey:ProductID"`
-	Name        string         `json:"name" gorm:"size:100;not null"`
-	Type        string         `json:"type" gorm:"size:50;not null"`
-	MinValue    float64        `json:"min_value"`
-	MaxValue    float64        `json:"max_value"`
-	TargetValue float64        `json:"target_value"`
-	Unit        string         `json:"unit" gorm:"size:20"`
-	Description string         `json:"description" gorm:"type:text"`
-	IsActive    bool           `json:"is_active" gorm:"default:true"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                 uint           `json:"id" gorm:"primarykey"`
+	ProductID          uint           `json:"product_id" gorm:"not null"`
+	Product            Product        `json:"product" gorm:"foreignKey:ProductID"`
+	Name               string         `json:"name" gorm:"size:100;not null"`
+	Type               string         `json:"type" gorm:"size:50;not null"`
+	CharacteristicType string         `json:"characteristic_type" gorm:"size:20;default:'variable'"` // 特性类型 variable:计量型 attribute:计数型(合格/不合格) defect_count:缺陷数
+	MaxDefects         int            `json:"max_defects"`                                           // 缺陷数型特性允许的最大缺陷数（含）
+	MinValue           float64        `json:"min_value"`
+	MaxValue           float64        `json:"max_value"`
+	LimitType          string         `json:"limit_type" gorm:"size:20;default:'two_sided'"`   // two_sided:双侧 lower:仅下限 upper:仅上限
+	MinBoundary        string         `json:"min_boundary" gorm:"size:20;default:'inclusive'"` // 下限边界 inclusive:等于下限合格 exclusive:等于下限不合格
+	MaxBoundary        string         `json:"max_boundary" gorm:"size:20;default:'inclusive'"` // 上限边界 inclusive:等于上限合格 exclusive:等于上限不合格
+	TargetValue        float64        `json:"target_value"`
+	Unit               string         `json:"unit" gorm:"size:20"`
+	Description        string         `json:"description" gorm:"type:text"`
+	IsActive           bool           `json:"is_active" gorm:"default:true"`
+	SpcRules           string         `json:"spc_rules" gorm:"size:50;default:'1,2,3,4,5,6,7,8'"` // 启用的Nelson判异规则编号，逗号分隔，为空不判异
+	SpcBlockCompletion bool           `json:"spc_block_completion"`                               // 存在未确认的判异事件时禁止生产工单完成
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // QualityInspection 质量检验记录
 type QualityInspection struct {
-	ID                uint           `json:"id" gorm:"primarykey"`
-	ProductionOrderID uint           `json:"production_order_id" gorm:"not null"`
-	ProductionOrder   ProductionOrder `json:"production_order" gorm:"foreignKey:ProductionOrderID"`
-	QualityStandardID uint           `json:"quality_standard_id" gorm:"not null"`
-	QualityStandard   QualityStandard `json:"quality_standard" gorm:"foreignKey:QualityStandardID"`
-	InspectorID       uint           `json:"inspector_id" gorm:"not null"`
-	Inspector         User           `json:"inspector" gorm:"foreignKey:InspectorID"`
-	ActualValue       float64        `json:"actual_value"`
-	Result            string         `json:"result" gorm:"size:20;not null"` // pass, fail
-	Remark            string         `json:"remark" gorm:"type:text"`
-	InspectionTime    time.Time      `json:"inspection_time" gorm:"not null"`
-	CreatedAt         time.Time      `json:"created_at"`
-	UpdatedAt         time.Time      `json:"updated_at"`
-	DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                uint                      `json:"id" gorm:"primarykey"`
+	ProductionOrderID uint                      `json:"production_order_id" gorm:"not null"`
+	ProductionOrder   ProductionOrder           `json:"production_order" gorm:"foreignKey:ProductionOrderID"`
+	QualityStandardID uint                      `json:"quality_standard_id" gorm:"not null"`
+	QualityStandard   QualityStandard           `json:"quality_standard" gorm:"foreignKey:QualityStandardID"`
+	InspectionSheetID *uint                     `json:"inspection_sheet_id" gorm:"index"` // 所属检测单，单独检测时为空
+	InspectorID       uint                      `json:"inspector_id" gorm:"not null"`
+	Inspector         User                      `json:"inspector" gorm:"foreignKey:InspectorID"`
+	EquipmentID       *uint                     `json:"equipment_id" gorm:"index"` // 生产设备
+	Equipment         *Equipment                `json:"equipment,omitempty" gorm:"foreignKey:EquipmentID"`
+	ActualValue       float64                   `json:"actual_value"`
+	Result            string                    `json:"result" gorm:"size:20;not null"` // pass, fail
+	JudgedResult      string                    `json:"judged_result" gorm:"size:20"`   // 系统按规格限判定的结果
+	Overridden        bool                      `json:"overridden"`                     // 结果经人工改判
+	Remark            string                    `json:"remark" gorm:"type:text"`
+	InspectionTime    time.Time                 `json:"inspection_time" gorm:"not null"`
+	Defects           []QualityInspectionDefect `json:"defects,omitempty" gorm:"foreignKey:QualityInspectionID"`
+	CreatedAt         time.Time                 `json:"created_at"`
+	UpdatedAt         time.Time                 `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt            `json:"-" gorm:"index"`
+}
+
+// QualityResultOverride 检测结果人工改判记录
+type QualityResultOverride struct {
+	ID                  uint      `json:"id" gorm:"primarykey"`
+	QualityInspectionID uint      `json:"quality_inspection_id" gorm:"index;not null"`
+	ActualValue         float64   `json:"actual_value"`
+	JudgedResult        string    `json:"judged_result" gorm:"size:20"`   // 系统判定结果
+	OverrideResult      string    `json:"override_result" gorm:"size:20"` // 改判后的结果
+	Reason              string    `json:"reason" gorm:"size:500;not null"`
+	OperatorID          uint      `json:"operator_id"`
+	Operator            User      `json:"operator" gorm:"foreignKey:OperatorID"`
+	OperatorRole        string    `json:"operator_role" gorm:"size:20"`
+	CreatedAt           time.Time `json:"created_at"`
 }
 
 // TableName 指定表名
@@ -48,4 +75,8 @@ func (QualityStandard) TableName() string {
 
 func (QualityInspection) TableName() string {
 	return "quality_inspections"
-}
\ No newline at end of file
+}
+
+func (QualityResultOverride) TableName() string {
+	return "quality_result_overrides"
+}
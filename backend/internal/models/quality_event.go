@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// QualityEvent 质量异常事件，如测量值触发SPC判异规则
+type QualityEvent struct {
+	ID                  uint            `json:"id" gorm:"primarykey"`
+	Type                string          `json:"type" gorm:"size:20;default:'spc_rule';not null"` // spc_rule:SPC判异
+	QualityStandardID   uint            `json:"quality_standard_id" gorm:"index;not null"`
+	QualityStandard     QualityStandard `json:"quality_standard" gorm:"foreignKey:QualityStandardID"`
+	QualityInspectionID uint            `json:"quality_inspection_id" gorm:"index;not null"` // 触发事件的检测记录
+	ProductionOrderID   uint            `json:"production_order_id" gorm:"index;not null"`
+	ProductionOrder     ProductionOrder `json:"production_order" gorm:"foreignKey:ProductionOrderID"`
+	Rule                int             `json:"rule"` // 触发的Nelson规则编号1~8
+	Description         string          `json:"description" gorm:"size:200"`
+	Value               float64         `json:"value"`                                         // 触发事件的测量值
+	CenterLine          float64         `json:"center_line"`                                   // 判异时的中心线
+	Sigma               float64         `json:"sigma"`                                         // 判异时估计的标准差
+	BlocksCompletion    bool            `json:"blocks_completion"`                             // 未确认前禁止生产工单完成
+	Status              string          `json:"status" gorm:"size:20;default:'open';not null"` // open:待确认 acknowledged:已确认
+	AcknowledgedBy      *uint           `json:"acknowledged_by"`
+	Acknowledger        *User           `json:"acknowledger,omitempty" gorm:"foreignKey:AcknowledgedBy"`
+	AcknowledgedAt      *time.Time      `json:"acknowledged_at"`
+	AcknowledgeRemark   string          `json:"acknowledge_remark" gorm:"size:500"`
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (QualityEvent) TableName() string {
+	return "quality_events"
+}
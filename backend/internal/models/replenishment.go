@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReplenishmentProposal 补货建议
+type ReplenishmentProposal struct {
+	ID                  uint           `json:"id" gorm:"primarykey"`
+	ProposalNo          string         `json:"proposal_no" gorm:"uniqueIndex;size:50;not null"`
+	MaterialID          uint           `json:"material_id" gorm:"index;not null"`
+	Material            Material       `json:"material" gorm:"foreignKey:MaterialID"`
+	OnHandStock         float64        `json:"on_hand_stock" gorm:"type:decimal(16,4)"`         // 可用在库数量（不含冻结库存）
+	OpenOrderQuantity   float64        `json:"open_order_quantity" gorm:"type:decimal(16,4)"`   // 采购在途数量
+	AvgDailyConsumption float64        `json:"avg_daily_consumption" gorm:"type:decimal(16,4)"` // 日均消耗
+	DaysOfCover         *float64       `json:"days_of_cover" gorm:"type:decimal(10,2)"`         // 可供天数，无消耗时为空
+	LeadTimeDays        int            `json:"lead_time_days"`
+	ReorderPoint        float64        `json:"reorder_point" gorm:"type:decimal(16,4)"` // 再订货点 = 最小库存 + 提前期消耗
+	TargetStock         float64        `json:"target_stock" gorm:"type:decimal(16,4)"`  // 补货目标（最大库存）
+	ProposedQuantity    float64        `json:"proposed_quantity" gorm:"type:decimal(16,4);not null"`
+	ApprovedQuantity    float64        `json:"approved_quantity" gorm:"type:decimal(16,4);default:0"`
+	Status              string         `json:"status" gorm:"size:20;default:'pending';not null;index"` // pending:待审核 approved:已批准 rejected:已驳回 closed:已关闭
+	Trigger             string         `json:"trigger" gorm:"size:20"`                                 // manual:手动运行 schedule:定时运行
+	GeneratedAt         time.Time      `json:"generated_at"`
+	ReviewedBy          *uint          `json:"reviewed_by"`
+	Reviewer            *User          `json:"reviewer,omitempty" gorm:"foreignKey:ReviewedBy"`
+	ReviewedAt          *time.Time     `json:"reviewed_at"`
+	Remark              string         `json:"remark" gorm:"size:500"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName 指定表名
+func (ReplenishmentProposal) TableName() string {
+	return "replenishment_proposals"
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaterialRequisition 领料单
+type MaterialRequisition struct {
+	ID                uint                      `json:"id" gorm:"primarykey"`
+	RequisitionNo     string                    `json:"requisition_no" gorm:"uniqueIndex;size:50;not null"`
+	ProductionOrderID *uint                     `json:"production_order_id" gorm:"index"` // 领料的生产工单
+	ProductionOrder   *ProductionOrder          `json:"production_order,omitempty" gorm:"foreignKey:ProductionOrderID"`
+	CostCenter        string                    `json:"cost_center" gorm:"size:50;index"` // 领料的成本中心（非生产领用）
+	RequiredDate      *time.Time                `json:"required_date"`
+	Status            string                    `json:"status" gorm:"size:20;default:'pending';not null"` // pending:待审批 approved:已批准 partial:部分发料 issued:已发料 closed:已关闭 rejected:已驳回 cancelled:已取消
+	RequestedBy       uint                      `json:"requested_by"`
+	Requester         User                      `json:"requester" gorm:"foreignKey:RequestedBy"`
+	ApprovedBy        *uint                     `json:"approved_by"`
+	Approver          *User                     `json:"approver,omitempty" gorm:"foreignKey:ApprovedBy"`
+	ApprovedAt        *time.Time                `json:"approved_at"`
+	ApprovalRemark    string                    `json:"approval_remark" gorm:"size:500"`
+	Remark            string                    `json:"remark" gorm:"size:500"`
+	Lines             []MaterialRequisitionLine `json:"lines" gorm:"foreignKey:RequisitionID"`
+	CreatedAt         time.Time                 `json:"created_at"`
+	UpdatedAt         time.Time                 `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt            `json:"-" gorm:"index"`
+}
+
+// MaterialRequisitionLine 领料单行
+type MaterialRequisitionLine struct {
+	ID             uint           `json:"id" gorm:"primarykey"`
+	RequisitionID  uint           `json:"requisition_id" gorm:"index;not null"`
+	LineNo         int            `json:"line_no" gorm:"not null"`
+	MaterialID     uint           `json:"material_id" gorm:"index;not null"`
+	Material       Material       `json:"material" gorm:"foreignKey:MaterialID"`
+	Quantity       float64        `json:"quantity" gorm:"type:decimal(16,4);not null"`         // 申请数量（基本单位）
+	IssuedQuantity float64        `json:"issued_quantity" gorm:"type:decimal(16,4);default:0"` // 已发料数量
+	Status         string         `json:"status" gorm:"size:20;default:'open';not null"`       // open:待发料 issued:已发齐 closed:已关闭
+	Remark         string         `json:"remark" gorm:"size:500"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName 指定表名
+func (MaterialRequisition) TableName() string {
+	return "material_requisitions"
+}
+
+func (MaterialRequisitionLine) TableName() string {
+	return "material_requisition_lines"
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Customer 客户
+type Customer struct {
+	ID          uint           `json:"id" gorm:"primarykey"`
+	Code        string         `json:"code" gorm:"uniqueIndex;size:50;not null"`
+	Name        string         `json:"name" gorm:"size:100;not null"`
+	ContactName string         `json:"contact_name" gorm:"size:50"`
+	Phone       string         `json:"phone" gorm:"size:20"`
+	Email       string         `json:"email" gorm:"size:100"`
+	Address     string         `json:"address" gorm:"size:200"`
+	Remark      string         `json:"remark" gorm:"size:500"`
+	Status      int            `json:"status" gorm:"default:1"` // 1:启用 0:停用
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// SalesOrder 销售订单
+type SalesOrder struct {
+	ID         uint             `json:"id" gorm:"primarykey"`
+	OrderNo    string           `json:"order_no" gorm:"uniqueIndex;size:50;not null"`
+	CustomerID uint             `json:"customer_id" gorm:"index;not null"`
+	Customer   Customer         `json:"customer" gorm:"foreignKey:CustomerID"`
+	CustomerPO string           `json:"customer_po" gorm:"size:50"` // 客户采购单号
+	OrderDate  time.Time        `json:"order_date"`
+	Status     string           `json:"status" gorm:"size:20;default:'draft';not null"` // draft:草稿 confirmed:已确认 closed:已关闭 cancelled:已取消
+	Remark     string           `json:"remark" gorm:"size:500"`
+	CreatedBy  uint             `json:"created_by"`
+	Creator    User             `json:"creator" gorm:"foreignKey:CreatedBy"`
+	Lines      []SalesOrderLine `json:"lines" gorm:"foreignKey:SalesOrderID"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt   `json:"-" gorm:"index"`
+}
+
+// SalesOrderLine 销售订单行
+type SalesOrderLine struct {
+	ID              uint           `json:"id" gorm:"primarykey"`
+	SalesOrderID    uint           `json:"sales_order_id" gorm:"index;not null"`
+	LineNo          int            `json:"line_no" gorm:"not null"`
+	ProductID       uint           `json:"product_id" gorm:"index;not null"`
+	Product         Product        `json:"product" gorm:"foreignKey:ProductID"`
+	Quantity        float64        `json:"quantity" gorm:"type:decimal(16,4);not null"`
+	Price           float64        `json:"price" gorm:"type:decimal(10,2);default:0"`
+	ShippedQuantity float64        `json:"shipped_quantity" gorm:"type:decimal(16,4);default:0"` // 已发货数量
+	DueDate         time.Time      `json:"due_date" gorm:"index"`
+	Status          string         `json:"status" gorm:"size:20;default:'open';not null"` // open:未结 closed:已关闭
+	Remark          string         `json:"remark" gorm:"size:500"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName 指定表名
+func (Customer) TableName() string {
+	return "customers"
+}
+
+func (SalesOrder) TableName() string {
+	return "sales_orders"
+}
+
+func (SalesOrderLine) TableName() string {
+	return "sales_order_lines"
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SamplingScheme 抽样方案（GB/T 2828.1 / ISO 2859-1 计数一次抽样），按产品或质量特性配置，记录当前检验严格度及转移规则状态
+type SamplingScheme struct {
+	ID                uint             `json:"id" gorm:"primarykey"`
+	Name              string           `json:"name" gorm:"size:100;not null"`
+	ProductID         uint             `json:"product_id" gorm:"index;not null"`
+	Product           Product          `json:"product" gorm:"foreignKey:ProductID"`
+	QualityStandardID *uint            `json:"quality_standard_id" gorm:"index"` // 适用的质量特性，为空时按检测单总体结论判定
+	QualityStandard   *QualityStandard `json:"quality_standard,omitempty" gorm:"foreignKey:QualityStandardID"`
+	Basis             string           `json:"basis" gorm:"size:20;default:'nonconforming'"` // nonconforming:不合格品数 nonconformities:不合格数（每百单位）
+	AQL               float64          `json:"aql" gorm:"column:aql;type:decimal(10,3);not null"`
+	InspectionLevel   string           `json:"inspection_level" gorm:"size:10;default:'II'"` // S-1~S-4 特殊检验水平，I/II/III 一般检验水平
+	Severity          string           `json:"severity" gorm:"size:20;default:'normal'"`     // 当前严格度 normal:正常 tightened:加严 reduced:放宽
+	SwitchingScore    int              `json:"switching_score"`                              // 正常检验转放宽检验的转移得分
+	AllowReduced      bool             `json:"allow_reduced"`                                // 负责部门批准可转放宽检验
+	TightenedRejected int              `json:"tightened_rejected"`                           // 本次加严检验累计不接收批数
+	Discontinued      bool             `json:"discontinued"`                                 // 加严检验累计5批不接收，暂停检验
+	SwitchedAt        *time.Time       `json:"switched_at"`                                  // 最近一次转移严格度的时间
+	IsActive          bool             `json:"is_active" gorm:"default:true"`
+	Remark            string           `json:"remark" gorm:"size:500"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt   `json:"-" gorm:"index"`
+}
+
+// InspectionLot 检验批，样本以检测单记录，每张检测单为一个样本单位
+type InspectionLot struct {
+	ID                 uint            `json:"id" gorm:"primarykey"`
+	LotNo              string          `json:"lot_no" gorm:"uniqueIndex;size:50;not null"`
+	SamplingSchemeID   uint            `json:"sampling_scheme_id" gorm:"index;not null"`
+	SamplingScheme     SamplingScheme  `json:"sampling_scheme" gorm:"foreignKey:SamplingSchemeID"`
+	ProductionOrderID  uint            `json:"production_order_id" gorm:"index;not null"`
+	ProductionOrder    ProductionOrder `json:"production_order" gorm:"foreignKey:ProductionOrderID"`
+	LotSize            int             `json:"lot_size" gorm:"not null"`
+	Severity           string          `json:"severity" gorm:"size:20;not null"` // 建批时的检验严格度
+	CodeLetter         string          `json:"code_letter" gorm:"size:2"`        // 样本量字码
+	SampleSize         int             `json:"sample_size"`
+	AcceptNumber       int             `json:"accept_number"` // 接收数 Ac
+	RejectNumber       int             `json:"reject_number"` // 拒收数 Re
+	InspectedCount     int             `json:"inspected_count"`
+	NonconformingCount int             `json:"nonconforming_count"`                                // 不合格品数或不合格数，取决于方案的判定依据
+	Disposition        string          `json:"disposition" gorm:"size:20;default:'pending';index"` // pending:待判定 accepted:接收 rejected:不接收
+	SeverityAfter      string          `json:"severity_after" gorm:"size:20"`                      // 判定后方案的检验严格度
+	DecidedBy          *uint           `json:"decided_by"`
+	Decider            *User           `json:"decider,omitempty" gorm:"foreignKey:DecidedBy"`
+	DecidedAt          *time.Time      `json:"decided_at"`
+	Remark             string          `json:"remark" gorm:"size:500"`
+	CreatedBy          uint            `json:"created_by"`
+	Creator            User            `json:"creator" gorm:"foreignKey:CreatedBy"`
+	CreatedAt          time.Time       `json:"created_at"`
+	UpdatedAt          time.Time       `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt  `json:"-" gorm:"index"`
+}
+
+// TableName 指定表名
+func (SamplingScheme) TableName() string {
+	return "sampling_schemes"
+}
+
+func (InspectionLot) TableName() string {
+	return "inspection_lots"
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Shipment 成品发货单
+type Shipment struct {
+	ID           uint           `json:"id" gorm:"primarykey"`
+	ShipmentNo   string         `json:"shipment_no" gorm:"uniqueIndex;size:50;not null"`
+	CustomerID   uint           `json:"customer_id" gorm:"index;not null"`
+	Customer     Customer       `json:"customer" gorm:"foreignKey:CustomerID"`
+	SalesOrderID *uint          `json:"sales_order_id" gorm:"index"` // 关联的销售订单，为空时为无订单发货
+	ShipDate     time.Time      `json:"ship_date"`
+	Status       string         `json:"status" gorm:"size:20;default:'draft';not null"` // draft:草稿 shipped:已发货 cancelled:已取消
+	ShipTo       string         `json:"ship_to" gorm:"size:200"`                        // 收货地址，默认为客户地址
+	Carrier      string         `json:"carrier" gorm:"size:100"`                        // 承运商
+	TrackingNo   string         `json:"tracking_no" gorm:"size:100"`                    // 运单号
+	Remark       string         `json:"remark" gorm:"size:500"`
+	CreatedBy    uint           `json:"created_by"`
+	Creator      User           `json:"creator" gorm:"foreignKey:CreatedBy"`
+	ShippedBy    *uint          `json:"shipped_by"`
+	ShippedAt    *time.Time     `json:"shipped_at"`
+	Lines        []ShipmentLine `json:"lines" gorm:"foreignKey:ShipmentID"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// ShipmentLine 发货单行，每行从一个生产工单的已完工数量中发货
+type ShipmentLine struct {
+	ID                uint             `json:"id" gorm:"primarykey"`
+	ShipmentID        uint             `json:"shipment_id" gorm:"index;not null"`
+	LineNo            int              `json:"line_no" gorm:"not null"`
+	ProductID         uint             `json:"product_id" gorm:"index;not null"`
+	Product           Product          `json:"product" gorm:"foreignKey:ProductID"`
+	ProductionOrderID uint             `json:"production_order_id" gorm:"index;not null"`
+	ProductionOrder   ProductionOrder  `json:"production_order" gorm:"foreignKey:ProductionOrderID"`
+	SalesOrderLineID  *uint            `json:"sales_order_line_id" gorm:"index"` // 生产工单关联的销售订单行
+	Quantity          float64          `json:"quantity" gorm:"type:decimal(16,4);not null"`
+	LotNo             string           `json:"lot_no" gorm:"size:50;index"` // 发出的成品批次号
+	Serials           []ShipmentSerial `json:"serials" gorm:"foreignKey:ShipmentLineID"`
+	Remark            string           `json:"remark" gorm:"size:500"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt   `json:"-" gorm:"index"`
+}
+
+// ShipmentSerial 发货单行的产品序列号
+type ShipmentSerial struct {
+	ID             uint           `json:"id" gorm:"primarykey"`
+	ShipmentLineID uint           `json:"shipment_line_id" gorm:"index;not null"`
+	ProductID      uint           `json:"product_id" gorm:"index:idx_shipment_serial_product;not null"`
+	SerialNo       string         `json:"serial_no" gorm:"index:idx_shipment_serial_product;size:100;not null"`
+	CreatedAt      time.Time      `json:"created_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName 指定表名
+func (Shipment) TableName() string {
+	return "shipments"
+}
+
+func (ShipmentLine) TableName() string {
+	return "shipment_lines"
+}
+
+func (ShipmentSerial) TableName() string {
+	return "shipment_serials"
+}
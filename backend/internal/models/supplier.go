@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Supplier 供应商
+type Supplier struct {
+	ID        uint               `json:"id" gorm:"primarykey"`
+	Code      string             `json:"code" gorm:"uniqueIndex;size:50;not null"`
+	Name      string             `json:"name" gorm:"size:100;not null"`
+	Address   string             `json:"address" gorm:"size:200"`
+	Remark    string             `json:"remark" gorm:"size:500"`
+	Status    int                `json:"status" gorm:"default:1"` // 1:合格 0:停用
+	Contacts  []SupplierContact  `json:"contacts" gorm:"foreignKey:SupplierID"`
+	Materials []SupplierMaterial `json:"materials" gorm:"foreignKey:SupplierID"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	DeletedAt gorm.DeletedAt     `json:"-" gorm:"index"`
+}
+
+// SupplierContact 供应商联系人
+type SupplierContact struct {
+	ID         uint           `json:"id" gorm:"primarykey"`
+	SupplierID uint           `json:"supplier_id" gorm:"index;not null"`
+	Name       string         `json:"name" gorm:"size:50;not null"`
+	Title      string         `json:"title" gorm:"size:50"`
+	Phone      string         `json:"phone" gorm:"size:20"`
+	Email      string         `json:"email" gorm:"size:100"`
+	IsPrimary  bool           `json:"is_primary" gorm:"default:false"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// SupplierMaterial 供应商合格物料
+type SupplierMaterial struct {
+	ID                   uint      `json:"id" gorm:"primarykey"`
+	SupplierID           uint      `json:"supplier_id" gorm:"uniqueIndex:idx_supplier_material;not null"`
+	MaterialID           uint      `json:"material_id" gorm:"uniqueIndex:idx_supplier_material;not null"`
+	Material             Material  `json:"material" gorm:"foreignKey:MaterialID"`
+	SupplierMaterialCode string    `json:"supplier_material_code" gorm:"size:50"` // 供应商物料编码
+	Remark               string    `json:"remark" gorm:"size:500"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Supplier) TableName() string {
+	return "suppliers"
+}
+
+func (SupplierContact) TableName() string {
+	return "supplier_contacts"
+}
+
+func (SupplierMaterial) TableName() string {
+	return "supplier_materials"
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfMeasure 计量单位
+type UnitOfMeasure struct {
+	ID          uint           `json:"id" gorm:"primarykey"`
+	Code        string         `json:"code" gorm:"uniqueIndex;size:20;not null"`
+	Name        string         `json:"name" gorm:"size:50;not null"`
+	Dimension   string         `json:"dimension" gorm:"size:20;not null"` // 量纲：count:计数 mass:质量 length:长度 volume:体积 area:面积
+	Precision   int            `json:"precision" gorm:"default:4"`        // 数量保留的小数位数，最多4位
+	Description string         `json:"description" gorm:"size:500"`
+	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// UnitConversion 计量单位换算，1 个 FromUnit 等于 Factor 个 ToUnit
+type UnitConversion struct {
+	ID         uint           `json:"id" gorm:"primarykey"`
+	MaterialID *uint          `json:"material_id" gorm:"index"` // 物料专用换算（如 1箱=24个），为空时为通用换算
+	Material   *Material      `json:"material,omitempty" gorm:"foreignKey:MaterialID"`
+	FromUnit   string         `json:"from_unit" gorm:"size:20;not null"`
+	ToUnit     string         `json:"to_unit" gorm:"size:20;not null"`
+	Factor     float64        `json:"factor" gorm:"type:decimal(18,8);not null"`
+	Remark     string         `json:"remark" gorm:"size:500"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName 指定表名
+func (UnitOfMeasure) TableName() string {
+	return "units_of_measure"
+}
+
+func (UnitConversion) TableName() string {
+	return "unit_conversions"
+}
@@ -14,7 +14,8 @@ type User struct {
 	RealName  string         `json:"real_name" gorm:"size:50"`
 	Phone     string         `json:"phone" gorm:"size:20"`
 	Role      string         `json:"role" gorm:"size:20;default:'user'"`
-	Status    int            `json:"status" gorm:"default:1"` // 1:启用 0:禁用
+	BadgeNo   *string        `json:"badge_no" gorm:"uniqueIndex;size:50"` // 工牌号，手持终端扫描工牌识别用户
+	Status    int            `json:"status" gorm:"default:1"`             // 1:启用 0:禁用
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
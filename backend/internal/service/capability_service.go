@@ -0,0 +1,339 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"mes-system/internal/models"
+)
+
+// capabilityMinSamples 能力指数可信所需的最少样本量
+const capabilityMinSamples = 30
+
+// capabilityMinNormalitySamples 进行正态性检验所需的最少样本量
+const capabilityMinNormalitySamples = 8
+
+// capabilityNormalityAlpha 正态性检验显著性水平
+const capabilityNormalityAlpha = 0.05
+
+// CapabilityQuery 过程能力查询条件
+type CapabilityQuery struct {
+	ProductID         uint       // 产品ID
+	QualityStandardID uint       // 质量标准ID
+	Period            string     // 统计期间：空为整个时间窗口，day/week/month
+	SubgroupBy        string     // 组内标准差估计方式：空为移动极差，production_order/hour/day/week为子组标准差
+	StartDate         *time.Time // 开始时间（含）
+	EndDate           *time.Time // 结束时间（不含）
+}
+
+// CapabilityReport 过程能力报表行
+type CapabilityReport struct {
+	QualityStandardID   uint      `json:"quality_standard_id"`
+	QualityStandardName string    `json:"quality_standard_name"`
+	ProductID           uint      `json:"product_id"`
+	ProductCode         string    `json:"product_code"`
+	ProductName         string    `json:"product_name"`
+	Unit                string    `json:"unit"`
+	Period              string    `json:"period"`       // 期间标签，整个时间窗口时为“开始日期~结束日期”
+	PeriodStart         time.Time `json:"period_start"` // 期间开始（含）
+	PeriodEnd           time.Time `json:"period_end"`   // 期间结束（不含）
+	SampleSize          int       `json:"sample_size"`
+	SpecLower           *float64  `json:"spec_lower"` // 规格下限（质量标准最小值），仅上限规格时为空
+	SpecUpper           *float64  `json:"spec_upper"` // 规格上限（质量标准最大值），仅下限规格时为空
+	Target              float64   `json:"target"`
+	Mean                float64   `json:"mean"`
+	MinValue            float64   `json:"min_value"`
+	MaxValue            float64   `json:"max_value"`
+	SigmaWithin         float64   `json:"sigma_within"`  // 组内标准差，用于Cp/Cpk
+	SigmaOverall        float64   `json:"sigma_overall"` // 整体样本标准差，用于Pp/Ppk
+	Cp                  *float64  `json:"cp"`
+	Cpk                 *float64  `json:"cpk"`
+	Pp                  *float64  `json:"pp"`
+	Ppk                 *float64  `json:"ppk"`
+	ADStatistic         *float64  `json:"ad_statistic"`      // Anderson-Darling正态性检验统计量（经小样本修正）
+	NormalityPValue     *float64  `json:"normality_p_value"` // 正态性检验P值，小于0.05视为非正态
+	Warnings            []string  `json:"warnings,omitempty"`
+}
+
+// capabilityGroup 同一质量标准、同一期间的样本
+type capabilityGroup struct {
+	Standard    *models.QualityStandard
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Inspections []models.QualityInspection
+}
+
+// CapabilityService 过程能力服务
+type CapabilityService struct {
+	db *gorm.DB
+}
+
+// NewCapabilityService 创建过程能力服务实例
+func NewCapabilityService(db *gorm.DB) *CapabilityService {
+	return &CapabilityService{db: db}
+}
+
+// GetCapabilityReport 按质量标准、产品和期间计算Cp、Cpk、Pp、Ppk
+func (s *CapabilityService) GetCapabilityReport(query *CapabilityQuery) ([]CapabilityReport, error) {
+	switch query.Period {
+	case "", SpcSubgroupDay, SpcSubgroupWeek, spcBucketMonth:
+	default:
+		return nil, errors.New("无效的统计期间")
+	}
+	if query.SubgroupBy != "" && !isValidSpcSubgroupBy(query.SubgroupBy) {
+		return nil, errors.New("无效的子组划分方式")
+	}
+
+	start, end := spcWindow(query.StartDate, query.EndDate)
+	if !start.Before(end) {
+		return nil, errors.New("开始日期不能晚于结束日期")
+	}
+
+	db := s.db.Model(&models.QualityInspection{}).
+		Joins("JOIN quality_standards ON quality_standards.id = quality_inspections.quality_standard_id AND quality_standards.deleted_at IS NULL").
+		Where("quality_standards.characteristic_type = ?", "variable").
+		Where("quality_inspections.inspection_time >= ? AND quality_inspections.inspection_time < ?", start, end)
+	if query.ProductID > 0 {
+		db = db.Where("quality_standards.product_id = ?", query.ProductID)
+	}
+	if query.QualityStandardID > 0 {
+		db = db.Where("quality_inspections.quality_standard_id = ?", query.QualityStandardID)
+	}
+
+	var inspections []models.QualityInspection
+	if err := db.Preload("QualityStandard.Product").
+		Order("quality_inspections.inspection_time ASC, quality_inspections.id ASC").
+		Find(&inspections).Error; err != nil {
+		return nil, fmt.Errorf("获取检测记录失败: %v", err)
+	}
+
+	// 按质量标准和期间分组，保持检测时间顺序以便计算移动极差
+	var groups []*capabilityGroup
+	index := make(map[string]*capabilityGroup)
+	for i := range inspections {
+		inspection := &inspections[i]
+		periodStart, periodEnd := start, end
+		if query.Period != "" {
+			// 期间超出时间窗口时以时间窗口为界
+			bucket := spcBucketStart(inspection.InspectionTime, query.Period)
+			periodStart = maxDate(bucket, start)
+			periodEnd = capabilityPeriodEnd(bucket, query.Period)
+			if periodEnd.After(end) {
+				periodEnd = end
+			}
+		}
+		key := fmt.Sprintf("%d|%d", inspection.QualityStandardID, periodStart.Unix())
+		group, ok := index[key]
+		if !ok {
+			group = &capabilityGroup{
+				Standard:    &inspection.QualityStandard,
+				PeriodStart: periodStart,
+				PeriodEnd:   periodEnd,
+			}
+			index[key] = group
+			groups = append(groups, group)
+		}
+		group.Inspections = append(group.Inspections, *inspection)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		a, b := groups[i].Standard, groups[j].Standard
+		if a.Product.Code != b.Product.Code {
+			return a.Product.Code < b.Product.Code
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return groups[i].PeriodStart.Before(groups[j].PeriodStart)
+	})
+
+	reports := make([]CapabilityReport, 0, len(groups))
+	for _, group := range groups {
+		report := buildCapabilityReport(group, query.SubgroupBy)
+		if query.Period == "" {
+			report.Period = fmt.Sprintf("%s~%s", start.Format("2006-01-02"), end.Add(-time.Nanosecond).Format("2006-01-02"))
+		} else {
+			report.Period = spcBucketLabel(group.PeriodStart, query.Period)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// 辅助函数：计算一组样本的过程能力
+func buildCapabilityReport(group *capabilityGroup, subgroupBy string) CapabilityReport {
+	standard := group.Standard
+	report := CapabilityReport{
+		QualityStandardID:   standard.ID,
+		QualityStandardName: standard.Name,
+		ProductID:           standard.ProductID,
+		ProductCode:         standard.Product.Code,
+		ProductName:         standard.Product.Name,
+		Unit:                standard.Unit,
+		PeriodStart:         group.PeriodStart,
+		PeriodEnd:           group.PeriodEnd,
+		SampleSize:          len(group.Inspections),
+		Target:              standard.TargetValue,
+	}
+	report.SpecLower, report.SpecUpper = qualitySpecLimits(standard)
+
+	values := make([]float64, len(group.Inspections))
+	for i, inspection := range group.Inspections {
+		values[i] = inspection.ActualValue
+	}
+	mean, sdOverall, _ := spcDescribe(values)
+	report.Mean = spcRound(mean)
+	report.MinValue = values[0]
+	report.MaxValue = values[0]
+	for _, v := range values {
+		report.MinValue = math.Min(report.MinValue, v)
+		report.MaxValue = math.Max(report.MaxValue, v)
+	}
+	report.SigmaOverall = spcRound(sdOverall)
+
+	if report.SampleSize < 2 {
+		report.Warnings = append(report.Warnings, "样本量不足2个，无法计算能力指数")
+		return report
+	}
+	if report.SampleSize < capabilityMinSamples {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("样本量少于%d个，能力指数的置信度较低", capabilityMinSamples))
+	}
+
+	sigmaWithin, ok := capabilitySigmaWithin(group.Inspections, subgroupBy)
+	if !ok {
+		report.Warnings = append(report.Warnings, "没有包含两个及以上测量值的子组，无法估计组内标准差")
+	}
+	report.SigmaWithin = spcRound(sigmaWithin)
+
+	lsl, usl := report.SpecLower, report.SpecUpper
+	if lsl != nil && usl != nil && *usl <= *lsl {
+		report.Warnings = append(report.Warnings, "质量标准的最大值不大于最小值，无法计算能力指数")
+	} else {
+		report.Cp, report.Cpk = capabilityIndices(mean, sigmaWithin, lsl, usl)
+		report.Pp, report.Ppk = capabilityIndices(mean, sdOverall, lsl, usl)
+		if sdOverall == 0 {
+			report.Warnings = append(report.Warnings, "测量值没有波动，无法计算能力指数")
+		}
+		if lsl == nil || usl == nil {
+			report.Warnings = append(report.Warnings, "单侧规格只计算Cpk和Ppk")
+		}
+	}
+
+	if report.SampleSize < capabilityMinNormalitySamples {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("样本量少于%d个，未进行正态性检验", capabilityMinNormalitySamples))
+	} else if sdOverall > 0 {
+		statistic, pValue := andersonDarlingNormality(values, mean, sdOverall)
+		statistic, pValue = spcRound(statistic), spcRound(pValue)
+		report.ADStatistic = &statistic
+		report.NormalityPValue = &pValue
+		if pValue < capabilityNormalityAlpha {
+			report.Warnings = append(report.Warnings, "数据不服从正态分布（Anderson-Darling检验P值小于0.05），能力指数可能失真")
+		}
+	}
+
+	return report
+}
+
+// 辅助函数：估计组内标准差，未指定子组时按移动极差估计，否则取各子组标准差修正后的平均
+func capabilitySigmaWithin(inspections []models.QualityInspection, subgroupBy string) (float64, bool) {
+	if subgroupBy == "" {
+		var mrSum float64
+		for i := 1; i < len(inspections); i++ {
+			mrSum += math.Abs(inspections[i].ActualValue - inspections[i-1].ActualValue)
+		}
+		return mrSum / float64(len(inspections)-1) / spcD2[2], true
+	}
+
+	var sigmaSum float64
+	var count int
+	for _, subgroup := range groupSpcInspections(inspections, subgroupBy) {
+		n := len(subgroup.Values)
+		if n < 2 {
+			continue
+		}
+		_, sd, _ := spcDescribe(subgroup.Values)
+		sigmaSum += sd / spcC4(n)
+		count++
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return sigmaSum / float64(count), true
+}
+
+// 辅助函数：按标准差计算能力指数（Cp/Cpk或Pp/Ppk），标准差为0时不计算，单侧规格不计算Cp/Pp
+func capabilityIndices(mean, sigma float64, lsl, usl *float64) (*float64, *float64) {
+	if sigma <= 0 {
+		return nil, nil
+	}
+
+	actual := math.Inf(1)
+	if lsl != nil {
+		actual = (mean - *lsl) / (3 * sigma)
+	}
+	if usl != nil {
+		actual = math.Min(actual, (*usl-mean)/(3*sigma))
+	}
+	actual = spcRound(actual)
+	if lsl == nil || usl == nil {
+		return nil, &actual
+	}
+
+	potential := spcRound((*usl - *lsl) / (6 * sigma))
+	return &potential, &actual
+}
+
+// 辅助函数：Anderson-Darling正态性检验，返回经小样本修正的统计量和近似P值（D'Agostino & Stephens）
+func andersonDarlingNormality(values []float64, mean, sd float64) (float64, float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	var sum float64
+	for i := 0; i < n; i++ {
+		low := capabilityNormalCDF((sorted[i] - mean) / sd)
+		high := capabilityNormalCDF((sorted[n-1-i] - mean) / sd)
+		sum += float64(2*i+1) * (math.Log(low) + math.Log(1-high))
+	}
+	a2 := -float64(n) - sum/float64(n)
+	a2 *= 1 + 0.75/float64(n) + 2.25/float64(n*n)
+	return a2, andersonDarlingPValue(a2)
+}
+
+// 辅助函数：按经小样本修正的Anderson-Darling统计量计算近似P值
+func andersonDarlingPValue(a2 float64) float64 {
+	var p float64
+	switch {
+	case a2 >= 0.6:
+		p = math.Exp(1.2937 - 5.709*a2 + 0.0186*a2*a2)
+	case a2 >= 0.34:
+		p = math.Exp(0.9177 - 4.279*a2 - 1.38*a2*a2)
+	case a2 >= 0.2:
+		p = 1 - math.Exp(-8.318+42.796*a2-59.938*a2*a2)
+	default:
+		p = 1 - math.Exp(-13.436+101.14*a2-223.73*a2*a2)
+	}
+	return math.Max(0, math.Min(1, p))
+}
+
+// 辅助函数：标准正态分布函数，结果限制在(0,1)内避免取对数溢出
+func capabilityNormalCDF(z float64) float64 {
+	p := 0.5 * math.Erfc(-z/math.Sqrt2)
+	return math.Max(1e-15, math.Min(1-1e-15, p))
+}
+
+// 辅助函数：获取统计期间的结束时间（不含）
+func capabilityPeriodEnd(start time.Time, period string) time.Time {
+	switch period {
+	case SpcSubgroupWeek:
+		return start.AddDate(0, 0, 7)
+	case spcBucketMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"mes-system/internal/models"
+)
+
+// 期望值取自 Montgomery《统计质量控制导论》过程能力分析例题：活塞环内径 Cp≈1.68，瓶子抗爆强度单侧下限 Cpl≈0.67
+func TestCapabilityIndices(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name     string
+		mean     float64
+		sigma    float64
+		lsl, usl *float64
+		cp, cpk  *float64
+	}{
+		{"双侧规格", 74.001, 0.0099, float(73.95), float(74.05), float(1.68), float(1.65)},
+		{"单侧下限规格", 264.06, 32.02, float(200), nil, nil, float(0.67)},
+		{"单侧上限规格", 10.2, 0.2, nil, float(11), nil, float(1.33)},
+		{"均值偏离中心", 10.2, 0.2, float(9), float(11), float(1.67), float(1.33)},
+		{"均值超出规格", 11.3, 0.1, float(9), float(11), float(3.33), float(-1)},
+		{"标准差为0不计算", 10, 0, float(9), float(11), nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp, cpk := capabilityIndices(tt.mean, tt.sigma, tt.lsl, tt.usl)
+			assertIndex(t, "Cp", cp, tt.cp)
+			assertIndex(t, "Cpk", cpk, tt.cpk)
+		})
+	}
+}
+
+// 修正统计量 A*=A²(1+0.75/n+2.25/n²) 的临界值取自 D'Agostino & Stephens《Goodness-of-Fit Techniques》表4.7，近似公式与表值的相对误差在10%以内
+func TestAndersonDarlingPValue(t *testing.T) {
+	tests := []struct {
+		statistic float64
+		pValue    float64
+	}{
+		{0.631, 0.10},
+		{0.754, 0.05},
+		{0.884, 0.025},
+		{1.047, 0.01},
+		{1.159, 0.005},
+		{0.34, 0.50},
+	}
+
+	for _, tt := range tests {
+		if p := andersonDarlingPValue(tt.statistic); math.Abs(p-tt.pValue) > tt.pValue*0.1 {
+			t.Errorf("A*=%.3f P值 = %.4f，期望 %.4f", tt.statistic, p, tt.pValue)
+		}
+	}
+}
+
+func TestAndersonDarlingNormality(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		normal bool
+	}{
+		// 标准正态分布 (i-0.5)/n 分位点，应判为正态
+		{"正态分位点", []float64{-1.645, -1.036, -0.674, -0.385, -0.126, 0.126, 0.385, 0.674, 1.036, 1.645}, true},
+		{"严重右偏", []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 20}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mean, sd, _ := spcDescribe(tt.values)
+			_, p := andersonDarlingNormality(tt.values, mean, sd)
+			if normal := p >= capabilityNormalityAlpha; normal != tt.normal {
+				t.Errorf("P值 = %.4f，期望正态 = %v", p, tt.normal)
+			}
+		})
+	}
+}
+
+func TestCapabilitySigmaWithin(t *testing.T) {
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local)
+	values := []float64{10, 12, 11, 13, 12, 14}
+	inspections := make([]models.QualityInspection, len(values))
+	for i, v := range values {
+		// 每天3个测量值
+		inspections[i] = models.QualityInspection{ActualValue: v, InspectionTime: start.AddDate(0, 0, i/3)}
+	}
+
+	// 移动极差 2,1,2,1,2，平均1.6，除以d2=1.128
+	sigma, ok := capabilitySigmaWithin(inspections, "")
+	if !ok || math.Abs(sigma-1.6/1.128) > 1e-9 {
+		t.Errorf("移动极差估计 = %v，期望 %v", sigma, 1.6/1.128)
+	}
+
+	// 两个子组的标准差都为1，除以n=3时的c4=0.8862
+	sigma, ok = capabilitySigmaWithin(inspections, SpcSubgroupDay)
+	if !ok || math.Abs(sigma-1/0.8862) > 1e-3 {
+		t.Errorf("子组标准差估计 = %v，期望 %v", sigma, 1/0.8862)
+	}
+
+	if _, ok = capabilitySigmaWithin(inspections[:1], SpcSubgroupDay); ok {
+		t.Error("只有单值子组时期望无法估计组内标准差")
+	}
+}
+
+func TestBuildCapabilityReport(t *testing.T) {
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local)
+	inspectionsOf := func(values ...float64) []models.QualityInspection {
+		inspections := make([]models.QualityInspection, len(values))
+		for i, v := range values {
+			inspections[i] = models.QualityInspection{ActualValue: v, InspectionTime: start.Add(time.Duration(i) * time.Hour)}
+		}
+		return inspections
+	}
+
+	tests := []struct {
+		name      string
+		standard  models.QualityStandard
+		values    []float64
+		hasCp     bool
+		hasCpk    bool
+		normality bool
+		warnings  int
+	}{
+		{
+			name:      "双侧规格",
+			standard:  models.QualityStandard{MinValue: 9, MaxValue: 11, LimitType: "two_sided"},
+			values:    []float64{10, 10.2, 9.9, 10.1, 9.8, 10.3, 10, 9.9},
+			hasCp:     true,
+			hasCpk:    true,
+			normality: true,
+			warnings:  1, // 样本量少于30
+		},
+		{
+			name:      "单侧规格",
+			standard:  models.QualityStandard{MaxValue: 11, LimitType: "upper"},
+			values:    []float64{10, 10.2, 9.9, 10.1, 9.8, 10.3, 10, 9.9},
+			hasCpk:    true,
+			normality: true,
+			warnings:  2, // 样本量少于30，单侧规格
+		},
+		{
+			name:     "测量值没有波动",
+			standard: models.QualityStandard{MinValue: 9, MaxValue: 11, LimitType: "two_sided"},
+			values:   []float64{10, 10, 10},
+			warnings: 3, // 样本量少于30，没有波动，未进行正态性检验
+		},
+		{
+			name:     "规格限倒置",
+			standard: models.QualityStandard{MinValue: 11, MaxValue: 9, LimitType: "two_sided"},
+			values:   []float64{10, 10.2, 9.9},
+			warnings: 3, // 样本量少于30，规格限倒置，未进行正态性检验
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := buildCapabilityReport(&capabilityGroup{Standard: &tt.standard, Inspections: inspectionsOf(tt.values...)}, "")
+			if (report.Cp != nil) != tt.hasCp || (report.Cpk != nil) != tt.hasCpk {
+				t.Errorf("Cp=%v Cpk=%v，期望计算Cp=%v Cpk=%v", report.Cp, report.Cpk, tt.hasCp, tt.hasCpk)
+			}
+			if (report.NormalityPValue != nil) != tt.normality {
+				t.Errorf("正态性检验 = %v，期望 %v", report.NormalityPValue != nil, tt.normality)
+			}
+			if len(report.Warnings) != tt.warnings {
+				t.Errorf("提示 = %v，期望 %d 条", report.Warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+// 辅助函数：按两位小数比较能力指数
+func assertIndex(t *testing.T, name string, got, want *float64) {
+	t.Helper()
+	if got == nil || want == nil {
+		if got != want {
+			t.Errorf("%s = %v，期望 %v", name, got, want)
+		}
+		return
+	}
+	if math.Abs(*got-*want) > 0.005 {
+		t.Errorf("%s = %.4f，期望 %.2f", name, *got, *want)
+	}
+}
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"gorm.io/gorm"
+	"mes-system/internal/models"
+)
+
+// 物料计价方法
+const (
+	CostingMethodMovingAverage = "moving_average" // 移动加权平均
+	CostingMethodFIFO          = "fifo"           // 先进先出
+)
+
+// MaterialValuationResponse 物料库存金额响应结构体
+type MaterialValuationResponse struct {
+	MaterialID    uint    `json:"material_id"`
+	MaterialCode  string  `json:"material_code"`
+	MaterialName  string  `json:"material_name"`
+	MaterialType  string  `json:"material_type"`
+	Unit          string  `json:"unit"`
+	CostingMethod string  `json:"costing_method"`
+	Quantity      float64 `json:"quantity"`
+	UnitCost      float64 `json:"unit_cost"`
+	Value         float64 `json:"value"`
+}
+
+// MaterialTypeValuation 按物料类型汇总的库存金额
+type MaterialTypeValuation struct {
+	MaterialType  string  `json:"material_type"`
+	MaterialCount int     `json:"material_count"`
+	Quantity      float64 `json:"quantity"`
+	Value         float64 `json:"value"`
+}
+
+// InventoryValuationResponse 库存估值响应结构体
+type InventoryValuationResponse struct {
+	TotalValue float64                     `json:"total_value"`
+	ByType     []MaterialTypeValuation     `json:"by_type"`
+	Materials  []MaterialValuationResponse `json:"materials"`
+}
+
+// CostingService 物料成本核算服务
+type CostingService struct {
+	db *gorm.DB
+}
+
+// NewCostingService 创建物料成本核算服务实例
+func NewCostingService(db *gorm.DB) *CostingService {
+	return &CostingService{db: db}
+}
+
+// GetInventoryValuation 获取库存估值，按物料类型汇总
+func (s *CostingService) GetInventoryValuation(materialType string) (*InventoryValuationResponse, error) {
+	var materials []models.Material
+
+	query := s.db.Model(&models.Material{})
+	if materialType != "" {
+		query = query.Where("type = ?", materialType)
+	}
+
+	if err := query.Order("type, code").Find(&materials).Error; err != nil {
+		return nil, fmt.Errorf("获取物料失败: %v", err)
+	}
+
+	result := &InventoryValuationResponse{
+		ByType:    []MaterialTypeValuation{},
+		Materials: make([]MaterialValuationResponse, 0, len(materials)),
+	}
+	byType := make(map[string]*MaterialTypeValuation)
+
+	for _, material := range materials {
+		value := materialStockValue(&material)
+		result.Materials = append(result.Materials, MaterialValuationResponse{
+			MaterialID:    material.ID,
+			MaterialCode:  material.Code,
+			MaterialName:  material.Name,
+			MaterialType:  material.Type,
+			Unit:          material.Unit,
+			CostingMethod: material.CostingMethod,
+			Quantity:      material.CurrentStock,
+			UnitCost:      materialUnitCost(&material),
+			Value:         value,
+		})
+
+		summary, exists := byType[material.Type]
+		if !exists {
+			summary = &MaterialTypeValuation{MaterialType: material.Type}
+			byType[material.Type] = summary
+		}
+		summary.MaterialCount++
+		summary.Quantity = roundQuantity(summary.Quantity + material.CurrentStock)
+		summary.Value = roundAmount(summary.Value + value)
+
+		result.TotalValue = roundAmount(result.TotalValue + value)
+	}
+
+	for _, summary := range byType {
+		result.ByType = append(result.ByType, *summary)
+	}
+	sort.Slice(result.ByType, func(i, j int) bool {
+		return result.ByType[i].MaterialType < result.ByType[j].MaterialType
+	})
+
+	return result, nil
+}
+
+// GetCostLayers 获取物料的剩余成本层
+func (s *CostingService) GetCostLayers(materialID uint) ([]models.MaterialCostLayer, error) {
+	var material models.Material
+	if err := s.db.First(&material, materialID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("物料不存在")
+		}
+		return nil, fmt.Errorf("获取物料失败: %v", err)
+	}
+
+	var layers []models.MaterialCostLayer
+	if err := s.db.Where("material_id = ? AND remaining_quantity > 0", materialID).
+		Order("received_at, id").Find(&layers).Error; err != nil {
+		return nil, fmt.Errorf("获取成本层失败: %v", err)
+	}
+
+	return layers, nil
+}
+
+// isValidCostingMethod 验证计价方法是否有效
+func isValidCostingMethod(method string) bool {
+	return method == CostingMethodMovingAverage || method == CostingMethodFIFO
+}
+
+// applyInboundCost 入库后更新物料的库存金额和单位成本，先进先出计价时生成新的成本层
+// 调用方负责在此之后更新物料的库存数量
+func applyInboundCost(tx *gorm.DB, material *models.Material, transaction *models.MaterialTransaction) error {
+	initOpeningValuation(material)
+
+	if material.CostingMethod == CostingMethodFIFO && transaction.Quantity > 0 {
+		if err := syncOpeningLayer(tx, material); err != nil {
+			return err
+		}
+
+		layer := &models.MaterialCostLayer{
+			MaterialID:        material.ID,
+			TransactionID:     &transaction.ID,
+			Quantity:          transaction.Quantity,
+			RemainingQuantity: transaction.Quantity,
+			UnitCost:          transaction.Price,
+			ReceivedAt:        transaction.CreatedAt,
+		}
+		if err := tx.Create(layer).Error; err != nil {
+			return fmt.Errorf("创建成本层失败: %v", err)
+		}
+	}
+
+	material.StockValue = roundAmount(material.StockValue + transaction.TotalAmount)
+	material.AverageCost = averageCost(material.StockValue, material.CurrentStock+transaction.Quantity)
+
+	return nil
+}
+
+// applyOutboundCost 按物料计价方法计算出库交易的单位成本和金额，并扣减物料的库存金额
+// 调用方负责在此之后更新物料的库存数量
+func applyOutboundCost(tx *gorm.DB, material *models.Material, transaction *models.MaterialTransaction) error {
+	initOpeningValuation(material)
+
+	var cost float64
+	if material.CostingMethod == CostingMethodFIFO {
+		if err := syncOpeningLayer(tx, material); err != nil {
+			return err
+		}
+
+		var err error
+		cost, err = consumeCostLayers(tx, material.ID, transaction.Quantity)
+		if err != nil {
+			return err
+		}
+	} else {
+		cost = roundAmount(material.AverageCost * transaction.Quantity)
+	}
+
+	remaining := roundQuantity(material.CurrentStock - transaction.Quantity)
+	if remaining <= 0 {
+		// 库存清零时，将尾差全部计入本次出库
+		cost = material.StockValue
+		material.StockValue = 0
+	} else {
+		material.StockValue = roundAmount(material.StockValue - cost)
+	}
+
+	transaction.TotalAmount = cost
+	transaction.Price = roundUnitCost(cost / transaction.Quantity)
+	if material.CostingMethod == CostingMethodFIFO {
+		material.AverageCost = averageCost(material.StockValue, remaining)
+	}
+
+	return nil
+}
+
+// changeCostingMethod 切换物料计价方法，按当前库存金额重建成本层
+func changeCostingMethod(tx *gorm.DB, material *models.Material, method string) error {
+	if material.CostingMethod == method {
+		return nil
+	}
+
+	initOpeningValuation(material)
+
+	// 作废现有的剩余成本层
+	if err := tx.Model(&models.MaterialCostLayer{}).
+		Where("material_id = ? AND remaining_quantity > 0", material.ID).
+		Update("remaining_quantity", 0).Error; err != nil {
+		return fmt.Errorf("重置成本层失败: %v", err)
+	}
+
+	material.CostingMethod = method
+	material.AverageCost = averageCost(material.StockValue, material.CurrentStock)
+
+	if method == CostingMethodFIFO {
+		return syncOpeningLayer(tx, material)
+	}
+
+	return nil
+}
+
+// initOpeningValuation 为尚未建立库存金额的物料按标准单价初始化期初金额
+func initOpeningValuation(material *models.Material) {
+	if material.CostingMethod == "" {
+		material.CostingMethod = CostingMethodMovingAverage
+	}
+
+	if material.StockValue == 0 && material.CurrentStock > 0 {
+		material.StockValue = roundAmount(material.CurrentStock * material.Price)
+		material.AverageCost = material.Price
+	}
+}
+
+// syncOpeningLayer 将未被成本层覆盖的库存补建为期初成本层
+func syncOpeningLayer(tx *gorm.DB, material *models.Material) error {
+	var layered struct {
+		Quantity float64
+		Value    float64
+	}
+	if err := tx.Model(&models.MaterialCostLayer{}).
+		Select("COALESCE(SUM(remaining_quantity), 0) AS quantity, COALESCE(SUM(remaining_quantity * unit_cost), 0) AS value").
+		Where("material_id = ? AND remaining_quantity > 0", material.ID).
+		Scan(&layered).Error; err != nil {
+		return fmt.Errorf("汇总成本层失败: %v", err)
+	}
+
+	uncovered := roundQuantity(material.CurrentStock - layered.Quantity)
+	if uncovered <= 0 {
+		return nil
+	}
+
+	unitCost := roundUnitCost((material.StockValue - layered.Value) / uncovered)
+	if unitCost < 0 {
+		unitCost = material.AverageCost
+	}
+
+	layer := &models.MaterialCostLayer{
+		MaterialID:        material.ID,
+		Quantity:          uncovered,
+		RemainingQuantity: uncovered,
+		UnitCost:          unitCost,
+		ReceivedAt:        material.CreatedAt, // 期初成本层排在最前，优先被消耗
+	}
+	if err := tx.Create(layer).Error; err != nil {
+		return fmt.Errorf("创建期初成本层失败: %v", err)
+	}
+
+	return nil
+}
+
+// consumeCostLayers 按先进先出顺序消耗成本层，返回消耗的总成本
+func consumeCostLayers(tx *gorm.DB, materialID uint, quantity float64) (float64, error) {
+	var layers []models.MaterialCostLayer
+	if err := tx.Where("material_id = ? AND remaining_quantity > 0", materialID).
+		Order("received_at, id").Find(&layers).Error; err != nil {
+		return 0, fmt.Errorf("获取成本层失败: %v", err)
+	}
+
+	var cost float64
+	remaining := quantity
+	for i := range layers {
+		if remaining <= 0 {
+			break
+		}
+
+		consumed := layers[i].RemainingQuantity
+		if consumed > remaining {
+			consumed = remaining
+		}
+
+		cost += consumed * layers[i].UnitCost
+		remaining = roundQuantity(remaining - consumed)
+
+		if err := tx.Model(&layers[i]).Update("remaining_quantity", roundQuantity(layers[i].RemainingQuantity-consumed)).Error; err != nil {
+			return 0, fmt.Errorf("更新成本层失败: %v", err)
+		}
+	}
+
+	if remaining > 0 {
+		return 0, errors.New("成本层数量不足，请检查物料库存")
+	}
+
+	return roundAmount(cost), nil
+}
+
+// materialStockValue 获取物料的当前库存金额，未初始化时按标准单价估算
+func materialStockValue(material *models.Material) float64 {
+	if material.StockValue == 0 && material.CurrentStock > 0 {
+		return roundAmount(material.CurrentStock * material.Price)
+	}
+	return material.StockValue
+}
+
+// materialUnitCost 获取物料的当前单位成本，未初始化时使用标准单价
+func materialUnitCost(material *models.Material) float64 {
+	if material.StockValue == 0 && material.CurrentStock > 0 {
+		return material.Price
+	}
+	return material.AverageCost
+}
+
+// averageCost 计算平均单位成本
+func averageCost(value, quantity float64) float64 {
+	if quantity <= 0 {
+		return 0
+	}
+	return roundUnitCost(value / quantity)
+}
+
+// roundAmount 金额保留两位小数
+func roundAmount(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// roundUnitCost 单位成本保留四位小数
+func roundUnitCost(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
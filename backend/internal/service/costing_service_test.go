@@ -0,0 +1,81 @@
+package service
+
+import (
+	"testing"
+
+	"mes-system/internal/models"
+)
+
+// 移动加权平均法：每次入库后按（结存金额+本次入库金额）/（结存数量+本次入库数量）重算单位成本，出库按当前单位成本计价，
+// 期望值按企业会计准则存货计价的移动加权平均法逐笔手工计算
+func TestMovingAverageCosting(t *testing.T) {
+	material := &models.Material{CurrentStock: 300, Price: 50}
+
+	steps := []struct {
+		name        string
+		typ         string
+		quantity    float64
+		price       float64
+		amount      float64 // 出库成本，入库时不校验
+		unitCost    float64 // 出库单价，入库时不校验
+		stockValue  float64
+		averageCost float64
+	}{
+		{"期初按标准单价300件×50元，购入900件×60元", "in", 900, 60, 0, 0, 69000, 57.5},
+		{"发出400件", "out", 400, 0, 23000, 57.5, 46000, 57.5},
+		{"购入600件×70元", "in", 600, 70, 0, 0, 88000, 62.8571},
+		{"发出800件", "out", 800, 0, 50285.68, 62.8571, 37714.32, 62.8571},
+		{"发出剩余600件，尾差计入本次出库", "out", 600, 0, 37714.32, 62.8572, 0, 62.8571},
+		{"库存清零后购入100件×65元", "in", 100, 65, 0, 0, 6500, 65},
+	}
+
+	for _, step := range steps {
+		transaction := &models.MaterialTransaction{Type: step.typ, Quantity: step.quantity, Price: step.price}
+		if step.typ == "in" {
+			transaction.TotalAmount = roundAmount(step.quantity * step.price)
+			// 移动加权平均法不使用成本层，无需数据库
+			if err := applyInboundCost(nil, material, transaction); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			material.CurrentStock = roundQuantity(material.CurrentStock + step.quantity)
+		} else {
+			if err := applyOutboundCost(nil, material, transaction); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			material.CurrentStock = roundQuantity(material.CurrentStock - step.quantity)
+			if transaction.TotalAmount != step.amount || transaction.Price != step.unitCost {
+				t.Errorf("%s: 出库成本=%v 单价=%v，期望 %v %v", step.name, transaction.TotalAmount, transaction.Price, step.amount, step.unitCost)
+			}
+		}
+
+		if material.StockValue != step.stockValue || material.AverageCost != step.averageCost {
+			t.Errorf("%s: 结存金额=%v 单位成本=%v，期望 %v %v", step.name, material.StockValue, material.AverageCost, step.stockValue, step.averageCost)
+		}
+	}
+
+	if material.CostingMethod != CostingMethodMovingAverage {
+		t.Errorf("计价方法 = %s，期望默认移动加权平均", material.CostingMethod)
+	}
+}
+
+func TestAverageCost(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    float64
+		quantity float64
+		want     float64
+	}{
+		{"保留四位小数", 88000, 1400, 62.8571},
+		{"整除", 69000, 1200, 57.5},
+		{"数量为0", 100, 0, 0},
+		{"数量为负", 100, -5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := averageCost(tt.value, tt.quantity); got != tt.want {
+				t.Errorf("单位成本 = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"mes-system/internal/models"
+)
+
+// CustomerRequest 客户请求结构体
+type CustomerRequest struct {
+	Code        string `json:"code" binding:"required"` // 客户编码
+	Name        string `json:"name" binding:"required"` // 客户名称
+	ContactName string `json:"contact_name"`            // 联系人
+	Phone       string `json:"phone"`                   // 电话
+	Email       string `json:"email"`                   // 邮箱
+	Address     string `json:"address"`                 // 地址
+	Status      *int   `json:"status"`                  // 状态：1启用 0停用，默认为1
+	Remark      string `json:"remark"`                  // 备注
+}
+
+// CustomerService 客户服务
+type CustomerService struct {
+	db *gorm.DB
+}
+
+// NewCustomerService 创建客户服务实例
+func NewCustomerService(db *gorm.DB) *CustomerService {
+	return &CustomerService{db: db}
+}
+
+// CreateCustomer 创建客户
+func (s *CustomerService) CreateCustomer(req *CustomerRequest) (*models.Customer, error) {
+	if s.isCustomerCodeExists(req.Code, 0) {
+		return nil, errors.New("客户编码已存在")
+	}
+
+	status := 1
+	if req.Status != nil {
+		status = *req.Status
+	}
+
+	customer := &models.Customer{
+		Code:        req.Code,
+		Name:        req.Name,
+		ContactName: req.ContactName,
+		Phone:       req.Phone,
+		Email:       req.Email,
+		Address:     req.Address,
+		Status:      status,
+		Remark:      req.Remark,
+	}
+
+	if err := s.db.Create(customer).Error; err != nil {
+		return nil, fmt.Errorf("创建客户失败: %v", err)
+	}
+
+	return customer, nil
+}
+
+// GetCustomer 获取客户详情
+func (s *CustomerService) GetCustomer(id uint) (*models.Customer, error) {
+	var customer models.Customer
+	if err := s.db.First(&customer, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("客户不存在")
+		}
+		return nil, fmt.Errorf("获取客户失败: %v", err)
+	}
+
+	return &customer, nil
+}
+
+// GetCustomerList 获取客户列表
+func (s *CustomerService) GetCustomerList(page, pageSize int, keyword string, status *int) ([]models.Customer, int64, error) {
+	var customers []models.Customer
+	var total int64
+
+	query := s.db.Model(&models.Customer{})
+
+	// 关键词搜索
+	if keyword != "" {
+		query = query.Where("code LIKE ? OR name LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+
+	// 按状态筛选
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取客户总数失败: %v", err)
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Offset(offset).Limit(pageSize).Order("code").Find(&customers).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取客户列表失败: %v", err)
+	}
+
+	return customers, total, nil
+}
+
+// UpdateCustomer 更新客户
+func (s *CustomerService) UpdateCustomer(id uint, req *CustomerRequest) (*models.Customer, error) {
+	customer, err := s.GetCustomer(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.isCustomerCodeExists(req.Code, id) {
+		return nil, errors.New("客户编码已存在")
+	}
+
+	customer.Code = req.Code
+	customer.Name = req.Name
+	customer.ContactName = req.ContactName
+	customer.Phone = req.Phone
+	customer.Email = req.Email
+	customer.Address = req.Address
+	customer.Remark = req.Remark
+	if req.Status != nil {
+		customer.Status = *req.Status
+	}
+
+	if err := s.db.Save(customer).Error; err != nil {
+		return nil, fmt.Errorf("更新客户失败: %v", err)
+	}
+
+	return customer, nil
+}
+
+// DeleteCustomer 删除客户，存在未结销售订单时不能删除
+func (s *CustomerService) DeleteCustomer(id uint) error {
+	customer, err := s.GetCustomer(id)
+	if err != nil {
+		return err
+	}
+
+	var openOrders int64
+	s.db.Model(&models.SalesOrder{}).
+		Where("customer_id = ? AND status IN ?", id, []string{"draft", "confirmed"}).
+		Count(&openOrders)
+	if openOrders > 0 {
+		return errors.New("客户存在未结销售订单，不能删除")
+	}
+
+	if err := s.db.Delete(customer).Error; err != nil {
+		return fmt.Errorf("删除客户失败: %v", err)
+	}
+	return nil
+}
+
+// 辅助函数：检查客户编码是否存在
+func (s *CustomerService) isCustomerCodeExists(code string, excludeID uint) bool {
+	var count int64
+	query := s.db.Model(&models.Customer{}).Where("code = ?", code)
+	if excludeID > 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+	query.Count(&count)
+	return count > 0
+}
@@ -0,0 +1,640 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"mes-system/internal/models"
+)
+
+// 缺陷排列图的分组方式
+const (
+	DefectParetoByCode      = "code"      // 按缺陷代码
+	DefectParetoByProduct   = "product"   // 按产品
+	DefectParetoByEquipment = "equipment" // 按生产设备
+	DefectParetoByPeriod    = "period"    // 按时间段
+)
+
+// DefectCodeRequest 缺陷代码请求结构体
+type DefectCodeRequest struct {
+	Code        string `json:"code" binding:"required"` // 缺陷代码
+	Name        string `json:"name" binding:"required"` // 缺陷名称
+	ParentID    *uint  `json:"parent_id"`               // 上级缺陷代码ID，为空时为顶级分类
+	Severity    string `json:"severity"`                // 严重度：critical/major/minor，默认minor
+	Description string `json:"description"`             // 描述
+	IsActive    bool   `json:"is_active"`               // 是否启用
+}
+
+// DefectCodeResponse 缺陷代码响应结构体
+type DefectCodeResponse struct {
+	ID          uint                 `json:"id"`
+	Code        string               `json:"code"`
+	Name        string               `json:"name"`
+	ParentID    *uint                `json:"parent_id"`
+	ParentCode  string               `json:"parent_code"`
+	ParentName  string               `json:"parent_name"`
+	Level       int                  `json:"level"`
+	Severity    string               `json:"severity"`
+	Description string               `json:"description"`
+	IsActive    bool                 `json:"is_active"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	Children    []DefectCodeResponse `json:"children,omitempty"`
+}
+
+// DefectParetoQuery 缺陷排列图查询条件
+type DefectParetoQuery struct {
+	GroupBy      string     // 分组方式：code/product/equipment/period，默认code
+	Period       string     // 按时间段分组时的粒度：day/week/month，默认week
+	Level        int        // 按缺陷代码分组时汇总到的层级，0为不汇总
+	ProductID    uint       // 产品ID
+	EquipmentID  uint       // 生产设备ID
+	DefectCodeID uint       // 缺陷代码ID，包含其下级代码
+	StartDate    *time.Time // 开始时间，默认结束时间前30天
+	EndDate      *time.Time // 结束时间（不含），默认当天结束
+}
+
+// DefectParetoItem 缺陷排列图条目
+type DefectParetoItem struct {
+	ID                   uint    `json:"id"` // 缺陷代码、产品或设备ID，未指定设备或按时间段分组时为0
+	Code                 string  `json:"code"`
+	Name                 string  `json:"name"`
+	Quantity             int     `json:"quantity"`              // 缺陷数量
+	InspectionCount      int     `json:"inspection_count"`      // 涉及的检测记录数
+	Percentage           float64 `json:"percentage"`            // 占比（%）
+	CumulativePercentage float64 `json:"cumulative_percentage"` // 累计占比（%）
+}
+
+// DefectParetoResponse 缺陷排列图响应结构体
+type DefectParetoResponse struct {
+	GroupBy       string             `json:"group_by"`
+	Period        string             `json:"period,omitempty"`
+	Level         int                `json:"level,omitempty"`
+	StartDate     time.Time          `json:"start_date"`
+	EndDate       time.Time          `json:"end_date"`
+	TotalQuantity int                `json:"total_quantity"`
+	Items         []DefectParetoItem `json:"items"`
+}
+
+// defectParetoRow 参与排列图统计的缺陷明细
+type defectParetoRow struct {
+	QualityInspectionID uint
+	DefectCodeID        uint
+	Quantity            int
+	ProductID           uint
+	EquipmentID         *uint
+	InspectionTime      time.Time
+}
+
+// validDefectSeverities 支持的缺陷严重度
+var validDefectSeverities = []string{"critical", "major", "minor"}
+
+// DefectCodeService 缺陷代码服务
+type DefectCodeService struct {
+	db *gorm.DB
+}
+
+// NewDefectCodeService 创建缺陷代码服务实例
+func NewDefectCodeService(db *gorm.DB) *DefectCodeService {
+	return &DefectCodeService{db: db}
+}
+
+// CreateDefectCode 创建缺陷代码
+func (s *DefectCodeService) CreateDefectCode(req *DefectCodeRequest) (*DefectCodeResponse, error) {
+	if err := normalizeDefectSeverity(req); err != nil {
+		return nil, err
+	}
+
+	if s.isDefectCodeExists(req.Code, 0) {
+		return nil, errors.New("缺陷代码已存在")
+	}
+
+	level := 1
+	if req.ParentID != nil {
+		parent, err := s.getParentDefectCode(*req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		level = parent.Level + 1
+	}
+
+	defectCode := &models.DefectCode{
+		Code:        req.Code,
+		Name:        req.Name,
+		ParentID:    req.ParentID,
+		Level:       level,
+		Severity:    req.Severity,
+		Description: req.Description,
+		IsActive:    req.IsActive,
+	}
+
+	if err := s.db.Create(defectCode).Error; err != nil {
+		return nil, fmt.Errorf("创建缺陷代码失败: %v", err)
+	}
+
+	return s.GetDefectCode(defectCode.ID)
+}
+
+// GetDefectCode 获取缺陷代码详情
+func (s *DefectCodeService) GetDefectCode(id uint) (*DefectCodeResponse, error) {
+	var defectCode models.DefectCode
+	if err := s.db.Preload("Parent").First(&defectCode, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("缺陷代码不存在")
+		}
+		return nil, fmt.Errorf("获取缺陷代码失败: %v", err)
+	}
+
+	return defectCodeToResponse(&defectCode), nil
+}
+
+// GetDefectCodeList 获取缺陷代码列表
+func (s *DefectCodeService) GetDefectCodeList(page, pageSize int, keyword string, parentID uint, severity string, isActive *bool) ([]DefectCodeResponse, int64, error) {
+	query := s.db.Model(&models.DefectCode{})
+
+	// 按代码或名称搜索
+	if keyword != "" {
+		query = query.Where("code LIKE ? OR name LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+
+	// 按上级代码筛选
+	if parentID > 0 {
+		query = query.Where("parent_id = ?", parentID)
+	}
+
+	// 按严重度筛选
+	if severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+
+	// 按状态筛选
+	if isActive != nil {
+		query = query.Where("is_active = ?", *isActive)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取缺陷代码总数失败: %v", err)
+	}
+
+	var defectCodes []models.DefectCode
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Parent").Order("level, code").Offset(offset).Limit(pageSize).Find(&defectCodes).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取缺陷代码列表失败: %v", err)
+	}
+
+	responses := make([]DefectCodeResponse, 0, len(defectCodes))
+	for i := range defectCodes {
+		responses = append(responses, *defectCodeToResponse(&defectCodes[i]))
+	}
+	return responses, total, nil
+}
+
+// GetDefectCodeTree 获取缺陷代码目录树
+func (s *DefectCodeService) GetDefectCodeTree(isActive *bool) ([]DefectCodeResponse, error) {
+	query := s.db.Model(&models.DefectCode{})
+	if isActive != nil {
+		query = query.Where("is_active = ?", *isActive)
+	}
+
+	var defectCodes []models.DefectCode
+	if err := query.Order("level, code").Find(&defectCodes).Error; err != nil {
+		return nil, fmt.Errorf("获取缺陷代码目录失败: %v", err)
+	}
+
+	byID := make(map[uint]*models.DefectCode, len(defectCodes))
+	children := make(map[uint][]*models.DefectCode)
+	var roots []*models.DefectCode
+	for i := range defectCodes {
+		byID[defectCodes[i].ID] = &defectCodes[i]
+	}
+	for i := range defectCodes {
+		code := &defectCodes[i]
+		// 上级被停用筛掉时作为顶级节点展示
+		if code.ParentID != nil && byID[*code.ParentID] != nil {
+			children[*code.ParentID] = append(children[*code.ParentID], code)
+			continue
+		}
+		roots = append(roots, code)
+	}
+
+	var build func(code *models.DefectCode) DefectCodeResponse
+	build = func(code *models.DefectCode) DefectCodeResponse {
+		if code.ParentID != nil {
+			code.Parent = byID[*code.ParentID]
+		}
+		resp := *defectCodeToResponse(code)
+		for _, child := range children[code.ID] {
+			resp.Children = append(resp.Children, build(child))
+		}
+		return resp
+	}
+
+	tree := make([]DefectCodeResponse, 0, len(roots))
+	for _, root := range roots {
+		tree = append(tree, build(root))
+	}
+	return tree, nil
+}
+
+// UpdateDefectCode 更新缺陷代码，调整上级时同步更新下级代码的层级
+func (s *DefectCodeService) UpdateDefectCode(id uint, req *DefectCodeRequest) (*DefectCodeResponse, error) {
+	var defectCode models.DefectCode
+	if err := s.db.First(&defectCode, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("缺陷代码不存在")
+		}
+		return nil, fmt.Errorf("获取缺陷代码失败: %v", err)
+	}
+
+	if err := normalizeDefectSeverity(req); err != nil {
+		return nil, err
+	}
+
+	if s.isDefectCodeExists(req.Code, id) {
+		return nil, errors.New("缺陷代码已存在")
+	}
+
+	level := 1
+	if req.ParentID != nil {
+		if *req.ParentID == id {
+			return nil, errors.New("上级缺陷代码不能是自身")
+		}
+		parent, err := s.getParentDefectCode(*req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		descendants, err := s.descendantDefectCodeIDs(id)
+		if err != nil {
+			return nil, err
+		}
+		if descendants[parent.ID] {
+			return nil, errors.New("上级缺陷代码不能是自身的下级代码")
+		}
+		level = parent.Level + 1
+	}
+
+	levelChanged := level != defectCode.Level
+	defectCode.Code = req.Code
+	defectCode.Name = req.Name
+	defectCode.ParentID = req.ParentID
+	defectCode.Level = level
+	defectCode.Severity = req.Severity
+	defectCode.Description = req.Description
+	defectCode.IsActive = req.IsActive
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Parent").Save(&defectCode).Error; err != nil {
+			return fmt.Errorf("更新缺陷代码失败: %v", err)
+		}
+		if levelChanged {
+			return updateDefectCodeChildLevels(tx, defectCode.ID, defectCode.Level)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetDefectCode(id)
+}
+
+// DeleteDefectCode 删除没有下级代码且未被检测记录引用的缺陷代码
+func (s *DefectCodeService) DeleteDefectCode(id uint) error {
+	var defectCode models.DefectCode
+	if err := s.db.First(&defectCode, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("缺陷代码不存在")
+		}
+		return fmt.Errorf("获取缺陷代码失败: %v", err)
+	}
+
+	var count int64
+	if err := s.db.Model(&models.DefectCode{}).Where("parent_id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("检查下级缺陷代码失败: %v", err)
+	}
+	if count > 0 {
+		return errors.New("该缺陷代码存在下级代码，无法删除")
+	}
+
+	if err := s.db.Model(&models.QualityInspectionDefect{}).Where("defect_code_id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("检查缺陷记录失败: %v", err)
+	}
+	if count > 0 {
+		return errors.New("该缺陷代码已被检测记录引用，无法删除，可停用")
+	}
+
+	if err := s.db.Delete(&defectCode).Error; err != nil {
+		return fmt.Errorf("删除缺陷代码失败: %v", err)
+	}
+	return nil
+}
+
+// GetDefectPareto 按缺陷代码、产品、生产设备或时间段统计缺陷数量排列图
+func (s *DefectCodeService) GetDefectPareto(query *DefectParetoQuery) (*DefectParetoResponse, error) {
+	if query.GroupBy == "" {
+		query.GroupBy = DefectParetoByCode
+	}
+	switch query.GroupBy {
+	case DefectParetoByCode, DefectParetoByProduct, DefectParetoByEquipment:
+	case DefectParetoByPeriod:
+		if query.Period == "" {
+			query.Period = SpcSubgroupWeek
+		}
+		if query.Period != SpcSubgroupDay && query.Period != SpcSubgroupWeek && query.Period != spcBucketMonth {
+			return nil, errors.New("无效的统计期间")
+		}
+	default:
+		return nil, errors.New("无效的分组方式")
+	}
+	if query.Level < 0 {
+		return nil, errors.New("无效的汇总层级")
+	}
+
+	start, end := spcWindow(query.StartDate, query.EndDate)
+	if !start.Before(end) {
+		return nil, errors.New("开始日期不能晚于结束日期")
+	}
+
+	// 包含已删除的缺陷代码，保证历史缺陷仍可汇总到上级
+	var defectCodes []models.DefectCode
+	if err := s.db.Unscoped().Find(&defectCodes).Error; err != nil {
+		return nil, fmt.Errorf("获取缺陷代码失败: %v", err)
+	}
+	codes := make(map[uint]*models.DefectCode, len(defectCodes))
+	for i := range defectCodes {
+		codes[defectCodes[i].ID] = &defectCodes[i]
+	}
+
+	db := s.db.Table("quality_inspection_defects").
+		Select("quality_inspection_defects.quality_inspection_id, quality_inspection_defects.defect_code_id, quality_inspection_defects.quantity, "+
+			"production_orders.product_id, quality_inspections.equipment_id, quality_inspections.inspection_time").
+		Joins("JOIN quality_inspections ON quality_inspections.id = quality_inspection_defects.quality_inspection_id AND quality_inspections.deleted_at IS NULL").
+		Joins("JOIN production_orders ON production_orders.id = quality_inspections.production_order_id").
+		Where("quality_inspections.inspection_time >= ? AND quality_inspections.inspection_time < ?", start, end)
+	if query.ProductID > 0 {
+		db = db.Where("production_orders.product_id = ?", query.ProductID)
+	}
+	if query.EquipmentID > 0 {
+		db = db.Where("quality_inspections.equipment_id = ?", query.EquipmentID)
+	}
+	if query.DefectCodeID > 0 {
+		if codes[query.DefectCodeID] == nil {
+			return nil, errors.New("缺陷代码不存在")
+		}
+		db = db.Where("quality_inspection_defects.defect_code_id IN ?", defectCodeSubtree(codes, query.DefectCodeID))
+	}
+
+	var rows []defectParetoRow
+	if err := db.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("获取缺陷记录失败: %v", err)
+	}
+
+	items, err := s.groupDefectPareto(rows, codes, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DefectParetoResponse{
+		GroupBy:   query.GroupBy,
+		Level:     query.Level,
+		StartDate: start,
+		EndDate:   end,
+		Items:     items,
+	}
+	if query.GroupBy == DefectParetoByPeriod {
+		result.Period = query.Period
+	}
+	for _, item := range items {
+		result.TotalQuantity += item.Quantity
+	}
+
+	// 按数量从大到小排列并计算累计占比
+	sort.SliceStable(result.Items, func(i, j int) bool {
+		if result.Items[i].Quantity != result.Items[j].Quantity {
+			return result.Items[i].Quantity > result.Items[j].Quantity
+		}
+		return result.Items[i].Code < result.Items[j].Code
+	})
+	cumulative := 0
+	for i := range result.Items {
+		cumulative += result.Items[i].Quantity
+		if result.TotalQuantity > 0 {
+			result.Items[i].Percentage = roundToPrecision(float64(result.Items[i].Quantity)/float64(result.TotalQuantity)*100, 2)
+			result.Items[i].CumulativePercentage = roundToPrecision(float64(cumulative)/float64(result.TotalQuantity)*100, 2)
+		}
+	}
+
+	return result, nil
+}
+
+// 辅助函数：按分组方式汇总缺陷明细
+func (s *DefectCodeService) groupDefectPareto(rows []defectParetoRow, codes map[uint]*models.DefectCode, query *DefectParetoQuery) ([]DefectParetoItem, error) {
+	groups := make(map[string]*DefectParetoItem)
+	inspections := make(map[string]map[uint]bool)
+	var order []string
+	var productIDs, equipmentIDs []uint
+
+	for _, row := range rows {
+		var key string
+		item := DefectParetoItem{}
+		switch query.GroupBy {
+		case DefectParetoByCode:
+			code := rollUpDefectCode(codes, row.DefectCodeID, query.Level)
+			key = fmt.Sprintf("%d", code.ID)
+			item.ID, item.Code, item.Name = code.ID, code.Code, code.Name
+		case DefectParetoByProduct:
+			key = fmt.Sprintf("%d", row.ProductID)
+			item.ID = row.ProductID
+		case DefectParetoByEquipment:
+			if row.EquipmentID != nil {
+				item.ID = *row.EquipmentID
+			}
+			key = fmt.Sprintf("%d", item.ID)
+		case DefectParetoByPeriod:
+			bucket := spcBucketStart(row.InspectionTime, query.Period)
+			key = spcBucketLabel(bucket, query.Period)
+			item.Code, item.Name = key, key
+		}
+
+		group, exists := groups[key]
+		if !exists {
+			group = &item
+			groups[key] = group
+			inspections[key] = make(map[uint]bool)
+			order = append(order, key)
+			switch query.GroupBy {
+			case DefectParetoByProduct:
+				productIDs = append(productIDs, item.ID)
+			case DefectParetoByEquipment:
+				if item.ID > 0 {
+					equipmentIDs = append(equipmentIDs, item.ID)
+				}
+			}
+		}
+		group.Quantity += row.Quantity
+		inspections[key][row.QualityInspectionID] = true
+	}
+
+	// 补充产品和设备的编码名称
+	if len(productIDs) > 0 {
+		var products []models.Product
+		if err := s.db.Unscoped().Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+			return nil, fmt.Errorf("获取产品失败: %v", err)
+		}
+		for _, product := range products {
+			if group := groups[fmt.Sprintf("%d", product.ID)]; group != nil {
+				group.Code, group.Name = product.Code, product.Name
+			}
+		}
+	}
+	if query.GroupBy == DefectParetoByEquipment {
+		if len(equipmentIDs) > 0 {
+			var equipments []models.Equipment
+			if err := s.db.Unscoped().Where("id IN ?", equipmentIDs).Find(&equipments).Error; err != nil {
+				return nil, fmt.Errorf("获取生产设备失败: %v", err)
+			}
+			for _, equipment := range equipments {
+				if group := groups[fmt.Sprintf("%d", equipment.ID)]; group != nil {
+					group.Code, group.Name = equipment.Code, equipment.Name
+				}
+			}
+		}
+		if group := groups["0"]; group != nil {
+			group.Name = "未指定设备"
+		}
+	}
+
+	items := make([]DefectParetoItem, 0, len(order))
+	for _, key := range order {
+		groups[key].InspectionCount = len(inspections[key])
+		items = append(items, *groups[key])
+	}
+	return items, nil
+}
+
+// 辅助函数：将缺陷代码向上汇总到指定层级，层级为0或代码本身不深于该层级时不汇总
+func rollUpDefectCode(codes map[uint]*models.DefectCode, id uint, level int) *models.DefectCode {
+	code := codes[id]
+	if code == nil {
+		return &models.DefectCode{ID: id}
+	}
+	for level > 0 && code.Level > level && code.ParentID != nil && codes[*code.ParentID] != nil {
+		code = codes[*code.ParentID]
+	}
+	return code
+}
+
+// 辅助函数：获取缺陷代码及其全部下级代码的ID
+func defectCodeSubtree(codes map[uint]*models.DefectCode, rootID uint) []uint {
+	children := make(map[uint][]uint)
+	for _, code := range codes {
+		if code.ParentID != nil {
+			children[*code.ParentID] = append(children[*code.ParentID], code.ID)
+		}
+	}
+
+	ids := []uint{rootID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
+
+// 辅助函数：获取缺陷代码的全部下级代码ID集合
+func (s *DefectCodeService) descendantDefectCodeIDs(id uint) (map[uint]bool, error) {
+	var defectCodes []models.DefectCode
+	if err := s.db.Select("id", "parent_id").Find(&defectCodes).Error; err != nil {
+		return nil, fmt.Errorf("获取缺陷代码失败: %v", err)
+	}
+	codes := make(map[uint]*models.DefectCode, len(defectCodes))
+	for i := range defectCodes {
+		codes[defectCodes[i].ID] = &defectCodes[i]
+	}
+
+	descendants := make(map[uint]bool)
+	for _, childID := range defectCodeSubtree(codes, id)[1:] {
+		descendants[childID] = true
+	}
+	return descendants, nil
+}
+
+// 辅助函数：逐级更新下级缺陷代码的层级
+func updateDefectCodeChildLevels(tx *gorm.DB, parentID uint, parentLevel int) error {
+	var childIDs []uint
+	if err := tx.Model(&models.DefectCode{}).Where("parent_id = ?", parentID).Pluck("id", &childIDs).Error; err != nil {
+		return fmt.Errorf("获取下级缺陷代码失败: %v", err)
+	}
+	if len(childIDs) == 0 {
+		return nil
+	}
+	if err := tx.Model(&models.DefectCode{}).Where("id IN ?", childIDs).Update("level", parentLevel+1).Error; err != nil {
+		return fmt.Errorf("更新下级缺陷代码层级失败: %v", err)
+	}
+	for _, childID := range childIDs {
+		if err := updateDefectCodeChildLevels(tx, childID, parentLevel+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 辅助函数：获取并验证上级缺陷代码
+func (s *DefectCodeService) getParentDefectCode(parentID uint) (*models.DefectCode, error) {
+	var parent models.DefectCode
+	if err := s.db.First(&parent, parentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("上级缺陷代码不存在")
+		}
+		return nil, fmt.Errorf("验证上级缺陷代码失败: %v", err)
+	}
+	return &parent, nil
+}
+
+// 辅助函数：规范化并验证缺陷严重度
+func normalizeDefectSeverity(req *DefectCodeRequest) error {
+	if req.Severity == "" {
+		req.Severity = "minor"
+	}
+	for _, severity := range validDefectSeverities {
+		if req.Severity == severity {
+			return nil
+		}
+	}
+	return errors.New("严重度必须是 critical、major 或 minor")
+}
+
+// 辅助函数：检查缺陷代码是否已存在
+func (s *DefectCodeService) isDefectCodeExists(code string, excludeID uint) bool {
+	var count int64
+	query := s.db.Model(&models.DefectCode{}).Where("code = ?", code)
+	if excludeID > 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+	query.Count(&count)
+	return count > 0
+}
+
+// 辅助函数：转换为响应结构体
+func defectCodeToResponse(code *models.DefectCode) *DefectCodeResponse {
+	resp := &DefectCodeResponse{
+		ID:          code.ID,
+		Code:        code.Code,
+		Name:        code.Name,
+		ParentID:    code.ParentID,
+		Level:       code.Level,
+		Severity:    code.Severity,
+		Description: code.Description,
+		IsActive:    code.IsActive,
+		CreatedAt:   code.CreatedAt,
+		UpdatedAt:   code.UpdatedAt,
+	}
+	if code.Parent != nil {
+		resp.ParentCode = code.Parent.Code
+		resp.ParentName = code.Parent.Name
+	}
+	return resp
+}
@@ -0,0 +1,661 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mes-system/internal/models"
+)
+
+// ReasonCodeSupplierRejection 来料检验不合格退供应商的原因代码
+const ReasonCodeSupplierRejection = "supplier_rejection"
+
+// MaterialQualityStandardRequest 物料质量标准请求结构体
+type MaterialQualityStandardRequest struct {
+	MaterialID  uint    `json:"material_id" binding:"required"` // 物料ID
+	Name        string  `json:"name" binding:"required"`        // 标准名称
+	Type        string  `json:"type" binding:"required"`        // 检测类型
+	MinValue    float64 `json:"min_value"`                      // 最小值
+	MaxValue    float64 `json:"max_value"`                      // 最大值
+	TargetValue float64 `json:"target_value"`                   // 目标值
+	Unit        string  `json:"unit"`                           // 单位
+	Description string  `json:"description"`                    // 描述
+	IsActive    bool    `json:"is_active"`                      // 是否启用
+}
+
+// MaterialQualityStandardResponse 物料质量标准响应结构体
+type MaterialQualityStandardResponse struct {
+	ID           uint      `json:"id"`
+	MaterialID   uint      `json:"material_id"`
+	MaterialCode string    `json:"material_code"`
+	MaterialName string    `json:"material_name"`
+	Name         string    `json:"name"`
+	Type         string    `json:"type"`
+	MinValue     float64   `json:"min_value"`
+	MaxValue     float64   `json:"max_value"`
+	TargetValue  float64   `json:"target_value"`
+	Unit         string    `json:"unit"`
+	Description  string    `json:"description"`
+	IsActive     bool      `json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// IncomingInspectionRequest 创建来料检验单请求结构体
+type IncomingInspectionRequest struct {
+	TransactionID uint   `json:"transaction_id" binding:"required"` // 入库交易ID
+	Remark        string `json:"remark"`                            // 备注
+}
+
+// IncomingInspectionItemRequest 来料检验项目结果请求结构体
+type IncomingInspectionItemRequest struct {
+	MaterialQualityStandardID uint    `json:"material_quality_standard_id" binding:"required"` // 物料质量标准ID
+	ActualValue               float64 `json:"actual_value"`                                    // 实测值
+	Remark                    string  `json:"remark"`                                          // 备注
+}
+
+// IncomingInspectionResultRequest 录入来料检验结果请求结构体
+type IncomingInspectionResultRequest struct {
+	Items  []IncomingInspectionItemRequest `json:"items" binding:"dive"` // 检验项目结果，物料有启用的质量标准时必须全部录入
+	Result string                          `json:"result"`               // 检验结论：pass/fail，仅在物料没有质量标准时使用
+	Remark string                          `json:"remark"`               // 备注
+}
+
+// IncomingInspectionItemResponse 来料检验项目结果响应结构体
+type IncomingInspectionItemResponse struct {
+	ID                        uint    `json:"id"`
+	MaterialQualityStandardID uint    `json:"material_quality_standard_id"`
+	StandardName              string  `json:"standard_name"`
+	MinValue                  float64 `json:"min_value"`
+	MaxValue                  float64 `json:"max_value"`
+	TargetValue               float64 `json:"target_value"`
+	Unit                      string  `json:"unit"`
+	ActualValue               float64 `json:"actual_value"`
+	Result                    string  `json:"result"`
+	Remark                    string  `json:"remark"`
+}
+
+// IncomingInspectionResponse 来料检验单响应结构体
+type IncomingInspectionResponse struct {
+	ID                  uint                             `json:"id"`
+	InspectionNo        string                           `json:"inspection_no"`
+	TransactionID       uint                             `json:"transaction_id"`
+	MaterialID          uint                             `json:"material_id"`
+	MaterialCode        string                           `json:"material_code"`
+	MaterialName        string                           `json:"material_name"`
+	SupplierID          *uint                            `json:"supplier_id"`
+	Supplier            string                           `json:"supplier"`
+	Quantity            float64                          `json:"quantity"`
+	Status              string                           `json:"status"`
+	InspectorID         *uint                            `json:"inspector_id"`
+	InspectorName       string                           `json:"inspector_name"`
+	InspectedAt         *time.Time                       `json:"inspected_at"`
+	RejectTransactionID *uint                            `json:"reject_transaction_id"`
+	Remark              string                           `json:"remark"`
+	Items               []IncomingInspectionItemResponse `json:"items,omitempty"`
+	ReceivedAt          time.Time                        `json:"received_at"`
+	CreatedAt           time.Time                        `json:"created_at"`
+}
+
+// IncomingInspectionService 来料检验服务
+type IncomingInspectionService struct {
+	db *gorm.DB
+}
+
+// NewIncomingInspectionService 创建来料检验服务实例
+func NewIncomingInspectionService(db *gorm.DB) *IncomingInspectionService {
+	return &IncomingInspectionService{db: db}
+}
+
+// CreateMaterialStandard 创建物料质量标准
+func (s *IncomingInspectionService) CreateMaterialStandard(req *MaterialQualityStandardRequest) (*MaterialQualityStandardResponse, error) {
+	var material models.Material
+	if err := s.db.First(&material, req.MaterialID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("物料不存在")
+		}
+		return nil, fmt.Errorf("验证物料失败: %v", err)
+	}
+
+	if err := validateStandardRange(req.MinValue, req.MaxValue, req.TargetValue); err != nil {
+		return nil, err
+	}
+
+	if s.isMaterialStandardNameExists(req.MaterialID, req.Name, 0) {
+		return nil, errors.New("该物料下已存在相同名称的质量标准")
+	}
+
+	standard := &models.MaterialQualityStandard{
+		MaterialID:  req.MaterialID,
+		Name:        req.Name,
+		Type:        req.Type,
+		MinValue:    req.MinValue,
+		MaxValue:    req.MaxValue,
+		TargetValue: req.TargetValue,
+		Unit:        req.Unit,
+		Description: req.Description,
+		IsActive:    req.IsActive,
+	}
+
+	if err := s.db.Create(standard).Error; err != nil {
+		return nil, fmt.Errorf("创建物料质量标准失败: %v", err)
+	}
+
+	return s.standardToResponse(standard, &material), nil
+}
+
+// GetMaterialStandard 获取物料质量标准详情
+func (s *IncomingInspectionService) GetMaterialStandard(id uint) (*MaterialQualityStandardResponse, error) {
+	var standard models.MaterialQualityStandard
+	if err := s.db.Preload("Material").First(&standard, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("物料质量标准不存在")
+		}
+		return nil, fmt.Errorf("获取物料质量标准失败: %v", err)
+	}
+
+	return s.standardToResponse(&standard, &standard.Material), nil
+}
+
+// GetMaterialStandardList 获取物料质量标准列表
+func (s *IncomingInspectionService) GetMaterialStandardList(page, pageSize int, materialID uint, isActive *bool) ([]MaterialQualityStandardResponse, int64, error) {
+	var standards []models.MaterialQualityStandard
+	var total int64
+
+	query := s.db.Model(&models.MaterialQualityStandard{})
+
+	// 按物料筛选
+	if materialID > 0 {
+		query = query.Where("material_id = ?", materialID)
+	}
+
+	// 按状态筛选
+	if isActive != nil {
+		query = query.Where("is_active = ?", *isActive)
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取物料质量标准总数失败: %v", err)
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Material").Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&standards).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取物料质量标准列表失败: %v", err)
+	}
+
+	var responses []MaterialQualityStandardResponse
+	for _, standard := range standards {
+		responses = append(responses, *s.standardToResponse(&standard, &standard.Material))
+	}
+
+	return responses, total, nil
+}
+
+// UpdateMaterialStandard 更新物料质量标准
+func (s *IncomingInspectionService) UpdateMaterialStandard(id uint, req *MaterialQualityStandardRequest) (*MaterialQualityStandardResponse, error) {
+	var standard models.MaterialQualityStandard
+	if err := s.db.First(&standard, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("物料质量标准不存在")
+		}
+		return nil, fmt.Errorf("获取物料质量标准失败: %v", err)
+	}
+
+	var material models.Material
+	if err := s.db.First(&material, req.MaterialID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("物料不存在")
+		}
+		return nil, fmt.Errorf("验证物料失败: %v", err)
+	}
+
+	if err := validateStandardRange(req.MinValue, req.MaxValue, req.TargetValue); err != nil {
+		return nil, err
+	}
+
+	if s.isMaterialStandardNameExists(req.MaterialID, req.Name, id) {
+		return nil, errors.New("该物料下已存在相同名称的质量标准")
+	}
+
+	standard.MaterialID = req.MaterialID
+	standard.Name = req.Name
+	standard.Type = req.Type
+	standard.MinValue = req.MinValue
+	standard.MaxValue = req.MaxValue
+	standard.TargetValue = req.TargetValue
+	standard.Unit = req.Unit
+	standard.Description = req.Description
+	standard.IsActive = req.IsActive
+
+	if err := s.db.Save(&standard).Error; err != nil {
+		return nil, fmt.Errorf("更新物料质量标准失败: %v", err)
+	}
+
+	return s.standardToResponse(&standard, &material), nil
+}
+
+// DeleteMaterialStandard 删除物料质量标准
+func (s *IncomingInspectionService) DeleteMaterialStandard(id uint) error {
+	var standard models.MaterialQualityStandard
+	if err := s.db.First(&standard, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("物料质量标准不存在")
+		}
+		return fmt.Errorf("获取物料质量标准失败: %v", err)
+	}
+
+	// 检查是否有相关的检验记录
+	var count int64
+	if err := s.db.Model(&models.IncomingInspectionItem{}).Where("material_quality_standard_id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("检查来料检验记录失败: %v", err)
+	}
+
+	if count > 0 {
+		return errors.New("该物料质量标准存在检验记录，无法删除")
+	}
+
+	if err := s.db.Delete(&standard).Error; err != nil {
+		return fmt.Errorf("删除物料质量标准失败: %v", err)
+	}
+
+	return nil
+}
+
+// CreateInspection 针对入库交易创建来料检验单，收货数量转入隔离库存
+func (s *IncomingInspectionService) CreateInspection(req *IncomingInspectionRequest) (*IncomingInspectionResponse, error) {
+	var transaction models.MaterialTransaction
+	if err := s.db.First(&transaction, req.TransactionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("入库交易不存在")
+		}
+		return nil, fmt.Errorf("获取入库交易失败: %v", err)
+	}
+
+	if transaction.Type != "in" || transaction.Quantity <= 0 {
+		return nil, errors.New("只能对有合格入库数量的收货交易创建来料检验单")
+	}
+
+	var inspection *models.IncomingInspection
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var material models.Material
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&material, transaction.MaterialID).Error; err != nil {
+			return fmt.Errorf("获取物料失败: %v", err)
+		}
+
+		// 收货后已发出的数量无法再隔离
+		if issuableStock(&material) < transaction.Quantity {
+			return errors.New("可用库存不足，收货数量已部分发出，无法隔离待检")
+		}
+
+		created, err := createIncomingInspection(tx, &transaction, &material)
+		if err != nil {
+			return err
+		}
+		if req.Remark != "" {
+			created.Remark = req.Remark
+			if err := tx.Model(created).Update("remark", req.Remark).Error; err != nil {
+				return fmt.Errorf("更新来料检验单失败: %v", err)
+			}
+		}
+		inspection = created
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetInspection(inspection.ID)
+}
+
+// GetInspection 获取来料检验单详情
+func (s *IncomingInspectionService) GetInspection(id uint) (*IncomingInspectionResponse, error) {
+	var inspection models.IncomingInspection
+	err := s.db.Preload("Transaction").Preload("Material").Preload("Inspector").
+		Preload("Items.MaterialQualityStandard").
+		First(&inspection, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("来料检验单不存在")
+		}
+		return nil, fmt.Errorf("获取来料检验单失败: %v", err)
+	}
+
+	return s.inspectionToResponse(&inspection, true), nil
+}
+
+// GetInspectionList 获取来料检验单列表
+func (s *IncomingInspectionService) GetInspectionList(page, pageSize int, status string, materialID, supplierID uint) ([]IncomingInspectionResponse, int64, error) {
+	var inspections []models.IncomingInspection
+	var total int64
+
+	query := s.db.Model(&models.IncomingInspection{})
+
+	// 按状态筛选
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 按物料筛选
+	if materialID > 0 {
+		query = query.Where("material_id = ?", materialID)
+	}
+
+	// 按供应商筛选
+	if supplierID > 0 {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取来料检验单总数失败: %v", err)
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Transaction").Preload("Material").Preload("Inspector").
+		Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&inspections).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取来料检验单列表失败: %v", err)
+	}
+
+	var responses []IncomingInspectionResponse
+	for _, inspection := range inspections {
+		responses = append(responses, *s.inspectionToResponse(&inspection, false))
+	}
+
+	return responses, total, nil
+}
+
+// RecordResult 录入来料检验结果：合格时解除隔离，不合格时解除隔离并将整批退回供应商
+func (s *IncomingInspectionService) RecordResult(id uint, req *IncomingInspectionResultRequest, inspectorID uint) (*IncomingInspectionResponse, error) {
+	var inspection models.IncomingInspection
+	if err := s.db.Preload("Transaction").First(&inspection, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("来料检验单不存在")
+		}
+		return nil, fmt.Errorf("获取来料检验单失败: %v", err)
+	}
+
+	if inspection.Status != "pending" {
+		return nil, errors.New("该来料检验单已完成检验")
+	}
+
+	items, result, err := s.evaluateItems(inspection.MaterialID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定检验单后重新校验状态，防止并发录入重复解除隔离
+		var locked models.IncomingInspection
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, inspection.ID).Error; err != nil {
+			return fmt.Errorf("获取来料检验单失败: %v", err)
+		}
+		if locked.Status != "pending" {
+			return errors.New("该来料检验单已完成检验")
+		}
+
+		// 合格时解除隔离，不合格时由退供应商交易从隔离库存出库
+		if result != "fail" {
+			var material models.Material
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&material, inspection.MaterialID).Error; err != nil {
+				return fmt.Errorf("获取物料失败: %v", err)
+			}
+			material.QuarantineStock = roundQuantity(material.QuarantineStock - inspection.Quantity)
+			if material.QuarantineStock < 0 {
+				return fmt.Errorf("物料 %s 的隔离库存不足以解除本次检验数量", material.Code)
+			}
+			if err := tx.Model(&material).Update("quarantine_stock", material.QuarantineStock).Error; err != nil {
+				return fmt.Errorf("解除隔离库存失败: %v", err)
+			}
+		}
+
+		for i := range items {
+			items[i].InspectionID = inspection.ID
+		}
+		if len(items) > 0 {
+			if err := tx.Create(&items).Error; err != nil {
+				return fmt.Errorf("保存检验项目结果失败: %v", err)
+			}
+		}
+
+		updates := map[string]interface{}{
+			"status":       "passed",
+			"inspector_id": inspectorID,
+			"inspected_at": now,
+		}
+		if req.Remark != "" {
+			updates["remark"] = req.Remark
+		}
+
+		// 不合格时整批退回供应商
+		if result == "fail" {
+			updates["status"] = "failed"
+
+			rejection := &models.MaterialTransaction{
+				MaterialID: inspection.MaterialID,
+				Type:       "return_supplier",
+				Quantity:   inspection.Quantity,
+				Supplier:   inspection.Transaction.Supplier,
+				SupplierID: inspection.SupplierID,
+				LotNo:      inspection.Transaction.LotNo,
+				ReasonCode: ReasonCodeSupplierRejection,
+				Remark:     fmt.Sprintf("来料检验单 %s 不合格退供应商", inspection.InspectionNo),
+				OperatorID: inspectorID,
+			}
+			if _, err := postRestrictedDisposal(tx, rejection, restrictedStockQuarantine); err != nil {
+				return err
+			}
+			updates["reject_transaction_id"] = rejection.ID
+
+			// 退回数量不计入采购订单行已收数量
+			if inspection.Transaction.PurchaseOrderLineID != nil {
+				if err := reversePurchaseOrderReceipt(tx, *inspection.Transaction.PurchaseOrderLineID, inspection.Quantity); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Model(&inspection).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新来料检验单失败: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetInspection(inspection.ID)
+}
+
+// createIncomingInspection 为入库交易生成待检的来料检验单并将收货数量转入隔离库存
+// 需在入库交易过账的同一事务中调用
+func createIncomingInspection(tx *gorm.DB, transaction *models.MaterialTransaction, material *models.Material) (*models.IncomingInspection, error) {
+	var count int64
+	tx.Model(&models.IncomingInspection{}).Where("transaction_id = ?", transaction.ID).Count(&count)
+	if count > 0 {
+		return nil, errors.New("该入库交易已存在来料检验单")
+	}
+
+	inspection := &models.IncomingInspection{
+		InspectionNo:  generateIncomingInspectionNo(tx),
+		TransactionID: transaction.ID,
+		MaterialID:    transaction.MaterialID,
+		SupplierID:    transaction.SupplierID,
+		Quantity:      transaction.Quantity,
+		Status:        "pending",
+	}
+	if err := tx.Create(inspection).Error; err != nil {
+		return nil, fmt.Errorf("创建来料检验单失败: %v", err)
+	}
+
+	if err := tx.Model(&models.Material{}).Where("id = ?", material.ID).
+		Update("quarantine_stock", gorm.Expr("quarantine_stock + ?", transaction.Quantity)).Error; err != nil {
+		return nil, fmt.Errorf("更新隔离库存失败: %v", err)
+	}
+	material.QuarantineStock = roundQuantity(material.QuarantineStock + transaction.Quantity)
+
+	return inspection, nil
+}
+
+// 辅助函数：校验检验项目并计算检验结论，任一项目超出标准范围即为不合格
+func (s *IncomingInspectionService) evaluateItems(materialID uint, req *IncomingInspectionResultRequest) ([]models.IncomingInspectionItem, string, error) {
+	var standards []models.MaterialQualityStandard
+	if err := s.db.Where("material_id = ? AND is_active = ?", materialID, true).Find(&standards).Error; err != nil {
+		return nil, "", fmt.Errorf("获取物料质量标准失败: %v", err)
+	}
+
+	// 物料没有质量标准时直接使用检验结论
+	if len(standards) == 0 {
+		if req.Result != "pass" && req.Result != "fail" {
+			return nil, "", errors.New("物料没有质量标准，检验结论必须是 pass 或 fail")
+		}
+		return nil, req.Result, nil
+	}
+
+	standardMap := make(map[uint]models.MaterialQualityStandard, len(standards))
+	for _, standard := range standards {
+		standardMap[standard.ID] = standard
+	}
+
+	result := "pass"
+	recorded := make(map[uint]bool, len(req.Items))
+	items := make([]models.IncomingInspectionItem, 0, len(req.Items))
+	for _, item := range req.Items {
+		standard, ok := standardMap[item.MaterialQualityStandardID]
+		if !ok {
+			return nil, "", fmt.Errorf("质量标准 %d 不属于该物料或未启用", item.MaterialQualityStandardID)
+		}
+		if recorded[standard.ID] {
+			return nil, "", fmt.Errorf("质量标准 %s 重复录入", standard.Name)
+		}
+		recorded[standard.ID] = true
+
+		itemResult := "pass"
+		if item.ActualValue < standard.MinValue || item.ActualValue > standard.MaxValue {
+			itemResult = "fail"
+			result = "fail"
+		}
+
+		items = append(items, models.IncomingInspectionItem{
+			MaterialQualityStandardID: standard.ID,
+			ActualValue:               item.ActualValue,
+			Result:                    itemResult,
+			Remark:                    item.Remark,
+		})
+	}
+
+	for _, standard := range standards {
+		if !recorded[standard.ID] {
+			return nil, "", fmt.Errorf("缺少质量标准 %s 的检验结果", standard.Name)
+		}
+	}
+
+	return items, result, nil
+}
+
+// 辅助函数：校验标准数值范围
+func validateStandardRange(minValue, maxValue, targetValue float64) error {
+	if minValue >= maxValue {
+		return errors.New("最小值必须小于最大值")
+	}
+
+	if targetValue < minValue || targetValue > maxValue {
+		return errors.New("目标值必须在最小值和最大值之间")
+	}
+
+	return nil
+}
+
+// 辅助函数：检查同一物料下是否存在相同名称的质量标准
+func (s *IncomingInspectionService) isMaterialStandardNameExists(materialID uint, name string, excludeID uint) bool {
+	var count int64
+	query := s.db.Model(&models.MaterialQualityStandard{}).Where("material_id = ? AND name = ?", materialID, name)
+	if excludeID > 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+	query.Count(&count)
+	return count > 0
+}
+
+// generateIncomingInspectionNo 生成来料检验单号
+func generateIncomingInspectionNo(tx *gorm.DB) string {
+	prefix := fmt.Sprintf("IQC%s", time.Now().Format("20060102"))
+
+	var count int64
+	tx.Unscoped().Model(&models.IncomingInspection{}).
+		Where("inspection_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：转换物料质量标准响应结构体
+func (s *IncomingInspectionService) standardToResponse(standard *models.MaterialQualityStandard, material *models.Material) *MaterialQualityStandardResponse {
+	return &MaterialQualityStandardResponse{
+		ID:           standard.ID,
+		MaterialID:   standard.MaterialID,
+		MaterialCode: material.Code,
+		MaterialName: material.Name,
+		Name:         standard.Name,
+		Type:         standard.Type,
+		MinValue:     standard.MinValue,
+		MaxValue:     standard.MaxValue,
+		TargetValue:  standard.TargetValue,
+		Unit:         standard.Unit,
+		Description:  standard.Description,
+		IsActive:     standard.IsActive,
+		CreatedAt:    standard.CreatedAt,
+		UpdatedAt:    standard.UpdatedAt,
+	}
+}
+
+// 辅助函数：转换来料检验单响应结构体
+func (s *IncomingInspectionService) inspectionToResponse(inspection *models.IncomingInspection, withItems bool) *IncomingInspectionResponse {
+	resp := &IncomingInspectionResponse{
+		ID:                  inspection.ID,
+		InspectionNo:        inspection.InspectionNo,
+		TransactionID:       inspection.TransactionID,
+		MaterialID:          inspection.MaterialID,
+		MaterialCode:        inspection.Material.Code,
+		MaterialName:        inspection.Material.Name,
+		SupplierID:          inspection.SupplierID,
+		Supplier:            inspection.Transaction.Supplier,
+		Quantity:            inspection.Quantity,
+		Status:              inspection.Status,
+		InspectorID:         inspection.InspectorID,
+		InspectedAt:         inspection.InspectedAt,
+		RejectTransactionID: inspection.RejectTransactionID,
+		Remark:              inspection.Remark,
+		ReceivedAt:          inspection.Transaction.CreatedAt,
+		CreatedAt:           inspection.CreatedAt,
+	}
+	if inspection.Inspector != nil {
+		resp.InspectorName = inspection.Inspector.Username
+	}
+
+	if withItems {
+		for _, item := range inspection.Items {
+			resp.Items = append(resp.Items, IncomingInspectionItemResponse{
+				ID:                        item.ID,
+				MaterialQualityStandardID: item.MaterialQualityStandardID,
+				StandardName:              item.MaterialQualityStandard.Name,
+				MinValue:                  item.MaterialQualityStandard.MinValue,
+				MaxValue:                  item.MaterialQualityStandard.MaxValue,
+				TargetValue:               item.MaterialQualityStandard.TargetValue,
+				Unit:                      item.MaterialQualityStandard.Unit,
+				ActualValue:               item.ActualValue,
+				Result:                    item.Result,
+				Remark:                    item.Remark,
+			})
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,565 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mes-system/internal/models"
+)
+
+// InspectionSheetLineRequest 检测单明细请求结构体，每行为一个质量特性的测量值
+type InspectionSheetLineRequest struct {
+	QualityStandardID uint                      `json:"quality_standard_id" binding:"required"` // 质量标准（质量特性）ID
+	ActualValue       float64                   `json:"actual_value"`                           // 实际值，计数型特性见缺陷明细
+	Defects           []InspectionDefectRequest `json:"defects" binding:"omitempty,dive"`       // 缺陷明细
+	Result            string                    `json:"result"`                                 // 检测结果：pass/fail，不填由系统判定；与系统判定不一致时为人工改判
+	OverrideReason    string                    `json:"override_reason"`                        // 人工改判原因
+	Remark            string                    `json:"remark"`                                 // 备注
+}
+
+// InspectionSheetRequest 检测单请求结构体
+type InspectionSheetRequest struct {
+	ProductionOrderID uint                         `json:"production_order_id" binding:"required"` // 生产工单ID
+	SampleNo          int                          `json:"sample_no" binding:"required,min=1"`     // 样品序号
+	InspectorID       uint                         `json:"inspector_id" binding:"required"`        // 检测员ID
+	EquipmentID       *uint                        `json:"equipment_id"`                           // 生产设备ID
+	InspectionLotID   *uint                        `json:"inspection_lot_id"`                      // 检验批ID，作为抽样检验的样本时填写
+	InspectionTime    *time.Time                   `json:"inspection_time"`                        // 检测时间，默认为当前时间
+	Remark            string                       `json:"remark"`                                 // 备注
+	Lines             []InspectionSheetLineRequest `json:"lines" binding:"required,min=1,dive"`    // 检测明细
+}
+
+// InspectionSheetResponse 检测单响应结构体
+type InspectionSheetResponse struct {
+	ID                uint                        `json:"id"`
+	SheetNo           string                      `json:"sheet_no"`
+	ProductionOrderID uint                        `json:"production_order_id"`
+	ProductionOrderNo string                      `json:"production_order_no"`
+	ProductID         uint                        `json:"product_id"`
+	ProductCode       string                      `json:"product_code"`
+	ProductName       string                      `json:"product_name"`
+	SampleNo          int                         `json:"sample_no"`
+	InspectorID       uint                        `json:"inspector_id"`
+	InspectorName     string                      `json:"inspector_name"`
+	EquipmentID       *uint                       `json:"equipment_id"`
+	EquipmentName     string                      `json:"equipment_name"`
+	InspectionLotID   *uint                       `json:"inspection_lot_id"`
+	InspectionTime    time.Time                   `json:"inspection_time"`
+	Result            string                      `json:"result"`
+	LineCount         int                         `json:"line_count"`
+	FailedCount       int                         `json:"failed_count"`
+	Remark            string                      `json:"remark"`
+	CreatedBy         uint                        `json:"created_by"`
+	CreatorName       string                      `json:"creator_name"`
+	CreatedAt         time.Time                   `json:"created_at"`
+	UpdatedAt         time.Time                   `json:"updated_at"`
+	Lines             []QualityInspectionResponse `json:"lines,omitempty"`
+	QualityEvents     []QualityEventResponse      `json:"quality_events,omitempty"` // 本次保存触发的判异事件
+}
+
+// InspectionSheetService 检测单服务
+type InspectionSheetService struct {
+	db             *gorm.DB
+	qualityService *QualityService
+}
+
+// NewInspectionSheetService 创建检测单服务实例
+func NewInspectionSheetService(db *gorm.DB, qualityService *QualityService) *InspectionSheetService {
+	return &InspectionSheetService{
+		db:             db,
+		qualityService: qualityService,
+	}
+}
+
+// CreateInspectionSheet 创建检测单，各明细由系统按规格限判定并判异，总体结论由明细计算
+func (s *InspectionSheetService) CreateInspectionSheet(req *InspectionSheetRequest, operatorID uint, role string) (*InspectionSheetResponse, error) {
+	lines, standards, err := s.buildSheetLines(req, role, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSheetLot(s.db, req, 0); err != nil {
+		return nil, err
+	}
+
+	sheet := &models.InspectionSheet{
+		ProductionOrderID: req.ProductionOrderID,
+		SampleNo:          req.SampleNo,
+		InspectorID:       req.InspectorID,
+		EquipmentID:       req.EquipmentID,
+		InspectionLotID:   req.InspectionLotID,
+		InspectionTime:    sheetInspectionTime(req),
+		Remark:            req.Remark,
+		CreatedBy:         operatorID,
+	}
+	applySheetResult(sheet, lines)
+
+	var events []models.QualityEvent
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		sheet.SheetNo = s.generateSheetNo(tx)
+		if err := tx.Create(sheet).Error; err != nil {
+			return fmt.Errorf("创建检测单失败: %v", err)
+		}
+
+		events, err = saveSheetLines(tx, sheet, lines, standards, req.Lines, nil, operatorID, role)
+		if err != nil {
+			return err
+		}
+		return syncSheetNcr(tx, sheet)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.sheetResponseWithEvents(sheet.ID, events)
+}
+
+// GetInspectionSheet 获取检测单详情
+func (s *InspectionSheetService) GetInspectionSheet(id uint) (*InspectionSheetResponse, error) {
+	sheet, err := s.loadSheet(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.sheetToResponse(sheet, true), nil
+}
+
+// GetInspectionSheetList 获取检测单列表
+func (s *InspectionSheetService) GetInspectionSheetList(page, pageSize int, productionOrderID, inspectorID, inspectionLotID uint, result, keyword string) ([]InspectionSheetResponse, int64, error) {
+	query := s.db.Model(&models.InspectionSheet{})
+	if productionOrderID > 0 {
+		query = query.Where("production_order_id = ?", productionOrderID)
+	}
+	if inspectorID > 0 {
+		query = query.Where("inspector_id = ?", inspectorID)
+	}
+	if inspectionLotID > 0 {
+		query = query.Where("inspection_lot_id = ?", inspectionLotID)
+	}
+	if result != "" {
+		query = query.Where("result = ?", result)
+	}
+	if keyword != "" {
+		query = query.Where("sheet_no LIKE ?", "%"+keyword+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取检测单总数失败: %v", err)
+	}
+
+	var sheets []models.InspectionSheet
+	offset := (page - 1) * pageSize
+	if err := query.Preload("ProductionOrder.Product").Preload("Inspector").Preload("Equipment").Preload("Creator").
+		Order("inspection_time DESC, id DESC").
+		Offset(offset).Limit(pageSize).Find(&sheets).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取检测单列表失败: %v", err)
+	}
+
+	responses := make([]InspectionSheetResponse, 0, len(sheets))
+	for i := range sheets {
+		responses = append(responses, *s.sheetToResponse(&sheets[i], false))
+	}
+	return responses, total, nil
+}
+
+// UpdateInspectionSheet 更新检测单，明细按质量标准原位更新并重新判定，新增的明细及测量值变化的明细参与判异
+func (s *InspectionSheetService) UpdateInspectionSheet(id uint, req *InspectionSheetRequest, operatorID uint, role string) (*InspectionSheetResponse, error) {
+	var events []models.QualityEvent
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定检测单后再校验所属检验批，避免与检验批判定并发修改样本记录
+		var sheet models.InspectionSheet
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sheet, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("检测单不存在")
+			}
+			return fmt.Errorf("获取检测单失败: %v", err)
+		}
+		if err := checkSheetLotEditable(tx, id); err != nil {
+			return err
+		}
+		if err := validateSheetLot(tx, req, id); err != nil {
+			return err
+		}
+
+		// 原有明细按质量标准匹配，保留的明细原位更新，测量值未变化时不再重复判异；移除的质量标准删除对应明细
+		var existing []models.QualityInspection
+		if err := tx.Where("inspection_sheet_id = ?", id).Find(&existing).Error; err != nil {
+			return fmt.Errorf("获取检测明细失败: %v", err)
+		}
+		existingLines := make(map[uint]*models.QualityInspection, len(existing))
+		for i := range existing {
+			existingLines[existing[i].QualityStandardID] = &existing[i]
+		}
+
+		// 保留的明细原经人工改判时按原改判结果判定，撤销或变更改判需管理员或主管权限
+		lines, standards, err := s.buildSheetLines(req, role, existingLines)
+		if err != nil {
+			return err
+		}
+
+		var removedIDs []uint
+		for standardID, old := range existingLines {
+			if _, kept := standards[standardID]; !kept {
+				removedIDs = append(removedIDs, old.ID)
+			}
+		}
+		if len(removedIDs) > 0 {
+			if err := tx.Delete(&models.QualityInspection{}, removedIDs).Error; err != nil {
+				return fmt.Errorf("删除检测明细失败: %v", err)
+			}
+		}
+
+		sheet.ProductionOrderID = req.ProductionOrderID
+		sheet.SampleNo = req.SampleNo
+		sheet.InspectorID = req.InspectorID
+		sheet.EquipmentID = req.EquipmentID
+		sheet.InspectionLotID = req.InspectionLotID
+		sheet.InspectionTime = sheetInspectionTime(req)
+		sheet.Remark = req.Remark
+		applySheetResult(&sheet, lines)
+		if err := tx.Omit("ProductionOrder", "Inspector", "Equipment", "Creator", "Lines").Save(&sheet).Error; err != nil {
+			return fmt.Errorf("更新检测单失败: %v", err)
+		}
+
+		events, err = saveSheetLines(tx, &sheet, lines, standards, req.Lines, existingLines, operatorID, role)
+		if err != nil {
+			return err
+		}
+		return syncSheetNcr(tx, &sheet)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.sheetResponseWithEvents(id, events)
+}
+
+// DeleteInspectionSheet 删除检测单及其明细
+func (s *InspectionSheetService) DeleteInspectionSheet(id uint) error {
+	if _, err := s.loadSheet(s.db, id); err != nil {
+		return err
+	}
+	if err := checkSheetLotEditable(s.db, id); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("inspection_sheet_id = ?", id).Delete(&models.QualityInspection{}).Error; err != nil {
+			return fmt.Errorf("删除检测明细失败: %v", err)
+		}
+		if err := tx.Delete(&models.InspectionSheet{}, id).Error; err != nil {
+			return fmt.Errorf("删除检测单失败: %v", err)
+		}
+		return cancelAutoNcr(tx, "inspection_sheet_id", id)
+	})
+}
+
+// 辅助函数：验证检测单所属检验批，检验批须待判定且属于同一工单，样品序号不超过样本量且在批内唯一
+func validateSheetLot(db *gorm.DB, req *InspectionSheetRequest, sheetID uint) error {
+	if req.InspectionLotID == nil {
+		return nil
+	}
+
+	var lot models.InspectionLot
+	if err := db.Clauses(clause.Locking{Strength: "SHARE"}).First(&lot, *req.InspectionLotID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("检验批不存在")
+		}
+		return fmt.Errorf("验证检验批失败: %v", err)
+	}
+	if lot.Disposition != LotDispositionPending {
+		return errors.New("检验批已判定，不能添加样本")
+	}
+	if lot.ProductionOrderID != req.ProductionOrderID {
+		return errors.New("检测单的生产工单与检验批不一致")
+	}
+	if req.SampleNo > lot.SampleSize {
+		return fmt.Errorf("样品序号超出检验批样本量 %d", lot.SampleSize)
+	}
+
+	var count int64
+	if err := db.Model(&models.InspectionSheet{}).
+		Where("inspection_lot_id = ? AND sample_no = ? AND id <> ?", lot.ID, req.SampleNo, sheetID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("检查样品序号失败: %v", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("检验批中已存在样品序号 %d 的检测单", req.SampleNo)
+	}
+	return nil
+}
+
+// 辅助函数：验证检测单表头并按规格限判定各明细，返回未保存的明细和对应的质量标准，existing为修改检测单时按质量标准匹配的原有明细
+func (s *InspectionSheetService) buildSheetLines(req *InspectionSheetRequest, role string, existing map[uint]*models.QualityInspection) ([]models.QualityInspection, map[uint]*models.QualityStandard, error) {
+	var order models.ProductionOrder
+	if err := s.db.First(&order, req.ProductionOrderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("生产工单不存在")
+		}
+		return nil, nil, fmt.Errorf("验证生产工单失败: %v", err)
+	}
+
+	var inspector models.User
+	if err := s.db.First(&inspector, req.InspectorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("检测员不存在")
+		}
+		return nil, nil, fmt.Errorf("验证检测员失败: %v", err)
+	}
+
+	if _, err := validateInspectionEquipment(s.db, req.EquipmentID); err != nil {
+		return nil, nil, err
+	}
+
+	inspectionTime := sheetInspectionTime(req)
+	standards := make(map[uint]*models.QualityStandard)
+	lines := make([]models.QualityInspection, 0, len(req.Lines))
+	for i, lineReq := range req.Lines {
+		if _, exists := standards[lineReq.QualityStandardID]; exists {
+			return nil, nil, fmt.Errorf("第%d行: 质量标准重复", i+1)
+		}
+
+		var standard models.QualityStandard
+		if err := s.db.First(&standard, lineReq.QualityStandardID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil, fmt.Errorf("第%d行: 质量标准不存在", i+1)
+			}
+			return nil, nil, fmt.Errorf("第%d行: 验证质量标准失败: %v", i+1, err)
+		}
+		if standard.ProductID != order.ProductID {
+			return nil, nil, fmt.Errorf("第%d行: 质量标准 %s 不属于工单产品", i+1, standard.Name)
+		}
+		standards[standard.ID] = &standard
+
+		if err := validateInspectionDefects(s.db, lineReq.Defects); err != nil {
+			return nil, nil, fmt.Errorf("第%d行: %v", i+1, err)
+		}
+
+		inspectionReq := &QualityInspectionRequest{
+			ActualValue:    lineReq.ActualValue,
+			Result:         lineReq.Result,
+			OverrideReason: lineReq.OverrideReason,
+			Defects:        lineReq.Defects,
+		}
+		actualValue, judged, err := judgeInspection(&standard, inspectionReq)
+		if err != nil {
+			return nil, nil, fmt.Errorf("第%d行: %v", i+1, err)
+		}
+		result, overridden, err := resolveUpdatedInspectionResult(inspectionReq, judged, role, existing[standard.ID])
+		if err != nil {
+			return nil, nil, fmt.Errorf("第%d行: %v", i+1, err)
+		}
+
+		lines = append(lines, models.QualityInspection{
+			ProductionOrderID: req.ProductionOrderID,
+			QualityStandardID: standard.ID,
+			InspectorID:       req.InspectorID,
+			EquipmentID:       req.EquipmentID,
+			ActualValue:       actualValue,
+			Result:            result,
+			JudgedResult:      judged,
+			Overridden:        overridden,
+			Remark:            lineReq.Remark,
+			InspectionTime:    inspectionTime,
+		})
+	}
+
+	return lines, standards, nil
+}
+
+// 辅助函数：保存检测明细及其缺陷并记录人工改判，existing中已有的明细原位更新，测量值等变化时重新判异，新增的明细按质量标准判异
+func saveSheetLines(tx *gorm.DB, sheet *models.InspectionSheet, lines []models.QualityInspection, standards map[uint]*models.QualityStandard, reqLines []InspectionSheetLineRequest, existing map[uint]*models.QualityInspection, operatorID uint, role string) ([]models.QualityEvent, error) {
+	var events []models.QualityEvent
+	for i := range lines {
+		line := &lines[i]
+		line.InspectionSheetID = &sheet.ID
+
+		if old, ok := existing[line.QualityStandardID]; ok {
+			line.ID = old.ID
+			line.CreatedAt = old.CreatedAt
+			if err := tx.Model(&models.QualityInspection{}).Where("id = ?", old.ID).Updates(map[string]interface{}{
+				"production_order_id": line.ProductionOrderID,
+				"inspector_id":        line.InspectorID,
+				"equipment_id":        line.EquipmentID,
+				"actual_value":        line.ActualValue,
+				"result":              line.Result,
+				"judged_result":       line.JudgedResult,
+				"overridden":          line.Overridden,
+				"remark":              line.Remark,
+				"inspection_time":     line.InspectionTime,
+			}).Error; err != nil {
+				return nil, fmt.Errorf("更新检测明细失败: %v", err)
+			}
+			if err := saveInspectionDefects(tx, line, reqLines[i].Defects); err != nil {
+				return nil, err
+			}
+			if err := writeOverrideChange(tx, line, old.Overridden, old.Result, reqLines[i].OverrideReason, operatorID, role); err != nil {
+				return nil, err
+			}
+			if spcInputsChanged(old, line) {
+				lineEvents, err := reevaluateSpcRules(tx, line, standards[line.QualityStandardID])
+				if err != nil {
+					return nil, err
+				}
+				events = append(events, lineEvents...)
+			}
+			continue
+		}
+
+		if err := tx.Create(line).Error; err != nil {
+			return nil, fmt.Errorf("创建检测明细失败: %v", err)
+		}
+		if err := saveInspectionDefects(tx, line, reqLines[i].Defects); err != nil {
+			return nil, err
+		}
+
+		if line.Overridden {
+			if err := writeResultOverride(tx, line, reqLines[i].OverrideReason, operatorID, role); err != nil {
+				return nil, err
+			}
+		}
+
+		lineEvents, err := evaluateSpcRules(tx, line, standards[line.QualityStandardID])
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, lineEvents...)
+	}
+	return events, nil
+}
+
+// 辅助函数：按明细计算检测单的总体结论和不合格数
+func applySheetResult(sheet *models.InspectionSheet, lines []models.QualityInspection) {
+	sheet.LineCount = len(lines)
+	sheet.FailedCount = 0
+	for _, line := range lines {
+		if line.Result == "fail" {
+			sheet.FailedCount++
+		}
+	}
+	sheet.Result = "pass"
+	if sheet.FailedCount > 0 {
+		sheet.Result = "fail"
+	}
+}
+
+// 辅助函数：单独修改或删除检测明细后重新计算所属检测单的总体结论，并同步自动创建的不合格品报告
+func refreshInspectionSheetResult(tx *gorm.DB, sheetID uint) error {
+	var sheet models.InspectionSheet
+	if err := tx.First(&sheet, sheetID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("获取检测单失败: %v", err)
+	}
+
+	var lines []models.QualityInspection
+	if err := tx.Select("id", "result").Where("inspection_sheet_id = ?", sheetID).Find(&lines).Error; err != nil {
+		return fmt.Errorf("获取检测明细失败: %v", err)
+	}
+
+	applySheetResult(&sheet, lines)
+	if err := tx.Model(&sheet).Updates(map[string]interface{}{
+		"result":       sheet.Result,
+		"line_count":   sheet.LineCount,
+		"failed_count": sheet.FailedCount,
+	}).Error; err != nil {
+		return fmt.Errorf("更新检测单结论失败: %v", err)
+	}
+	return syncSheetNcr(tx, &sheet)
+}
+
+// 辅助函数：获取检测时间，未填写时为当前时间
+func sheetInspectionTime(req *InspectionSheetRequest) time.Time {
+	if req.InspectionTime != nil {
+		return *req.InspectionTime
+	}
+	return time.Now()
+}
+
+// 辅助函数：加载检测单及明细
+func (s *InspectionSheetService) loadSheet(db *gorm.DB, id uint) (*models.InspectionSheet, error) {
+	var sheet models.InspectionSheet
+	err := db.Preload("ProductionOrder.Product").Preload("Inspector").Preload("Equipment").Preload("Creator").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Lines.QualityStandard").Preload("Lines.Equipment").Preload("Lines.Defects.DefectCode").
+		First(&sheet, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("检测单不存在")
+		}
+		return nil, fmt.Errorf("获取检测单失败: %v", err)
+	}
+	return &sheet, nil
+}
+
+// 辅助函数：获取检测单响应并附加本次保存触发的判异事件
+func (s *InspectionSheetService) sheetResponseWithEvents(id uint, events []models.QualityEvent) (*InspectionSheetResponse, error) {
+	sheet, err := s.loadSheet(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := s.sheetToResponse(sheet, true)
+	standards := make(map[uint]models.QualityStandard)
+	for _, line := range sheet.Lines {
+		standards[line.QualityStandardID] = line.QualityStandard
+	}
+	for i := range events {
+		events[i].QualityStandard = standards[events[i].QualityStandardID]
+		events[i].ProductionOrder = sheet.ProductionOrder
+		resp.QualityEvents = append(resp.QualityEvents, *qualityEventToResponse(&events[i]))
+	}
+	return resp, nil
+}
+
+// 辅助函数：生成检测单号
+func (s *InspectionSheetService) generateSheetNo(tx *gorm.DB) string {
+	prefix := fmt.Sprintf("QC%s", time.Now().Format("20060102"))
+
+	var count int64
+	tx.Unscoped().Model(&models.InspectionSheet{}).
+		Where("sheet_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：转换为响应结构体
+func (s *InspectionSheetService) sheetToResponse(sheet *models.InspectionSheet, withLines bool) *InspectionSheetResponse {
+	resp := &InspectionSheetResponse{
+		ID:                sheet.ID,
+		SheetNo:           sheet.SheetNo,
+		ProductionOrderID: sheet.ProductionOrderID,
+		ProductionOrderNo: sheet.ProductionOrder.OrderNo,
+		ProductID:         sheet.ProductionOrder.ProductID,
+		ProductCode:       sheet.ProductionOrder.Product.Code,
+		ProductName:       sheet.ProductionOrder.Product.Name,
+		SampleNo:          sheet.SampleNo,
+		InspectorID:       sheet.InspectorID,
+		InspectorName:     sheet.Inspector.Username,
+		EquipmentID:       sheet.EquipmentID,
+		InspectionLotID:   sheet.InspectionLotID,
+		InspectionTime:    sheet.InspectionTime,
+		Result:            sheet.Result,
+		LineCount:         sheet.LineCount,
+		FailedCount:       sheet.FailedCount,
+		Remark:            sheet.Remark,
+		CreatedBy:         sheet.CreatedBy,
+		CreatorName:       sheet.Creator.Username,
+		CreatedAt:         sheet.CreatedAt,
+		UpdatedAt:         sheet.UpdatedAt,
+	}
+	if sheet.Equipment != nil {
+		resp.EquipmentName = sheet.Equipment.Name
+	}
+	if withLines {
+		for i := range sheet.Lines {
+			line := &sheet.Lines[i]
+			resp.Lines = append(resp.Lines, *s.qualityService.qualityInspectionToResponse(line, &sheet.ProductionOrder, &line.QualityStandard, &sheet.Inspector))
+		}
+	}
+	return resp
+}
@@ -0,0 +1,557 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"mes-system/internal/models"
+)
+
+// countReasonCodes 盘点差异原因代码
+var countReasonCodes = map[string]string{
+	"count_variance": "盘点差异",
+	"damage":         "损坏",
+	"loss":           "丢失",
+	"unrecorded":     "收发未登记",
+	"miscount":       "前次盘点错误",
+}
+
+// defaultCountReasonCode 未指定原因时使用的默认原因代码
+const defaultCountReasonCode = "count_variance"
+
+// InventoryCountRequest 创建盘点单请求结构体
+type InventoryCountRequest struct {
+	MaterialIDs  []uint   `json:"material_ids"`  // 盘点物料ID
+	Locations    []string `json:"locations"`     // 盘点库位
+	MaterialType string   `json:"material_type"` // 盘点物料类型
+	Remark       string   `json:"remark"`        // 备注
+}
+
+// InventoryCountEntry 实盘数量录入
+type InventoryCountEntry struct {
+	MaterialID      uint    `json:"material_id" binding:"required"`   // 物料ID
+	CountedQuantity float64 `json:"counted_quantity" binding:"min=0"` // 实盘数量
+	ReasonCode      string  `json:"reason_code"`                      // 差异原因代码
+	Remark          string  `json:"remark"`                           // 备注
+}
+
+// InventoryCountEntryRequest 实盘数量录入请求结构体
+type InventoryCountEntryRequest struct {
+	Entries []InventoryCountEntry `json:"entries" binding:"required,min=1,dive"`
+}
+
+// InventoryCountLineResponse 盘点明细响应结构体
+type InventoryCountLineResponse struct {
+	ID              uint     `json:"id"`
+	MaterialID      uint     `json:"material_id"`
+	MaterialCode    string   `json:"material_code"`
+	MaterialName    string   `json:"material_name"`
+	Location        string   `json:"location"`
+	Unit            string   `json:"unit"`
+	FrozenQuantity  float64  `json:"frozen_quantity"`
+	CurrentStock    float64  `json:"current_stock"`
+	CountedQuantity *float64 `json:"counted_quantity"`
+	Variance        *float64 `json:"variance"`
+	VarianceValue   *float64 `json:"variance_value"`
+	ReasonCode      string   `json:"reason_code"`
+	Remark          string   `json:"remark"`
+	TransactionID   *uint    `json:"transaction_id"`
+}
+
+// InventoryCountResponse 盘点单响应结构体
+type InventoryCountResponse struct {
+	ID           uint                         `json:"id"`
+	CountNo      string                       `json:"count_no"`
+	Locations    []string                     `json:"locations"`
+	MaterialType string                       `json:"material_type"`
+	Status       string                       `json:"status"`
+	Remark       string                       `json:"remark"`
+	CreatedBy    uint                         `json:"created_by"`
+	CreatorName  string                       `json:"creator_name"`
+	ApprovedBy   *uint                        `json:"approved_by"`
+	ApprovedAt   *time.Time                   `json:"approved_at"`
+	LineCount    int                          `json:"line_count"`
+	CountedCount int                          `json:"counted_count"`
+	Lines        []InventoryCountLineResponse `json:"lines,omitempty"`
+	CreatedAt    time.Time                    `json:"created_at"`
+}
+
+// InventoryCountService 库存盘点服务
+type InventoryCountService struct {
+	db *gorm.DB
+}
+
+// NewInventoryCountService 创建库存盘点服务实例
+func NewInventoryCountService(db *gorm.DB) *InventoryCountService {
+	return &InventoryCountService{db: db}
+}
+
+// CreateCount 创建盘点单并冻结盘点范围内的物料
+func (s *InventoryCountService) CreateCount(req *InventoryCountRequest, createdBy uint) (*InventoryCountResponse, error) {
+	if len(req.MaterialIDs) == 0 && len(req.Locations) == 0 && req.MaterialType == "" {
+		return nil, errors.New("请指定盘点的物料、库位或物料类型")
+	}
+
+	// 按条件确定盘点物料
+	query := s.db.Model(&models.Material{})
+	if len(req.MaterialIDs) > 0 {
+		query = query.Where("id IN ?", req.MaterialIDs)
+	}
+	if len(req.Locations) > 0 {
+		query = query.Where("location IN ?", req.Locations)
+	}
+	if req.MaterialType != "" {
+		query = query.Where("type = ?", req.MaterialType)
+	}
+
+	var materials []models.Material
+	if err := query.Order("location, code").Find(&materials).Error; err != nil {
+		return nil, fmt.Errorf("获取盘点物料失败: %v", err)
+	}
+	if len(materials) == 0 {
+		return nil, errors.New("盘点范围内没有物料")
+	}
+
+	count := &models.InventoryCount{
+		CountNo:      s.generateCountNo(),
+		Locations:    strings.Join(req.Locations, ","),
+		MaterialType: req.MaterialType,
+		Status:       "counting",
+		Remark:       req.Remark,
+		CreatedBy:    createdBy,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 同一物料不能同时存在于多个进行中的盘点单
+		for _, material := range materials {
+			if err := checkMaterialNotFrozen(tx, material.ID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(count).Error; err != nil {
+			return fmt.Errorf("创建盘点单失败: %v", err)
+		}
+
+		lines := make([]models.InventoryCountLine, 0, len(materials))
+		for _, material := range materials {
+			lines = append(lines, models.InventoryCountLine{
+				CountID:        count.ID,
+				MaterialID:     material.ID,
+				FrozenQuantity: material.CurrentStock,
+			})
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return fmt.Errorf("创建盘点明细失败: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCount(count.ID)
+}
+
+// GetCount 获取盘点单详情
+func (s *InventoryCountService) GetCount(id uint) (*InventoryCountResponse, error) {
+	var count models.InventoryCount
+	if err := s.db.Preload("Creator").Preload("Lines.Material").First(&count, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("盘点单不存在")
+		}
+		return nil, fmt.Errorf("获取盘点单失败: %v", err)
+	}
+
+	return s.countToResponse(&count, true), nil
+}
+
+// GetCountList 获取盘点单列表
+func (s *InventoryCountService) GetCountList(page, pageSize int, status string) ([]InventoryCountResponse, int64, error) {
+	var counts []models.InventoryCount
+	var total int64
+
+	query := s.db.Model(&models.InventoryCount{})
+
+	// 按状态筛选
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取盘点单总数失败: %v", err)
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Creator").Preload("Lines").Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&counts).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取盘点单列表失败: %v", err)
+	}
+
+	var responses []InventoryCountResponse
+	for _, count := range counts {
+		responses = append(responses, *s.countToResponse(&count, false))
+	}
+
+	return responses, total, nil
+}
+
+// RecordCounts 录入实盘数量
+func (s *InventoryCountService) RecordCounts(id uint, entries []InventoryCountEntry) (*InventoryCountResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		lines, err := s.getCountingLines(tx, id)
+		if err != nil {
+			return err
+		}
+
+		byMaterial := make(map[uint]*models.InventoryCountLine, len(lines))
+		for i := range lines {
+			byMaterial[lines[i].MaterialID] = &lines[i]
+		}
+
+		for _, entry := range entries {
+			line, exists := byMaterial[entry.MaterialID]
+			if !exists {
+				return fmt.Errorf("物料 %d 不在该盘点单范围内", entry.MaterialID)
+			}
+			if err := s.updateLine(tx, line, entry); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCount(id)
+}
+
+// ImportCounts 从CSV导入实盘数量
+// CSV列依次为：物料编码、实盘数量、差异原因代码（可选）、备注（可选），首行可为表头
+func (s *InventoryCountService) ImportCounts(id uint, reader io.Reader) (*InventoryCountResponse, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析CSV失败: %v", err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		lines, err := s.getCountingLines(tx, id)
+		if err != nil {
+			return err
+		}
+
+		byCode := make(map[string]*models.InventoryCountLine, len(lines))
+		for i := range lines {
+			byCode[lines[i].Material.Code] = &lines[i]
+		}
+
+		imported := 0
+		for i, record := range records {
+			if len(record) < 2 {
+				return fmt.Errorf("第 %d 行格式错误，至少需要物料编码和实盘数量", i+1)
+			}
+
+			code := strings.TrimPrefix(strings.TrimSpace(record[0]), "\xEF\xBB\xBF")
+			quantity, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+			if err != nil {
+				// 首行无法解析数量时视为表头
+				if i == 0 {
+					continue
+				}
+				return fmt.Errorf("第 %d 行实盘数量无效", i+1)
+			}
+			if quantity < 0 {
+				return fmt.Errorf("第 %d 行实盘数量不能为负数", i+1)
+			}
+
+			line, exists := byCode[code]
+			if !exists {
+				return fmt.Errorf("第 %d 行物料 %s 不在该盘点单范围内", i+1, code)
+			}
+
+			entry := InventoryCountEntry{MaterialID: line.MaterialID, CountedQuantity: quantity}
+			if len(record) > 2 {
+				entry.ReasonCode = strings.TrimSpace(record[2])
+			}
+			if len(record) > 3 {
+				entry.Remark = strings.TrimSpace(record[3])
+			}
+
+			if err := s.updateLine(tx, line, entry); err != nil {
+				return fmt.Errorf("第 %d 行: %v", i+1, err)
+			}
+			imported++
+		}
+
+		if imported == 0 {
+			return errors.New("CSV中没有可导入的盘点数据")
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCount(id)
+}
+
+// ApproveCount 审核盘点单，按差异生成盘盈/盘亏调整交易并解除冻结
+func (s *InventoryCountService) ApproveCount(id uint, approverID uint) (*InventoryCountResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count models.InventoryCount
+		if err := tx.Preload("Lines.Material").First(&count, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("盘点单不存在")
+			}
+			return fmt.Errorf("获取盘点单失败: %v", err)
+		}
+
+		if count.Status != "counting" {
+			return errors.New("只有盘点中的盘点单可以审核")
+		}
+
+		for _, line := range count.Lines {
+			if line.CountedQuantity == nil {
+				return fmt.Errorf("物料 %s 尚未录入实盘数量", line.Material.Code)
+			}
+		}
+
+		// 先更新盘点单状态以解除冻结，再登记调整交易
+		now := time.Now()
+		if err := tx.Model(&count).Updates(map[string]interface{}{
+			"status":      "approved",
+			"approved_by": approverID,
+			"approved_at": now,
+		}).Error; err != nil {
+			return fmt.Errorf("更新盘点单状态失败: %v", err)
+		}
+
+		for i := range count.Lines {
+			line := &count.Lines[i]
+			variance := roundQuantity(*line.CountedQuantity - line.Material.CurrentStock)
+			if variance == 0 {
+				continue
+			}
+
+			reasonCode := line.ReasonCode
+			if reasonCode == "" {
+				reasonCode = defaultCountReasonCode
+			}
+
+			transaction := &models.MaterialTransaction{
+				MaterialID: line.MaterialID,
+				ReasonCode: reasonCode,
+				OperatorID: approverID,
+				Remark:     fmt.Sprintf("盘点单 %s 差异调整", count.CountNo),
+			}
+			if variance > 0 {
+				// 盘盈按当前单位成本入账
+				unitCost := materialUnitCost(&line.Material)
+				transaction.Type = "adjust_in"
+				transaction.Quantity = variance
+				transaction.Price = unitCost
+				transaction.TotalAmount = roundAmount(variance * unitCost)
+			} else {
+				transaction.Type = "adjust_out"
+				transaction.Quantity = -variance
+			}
+
+			var err error
+			if transaction.Type == "adjust_out" {
+				// 盘亏是实物短缺，非限制库存不足时扣减冻结库存
+				_, err = postRestrictedDisposal(tx, transaction, restrictedStockCount)
+			} else {
+				_, err = postMaterialTransaction(tx, transaction)
+			}
+			if err != nil {
+				return fmt.Errorf("物料 %s 调整失败: %v", line.Material.Code, err)
+			}
+
+			if err := tx.Model(line).Update("transaction_id", transaction.ID).Error; err != nil {
+				return fmt.Errorf("更新盘点明细失败: %v", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetCount(id)
+}
+
+// CancelCount 取消盘点单并解除冻结
+func (s *InventoryCountService) CancelCount(id uint) error {
+	var count models.InventoryCount
+	if err := s.db.First(&count, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("盘点单不存在")
+		}
+		return fmt.Errorf("获取盘点单失败: %v", err)
+	}
+
+	if count.Status != "counting" {
+		return errors.New("只有盘点中的盘点单可以取消")
+	}
+
+	if err := s.db.Model(&count).Update("status", "cancelled").Error; err != nil {
+		return fmt.Errorf("取消盘点单失败: %v", err)
+	}
+
+	return nil
+}
+
+// GetCountReasonCodes 获取盘点差异原因代码
+func (s *InventoryCountService) GetCountReasonCodes() map[string]string {
+	return countReasonCodes
+}
+
+// checkMaterialNotFrozen 检查物料是否处于进行中的盘点，盘点期间暂停收发
+func checkMaterialNotFrozen(tx *gorm.DB, materialID uint) error {
+	var count models.InventoryCount
+	err := tx.Model(&models.InventoryCount{}).
+		Joins("JOIN inventory_count_lines ON inventory_count_lines.count_id = inventory_counts.id AND inventory_count_lines.deleted_at IS NULL").
+		Where("inventory_counts.status = ? AND inventory_count_lines.material_id = ?", "counting", materialID).
+		First(&count).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("检查盘点状态失败: %v", err)
+	}
+
+	return fmt.Errorf("物料正在盘点中（盘点单 %s），暂停收发", count.CountNo)
+}
+
+// 辅助函数：获取盘点中的盘点单明细
+func (s *InventoryCountService) getCountingLines(tx *gorm.DB, id uint) ([]models.InventoryCountLine, error) {
+	var count models.InventoryCount
+	if err := tx.First(&count, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("盘点单不存在")
+		}
+		return nil, fmt.Errorf("获取盘点单失败: %v", err)
+	}
+
+	if count.Status != "counting" {
+		return nil, errors.New("只有盘点中的盘点单可以录入实盘数量")
+	}
+
+	var lines []models.InventoryCountLine
+	if err := tx.Preload("Material").Where("count_id = ?", id).Find(&lines).Error; err != nil {
+		return nil, fmt.Errorf("获取盘点明细失败: %v", err)
+	}
+
+	return lines, nil
+}
+
+// 辅助函数：更新盘点明细的实盘数量
+func (s *InventoryCountService) updateLine(tx *gorm.DB, line *models.InventoryCountLine, entry InventoryCountEntry) error {
+	if entry.ReasonCode != "" {
+		if _, exists := countReasonCodes[entry.ReasonCode]; !exists {
+			return fmt.Errorf("无效的差异原因代码: %s", entry.ReasonCode)
+		}
+	}
+
+	counted := roundQuantity(entry.CountedQuantity)
+	line.CountedQuantity = &counted
+	line.ReasonCode = entry.ReasonCode
+	line.Remark = entry.Remark
+
+	if err := tx.Model(line).Updates(map[string]interface{}{
+		"counted_quantity": counted,
+		"reason_code":      entry.ReasonCode,
+		"remark":           entry.Remark,
+	}).Error; err != nil {
+		return fmt.Errorf("更新盘点明细失败: %v", err)
+	}
+
+	return nil
+}
+
+// 辅助函数：生成盘点单号
+func (s *InventoryCountService) generateCountNo() string {
+	prefix := fmt.Sprintf("IC%s", time.Now().Format("20060102"))
+
+	var count int64
+	s.db.Unscoped().Model(&models.InventoryCount{}).
+		Where("count_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：将盘点单模型转换为响应结构体
+func (s *InventoryCountService) countToResponse(count *models.InventoryCount, withLines bool) *InventoryCountResponse {
+	resp := &InventoryCountResponse{
+		ID:           count.ID,
+		CountNo:      count.CountNo,
+		Locations:    []string{},
+		MaterialType: count.MaterialType,
+		Status:       count.Status,
+		Remark:       count.Remark,
+		CreatedBy:    count.CreatedBy,
+		CreatorName:  count.Creator.Username,
+		ApprovedBy:   count.ApprovedBy,
+		ApprovedAt:   count.ApprovedAt,
+		LineCount:    len(count.Lines),
+		CreatedAt:    count.CreatedAt,
+	}
+	if count.Locations != "" {
+		resp.Locations = strings.Split(count.Locations, ",")
+	}
+
+	for _, line := range count.Lines {
+		if line.CountedQuantity != nil {
+			resp.CountedCount++
+		}
+		if !withLines {
+			continue
+		}
+
+		lineResp := InventoryCountLineResponse{
+			ID:              line.ID,
+			MaterialID:      line.MaterialID,
+			MaterialCode:    line.Material.Code,
+			MaterialName:    line.Material.Name,
+			Location:        line.Material.Location,
+			Unit:            line.Material.Unit,
+			FrozenQuantity:  line.FrozenQuantity,
+			CurrentStock:    line.Material.CurrentStock,
+			CountedQuantity: line.CountedQuantity,
+			ReasonCode:      line.ReasonCode,
+			Remark:          line.Remark,
+			TransactionID:   line.TransactionID,
+		}
+		if line.CountedQuantity != nil {
+			// 盘点中以当前账面库存为基准，审核后账面已调整，以冻结时的库存为基准
+			base := line.Material.CurrentStock
+			if count.Status != "counting" {
+				base = line.FrozenQuantity
+			}
+			variance := roundQuantity(*line.CountedQuantity - base)
+			varianceValue := roundAmount(variance * materialUnitCost(&line.Material))
+			lineResp.Variance = &variance
+			lineResp.VarianceValue = &varianceValue
+		}
+		resp.Lines = append(resp.Lines, lineResp)
+	}
+
+	return resp
+}
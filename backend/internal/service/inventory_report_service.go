@@ -0,0 +1,274 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"mes-system/internal/models"
+)
+
+// StockAsOfResponse 指定日期库存响应结构体
+type StockAsOfResponse struct {
+	MaterialID   uint    `json:"material_id"`
+	MaterialCode string  `json:"material_code"`
+	MaterialName string  `json:"material_name"`
+	MaterialType string  `json:"material_type"`
+	Unit         string  `json:"unit"`
+	AsOf         string  `json:"as_of"`
+	Quantity     float64 `json:"quantity"`
+	UnitCost     float64 `json:"unit_cost"`
+	Value        float64 `json:"value"`
+}
+
+// StockMovementReport 物料收发存报表行
+type StockMovementReport struct {
+	MaterialID      uint    `json:"material_id"`
+	MaterialCode    string  `json:"material_code"`
+	MaterialName    string  `json:"material_name"`
+	MaterialType    string  `json:"material_type"`
+	Unit            string  `json:"unit"`
+	OpeningQuantity float64 `json:"opening_quantity"`
+	OpeningValue    float64 `json:"opening_value"`
+	ReceiptQuantity float64 `json:"receipt_quantity"`
+	ReceiptValue    float64 `json:"receipt_value"`
+	IssueQuantity   float64 `json:"issue_quantity"`
+	IssueValue      float64 `json:"issue_value"`
+	ClosingQuantity float64 `json:"closing_quantity"`
+	ClosingValue    float64 `json:"closing_value"`
+
+	// 按交易类型拆分的收发：入库、生产退料、盘盈调整、生产领用、退供应商、报废、盘亏调整
+	// 合计即为上面的入库和出库；净消耗为生产领用减生产退料
+	StockInQuantity          float64 `json:"stock_in_quantity"`
+	StockInValue             float64 `json:"stock_in_value"`
+	ProductionReturnQuantity float64 `json:"production_return_quantity"`
+	ProductionReturnValue    float64 `json:"production_return_value"`
+	AdjustInQuantity         float64 `json:"adjust_in_quantity"`
+	AdjustInValue            float64 `json:"adjust_in_value"`
+	ConsumptionQuantity      float64 `json:"consumption_quantity"`
+	ConsumptionValue         float64 `json:"consumption_value"`
+	SupplierReturnQuantity   float64 `json:"supplier_return_quantity"`
+	SupplierReturnValue      float64 `json:"supplier_return_value"`
+	ScrapQuantity            float64 `json:"scrap_quantity"`
+	ScrapValue               float64 `json:"scrap_value"`
+	AdjustOutQuantity        float64 `json:"adjust_out_quantity"`
+	AdjustOutValue           float64 `json:"adjust_out_value"`
+	NetConsumptionQuantity   float64 `json:"net_consumption_quantity"`
+	NetConsumptionValue      float64 `json:"net_consumption_value"`
+}
+
+// movementTotal 某一交易类型的收发数量和金额
+type movementTotal struct {
+	Quantity float64
+	Amount   float64
+}
+
+// materialMovementSum 物料在某一时间段内的收发汇总
+type materialMovementSum struct {
+	InQuantity  float64
+	InAmount    float64
+	OutQuantity float64
+	OutAmount   float64
+	byType      map[string]movementTotal
+}
+
+// 辅助函数：获取指定交易类型的收发汇总
+func (m materialMovementSum) typeTotal(transactionType string) movementTotal {
+	return m.byType[transactionType]
+}
+
+// InventoryReportService 库存报表服务
+type InventoryReportService struct {
+	db *gorm.DB
+}
+
+// NewInventoryReportService 创建库存报表服务实例
+func NewInventoryReportService(db *gorm.DB) *InventoryReportService {
+	return &InventoryReportService{db: db}
+}
+
+// GetStockAsOf 获取指定日期日终的物料库存
+func (s *InventoryReportService) GetStockAsOf(date time.Time, materialID uint, materialType string) ([]StockAsOfResponse, error) {
+	materials, err := s.findMaterials(materialID, materialType)
+	if err != nil {
+		return nil, err
+	}
+
+	// 以当前库存为基准，倒推扣除指定日期之后发生的交易
+	cutoff := endOfDay(date)
+	after, err := s.sumMovements(&cutoff, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]StockAsOfResponse, 0, len(materials))
+	for _, material := range materials {
+		sum := after[material.ID]
+		quantity := roundQuantity(material.CurrentStock - sum.InQuantity + sum.OutQuantity)
+		value := roundAmount(materialStockValue(&material) - sum.InAmount + sum.OutAmount)
+		responses = append(responses, StockAsOfResponse{
+			MaterialID:   material.ID,
+			MaterialCode: material.Code,
+			MaterialName: material.Name,
+			MaterialType: material.Type,
+			Unit:         material.Unit,
+			AsOf:         date.Format("2006-01-02"),
+			Quantity:     quantity,
+			UnitCost:     averageCost(value, quantity),
+			Value:        value,
+		})
+	}
+
+	return responses, nil
+}
+
+// GetMovementReport 获取物料收发存报表，起止日期均包含在内
+func (s *InventoryReportService) GetMovementReport(startDate, endDate time.Time, materialID uint, materialType string) ([]StockMovementReport, error) {
+	if endDate.Before(startDate) {
+		return nil, errors.New("结束日期不能早于开始日期")
+	}
+
+	materials, err := s.findMaterials(materialID, materialType)
+	if err != nil {
+		return nil, err
+	}
+
+	periodStart := startOfDay(startDate)
+	periodEnd := endOfDay(endDate)
+
+	// 期间内的收发汇总
+	period, err := s.sumMovements(&periodStart, &periodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// 期末之后的收发汇总，用于从当前库存倒推期末库存
+	after, err := s.sumMovements(&periodEnd, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]StockMovementReport, 0, len(materials))
+	for _, material := range materials {
+		p := period[material.ID]
+		a := after[material.ID]
+
+		closing := roundQuantity(material.CurrentStock - a.InQuantity + a.OutQuantity)
+		opening := roundQuantity(closing - p.InQuantity + p.OutQuantity)
+		closingValue := roundAmount(materialStockValue(&material) - a.InAmount + a.OutAmount)
+		openingValue := roundAmount(closingValue - p.InAmount + p.OutAmount)
+
+		consumption := p.typeTotal("out")
+		productionReturn := p.typeTotal("return_production")
+		reports = append(reports, StockMovementReport{
+			MaterialID:      material.ID,
+			MaterialCode:    material.Code,
+			MaterialName:    material.Name,
+			MaterialType:    material.Type,
+			Unit:            material.Unit,
+			OpeningQuantity: opening,
+			OpeningValue:    openingValue,
+			ReceiptQuantity: p.InQuantity,
+			ReceiptValue:    p.InAmount,
+			IssueQuantity:   p.OutQuantity,
+			IssueValue:      p.OutAmount,
+			ClosingQuantity: closing,
+			ClosingValue:    closingValue,
+
+			StockInQuantity:          p.typeTotal("in").Quantity,
+			StockInValue:             p.typeTotal("in").Amount,
+			ProductionReturnQuantity: productionReturn.Quantity,
+			ProductionReturnValue:    productionReturn.Amount,
+			AdjustInQuantity:         p.typeTotal("adjust_in").Quantity,
+			AdjustInValue:            p.typeTotal("adjust_in").Amount,
+			ConsumptionQuantity:      consumption.Quantity,
+			ConsumptionValue:         consumption.Amount,
+			SupplierReturnQuantity:   p.typeTotal("return_supplier").Quantity,
+			SupplierReturnValue:      p.typeTotal("return_supplier").Amount,
+			ScrapQuantity:            p.typeTotal("scrap").Quantity,
+			ScrapValue:               p.typeTotal("scrap").Amount,
+			AdjustOutQuantity:        p.typeTotal("adjust_out").Quantity,
+			AdjustOutValue:           p.typeTotal("adjust_out").Amount,
+			NetConsumptionQuantity:   roundQuantity(consumption.Quantity - productionReturn.Quantity),
+			NetConsumptionValue:      roundAmount(consumption.Amount - productionReturn.Amount),
+		})
+	}
+
+	return reports, nil
+}
+
+// 辅助函数：按条件查询物料
+func (s *InventoryReportService) findMaterials(materialID uint, materialType string) ([]models.Material, error) {
+	var materials []models.Material
+
+	query := s.db.Model(&models.Material{})
+	if materialID > 0 {
+		query = query.Where("id = ?", materialID)
+	}
+	if materialType != "" {
+		query = query.Where("type = ?", materialType)
+	}
+
+	if err := query.Order("code").Find(&materials).Error; err != nil {
+		return nil, fmt.Errorf("获取物料失败: %v", err)
+	}
+
+	return materials, nil
+}
+
+// 辅助函数：按物料和交易类型汇总 [from, to) 时间段内的收发数量和金额，to 为空表示至今
+func (s *InventoryReportService) sumMovements(from, to *time.Time) (map[uint]materialMovementSum, error) {
+	var rows []struct {
+		MaterialID uint
+		Type       string
+		Quantity   float64
+		Amount     float64
+	}
+
+	query := s.db.Model(&models.MaterialTransaction{}).
+		Select("material_id, type, " +
+			"COALESCE(SUM(quantity), 0) AS quantity, " +
+			"COALESCE(SUM(total_amount), 0) AS amount").
+		Group("material_id, type")
+
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("created_at < ?", *to)
+	}
+
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("汇总物料交易失败: %v", err)
+	}
+
+	result := make(map[uint]materialMovementSum)
+	for _, row := range rows {
+		sum, exists := result[row.MaterialID]
+		if !exists {
+			sum.byType = make(map[string]movementTotal)
+		}
+		if isInboundTransactionType(row.Type) {
+			sum.InQuantity = roundQuantity(sum.InQuantity + row.Quantity)
+			sum.InAmount = roundAmount(sum.InAmount + row.Amount)
+		} else {
+			sum.OutQuantity = roundQuantity(sum.OutQuantity + row.Quantity)
+			sum.OutAmount = roundAmount(sum.OutAmount + row.Amount)
+		}
+		sum.byType[row.Type] = movementTotal{Quantity: roundQuantity(row.Quantity), Amount: roundAmount(row.Amount)}
+		result[row.MaterialID] = sum
+	}
+
+	return result, nil
+}
+
+// 辅助函数：获取指定日期的零点
+func startOfDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+}
+
+// 辅助函数：获取指定日期的日终时间点（即次日零点）
+func endOfDay(date time.Time) time.Time {
+	return startOfDay(date).AddDate(0, 0, 1)
+}
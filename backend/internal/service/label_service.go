@@ -0,0 +1,1008 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"gorm.io/gorm"
+	"mes-system/internal/models"
+)
+
+// 条码内容前缀，扫码时据此识别对象类型
+const (
+	LabelPrefixMaterial        = "M:"
+	LabelPrefixLot             = "L:"
+	LabelPrefixProductionOrder = "PO:"
+	LabelPrefixEquipment       = "E:"
+)
+
+// maxLabelsPerPrint 单次打印的最大标签数（含份数）
+const maxLabelsPerPrint = 500
+
+// labelEntityPrefixes 各对象类型的条码内容前缀
+var labelEntityPrefixes = map[string]string{
+	"material":         LabelPrefixMaterial,
+	"lot":              LabelPrefixLot,
+	"production_order": LabelPrefixProductionOrder,
+	"equipment":        LabelPrefixEquipment,
+}
+
+// labelFields 各对象类型可打印的字段
+var labelFields = map[string]map[string]string{
+	"material": {
+		"code":     "物料编码",
+		"name":     "物料名称",
+		"type":     "物料类型",
+		"unit":     "单位",
+		"location": "库位",
+	},
+	"lot": {
+		"lot_no":        "批次号",
+		"material_code": "物料编码",
+		"material_name": "物料名称",
+		"quantity":      "数量",
+		"supplier":      "供应商",
+		"received_at":   "入库日期",
+		"package":       "包装",
+	},
+	"production_order": {
+		"order_no":     "工单号",
+		"product_code": "产品编码",
+		"product_name": "产品名称",
+		"quantity":     "计划数量",
+		"start_date":   "计划开始",
+		"end_date":     "计划完成",
+	},
+	"equipment": {
+		"code":         "设备编码",
+		"name":         "设备名称",
+		"model":        "型号",
+		"manufacturer": "制造商",
+		"location":     "位置",
+	},
+}
+
+// defaultLabelFields 未配置模板时各对象类型默认打印的字段
+var defaultLabelFields = map[string]string{
+	"material":         "code,name,unit,location",
+	"lot":              "lot_no,material_code,material_name,quantity,received_at",
+	"production_order": "order_no,product_name,quantity,end_date",
+	"equipment":        "code,name,model,location",
+}
+
+// LabelTemplateRequest 标签模板请求结构体
+type LabelTemplateRequest struct {
+	Code        string   `json:"code" binding:"required"`                   // 模板编码
+	Name        string   `json:"name" binding:"required"`                   // 模板名称
+	EntityType  string   `json:"entity_type" binding:"required"`            // 对象类型：material/lot/production_order/equipment
+	Symbology   string   `json:"symbology" binding:"required"`              // 条码类型：code128/qr
+	WidthMM     float64  `json:"width_mm" binding:"required,gt=0,lte=200"`  // 标签宽度（毫米）
+	HeightMM    float64  `json:"height_mm" binding:"required,gt=0,lte=200"` // 标签高度（毫米）
+	DPI         int      `json:"dpi"`                                       // 打印分辨率：203/300/600，默认203
+	Fields      []string `json:"fields"`                                    // 打印字段，按顺序打印，第一个字段为标题，为空时使用默认字段
+	ShowPayload *bool    `json:"show_payload"`                              // 是否打印条码内容，默认打印
+	IsDefault   bool     `json:"is_default"`                                // 是否设为该对象类型的默认模板
+	Description string   `json:"description"`                               // 描述
+}
+
+// LabelTemplateResponse 标签模板响应结构体
+type LabelTemplateResponse struct {
+	ID          uint      `json:"id"`
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	EntityType  string    `json:"entity_type"`
+	Symbology   string    `json:"symbology"`
+	WidthMM     float64   `json:"width_mm"`
+	HeightMM    float64   `json:"height_mm"`
+	DPI         int       `json:"dpi"`
+	Fields      []string  `json:"fields"`
+	ShowPayload bool      `json:"show_payload"`
+	IsDefault   bool      `json:"is_default"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// LabelPrintRequest 标签打印请求结构体
+type LabelPrintRequest struct {
+	EntityType      string  `json:"entity_type" binding:"required"`   // 对象类型：material/lot/production_order/equipment
+	Format          string  `json:"format" binding:"required"`        // 输出格式：png/pdf/zpl
+	IDs             []uint  `json:"ids"`                              // 物料、生产工单或设备ID，批次标签为入库交易ID
+	PurchaseOrderID *uint   `json:"purchase_order_id"`                // 批次标签：打印该采购订单全部收货批次
+	TemplateID      *uint   `json:"template_id"`                      // 标签模板ID，为空时使用该对象类型的默认模板
+	Copies          int     `json:"copies" binding:"min=0,max=100"`   // 每个标签的份数，默认1
+	PackageQuantity float64 `json:"package_quantity" binding:"min=0"` // 批次标签：按包装数量拆分，每包一张标签
+}
+
+// LabelDocument 生成的标签文件
+type LabelDocument struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// labelContent 单张标签的内容
+type labelContent struct {
+	Payload string            // 条码内容
+	Fields  map[string]string // 可打印字段的值
+}
+
+// labelTextLine 标签上的一行文字（单位：毫米）
+type labelTextLine struct {
+	Baseline float64
+	Size     float64
+}
+
+// labelLayout 标签版面（单位：毫米）
+type labelLayout struct {
+	BarcodeX, BarcodeY, BarcodeW, BarcodeH float64
+	TextX, TextW                           float64
+	Lines                                  []labelTextLine
+	Payload                                *labelTextLine // 一维码下方的条码内容，nil 表示不打印
+}
+
+// LabelService 标签服务
+type LabelService struct {
+	db       *gorm.DB
+	fontData []byte         // TrueType字体，为空时只能打印ASCII字符
+	font     *opentype.Font // 解析后的字体，用于PNG标签
+	zplFont  string         // 斑马打印机上的字体名称
+}
+
+// NewLabelService 创建标签服务实例，fontPath 为打印中文所需的TrueType字体
+func NewLabelService(db *gorm.DB, fontPath, zplFont string) *LabelService {
+	s := &LabelService{db: db, zplFont: zplFont}
+
+	if fontPath != "" {
+		data, err := os.ReadFile(fontPath)
+		if err == nil {
+			s.font, err = opentype.Parse(data)
+		}
+		if err != nil {
+			log.Printf("加载标签字体失败，标签上的中文将以?代替: %v", err)
+		} else {
+			s.fontData = data
+		}
+	}
+
+	return s
+}
+
+// CreateTemplate 创建标签模板
+func (s *LabelService) CreateTemplate(req *LabelTemplateRequest) (*LabelTemplateResponse, error) {
+	template := &models.LabelTemplate{}
+	if err := s.applyTemplateRequest(template, req); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaultLabelTemplate(tx, template); err != nil {
+			return err
+		}
+		if err := tx.Create(template).Error; err != nil {
+			return fmt.Errorf("创建标签模板失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.templateToResponse(template), nil
+}
+
+// GetTemplate 获取标签模板详情
+func (s *LabelService) GetTemplate(id uint) (*LabelTemplateResponse, error) {
+	var template models.LabelTemplate
+	if err := s.db.First(&template, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("标签模板不存在")
+		}
+		return nil, fmt.Errorf("获取标签模板失败: %v", err)
+	}
+
+	return s.templateToResponse(&template), nil
+}
+
+// GetTemplateList 获取标签模板列表
+func (s *LabelService) GetTemplateList(entityType string) ([]LabelTemplateResponse, error) {
+	var templates []models.LabelTemplate
+
+	query := s.db.Model(&models.LabelTemplate{})
+	if entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+
+	if err := query.Order("entity_type, code").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("获取标签模板列表失败: %v", err)
+	}
+
+	responses := make([]LabelTemplateResponse, 0, len(templates))
+	for _, template := range templates {
+		responses = append(responses, *s.templateToResponse(&template))
+	}
+
+	return responses, nil
+}
+
+// UpdateTemplate 更新标签模板
+func (s *LabelService) UpdateTemplate(id uint, req *LabelTemplateRequest) (*LabelTemplateResponse, error) {
+	var template models.LabelTemplate
+	if err := s.db.First(&template, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("标签模板不存在")
+		}
+		return nil, fmt.Errorf("获取标签模板失败: %v", err)
+	}
+
+	if err := s.applyTemplateRequest(&template, req); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaultLabelTemplate(tx, &template); err != nil {
+			return err
+		}
+		if err := tx.Save(&template).Error; err != nil {
+			return fmt.Errorf("更新标签模板失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.templateToResponse(&template), nil
+}
+
+// DeleteTemplate 删除标签模板
+func (s *LabelService) DeleteTemplate(id uint) error {
+	result := s.db.Delete(&models.LabelTemplate{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除标签模板失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("标签模板不存在")
+	}
+	return nil
+}
+
+// GetLabelFields 获取各对象类型可打印的字段
+func (s *LabelService) GetLabelFields() map[string]map[string]string {
+	return labelFields
+}
+
+// PrintLabels 按模板生成标签文件，支持PNG（多张时打包为zip）、PDF（每页一张）和ZPL
+func (s *LabelService) PrintLabels(req *LabelPrintRequest) (*LabelDocument, error) {
+	if _, exists := labelFields[req.EntityType]; !exists {
+		return nil, fmt.Errorf("无效的标签对象类型: %s", req.EntityType)
+	}
+	if req.Format != "png" && req.Format != "pdf" && req.Format != "zpl" {
+		return nil, errors.New("输出格式必须是 png、pdf 或 zpl")
+	}
+
+	template, err := s.resolveTemplate(req.TemplateID, req.EntityType)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := s.loadLabelContents(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(contents) == 0 {
+		return nil, errors.New("没有可打印的标签")
+	}
+
+	copies := req.Copies
+	if copies <= 0 {
+		copies = 1
+	}
+	if len(contents)*copies > maxLabelsPerPrint {
+		return nil, fmt.Errorf("单次最多打印 %d 张标签", maxLabelsPerPrint)
+	}
+
+	filename := fmt.Sprintf("labels_%s_%s", req.EntityType, time.Now().Format("20060102150405"))
+	switch req.Format {
+	case "zpl":
+		data, err := s.renderZPL(template, contents, copies)
+		if err != nil {
+			return nil, err
+		}
+		return &LabelDocument{Filename: filename + ".zpl", ContentType: "text/plain; charset=utf-8", Data: data}, nil
+	case "pdf":
+		data, err := s.renderPDF(template, repeatLabelContents(contents, copies))
+		if err != nil {
+			return nil, err
+		}
+		return &LabelDocument{Filename: filename + ".pdf", ContentType: "application/pdf", Data: data}, nil
+	default:
+		labels := repeatLabelContents(contents, copies)
+		if len(labels) == 1 {
+			data, err := s.renderPNG(template, labels[0])
+			if err != nil {
+				return nil, err
+			}
+			return &LabelDocument{Filename: filename + ".png", ContentType: "image/png", Data: data}, nil
+		}
+		data, err := s.renderPNGArchive(template, labels)
+		if err != nil {
+			return nil, err
+		}
+		return &LabelDocument{Filename: filename + ".zip", ContentType: "application/zip", Data: data}, nil
+	}
+}
+
+// 辅助函数：校验模板请求并写入模型
+func (s *LabelService) applyTemplateRequest(template *models.LabelTemplate, req *LabelTemplateRequest) error {
+	fields, exists := labelFields[req.EntityType]
+	if !exists {
+		return fmt.Errorf("无效的标签对象类型: %s", req.EntityType)
+	}
+	if req.Symbology != "code128" && req.Symbology != "qr" {
+		return errors.New("条码类型必须是 code128 或 qr")
+	}
+
+	dpi := req.DPI
+	if dpi == 0 {
+		dpi = 203
+	}
+	if dpi != 203 && dpi != 300 && dpi != 600 {
+		return errors.New("打印分辨率必须是 203、300 或 600")
+	}
+
+	selected := req.Fields
+	if len(selected) == 0 {
+		selected = splitLabelFields(defaultLabelFields[req.EntityType])
+	}
+	for _, field := range selected {
+		if _, valid := fields[field]; !valid {
+			return fmt.Errorf("对象类型 %s 没有字段: %s", req.EntityType, field)
+		}
+	}
+
+	var count int64
+	s.db.Model(&models.LabelTemplate{}).Where("code = ? AND id != ?", req.Code, template.ID).Count(&count)
+	if count > 0 {
+		return errors.New("标签模板编码已存在")
+	}
+
+	showPayload := true
+	if req.ShowPayload != nil {
+		showPayload = *req.ShowPayload
+	}
+
+	template.Code = req.Code
+	template.Name = req.Name
+	template.EntityType = req.EntityType
+	template.Symbology = req.Symbology
+	template.WidthMM = req.WidthMM
+	template.HeightMM = req.HeightMM
+	template.DPI = dpi
+	template.Fields = strings.Join(selected, ",")
+	template.ShowPayload = showPayload
+	template.IsDefault = req.IsDefault
+	template.Description = req.Description
+	return nil
+}
+
+// 辅助函数：设为默认模板时取消同一对象类型的其他默认模板
+func clearDefaultLabelTemplate(tx *gorm.DB, template *models.LabelTemplate) error {
+	if !template.IsDefault {
+		return nil
+	}
+	if err := tx.Model(&models.LabelTemplate{}).
+		Where("entity_type = ? AND is_default = ? AND id != ?", template.EntityType, true, template.ID).
+		Update("is_default", false).Error; err != nil {
+		return fmt.Errorf("更新默认标签模板失败: %v", err)
+	}
+	return nil
+}
+
+// 辅助函数：确定打印使用的模板，未指定时依次使用默认模板和内置模板
+func (s *LabelService) resolveTemplate(templateID *uint, entityType string) (*models.LabelTemplate, error) {
+	var template models.LabelTemplate
+
+	if templateID != nil {
+		if err := s.db.First(&template, *templateID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("标签模板不存在")
+			}
+			return nil, fmt.Errorf("获取标签模板失败: %v", err)
+		}
+		if template.EntityType != entityType {
+			return nil, fmt.Errorf("标签模板 %s 不适用于对象类型 %s", template.Code, entityType)
+		}
+		return &template, nil
+	}
+
+	err := s.db.Where("entity_type = ? AND is_default = ?", entityType, true).First(&template).Error
+	if err == nil {
+		return &template, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("获取默认标签模板失败: %v", err)
+	}
+
+	// 内置模板：60x40mm 一维码
+	return &models.LabelTemplate{
+		Code:        "default",
+		Name:        "默认模板",
+		EntityType:  entityType,
+		Symbology:   "code128",
+		WidthMM:     60,
+		HeightMM:    40,
+		DPI:         203,
+		Fields:      defaultLabelFields[entityType],
+		ShowPayload: true,
+	}, nil
+}
+
+// 辅助函数：按对象类型加载标签内容
+func (s *LabelService) loadLabelContents(req *LabelPrintRequest) ([]labelContent, error) {
+	if req.EntityType != "lot" && len(req.IDs) == 0 {
+		return nil, errors.New("请指定要打印标签的对象")
+	}
+
+	prefix := labelEntityPrefixes[req.EntityType]
+	var contents []labelContent
+
+	switch req.EntityType {
+	case "material":
+		var materials []models.Material
+		if err := s.db.Where("id IN ?", req.IDs).Order("code").Find(&materials).Error; err != nil {
+			return nil, fmt.Errorf("获取物料失败: %v", err)
+		}
+		if len(materials) != len(uniqueIDs(req.IDs)) {
+			return nil, errors.New("部分物料不存在")
+		}
+		for _, material := range materials {
+			contents = append(contents, labelContent{
+				Payload: prefix + material.Code,
+				Fields: map[string]string{
+					"code":     material.Code,
+					"name":     material.Name,
+					"type":     material.Type,
+					"unit":     material.Unit,
+					"location": material.Location,
+				},
+			})
+		}
+
+	case "production_order":
+		var orders []models.ProductionOrder
+		if err := s.db.Preload("Product").Where("id IN ?", req.IDs).Order("order_no").Find(&orders).Error; err != nil {
+			return nil, fmt.Errorf("获取生产工单失败: %v", err)
+		}
+		if len(orders) != len(uniqueIDs(req.IDs)) {
+			return nil, errors.New("部分生产工单不存在")
+		}
+		for _, order := range orders {
+			contents = append(contents, labelContent{
+				Payload: prefix + order.OrderNo,
+				Fields: map[string]string{
+					"order_no":     order.OrderNo,
+					"product_code": order.Product.Code,
+					"product_name": order.Product.Name,
+					"quantity":     fmt.Sprintf("%v %s", order.Quantity, order.Product.Unit),
+					"start_date":   formatLabelDate(order.StartDate),
+					"end_date":     formatLabelDate(order.EndDate),
+				},
+			})
+		}
+
+	case "equipment":
+		var equipments []models.Equipment
+		if err := s.db.Where("id IN ?", req.IDs).Order("code").Find(&equipments).Error; err != nil {
+			return nil, fmt.Errorf("获取设备失败: %v", err)
+		}
+		if len(equipments) != len(uniqueIDs(req.IDs)) {
+			return nil, errors.New("部分设备不存在")
+		}
+		for _, equipment := range equipments {
+			contents = append(contents, labelContent{
+				Payload: prefix + equipment.Code,
+				Fields: map[string]string{
+					"code":         equipment.Code,
+					"name":         equipment.Name,
+					"model":        equipment.Model,
+					"manufacturer": equipment.Manufacturer,
+					"location":     equipment.Location,
+				},
+			})
+		}
+
+	case "lot":
+		return s.loadLotLabelContents(req)
+	}
+
+	return contents, nil
+}
+
+// 辅助函数：加载批次标签内容，可按入库交易或采购订单批量打印，并按包装数量拆分
+func (s *LabelService) loadLotLabelContents(req *LabelPrintRequest) ([]labelContent, error) {
+	query := s.db.Preload("Material").
+		Where("material_transactions.type = ? AND material_transactions.lot_no <> ''", "in")
+
+	switch {
+	case req.PurchaseOrderID != nil:
+		query = query.Joins("JOIN purchase_order_lines ON purchase_order_lines.id = material_transactions.purchase_order_line_id").
+			Where("purchase_order_lines.purchase_order_id = ?", *req.PurchaseOrderID)
+	case len(req.IDs) > 0:
+		query = query.Where("material_transactions.id IN ?", req.IDs)
+	default:
+		return nil, errors.New("批次标签请指定入库交易或采购订单")
+	}
+
+	var transactions []models.MaterialTransaction
+	if err := query.Order("material_transactions.id").Find(&transactions).Error; err != nil {
+		return nil, fmt.Errorf("获取入库批次失败: %v", err)
+	}
+	if req.PurchaseOrderID == nil && len(transactions) != len(uniqueIDs(req.IDs)) {
+		return nil, errors.New("部分交易不存在或不是带批次号的入库交易")
+	}
+
+	var contents []labelContent
+	for _, transaction := range transactions {
+		if transaction.Quantity <= 0 {
+			continue
+		}
+
+		// 按包装数量拆分，最后一包为余数
+		packages := []float64{transaction.Quantity}
+		if req.PackageQuantity > 0 {
+			count := int(math.Ceil(roundQuantity(transaction.Quantity / req.PackageQuantity)))
+			if count > maxLabelsPerPrint {
+				return nil, fmt.Errorf("批次 %s 按包装数量拆分后超过 %d 张标签", transaction.LotNo, maxLabelsPerPrint)
+			}
+			packages = make([]float64, 0, count)
+			remaining := transaction.Quantity
+			for i := 0; i < count; i++ {
+				packages = append(packages, math.Min(remaining, req.PackageQuantity))
+				remaining = roundQuantity(remaining - req.PackageQuantity)
+			}
+		}
+
+		for i, quantity := range packages {
+			contents = append(contents, labelContent{
+				Payload: LabelPrefixLot + transaction.LotNo,
+				Fields: map[string]string{
+					"lot_no":        transaction.LotNo,
+					"material_code": transaction.Material.Code,
+					"material_name": transaction.Material.Name,
+					"quantity":      fmt.Sprintf("%v %s", quantity, transaction.Material.Unit),
+					"supplier":      transaction.Supplier,
+					"received_at":   transaction.CreatedAt.Format("2006-01-02"),
+					"package":       fmt.Sprintf("%d/%d", i+1, len(packages)),
+				},
+			})
+		}
+	}
+
+	return contents, nil
+}
+
+// 辅助函数：按模板字段生成标签文字，第一行为标题，withNames 为 false 时（无中文字体）不打印字段名称
+func labelTextLines(template *models.LabelTemplate, content labelContent, withNames bool) []string {
+	names := labelFields[template.EntityType]
+
+	var lines []string
+	for _, field := range splitLabelFields(template.Fields) {
+		value := content.Fields[field]
+		if value == "" {
+			continue
+		}
+		if len(lines) == 0 || !withNames {
+			lines = append(lines, value)
+		} else {
+			lines = append(lines, names[field]+"："+value)
+		}
+	}
+
+	// 二维码旁没有空间单独打印条码内容，作为最后一行文字
+	if template.Symbology == "qr" && template.ShowPayload {
+		lines = append(lines, content.Payload)
+	}
+
+	return lines
+}
+
+// 辅助函数：计算标签版面，二维码在左侧、文字在右侧；一维码在下方、文字在上方，放不下的文字行不打印
+func computeLabelLayout(template *models.LabelTemplate, lineCount int) labelLayout {
+	const titleSize, lineSize, payloadSize, lineGap = 4.0, 3.0, 2.5, 0.8
+
+	width, height := template.WidthMM, template.HeightMM
+	margin := math.Min(2, math.Min(width, height)*0.05)
+
+	var layout labelLayout
+	var textBottom float64
+	if template.Symbology == "qr" {
+		size := math.Min(height-2*margin, width*0.45)
+		layout.BarcodeX, layout.BarcodeY = margin, (height-size)/2
+		layout.BarcodeW, layout.BarcodeH = size, size
+		layout.TextX = 2*margin + size
+		textBottom = height - margin
+	} else {
+		bottom := height - margin
+		if template.ShowPayload {
+			layout.Payload = &labelTextLine{Baseline: bottom - payloadSize*0.2, Size: payloadSize}
+			bottom -= payloadSize + 0.5
+		}
+		barcodeHeight := math.Min(math.Max(height*0.35, 8), bottom-margin)
+		layout.BarcodeX, layout.BarcodeY = margin, bottom-barcodeHeight
+		layout.BarcodeW, layout.BarcodeH = width-2*margin, barcodeHeight
+		layout.TextX = margin
+		textBottom = layout.BarcodeY - 1
+	}
+	layout.TextW = width - layout.TextX - margin
+
+	y := margin
+	for i := 0; i < lineCount; i++ {
+		size := lineSize
+		if i == 0 {
+			size = titleSize
+		}
+		if y+size > textBottom {
+			break
+		}
+		y += size
+		layout.Lines = append(layout.Lines, labelTextLine{Baseline: y - size*0.2, Size: size})
+		y += lineGap
+	}
+
+	return layout
+}
+
+// 辅助函数：生成条码
+func encodeLabelBarcode(symbology, payload string) (barcode.Barcode, error) {
+	if symbology == "qr" {
+		return qr.Encode(payload, qr.M, qr.Auto)
+	}
+	return code128.Encode(payload)
+}
+
+// 辅助函数：生成PNG标签
+func (s *LabelService) renderPNG(template *models.LabelTemplate, content labelContent) ([]byte, error) {
+	pxPerMM := float64(template.DPI) / 25.4
+	px := func(mm float64) int { return int(math.Round(mm * pxPerMM)) }
+
+	lines := labelTextLines(template, content, s.fontData != nil)
+	layout := computeLabelLayout(template, len(lines))
+
+	img := image.NewRGBA(image.Rect(0, 0, px(template.WidthMM), px(template.HeightMM)))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	code, err := encodeLabelBarcode(template.Symbology, content.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("生成条码失败: %v", err)
+	}
+	scaled, err := barcode.Scale(code, px(layout.BarcodeW), px(layout.BarcodeH))
+	if err != nil {
+		return nil, fmt.Errorf("标签尺寸不足以打印条码 %s: %v", content.Payload, err)
+	}
+	draw.Draw(img, image.Rect(px(layout.BarcodeX), px(layout.BarcodeY), px(layout.BarcodeX+layout.BarcodeW), px(layout.BarcodeY+layout.BarcodeH)),
+		scaled, scaled.Bounds().Min, draw.Src)
+
+	drawText := func(text string, x, baseline, size, maxWidth float64, center bool) error {
+		face, err := s.pngFace(size, template.DPI)
+		if err != nil {
+			return err
+		}
+		drawer := &font.Drawer{Dst: img, Src: image.Black, Face: face}
+		measure := func(str string) float64 { return float64(drawer.MeasureString(str)) / 64 }
+
+		text = fitLabelText(s.printableText(text), float64(px(maxWidth)), measure)
+		left := float64(px(x))
+		if center {
+			left += (float64(px(maxWidth)) - measure(text)) / 2
+		}
+		drawer.Dot = fixed.P(int(left), px(baseline))
+		drawer.DrawString(text)
+		return nil
+	}
+
+	for i, line := range layout.Lines {
+		if err := drawText(lines[i], layout.TextX, line.Baseline, line.Size, layout.TextW, false); err != nil {
+			return nil, err
+		}
+	}
+	if layout.Payload != nil {
+		if err := drawText(content.Payload, layout.BarcodeX, layout.Payload.Baseline, layout.Payload.Size, layout.BarcodeW, true); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("生成PNG失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// 辅助函数：将多张PNG标签打包为zip
+func (s *LabelService) renderPNGArchive(template *models.LabelTemplate, contents []labelContent) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	for i, content := range contents {
+		data, err := s.renderPNG(template, content)
+		if err != nil {
+			return nil, err
+		}
+		writer, err := archive.Create(fmt.Sprintf("label_%04d.png", i+1))
+		if err != nil {
+			return nil, fmt.Errorf("生成压缩包失败: %v", err)
+		}
+		if _, err := writer.Write(data); err != nil {
+			return nil, fmt.Errorf("生成压缩包失败: %v", err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("生成压缩包失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// 辅助函数：获取PNG标签的字体，字号为毫米
+func (s *LabelService) pngFace(sizeMM float64, dpi int) (font.Face, error) {
+	if s.font == nil {
+		return basicfont.Face7x13, nil
+	}
+	face, err := opentype.NewFace(s.font, &opentype.FaceOptions{
+		Size:    sizeMM / 25.4 * 72,
+		DPI:     float64(dpi),
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("加载标签字体失败: %v", err)
+	}
+	return face, nil
+}
+
+// 辅助函数：生成PDF标签，每页一张，页面尺寸即标签尺寸
+func (s *LabelService) renderPDF(template *models.LabelTemplate, contents []labelContent) ([]byte, error) {
+	size := gofpdf.SizeType{Wd: template.WidthMM, Ht: template.HeightMM}
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{UnitStr: "mm", Size: size})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+
+	family := "Helvetica"
+	if s.fontData != nil {
+		pdf.AddUTF8FontFromBytes("label", "", s.fontData)
+		family = "label"
+	}
+
+	for i, content := range contents {
+		pdf.AddPageFormat("P", size)
+
+		lines := labelTextLines(template, content, s.fontData != nil)
+		layout := computeLabelLayout(template, len(lines))
+
+		// 条码按模块整数倍放大后嵌入，由PDF缩放到版面尺寸
+		code, err := encodeLabelBarcode(template.Symbology, content.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("生成条码失败: %v", err)
+		}
+		width, height := code.Bounds().Dx()*4, code.Bounds().Dy()*4
+		if template.Symbology == "code128" {
+			height = 200
+		}
+		scaled, err := barcode.Scale(code, width, height)
+		if err != nil {
+			return nil, fmt.Errorf("生成条码失败: %v", err)
+		}
+		// 条码图像为16位灰度，PDF不支持，转换为8位灰度后嵌入
+		gray := image.NewGray(scaled.Bounds())
+		draw.Draw(gray, gray.Bounds(), scaled, scaled.Bounds().Min, draw.Src)
+		var img bytes.Buffer
+		if err := png.Encode(&img, gray); err != nil {
+			return nil, fmt.Errorf("生成条码失败: %v", err)
+		}
+		name := fmt.Sprintf("barcode_%d", i)
+		options := gofpdf.ImageOptions{ImageType: "PNG"}
+		pdf.RegisterImageOptionsReader(name, options, &img)
+		pdf.ImageOptions(name, layout.BarcodeX, layout.BarcodeY, layout.BarcodeW, layout.BarcodeH, false, options, 0, "")
+
+		drawText := func(text string, x, baseline, sizeMM, maxWidth float64, center bool) {
+			pdf.SetFont(family, "", sizeMM/25.4*72)
+			text = fitLabelText(s.printableText(text), maxWidth, pdf.GetStringWidth)
+			if center {
+				x += (maxWidth - pdf.GetStringWidth(text)) / 2
+			}
+			pdf.Text(x, baseline, text)
+		}
+
+		for j, line := range layout.Lines {
+			drawText(lines[j], layout.TextX, line.Baseline, line.Size, layout.TextW, false)
+		}
+		if layout.Payload != nil {
+			drawText(content.Payload, layout.BarcodeX, layout.Payload.Baseline, layout.Payload.Size, layout.BarcodeW, true)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("生成PDF失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// 辅助函数：生成斑马打印机ZPL指令，份数由打印机按 ^PQ 重复打印
+func (s *LabelService) renderZPL(template *models.LabelTemplate, contents []labelContent, copies int) ([]byte, error) {
+	dotsPerMM := float64(template.DPI) / 25.4
+	dots := func(mm float64) int { return int(math.Round(mm * dotsPerMM)) }
+
+	fontCommand := func(sizeMM float64) string {
+		if s.zplFont != "" {
+			return fmt.Sprintf("^A@N,%d,%d,%s", dots(sizeMM), dots(sizeMM), s.zplFont)
+		}
+		return fmt.Sprintf("^A0N,%d,%d", dots(sizeMM), dots(sizeMM))
+	}
+
+	var b strings.Builder
+	for _, content := range contents {
+		lines := labelTextLines(template, content, s.zplFont != "")
+		layout := computeLabelLayout(template, len(lines))
+
+		code, err := encodeLabelBarcode(template.Symbology, content.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("生成条码失败: %v", err)
+		}
+		modules := code.Bounds().Dx()
+
+		b.WriteString("^XA\n^CI28\n")
+		fmt.Fprintf(&b, "^PW%d\n^LL%d\n", dots(template.WidthMM), dots(template.HeightMM))
+
+		if template.Symbology == "qr" {
+			magnification := clampInt(dots(layout.BarcodeW)/modules, 1, 10)
+			fmt.Fprintf(&b, "^FO%d,%d^BQN,2,%d^FH^FDMA,%s^FS\n",
+				dots(layout.BarcodeX), dots(layout.BarcodeY), magnification, zplEscape(content.Payload))
+		} else {
+			moduleWidth := clampInt(dots(layout.BarcodeW)/modules, 1, 10)
+			x := dots(layout.BarcodeX) + (dots(layout.BarcodeW)-moduleWidth*modules)/2
+			fmt.Fprintf(&b, "^FO%d,%d^BY%d^BCN,%d,N,N,N,A^FH^FD%s^FS\n",
+				x, dots(layout.BarcodeY), moduleWidth, dots(layout.BarcodeH), zplEscape(content.Payload))
+		}
+
+		// ^FO 为文字框左上角，^FB 限制为单行避免超出标签
+		for i, line := range layout.Lines {
+			fmt.Fprintf(&b, "^FO%d,%d%s^FB%d,1,0,L^FH^FD%s^FS\n",
+				dots(layout.TextX), dots(line.Baseline-line.Size*0.8), fontCommand(line.Size), dots(layout.TextW), zplEscape(lines[i]))
+		}
+		if layout.Payload != nil {
+			fmt.Fprintf(&b, "^FO%d,%d%s^FB%d,1,0,C^FH^FD%s^FS\n",
+				dots(layout.BarcodeX), dots(layout.Payload.Baseline-layout.Payload.Size*0.8), fontCommand(layout.Payload.Size),
+				dots(layout.BarcodeW), zplEscape(content.Payload))
+		}
+
+		fmt.Fprintf(&b, "^PQ%d\n^XZ\n", copies)
+	}
+
+	return []byte(b.String()), nil
+}
+
+// 辅助函数：没有中文字体时将非ASCII字符替换为?
+func (s *LabelService) printableText(text string) string {
+	if s.fontData != nil {
+		return text
+	}
+	return strings.Map(func(r rune) rune {
+		if r > 126 {
+			return '?'
+		}
+		return r
+	}, text)
+}
+
+// 辅助函数：文字超出宽度时截断并以...结尾
+func fitLabelText(text string, maxWidth float64, measure func(string) float64) string {
+	if measure(text) <= maxWidth {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := string(runes) + "..."
+		if measure(candidate) <= maxWidth {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// 辅助函数：转义ZPL字段数据中的控制字符，配合 ^FH 使用
+func zplEscape(text string) string {
+	return strings.NewReplacer("_", "_5F", "^", "_5E", "~", "_7E").Replace(text)
+}
+
+// 辅助函数：按份数重复标签
+func repeatLabelContents(contents []labelContent, copies int) []labelContent {
+	if copies <= 1 {
+		return contents
+	}
+	repeated := make([]labelContent, 0, len(contents)*copies)
+	for _, content := range contents {
+		for i := 0; i < copies; i++ {
+			repeated = append(repeated, content)
+		}
+	}
+	return repeated
+}
+
+// 辅助函数：拆分模板字段
+func splitLabelFields(fields string) []string {
+	var result []string
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// 辅助函数：格式化标签上的日期
+func formatLabelDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format("2006-01-02")
+}
+
+// 辅助函数：去除重复ID
+func uniqueIDs(ids []uint) map[uint]bool {
+	unique := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	return unique
+}
+
+// 辅助函数：将整数限制在区间内
+func clampInt(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
+
+// 辅助函数：转换为响应结构体
+func (s *LabelService) templateToResponse(template *models.LabelTemplate) *LabelTemplateResponse {
+	return &LabelTemplateResponse{
+		ID:          template.ID,
+		Code:        template.Code,
+		Name:        template.Name,
+		EntityType:  template.EntityType,
+		Symbology:   template.Symbology,
+		WidthMM:     template.WidthMM,
+		HeightMM:    template.HeightMM,
+		DPI:         template.DPI,
+		Fields:      splitLabelFields(template.Fields),
+		ShowPayload: template.ShowPayload,
+		IsDefault:   template.IsDefault,
+		Description: template.Description,
+		CreatedAt:   template.CreatedAt,
+		UpdatedAt:   template.UpdatedAt,
+	}
+}
@@ -0,0 +1,455 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mes-system/internal/models"
+)
+
+// ReasonCodeQualityHold 冻结物料报废的原因代码
+const ReasonCodeQualityHold = "quality_hold"
+
+// MaterialHoldRequest 创建物料冻结请求结构体
+type MaterialHoldRequest struct {
+	MaterialID uint    `json:"material_id" binding:"required"` // 物料ID
+	Quantity   float64 `json:"quantity" binding:"min=0"`       // 冻结数量，0 表示整体冻结该物料
+	Reason     string  `json:"reason" binding:"required"`      // 冻结原因
+	OwnerID    uint    `json:"owner_id"`                       // 责任人，默认为当前用户
+	Remark     string  `json:"remark"`                         // 备注
+}
+
+// MaterialHoldDispositionRequest 物料冻结处置请求结构体
+type MaterialHoldDispositionRequest struct {
+	Quantity float64 `json:"quantity" binding:"min=0"` // 处置数量，0 表示全部剩余冻结数量
+	Remark   string  `json:"remark"`                   // 备注
+}
+
+// MaterialHoldLogResponse 物料冻结操作记录响应结构体
+type MaterialHoldLogResponse struct {
+	ID            uint      `json:"id"`
+	Action        string    `json:"action"`
+	Quantity      float64   `json:"quantity"`
+	TransactionID *uint     `json:"transaction_id"`
+	OperatorID    uint      `json:"operator_id"`
+	OperatorName  string    `json:"operator_name"`
+	Remark        string    `json:"remark"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// MaterialHoldResponse 物料冻结响应结构体
+type MaterialHoldResponse struct {
+	ID                uint                      `json:"id"`
+	HoldNo            string                    `json:"hold_no"`
+	MaterialID        uint                      `json:"material_id"`
+	MaterialCode      string                    `json:"material_code"`
+	MaterialName      string                    `json:"material_name"`
+	WholeMaterial     bool                      `json:"whole_material"`
+	Quantity          float64                   `json:"quantity"`
+	ReleasedQuantity  float64                   `json:"released_quantity"`
+	ScrappedQuantity  float64                   `json:"scrapped_quantity"`
+	RemainingQuantity float64                   `json:"remaining_quantity"`
+	Reason            string                    `json:"reason"`
+	OwnerID           uint                      `json:"owner_id"`
+	OwnerName         string                    `json:"owner_name"`
+	Status            string                    `json:"status"`
+	CreatedBy         uint                      `json:"created_by"`
+	CreatorName       string                    `json:"creator_name"`
+	ClosedAt          *time.Time                `json:"closed_at"`
+	Logs              []MaterialHoldLogResponse `json:"logs,omitempty"`
+	CreatedAt         time.Time                 `json:"created_at"`
+}
+
+// MaterialHoldService 物料冻结服务
+type MaterialHoldService struct {
+	db *gorm.DB
+}
+
+// NewMaterialHoldService 创建物料冻结服务实例
+func NewMaterialHoldService(db *gorm.DB) *MaterialHoldService {
+	return &MaterialHoldService{db: db}
+}
+
+// CreateHold 冻结物料：指定数量时冻结部分非限制库存，数量为0时整体冻结该物料
+func (s *MaterialHoldService) CreateHold(req *MaterialHoldRequest, createdBy uint) (*MaterialHoldResponse, error) {
+	quantity := roundQuantity(req.Quantity)
+	ownerID := req.OwnerID
+	if ownerID == 0 {
+		ownerID = createdBy
+	}
+
+	var owner models.User
+	if err := s.db.First(&owner, ownerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("责任人不存在")
+		}
+		return nil, fmt.Errorf("验证责任人失败: %v", err)
+	}
+
+	var hold *models.MaterialHold
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var material models.Material
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&material, req.MaterialID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("物料不存在")
+			}
+			return fmt.Errorf("获取物料失败: %v", err)
+		}
+
+		if quantity > 0 {
+			if available := issuableStock(&material); available < quantity {
+				return fmt.Errorf("冻结数量超过非限制库存 %v", available)
+			}
+			material.BlockedStock = roundQuantity(material.BlockedStock + quantity)
+		} else {
+			material.OnHold = true
+		}
+
+		if err := tx.Model(&material).Updates(map[string]interface{}{
+			"blocked_stock": material.BlockedStock,
+			"on_hold":       material.OnHold,
+		}).Error; err != nil {
+			return fmt.Errorf("更新冻结库存失败: %v", err)
+		}
+
+		hold = &models.MaterialHold{
+			HoldNo:     generateHoldNo(tx),
+			MaterialID: material.ID,
+			Quantity:   quantity,
+			Reason:     req.Reason,
+			OwnerID:    ownerID,
+			Status:     "active",
+			CreatedBy:  createdBy,
+		}
+		if err := tx.Create(hold).Error; err != nil {
+			return fmt.Errorf("创建物料冻结失败: %v", err)
+		}
+
+		return writeHoldLog(tx, hold.ID, "hold", quantity, nil, createdBy, req.Remark)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetHold(hold.ID)
+}
+
+// GetHold 获取物料冻结详情
+func (s *MaterialHoldService) GetHold(id uint) (*MaterialHoldResponse, error) {
+	var hold models.MaterialHold
+	err := s.db.Preload("Material").Preload("Owner").Preload("Creator").
+		Preload("Logs", func(db *gorm.DB) *gorm.DB { return db.Order("created_at, id") }).
+		Preload("Logs.Operator").
+		First(&hold, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("物料冻结不存在")
+		}
+		return nil, fmt.Errorf("获取物料冻结失败: %v", err)
+	}
+
+	return s.holdToResponse(&hold, true), nil
+}
+
+// GetHoldList 获取物料冻结列表
+func (s *MaterialHoldService) GetHoldList(page, pageSize int, status string, materialID uint) ([]MaterialHoldResponse, int64, error) {
+	var holds []models.MaterialHold
+	var total int64
+
+	query := s.db.Model(&models.MaterialHold{})
+
+	// 按状态筛选
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 按物料筛选
+	if materialID > 0 {
+		query = query.Where("material_id = ?", materialID)
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取物料冻结总数失败: %v", err)
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Material").Preload("Owner").Preload("Creator").
+		Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&holds).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取物料冻结列表失败: %v", err)
+	}
+
+	var responses []MaterialHoldResponse
+	for _, hold := range holds {
+		responses = append(responses, *s.holdToResponse(&hold, false))
+	}
+
+	return responses, total, nil
+}
+
+// ReleaseHold 解除物料冻结，部分冻结可按数量分次解除
+func (s *MaterialHoldService) ReleaseHold(id uint, req *MaterialHoldDispositionRequest, operatorID uint) (*MaterialHoldResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		hold, material, err := lockActiveHold(tx, id)
+		if err != nil {
+			return err
+		}
+
+		// 整体冻结一次性解除
+		if hold.Quantity == 0 {
+			if err := tx.Model(hold).Update("status", "released").Error; err != nil {
+				return fmt.Errorf("更新物料冻结失败: %v", err)
+			}
+			if err := closeHold(tx, hold); err != nil {
+				return err
+			}
+			if err := refreshMaterialOnHold(tx, material); err != nil {
+				return err
+			}
+			return writeHoldLog(tx, hold.ID, "release", 0, nil, operatorID, req.Remark)
+		}
+
+		quantity, err := dispositionQuantity(hold, roundQuantity(req.Quantity))
+		if err != nil {
+			return err
+		}
+
+		if err := releaseBlockedStock(tx, material, quantity); err != nil {
+			return err
+		}
+
+		hold.ReleasedQuantity = roundQuantity(hold.ReleasedQuantity + quantity)
+		if err := tx.Model(hold).Update("released_quantity", hold.ReleasedQuantity).Error; err != nil {
+			return fmt.Errorf("更新物料冻结失败: %v", err)
+		}
+		if err := finishHoldIfDisposed(tx, hold); err != nil {
+			return err
+		}
+
+		return writeHoldLog(tx, hold.ID, "release", quantity, nil, operatorID, req.Remark)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetHold(id)
+}
+
+// ScrapHold 报废冻结物料，生成报废出库交易
+func (s *MaterialHoldService) ScrapHold(id uint, req *MaterialHoldDispositionRequest, operatorID uint) (*MaterialHoldResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		hold, _, err := lockActiveHold(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if hold.Quantity == 0 {
+			return errors.New("整体冻结不能直接报废，请按数量冻结后再报废")
+		}
+
+		quantity, err := dispositionQuantity(hold, roundQuantity(req.Quantity))
+		if err != nil {
+			return err
+		}
+
+		remark := fmt.Sprintf("物料冻结 %s 报废", hold.HoldNo)
+		if req.Remark != "" {
+			remark = remark + "：" + req.Remark
+		}
+		scrap := &models.MaterialTransaction{
+			MaterialID: hold.MaterialID,
+			Type:       "scrap",
+			Quantity:   quantity,
+			ReasonCode: ReasonCodeQualityHold,
+			Remark:     remark,
+			OperatorID: operatorID,
+		}
+		// 报废从冻结库存出库并扣减冻结库存
+		if _, err := postRestrictedDisposal(tx, scrap, restrictedStockBlocked); err != nil {
+			return err
+		}
+
+		hold.ScrappedQuantity = roundQuantity(hold.ScrappedQuantity + quantity)
+		if err := tx.Model(hold).Update("scrapped_quantity", hold.ScrappedQuantity).Error; err != nil {
+			return fmt.Errorf("更新物料冻结失败: %v", err)
+		}
+		if err := finishHoldIfDisposed(tx, hold); err != nil {
+			return err
+		}
+
+		return writeHoldLog(tx, hold.ID, "scrap", quantity, &scrap.ID, operatorID, req.Remark)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetHold(id)
+}
+
+// 辅助函数：锁定冻结中的冻结记录及其物料
+func lockActiveHold(tx *gorm.DB, id uint) (*models.MaterialHold, *models.Material, error) {
+	var hold models.MaterialHold
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("物料冻结不存在")
+		}
+		return nil, nil, fmt.Errorf("获取物料冻结失败: %v", err)
+	}
+
+	if hold.Status != "active" {
+		return nil, nil, errors.New("该物料冻结已处置完毕")
+	}
+
+	var material models.Material
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&material, hold.MaterialID).Error; err != nil {
+		return nil, nil, fmt.Errorf("获取物料失败: %v", err)
+	}
+
+	return &hold, &material, nil
+}
+
+// 辅助函数：确定处置数量，未指定时为全部剩余冻结数量
+func dispositionQuantity(hold *models.MaterialHold, quantity float64) (float64, error) {
+	remaining := roundQuantity(hold.Quantity - hold.ReleasedQuantity - hold.ScrappedQuantity)
+	if quantity == 0 {
+		return remaining, nil
+	}
+	if quantity > remaining {
+		return 0, fmt.Errorf("处置数量超过剩余冻结数量 %v", remaining)
+	}
+	return quantity, nil
+}
+
+// 辅助函数：扣减物料冻结库存
+func releaseBlockedStock(tx *gorm.DB, material *models.Material, quantity float64) error {
+	material.BlockedStock = roundQuantity(material.BlockedStock - quantity)
+	if material.BlockedStock < 0 {
+		material.BlockedStock = 0
+	}
+	if err := tx.Model(material).Update("blocked_stock", material.BlockedStock).Error; err != nil {
+		return fmt.Errorf("更新冻结库存失败: %v", err)
+	}
+	return nil
+}
+
+// 辅助函数：按是否仍有整体冻结刷新物料冻结标记
+func refreshMaterialOnHold(tx *gorm.DB, material *models.Material) error {
+	var count int64
+	if err := tx.Model(&models.MaterialHold{}).
+		Where("material_id = ? AND quantity = 0 AND status = ?", material.ID, "active").
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("获取物料冻结失败: %v", err)
+	}
+
+	if err := tx.Model(material).Update("on_hold", count > 0).Error; err != nil {
+		return fmt.Errorf("更新物料冻结标记失败: %v", err)
+	}
+	return nil
+}
+
+// 辅助函数：冻结数量全部处置后按处置方式结束冻结
+func finishHoldIfDisposed(tx *gorm.DB, hold *models.MaterialHold) error {
+	if hold.ReleasedQuantity+hold.ScrappedQuantity < hold.Quantity {
+		return nil
+	}
+
+	status := "closed"
+	if hold.ScrappedQuantity == 0 {
+		status = "released"
+	} else if hold.ReleasedQuantity == 0 {
+		status = "scrapped"
+	}
+	if err := tx.Model(hold).Update("status", status).Error; err != nil {
+		return fmt.Errorf("更新物料冻结失败: %v", err)
+	}
+
+	return closeHold(tx, hold)
+}
+
+// 辅助函数：记录冻结结束时间
+func closeHold(tx *gorm.DB, hold *models.MaterialHold) error {
+	now := time.Now()
+	hold.ClosedAt = &now
+	if err := tx.Model(hold).Update("closed_at", now).Error; err != nil {
+		return fmt.Errorf("更新物料冻结失败: %v", err)
+	}
+	return nil
+}
+
+// 辅助函数：写入冻结操作记录
+func writeHoldLog(tx *gorm.DB, holdID uint, action string, quantity float64, transactionID *uint, operatorID uint, remark string) error {
+	log := &models.MaterialHoldLog{
+		HoldID:        holdID,
+		Action:        action,
+		Quantity:      quantity,
+		TransactionID: transactionID,
+		OperatorID:    operatorID,
+		Remark:        remark,
+	}
+	if err := tx.Create(log).Error; err != nil {
+		return fmt.Errorf("记录冻结操作失败: %v", err)
+	}
+	return nil
+}
+
+// generateHoldNo 生成物料冻结单号
+func generateHoldNo(tx *gorm.DB) string {
+	prefix := fmt.Sprintf("HLD%s", time.Now().Format("20060102"))
+
+	var count int64
+	tx.Unscoped().Model(&models.MaterialHold{}).
+		Where("hold_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：转换为响应结构体
+func (s *MaterialHoldService) holdToResponse(hold *models.MaterialHold, withLogs bool) *MaterialHoldResponse {
+	var remaining float64
+	if hold.Status == "active" {
+		remaining = roundQuantity(hold.Quantity - hold.ReleasedQuantity - hold.ScrappedQuantity)
+	}
+
+	resp := &MaterialHoldResponse{
+		ID:                hold.ID,
+		HoldNo:            hold.HoldNo,
+		MaterialID:        hold.MaterialID,
+		MaterialCode:      hold.Material.Code,
+		MaterialName:      hold.Material.Name,
+		WholeMaterial:     hold.Quantity == 0,
+		Quantity:          hold.Quantity,
+		ReleasedQuantity:  hold.ReleasedQuantity,
+		ScrappedQuantity:  hold.ScrappedQuantity,
+		RemainingQuantity: remaining,
+		Reason:            hold.Reason,
+		OwnerID:           hold.OwnerID,
+		OwnerName:         hold.Owner.Username,
+		Status:            hold.Status,
+		CreatedBy:         hold.CreatedBy,
+		CreatorName:       hold.Creator.Username,
+		ClosedAt:          hold.ClosedAt,
+		CreatedAt:         hold.CreatedAt,
+	}
+
+	if withLogs {
+		for _, log := range hold.Logs {
+			resp.Logs = append(resp.Logs, MaterialHoldLogResponse{
+				ID:            log.ID,
+				Action:        log.Action,
+				Quantity:      log.Quantity,
+				TransactionID: log.TransactionID,
+				OperatorID:    log.OperatorID,
+				OperatorName:  log.Operator.Username,
+				Remark:        log.Remark,
+				CreatedAt:     log.CreatedAt,
+			})
+		}
+	}
+
+	return resp
+}
@@ -3,65 +3,160 @@ package service
 import (
 	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"mes-system/internal/models"
 )
 
+// inboundTransactionTypes 增加库存的交易类型，其余类型均视为减少库存
+var inboundTransactionTypes = []string{"in", "return_production", "adjust_in"}
+
+// transactionTypeNames 物料交易类型
+var transactionTypeNames = map[string]string{
+	"in":                "入库",
+	"out":               "生产领用",
+	"return_supplier":   "退供应商",
+	"return_production": "生产退料",
+	"scrap":             "报废",
+	"adjust_in":         "盘盈调整",
+	"adjust_out":        "盘亏调整",
+}
+
+// transactionReasonCodes 各交易类型可手工选择的原因代码，列出的类型必须指定原因代码
+// 来料检验退货和冻结报废的原因代码由系统生成，不能手工选择
+var transactionReasonCodes = map[string]map[string]string{
+	"return_supplier": {
+		ReasonCodeDefective: "质量缺陷",
+		"wrong_item":        "错发物料",
+		"excess":            "超量到货",
+		"expired":           "过期失效",
+	},
+	"return_production": {
+		"excess":          "领料剩余",
+		"order_cancelled": "工单取消",
+		"wrong_material":  "领错物料",
+	},
+	"scrap": {
+		"damaged":  "损坏",
+		"expired":  "过期失效",
+		"obsolete": "呆滞淘汰",
+		"quality":  "质量不良",
+	},
+	"adjust_in":  countReasonCodes,
+	"adjust_out": countReasonCodes,
+}
+
+// ReasonCodeDefective 因质量缺陷退供应商的原因代码
+const ReasonCodeDefective = "defective"
+
+// manualAdjustmentTypes 手工登记需要管理员或主管权限的交易类型
+var manualAdjustmentTypes = map[string]bool{
+	"scrap":      true,
+	"adjust_in":  true,
+	"adjust_out": true,
+}
+
+// 限制库存处置类别：系统生成的处置出库不受隔离和冻结限制，过账时同步扣减对应的限制库存
+const (
+	restrictedStockQuarantine = "quarantine" // 来料检验不合格退货，扣减隔离库存
+	restrictedStockBlocked    = "blocked"    // 冻结物料报废，扣减冻结库存
+	restrictedStockCount      = "count"      // 盘亏调整，非限制库存不足时扣减冻结库存
+)
+
 // MaterialRequest 物料请求结构体
 type MaterialRequest struct {
-	Code        string  `json:"code" binding:"required"`         // 物料编码
-	Name        string  `json:"name" binding:"required"`         // 物料名称
-	Type        string  `json:"type" binding:"required"`         // 物料类型
-	Unit        string  `json:"unit" binding:"required"`         // 计量单位
-	Price       float64 `json:"price" binding:"min=0"`           // 单价
-	MinStock    int     `json:"min_stock" binding:"min=0"`       // 最小库存
-	MaxStock    int     `json:"max_stock" binding:"min=0"`       // 最大库存
-	Description string  `json:"description"`                     // 描述
+	Code               string  `json:"code" binding:"required"`        // 物料编码
+	Name               string  `json:"name" binding:"required"`        // 物料名称
+	Type               string  `json:"type" binding:"required"`        // 物料类型
+	Unit               string  `json:"unit" binding:"required"`        // 基本计量单位（计量单位编码）
+	Location           string  `json:"location"`                       // 存放库位
+	Price              float64 `json:"price" binding:"min=0"`          // 单价
+	MinStock           float64 `json:"min_stock" binding:"min=0"`      // 最小库存
+	MaxStock           float64 `json:"max_stock" binding:"min=0"`      // 最大库存
+	LeadTimeDays       int     `json:"lead_time_days" binding:"min=0"` // 采购提前期（天）
+	CostingMethod      string  `json:"costing_method"`                 // 计价方法：moving_average/fifo，默认移动加权平均
+	InspectionRequired bool    `json:"inspection_required"`            // 收货是否需要来料检验
+	Description        string  `json:"description"`                    // 描述
 }
 
 // MaterialResponse 物料响应结构体
 type MaterialResponse struct {
-	ID          uint      `json:"id"`
-	Code        string    `json:"code"`
-	Name        string    `json:"name"`
-	Type        string    `json:"type"`
-	Unit        string    `json:"unit"`
-	Price       float64   `json:"price"`
-	CurrentStock int      `json:"current_stock"`
-	MinStock    int       `json:"min_stock"`
-	MaxStock    int       `json:"max_stock"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                 uint      `json:"id"`
+	Code               string    `json:"code"`
+	Name               string    `json:"name"`
+	Type               string    `json:"type"`
+	Unit               string    `json:"unit"`
+	Location           string    `json:"location"`
+	Price              float64   `json:"price"`
+	CurrentStock       float64   `json:"current_stock"`
+	QuarantineStock    float64   `json:"quarantine_stock"`
+	BlockedStock       float64   `json:"blocked_stock"`      // 质量冻结库存，整体冻结时为扣除隔离库存后的全部库存
+	UnrestrictedStock  float64   `json:"unrestricted_stock"` // 非限制库存，即可发料库存
+	OnHold             bool      `json:"on_hold"`
+	MinStock           float64   `json:"min_stock"`
+	MaxStock           float64   `json:"max_stock"`
+	LeadTimeDays       int       `json:"lead_time_days"`
+	CostingMethod      string    `json:"costing_method"`
+	InspectionRequired bool      `json:"inspection_required"`
+	AverageCost        float64   `json:"average_cost"`
+	StockValue         float64   `json:"stock_value"`
+	Description        string    `json:"description"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 // MaterialTransactionRequest 物料交易请求结构体
 type MaterialTransactionRequest struct {
-	MaterialID    uint    `json:"material_id" binding:"required"`    // 物料ID
-	Type          string  `json:"type" binding:"required"`           // 交易类型：in/out
-	Quantity      int     `json:"quantity" binding:"required,min=1"` // 数量
-	Price         float64 `json:"price" binding:"min=0"`             // 单价
-	Supplier      string  `json:"supplier"`                          // 供应商
-	ProductionOrderID *uint `json:"production_order_id"`             // 生产工单ID（出库时）
-	Remark        string  `json:"remark"`                            // 备注
+	MaterialID          uint    `json:"material_id" binding:"required"`    // 物料ID
+	Type                string  `json:"type" binding:"required"`           // 交易类型：in/out/return_supplier/return_production/scrap/adjust_in/adjust_out
+	Quantity            float64 `json:"quantity" binding:"min=0"`          // 数量（收货时为合格入库数量），按录入单位
+	Unit                string  `json:"unit"`                              // 录入单位，默认物料基本单位，数量按换算关系折算为基本单位
+	Price               float64 `json:"price" binding:"min=0"`             // 单价（入库时为按录入单位的采购单价，出库时由系统按计价方法计算）
+	Supplier            string  `json:"supplier"`                          // 供应商
+	SupplierID          *uint   `json:"supplier_id"`                       // 供应商ID（入库和退供应商时）
+	RejectedQuantity    float64 `json:"rejected_quantity" binding:"min=0"` // 不合格数量（收货时），按录入单位，不计入库存
+	ProductionOrderID   *uint   `json:"production_order_id"`               // 生产工单ID（生产领用时可选，生产退料时必填）
+	PurchaseOrderLineID *uint   `json:"purchase_order_line_id"`            // 采购订单行ID（按采购订单收货时）
+	LotNo               string  `json:"lot_no"`                            // 批次号：入库时为空则自动生成，其他交易可指定所用批次
+	ReasonCode          string  `json:"reason_code"`                       // 原因代码（退供应商、生产退料、报废和调整时必填）
+	Remark              string  `json:"remark"`                            // 备注（报废和调整时必填）
 }
 
 // MaterialTransactionResponse 物料交易响应结构体
 type MaterialTransactionResponse struct {
-	ID                uint      `json:"id"`
-	MaterialID        uint      `json:"material_id"`
-	MaterialCode      string    `json:"material_code"`
-	MaterialName      string    `json:"material_name"`
-	Type              string    `json:"type"`
-	Quantity          int       `json:"quantity"`
-	Price             float64   `json:"price"`
-	TotalAmount       float64   `json:"total_amount"`
-	Supplier          string    `json:"supplier"`
-	ProductionOrderID *uint     `json:"production_order_id"`
-	Remark            string    `json:"remark"`
-	CreatedAt         time.Time `json:"created_at"`
+	ID                  uint      `json:"id"`
+	MaterialID          uint      `json:"material_id"`
+	MaterialCode        string    `json:"material_code"`
+	MaterialName        string    `json:"material_name"`
+	Type                string    `json:"type"`
+	Quantity            float64   `json:"quantity"`
+	EnteredQuantity     float64   `json:"entered_quantity"`
+	EnteredUnit         string    `json:"entered_unit"`
+	Price               float64   `json:"price"`
+	TotalAmount         float64   `json:"total_amount"`
+	Supplier            string    `json:"supplier"`
+	SupplierID          *uint     `json:"supplier_id"`
+	RejectedQuantity    float64   `json:"rejected_quantity"`
+	ProductionOrderID   *uint     `json:"production_order_id"`
+	PurchaseOrderLineID *uint     `json:"purchase_order_line_id"`
+	RequisitionID       *uint     `json:"requisition_id"`
+	RequisitionLineID   *uint     `json:"requisition_line_id"`
+	LotNo               string    `json:"lot_no"`
+	ReasonCode          string    `json:"reason_code"`
+	Remark              string    `json:"remark"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// TransactionTypeResponse 物料交易类型响应结构体
+type TransactionTypeResponse struct {
+	Type        string            `json:"type"`
+	Name        string            `json:"name"`
+	Inbound     bool              `json:"inbound"`      // 是否增加库存
+	ReasonCodes map[string]string `json:"reason_codes"` // 可选择的原因代码，为空表示不需要原因代码
 }
 
 // MaterialService 物料服务
@@ -86,16 +181,34 @@ func (s *MaterialService) CreateMaterial(req *MaterialRequest) (*MaterialRespons
 		return nil, errors.New("最大库存必须大于最小库存")
 	}
 
+	// 验证基本计量单位
+	if err := validateMaterialUnit(s.db, req.Unit); err != nil {
+		return nil, err
+	}
+
+	// 验证计价方法
+	costingMethod := req.CostingMethod
+	if costingMethod == "" {
+		costingMethod = CostingMethodMovingAverage
+	}
+	if !isValidCostingMethod(costingMethod) {
+		return nil, errors.New("计价方法必须是 moving_average 或 fifo")
+	}
+
 	material := &models.Material{
-		Code:        req.Code,
-		Name:        req.Name,
-		Type:        req.Type,
-		Unit:        req.Unit,
-		Price:       req.Price,
-		CurrentStock: 0, // 初始库存为0
-		MinStock:    req.MinStock,
-		MaxStock:    req.MaxStock,
-		Description: req.Description,
+		Code:               req.Code,
+		Name:               req.Name,
+		Type:               req.Type,
+		Unit:               req.Unit,
+		Location:           req.Location,
+		Price:              req.Price,
+		CurrentStock:       0, // 初始库存为0
+		MinStock:           req.MinStock,
+		MaxStock:           req.MaxStock,
+		LeadTimeDays:       req.LeadTimeDays,
+		CostingMethod:      costingMethod,
+		InspectionRequired: req.InspectionRequired,
+		Description:        req.Description,
 	}
 
 	if err := s.db.Create(material).Error; err != nil {
@@ -174,18 +287,54 @@ func (s *MaterialService) UpdateMaterial(id uint, req *MaterialRequest) (*Materi
 		return nil, errors.New("最大库存必须大于最小库存")
 	}
 
+	// 基本计量单位变更时校验单位目录，已有交易的物料不能变更基本单位
+	if req.Unit != material.Unit {
+		if err := validateMaterialUnit(s.db, req.Unit); err != nil {
+			return nil, err
+		}
+
+		var count int64
+		if err := s.db.Model(&models.MaterialTransaction{}).Where("material_id = ?", id).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("检查物料交易记录失败: %v", err)
+		}
+		if count > 0 {
+			return nil, errors.New("该物料存在交易记录，不能变更基本计量单位")
+		}
+	}
+
+	// 验证计价方法
+	if req.CostingMethod != "" && !isValidCostingMethod(req.CostingMethod) {
+		return nil, errors.New("计价方法必须是 moving_average 或 fifo")
+	}
+
 	// 更新物料信息
 	material.Code = req.Code
 	material.Name = req.Name
 	material.Type = req.Type
 	material.Unit = req.Unit
+	material.Location = req.Location
 	material.Price = req.Price
 	material.MinStock = req.MinStock
 	material.MaxStock = req.MaxStock
+	material.LeadTimeDays = req.LeadTimeDays
+	material.InspectionRequired = req.InspectionRequired
 	material.Description = req.Description
 
-	if err := s.db.Save(&material).Error; err != nil {
-		return nil, fmt.Errorf("更新物料失败: %v", err)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 计价方法变更时按当前库存金额重建成本层
+		if req.CostingMethod != "" {
+			if err := changeCostingMethod(tx, &material, req.CostingMethod); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Save(&material).Error; err != nil {
+			return fmt.Errorf("更新物料失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return s.materialToResponse(&material), nil
@@ -218,11 +367,16 @@ func (s *MaterialService) DeleteMaterial(id uint) error {
 	return nil
 }
 
-// CreateTransaction 创建物料交易（入库/出库）
-func (s *MaterialService) CreateTransaction(req *MaterialTransactionRequest) (*MaterialTransactionResponse, error) {
-	// 验证交易类型
-	if req.Type != "in" && req.Type != "out" {
-		return nil, errors.New("交易类型必须是 in 或 out")
+// CreateTransaction 创建物料交易（入库、生产领用、退供应商、生产退料、报废和调整）
+func (s *MaterialService) CreateTransaction(req *MaterialTransactionRequest, role string) (*MaterialTransactionResponse, error) {
+	// 按交易类型验证请求
+	if err := validateTransactionRequest(req); err != nil {
+		return nil, err
+	}
+
+	// 报废和盘盈盘亏调整直接改变账面库存，手工登记仅限管理员和主管
+	if manualAdjustmentTypes[req.Type] && role != "admin" && role != "manager" {
+		return nil, fmt.Errorf("手工登记%s交易需要管理员或主管权限", transactionTypeNames[req.Type])
 	}
 
 	// 获取物料信息
@@ -234,9 +388,54 @@ func (s *MaterialService) CreateTransaction(req *MaterialTransactionRequest) (*M
 		return nil, fmt.Errorf("获取物料失败: %v", err)
 	}
 
-	// 出库时检查库存是否充足
-	if req.Type == "out" && material.CurrentStock < req.Quantity {
-		return nil, errors.New("库存不足")
+	// 按录入单位换算为基本单位数量
+	unit := req.Unit
+	if unit == "" {
+		unit = material.Unit
+	}
+	quantity, err := normalizeQuantity(s.db, &material, req.Quantity, unit)
+	if err != nil {
+		return nil, err
+	}
+	rejectedQuantity, err := normalizeQuantity(s.db, &material, req.RejectedQuantity, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	// 入库未提供单价时按物料标准单价入账，按采购订单收货时取订单单价
+	// 录入单价按录入单位计，折算为基本单位单价，金额按录入数量计算
+	price := req.Price
+	totalAmount := roundAmount(req.Quantity * req.Price)
+	if price > 0 && quantity > 0 {
+		price = roundUnitCost(totalAmount / quantity)
+	}
+	if req.Type == "in" && price == 0 && req.PurchaseOrderLineID == nil {
+		price = material.Price
+		totalAmount = roundAmount(quantity * price)
+	}
+	// 盘盈调整未提供单价时按当前单位成本入账
+	if req.Type == "adjust_in" && price == 0 {
+		price = materialUnitCost(&material)
+		totalAmount = roundAmount(quantity * price)
+	}
+
+	// 创建交易记录
+	transaction := &models.MaterialTransaction{
+		MaterialID:          req.MaterialID,
+		Type:                req.Type,
+		Quantity:            quantity,
+		EnteredQuantity:     req.Quantity,
+		EnteredUnit:         unit,
+		Price:               price,
+		TotalAmount:         totalAmount,
+		Supplier:            req.Supplier,
+		SupplierID:          req.SupplierID,
+		RejectedQuantity:    rejectedQuantity,
+		ProductionOrderID:   req.ProductionOrderID,
+		PurchaseOrderLineID: req.PurchaseOrderLineID,
+		LotNo:               req.LotNo,
+		ReasonCode:          req.ReasonCode,
+		Remark:              req.Remark,
 	}
 
 	// 开始事务
@@ -247,38 +446,68 @@ func (s *MaterialService) CreateTransaction(req *MaterialTransactionRequest) (*M
 		}
 	}()
 
-	// 创建交易记录
-	transaction := &models.MaterialTransaction{
-		MaterialID:        req.MaterialID,
-		Type:              req.Type,
-		Quantity:          req.Quantity,
-		Price:             req.Price,
-		TotalAmount:       float64(req.Quantity) * req.Price,
-		Supplier:          req.Supplier,
-		ProductionOrderID: req.ProductionOrderID,
-		Remark:            req.Remark,
+	// 按采购订单收货时校验订单行并更新未收数量
+	if transaction.PurchaseOrderLineID != nil {
+		if err := receivePurchaseOrderLine(tx, transaction); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 	}
 
-	if err := tx.Create(transaction).Error; err != nil {
+	// 收货时校验供应商及其合格物料
+	if transaction.Type == "in" && transaction.SupplierID != nil {
+		supplier, err := resolveReceiptSupplier(tx, *transaction.SupplierID, transaction.MaterialID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		transaction.Supplier = supplier.Name
+	}
+
+	// 退供应商时校验供应商
+	if transaction.Type == "return_supplier" && transaction.SupplierID != nil {
+		var supplier models.Supplier
+		if err := tx.First(&supplier, *transaction.SupplierID).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("供应商不存在")
+			}
+			return nil, fmt.Errorf("获取供应商失败: %v", err)
+		}
+		transaction.Supplier = supplier.Name
+	}
+
+	// 入库时生成批次号，其他交易指定的批次必须是该物料已入库的批次
+	if err := assignTransactionLot(tx, transaction); err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("创建交易记录失败: %v", err)
+		return nil, err
 	}
 
-	// 更新库存
-	if req.Type == "in" {
-		material.CurrentStock += req.Quantity
-	} else {
-		material.CurrentStock -= req.Quantity
+	// 生产退料按该工单的领用成本入账
+	if transaction.Type == "return_production" {
+		if err := applyProductionReturnCost(tx, transaction); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 	}
 
-	if err := tx.Save(&material).Error; err != nil {
+	updated, err := postMaterialTransaction(tx, transaction)
+	if err != nil {
 		tx.Rollback()
-		return nil, fmt.Errorf("更新库存失败: %v", err)
+		return nil, err
+	}
+
+	// 需要来料检验的物料收货后进入隔离状态，并生成待检的来料检验单
+	if transaction.Type == "in" && updated.InspectionRequired && transaction.Quantity > 0 {
+		if _, err := createIncomingInspection(tx, transaction, updated); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 	}
 
 	tx.Commit()
 
-	return s.transactionToResponse(transaction, &material), nil
+	return s.transactionToResponse(transaction, updated), nil
 }
 
 // GetTransactionList 获取物料交易列表
@@ -317,6 +546,23 @@ func (s *MaterialService) GetTransactionList(page, pageSize int, materialID uint
 	return responses, total, nil
 }
 
+// GetTransactionTypes 获取物料交易类型及其原因代码
+func (s *MaterialService) GetTransactionTypes() []TransactionTypeResponse {
+	types := make([]TransactionTypeResponse, 0, len(transactionTypeNames))
+	for transactionType, name := range transactionTypeNames {
+		types = append(types, TransactionTypeResponse{
+			Type:        transactionType,
+			Name:        name,
+			Inbound:     isInboundTransactionType(transactionType),
+			ReasonCodes: transactionReasonCodes[transactionType],
+		})
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Type < types[j].Type
+	})
+	return types
+}
+
 // GetLowStockMaterials 获取低库存物料列表
 func (s *MaterialService) GetLowStockMaterials() ([]MaterialResponse, error) {
 	var materials []models.Material
@@ -341,6 +587,290 @@ func (s *MaterialService) GetMaterialTypes() ([]string, error) {
 	return types, nil
 }
 
+// postMaterialTransaction 在事务中登记物料交易：校验库存、核算成本并更新物料库存
+// 所有改变物料库存的业务都应通过该函数记账，出库只能使用非限制库存，返回更新后的物料
+func postMaterialTransaction(tx *gorm.DB, transaction *models.MaterialTransaction) (*models.Material, error) {
+	return postStockTransaction(tx, transaction, "")
+}
+
+// postRestrictedDisposal 在事务中登记系统生成的限制库存处置出库（来料检验退货、冻结报废、盘亏调整），
+// 按处置类别从隔离或冻结库存出库并扣减对应的限制库存
+func postRestrictedDisposal(tx *gorm.DB, transaction *models.MaterialTransaction, restriction string) (*models.Material, error) {
+	return postStockTransaction(tx, transaction, restriction)
+}
+
+// 辅助函数：登记物料交易，restriction 为空时为普通交易
+func postStockTransaction(tx *gorm.DB, transaction *models.MaterialTransaction, restriction string) (*models.Material, error) {
+	// 锁定物料行，避免并发收发导致库存错误
+	var material models.Material
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&material, transaction.MaterialID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("物料不存在")
+		}
+		return nil, fmt.Errorf("获取物料失败: %v", err)
+	}
+
+	// 盘点冻结中的物料暂停收发
+	if err := checkMaterialNotFrozen(tx, material.ID); err != nil {
+		return nil, err
+	}
+
+	inbound := isInboundTransactionType(transaction.Type)
+
+	// 出库时检查库存是否充足，隔离和冻结的库存不能发料，限制库存处置按对应类别的库存校验
+	if !inbound {
+		if err := checkOutboundStock(&material, transaction.Quantity, restriction); err != nil {
+			return nil, err
+		}
+	}
+
+	// 出库时按计价方法计算出库成本
+	if !inbound {
+		if err := applyOutboundCost(tx, &material, transaction); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Create(transaction).Error; err != nil {
+		return nil, fmt.Errorf("创建交易记录失败: %v", err)
+	}
+
+	// 入库时更新库存金额和成本层
+	if inbound {
+		if err := applyInboundCost(tx, &material, transaction); err != nil {
+			return nil, err
+		}
+	}
+
+	// 更新库存，限制库存处置同步扣减隔离或冻结库存
+	if inbound {
+		material.CurrentStock = roundQuantity(material.CurrentStock + transaction.Quantity)
+	} else {
+		material.CurrentStock = roundQuantity(material.CurrentStock - transaction.Quantity)
+		switch restriction {
+		case restrictedStockQuarantine:
+			material.QuarantineStock = roundQuantity(material.QuarantineStock - transaction.Quantity)
+		case restrictedStockBlocked:
+			material.BlockedStock = roundQuantity(material.BlockedStock - transaction.Quantity)
+		case restrictedStockCount:
+			// 盘亏超出非限制库存的部分视为冻结库存的短缺
+			if unrestricted := roundQuantity(material.CurrentStock - material.QuarantineStock); material.BlockedStock > unrestricted {
+				material.BlockedStock = unrestricted
+			}
+		}
+	}
+
+	if err := tx.Save(&material).Error; err != nil {
+		return nil, fmt.Errorf("更新库存失败: %v", err)
+	}
+
+	return &material, nil
+}
+
+// issuableStock 计算非限制（可发料）库存，隔离和冻结的库存不能发料
+func issuableStock(material *models.Material) float64 {
+	if material.OnHold {
+		return 0
+	}
+
+	stock := roundQuantity(material.CurrentStock - material.QuarantineStock - material.BlockedStock)
+	if stock < 0 {
+		return 0
+	}
+	return stock
+}
+
+// blockedStock 计算质量冻结库存，整体冻结时除隔离库存外全部视为冻结
+func blockedStock(material *models.Material) float64 {
+	if material.OnHold {
+		stock := roundQuantity(material.CurrentStock - material.QuarantineStock)
+		if stock < 0 {
+			return 0
+		}
+		return stock
+	}
+	return material.BlockedStock
+}
+
+// checkOutboundStock 按处置类别校验出库库存：普通出库只能使用非限制库存，来料检验退货和冻结报废分别使用隔离和冻结库存，
+// 盘亏调整可使用除隔离库存外的全部库存
+func checkOutboundStock(material *models.Material, quantity float64, restriction string) error {
+	switch restriction {
+	case restrictedStockQuarantine:
+		if material.QuarantineStock < quantity || material.CurrentStock < quantity {
+			return fmt.Errorf("隔离库存不足，当前隔离库存为 %v", material.QuarantineStock)
+		}
+	case restrictedStockBlocked:
+		if material.BlockedStock < quantity || material.CurrentStock < quantity {
+			return fmt.Errorf("冻结库存不足，当前冻结库存为 %v", material.BlockedStock)
+		}
+	case restrictedStockCount:
+		if available := roundQuantity(material.CurrentStock - material.QuarantineStock); available < quantity {
+			return fmt.Errorf("盘亏数量超过非隔离库存 %v，请先完成待检物料的来料检验", math.Max(available, 0))
+		}
+	default:
+		if available := issuableStock(material); available < quantity {
+			if material.CurrentStock >= quantity {
+				return fmt.Errorf("可发料库存不足，非限制库存为 %v，其余库存处于隔离或冻结状态", available)
+			}
+			return errors.New("库存不足")
+		}
+	}
+	return nil
+}
+
+// validateTransactionRequest 按交易类型验证手工物料交易请求
+func validateTransactionRequest(req *MaterialTransactionRequest) error {
+	typeName, exists := transactionTypeNames[req.Type]
+	if !exists {
+		return fmt.Errorf("无效的交易类型: %s", req.Type)
+	}
+
+	// 验证数量：只有收货时可以记录不合格数量，且合格数量和不合格数量不能同时为0
+	if req.Type != "in" && req.RejectedQuantity > 0 {
+		return errors.New("只有入库交易可以记录不合格数量")
+	}
+	if req.Quantity == 0 && req.RejectedQuantity == 0 {
+		return errors.New("数量必须大于0")
+	}
+
+	// 只有入库和退供应商可以指定供应商
+	if req.Type != "in" && req.Type != "return_supplier" && (req.SupplierID != nil || req.Supplier != "") {
+		return fmt.Errorf("%s交易不能指定供应商", typeName)
+	}
+
+	// 需要原因代码的类型必须从该类型的原因代码中选择
+	if reasonCodes, required := transactionReasonCodes[req.Type]; required {
+		if req.ReasonCode == "" {
+			return fmt.Errorf("%s交易必须指定原因代码", typeName)
+		}
+		if _, valid := reasonCodes[req.ReasonCode]; !valid {
+			return fmt.Errorf("无效的%s原因代码: %s", typeName, req.ReasonCode)
+		}
+	} else if req.ReasonCode != "" {
+		return fmt.Errorf("%s交易不需要原因代码", typeName)
+	}
+
+	switch req.Type {
+	case "return_supplier":
+		if req.SupplierID == nil && req.Supplier == "" {
+			return errors.New("退供应商必须指定供应商")
+		}
+		// 质量缺陷退货按批次计入原收货期间的供应商绩效
+		if req.ReasonCode == ReasonCodeDefective && req.LotNo == "" {
+			return errors.New("因质量缺陷退供应商必须指定退回的批次号")
+		}
+	case "return_production":
+		if req.ProductionOrderID == nil {
+			return errors.New("生产退料必须指定原领料的生产工单")
+		}
+	case "scrap", "adjust_in", "adjust_out":
+		if req.Remark == "" {
+			return fmt.Errorf("%s交易必须填写备注说明", typeName)
+		}
+	}
+
+	if req.ProductionOrderID != nil && req.Type != "out" && req.Type != "return_production" && req.Type != "scrap" {
+		return fmt.Errorf("%s交易不能关联生产工单", typeName)
+	}
+
+	return nil
+}
+
+// assignTransactionLot 入库交易未指定批次号时生成批次号，其他交易指定批次号时校验该物料存在该批次的入库
+func assignTransactionLot(tx *gorm.DB, transaction *models.MaterialTransaction) error {
+	if transaction.Type == "in" {
+		if transaction.LotNo == "" && transaction.Quantity > 0 {
+			transaction.LotNo = generateLotNo(tx)
+		}
+		return nil
+	}
+
+	if transaction.LotNo == "" {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.MaterialTransaction{}).
+		Where("material_id = ? AND type = ? AND lot_no = ?", transaction.MaterialID, "in", transaction.LotNo).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("校验批次失败: %v", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("该物料没有批次 %s 的入库记录", transaction.LotNo)
+	}
+	return nil
+}
+
+// generateLotNo 生成入库批次号
+func generateLotNo(tx *gorm.DB) string {
+	prefix := fmt.Sprintf("LOT%s", time.Now().Format("20060102"))
+
+	var count int64
+	tx.Unscoped().Model(&models.MaterialTransaction{}).
+		Where("type = ? AND lot_no LIKE ?", "in", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// applyProductionReturnCost 校验生产退料并计算入账成本：
+// 退料数量不能超过该工单该物料的净领用数量（领用减已退回），按净领用的平均成本入账
+func applyProductionReturnCost(tx *gorm.DB, transaction *models.MaterialTransaction) error {
+	var order models.ProductionOrder
+	if err := tx.First(&order, *transaction.ProductionOrderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("生产工单不存在")
+		}
+		return fmt.Errorf("获取生产工单失败: %v", err)
+	}
+
+	// 锁定物料行，避免并发退料超过净领用数量
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Material{}, transaction.MaterialID).Error; err != nil {
+		return fmt.Errorf("获取物料失败: %v", err)
+	}
+
+	var issued struct {
+		Quantity float64
+		Amount   float64
+	}
+	if err := tx.Model(&models.MaterialTransaction{}).
+		Select("COALESCE(SUM(CASE WHEN type = 'out' THEN quantity ELSE -quantity END), 0) AS quantity, "+
+			"COALESCE(SUM(CASE WHEN type = 'out' THEN total_amount ELSE -total_amount END), 0) AS amount").
+		Where("production_order_id = ? AND material_id = ? AND type IN ?",
+			order.ID, transaction.MaterialID, []string{"out", "return_production"}).
+		Scan(&issued).Error; err != nil {
+		return fmt.Errorf("汇总工单领料失败: %v", err)
+	}
+
+	netQuantity := roundQuantity(issued.Quantity)
+	if netQuantity <= 0 {
+		return fmt.Errorf("生产工单 %s 没有该物料的未退回领料", order.OrderNo)
+	}
+	if transaction.Quantity > netQuantity {
+		return fmt.Errorf("退料数量超过生产工单 %s 的净领用数量 %v", order.OrderNo, netQuantity)
+	}
+
+	// 全部退回时按剩余领用金额入账，避免尾差
+	if transaction.Quantity == netQuantity {
+		transaction.TotalAmount = roundAmount(issued.Amount)
+	} else {
+		transaction.TotalAmount = roundAmount(issued.Amount / netQuantity * transaction.Quantity)
+	}
+	transaction.Price = roundUnitCost(transaction.TotalAmount / transaction.Quantity)
+
+	return nil
+}
+
+// isInboundTransactionType 判断交易类型是否增加库存
+func isInboundTransactionType(transactionType string) bool {
+	for _, t := range inboundTransactionTypes {
+		if t == transactionType {
+			return true
+		}
+	}
+	return false
+}
+
 // 辅助函数：检查物料编码是否存在
 func (s *MaterialService) isMaterialCodeExists(code string, excludeID uint) bool {
 	var count int64
@@ -355,35 +885,54 @@ func (s *MaterialService) isMaterialCodeExists(code string, excludeID uint) bool
 // 辅助函数：将物料模型转换为响应结构体
 func (s *MaterialService) materialToResponse(material *models.Material) *MaterialResponse {
 	return &MaterialResponse{
-		ID:           material.ID,
-		Code:         material.Code,
-		Name:         material.Name,
-		Type:         material.Type,        // 确保字段名一致
-		Unit:         material.Unit,
-		Price:        material.Price,
-		CurrentStock: material.CurrentStock,
-		MinStock:     material.MinStock,
-		MaxStock:     material.MaxStock,
-		Description:  material.Description, // 确保字段名一致
-		CreatedAt:    material.CreatedAt,
-		UpdatedAt:    material.UpdatedAt,
+		ID:                 material.ID,
+		Code:               material.Code,
+		Name:               material.Name,
+		Type:               material.Type, // 确保字段名一致
+		Unit:               material.Unit,
+		Location:           material.Location,
+		Price:              material.Price,
+		CurrentStock:       material.CurrentStock,
+		QuarantineStock:    material.QuarantineStock,
+		BlockedStock:       blockedStock(material),
+		UnrestrictedStock:  issuableStock(material),
+		OnHold:             material.OnHold,
+		MinStock:           material.MinStock,
+		MaxStock:           material.MaxStock,
+		LeadTimeDays:       material.LeadTimeDays,
+		CostingMethod:      material.CostingMethod,
+		InspectionRequired: material.InspectionRequired,
+		AverageCost:        materialUnitCost(material),
+		StockValue:         materialStockValue(material),
+		Description:        material.Description, // 确保字段名一致
+		CreatedAt:          material.CreatedAt,
+		UpdatedAt:          material.UpdatedAt,
 	}
 }
 
 // 辅助函数：将交易模型转换为响应结构体
 func (s *MaterialService) transactionToResponse(transaction *models.MaterialTransaction, material *models.Material) *MaterialTransactionResponse {
 	return &MaterialTransactionResponse{
-		ID:                transaction.ID,
-		MaterialID:        transaction.MaterialID,
-		MaterialCode:      material.Code,
-		MaterialName:      material.Name,
-		Type:              transaction.Type,
-		Quantity:          transaction.Quantity,
-		Price:             transaction.Price,             // 现在模型中有这个字段
-		TotalAmount:       transaction.TotalAmount,       // 现在模型中有这个字段
-		Supplier:          transaction.Supplier,          // 现在模型中有这个字段
-		ProductionOrderID: transaction.ProductionOrderID, // 现在模型中有这个字段
-		Remark:            transaction.Remark,            // 现在模型中有这个字段
-		CreatedAt:         transaction.CreatedAt,
-	}
-}
\ No newline at end of file
+		ID:                  transaction.ID,
+		MaterialID:          transaction.MaterialID,
+		MaterialCode:        material.Code,
+		MaterialName:        material.Name,
+		Type:                transaction.Type,
+		Quantity:            transaction.Quantity,
+		EnteredQuantity:     transaction.EnteredQuantity,
+		EnteredUnit:         transaction.EnteredUnit,
+		Price:               transaction.Price,             // 现在模型中有这个字段
+		TotalAmount:         transaction.TotalAmount,       // 现在模型中有这个字段
+		Supplier:            transaction.Supplier,          // 现在模型中有这个字段
+		ProductionOrderID:   transaction.ProductionOrderID, // 现在模型中有这个字段
+		PurchaseOrderLineID: transaction.PurchaseOrderLineID,
+		RequisitionID:       transaction.RequisitionID,
+		RequisitionLineID:   transaction.RequisitionLineID,
+		LotNo:               transaction.LotNo,
+		ReasonCode:          transaction.ReasonCode,
+		SupplierID:          transaction.SupplierID,
+		RejectedQuantity:    transaction.RejectedQuantity,
+		Remark:              transaction.Remark, // 现在模型中有这个字段
+		CreatedAt:           transaction.CreatedAt,
+	}
+}
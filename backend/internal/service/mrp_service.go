@@ -0,0 +1,1078 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"mes-system/internal/models"
+)
+
+// defaultMrpHorizonDays MRP默认计划展望期（天）
+const defaultMrpHorizonDays = 90
+
+// ProductDemandRequest 产品需求请求结构体
+type ProductDemandRequest struct {
+	ProductID  uint      `json:"product_id" binding:"required"`                // 产品ID
+	DemandDate time.Time `json:"demand_date" binding:"required"`               // 需求日期
+	Quantity   float64   `json:"quantity" binding:"required,gt=0"`             // 需求数量
+	Type       string    `json:"type" binding:"omitempty,oneof=forecast firm"` // 需求类型：forecast 预测（默认）/firm 确定需求
+	Remark     string    `json:"remark"`                                       // 备注
+}
+
+// ProductDemandResponse 产品需求响应结构体
+type ProductDemandResponse struct {
+	ID          uint      `json:"id"`
+	ProductID   uint      `json:"product_id"`
+	ProductCode string    `json:"product_code"`
+	ProductName string    `json:"product_name"`
+	Unit        string    `json:"unit"`
+	DemandDate  time.Time `json:"demand_date"`
+	Quantity    float64   `json:"quantity"`
+	Type        string    `json:"type"`
+	Remark      string    `json:"remark"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ProductMaterialRequest 产品物料消耗定额请求结构体
+type ProductMaterialRequest struct {
+	MaterialID  uint    `json:"material_id" binding:"required"`       // 物料ID
+	QuantityPer float64 `json:"quantity_per" binding:"required,gt=0"` // 单位产品消耗数量（物料基本单位）
+	ScrapRate   float64 `json:"scrap_rate" binding:"min=0,lt=1"`      // 损耗率
+	Remark      string  `json:"remark"`                               // 备注
+}
+
+// ProductMaterialsRequest 设置产品物料消耗定额请求结构体，整体替换
+type ProductMaterialsRequest struct {
+	Materials []ProductMaterialRequest `json:"materials" binding:"dive"`
+}
+
+// ProductMaterialResponse 产品物料消耗定额响应结构体
+type ProductMaterialResponse struct {
+	ID           uint    `json:"id"`
+	MaterialID   uint    `json:"material_id"`
+	MaterialCode string  `json:"material_code"`
+	MaterialName string  `json:"material_name"`
+	Unit         string  `json:"unit"`
+	QuantityPer  float64 `json:"quantity_per"`
+	ScrapRate    float64 `json:"scrap_rate"`
+	Remark       string  `json:"remark"`
+}
+
+// MrpRunRequest MRP运行请求结构体
+type MrpRunRequest struct {
+	HorizonDays int    `json:"horizon_days" binding:"min=0"` // 计划展望期（天），为0时取默认值
+	Remark      string `json:"remark"`                       // 备注
+}
+
+// MrpFirmPlannedOrderRequest 确认计划生产订单请求结构体
+type MrpFirmPlannedOrderRequest struct {
+	Quantity  float64    `json:"quantity" binding:"min=0"`       // 生产数量，为0时取计划数量
+	Priority  int        `json:"priority" binding:"min=0,max=5"` // 优先级，默认为3
+	StartDate *time.Time `json:"start_date"`                     // 计划开工日期，默认为计划开工日期
+}
+
+// MrpFirmPurchaseRequest 确认采购建议请求结构体
+type MrpFirmPurchaseRequest struct {
+	SupplierID uint    `json:"supplier_id"`              // 供应商ID，为空时取该物料唯一的合格供应商
+	Quantity   float64 `json:"quantity" binding:"min=0"` // 采购数量，为0时取建议数量
+	Price      float64 `json:"price" binding:"min=0"`    // 采购单价，为0时取物料标准单价
+}
+
+// MrpPlannedOrderResponse 计划生产订单响应结构体
+type MrpPlannedOrderResponse struct {
+	ID                 uint      `json:"id"`
+	RunID              uint      `json:"run_id"`
+	ProductID          uint      `json:"product_id"`
+	ProductCode        string    `json:"product_code"`
+	ProductName        string    `json:"product_name"`
+	Unit               string    `json:"unit"`
+	GrossRequirement   float64   `json:"gross_requirement"`
+	ProjectedAvailable float64   `json:"projected_available"`
+	NetRequirement     float64   `json:"net_requirement"`
+	Quantity           float64   `json:"quantity"`
+	StartDate          time.Time `json:"start_date"`
+	DueDate            time.Time `json:"due_date"`
+	PastDue            bool      `json:"past_due"`
+	Status             string    `json:"status"`
+	ProductionOrderID  *uint     `json:"production_order_id"`
+	ProductionOrderNo  string    `json:"production_order_no"`
+}
+
+// MrpPurchaseSuggestionResponse 采购建议响应结构体
+type MrpPurchaseSuggestionResponse struct {
+	ID                 uint      `json:"id"`
+	RunID              uint      `json:"run_id"`
+	MaterialID         uint      `json:"material_id"`
+	MaterialCode       string    `json:"material_code"`
+	MaterialName       string    `json:"material_name"`
+	Unit               string    `json:"unit"`
+	LeadTimeDays       int       `json:"lead_time_days"`
+	GrossRequirement   float64   `json:"gross_requirement"`
+	ProjectedAvailable float64   `json:"projected_available"`
+	NetRequirement     float64   `json:"net_requirement"`
+	Quantity           float64   `json:"quantity"`
+	OrderDate          time.Time `json:"order_date"`
+	NeedDate           time.Time `json:"need_date"`
+	PastDue            bool      `json:"past_due"`
+	Status             string    `json:"status"`
+	PurchaseOrderID    *uint     `json:"purchase_order_id"`
+	PurchaseOrderNo    string    `json:"purchase_order_no"`
+}
+
+// MrpRunResponse MRP运行响应结构体
+type MrpRunResponse struct {
+	ID                      uint                            `json:"id"`
+	RunNo                   string                          `json:"run_no"`
+	HorizonDays             int                             `json:"horizon_days"`
+	Status                  string                          `json:"status"`
+	PlannedOrderCount       int                             `json:"planned_order_count"`
+	PurchaseSuggestionCount int                             `json:"purchase_suggestion_count"`
+	Remark                  string                          `json:"remark"`
+	CreatedBy               uint                            `json:"created_by"`
+	CreatorName             string                          `json:"creator_name"`
+	PlannedOrders           []MrpPlannedOrderResponse       `json:"planned_orders,omitempty"`
+	PurchaseSuggestions     []MrpPurchaseSuggestionResponse `json:"purchase_suggestions,omitempty"`
+	CreatedAt               time.Time                       `json:"created_at"`
+}
+
+// mrpBucket 按日期汇总的需求或供给
+type mrpBucket struct {
+	Date     time.Time
+	Quantity float64
+}
+
+// MrpService MRP服务
+type MrpService struct {
+	db                   *gorm.DB
+	productionService    *ProductionService
+	purchaseOrderService *PurchaseOrderService
+	mu                   sync.Mutex // 保证MRP运行和确认不会同时进行
+}
+
+// NewMrpService 创建MRP服务实例
+func NewMrpService(db *gorm.DB, productionService *ProductionService, purchaseOrderService *PurchaseOrderService) *MrpService {
+	return &MrpService{db: db, productionService: productionService, purchaseOrderService: purchaseOrderService}
+}
+
+// CreateDemand 创建产品需求
+func (s *MrpService) CreateDemand(req *ProductDemandRequest, createdBy uint) (*ProductDemandResponse, error) {
+	demand := &models.ProductDemand{CreatedBy: createdBy}
+	if err := s.applyDemandRequest(demand, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(demand).Error; err != nil {
+		return nil, fmt.Errorf("创建产品需求失败: %v", err)
+	}
+
+	return s.GetDemand(demand.ID)
+}
+
+// GetDemand 获取产品需求详情
+func (s *MrpService) GetDemand(id uint) (*ProductDemandResponse, error) {
+	var demand models.ProductDemand
+	if err := s.db.Preload("Product").First(&demand, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("产品需求不存在")
+		}
+		return nil, fmt.Errorf("获取产品需求失败: %v", err)
+	}
+	return demandToResponse(&demand), nil
+}
+
+// GetDemandList 获取产品需求列表，可按产品和需求日期范围筛选
+func (s *MrpService) GetDemandList(page, pageSize int, productID uint, demandType string, start, end *time.Time) ([]ProductDemandResponse, int64, error) {
+	var demands []models.ProductDemand
+	var total int64
+
+	query := s.db.Model(&models.ProductDemand{})
+
+	if productID > 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	if demandType != "" {
+		query = query.Where("type = ?", demandType)
+	}
+	if start != nil {
+		query = query.Where("demand_date >= ?", startOfDay(*start))
+	}
+	if end != nil {
+		query = query.Where("demand_date < ?", endOfDay(*end))
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取产品需求总数失败: %v", err)
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Product").Offset(offset).Limit(pageSize).
+		Order("demand_date, id").Find(&demands).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取产品需求列表失败: %v", err)
+	}
+
+	responses := make([]ProductDemandResponse, 0, len(demands))
+	for i := range demands {
+		responses = append(responses, *demandToResponse(&demands[i]))
+	}
+
+	return responses, total, nil
+}
+
+// UpdateDemand 更新产品需求
+func (s *MrpService) UpdateDemand(id uint, req *ProductDemandRequest) (*ProductDemandResponse, error) {
+	var demand models.ProductDemand
+	if err := s.db.First(&demand, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("产品需求不存在")
+		}
+		return nil, fmt.Errorf("获取产品需求失败: %v", err)
+	}
+
+	if err := s.applyDemandRequest(&demand, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Save(&demand).Error; err != nil {
+		return nil, fmt.Errorf("更新产品需求失败: %v", err)
+	}
+
+	return s.GetDemand(demand.ID)
+}
+
+// DeleteDemand 删除产品需求
+func (s *MrpService) DeleteDemand(id uint) error {
+	result := s.db.Delete(&models.ProductDemand{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除产品需求失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("产品需求不存在")
+	}
+	return nil
+}
+
+// GetProductMaterials 获取产品物料消耗定额
+func (s *MrpService) GetProductMaterials(productID uint) ([]ProductMaterialResponse, error) {
+	if err := s.db.First(&models.Product{}, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("产品不存在")
+		}
+		return nil, fmt.Errorf("获取产品失败: %v", err)
+	}
+
+	var items []models.ProductMaterial
+	if err := s.db.Preload("Material").Where("product_id = ?", productID).Order("id").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("获取产品物料消耗定额失败: %v", err)
+	}
+
+	responses := make([]ProductMaterialResponse, 0, len(items))
+	for _, item := range items {
+		responses = append(responses, ProductMaterialResponse{
+			ID:           item.ID,
+			MaterialID:   item.MaterialID,
+			MaterialCode: item.Material.Code,
+			MaterialName: item.Material.Name,
+			Unit:         item.Material.Unit,
+			QuantityPer:  item.QuantityPer,
+			ScrapRate:    item.ScrapRate,
+			Remark:       item.Remark,
+		})
+	}
+
+	return responses, nil
+}
+
+// SetProductMaterials 设置产品物料消耗定额，整体替换原有定额
+func (s *MrpService) SetProductMaterials(productID uint, req *ProductMaterialsRequest) ([]ProductMaterialResponse, error) {
+	if err := s.db.First(&models.Product{}, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("产品不存在")
+		}
+		return nil, fmt.Errorf("获取产品失败: %v", err)
+	}
+
+	items := make([]models.ProductMaterial, 0, len(req.Materials))
+	seen := make(map[uint]bool, len(req.Materials))
+	for i, itemReq := range req.Materials {
+		if seen[itemReq.MaterialID] {
+			return nil, fmt.Errorf("第 %d 行物料重复", i+1)
+		}
+		seen[itemReq.MaterialID] = true
+
+		var material models.Material
+		if err := s.db.First(&material, itemReq.MaterialID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("第 %d 行物料不存在", i+1)
+			}
+			return nil, fmt.Errorf("获取物料失败: %v", err)
+		}
+
+		items = append(items, models.ProductMaterial{
+			ProductID:   productID,
+			MaterialID:  itemReq.MaterialID,
+			QuantityPer: roundQuantity(itemReq.QuantityPer),
+			ScrapRate:   itemReq.ScrapRate,
+			Remark:      itemReq.Remark,
+		})
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productID).Delete(&models.ProductMaterial{}).Error; err != nil {
+			return fmt.Errorf("删除原有物料消耗定额失败: %v", err)
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		if err := tx.Create(&items).Error; err != nil {
+			return fmt.Errorf("保存物料消耗定额失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProductMaterials(productID)
+}
+
+// RunMrp 运行MRP：产品需求按成品库存和未完工生产工单净算生成计划生产订单，
+// 计划生产订单和未完工工单按物料消耗定额展开为物料需求（工单扣减已领用数量），按可用库存、最小库存和已下达的采购在途净算生成采购建议。
+// 计划开工日期和建议下单日期分别按产品生产提前期和物料采购提前期倒排，新运行会取代之前的运行
+func (s *MrpService) RunMrp(req *MrpRunRequest, createdBy uint) (*MrpRunResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	horizonDays := req.HorizonDays
+	if horizonDays == 0 {
+		horizonDays = defaultMrpHorizonDays
+	}
+	today := startOfDay(time.Now())
+	horizonEnd := today.AddDate(0, 0, horizonDays)
+
+	run := &models.MrpRun{
+		HorizonDays: horizonDays,
+		Status:      "active",
+		Remark:      req.Remark,
+		CreatedBy:   createdBy,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 之前的运行结果不再有效，其中未确认的计划不能再确认
+		if err := tx.Model(&models.MrpRun{}).Where("status = ?", "active").
+			Update("status", "superseded").Error; err != nil {
+			return fmt.Errorf("更新历史MRP运行失败: %v", err)
+		}
+
+		run.RunNo = generateMrpRunNo(tx)
+		if err := tx.Create(run).Error; err != nil {
+			return fmt.Errorf("创建MRP运行记录失败: %v", err)
+		}
+
+		plannedOrders, err := planProduction(tx, run.ID, today, horizonEnd)
+		if err != nil {
+			return err
+		}
+		if len(plannedOrders) > 0 {
+			if err := tx.Create(&plannedOrders).Error; err != nil {
+				return fmt.Errorf("保存计划生产订单失败: %v", err)
+			}
+		}
+
+		suggestions, err := planMaterials(tx, run.ID, plannedOrders, today)
+		if err != nil {
+			return err
+		}
+		if len(suggestions) > 0 {
+			if err := tx.Create(&suggestions).Error; err != nil {
+				return fmt.Errorf("保存采购建议失败: %v", err)
+			}
+		}
+
+		return tx.Model(run).Updates(map[string]interface{}{
+			"planned_order_count":       len(plannedOrders),
+			"purchase_suggestion_count": len(suggestions),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRun(run.ID)
+}
+
+// GetRun 获取MRP运行结果，包含计划生产订单和采购建议
+func (s *MrpService) GetRun(id uint) (*MrpRunResponse, error) {
+	var run models.MrpRun
+	err := s.db.Preload("Creator").
+		Preload("PlannedOrders", func(db *gorm.DB) *gorm.DB { return db.Order("due_date, product_id") }).
+		Preload("PlannedOrders.Product").Preload("PlannedOrders.ProductionOrder").
+		Preload("PurchaseSuggestions", func(db *gorm.DB) *gorm.DB { return db.Order("order_date, material_id") }).
+		Preload("PurchaseSuggestions.Material").Preload("PurchaseSuggestions.PurchaseOrder").
+		First(&run, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("MRP运行记录不存在")
+		}
+		return nil, fmt.Errorf("获取MRP运行记录失败: %v", err)
+	}
+
+	resp := runToResponse(&run)
+	resp.PlannedOrders = make([]MrpPlannedOrderResponse, 0, len(run.PlannedOrders))
+	for i := range run.PlannedOrders {
+		resp.PlannedOrders = append(resp.PlannedOrders, *plannedOrderToResponse(&run.PlannedOrders[i]))
+	}
+	resp.PurchaseSuggestions = make([]MrpPurchaseSuggestionResponse, 0, len(run.PurchaseSuggestions))
+	for i := range run.PurchaseSuggestions {
+		resp.PurchaseSuggestions = append(resp.PurchaseSuggestions, *purchaseSuggestionToResponse(&run.PurchaseSuggestions[i]))
+	}
+
+	return resp, nil
+}
+
+// GetRunList 获取MRP运行记录列表
+func (s *MrpService) GetRunList(page, pageSize int, status string) ([]MrpRunResponse, int64, error) {
+	var runs []models.MrpRun
+	var total int64
+
+	query := s.db.Model(&models.MrpRun{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取MRP运行记录总数失败: %v", err)
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Creator").Offset(offset).Limit(pageSize).
+		Order("created_at DESC, id DESC").Find(&runs).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取MRP运行记录列表失败: %v", err)
+	}
+
+	responses := make([]MrpRunResponse, 0, len(runs))
+	for i := range runs {
+		responses = append(responses, *runToResponse(&runs[i]))
+	}
+
+	return responses, total, nil
+}
+
+// FirmPlannedOrder 确认计划生产订单，生成待开工的生产工单
+func (s *MrpService) FirmPlannedOrder(id uint, req *MrpFirmPlannedOrderRequest, createdBy uint) (*MrpPlannedOrderResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 生成生产工单和更新计划生产订单在同一事务中完成，按计划状态条件更新防止重复确认
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var planned models.MrpPlannedOrder
+		if err := tx.First(&planned, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("计划生产订单不存在")
+			}
+			return fmt.Errorf("获取计划生产订单失败: %v", err)
+		}
+		if err := checkMrpItemFirmable(tx, planned.RunID, planned.Status); err != nil {
+			return err
+		}
+
+		quantity := req.Quantity
+		if quantity == 0 {
+			quantity = planned.Quantity
+		}
+		startDate := planned.StartDate
+		if req.StartDate != nil {
+			startDate = *req.StartDate
+		}
+		dueDate := planned.DueDate
+
+		order, err := s.productionService.createProductionOrderTx(tx, &CreateProductionOrderRequest{
+			ProductID: planned.ProductID,
+			Quantity:  quantity,
+			Priority:  req.Priority,
+			StartDate: &startDate,
+			EndDate:   &dueDate,
+		}, createdBy)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.MrpPlannedOrder{}).
+			Where("id = ? AND status = ?", planned.ID, "planned").
+			Updates(map[string]interface{}{
+				"status":              "firmed",
+				"production_order_id": order.ID,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("更新计划生产订单失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("计划生产订单已被确认或取消")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getPlannedOrder(id)
+}
+
+// CancelPlannedOrder 取消计划生产订单
+func (s *MrpService) CancelPlannedOrder(id uint) (*MrpPlannedOrderResponse, error) {
+	result := s.db.Model(&models.MrpPlannedOrder{}).
+		Where("id = ? AND status = ?", id, "planned").
+		Update("status", "cancelled")
+	if result.Error != nil {
+		return nil, fmt.Errorf("取消计划生产订单失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("计划生产订单不存在或已确认、已取消")
+	}
+	return s.getPlannedOrder(id)
+}
+
+// FirmPurchaseSuggestion 确认采购建议，生成草稿采购订单，要求到货日期为需求日期
+func (s *MrpService) FirmPurchaseSuggestion(id uint, req *MrpFirmPurchaseRequest, createdBy uint) (*MrpPurchaseSuggestionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 生成采购订单和更新采购建议在同一事务中完成，按计划状态条件更新防止重复确认
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var suggestion models.MrpPurchaseSuggestion
+		if err := tx.First(&suggestion, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("采购建议不存在")
+			}
+			return fmt.Errorf("获取采购建议失败: %v", err)
+		}
+		if err := checkMrpItemFirmable(tx, suggestion.RunID, suggestion.Status); err != nil {
+			return err
+		}
+
+		supplierID := req.SupplierID
+		if supplierID == 0 {
+			var supplierIDs []uint
+			if err := tx.Model(&models.SupplierMaterial{}).
+				Joins("JOIN suppliers ON suppliers.id = supplier_materials.supplier_id AND suppliers.deleted_at IS NULL").
+				Where("supplier_materials.material_id = ? AND suppliers.status = ?", suggestion.MaterialID, 1).
+				Pluck("supplier_materials.supplier_id", &supplierIDs).Error; err != nil {
+				return fmt.Errorf("获取物料合格供应商失败: %v", err)
+			}
+			if len(supplierIDs) != 1 {
+				return errors.New("物料没有唯一的合格供应商，请指定供应商")
+			}
+			supplierID = supplierIDs[0]
+		}
+
+		quantity := roundQuantity(req.Quantity)
+		if quantity == 0 {
+			quantity = suggestion.Quantity
+		}
+
+		var run models.MrpRun
+		if err := tx.First(&run, suggestion.RunID).Error; err != nil {
+			return fmt.Errorf("获取MRP运行记录失败: %v", err)
+		}
+
+		order, err := s.purchaseOrderService.createPurchaseOrderTx(tx, &PurchaseOrderRequest{
+			SupplierID: supplierID,
+			Remark:     fmt.Sprintf("MRP运行 %s 采购建议", run.RunNo),
+			Lines: []PurchaseOrderLineRequest{{
+				MaterialID: suggestion.MaterialID,
+				Quantity:   quantity,
+				Price:      req.Price,
+				DueDate:    suggestion.NeedDate,
+			}},
+		}, createdBy)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.MrpPurchaseSuggestion{}).
+			Where("id = ? AND status = ?", suggestion.ID, "planned").
+			Updates(map[string]interface{}{
+				"status":            "firmed",
+				"purchase_order_id": order.ID,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("更新采购建议失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("采购建议已被确认或取消")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getPurchaseSuggestion(id)
+}
+
+// CancelPurchaseSuggestion 取消采购建议
+func (s *MrpService) CancelPurchaseSuggestion(id uint) (*MrpPurchaseSuggestionResponse, error) {
+	result := s.db.Model(&models.MrpPurchaseSuggestion{}).
+		Where("id = ? AND status = ?", id, "planned").
+		Update("status", "cancelled")
+	if result.Error != nil {
+		return nil, fmt.Errorf("取消采购建议失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("采购建议不存在或已确认、已取消")
+	}
+	return s.getPurchaseSuggestion(id)
+}
+
+// planProduction 产品层净算：展望期内（自今天起）的需求按日期依次扣减成品库存和到期的未完工工单剩余数量，不足部分生成计划生产订单
+func planProduction(tx *gorm.DB, runID uint, today, horizonEnd time.Time) ([]models.MrpPlannedOrder, error) {
+	var demands []models.ProductDemand
+	if err := tx.Where("demand_date >= ? AND demand_date < ?", today, horizonEnd).
+		Order("demand_date").Find(&demands).Error; err != nil {
+		return nil, fmt.Errorf("获取产品需求失败: %v", err)
+	}
+	if len(demands) == 0 {
+		return nil, nil
+	}
+
+	demandBuckets := make(map[uint][]mrpBucket)
+	for _, demand := range demands {
+		demandBuckets[demand.ProductID] = addMrpBucket(demandBuckets[demand.ProductID], startOfDay(demand.DemandDate), demand.Quantity)
+	}
+	productIDs := sortedMrpKeys(demandBuckets)
+
+	var products []models.Product
+	if err := tx.Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("获取产品失败: %v", err)
+	}
+	productMap := make(map[uint]*models.Product, len(products))
+	for i := range products {
+		productMap[products[i].ID] = &products[i]
+	}
+
+	// 未完工工单的剩余数量按计划完工日期作为预计入库，未排完工日期或已逾期的按今天入库；返工工单不增加库存
+	var openOrders []models.ProductionOrder
+	if err := tx.Where("product_id IN ? AND status IN ? AND order_type <> ?", productIDs, []string{"pending", "processing"}, ProductionOrderTypeRework).
+		Find(&openOrders).Error; err != nil {
+		return nil, fmt.Errorf("获取未完工生产工单失败: %v", err)
+	}
+	receipts := make(map[uint][]mrpBucket)
+	for _, order := range openOrders {
+		remaining := roundQuantity(order.Quantity - order.Produced)
+		if remaining <= 0 {
+			continue
+		}
+		date := today
+		if order.EndDate != nil && order.EndDate.After(today) {
+			date = startOfDay(*order.EndDate)
+		}
+		receipts[order.ProductID] = addMrpBucket(receipts[order.ProductID], date, remaining)
+	}
+
+	var plannedOrders []models.MrpPlannedOrder
+	for _, productID := range productIDs {
+		product := productMap[productID]
+		if product == nil {
+			continue
+		}
+
+		available := product.CurrentStock
+		productReceipts := receipts[productID]
+		next := 0
+		for _, bucket := range demandBuckets[productID] {
+			for next < len(productReceipts) && !productReceipts[next].Date.After(bucket.Date) {
+				available = roundQuantity(available + productReceipts[next].Quantity)
+				next++
+			}
+
+			if available < bucket.Quantity {
+				net := roundQuantity(bucket.Quantity - available)
+				startDate := bucket.Date.AddDate(0, 0, -product.LeadTimeDays)
+				plannedOrders = append(plannedOrders, models.MrpPlannedOrder{
+					RunID:              runID,
+					ProductID:          productID,
+					GrossRequirement:   bucket.Quantity,
+					ProjectedAvailable: available,
+					NetRequirement:     net,
+					Quantity:           net,
+					StartDate:          startDate,
+					DueDate:            bucket.Date,
+					PastDue:            startDate.Before(today),
+					Status:             "planned",
+				})
+				available = roundQuantity(available + net)
+			}
+			available = roundQuantity(available - bucket.Quantity)
+		}
+	}
+
+	return plannedOrders, nil
+}
+
+// planMaterials 物料层净算：计划生产订单和未完工工单按消耗定额展开为物料毛需求，
+// 按日期依次扣减可用库存（扣除冻结库存和最小库存）和到期的采购在途，不足部分按采购提前期生成采购建议
+func planMaterials(tx *gorm.DB, runID uint, plannedOrders []models.MrpPlannedOrder, today time.Time) ([]models.MrpPurchaseSuggestion, error) {
+	// 生产需求：计划生产订单按计划开工日期计入
+	production := make(map[uint][]mrpBucket)
+	for _, planned := range plannedOrders {
+		production[planned.ProductID] = addMrpBucket(production[planned.ProductID], maxDate(planned.StartDate, today), planned.Quantity)
+	}
+
+	// 未完工（待开工和进行中）的工单按计划开工日期计入，已开工的按今天计入；返工工单不领用物料
+	var openOrders []models.ProductionOrder
+	if err := tx.Where("status IN ? AND order_type <> ?", []string{"pending", "processing"}, ProductionOrderTypeRework).
+		Find(&openOrders).Error; err != nil {
+		return nil, fmt.Errorf("获取未完工生产工单失败: %v", err)
+	}
+	productIDs := sortedMrpKeys(production)
+	orderIDs := make([]uint, 0, len(openOrders))
+	for _, order := range openOrders {
+		productIDs = append(productIDs, order.ProductID)
+		orderIDs = append(orderIDs, order.ID)
+	}
+
+	// 物料毛需求
+	needs := make(map[uint][]mrpBucket)
+	if len(productIDs) > 0 {
+		var consumption []models.ProductMaterial
+		if err := tx.Where("product_id IN ?", productIDs).Find(&consumption).Error; err != nil {
+			return nil, fmt.Errorf("获取物料消耗定额失败: %v", err)
+		}
+		boms := make(map[uint][]models.ProductMaterial)
+		for _, item := range consumption {
+			boms[item.ProductID] = append(boms[item.ProductID], item)
+			for _, bucket := range production[item.ProductID] {
+				quantity := roundQuantity(bucket.Quantity * item.QuantityPer * (1 + item.ScrapRate))
+				needs[item.MaterialID] = addMrpBucket(needs[item.MaterialID], bucket.Date, quantity)
+			}
+		}
+
+		// 工单需求为按工单数量展开的需求扣减已领用到该工单的净数量（领用减退料）
+		issued, err := issuedByProductionOrder(tx, orderIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, order := range openOrders {
+			date := today
+			if order.StartDate != nil {
+				date = maxDate(startOfDay(*order.StartDate), today)
+			}
+			for _, item := range boms[order.ProductID] {
+				quantity := roundQuantity(order.Quantity*item.QuantityPer*(1+item.ScrapRate) - issued[order.ID][item.MaterialID])
+				if quantity > 0 {
+					needs[item.MaterialID] = addMrpBucket(needs[item.MaterialID], date, quantity)
+				}
+			}
+		}
+	}
+
+	// 库存低于最小库存的物料即使没有生产需求也需补足
+	var materials []models.Material
+	if err := tx.Where("status = ? AND (id IN ? OR current_stock - blocked_stock < min_stock OR on_hold = ?)", 1, append(sortedMrpKeys(needs), 0), true).
+		Find(&materials).Error; err != nil {
+		return nil, fmt.Errorf("获取物料失败: %v", err)
+	}
+	sort.Slice(materials, func(i, j int) bool { return materials[i].ID < materials[j].ID })
+
+	receipts, err := openPurchaseReceiptsByMaterial(tx, today)
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestions []models.MrpPurchaseSuggestion
+	for i := range materials {
+		material := &materials[i]
+		precision := unitPrecision(tx, material.Unit)
+
+		available := roundQuantity(material.CurrentStock - blockedStock(material) - material.MinStock)
+		buckets := needs[material.ID]
+		if available < 0 && (len(buckets) == 0 || buckets[0].Date.After(today)) {
+			buckets = append([]mrpBucket{{Date: today}}, buckets...)
+		}
+
+		materialReceipts := receipts[material.ID]
+		next := 0
+		for _, bucket := range buckets {
+			for next < len(materialReceipts) && !materialReceipts[next].Date.After(bucket.Date) {
+				available = roundQuantity(available + materialReceipts[next].Quantity)
+				next++
+			}
+
+			if available < bucket.Quantity {
+				net := roundQuantity(bucket.Quantity - available)
+				quantity := ceilToPrecision(net, precision)
+				orderDate := bucket.Date.AddDate(0, 0, -material.LeadTimeDays)
+				suggestions = append(suggestions, models.MrpPurchaseSuggestion{
+					RunID:              runID,
+					MaterialID:         material.ID,
+					GrossRequirement:   bucket.Quantity,
+					ProjectedAvailable: available,
+					NetRequirement:     net,
+					Quantity:           quantity,
+					OrderDate:          orderDate,
+					NeedDate:           bucket.Date,
+					PastDue:            orderDate.Before(today),
+					Status:             "planned",
+				})
+				available = roundQuantity(available + quantity)
+			}
+			available = roundQuantity(available - bucket.Quantity)
+		}
+	}
+
+	return suggestions, nil
+}
+
+// issuedByProductionOrder 按生产工单和物料汇总已领用的净数量（生产领用减生产退料）
+func issuedByProductionOrder(tx *gorm.DB, orderIDs []uint) (map[uint]map[uint]float64, error) {
+	issued := make(map[uint]map[uint]float64)
+	if len(orderIDs) == 0 {
+		return issued, nil
+	}
+
+	var rows []struct {
+		ProductionOrderID uint
+		MaterialID        uint
+		Quantity          float64
+	}
+	if err := tx.Model(&models.MaterialTransaction{}).
+		Select("production_order_id, material_id, COALESCE(SUM(CASE WHEN type = 'out' THEN quantity ELSE -quantity END), 0) AS quantity").
+		Where("production_order_id IN ? AND type IN ?", orderIDs, []string{"out", "return_production"}).
+		Group("production_order_id, material_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("汇总工单领料数量失败: %v", err)
+	}
+
+	for _, row := range rows {
+		if issued[row.ProductionOrderID] == nil {
+			issued[row.ProductionOrderID] = make(map[uint]float64)
+		}
+		issued[row.ProductionOrderID][row.MaterialID] = roundQuantity(row.Quantity)
+	}
+	return issued, nil
+}
+
+// openPurchaseReceiptsByMaterial 按物料和要求到货日期汇总已下达未收齐的采购订单数量，已逾期的按今天到货，草稿订单未下达不计入
+func openPurchaseReceiptsByMaterial(tx *gorm.DB, today time.Time) (map[uint][]mrpBucket, error) {
+	var rows []struct {
+		MaterialID uint
+		DueDate    time.Time
+		Quantity   float64
+	}
+	if err := tx.Table("purchase_order_lines AS l").
+		Select("l.material_id, l.due_date, l.quantity - l.received_quantity AS quantity").
+		Joins("JOIN purchase_orders AS o ON o.id = l.purchase_order_id AND o.deleted_at IS NULL").
+		Where("l.deleted_at IS NULL AND l.status = ? AND o.status IN ?", "open", []string{"released", "partial"}).
+		Order("l.due_date").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("汇总采购在途数量失败: %v", err)
+	}
+
+	receipts := make(map[uint][]mrpBucket)
+	for _, row := range rows {
+		if row.Quantity <= 0 {
+			continue
+		}
+		receipts[row.MaterialID] = addMrpBucket(receipts[row.MaterialID], maxDate(startOfDay(row.DueDate), today), row.Quantity)
+	}
+	return receipts, nil
+}
+
+// checkMrpItemFirmable 校验MRP计划可以确认：所属运行仍有效且计划未确认或取消
+func checkMrpItemFirmable(db *gorm.DB, runID uint, status string) error {
+	if status != "planned" {
+		return errors.New("只有计划状态的MRP结果可以确认")
+	}
+
+	var run models.MrpRun
+	if err := db.First(&run, runID).Error; err != nil {
+		return fmt.Errorf("获取MRP运行记录失败: %v", err)
+	}
+	if run.Status != "active" {
+		return fmt.Errorf("MRP运行 %s 已被新的运行取代，请按最新运行结果确认", run.RunNo)
+	}
+	return nil
+}
+
+// 辅助函数：按日期累加到有序的日期汇总中
+func addMrpBucket(buckets []mrpBucket, date time.Time, quantity float64) []mrpBucket {
+	index := sort.Search(len(buckets), func(i int) bool { return !buckets[i].Date.Before(date) })
+	if index < len(buckets) && buckets[index].Date.Equal(date) {
+		buckets[index].Quantity = roundQuantity(buckets[index].Quantity + quantity)
+		return buckets
+	}
+
+	buckets = append(buckets, mrpBucket{})
+	copy(buckets[index+1:], buckets[index:])
+	buckets[index] = mrpBucket{Date: date, Quantity: roundQuantity(quantity)}
+	return buckets
+}
+
+// 辅助函数：按ID顺序返回汇总的键，保证计算结果稳定
+func sortedMrpKeys(buckets map[uint][]mrpBucket) []uint {
+	keys := make([]uint, 0, len(buckets))
+	for key := range buckets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// 辅助函数：取较晚的日期
+func maxDate(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// generateMrpRunNo 生成MRP运行编号
+func generateMrpRunNo(tx *gorm.DB) string {
+	prefix := fmt.Sprintf("MRP%s", time.Now().Format("20060102"))
+
+	var count int64
+	tx.Unscoped().Model(&models.MrpRun{}).
+		Where("run_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：校验产品并设置需求字段
+func (s *MrpService) applyDemandRequest(demand *models.ProductDemand, req *ProductDemandRequest) error {
+	var product models.Product
+	if err := s.db.First(&product, req.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("产品不存在")
+		}
+		return fmt.Errorf("获取产品失败: %v", err)
+	}
+	if product.Status != 1 {
+		return fmt.Errorf("产品 %s 已停用", product.Name)
+	}
+
+	demandType := req.Type
+	if demandType == "" {
+		demandType = "forecast"
+	}
+
+	demand.ProductID = req.ProductID
+	demand.DemandDate = startOfDay(req.DemandDate)
+	demand.Quantity = roundQuantity(req.Quantity)
+	demand.Type = demandType
+	demand.Remark = req.Remark
+	return nil
+}
+
+// 辅助函数：获取计划生产订单响应
+func (s *MrpService) getPlannedOrder(id uint) (*MrpPlannedOrderResponse, error) {
+	var planned models.MrpPlannedOrder
+	if err := s.db.Preload("Product").Preload("ProductionOrder").First(&planned, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("计划生产订单不存在")
+		}
+		return nil, fmt.Errorf("获取计划生产订单失败: %v", err)
+	}
+	return plannedOrderToResponse(&planned), nil
+}
+
+// 辅助函数：获取采购建议响应
+func (s *MrpService) getPurchaseSuggestion(id uint) (*MrpPurchaseSuggestionResponse, error) {
+	var suggestion models.MrpPurchaseSuggestion
+	if err := s.db.Preload("Material").Preload("PurchaseOrder").First(&suggestion, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("采购建议不存在")
+		}
+		return nil, fmt.Errorf("获取采购建议失败: %v", err)
+	}
+	return purchaseSuggestionToResponse(&suggestion), nil
+}
+
+// 辅助函数：转换产品需求为响应结构
+func demandToResponse(demand *models.ProductDemand) *ProductDemandResponse {
+	return &ProductDemandResponse{
+		ID:          demand.ID,
+		ProductID:   demand.ProductID,
+		ProductCode: demand.Product.Code,
+		ProductName: demand.Product.Name,
+		Unit:        demand.Product.Unit,
+		DemandDate:  demand.DemandDate,
+		Quantity:    demand.Quantity,
+		Type:        demand.Type,
+		Remark:      demand.Remark,
+		CreatedBy:   demand.CreatedBy,
+		CreatedAt:   demand.CreatedAt,
+	}
+}
+
+// 辅助函数：转换MRP运行记录为响应结构
+func runToResponse(run *models.MrpRun) *MrpRunResponse {
+	return &MrpRunResponse{
+		ID:                      run.ID,
+		RunNo:                   run.RunNo,
+		HorizonDays:             run.HorizonDays,
+		Status:                  run.Status,
+		PlannedOrderCount:       run.PlannedOrderCount,
+		PurchaseSuggestionCount: run.PurchaseSuggestionCount,
+		Remark:                  run.Remark,
+		CreatedBy:               run.CreatedBy,
+		CreatorName:             run.Creator.Username,
+		CreatedAt:               run.CreatedAt,
+	}
+}
+
+// 辅助函数：转换计划生产订单为响应结构
+func plannedOrderToResponse(planned *models.MrpPlannedOrder) *MrpPlannedOrderResponse {
+	resp := &MrpPlannedOrderResponse{
+		ID:                 planned.ID,
+		RunID:              planned.RunID,
+		ProductID:          planned.ProductID,
+		ProductCode:        planned.Product.Code,
+		ProductName:        planned.Product.Name,
+		Unit:               planned.Product.Unit,
+		GrossRequirement:   planned.GrossRequirement,
+		ProjectedAvailable: planned.ProjectedAvailable,
+		NetRequirement:     planned.NetRequirement,
+		Quantity:           planned.Quantity,
+		StartDate:          planned.StartDate,
+		DueDate:            planned.DueDate,
+		PastDue:            planned.PastDue,
+		Status:             planned.Status,
+		ProductionOrderID:  planned.ProductionOrderID,
+	}
+	if planned.ProductionOrder != nil {
+		resp.ProductionOrderNo = planned.ProductionOrder.OrderNo
+	}
+	return resp
+}
+
+// 辅助函数：转换采购建议为响应结构
+func purchaseSuggestionToResponse(suggestion *models.MrpPurchaseSuggestion) *MrpPurchaseSuggestionResponse {
+	resp := &MrpPurchaseSuggestionResponse{
+		ID:                 suggestion.ID,
+		RunID:              suggestion.RunID,
+		MaterialID:         suggestion.MaterialID,
+		MaterialCode:       suggestion.Material.Code,
+		MaterialName:       suggestion.Material.Name,
+		Unit:               suggestion.Material.Unit,
+		LeadTimeDays:       suggestion.Material.LeadTimeDays,
+		GrossRequirement:   suggestion.GrossRequirement,
+		ProjectedAvailable: suggestion.ProjectedAvailable,
+		NetRequirement:     suggestion.NetRequirement,
+		Quantity:           suggestion.Quantity,
+		OrderDate:          suggestion.OrderDate,
+		NeedDate:           suggestion.NeedDate,
+		PastDue:            suggestion.PastDue,
+		Status:             suggestion.Status,
+		PurchaseOrderID:    suggestion.PurchaseOrderID,
+	}
+	if suggestion.PurchaseOrder != nil {
+		resp.PurchaseOrderNo = suggestion.PurchaseOrder.OrderNo
+	}
+	return resp
+}
@@ -0,0 +1,702 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mes-system/internal/models"
+)
+
+// NCR处置方式
+const (
+	NcrDispositionScrap            = "scrap"              // 报废
+	NcrDispositionRework           = "rework"             // 返工
+	NcrDispositionUseAsIs          = "use_as_is"          // 让步接收
+	NcrDispositionReturnToSupplier = "return_to_supplier" // 退供应商
+)
+
+// NCR状态
+const (
+	NcrStatusOpen      = "open"      // 待处置
+	NcrStatusSubmitted = "submitted" // 处置待审批
+	NcrStatusApproved  = "approved"  // 处置已批准
+	NcrStatusCancelled = "cancelled" // 已取消
+)
+
+// NcrRequest 不合格品报告请求结构体
+type NcrRequest struct {
+	QualityInspectionID *uint   `json:"quality_inspection_id"`                     // 不合格的质量检测记录ID
+	ProductionOrderID   *uint   `json:"production_order_id"`                       // 生产工单ID
+	ProductID           uint    `json:"product_id"`                                // 产品ID，关联检测记录或生产工单时可不填
+	SupplierID          *uint   `json:"supplier_id"`                               // 责任供应商ID
+	AffectedQuantity    float64 `json:"affected_quantity" binding:"required,gt=0"` // 涉及数量
+	Description         string  `json:"description" binding:"required"`            // 不合格描述
+	Containment         string  `json:"containment"`                               // 围堵措施
+}
+
+// NcrDispositionRequest 提交处置请求结构体
+type NcrDispositionRequest struct {
+	Disposition string `json:"disposition" binding:"required,oneof=scrap rework use_as_is return_to_supplier"` // 处置方式
+	SupplierID  *uint  `json:"supplier_id"`                                                                    // 退供应商时的供应商ID，默认为报告的责任供应商
+	Remark      string `json:"remark"`                                                                         // 处置说明
+}
+
+// NcrApprovalRequest 审批处置请求结构体
+type NcrApprovalRequest struct {
+	Remark string `json:"remark"` // 审批意见，驳回时必填
+}
+
+// NcrLogResponse 不合格品报告操作记录响应结构体
+type NcrLogResponse struct {
+	ID           uint      `json:"id"`
+	Action       string    `json:"action"`
+	Disposition  string    `json:"disposition"`
+	OperatorID   uint      `json:"operator_id"`
+	OperatorName string    `json:"operator_name"`
+	OperatorRole string    `json:"operator_role"`
+	Remark       string    `json:"remark"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// NcrResponse 不合格品报告响应结构体
+type NcrResponse struct {
+	ID                  uint             `json:"id"`
+	NcrNo               string           `json:"ncr_no"`
+	Source              string           `json:"source"`
+	AutoCreated         bool             `json:"auto_created"`
+	QualityInspectionID *uint            `json:"quality_inspection_id"`
+	InspectionSheetID   *uint            `json:"inspection_sheet_id"`
+	InspectionLotID     *uint            `json:"inspection_lot_id"`
+	ProductionOrderID   *uint            `json:"production_order_id"`
+	ProductionOrderNo   string           `json:"production_order_no"`
+	ProductID           uint             `json:"product_id"`
+	ProductCode         string           `json:"product_code"`
+	ProductName         string           `json:"product_name"`
+	SupplierID          *uint            `json:"supplier_id"`
+	SupplierName        string           `json:"supplier_name"`
+	AffectedQuantity    float64          `json:"affected_quantity"`
+	Description         string           `json:"description"`
+	Containment         string           `json:"containment"`
+	Disposition         string           `json:"disposition"`
+	DispositionRemark   string           `json:"disposition_remark"`
+	Status              string           `json:"status"`
+	ReportedBy          uint             `json:"reported_by"`
+	ReporterName        string           `json:"reporter_name"`
+	SubmittedBy         *uint            `json:"submitted_by"`
+	SubmittedAt         *time.Time       `json:"submitted_at"`
+	ApprovedBy          *uint            `json:"approved_by"`
+	ApproverName        string           `json:"approver_name"`
+	ApprovedAt          *time.Time       `json:"approved_at"`
+	ReworkOrderID       *uint            `json:"rework_order_id"`
+	ReworkOrderNo       string           `json:"rework_order_no"`
+	Logs                []NcrLogResponse `json:"logs,omitempty"`
+	CreatedAt           time.Time        `json:"created_at"`
+	UpdatedAt           time.Time        `json:"updated_at"`
+}
+
+// NcrService 不合格品报告服务
+type NcrService struct {
+	db                *gorm.DB
+	productionService *ProductionService
+}
+
+// NewNcrService 创建不合格品报告服务实例
+func NewNcrService(db *gorm.DB, productionService *ProductionService) *NcrService {
+	return &NcrService{db: db, productionService: productionService}
+}
+
+// CreateNcr 手工创建不合格品报告，关联不合格的检测记录时带出生产工单和产品
+func (s *NcrService) CreateNcr(req *NcrRequest, operatorID uint, role string) (*NcrResponse, error) {
+	ncr := &models.NonconformanceReport{
+		Source:           "manual",
+		SupplierID:       req.SupplierID,
+		AffectedQuantity: roundQuantity(req.AffectedQuantity),
+		Description:      strings.TrimSpace(req.Description),
+		Containment:      req.Containment,
+		Status:           NcrStatusOpen,
+		ReportedBy:       operatorID,
+	}
+	if err := s.applyNcrSubject(ncr, req); err != nil {
+		return nil, err
+	}
+	if req.SupplierID != nil {
+		if err := validateNcrSupplier(s.db, *req.SupplierID); err != nil {
+			return nil, err
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		ncr.NcrNo = generateNcrNo(tx)
+		if err := tx.Create(ncr).Error; err != nil {
+			return fmt.Errorf("创建不合格品报告失败: %v", err)
+		}
+		return writeNcrLog(tx, ncr, "create", operatorID, role, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetNcr(ncr.ID)
+}
+
+// GetNcr 获取不合格品报告详情及操作记录
+func (s *NcrService) GetNcr(id uint) (*NcrResponse, error) {
+	ncr, err := s.loadNcr(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	return ncrToResponse(ncr, true), nil
+}
+
+// GetNcrList 获取不合格品报告列表
+func (s *NcrService) GetNcrList(page, pageSize int, status, disposition, source string, productionOrderID, productID uint, keyword string) ([]NcrResponse, int64, error) {
+	query := s.db.Model(&models.NonconformanceReport{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if disposition != "" {
+		query = query.Where("disposition = ?", disposition)
+	}
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if productionOrderID > 0 {
+		query = query.Where("production_order_id = ? OR rework_order_id = ?", productionOrderID, productionOrderID)
+	}
+	if productID > 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	if keyword != "" {
+		query = query.Where("ncr_no LIKE ? OR description LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取不合格品报告总数失败: %v", err)
+	}
+
+	var ncrs []models.NonconformanceReport
+	offset := (page - 1) * pageSize
+	if err := query.Preload("ProductionOrder").Preload("Product").Preload("Supplier").Preload("Reporter").
+		Preload("Approver").Preload("ReworkOrder").
+		Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&ncrs).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取不合格品报告列表失败: %v", err)
+	}
+
+	responses := make([]NcrResponse, 0, len(ncrs))
+	for i := range ncrs {
+		responses = append(responses, *ncrToResponse(&ncrs[i], false))
+	}
+	return responses, total, nil
+}
+
+// UpdateNcr 修改待处置的不合格品报告，自动创建的报告保留其来源关联
+func (s *NcrService) UpdateNcr(id uint, req *NcrRequest, operatorID uint, role string) (*NcrResponse, error) {
+	ncr, err := s.loadNcr(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if ncr.Status != NcrStatusOpen {
+		return nil, errors.New("只有待处置的不合格品报告可以修改")
+	}
+
+	if !ncr.AutoCreated {
+		if err := s.applyNcrSubject(ncr, req); err != nil {
+			return nil, err
+		}
+	}
+	if req.SupplierID != nil {
+		if err := validateNcrSupplier(s.db, *req.SupplierID); err != nil {
+			return nil, err
+		}
+	}
+	ncr.SupplierID = req.SupplierID
+	ncr.AffectedQuantity = roundQuantity(req.AffectedQuantity)
+	ncr.Description = strings.TrimSpace(req.Description)
+	ncr.Containment = req.Containment
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.NonconformanceReport{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"quality_inspection_id": ncr.QualityInspectionID,
+			"production_order_id":   ncr.ProductionOrderID,
+			"product_id":            ncr.ProductID,
+			"supplier_id":           ncr.SupplierID,
+			"affected_quantity":     ncr.AffectedQuantity,
+			"description":           ncr.Description,
+			"containment":           ncr.Containment,
+		}).Error; err != nil {
+			return fmt.Errorf("更新不合格品报告失败: %v", err)
+		}
+		return writeNcrLog(tx, ncr, "update", operatorID, role, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetNcr(id)
+}
+
+// SubmitNcrDisposition 提交处置方式，等待审批；退供应商必须指定责任供应商
+func (s *NcrService) SubmitNcrDisposition(id uint, req *NcrDispositionRequest, operatorID uint, role string) (*NcrResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		ncr, err := lockNcr(tx, id)
+		if err != nil {
+			return err
+		}
+		if ncr.Status != NcrStatusOpen {
+			return errors.New("只有待处置的不合格品报告可以提交处置")
+		}
+
+		supplierID := ncr.SupplierID
+		if req.SupplierID != nil {
+			supplierID = req.SupplierID
+		}
+		if req.Disposition == NcrDispositionReturnToSupplier {
+			if supplierID == nil {
+				return errors.New("退供应商处置必须指定供应商")
+			}
+			if err := validateNcrSupplier(tx, *supplierID); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		ncr.Disposition = req.Disposition
+		ncr.DispositionRemark = req.Remark
+		ncr.SupplierID = supplierID
+		ncr.Status = NcrStatusSubmitted
+		ncr.SubmittedBy = &operatorID
+		ncr.SubmittedAt = &now
+		if err := tx.Model(&models.NonconformanceReport{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"disposition":        ncr.Disposition,
+			"disposition_remark": ncr.DispositionRemark,
+			"supplier_id":        ncr.SupplierID,
+			"status":             ncr.Status,
+			"submitted_by":       ncr.SubmittedBy,
+			"submitted_at":       ncr.SubmittedAt,
+		}).Error; err != nil {
+			return fmt.Errorf("提交处置失败: %v", err)
+		}
+		return writeNcrLog(tx, ncr, "submit", operatorID, role, req.Remark)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetNcr(id)
+}
+
+// ApproveNcr 批准处置：让步接收仅管理员可批准；返工处置批准后按涉及数量生成返工生产工单
+func (s *NcrService) ApproveNcr(id uint, req *NcrApprovalRequest, operatorID uint, role string) (*NcrResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		ncr, err := lockNcr(tx, id)
+		if err != nil {
+			return err
+		}
+		if ncr.Status != NcrStatusSubmitted {
+			return errors.New("只有待审批的不合格品报告可以批准")
+		}
+		if ncr.Disposition == NcrDispositionUseAsIs && role != "admin" {
+			return errors.New("让步接收需要管理员批准")
+		}
+
+		// 返工工单与生产工单一样由生产服务创建，与批准在同一事务中回写到报告
+		var reworkOrderID *uint
+		if ncr.Disposition == NcrDispositionRework {
+			order, err := s.productionService.createProductionOrderTx(tx, &CreateProductionOrderRequest{
+				ProductID: ncr.ProductID,
+				Quantity:  ncr.AffectedQuantity,
+				OrderType: ProductionOrderTypeRework,
+			}, operatorID)
+			if err != nil {
+				return fmt.Errorf("生成返工生产工单失败: %v", err)
+			}
+			reworkOrderID = &order.ID
+		}
+
+		if err := tx.Model(&models.NonconformanceReport{}).Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"status":          NcrStatusApproved,
+				"approved_by":     operatorID,
+				"approved_at":     time.Now(),
+				"rework_order_id": reworkOrderID,
+			}).Error; err != nil {
+			return fmt.Errorf("批准处置失败: %v", err)
+		}
+		return writeNcrLog(tx, ncr, "approve", operatorID, role, req.Remark)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetNcr(id)
+}
+
+// RejectNcr 驳回处置，报告回到待处置状态以便重新提交
+func (s *NcrService) RejectNcr(id uint, req *NcrApprovalRequest, operatorID uint, role string) (*NcrResponse, error) {
+	if strings.TrimSpace(req.Remark) == "" {
+		return nil, errors.New("驳回处置必须填写审批意见")
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		ncr, err := lockNcr(tx, id)
+		if err != nil {
+			return err
+		}
+		if ncr.Status != NcrStatusSubmitted {
+			return errors.New("只有待审批的不合格品报告可以驳回")
+		}
+
+		if err := tx.Model(&models.NonconformanceReport{}).Where("id = ?", id).
+			Update("status", NcrStatusOpen).Error; err != nil {
+			return fmt.Errorf("驳回处置失败: %v", err)
+		}
+		return writeNcrLog(tx, ncr, "reject", operatorID, role, req.Remark)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetNcr(id)
+}
+
+// CancelNcr 取消未批准的不合格品报告
+func (s *NcrService) CancelNcr(id uint, req *NcrApprovalRequest, operatorID uint, role string) (*NcrResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		ncr, err := lockNcr(tx, id)
+		if err != nil {
+			return err
+		}
+		if ncr.Status != NcrStatusOpen && ncr.Status != NcrStatusSubmitted {
+			return errors.New("已批准或已取消的不合格品报告不能取消")
+		}
+
+		if err := tx.Model(&models.NonconformanceReport{}).Where("id = ?", id).
+			Update("status", NcrStatusCancelled).Error; err != nil {
+			return fmt.Errorf("取消不合格品报告失败: %v", err)
+		}
+		return writeNcrLog(tx, ncr, "cancel", operatorID, role, req.Remark)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetNcr(id)
+}
+
+// 辅助函数：按检测记录、生产工单或产品确定报告对象，检测记录必须不合格
+func (s *NcrService) applyNcrSubject(ncr *models.NonconformanceReport, req *NcrRequest) error {
+	ncr.QualityInspectionID = req.QualityInspectionID
+	ncr.ProductionOrderID = req.ProductionOrderID
+	ncr.ProductID = req.ProductID
+
+	if req.QualityInspectionID != nil {
+		var inspection models.QualityInspection
+		if err := s.db.First(&inspection, *req.QualityInspectionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("质量检测记录不存在")
+			}
+			return fmt.Errorf("验证质量检测记录失败: %v", err)
+		}
+		if inspection.Result != "fail" {
+			return errors.New("只能为不合格的检测记录创建不合格品报告")
+		}
+		if req.ProductionOrderID != nil && *req.ProductionOrderID != inspection.ProductionOrderID {
+			return errors.New("生产工单与检测记录不一致")
+		}
+		ncr.ProductionOrderID = &inspection.ProductionOrderID
+		ncr.InspectionSheetID = inspection.InspectionSheetID
+	}
+
+	if ncr.ProductionOrderID != nil {
+		var order models.ProductionOrder
+		if err := s.db.First(&order, *ncr.ProductionOrderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("生产工单不存在")
+			}
+			return fmt.Errorf("验证生产工单失败: %v", err)
+		}
+		if req.ProductID != 0 && req.ProductID != order.ProductID {
+			return errors.New("产品与生产工单不一致")
+		}
+		ncr.ProductID = order.ProductID
+	}
+
+	if ncr.ProductID == 0 {
+		return errors.New("请指定检测记录、生产工单或产品")
+	}
+	var product models.Product
+	if err := s.db.First(&product, ncr.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("产品不存在")
+		}
+		return fmt.Errorf("验证产品失败: %v", err)
+	}
+	return nil
+}
+
+// 辅助函数：同步检测结果自动创建的不合格品报告
+// 不合格且没有有效报告时创建；结果改为合格时取消仍待处置的自动报告，已提交处置的报告保留
+func syncAutoNcr(tx *gorm.DB, column string, sourceID uint, failed bool, build func() (*models.NonconformanceReport, error)) error {
+	var existing models.NonconformanceReport
+	err := tx.Where(column+" = ? AND auto_created = ? AND status <> ?", sourceID, true, NcrStatusCancelled).
+		Order("id DESC").First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("获取不合格品报告失败: %v", err)
+	}
+	found := err == nil
+
+	if !failed {
+		if found && existing.Status == NcrStatusOpen {
+			if err := tx.Model(&existing).Update("status", NcrStatusCancelled).Error; err != nil {
+				return fmt.Errorf("取消不合格品报告失败: %v", err)
+			}
+			return writeNcrLog(tx, &existing, "cancel", existing.ReportedBy, "", "检测结果已不再不合格，自动取消")
+		}
+		return nil
+	}
+	if found {
+		return nil
+	}
+
+	ncr, err := build()
+	if err != nil {
+		return err
+	}
+	ncr.AutoCreated = true
+	ncr.Status = NcrStatusOpen
+	ncr.NcrNo = generateNcrNo(tx)
+	if err := tx.Create(ncr).Error; err != nil {
+		return fmt.Errorf("创建不合格品报告失败: %v", err)
+	}
+	return writeNcrLog(tx, ncr, "create", ncr.ReportedBy, "", "由不合格检测结果自动创建")
+}
+
+// 辅助函数：同步单独录入的检测记录的自动不合格品报告，检测单明细按检测单汇总
+func syncInspectionNcr(tx *gorm.DB, inspection *models.QualityInspection, standard *models.QualityStandard) error {
+	if inspection.InspectionSheetID != nil {
+		return nil
+	}
+	return syncAutoNcr(tx, "quality_inspection_id", inspection.ID, inspection.Result == "fail", func() (*models.NonconformanceReport, error) {
+		var order models.ProductionOrder
+		if err := tx.Select("id", "product_id").First(&order, inspection.ProductionOrderID).Error; err != nil {
+			return nil, fmt.Errorf("获取生产工单失败: %v", err)
+		}
+		return &models.NonconformanceReport{
+			Source:              "inspection",
+			QualityInspectionID: &inspection.ID,
+			ProductionOrderID:   &inspection.ProductionOrderID,
+			ProductID:           order.ProductID,
+			AffectedQuantity:    1,
+			Description:         fmt.Sprintf("质量检测不合格：%s", ncrInspectionSummary(inspection, standard)),
+			ReportedBy:          inspection.InspectorID,
+		}, nil
+	})
+}
+
+// 辅助函数：同步检测单的自动不合格品报告，检验批的样本由检验批判定，不单独创建报告
+func syncSheetNcr(tx *gorm.DB, sheet *models.InspectionSheet) error {
+	failed := sheet.Result == "fail" && sheet.InspectionLotID == nil
+	return syncAutoNcr(tx, "inspection_sheet_id", sheet.ID, failed, func() (*models.NonconformanceReport, error) {
+		var order models.ProductionOrder
+		if err := tx.Select("id", "product_id").First(&order, sheet.ProductionOrderID).Error; err != nil {
+			return nil, fmt.Errorf("获取生产工单失败: %v", err)
+		}
+
+		var lines []models.QualityInspection
+		if err := tx.Preload("QualityStandard").Where("inspection_sheet_id = ? AND result = ?", sheet.ID, "fail").
+			Order("id").Find(&lines).Error; err != nil {
+			return nil, fmt.Errorf("获取检测明细失败: %v", err)
+		}
+		items := make([]string, 0, len(lines))
+		for i := range lines {
+			items = append(items, ncrInspectionSummary(&lines[i], &lines[i].QualityStandard))
+		}
+
+		return &models.NonconformanceReport{
+			Source:            "sheet",
+			InspectionSheetID: &sheet.ID,
+			ProductionOrderID: &sheet.ProductionOrderID,
+			ProductID:         order.ProductID,
+			AffectedQuantity:  1,
+			Description:       truncateNcrText(fmt.Sprintf("检测单 %s 样品 %d 不合格：%s", sheet.SheetNo, sheet.SampleNo, strings.Join(items, "；"))),
+			ReportedBy:        sheet.InspectorID,
+		}, nil
+	})
+}
+
+// 辅助函数：检验批判定不接收时创建不合格品报告，涉及数量为整批
+func raiseLotNcr(tx *gorm.DB, lot *models.InspectionLot, operatorID uint) error {
+	return syncAutoNcr(tx, "inspection_lot_id", lot.ID, lot.Disposition == LotDispositionRejected, func() (*models.NonconformanceReport, error) {
+		var order models.ProductionOrder
+		if err := tx.Select("id", "product_id").First(&order, lot.ProductionOrderID).Error; err != nil {
+			return nil, fmt.Errorf("获取生产工单失败: %v", err)
+		}
+		return &models.NonconformanceReport{
+			Source:            "lot",
+			InspectionLotID:   &lot.ID,
+			ProductionOrderID: &lot.ProductionOrderID,
+			ProductID:         order.ProductID,
+			AffectedQuantity:  float64(lot.LotSize),
+			Description: fmt.Sprintf("检验批 %s 不接收：样本量 %d，不合格数 %d，拒收数 %d",
+				lot.LotNo, lot.SampleSize, lot.NonconformingCount, lot.RejectNumber),
+			ReportedBy: operatorID,
+		}, nil
+	})
+}
+
+// 辅助函数：检测记录删除后取消仍待处置的自动不合格品报告
+func cancelAutoNcr(tx *gorm.DB, column string, sourceID uint) error {
+	return syncAutoNcr(tx, column, sourceID, false, nil)
+}
+
+// 辅助函数：描述检测记录的不合格项
+func ncrInspectionSummary(inspection *models.QualityInspection, standard *models.QualityStandard) string {
+	if isVariableCharacteristic(standard) {
+		return fmt.Sprintf("%s 实测 %v%s", standard.Name, inspection.ActualValue, standard.Unit)
+	}
+	if standard.CharacteristicType == "defect_count" {
+		return fmt.Sprintf("%s 缺陷数 %v，允许 %d", standard.Name, inspection.ActualValue, standard.MaxDefects)
+	}
+	return standard.Name
+}
+
+// 辅助函数：截断超出字段长度的描述
+func truncateNcrText(text string) string {
+	runes := []rune(text)
+	if len(runes) > 1000 {
+		return string(runes[:1000])
+	}
+	return text
+}
+
+// 辅助函数：验证供应商是否存在
+func validateNcrSupplier(db *gorm.DB, supplierID uint) error {
+	var supplier models.Supplier
+	if err := db.First(&supplier, supplierID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("供应商不存在")
+		}
+		return fmt.Errorf("验证供应商失败: %v", err)
+	}
+	return nil
+}
+
+// 辅助函数：写入不合格品报告操作记录
+func writeNcrLog(tx *gorm.DB, ncr *models.NonconformanceReport, action string, operatorID uint, role, remark string) error {
+	log := &models.NonconformanceReportLog{
+		NcrID:        ncr.ID,
+		Action:       action,
+		Disposition:  ncr.Disposition,
+		OperatorID:   operatorID,
+		OperatorRole: role,
+		Remark:       remark,
+	}
+	if err := tx.Create(log).Error; err != nil {
+		return fmt.Errorf("记录不合格品报告操作失败: %v", err)
+	}
+	return nil
+}
+
+// 辅助函数：锁定不合格品报告
+func lockNcr(tx *gorm.DB, id uint) (*models.NonconformanceReport, error) {
+	var ncr models.NonconformanceReport
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ncr, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("不合格品报告不存在")
+		}
+		return nil, fmt.Errorf("获取不合格品报告失败: %v", err)
+	}
+	return &ncr, nil
+}
+
+// 辅助函数：加载不合格品报告及关联数据
+func (s *NcrService) loadNcr(db *gorm.DB, id uint) (*models.NonconformanceReport, error) {
+	var ncr models.NonconformanceReport
+	if err := db.Preload("ProductionOrder").Preload("Product").Preload("Supplier").Preload("Reporter").
+		Preload("Approver").Preload("ReworkOrder").
+		Preload("Logs", func(db *gorm.DB) *gorm.DB { return db.Order("created_at, id") }).Preload("Logs.Operator").
+		First(&ncr, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("不合格品报告不存在")
+		}
+		return nil, fmt.Errorf("获取不合格品报告失败: %v", err)
+	}
+	return &ncr, nil
+}
+
+// 辅助函数：生成不合格品报告编号
+func generateNcrNo(tx *gorm.DB) string {
+	prefix := fmt.Sprintf("NCR%s", time.Now().Format("20060102"))
+
+	var count int64
+	tx.Unscoped().Model(&models.NonconformanceReport{}).
+		Where("ncr_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：将不合格品报告转换为响应结构体
+func ncrToResponse(ncr *models.NonconformanceReport, withLogs bool) *NcrResponse {
+	resp := &NcrResponse{
+		ID:                  ncr.ID,
+		NcrNo:               ncr.NcrNo,
+		Source:              ncr.Source,
+		AutoCreated:         ncr.AutoCreated,
+		QualityInspectionID: ncr.QualityInspectionID,
+		InspectionSheetID:   ncr.InspectionSheetID,
+		InspectionLotID:     ncr.InspectionLotID,
+		ProductionOrderID:   ncr.ProductionOrderID,
+		ProductID:           ncr.ProductID,
+		ProductCode:         ncr.Product.Code,
+		ProductName:         ncr.Product.Name,
+		SupplierID:          ncr.SupplierID,
+		AffectedQuantity:    ncr.AffectedQuantity,
+		Description:         ncr.Description,
+		Containment:         ncr.Containment,
+		Disposition:         ncr.Disposition,
+		DispositionRemark:   ncr.DispositionRemark,
+		Status:              ncr.Status,
+		ReportedBy:          ncr.ReportedBy,
+		ReporterName:        ncr.Reporter.Username,
+		SubmittedBy:         ncr.SubmittedBy,
+		SubmittedAt:         ncr.SubmittedAt,
+		ApprovedBy:          ncr.ApprovedBy,
+		ApprovedAt:          ncr.ApprovedAt,
+		ReworkOrderID:       ncr.ReworkOrderID,
+		CreatedAt:           ncr.CreatedAt,
+		UpdatedAt:           ncr.UpdatedAt,
+	}
+	if ncr.ProductionOrder != nil {
+		resp.ProductionOrderNo = ncr.ProductionOrder.OrderNo
+	}
+	if ncr.Supplier != nil {
+		resp.SupplierName = ncr.Supplier.Name
+	}
+	if ncr.Approver != nil {
+		resp.ApproverName = ncr.Approver.Username
+	}
+	if ncr.ReworkOrder != nil {
+		resp.ReworkOrderNo = ncr.ReworkOrder.OrderNo
+	}
+	if withLogs {
+		for _, log := range ncr.Logs {
+			resp.Logs = append(resp.Logs, NcrLogResponse{
+				ID:           log.ID,
+				Action:       log.Action,
+				Disposition:  log.Disposition,
+				OperatorID:   log.OperatorID,
+				OperatorName: log.Operator.Username,
+				OperatorRole: log.OperatorRole,
+				Remark:       log.Remark,
+				CreatedAt:    log.CreatedAt,
+			})
+		}
+	}
+	return resp
+}
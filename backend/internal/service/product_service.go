@@ -19,20 +19,22 @@ func NewProductService(db *gorm.DB) *ProductService {
 
 // CreateProductRequest 创建产品请求
 type CreateProductRequest struct {
-	Code        string  `json:"code" binding:"required,max=50"`
-	Name        string  `json:"name" binding:"required,max=100"`
-	Description string  `json:"description"`
-	Unit        string  `json:"unit" binding:"required,max=20"`
-	Price       float64 `json:"price" binding:"min=0"`
+	Code         string  `json:"code" binding:"required,max=50"`
+	Name         string  `json:"name" binding:"required,max=100"`
+	Description  string  `json:"description"`
+	Unit         string  `json:"unit" binding:"required,max=20"`
+	Price        float64 `json:"price" binding:"min=0"`
+	LeadTimeDays int     `json:"lead_time_days" binding:"min=0"` // 生产提前期（天）
 }
 
 // UpdateProductRequest 更新产品请求
 type UpdateProductRequest struct {
-	Name        *string  `json:"name,omitempty" binding:"omitempty,max=100"`
-	Description *string  `json:"description,omitempty"`
-	Unit        *string  `json:"unit,omitempty" binding:"omitempty,max=20"`
-	Price       *float64 `json:"price,omitempty" binding:"omitempty,min=0"`
-	Status      *int     `json:"status,omitempty" binding:"omitempty,oneof=0 1"`
+	Name         *string  `json:"name,omitempty" binding:"omitempty,max=100"`
+	Description  *string  `json:"description,omitempty"`
+	Unit         *string  `json:"unit,omitempty" binding:"omitempty,max=20"`
+	Price        *float64 `json:"price,omitempty" binding:"omitempty,min=0"`
+	LeadTimeDays *int     `json:"lead_time_days,omitempty" binding:"omitempty,min=0"` // 生产提前期（天）
+	Status       *int     `json:"status,omitempty" binding:"omitempty,oneof=0 1"`
 }
 
 // ProductListResponse 产品列表响应
@@ -54,12 +56,13 @@ func (s *ProductService) CreateProduct(req *CreateProductRequest) (*models.Produ
 
 	// 创建产品
 	product := models.Product{
-		Code:        req.Code,
-		Name:        req.Name,
-		Description: req.Description,
-		Unit:        req.Unit,
-		Price:       req.Price,
-		Status:      1,
+		Code:         req.Code,
+		Name:         req.Name,
+		Description:  req.Description,
+		Unit:         req.Unit,
+		Price:        req.Price,
+		LeadTimeDays: req.LeadTimeDays,
+		Status:       1,
 	}
 
 	err := s.db.Create(&product).Error
@@ -142,6 +145,10 @@ func (s *ProductService) UpdateProduct(id uint, req *UpdateProductRequest) (*mod
 		updateData["price"] = *req.Price
 	}
 
+	if req.LeadTimeDays != nil {
+		updateData["lead_time_days"] = *req.LeadTimeDays
+	}
+
 	if req.Status != nil {
 		updateData["status"] = *req.Status
 	}
@@ -170,6 +177,16 @@ func (s *ProductService) DeleteProduct(id uint) error {
 		return errors.New("该产品存在关联的生产工单，不能删除")
 	}
 
+	// 检查是否有成品库存
+	var product models.Product
+	err := s.db.First(&product, id).Error
+	if err != nil {
+		return err
+	}
+	if product.CurrentStock != 0 {
+		return errors.New("该产品存在成品库存，不能删除")
+	}
+
 	return s.db.Delete(&models.Product{}, id).Error
 }
 
@@ -0,0 +1,390 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"mes-system/internal/models"
+)
+
+// productInboundTransactionTypes 增加成品库存的交易类型，其余类型均视为减少库存
+var productInboundTransactionTypes = []string{"production_receipt", "adjust_in"}
+
+// productTransactionTypeNames 成品交易类型
+var productTransactionTypeNames = map[string]string{
+	"production_receipt":  "生产入库",
+	"production_reversal": "生产入库冲回",
+	"scrap":               "报废",
+	"adjust_in":           "盘盈调整",
+	"adjust_out":          "盘亏调整",
+	"shipment":            "发货出库",
+}
+
+// productTransactionReasonCodes 可手工登记的成品交易类型及其原因代码
+// 生产入库和冲回由生产工单报工自动登记，发货出库由发货单发货时登记，均不能手工创建
+var productTransactionReasonCodes = map[string]map[string]string{
+	"scrap":      transactionReasonCodes["scrap"],
+	"adjust_in":  countReasonCodes,
+	"adjust_out": countReasonCodes,
+}
+
+// ProductTransactionRequest 成品交易请求结构体
+type ProductTransactionRequest struct {
+	ProductID  uint    `json:"product_id" binding:"required"`    // 产品ID
+	Type       string  `json:"type" binding:"required"`          // 交易类型：scrap/adjust_in/adjust_out
+	Quantity   float64 `json:"quantity" binding:"required,gt=0"` // 数量
+	LotNo      string  `json:"lot_no"`                           // 成品批次号，盘盈调整为空时自动生成，其他交易可指定所用批次
+	ReasonCode string  `json:"reason_code" binding:"required"`   // 原因代码
+	Remark     string  `json:"remark" binding:"required"`        // 备注
+}
+
+// ProductTransactionResponse 成品交易响应结构体
+type ProductTransactionResponse struct {
+	ID                uint      `json:"id"`
+	ProductID         uint      `json:"product_id"`
+	ProductCode       string    `json:"product_code"`
+	ProductName       string    `json:"product_name"`
+	Unit              string    `json:"unit"`
+	Type              string    `json:"type"`
+	Quantity          float64   `json:"quantity"`
+	ProductionOrderID *uint     `json:"production_order_id"`
+	ShipmentLineID    *uint     `json:"shipment_line_id"`
+	LotNo             string    `json:"lot_no"`
+	ReasonCode        string    `json:"reason_code"`
+	Remark            string    `json:"remark"`
+	OperatorID        uint      `json:"operator_id"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// ProductLotResponse 成品批次结存
+type ProductLotResponse struct {
+	LotNo    string  `json:"lot_no"`
+	Quantity float64 `json:"quantity"`
+}
+
+// ProductStockResponse 成品库存响应结构体
+type ProductStockResponse struct {
+	ProductID    uint                 `json:"product_id"`
+	ProductCode  string               `json:"product_code"`
+	ProductName  string               `json:"product_name"`
+	Unit         string               `json:"unit"`
+	CurrentStock float64              `json:"current_stock"`
+	Lots         []ProductLotResponse `json:"lots"` // 按批次的结存，未指定批次的交易计入批次号为空的结存
+}
+
+// ProductStockService 成品库存服务
+type ProductStockService struct {
+	db *gorm.DB
+}
+
+// NewProductStockService 创建成品库存服务实例
+func NewProductStockService(db *gorm.DB) *ProductStockService {
+	return &ProductStockService{db: db}
+}
+
+// CreateTransaction 手工登记成品交易（报废和盘点调整）
+func (s *ProductStockService) CreateTransaction(req *ProductTransactionRequest, operatorID uint) (*ProductTransactionResponse, error) {
+	reasonCodes, ok := productTransactionReasonCodes[req.Type]
+	if !ok {
+		if _, exists := productTransactionTypeNames[req.Type]; exists {
+			return nil, fmt.Errorf("%s由业务单据自动登记，不能手工创建", productTransactionTypeNames[req.Type])
+		}
+		return nil, errors.New("无效的成品交易类型")
+	}
+	if _, ok := reasonCodes[req.ReasonCode]; !ok {
+		return nil, fmt.Errorf("无效的原因代码: %s", req.ReasonCode)
+	}
+
+	transaction := &models.ProductTransaction{
+		ProductID:  req.ProductID,
+		Type:       req.Type,
+		Quantity:   roundQuantity(req.Quantity),
+		LotNo:      req.LotNo,
+		ReasonCode: req.ReasonCode,
+		Remark:     req.Remark,
+		OperatorID: operatorID,
+	}
+
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := assignProductLot(tx, transaction); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	product, err := postProductTransaction(tx, transaction)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	return productTransactionToResponse(transaction, product), nil
+}
+
+// GetTransactionList 获取成品交易列表
+func (s *ProductStockService) GetTransactionList(page, pageSize int, productID, productionOrderID uint, transactionType, lotNo string) ([]ProductTransactionResponse, int64, error) {
+	var transactions []models.ProductTransaction
+	var total int64
+
+	query := s.db.Model(&models.ProductTransaction{}).Preload("Product")
+
+	if productID > 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	if productionOrderID > 0 {
+		query = query.Where("production_order_id = ?", productionOrderID)
+	}
+	if transactionType != "" {
+		query = query.Where("type = ?", transactionType)
+	}
+	if lotNo != "" {
+		query = query.Where("lot_no = ?", lotNo)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取成品交易总数失败: %v", err)
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Offset(offset).Limit(pageSize).Order("created_at DESC, id DESC").Find(&transactions).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取成品交易列表失败: %v", err)
+	}
+
+	responses := make([]ProductTransactionResponse, 0, len(transactions))
+	for i := range transactions {
+		responses = append(responses, *productTransactionToResponse(&transactions[i], &transactions[i].Product))
+	}
+
+	return responses, total, nil
+}
+
+// GetTransactionTypes 获取成品交易类型及其原因代码
+func (s *ProductStockService) GetTransactionTypes() []TransactionTypeResponse {
+	types := make([]TransactionTypeResponse, 0, len(productTransactionTypeNames))
+	for transactionType, name := range productTransactionTypeNames {
+		types = append(types, TransactionTypeResponse{
+			Type:        transactionType,
+			Name:        name,
+			Inbound:     isProductInboundTransactionType(transactionType),
+			ReasonCodes: productTransactionReasonCodes[transactionType],
+		})
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Type < types[j].Type
+	})
+	return types
+}
+
+// GetProductStock 获取产品的成品库存及批次结存
+func (s *ProductStockService) GetProductStock(productID uint) (*ProductStockResponse, error) {
+	var product models.Product
+	if err := s.db.First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("产品不存在")
+		}
+		return nil, fmt.Errorf("获取产品失败: %v", err)
+	}
+
+	var lots []ProductLotResponse
+	err := s.db.Model(&models.ProductTransaction{}).
+		Select("lot_no, SUM(CASE WHEN type IN ? THEN quantity ELSE -quantity END) AS quantity", productInboundTransactionTypes).
+		Where("product_id = ?", productID).
+		Group("lot_no").
+		Having("SUM(CASE WHEN type IN ? THEN quantity ELSE -quantity END) <> 0", productInboundTransactionTypes).
+		Order("lot_no").
+		Scan(&lots).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取批次结存失败: %v", err)
+	}
+	for i := range lots {
+		lots[i].Quantity = roundQuantity(lots[i].Quantity)
+	}
+	if lots == nil {
+		lots = []ProductLotResponse{}
+	}
+
+	return &ProductStockResponse{
+		ProductID:    product.ID,
+		ProductCode:  product.Code,
+		ProductName:  product.Name,
+		Unit:         product.Unit,
+		CurrentStock: product.CurrentStock,
+		Lots:         lots,
+	}, nil
+}
+
+// postProductTransaction 在事务中登记成品交易：校验库存并更新产品库存
+// 所有改变成品库存的业务都应通过该函数记账，返回更新后的产品
+func postProductTransaction(tx *gorm.DB, transaction *models.ProductTransaction) (*models.Product, error) {
+	// 锁定产品行，避免并发收发导致库存错误
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, transaction.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("产品不存在")
+		}
+		return nil, fmt.Errorf("获取产品失败: %v", err)
+	}
+
+	if transaction.Quantity <= 0 {
+		return nil, errors.New("交易数量必须大于0")
+	}
+
+	inbound := isProductInboundTransactionType(transaction.Type)
+	if !inbound && product.CurrentStock < transaction.Quantity {
+		return nil, fmt.Errorf("成品库存不足，当前库存为 %v", product.CurrentStock)
+	}
+
+	if err := tx.Create(transaction).Error; err != nil {
+		return nil, fmt.Errorf("创建成品交易记录失败: %v", err)
+	}
+
+	if inbound {
+		product.CurrentStock = roundQuantity(product.CurrentStock + transaction.Quantity)
+	} else {
+		product.CurrentStock = roundQuantity(product.CurrentStock - transaction.Quantity)
+	}
+
+	if err := tx.Model(&product).Update("current_stock", product.CurrentStock).Error; err != nil {
+		return nil, fmt.Errorf("更新成品库存失败: %v", err)
+	}
+
+	return &product, nil
+}
+
+// postProductionReceipt 按生产工单已生产数量的变化登记成品交易：增加时生产入库，减少时冲回
+// 入库未指定批次号时自动生成；冲回未指定批次号时取该工单最近一次入库的批次
+// 返工工单处理的是已入库的不合格品，报工不重复登记入库
+func postProductionReceipt(tx *gorm.DB, order *models.ProductionOrder, produced float64, lotNo string, operatorID uint) error {
+	if order.OrderType == ProductionOrderTypeRework {
+		return nil
+	}
+
+	delta := roundQuantity(produced - order.Produced)
+	if delta == 0 {
+		return nil
+	}
+
+	orderID := order.ID
+	transaction := &models.ProductTransaction{
+		ProductID:         order.ProductID,
+		Type:              "production_receipt",
+		Quantity:          delta,
+		ProductionOrderID: &orderID,
+		LotNo:             lotNo,
+		Remark:            fmt.Sprintf("生产工单 %s 报工入库", order.OrderNo),
+		OperatorID:        operatorID,
+	}
+
+	if delta < 0 {
+		transaction.Type = "production_reversal"
+		transaction.Quantity = -delta
+		transaction.Remark = fmt.Sprintf("生产工单 %s 已生产数量减少，冲回入库", order.OrderNo)
+
+		if transaction.LotNo == "" {
+			var last models.ProductTransaction
+			err := tx.Where("production_order_id = ? AND type = ?", order.ID, "production_receipt").
+				Order("id DESC").First(&last).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("获取工单入库批次失败: %v", err)
+			}
+			transaction.LotNo = last.LotNo
+		}
+	}
+
+	if err := assignProductLot(tx, transaction); err != nil {
+		return err
+	}
+
+	_, err := postProductTransaction(tx, transaction)
+	return err
+}
+
+// assignProductLot 生产入库和盘盈调整未指定批次号时生成批次号，其他交易指定批次号时校验该产品存在该批次的入库且结存足够
+func assignProductLot(tx *gorm.DB, transaction *models.ProductTransaction) error {
+	if isProductInboundTransactionType(transaction.Type) {
+		if transaction.LotNo == "" {
+			transaction.LotNo = generateProductLotNo(tx)
+		}
+		return nil
+	}
+
+	if transaction.LotNo == "" {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.ProductTransaction{}).
+		Where("product_id = ? AND type IN ? AND lot_no = ?", transaction.ProductID, productInboundTransactionTypes, transaction.LotNo).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("校验批次失败: %v", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("该产品没有批次 %s 的入库记录", transaction.LotNo)
+	}
+
+	// 锁定产品行后校验批次结存，避免并发出库超出批次数量
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&product, transaction.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("产品不存在")
+		}
+		return fmt.Errorf("获取产品失败: %v", err)
+	}
+	return checkProductLotBalance(tx, transaction.ProductID, transaction.LotNo, transaction.Quantity)
+}
+
+// generateProductLotNo 生成成品批次号
+func generateProductLotNo(tx *gorm.DB) string {
+	prefix := fmt.Sprintf("FG%s", time.Now().Format("20060102"))
+
+	var count int64
+	tx.Unscoped().Model(&models.ProductTransaction{}).
+		Where("lot_no LIKE ?", prefix+"%").
+		Distinct("lot_no").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// isProductInboundTransactionType 判断成品交易类型是否增加库存
+func isProductInboundTransactionType(transactionType string) bool {
+	for _, t := range productInboundTransactionTypes {
+		if t == transactionType {
+			return true
+		}
+	}
+	return false
+}
+
+// 辅助函数：转换成品交易为响应结构
+func productTransactionToResponse(transaction *models.ProductTransaction, product *models.Product) *ProductTransactionResponse {
+	return &ProductTransactionResponse{
+		ID:                transaction.ID,
+		ProductID:         transaction.ProductID,
+		ProductCode:       product.Code,
+		ProductName:       product.Name,
+		Unit:              product.Unit,
+		Type:              transaction.Type,
+		Quantity:          transaction.Quantity,
+		ProductionOrderID: transaction.ProductionOrderID,
+		ShipmentLineID:    transaction.ShipmentLineID,
+		LotNo:             transaction.LotNo,
+		ReasonCode:        transaction.ReasonCode,
+		Remark:            transaction.Remark,
+		OperatorID:        transaction.OperatorID,
+		CreatedAt:         transaction.CreatedAt,
+	}
+}
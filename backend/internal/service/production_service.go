@@ -7,6 +7,13 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 生产工单类型
+const (
+	ProductionOrderTypeStandard = "standard" // 标准生产
+	ProductionOrderTypeRework   = "rework"   // 不合格品返工，返工已入库的成品，不展开物料需求，报工不登记成品入库
 )
 
 // ProductionService 生产管理服务
@@ -21,21 +28,24 @@ func NewProductionService(db *gorm.DB) *ProductionService {
 
 // CreateProductionOrderRequest 创建生产工单请求
 type CreateProductionOrderRequest struct {
-	ProductID uint       `json:"product_id" binding:"required"`
-	Quantity  int        `json:"quantity" binding:"required,min=1"`
-	Priority  int        `json:"priority" binding:"min=1,max=5"`
-	StartDate *time.Time `json:"start_date"`
-	EndDate   *time.Time `json:"end_date"`
+	ProductID        uint       `json:"product_id" binding:"required"`
+	Quantity         float64    `json:"quantity" binding:"required,gt=0"`
+	Priority         int        `json:"priority" binding:"min=1,max=5"`
+	StartDate        *time.Time `json:"start_date"`
+	EndDate          *time.Time `json:"end_date"`
+	SalesOrderLineID *uint      `json:"sales_order_line_id"` // 按订单生产时关联的销售订单行
+	OrderType        string     `json:"-"`                   // 工单类型，返工工单只能由不合格品报告批准生成
 }
 
 // UpdateProductionOrderRequest 更新生产工单请求
 type UpdateProductionOrderRequest struct {
-	Quantity  *int       `json:"quantity,omitempty" binding:"omitempty,min=1"`
-	Produced  *int       `json:"produced,omitempty" binding:"omitempty,min=0"`
+	Quantity  *float64   `json:"quantity,omitempty" binding:"omitempty,gt=0"`
+	Produced  *float64   `json:"produced,omitempty" binding:"omitempty,min=0"`
 	Status    *string    `json:"status,omitempty"`
 	Priority  *int       `json:"priority,omitempty" binding:"omitempty,min=1,max=5"`
 	StartDate *time.Time `json:"start_date,omitempty"`
 	EndDate   *time.Time `json:"end_date,omitempty"`
+	LotNo     string     `json:"lot_no,omitempty"` // 本次报工入库的成品批次号，为空时自动生成
 }
 
 // ProductionOrderListResponse 生产工单列表响应
@@ -48,9 +58,30 @@ type ProductionOrderListResponse struct {
 
 // CreateProductionOrder 创建生产工单
 func (s *ProductionService) CreateProductionOrder(req *CreateProductionOrderRequest, createdBy uint) (*models.ProductionOrder, error) {
+	var order *models.ProductionOrder
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = s.createProductionOrderTx(tx, req, createdBy)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 预加载关联数据
+	err = s.db.Preload("Product").Preload("Creator").First(order, order.ID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// createProductionOrderTx 在事务中创建生产工单，按订单生产时锁定销售订单行后校验累计下达数量
+func (s *ProductionService) createProductionOrderTx(tx *gorm.DB, req *CreateProductionOrderRequest, createdBy uint) (*models.ProductionOrder, error) {
 	// 验证产品是否存在
 	var product models.Product
-	err := s.db.First(&product, req.ProductID).Error
+	err := tx.First(&product, req.ProductID).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("产品不存在")
@@ -58,8 +89,21 @@ func (s *ProductionService) CreateProductionOrder(req *CreateProductionOrderRequ
 		return nil, err
 	}
 
+	// 按订单生产时校验销售订单行
+	if req.SalesOrderLineID != nil {
+		err = checkSalesOrderLineForProduction(tx, *req.SalesOrderLineID, req.ProductID, req.Quantity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// 生成工单号
-	orderNo := s.generateOrderNo()
+	orderNo := s.generateOrderNo(tx)
+
+	orderType := req.OrderType
+	if orderType == "" {
+		orderType = ProductionOrderTypeStandard
+	}
 
 	// 设置默认优先级
 	priority := req.Priority
@@ -69,24 +113,20 @@ func (s *ProductionService) CreateProductionOrder(req *CreateProductionOrderRequ
 
 	// 创建生产工单
 	order := models.ProductionOrder{
-		OrderNo:   orderNo,
-		ProductID: req.ProductID,
-		Quantity:  req.Quantity,
-		Produced:  0,
-		Status:    "pending",
-		Priority:  priority,
-		StartDate: req.StartDate,
-		EndDate:   req.EndDate,
-		CreatedBy: createdBy,
-	}
-
-	err = s.db.Create(&order).Error
-	if err != nil {
-		return nil, err
+		OrderNo:          orderNo,
+		ProductID:        req.ProductID,
+		Quantity:         roundQuantity(req.Quantity),
+		Produced:         0,
+		Status:           "pending",
+		Priority:         priority,
+		OrderType:        orderType,
+		StartDate:        req.StartDate,
+		EndDate:          req.EndDate,
+		CreatedBy:        createdBy,
+		SalesOrderLineID: req.SalesOrderLineID,
 	}
 
-	// 预加载关联数据
-	err = s.db.Preload("Product").Preload("Creator").First(&order, order.ID).Error
+	err = tx.Create(&order).Error
 	if err != nil {
 		return nil, err
 	}
@@ -141,16 +181,26 @@ func (s *ProductionService) GetProductionOrderList(page, pageSize int, status, k
 	}, nil
 }
 
-// UpdateProductionOrder 更新生产工单
-func (s *ProductionService) UpdateProductionOrder(id uint, req *UpdateProductionOrderRequest) (*models.ProductionOrder, error) {
+// UpdateProductionOrder 更新生产工单，已生产数量变化时自动登记成品入库或冲回
+func (s *ProductionService) UpdateProductionOrder(id uint, req *UpdateProductionOrderRequest, operatorID uint) (*models.ProductionOrder, error) {
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 锁定工单行，避免并发报工重复入库
 	var order models.ProductionOrder
-	err := s.db.First(&order, id).Error
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
 	// 检查工单状态，已完成或已取消的工单不能修改
 	if order.Status == "completed" || order.Status == "cancelled" {
+		tx.Rollback()
 		return nil, errors.New("已完成或已取消的工单不能修改")
 	}
 
@@ -158,7 +208,19 @@ func (s *ProductionService) UpdateProductionOrder(id uint, req *UpdateProduction
 	updateData := make(map[string]interface{})
 
 	if req.Quantity != nil {
-		updateData["quantity"] = *req.Quantity
+		// 按订单生产的工单增加数量时，累计下达数量不能超过销售订单行订购数量
+		if order.SalesOrderLineID != nil && *req.Quantity > order.Quantity {
+			var line models.SalesOrderLine
+			if err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&line, *order.SalesOrderLineID).Error; err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if err = checkSalesLinePlannedQuantity(tx, &line, *req.Quantity, order.ID); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		updateData["quantity"] = roundQuantity(*req.Quantity)
 	}
 
 	if req.Produced != nil {
@@ -168,9 +230,21 @@ func (s *ProductionService) UpdateProductionOrder(id uint, req *UpdateProduction
 			quantity = *req.Quantity
 		}
 		if *req.Produced > quantity {
+			tx.Rollback()
 			return nil, errors.New("已生产数量不能超过计划数量")
 		}
-		updateData["produced"] = *req.Produced
+		// 已发货的成品不能冲回
+		if *req.Produced < order.Shipped {
+			tx.Rollback()
+			return nil, fmt.Errorf("已生产数量不能小于已发货数量 %v", order.Shipped)
+		}
+		updateData["produced"] = roundQuantity(*req.Produced)
+
+		// 已生产数量的变化登记为成品入库或冲回
+		if err = postProductionReceipt(tx, &order, *req.Produced, req.LotNo, operatorID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 
 		// 自动更新状态
 		if *req.Produced == 0 {
@@ -185,11 +259,28 @@ func (s *ProductionService) UpdateProductionOrder(id uint, req *UpdateProduction
 	if req.Status != nil {
 		// 验证状态转换的合法性
 		if !s.isValidStatusTransition(order.Status, *req.Status) {
+			tx.Rollback()
 			return nil, fmt.Errorf("不能从状态 %s 转换到 %s", order.Status, *req.Status)
 		}
 		updateData["status"] = *req.Status
 	}
 
+	// 存在未确认的阻止完成的质量异常事件时，不能手动完成；报工达到计划数量时保持进行中
+	if updateData["status"] == "completed" {
+		blocking, err := checkBlockingQualityEvents(tx, order.ID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if blocking > 0 {
+			if req.Status != nil && *req.Status == "completed" {
+				tx.Rollback()
+				return nil, fmt.Errorf("工单存在 %d 个未确认的质量异常事件，确认后才能完成", blocking)
+			}
+			updateData["status"] = "processing"
+		}
+	}
+
 	if req.Priority != nil {
 		updateData["priority"] = *req.Priority
 	}
@@ -203,8 +294,13 @@ func (s *ProductionService) UpdateProductionOrder(id uint, req *UpdateProduction
 	}
 
 	// 执行更新
-	err = s.db.Model(&order).Updates(updateData).Error
+	err = tx.Model(&order).Updates(updateData).Error
 	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = tx.Commit().Error; err != nil {
 		return nil, err
 	}
 
@@ -230,6 +326,11 @@ func (s *ProductionService) DeleteProductionOrder(id uint) error {
 		return errors.New("进行中的工单不能删除")
 	}
 
+	// 已发货的工单需保留发货追溯
+	if order.Shipped > 0 {
+		return errors.New("已发货的工单不能删除")
+	}
+
 	return s.db.Delete(&order).Error
 }
 
@@ -255,7 +356,7 @@ func (s *ProductionService) GetProductionStatistics() (map[string]interface{}, e
 	var todayStats struct {
 		TotalOrders    int64 `json:"total_orders"`
 		CompletedOrders int64 `json:"completed_orders"`
-		TotalProduced  int64 `json:"total_produced"`
+		TotalProduced  float64 `json:"total_produced"`
 	}
 
 	s.db.Model(&models.ProductionOrder{}).
@@ -276,7 +377,7 @@ func (s *ProductionService) GetProductionStatistics() (map[string]interface{}, e
 	// 本月生产趋势
 	var monthlyTrend []struct {
 		Date      string `json:"date"`
-		Produced  int64  `json:"produced"`
+		Produced  float64 `json:"produced"`
 		Completed int64  `json:"completed"`
 	}
 
@@ -296,13 +397,13 @@ func (s *ProductionService) GetProductionStatistics() (map[string]interface{}, e
 }
 
 // generateOrderNo 生成工单号
-func (s *ProductionService) generateOrderNo() string {
+func (s *ProductionService) generateOrderNo(tx *gorm.DB) string {
 	now := time.Now()
 	prefix := fmt.Sprintf("PO%s", now.Format("20060102"))
 
 	// 查询当天最大序号
 	var count int64
-	s.db.Model(&models.ProductionOrder{}).
+	tx.Model(&models.ProductionOrder{}).
 		Where("order_no LIKE ?", prefix+"%").
 		Count(&count)
 
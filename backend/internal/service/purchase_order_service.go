@@ -0,0 +1,623 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mes-system/internal/models"
+)
+
+// PurchaseOrderLineRequest 采购订单行请求结构体
+type PurchaseOrderLineRequest struct {
+	MaterialID uint      `json:"material_id" binding:"required"`   // 物料ID
+	Quantity   float64   `json:"quantity" binding:"required,gt=0"` // 采购数量（基本单位）
+	Price      float64   `json:"price" binding:"min=0"`            // 采购单价，为0时取物料标准单价
+	DueDate    time.Time `json:"due_date" binding:"required"`      // 要求到货日期
+	Remark     string    `json:"remark"`                           // 备注
+}
+
+// PurchaseOrderRequest 采购订单请求结构体
+type PurchaseOrderRequest struct {
+	SupplierID uint                       `json:"supplier_id" binding:"required"`      // 供应商ID
+	OrderDate  *time.Time                 `json:"order_date"`                          // 下单日期，默认为当前时间
+	Remark     string                     `json:"remark"`                              // 备注
+	Lines      []PurchaseOrderLineRequest `json:"lines" binding:"required,min=1,dive"` // 订单行
+}
+
+// PurchaseOrderLineResponse 采购订单行响应结构体
+type PurchaseOrderLineResponse struct {
+	ID               uint      `json:"id"`
+	LineNo           int       `json:"line_no"`
+	MaterialID       uint      `json:"material_id"`
+	MaterialCode     string    `json:"material_code"`
+	MaterialName     string    `json:"material_name"`
+	Unit             string    `json:"unit"`
+	Quantity         float64   `json:"quantity"`
+	ReceivedQuantity float64   `json:"received_quantity"`
+	OpenQuantity     float64   `json:"open_quantity"`
+	Price            float64   `json:"price"`
+	Amount           float64   `json:"amount"`
+	DueDate          time.Time `json:"due_date"`
+	Status           string    `json:"status"`
+	Remark           string    `json:"remark"`
+}
+
+// PurchaseOrderResponse 采购订单响应结构体
+type PurchaseOrderResponse struct {
+	ID          uint                        `json:"id"`
+	OrderNo     string                      `json:"order_no"`
+	SupplierID  *uint                       `json:"supplier_id"`
+	Supplier    string                      `json:"supplier"`
+	OrderDate   time.Time                   `json:"order_date"`
+	Status      string                      `json:"status"`
+	TotalAmount float64                     `json:"total_amount"`
+	Remark      string                      `json:"remark"`
+	CreatedBy   uint                        `json:"created_by"`
+	CreatorName string                      `json:"creator_name"`
+	Lines       []PurchaseOrderLineResponse `json:"lines,omitempty"`
+	CreatedAt   time.Time                   `json:"created_at"`
+	UpdatedAt   time.Time                   `json:"updated_at"`
+}
+
+// OverduePurchaseOrderLine 逾期未到货的采购订单行
+type OverduePurchaseOrderLine struct {
+	PurchaseOrderID  uint      `json:"purchase_order_id"`
+	OrderNo          string    `json:"order_no"`
+	SupplierID       *uint     `json:"supplier_id"`
+	Supplier         string    `json:"supplier"`
+	LineID           uint      `json:"line_id"`
+	LineNo           int       `json:"line_no"`
+	MaterialID       uint      `json:"material_id"`
+	MaterialCode     string    `json:"material_code"`
+	MaterialName     string    `json:"material_name"`
+	Quantity         float64   `json:"quantity"`
+	ReceivedQuantity float64   `json:"received_quantity"`
+	OpenQuantity     float64   `json:"open_quantity"`
+	DueDate          time.Time `json:"due_date"`
+	DaysOverdue      int       `json:"days_overdue"`
+}
+
+// PurchaseOrderService 采购订单服务
+type PurchaseOrderService struct {
+	db *gorm.DB
+}
+
+// NewPurchaseOrderService 创建采购订单服务实例
+func NewPurchaseOrderService(db *gorm.DB) *PurchaseOrderService {
+	return &PurchaseOrderService{db: db}
+}
+
+// CreatePurchaseOrder 创建采购订单（草稿状态）
+func (s *PurchaseOrderService) CreatePurchaseOrder(req *PurchaseOrderRequest, createdBy uint) (*PurchaseOrderResponse, error) {
+	order, err := s.createPurchaseOrderTx(s.db, req, createdBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetPurchaseOrder(order.ID)
+}
+
+// createPurchaseOrderTx 在指定事务中创建草稿采购订单，供其他业务在同一事务中生成采购订单
+func (s *PurchaseOrderService) createPurchaseOrderTx(tx *gorm.DB, req *PurchaseOrderRequest, createdBy uint) (*models.PurchaseOrder, error) {
+	supplier, lines, totalAmount, err := s.buildOrderLines(req)
+	if err != nil {
+		return nil, err
+	}
+
+	orderDate := time.Now()
+	if req.OrderDate != nil {
+		orderDate = *req.OrderDate
+	}
+
+	order := &models.PurchaseOrder{
+		OrderNo:     s.generateOrderNo(tx),
+		SupplierID:  &supplier.ID,
+		Supplier:    supplier.Name,
+		OrderDate:   orderDate,
+		Status:      "draft",
+		TotalAmount: totalAmount,
+		Remark:      req.Remark,
+		CreatedBy:   createdBy,
+		Lines:       lines,
+	}
+
+	if err := tx.Create(order).Error; err != nil {
+		return nil, fmt.Errorf("创建采购订单失败: %v", err)
+	}
+
+	return order, nil
+}
+
+// GetPurchaseOrder 获取采购订单详情
+func (s *PurchaseOrderService) GetPurchaseOrder(id uint) (*PurchaseOrderResponse, error) {
+	var order models.PurchaseOrder
+	err := s.db.Preload("Creator").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("line_no") }).
+		Preload("Lines.Material").
+		First(&order, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("采购订单不存在")
+		}
+		return nil, fmt.Errorf("获取采购订单失败: %v", err)
+	}
+
+	return s.orderToResponse(&order, true), nil
+}
+
+// GetPurchaseOrderList 获取采购订单列表
+func (s *PurchaseOrderService) GetPurchaseOrderList(page, pageSize int, status, keyword string) ([]PurchaseOrderResponse, int64, error) {
+	var orders []models.PurchaseOrder
+	var total int64
+
+	query := s.db.Model(&models.PurchaseOrder{})
+
+	// 按状态筛选
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 按订单号或供应商搜索
+	if keyword != "" {
+		query = query.Where("order_no LIKE ? OR supplier LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取采购订单总数失败: %v", err)
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Creator").Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&orders).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取采购订单列表失败: %v", err)
+	}
+
+	var responses []PurchaseOrderResponse
+	for _, order := range orders {
+		responses = append(responses, *s.orderToResponse(&order, false))
+	}
+
+	return responses, total, nil
+}
+
+// UpdatePurchaseOrder 更新采购订单，仅草稿状态可修改，订单行整体替换
+func (s *PurchaseOrderService) UpdatePurchaseOrder(id uint, req *PurchaseOrderRequest) (*PurchaseOrderResponse, error) {
+	var order models.PurchaseOrder
+	if err := s.db.First(&order, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("采购订单不存在")
+		}
+		return nil, fmt.Errorf("获取采购订单失败: %v", err)
+	}
+
+	if order.Status != "draft" {
+		return nil, errors.New("只有草稿状态的采购订单可以修改")
+	}
+
+	supplier, lines, totalAmount, err := s.buildOrderLines(req)
+	if err != nil {
+		return nil, err
+	}
+
+	order.SupplierID = &supplier.ID
+	order.Supplier = supplier.Name
+	if req.OrderDate != nil {
+		order.OrderDate = *req.OrderDate
+	}
+	order.TotalAmount = totalAmount
+	order.Remark = req.Remark
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("purchase_order_id = ?", order.ID).Delete(&models.PurchaseOrderLine{}).Error; err != nil {
+			return fmt.Errorf("删除原订单行失败: %v", err)
+		}
+
+		for i := range lines {
+			lines[i].PurchaseOrderID = order.ID
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return fmt.Errorf("创建订单行失败: %v", err)
+		}
+
+		if err := tx.Save(&order).Error; err != nil {
+			return fmt.Errorf("更新采购订单失败: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetPurchaseOrder(order.ID)
+}
+
+// DeletePurchaseOrder 删除采购订单，仅草稿或已取消的订单可删除
+func (s *PurchaseOrderService) DeletePurchaseOrder(id uint) error {
+	var order models.PurchaseOrder
+	if err := s.db.First(&order, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("采购订单不存在")
+		}
+		return fmt.Errorf("获取采购订单失败: %v", err)
+	}
+
+	if order.Status != "draft" && order.Status != "cancelled" {
+		return errors.New("只有草稿或已取消的采购订单可以删除")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("purchase_order_id = ?", order.ID).Delete(&models.PurchaseOrderLine{}).Error; err != nil {
+			return fmt.Errorf("删除订单行失败: %v", err)
+		}
+		if err := tx.Delete(&order).Error; err != nil {
+			return fmt.Errorf("删除采购订单失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// ReleasePurchaseOrder 下达采购订单，下达后才能收货
+func (s *PurchaseOrderService) ReleasePurchaseOrder(id uint) (*PurchaseOrderResponse, error) {
+	return s.changeStatus(id, "released")
+}
+
+// ClosePurchaseOrder 关闭采购订单，未收数量不再收货
+func (s *PurchaseOrderService) ClosePurchaseOrder(id uint) (*PurchaseOrderResponse, error) {
+	return s.changeStatus(id, "closed")
+}
+
+// CancelPurchaseOrder 取消采购订单，已有收货的订单不能取消
+func (s *PurchaseOrderService) CancelPurchaseOrder(id uint) (*PurchaseOrderResponse, error) {
+	return s.changeStatus(id, "cancelled")
+}
+
+// GetOverdueLines 获取截至指定日期逾期未收齐的采购订单行
+func (s *PurchaseOrderService) GetOverdueLines(asOf time.Time, supplier string, materialID uint) ([]OverduePurchaseOrderLine, error) {
+	today := startOfDay(asOf)
+
+	query := s.db.Model(&models.PurchaseOrderLine{}).
+		Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_lines.purchase_order_id AND purchase_orders.deleted_at IS NULL").
+		Where("purchase_orders.status IN ?", []string{"released", "partial"}).
+		Where("purchase_order_lines.status = ?", "open").
+		Where("purchase_order_lines.due_date < ?", today)
+
+	if supplier != "" {
+		query = query.Where("purchase_orders.supplier LIKE ?", "%"+supplier+"%")
+	}
+	if materialID > 0 {
+		query = query.Where("purchase_order_lines.material_id = ?", materialID)
+	}
+
+	var lines []models.PurchaseOrderLine
+	if err := query.Preload("Material").Order("purchase_order_lines.due_date, purchase_order_lines.id").Find(&lines).Error; err != nil {
+		return nil, fmt.Errorf("获取逾期订单行失败: %v", err)
+	}
+
+	// 批量加载订单头
+	orderIDs := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		orderIDs = append(orderIDs, line.PurchaseOrderID)
+	}
+	orders := make(map[uint]models.PurchaseOrder)
+	if len(orderIDs) > 0 {
+		var orderList []models.PurchaseOrder
+		if err := s.db.Where("id IN ?", orderIDs).Find(&orderList).Error; err != nil {
+			return nil, fmt.Errorf("获取采购订单失败: %v", err)
+		}
+		for _, order := range orderList {
+			orders[order.ID] = order
+		}
+	}
+
+	result := make([]OverduePurchaseOrderLine, 0, len(lines))
+	for _, line := range lines {
+		order := orders[line.PurchaseOrderID]
+		result = append(result, OverduePurchaseOrderLine{
+			PurchaseOrderID:  line.PurchaseOrderID,
+			OrderNo:          order.OrderNo,
+			SupplierID:       order.SupplierID,
+			Supplier:         order.Supplier,
+			LineID:           line.ID,
+			LineNo:           line.LineNo,
+			MaterialID:       line.MaterialID,
+			MaterialCode:     line.Material.Code,
+			MaterialName:     line.Material.Name,
+			Quantity:         line.Quantity,
+			ReceivedQuantity: line.ReceivedQuantity,
+			OpenQuantity:     roundQuantity(line.Quantity - line.ReceivedQuantity),
+			DueDate:          line.DueDate,
+			DaysOverdue:      int(today.Sub(startOfDay(line.DueDate)).Hours() / 24),
+		})
+	}
+
+	return result, nil
+}
+
+// receivePurchaseOrderLine 按采购订单行收货：校验订单行、补全单价和供应商，并更新未收数量和订单状态
+// 需在物料交易过账的同一事务中调用
+func receivePurchaseOrderLine(tx *gorm.DB, transaction *models.MaterialTransaction) error {
+	if transaction.Type != "in" {
+		return errors.New("只有入库交易可以关联采购订单行")
+	}
+
+	var line models.PurchaseOrderLine
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&line, *transaction.PurchaseOrderLineID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("采购订单行不存在")
+		}
+		return fmt.Errorf("获取采购订单行失败: %v", err)
+	}
+
+	var order models.PurchaseOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, line.PurchaseOrderID).Error; err != nil {
+		return fmt.Errorf("获取采购订单失败: %v", err)
+	}
+
+	if order.Status != "released" && order.Status != "partial" {
+		return fmt.Errorf("采购订单 %s 当前状态不允许收货", order.OrderNo)
+	}
+	if line.Status != "open" {
+		return fmt.Errorf("采购订单 %s 第 %d 行已收齐或已关闭", order.OrderNo, line.LineNo)
+	}
+	if line.MaterialID != transaction.MaterialID {
+		return errors.New("入库物料与采购订单行物料不一致")
+	}
+
+	// 不合格数量退回供应商，不计入订单行收货数量
+	openQuantity := roundQuantity(line.Quantity - line.ReceivedQuantity)
+	if transaction.Quantity > openQuantity {
+		return fmt.Errorf("收货数量超过采购订单行未收数量 %v", openQuantity)
+	}
+
+	// 收货供应商取采购订单上的供应商
+	if transaction.SupplierID != nil && order.SupplierID != nil && *transaction.SupplierID != *order.SupplierID {
+		return errors.New("收货供应商与采购订单供应商不一致")
+	}
+	transaction.SupplierID = order.SupplierID
+	if transaction.Supplier == "" {
+		transaction.Supplier = order.Supplier
+	}
+
+	// 未指定单价时取采购订单单价
+	if transaction.Price == 0 {
+		transaction.Price = line.Price
+		transaction.TotalAmount = roundAmount(transaction.Quantity * line.Price)
+	}
+
+	if transaction.Quantity == 0 {
+		return nil
+	}
+
+	// 更新订单行收货数量
+	line.ReceivedQuantity = roundQuantity(line.ReceivedQuantity + transaction.Quantity)
+	if line.ReceivedQuantity >= line.Quantity {
+		line.Status = "received"
+	}
+	if err := tx.Save(&line).Error; err != nil {
+		return fmt.Errorf("更新采购订单行失败: %v", err)
+	}
+
+	// 所有订单行收齐后订单变为已收货，否则为部分收货
+	var openLines int64
+	if err := tx.Model(&models.PurchaseOrderLine{}).
+		Where("purchase_order_id = ? AND status = ?", order.ID, "open").
+		Count(&openLines).Error; err != nil {
+		return fmt.Errorf("获取采购订单行失败: %v", err)
+	}
+
+	status := "partial"
+	if openLines == 0 {
+		status = "received"
+	}
+	if err := tx.Model(&order).Update("status", status).Error; err != nil {
+		return fmt.Errorf("更新采购订单状态失败: %v", err)
+	}
+
+	return nil
+}
+
+// reversePurchaseOrderReceipt 冲减采购订单行已收数量（来料检验不合格退货时），重新打开已收齐的订单行
+func reversePurchaseOrderReceipt(tx *gorm.DB, lineID uint, quantity float64) error {
+	var line models.PurchaseOrderLine
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&line, lineID).Error; err != nil {
+		return fmt.Errorf("获取采购订单行失败: %v", err)
+	}
+
+	var order models.PurchaseOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, line.PurchaseOrderID).Error; err != nil {
+		return fmt.Errorf("获取采购订单失败: %v", err)
+	}
+
+	line.ReceivedQuantity = roundQuantity(line.ReceivedQuantity - quantity)
+	if line.ReceivedQuantity < 0 {
+		line.ReceivedQuantity = 0
+	}
+
+	// 已关闭或已取消的订单只冲减数量，不再重新打开
+	if order.Status == "closed" || order.Status == "cancelled" {
+		return tx.Model(&line).Update("received_quantity", line.ReceivedQuantity).Error
+	}
+
+	if line.Status == "received" && line.ReceivedQuantity < line.Quantity {
+		line.Status = "open"
+	}
+	if err := tx.Save(&line).Error; err != nil {
+		return fmt.Errorf("更新采购订单行失败: %v", err)
+	}
+
+	var received int64
+	tx.Model(&models.PurchaseOrderLine{}).
+		Where("purchase_order_id = ? AND received_quantity > 0", order.ID).
+		Count(&received)
+
+	status := "partial"
+	if received == 0 {
+		status = "released"
+	}
+	if err := tx.Model(&order).Update("status", status).Error; err != nil {
+		return fmt.Errorf("更新采购订单状态失败: %v", err)
+	}
+
+	return nil
+}
+
+// 辅助函数：校验供应商并构建订单行，返回供应商和订单总金额
+func (s *PurchaseOrderService) buildOrderLines(orderReq *PurchaseOrderRequest) (*models.Supplier, []models.PurchaseOrderLine, float64, error) {
+	lines := make([]models.PurchaseOrderLine, 0, len(orderReq.Lines))
+	var supplier *models.Supplier
+	var totalAmount float64
+
+	for i, req := range orderReq.Lines {
+		var material models.Material
+		if err := s.db.First(&material, req.MaterialID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil, 0, fmt.Errorf("第 %d 行物料不存在", i+1)
+			}
+			return nil, nil, 0, fmt.Errorf("获取物料失败: %v", err)
+		}
+
+		// 订单行物料须在供应商合格物料清单中
+		lineSupplier, err := resolveReceiptSupplier(s.db, orderReq.SupplierID, req.MaterialID)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("第 %d 行: %v", i+1, err)
+		}
+		supplier = lineSupplier
+
+		price := req.Price
+		if price == 0 {
+			price = material.Price
+		}
+
+		lines = append(lines, models.PurchaseOrderLine{
+			LineNo:     i + 1,
+			MaterialID: req.MaterialID,
+			Quantity:   roundQuantity(req.Quantity),
+			Price:      price,
+			DueDate:    req.DueDate,
+			Status:     "open",
+			Remark:     req.Remark,
+		})
+		totalAmount += req.Quantity * price
+	}
+
+	return supplier, lines, roundAmount(totalAmount), nil
+}
+
+// 辅助函数：变更采购订单状态
+func (s *PurchaseOrderService) changeStatus(id uint, to string) (*PurchaseOrderResponse, error) {
+	var order models.PurchaseOrder
+	if err := s.db.First(&order, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("采购订单不存在")
+		}
+		return nil, fmt.Errorf("获取采购订单失败: %v", err)
+	}
+
+	if !s.isValidStatusTransition(order.Status, to) {
+		return nil, fmt.Errorf("不能从状态 %s 转换到 %s", order.Status, to)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&order).Update("status", to).Error; err != nil {
+			return fmt.Errorf("更新采购订单状态失败: %v", err)
+		}
+
+		// 关闭或取消订单时，未收齐的订单行一并关闭
+		if to == "closed" || to == "cancelled" {
+			if err := tx.Model(&models.PurchaseOrderLine{}).
+				Where("purchase_order_id = ? AND status = ?", order.ID, "open").
+				Update("status", "closed").Error; err != nil {
+				return fmt.Errorf("关闭采购订单行失败: %v", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetPurchaseOrder(order.ID)
+}
+
+// isValidStatusTransition 验证采购订单状态转换是否合法
+func (s *PurchaseOrderService) isValidStatusTransition(from, to string) bool {
+	validTransitions := map[string][]string{
+		"draft":     {"released", "cancelled"},
+		"released":  {"closed", "cancelled"},
+		"partial":   {"closed"},
+		"received":  {}, // 已收货状态不能转换
+		"closed":    {}, // 已关闭状态不能转换
+		"cancelled": {}, // 已取消状态不能转换
+	}
+
+	for _, state := range validTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// generateOrderNo 生成采购订单号
+func (s *PurchaseOrderService) generateOrderNo(tx *gorm.DB) string {
+	prefix := fmt.Sprintf("PUR%s", time.Now().Format("20060102"))
+
+	var count int64
+	tx.Unscoped().Model(&models.PurchaseOrder{}).
+		Where("order_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：转换为响应结构体
+func (s *PurchaseOrderService) orderToResponse(order *models.PurchaseOrder, withLines bool) *PurchaseOrderResponse {
+	resp := &PurchaseOrderResponse{
+		ID:          order.ID,
+		OrderNo:     order.OrderNo,
+		SupplierID:  order.SupplierID,
+		Supplier:    order.Supplier,
+		OrderDate:   order.OrderDate,
+		Status:      order.Status,
+		TotalAmount: order.TotalAmount,
+		Remark:      order.Remark,
+		CreatedBy:   order.CreatedBy,
+		CreatorName: order.Creator.Username,
+		CreatedAt:   order.CreatedAt,
+		UpdatedAt:   order.UpdatedAt,
+	}
+
+	if withLines {
+		for _, line := range order.Lines {
+			var openQuantity float64
+			if line.Status == "open" {
+				openQuantity = roundQuantity(line.Quantity - line.ReceivedQuantity)
+			}
+			resp.Lines = append(resp.Lines, PurchaseOrderLineResponse{
+				ID:               line.ID,
+				LineNo:           line.LineNo,
+				MaterialID:       line.MaterialID,
+				MaterialCode:     line.Material.Code,
+				MaterialName:     line.Material.Name,
+				Unit:             line.Material.Unit,
+				Quantity:         line.Quantity,
+				ReceivedQuantity: line.ReceivedQuantity,
+				OpenQuantity:     openQuantity,
+				Price:            line.Price,
+				Amount:           roundAmount(line.Quantity * line.Price),
+				DueDate:          line.DueDate,
+				Status:           line.Status,
+				Remark:           line.Remark,
+			})
+		}
+	}
+
+	return resp
+}
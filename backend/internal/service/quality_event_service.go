@@ -0,0 +1,414 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mes-system/internal/models"
+)
+
+// spcRuleBaselineSize 判异时用于估计中心线和标准差的最近测量值数量
+const spcRuleBaselineSize = 100
+
+// spcRuleNames Nelson判异规则说明，规则2采用AIAG的连续7点（Nelson原始规则为9点）
+var spcRuleNames = map[int]string{
+	1: "1点超出3σ控制限",
+	2: "连续7点落在中心线同一侧",
+	3: "连续6点持续上升或下降",
+	4: "连续14点交替上下",
+	5: "3点中有2点落在中心线同一侧的2σ以外",
+	6: "5点中有4点落在中心线同一侧的1σ以外",
+	7: "连续15点落在中心线两侧的1σ以内",
+	8: "连续8点落在中心线两侧且都在1σ以外",
+}
+
+// QualityEventResponse 质量异常事件响应结构体
+type QualityEventResponse struct {
+	ID                  uint       `json:"id"`
+	Type                string     `json:"type"`
+	QualityStandardID   uint       `json:"quality_standard_id"`
+	QualityStandardName string     `json:"quality_standard_name"`
+	QualityInspectionID uint       `json:"quality_inspection_id"`
+	ProductionOrderID   uint       `json:"production_order_id"`
+	ProductionOrderNo   string     `json:"production_order_no"`
+	Rule                int        `json:"rule"`
+	Description         string     `json:"description"`
+	Value               float64    `json:"value"`
+	CenterLine          float64    `json:"center_line"`
+	Sigma               float64    `json:"sigma"`
+	BlocksCompletion    bool       `json:"blocks_completion"`
+	Status              string     `json:"status"`
+	AcknowledgedBy      *uint      `json:"acknowledged_by"`
+	AcknowledgerName    string     `json:"acknowledger_name"`
+	AcknowledgedAt      *time.Time `json:"acknowledged_at"`
+	AcknowledgeRemark   string     `json:"acknowledge_remark"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// AcknowledgeQualityEventRequest 确认质量异常事件请求结构体
+type AcknowledgeQualityEventRequest struct {
+	Remark string `json:"remark"` // 确认说明，如原因分析和处理措施
+}
+
+// QualityEventService 质量异常事件服务
+type QualityEventService struct {
+	db *gorm.DB
+}
+
+// NewQualityEventService 创建质量异常事件服务实例
+func NewQualityEventService(db *gorm.DB) *QualityEventService {
+	return &QualityEventService{db: db}
+}
+
+// GetQualityEvent 获取质量异常事件详情
+func (s *QualityEventService) GetQualityEvent(id uint) (*QualityEventResponse, error) {
+	var event models.QualityEvent
+	if err := s.db.Preload("QualityStandard").Preload("ProductionOrder").Preload("Acknowledger").First(&event, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("质量异常事件不存在")
+		}
+		return nil, fmt.Errorf("获取质量异常事件失败: %v", err)
+	}
+	return qualityEventToResponse(&event), nil
+}
+
+// GetQualityEventList 获取质量异常事件列表
+func (s *QualityEventService) GetQualityEventList(page, pageSize int, status string, qualityStandardID, productionOrderID uint, rule int) ([]QualityEventResponse, int64, error) {
+	query := s.db.Model(&models.QualityEvent{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if qualityStandardID > 0 {
+		query = query.Where("quality_standard_id = ?", qualityStandardID)
+	}
+	if productionOrderID > 0 {
+		query = query.Where("production_order_id = ?", productionOrderID)
+	}
+	if rule > 0 {
+		query = query.Where("rule = ?", rule)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取质量异常事件总数失败: %v", err)
+	}
+
+	var events []models.QualityEvent
+	offset := (page - 1) * pageSize
+	if err := query.Preload("QualityStandard").Preload("ProductionOrder").Preload("Acknowledger").
+		Order("created_at DESC, id DESC").
+		Offset(offset).Limit(pageSize).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取质量异常事件列表失败: %v", err)
+	}
+
+	responses := make([]QualityEventResponse, 0, len(events))
+	for i := range events {
+		responses = append(responses, *qualityEventToResponse(&events[i]))
+	}
+	return responses, total, nil
+}
+
+// AcknowledgeQualityEvent 确认质量异常事件，确认后不再阻止生产工单完成
+func (s *QualityEventService) AcknowledgeQualityEvent(id uint, req *AcknowledgeQualityEventRequest, operatorID uint) (*QualityEventResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var event models.QualityEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("质量异常事件不存在")
+			}
+			return fmt.Errorf("获取质量异常事件失败: %v", err)
+		}
+		if event.Status != "open" {
+			return errors.New("质量异常事件已确认")
+		}
+
+		now := time.Now()
+		return tx.Model(&event).Updates(map[string]interface{}{
+			"status":             "acknowledged",
+			"acknowledged_by":    operatorID,
+			"acknowledged_at":    now,
+			"acknowledge_remark": req.Remark,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetQualityEvent(id)
+}
+
+// GetSpcRuleOptions 获取可配置的判异规则
+func (s *QualityEventService) GetSpcRuleOptions() map[int]string {
+	return spcRuleNames
+}
+
+// 辅助函数：规范化判异规则配置，返回去重排序后的规则编号字符串
+func normalizeSpcRules(rules string) (string, error) {
+	parsed, err := parseSpcRules(rules)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, len(parsed))
+	for i, rule := range parsed {
+		parts[i] = strconv.Itoa(rule)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// 辅助函数：解析逗号分隔的判异规则编号
+func parseSpcRules(rules string) ([]int, error) {
+	seen := make(map[int]bool)
+	var parsed []int
+	for _, part := range strings.Split(rules, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		rule, err := strconv.Atoi(part)
+		if err != nil || spcRuleNames[rule] == "" {
+			return nil, fmt.Errorf("无效的判异规则: %s", part)
+		}
+		if !seen[rule] {
+			seen[rule] = true
+			parsed = append(parsed, rule)
+		}
+	}
+	sort.Ints(parsed)
+	return parsed, nil
+}
+
+// 辅助函数：按质量标准启用的规则对最近测量值判异，为新检测记录触发的规则创建质量异常事件，计数型特性不判异
+// 中心线和标准差由最近的测量值按单值-移动极差估计，测量值不足时不判异
+func evaluateSpcRules(tx *gorm.DB, inspection *models.QualityInspection, standard *models.QualityStandard) ([]models.QualityEvent, error) {
+	if !isVariableCharacteristic(standard) {
+		return nil, nil
+	}
+
+	rules, err := parseSpcRules(standard.SpcRules)
+	if err != nil || len(rules) == 0 {
+		return nil, nil
+	}
+
+	var recent []models.QualityInspection
+	if err = tx.Select("id", "actual_value", "inspection_time").
+		Where("quality_standard_id = ?", standard.ID).
+		Where("inspection_time < ? OR (inspection_time = ? AND id <= ?)", inspection.InspectionTime, inspection.InspectionTime, inspection.ID).
+		Order("inspection_time DESC, id DESC").
+		Limit(spcRuleBaselineSize).
+		Find(&recent).Error; err != nil {
+		return nil, fmt.Errorf("获取最近检测记录失败: %v", err)
+	}
+	if len(recent) < spcMinSubgroups || recent[0].ID != inspection.ID {
+		return nil, nil
+	}
+
+	values := make([]float64, len(recent))
+	for i := range recent {
+		values[len(recent)-1-i] = recent[i].ActualValue
+	}
+
+	var total, mrSum float64
+	for i, v := range values {
+		total += v
+		if i > 0 {
+			mrSum += math.Abs(v - values[i-1])
+		}
+	}
+	center := total / float64(len(values))
+	sigma := mrSum / float64(len(values)-1) / spcD2[2]
+	if sigma == 0 {
+		return nil, nil
+	}
+
+	zones := make([]float64, len(values))
+	for i, v := range values {
+		zones[i] = (v - center) / sigma
+	}
+
+	var events []models.QualityEvent
+	for _, rule := range rules {
+		if !nelsonRuleViolated(rule, values, zones) {
+			continue
+		}
+
+		// 同一工单同一规则已有待确认事件时不重复创建
+		var openCount int64
+		if err = tx.Model(&models.QualityEvent{}).
+			Where("quality_standard_id = ? AND production_order_id = ? AND rule = ? AND status = ?", standard.ID, inspection.ProductionOrderID, rule, "open").
+			Count(&openCount).Error; err != nil {
+			return nil, fmt.Errorf("检查质量异常事件失败: %v", err)
+		}
+		if openCount > 0 {
+			continue
+		}
+
+		event := models.QualityEvent{
+			Type:                "spc_rule",
+			QualityStandardID:   standard.ID,
+			QualityInspectionID: inspection.ID,
+			ProductionOrderID:   inspection.ProductionOrderID,
+			Rule:                rule,
+			Description:         spcRuleNames[rule],
+			Value:               inspection.ActualValue,
+			CenterLine:          spcRound(center),
+			Sigma:               spcRound(sigma),
+			BlocksCompletion:    standard.SpcBlockCompletion,
+			Status:              "open",
+		}
+		if err = tx.Create(&event).Error; err != nil {
+			return nil, fmt.Errorf("创建质量异常事件失败: %v", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// 辅助函数：检测记录修改后重新判异，先删除该记录触发的待确认事件再按修改后的测量值判异，已确认的事件保留作为处理记录
+// 仅重新评估被修改的记录，其后检测记录的判异结果不回溯
+func reevaluateSpcRules(tx *gorm.DB, inspection *models.QualityInspection, standard *models.QualityStandard) ([]models.QualityEvent, error) {
+	if err := tx.Where("quality_inspection_id = ? AND status = ?", inspection.ID, "open").Delete(&models.QualityEvent{}).Error; err != nil {
+		return nil, fmt.Errorf("删除质量异常事件失败: %v", err)
+	}
+	return evaluateSpcRules(tx, inspection, standard)
+}
+
+// 辅助函数：判断检测记录的修改是否影响判异，测量值、质量标准、生产工单或检测时间变化时需要重新判异
+func spcInputsChanged(old, updated *models.QualityInspection) bool {
+	return old.ActualValue != updated.ActualValue ||
+		old.QualityStandardID != updated.QualityStandardID ||
+		old.ProductionOrderID != updated.ProductionOrderID ||
+		!old.InspectionTime.Equal(updated.InspectionTime)
+}
+
+// 辅助函数：判断以最后一个点结束的序列是否违反Nelson规则，zones为各点偏离中心线的σ倍数
+func nelsonRuleViolated(rule int, values, zones []float64) bool {
+	n := len(zones)
+	last := zones[n-1]
+	switch rule {
+	case 1:
+		return math.Abs(last) > 3
+	case 2:
+		if n < 7 {
+			return false
+		}
+		above, below := 0, 0
+		for _, z := range zones[n-7:] {
+			if z > 0 {
+				above++
+			} else if z < 0 {
+				below++
+			}
+		}
+		return above == 7 || below == 7
+	case 3:
+		if n < 6 {
+			return false
+		}
+		rising, falling := true, true
+		for i := n - 5; i < n; i++ {
+			rising = rising && values[i] > values[i-1]
+			falling = falling && values[i] < values[i-1]
+		}
+		return rising || falling
+	case 4:
+		if n < 14 {
+			return false
+		}
+		for i := n - 12; i < n; i++ {
+			prev, curr := values[i-1]-values[i-2], values[i]-values[i-1]
+			if prev*curr >= 0 {
+				return false
+			}
+		}
+		return true
+	case 5:
+		return n >= 3 && nelsonZoneCount(zones[n-3:], last, 2) >= 2
+	case 6:
+		return n >= 5 && nelsonZoneCount(zones[n-5:], last, 1) >= 4
+	case 7:
+		if n < 15 {
+			return false
+		}
+		for _, z := range zones[n-15:] {
+			if math.Abs(z) >= 1 {
+				return false
+			}
+		}
+		return true
+	case 8:
+		if n < 8 {
+			return false
+		}
+		above, below := false, false
+		for _, z := range zones[n-8:] {
+			if math.Abs(z) <= 1 {
+				return false
+			}
+			above = above || z > 0
+			below = below || z < 0
+		}
+		return above && below
+	}
+	return false
+}
+
+// 辅助函数：统计窗口内与最后一点同侧且超出limit倍σ的点数，最后一点本身未超出时返回0
+func nelsonZoneCount(window []float64, last, limit float64) int {
+	if math.Abs(last) <= limit {
+		return 0
+	}
+	count := 0
+	for _, z := range window {
+		if math.Abs(z) > limit && z*last > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// 辅助函数：检查生产工单是否存在阻止完成的待确认质量异常事件
+func checkBlockingQualityEvents(tx *gorm.DB, productionOrderID uint) (int64, error) {
+	var count int64
+	if err := tx.Model(&models.QualityEvent{}).
+		Where("production_order_id = ? AND status = ? AND blocks_completion = ?", productionOrderID, "open", true).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("检查质量异常事件失败: %v", err)
+	}
+	return count, nil
+}
+
+// 辅助函数：将质量异常事件模型转换为响应结构体
+func qualityEventToResponse(event *models.QualityEvent) *QualityEventResponse {
+	resp := &QualityEventResponse{
+		ID:                  event.ID,
+		Type:                event.Type,
+		QualityStandardID:   event.QualityStandardID,
+		QualityStandardName: event.QualityStandard.Name,
+		QualityInspectionID: event.QualityInspectionID,
+		ProductionOrderID:   event.ProductionOrderID,
+		ProductionOrderNo:   event.ProductionOrder.OrderNo,
+		Rule:                event.Rule,
+		Description:         event.Description,
+		Value:               event.Value,
+		CenterLine:          event.CenterLine,
+		Sigma:               event.Sigma,
+		BlocksCompletion:    event.BlocksCompletion,
+		Status:              event.Status,
+		AcknowledgedBy:      event.AcknowledgedBy,
+		AcknowledgedAt:      event.AcknowledgedAt,
+		AcknowledgeRemark:   event.AcknowledgeRemark,
+		CreatedAt:           event.CreatedAt,
+	}
+	if event.Acknowledger != nil {
+		resp.AcknowledgerName = event.Acknowledger.Username
+	}
+	return resp
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"mes-system/internal/models"
+)
+
+// 判异规则按 GB/T 17989.2-2020（ISO 7870-2）附录的八项检验模式，规则2采用AIAG的连续7点
+func TestNelsonRuleViolated(t *testing.T) {
+	repeat := func(z float64, n int) []float64 {
+		zones := make([]float64, n)
+		for i := range zones {
+			zones[i] = z
+		}
+		return zones
+	}
+	alternate := func(n int) []float64 {
+		zones := make([]float64, n)
+		for i := range zones {
+			zones[i] = 0.5
+			if i%2 == 1 {
+				zones[i] = -0.5
+			}
+		}
+		return zones
+	}
+
+	tests := []struct {
+		name  string
+		rule  int
+		zones []float64
+		want  bool
+	}{
+		{"规则1超出上控制限", 1, []float64{0.2, 3.2}, true},
+		{"规则1超出下控制限", 1, []float64{0.2, -3.5}, true},
+		{"规则1控制限以内", 1, []float64{0.2, 2.9}, false},
+		{"规则2连续7点在中心线上方", 2, repeat(0.4, 7), true},
+		{"规则2连续7点在中心线下方", 2, append([]float64{1}, repeat(-0.4, 7)...), true},
+		{"规则2仅连续6点同侧", 2, append([]float64{-0.4}, repeat(0.4, 6)...), false},
+		{"规则2中心线上的点不计", 2, append(repeat(0.4, 6), 0), false},
+		{"规则3连续6点上升", 3, []float64{-1, -0.8, -0.5, 0, 0.3, 0.9}, true},
+		{"规则3连续6点下降", 3, []float64{0.9, 0.3, 0, -0.5, -0.8, -1}, true},
+		{"规则3持平中断趋势", 3, []float64{-1, -0.8, -0.8, 0, 0.3, 0.9}, false},
+		{"规则4连续14点交替上下", 4, alternate(14), true},
+		{"规则4仅13点交替", 4, append([]float64{0.5}, alternate(13)...), false},
+		{"规则5三点中两点在同侧2σ以外", 5, []float64{2.1, 0.5, 2.2}, true},
+		{"规则5两点在两侧2σ以外", 5, []float64{2.1, 0.5, -2.2}, false},
+		{"规则5最后一点未超出2σ", 5, []float64{2.1, 2.2, 0.5}, false},
+		{"规则6五点中四点在同侧1σ以外", 6, []float64{1.2, 1.5, 0.3, 1.1, 1.4}, true},
+		{"规则6五点中三点在同侧1σ以外", 6, []float64{1.2, 0.5, 0.3, 1.1, 1.4}, false},
+		{"规则7连续15点在1σ以内", 7, alternate(15), true},
+		{"规则7有1点在1σ上", 7, append(alternate(14), 1), false},
+		{"规则8连续8点在两侧1σ以外", 8, []float64{1.5, -1.2, 1.1, -2, 1.3, -1.4, 1.6, -1.1}, true},
+		{"规则8连续8点在同侧1σ以外", 8, repeat(1.5, 8), false},
+		{"规则8有1点在1σ以内", 8, []float64{1.5, -1.2, 1.1, -0.9, 1.3, -1.4, 1.6, -1.1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 测量值取σ倍数本身，即中心线为0、σ为1
+			if got := nelsonRuleViolated(tt.rule, tt.zones, tt.zones); got != tt.want {
+				t.Errorf("规则%d = %v，期望 %v", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpcInputsChanged(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local)
+	old := models.QualityInspection{ActualValue: 10.1, QualityStandardID: 1, ProductionOrderID: 1, InspectionTime: at, Remark: "首件"}
+	tests := []struct {
+		name   string
+		modify func(*models.QualityInspection)
+		want   bool
+	}{
+		{"仅修改备注", func(q *models.QualityInspection) { q.Remark = "复检" }, false},
+		{"修改人工改判结果", func(q *models.QualityInspection) { q.Result, q.Overridden = "pass", true }, false},
+		{"修改测量值", func(q *models.QualityInspection) { q.ActualValue = 10.3 }, true},
+		{"修改质量标准", func(q *models.QualityInspection) { q.QualityStandardID = 2 }, true},
+		{"修改生产工单", func(q *models.QualityInspection) { q.ProductionOrderID = 2 }, true},
+		{"修改检测时间", func(q *models.QualityInspection) { q.InspectionTime = at.Add(time.Hour) }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := old
+			tt.modify(&updated)
+			if got := spcInputsChanged(&old, &updated); got != tt.want {
+				t.Errorf("需要重新判异 = %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,68 +13,112 @@ import (
 
 // QualityStandardRequest 质量标准请求结构体
 type QualityStandardRequest struct {
-	ProductID   uint    `json:"product_id" binding:"required"`   // 产品ID
-	Name        string  `json:"name" binding:"required"`         // 标准名称
-	Type        string  `json:"type" binding:"required"`         // 检测类型
-	MinValue    float64 `json:"min_value"`                       // 最小值
-	MaxValue    float64 `json:"max_value"`                       // 最大值
-	TargetValue float64 `json:"target_value"`                    // 目标值
-	Unit        string  `json:"unit"`                            // 单位
-	Description string  `json:"description"`                     // 描述
-	IsActive    bool    `json:"is_active"`                       // 是否启用
+	ProductID          uint    `json:"product_id" binding:"required"` // 产品ID
+	Name               string  `json:"name" binding:"required"`       // 标准名称
+	Type               string  `json:"type" binding:"required"`       // 检测类型
+	CharacteristicType string  `json:"characteristic_type"`           // 特性类型：variable/attribute/defect_count，默认variable
+	MaxDefects         int     `json:"max_defects" binding:"min=0"`   // 缺陷数型特性允许的最大缺陷数（含）
+	MinValue           float64 `json:"min_value"`                     // 最小值
+	MaxValue           float64 `json:"max_value"`                     // 最大值
+	LimitType          string  `json:"limit_type"`                    // 规格限类型：two_sided/lower/upper，默认two_sided
+	MinBoundary        string  `json:"min_boundary"`                  // 下限边界：inclusive/exclusive，默认inclusive
+	MaxBoundary        string  `json:"max_boundary"`                  // 上限边界：inclusive/exclusive，默认inclusive
+	TargetValue        float64 `json:"target_value"`                  // 目标值
+	Unit               string  `json:"unit"`                          // 单位
+	Description        string  `json:"description"`                   // 描述
+	IsActive           bool    `json:"is_active"`                     // 是否启用
+	SpcRules           *string `json:"spc_rules"`                     // 启用的Nelson判异规则编号，逗号分隔，为空不判异；不传时新建默认启用全部规则、更新保持不变
+	SpcBlockCompletion bool    `json:"spc_block_completion"`          // 存在未确认的判异事件时禁止生产工单完成
 }
 
 // QualityStandardResponse 质量标准响应结构体
 type QualityStandardResponse struct {
-	ID          uint      `json:"id"`
-	ProductID   uint      `json:"product_id"`
-	ProductCode string    `json:"product_code"`
-	ProductName string    `json:"product_name"`
-	Name        string    `json:"name"`
-	Type        string    `json:"type"`
-	MinValue    float64   `json:"min_value"`
-	MaxValue    float64   `json:"max_value"`
-	TargetValue float64   `json:"target_value"`
-	Unit        string    `json:"unit"`
-	Description string    `json:"description"`
-	IsActive    bool      `json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                 uint      `json:"id"`
+	ProductID          uint      `json:"product_id"`
+	ProductCode        string    `json:"product_code"`
+	ProductName        string    `json:"product_name"`
+	Name               string    `json:"name"`
+	Type               string    `json:"type"`
+	CharacteristicType string    `json:"characteristic_type"`
+	MaxDefects         int       `json:"max_defects"`
+	MinValue           float64   `json:"min_value"`
+	MaxValue           float64   `json:"max_value"`
+	LimitType          string    `json:"limit_type"`
+	MinBoundary        string    `json:"min_boundary"`
+	MaxBoundary        string    `json:"max_boundary"`
+	TargetValue        float64   `json:"target_value"`
+	Unit               string    `json:"unit"`
+	Description        string    `json:"description"`
+	IsActive           bool      `json:"is_active"`
+	SpcRules           string    `json:"spc_rules"`
+	SpcBlockCompletion bool      `json:"spc_block_completion"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 // QualityInspectionRequest 质量检测请求结构体
 type QualityInspectionRequest struct {
-	ProductionOrderID   uint    `json:"production_order_id" binding:"required"` // 生产工单ID
-	QualityStandardID   uint    `json:"quality_standard_id" binding:"required"` // 质量标准ID
-	InspectorID         uint    `json:"inspector_id" binding:"required"`        // 检测员ID
-	ActualValue         float64 `json:"actual_value" binding:"required"`        // 实际值
-	Result              string  `json:"result" binding:"required"`              // 检测结果：pass/fail
-	Remark              string  `json:"remark"`                                  // 备注
-	InspectionTime      *time.Time `json:"inspection_time"`                     // 检测时间
+	ProductionOrderID uint                      `json:"production_order_id" binding:"required"` // 生产工单ID
+	QualityStandardID uint                      `json:"quality_standard_id" binding:"required"` // 质量标准ID
+	InspectorID       uint                      `json:"inspector_id" binding:"required"`        // 检测员ID
+	EquipmentID       *uint                     `json:"equipment_id"`                           // 生产设备ID
+	ActualValue       float64                   `json:"actual_value"`                           // 实际值，计量型特性为测量值，缺陷数型特性未填缺陷明细时为缺陷数
+	Result            string                    `json:"result"`                                 // 检测结果：pass/fail，不填由系统按规格限判定，修改时原记录经人工改判则保留改判结果；与系统判定不一致时为人工改判
+	OverrideReason    string                    `json:"override_reason"`                        // 人工改判原因
+	Remark            string                    `json:"remark"`                                 // 备注
+	InspectionTime    *time.Time                `json:"inspection_time"`                        // 检测时间
+	Defects           []InspectionDefectRequest `json:"defects" binding:"omitempty,dive"`       // 缺陷明细
+}
+
+// InspectionDefectRequest 检测缺陷明细请求结构体
+type InspectionDefectRequest struct {
+	DefectCodeID uint   `json:"defect_code_id" binding:"required"` // 缺陷代码ID
+	Quantity     int    `json:"quantity" binding:"required,gt=0"`  // 缺陷数量
+	Remark       string `json:"remark"`                            // 备注
+}
+
+// InspectionDefectResponse 检测缺陷明细响应结构体
+type InspectionDefectResponse struct {
+	ID             uint   `json:"id"`
+	DefectCodeID   uint   `json:"defect_code_id"`
+	DefectCode     string `json:"defect_code"`
+	DefectName     string `json:"defect_name"`
+	DefectSeverity string `json:"defect_severity"`
+	Quantity       int    `json:"quantity"`
+	Remark         string `json:"remark"`
 }
 
 // QualityInspectionResponse 质量检测响应结构体
 type QualityInspectionResponse struct {
-	ID                  uint      `json:"id"`
-	ProductionOrderID   uint      `json:"production_order_id"`
-	ProductionOrderNo   string    `json:"production_order_no"`
-	QualityStandardID   uint      `json:"quality_standard_id"`
-	QualityStandardName string    `json:"quality_standard_name"`
-	InspectorID         uint      `json:"inspector_id"`
-	InspectorName       string    `json:"inspector_name"`
-	ActualValue         float64   `json:"actual_value"`
-	TargetValue         float64   `json:"target_value"`
-	MinValue            float64   `json:"min_value"`
-	MaxValue            float64   `json:"max_value"`
-	Unit                string    `json:"unit"`
-	Result              string    `json:"result"`
-	Remark              string    `json:"remark"`
-	InspectionTime      time.Time `json:"inspection_time"`
-	CreatedAt           time.Time `json:"created_at"`
+	ID                  uint                       `json:"id"`
+	InspectionSheetID   *uint                      `json:"inspection_sheet_id"` // 所属检测单，单项检测为空
+	ProductionOrderID   uint                       `json:"production_order_id"`
+	ProductionOrderNo   string                     `json:"production_order_no"`
+	QualityStandardID   uint                       `json:"quality_standard_id"`
+	QualityStandardName string                     `json:"quality_standard_name"`
+	CharacteristicType  string                     `json:"characteristic_type"`
+	InspectorID         uint                       `json:"inspector_id"`
+	InspectorName       string                     `json:"inspector_name"`
+	EquipmentID         *uint                      `json:"equipment_id"`
+	EquipmentName       string                     `json:"equipment_name"`
+	ActualValue         float64                    `json:"actual_value"`
+	TargetValue         float64                    `json:"target_value"`
+	MinValue            float64                    `json:"min_value"`
+	MaxValue            float64                    `json:"max_value"`
+	Unit                string                     `json:"unit"`
+	Result              string                     `json:"result"`
+	JudgedResult        string                     `json:"judged_result"` // 系统判定结果
+	Overridden          bool                       `json:"overridden"`    // 结果经人工改判
+	Remark              string                     `json:"remark"`
+	InspectionTime      time.Time                  `json:"inspection_time"`
+	CreatedAt           time.Time                  `json:"created_at"`
+	Defects             []InspectionDefectResponse `json:"defects,omitempty"`        // 缺陷明细
+	QualityEvents       []QualityEventResponse     `json:"quality_events,omitempty"` // 本次测量触发的判异事件
 }
 
 // QualityStatistics 质量统计结构体
 type QualityStatistics struct {
+	Level            string  `json:"level"` // 统计层级：line 按检测明细，sheet 按检测单
 	TotalInspections int     `json:"total_inspections"`
 	PassedCount      int     `json:"passed_count"`
 	FailedCount      int     `json:"failed_count"`
@@ -101,13 +147,9 @@ func (s *QualityService) CreateQualityStandard(req *QualityStandardRequest) (*Qu
 		return nil, fmt.Errorf("验证产品失败: %v", err)
 	}
 
-	// 验证数值范围
-	if req.MinValue >= req.MaxValue {
-		return nil, errors.New("最小值必须小于最大值")
-	}
-
-	if req.TargetValue < req.MinValue || req.TargetValue > req.MaxValue {
-		return nil, errors.New("目标值必须在最小值和最大值之间")
+	// 验证规格限类型和数值范围
+	if err := normalizeQualityStandardLimits(req); err != nil {
+		return nil, err
 	}
 
 	// 检查同一产品下是否已存在相同名称的质量标准
@@ -116,21 +158,44 @@ func (s *QualityService) CreateQualityStandard(req *QualityStandardRequest) (*Qu
 	}
 
 	qualityStandard := &models.QualityStandard{
-		ProductID:   req.ProductID,
-		Name:        req.Name,
-		Type:        req.Type,
-		MinValue:    req.MinValue,
-		MaxValue:    req.MaxValue,
-		TargetValue: req.TargetValue,
-		Unit:        req.Unit,
-		Description: req.Description,
-		IsActive:    req.IsActive,
+		ProductID:          req.ProductID,
+		Name:               req.Name,
+		Type:               req.Type,
+		CharacteristicType: req.CharacteristicType,
+		MaxDefects:         req.MaxDefects,
+		MinValue:           req.MinValue,
+		MaxValue:           req.MaxValue,
+		LimitType:          req.LimitType,
+		MinBoundary:        req.MinBoundary,
+		MaxBoundary:        req.MaxBoundary,
+		TargetValue:        req.TargetValue,
+		Unit:               req.Unit,
+		Description:        req.Description,
+		IsActive:           req.IsActive,
+		SpcBlockCompletion: req.SpcBlockCompletion,
+	}
+	if req.SpcRules != nil {
+		rules, err := normalizeSpcRules(*req.SpcRules)
+		if err != nil {
+			return nil, err
+		}
+		qualityStandard.SpcRules = rules
 	}
 
 	if err := s.db.Create(qualityStandard).Error; err != nil {
 		return nil, fmt.Errorf("创建质量标准失败: %v", err)
 	}
 
+	// 空的判异规则会被数据库默认值替换，需单独更新为空
+	if req.SpcRules != nil && qualityStandard.SpcRules == "" {
+		if err := s.db.Model(qualityStandard).Update("spc_rules", "").Error; err != nil {
+			return nil, fmt.Errorf("创建质量标准失败: %v", err)
+		}
+	}
+	if err := s.db.First(qualityStandard, qualityStandard.ID).Error; err != nil {
+		return nil, fmt.Errorf("获取质量标准失败: %v", err)
+	}
+
 	return s.qualityStandardToResponse(qualityStandard, &product), nil
 }
 
@@ -207,13 +272,9 @@ func (s *QualityService) UpdateQualityStandard(id uint, req *QualityStandardRequ
 		return nil, fmt.Errorf("验证产品失败: %v", err)
 	}
 
-	// 验证数值范围
-	if req.MinValue >= req.MaxValue {
-		return nil, errors.New("最小值必须小于最大值")
-	}
-
-	if req.TargetValue < req.MinValue || req.TargetValue > req.MaxValue {
-		return nil, errors.New("目标值必须在最小值和最大值之间")
+	// 验证规格限类型和数值范围
+	if err := normalizeQualityStandardLimits(req); err != nil {
+		return nil, err
 	}
 
 	// 检查同一产品下是否已存在相同名称的质量标准（排除当前标准）
@@ -221,16 +282,40 @@ func (s *QualityService) UpdateQualityStandard(id uint, req *QualityStandardRequ
 		return nil, errors.New("该产品下已存在相同名称的质量标准")
 	}
 
+	// 已有检测记录的质量标准不能修改特性类型
+	if req.CharacteristicType != qualityStandard.CharacteristicType {
+		var count int64
+		if err := s.db.Model(&models.QualityInspection{}).Where("quality_standard_id = ?", id).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("检查质量检测记录失败: %v", err)
+		}
+		if count > 0 {
+			return nil, errors.New("该质量标准存在检测记录，不能修改特性类型")
+		}
+	}
+
 	// 更新质量标准信息
 	qualityStandard.ProductID = req.ProductID
 	qualityStandard.Name = req.Name
 	qualityStandard.Type = req.Type
+	qualityStandard.CharacteristicType = req.CharacteristicType
+	qualityStandard.MaxDefects = req.MaxDefects
 	qualityStandard.MinValue = req.MinValue
 	qualityStandard.MaxValue = req.MaxValue
+	qualityStandard.LimitType = req.LimitType
+	qualityStandard.MinBoundary = req.MinBoundary
+	qualityStandard.MaxBoundary = req.MaxBoundary
 	qualityStandard.TargetValue = req.TargetValue
 	qualityStandard.Unit = req.Unit
 	qualityStandard.Description = req.Description
 	qualityStandard.IsActive = req.IsActive
+	qualityStandard.SpcBlockCompletion = req.SpcBlockCompletion
+	if req.SpcRules != nil {
+		rules, err := normalizeSpcRules(*req.SpcRules)
+		if err != nil {
+			return nil, err
+		}
+		qualityStandard.SpcRules = rules
+	}
 
 	if err := s.db.Save(&qualityStandard).Error; err != nil {
 		return nil, fmt.Errorf("更新质量标准失败: %v", err)
@@ -266,8 +351,8 @@ func (s *QualityService) DeleteQualityStandard(id uint) error {
 	return nil
 }
 
-// CreateQualityInspection 创建质量检测记录
-func (s *QualityService) CreateQualityInspection(req *QualityInspectionRequest) (*QualityInspectionResponse, error) {
+// CreateQualityInspection 创建质量检测记录，检测结果由系统按规格限判定，人工改判需管理员或主管权限并记录原因
+func (s *QualityService) CreateQualityInspection(req *QualityInspectionRequest, operatorID uint, role string) (*QualityInspectionResponse, error) {
 	// 验证生产工单是否存在
 	var productionOrder models.ProductionOrder
 	if err := s.db.First(&productionOrder, req.ProductionOrderID).Error; err != nil {
@@ -295,9 +380,23 @@ func (s *QualityService) CreateQualityInspection(req *QualityInspectionRequest)
 		return nil, fmt.Errorf("验证检测员失败: %v", err)
 	}
 
-	// 验证检测结果
-	if req.Result != "pass" && req.Result != "fail" {
-		return nil, errors.New("检测结果必须是 pass 或 fail")
+	// 验证生产设备和缺陷代码
+	equipment, err := validateInspectionEquipment(s.db, req.EquipmentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInspectionDefects(s.db, req.Defects); err != nil {
+		return nil, err
+	}
+
+	// 按质量特性类型判定检测结果
+	actualValue, judged, err := judgeInspection(&qualityStandard, req)
+	if err != nil {
+		return nil, err
+	}
+	result, overridden, err := resolveInspectionResult(req, judged, role)
+	if err != nil {
+		return nil, err
 	}
 
 	// 设置检测时间
@@ -310,23 +409,57 @@ func (s *QualityService) CreateQualityInspection(req *QualityInspectionRequest)
 		ProductionOrderID: req.ProductionOrderID,
 		QualityStandardID: req.QualityStandardID,
 		InspectorID:       req.InspectorID,
-		ActualValue:       req.ActualValue,
-		Result:            req.Result,
+		EquipmentID:       req.EquipmentID,
+		ActualValue:       actualValue,
+		Result:            result,
+		JudgedResult:      judged,
+		Overridden:        overridden,
 		Remark:            req.Remark,
 		InspectionTime:    inspectionTime,
 	}
 
-	if err := s.db.Create(qualityInspection).Error; err != nil {
-		return nil, fmt.Errorf("创建质量检测记录失败: %v", err)
+	// 保存测量值并按质量标准启用的规则判异
+	var events []models.QualityEvent
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(qualityInspection).Error; err != nil {
+			return fmt.Errorf("创建质量检测记录失败: %v", err)
+		}
+		if err := saveInspectionDefects(tx, qualityInspection, req.Defects); err != nil {
+			return err
+		}
+
+		if overridden {
+			if err := writeResultOverride(tx, qualityInspection, req.OverrideReason, operatorID, role); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		events, err = evaluateSpcRules(tx, qualityInspection, &qualityStandard)
+		if err != nil {
+			return err
+		}
+		return syncInspectionNcr(tx, qualityInspection, &qualityStandard)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return s.qualityInspectionToResponse(qualityInspection, &productionOrder, &qualityStandard, &inspector), nil
+	qualityInspection.Equipment = equipment
+	resp := s.qualityInspectionToResponse(qualityInspection, &productionOrder, &qualityStandard, &inspector)
+	for i := range events {
+		events[i].QualityStandard = qualityStandard
+		events[i].ProductionOrder = productionOrder
+		resp.QualityEvents = append(resp.QualityEvents, *qualityEventToResponse(&events[i]))
+	}
+	return resp, nil
 }
 
 // GetQualityInspection 获取质量检测记录详情
 func (s *QualityService) GetQualityInspection(id uint) (*QualityInspectionResponse, error) {
 	var qualityInspection models.QualityInspection
-	if err := s.db.Preload("ProductionOrder").Preload("QualityStandard").Preload("Inspector").First(&qualityInspection, id).Error; err != nil {
+	if err := s.db.Preload("ProductionOrder").Preload("QualityStandard").Preload("Inspector").
+		Preload("Equipment").Preload("Defects.DefectCode").First(&qualityInspection, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("质量检测记录不存在")
 		}
@@ -337,11 +470,12 @@ func (s *QualityService) GetQualityInspection(id uint) (*QualityInspectionRespon
 }
 
 // GetQualityInspectionList 获取质量检测记录列表
-func (s *QualityService) GetQualityInspectionList(page, pageSize int, productionOrderID, qualityStandardID, inspectorID uint, result string) ([]QualityInspectionResponse, int64, error) {
+func (s *QualityService) GetQualityInspectionList(page, pageSize int, productionOrderID, qualityStandardID, inspectorID, inspectionSheetID uint, result string) ([]QualityInspectionResponse, int64, error) {
 	var qualityInspections []models.QualityInspection
 	var total int64
 
-	query := s.db.Model(&models.QualityInspection{}).Preload("ProductionOrder").Preload("QualityStandard").Preload("Inspector")
+	query := s.db.Model(&models.QualityInspection{}).Preload("ProductionOrder").Preload("QualityStandard").Preload("Inspector").
+		Preload("Equipment").Preload("Defects.DefectCode")
 
 	// 按生产工单筛选
 	if productionOrderID > 0 {
@@ -358,6 +492,11 @@ func (s *QualityService) GetQualityInspectionList(page, pageSize int, production
 		query = query.Where("inspector_id = ?", inspectorID)
 	}
 
+	// 按检测单筛选
+	if inspectionSheetID > 0 {
+		query = query.Where("inspection_sheet_id = ?", inspectionSheetID)
+	}
+
 	// 按检测结果筛选
 	if result != "" {
 		query = query.Where("result = ?", result)
@@ -383,8 +522,18 @@ func (s *QualityService) GetQualityInspectionList(page, pageSize int, production
 }
 
 // GetQualityStatistics 获取质量统计数据
-func (s *QualityService) GetQualityStatistics(startDate, endDate *time.Time, productionOrderID, qualityStandardID uint) (*QualityStatistics, error) {
+func (s *QualityService) GetQualityStatistics(startDate, endDate *time.Time, productionOrderID, qualityStandardID uint, level string) (*QualityStatistics, error) {
+	if level == "" {
+		level = "line"
+	}
+	if level != "line" && level != "sheet" {
+		return nil, errors.New("无效的统计层级")
+	}
+
 	query := s.db.Model(&models.QualityInspection{})
+	if level == "sheet" {
+		query = s.db.Model(&models.InspectionSheet{})
+	}
 
 	// 时间范围筛选
 	if startDate != nil {
@@ -399,9 +548,14 @@ func (s *QualityService) GetQualityStatistics(startDate, endDate *time.Time, pro
 		query = query.Where("production_order_id = ?", productionOrderID)
 	}
 
-	// 按质量标准筛选
+	// 按质量标准筛选，检测单层级统计包含该质量特性的检测单
 	if qualityStandardID > 0 {
-		query = query.Where("quality_standard_id = ?", qualityStandardID)
+		if level == "sheet" {
+			query = query.Where("id IN (?)", s.db.Model(&models.QualityInspection{}).
+				Select("inspection_sheet_id").Where("quality_standard_id = ?", qualityStandardID))
+		} else {
+			query = query.Where("quality_standard_id = ?", qualityStandardID)
+		}
 	}
 
 	// 获取总检测次数
@@ -425,6 +579,7 @@ func (s *QualityService) GetQualityStatistics(startDate, endDate *time.Time, pro
 	}
 
 	return &QualityStatistics{
+		Level:            level,
 		TotalInspections: int(totalInspections),
 		PassedCount:      int(passedCount),
 		FailedCount:      int(failedCount),
@@ -456,47 +611,74 @@ func (s *QualityService) isQualityStandardNameExists(productID uint, name string
 // 辅助函数：将质量标准模型转换为响应结构体
 func (s *QualityService) qualityStandardToResponse(standard *models.QualityStandard, product *models.Product) *QualityStandardResponse {
 	return &QualityStandardResponse{
-		ID:          standard.ID,
-		ProductID:   standard.ProductID,
-		ProductCode: product.Code,
-		ProductName: product.Name,
-		Name:        standard.Name,
-		Type:        standard.Type,
-		MinValue:    standard.MinValue,
-		MaxValue:    standard.MaxValue,
-		TargetValue: standard.TargetValue,
-		Unit:        standard.Unit,
-		Description: standard.Description,
-		IsActive:    standard.IsActive,
-		CreatedAt:   standard.CreatedAt,
-		UpdatedAt:   standard.UpdatedAt,
+		ID:                 standard.ID,
+		ProductID:          standard.ProductID,
+		ProductCode:        product.Code,
+		ProductName:        product.Name,
+		Name:               standard.Name,
+		Type:               standard.Type,
+		CharacteristicType: standard.CharacteristicType,
+		MaxDefects:         standard.MaxDefects,
+		MinValue:           standard.MinValue,
+		MaxValue:           standard.MaxValue,
+		LimitType:          standard.LimitType,
+		MinBoundary:        standard.MinBoundary,
+		MaxBoundary:        standard.MaxBoundary,
+		TargetValue:        standard.TargetValue,
+		Unit:               standard.Unit,
+		Description:        standard.Description,
+		IsActive:           standard.IsActive,
+		SpcRules:           standard.SpcRules,
+		SpcBlockCompletion: standard.SpcBlockCompletion,
+		CreatedAt:          standard.CreatedAt,
+		UpdatedAt:          standard.UpdatedAt,
 	}
 }
 
 // 辅助函数：将质量检测模型转换为响应结构体
 func (s *QualityService) qualityInspectionToResponse(inspection *models.QualityInspection, productionOrder *models.ProductionOrder, qualityStandard *models.QualityStandard, inspector *models.User) *QualityInspectionResponse {
-	return &QualityInspectionResponse{
+	resp := &QualityInspectionResponse{
 		ID:                  inspection.ID,
+		InspectionSheetID:   inspection.InspectionSheetID,
 		ProductionOrderID:   inspection.ProductionOrderID,
 		ProductionOrderNo:   productionOrder.OrderNo,
 		QualityStandardID:   inspection.QualityStandardID,
 		QualityStandardName: qualityStandard.Name,
+		CharacteristicType:  qualityStandard.CharacteristicType,
 		InspectorID:         inspection.InspectorID,
 		InspectorName:       inspector.Username,
+		EquipmentID:         inspection.EquipmentID,
 		ActualValue:         inspection.ActualValue,
 		TargetValue:         qualityStandard.TargetValue,
 		MinValue:            qualityStandard.MinValue,
 		MaxValue:            qualityStandard.MaxValue,
 		Unit:                qualityStandard.Unit,
 		Result:              inspection.Result,
+		JudgedResult:        inspection.JudgedResult,
+		Overridden:          inspection.Overridden,
 		Remark:              inspection.Remark,
 		InspectionTime:      inspection.InspectionTime,
 		CreatedAt:           inspection.CreatedAt,
 	}
+	if inspection.Equipment != nil {
+		resp.EquipmentName = inspection.Equipment.Name
+	}
+	for _, defect := range inspection.Defects {
+		resp.Defects = append(resp.Defects, InspectionDefectResponse{
+			ID:             defect.ID,
+			DefectCodeID:   defect.DefectCodeID,
+			DefectCode:     defect.DefectCode.Code,
+			DefectName:     defect.DefectCode.Name,
+			DefectSeverity: defect.DefectCode.Severity,
+			Quantity:       defect.Quantity,
+			Remark:         defect.Remark,
+		})
+	}
+	return resp
 }
 
-// UpdateQualityInspection 更新质量检测记录
-func (s *QualityService) UpdateQualityInspection(id uint, req *QualityInspectionRequest) (*QualityInspectionResponse, error) {
+// UpdateQualityInspection 更新质量检测记录，按更新后的测量值重新判定检测结果
+func (s *QualityService) UpdateQualityInspection(id uint, req *QualityInspectionRequest, operatorID uint, role string) (*QualityInspectionResponse, error) {
 	var qualityInspection models.QualityInspection
 	if err := s.db.Preload("ProductionOrder").Preload("QualityStandard").Preload("Inspector").First(&qualityInspection, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -505,6 +687,16 @@ func (s *QualityService) UpdateQualityInspection(id uint, req *QualityInspection
 		return nil, fmt.Errorf("获取质量检测记录失败: %v", err)
 	}
 
+	// 检测单明细的工单由表头决定
+	if qualityInspection.InspectionSheetID != nil && req.ProductionOrderID != qualityInspection.ProductionOrderID {
+		return nil, errors.New("检测单明细不能修改生产工单，请修改检测单")
+	}
+	if qualityInspection.InspectionSheetID != nil {
+		if err := checkSheetLotEditable(s.db, *qualityInspection.InspectionSheetID); err != nil {
+			return nil, err
+		}
+	}
+
 	// 验证生产工单是否存在
 	var productionOrder models.ProductionOrder
 	if err := s.db.First(&productionOrder, req.ProductionOrderID).Error; err != nil {
@@ -523,6 +715,17 @@ func (s *QualityService) UpdateQualityInspection(id uint, req *QualityInspection
 		return nil, fmt.Errorf("验证质量标准失败: %v", err)
 	}
 
+	// 同一检测单内质量特性不能重复
+	if qualityInspection.InspectionSheetID != nil && req.QualityStandardID != qualityInspection.QualityStandardID {
+		var count int64
+		s.db.Model(&models.QualityInspection{}).
+			Where("inspection_sheet_id = ? AND quality_standard_id = ? AND id <> ?", *qualityInspection.InspectionSheetID, req.QualityStandardID, id).
+			Count(&count)
+		if count > 0 {
+			return nil, errors.New("检测单内已存在该质量标准的检测明细")
+		}
+	}
+
 	// 验证检测员是否存在
 	var inspector models.User
 	if err := s.db.First(&inspector, req.InspectorID).Error; err != nil {
@@ -532,17 +735,36 @@ func (s *QualityService) UpdateQualityInspection(id uint, req *QualityInspection
 		return nil, fmt.Errorf("验证检测员失败: %v", err)
 	}
 
-	// 验证检测结果
-	if req.Result != "pass" && req.Result != "fail" {
-		return nil, errors.New("检测结果必须是 pass 或 fail")
+	// 验证生产设备和缺陷代码
+	equipment, err := validateInspectionEquipment(s.db, req.EquipmentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateInspectionDefects(s.db, req.Defects); err != nil {
+		return nil, err
+	}
+
+	// 按质量特性类型重新判定检测结果
+	actualValue, judged, err := judgeInspection(&qualityStandard, req)
+	if err != nil {
+		return nil, err
+	}
+	result, overridden, err := resolveUpdatedInspectionResult(req, judged, role, &qualityInspection)
+	if err != nil {
+		return nil, err
 	}
 
 	// 更新质量检测记录信息
+	previous := qualityInspection
+	wasOverridden, previousResult := qualityInspection.Overridden, qualityInspection.Result
 	qualityInspection.ProductionOrderID = req.ProductionOrderID
 	qualityInspection.QualityStandardID = req.QualityStandardID
 	qualityInspection.InspectorID = req.InspectorID
-	qualityInspection.ActualValue = req.ActualValue
-	qualityInspection.Result = req.Result
+	qualityInspection.EquipmentID = req.EquipmentID
+	qualityInspection.ActualValue = actualValue
+	qualityInspection.Result = result
+	qualityInspection.JudgedResult = judged
+	qualityInspection.Overridden = overridden
 	qualityInspection.Remark = req.Remark
 
 	// 更新检测时间（如果提供）
@@ -550,11 +772,52 @@ func (s *QualityService) UpdateQualityInspection(id uint, req *QualityInspection
 		qualityInspection.InspectionTime = *req.InspectionTime
 	}
 
-	if err := s.db.Save(&qualityInspection).Error; err != nil {
-		return nil, fmt.Errorf("更新质量检测记录失败: %v", err)
+	var events []models.QualityEvent
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&qualityInspection).Error; err != nil {
+			return fmt.Errorf("更新质量检测记录失败: %v", err)
+		}
+		if err := saveInspectionDefects(tx, &qualityInspection, req.Defects); err != nil {
+			return err
+		}
+		if err := writeOverrideChange(tx, &qualityInspection, wasOverridden, previousResult, req.OverrideReason, operatorID, role); err != nil {
+			return err
+		}
+
+		// 测量值等判异依据变化时重新判异，避免遗留按原测量值触发的事件阻止工单完成
+		if spcInputsChanged(&previous, &qualityInspection) {
+			var err error
+			if events, err = reevaluateSpcRules(tx, &qualityInspection, &qualityStandard); err != nil {
+				return err
+			}
+		}
+		if qualityInspection.InspectionSheetID != nil {
+			return refreshInspectionSheetResult(tx, *qualityInspection.InspectionSheetID)
+		}
+		return syncInspectionNcr(tx, &qualityInspection, &qualityStandard)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	qualityInspection.Equipment = equipment
+	resp := s.qualityInspectionToResponse(&qualityInspection, &productionOrder, &qualityStandard, &inspector)
+	for i := range events {
+		events[i].QualityStandard = qualityStandard
+		events[i].ProductionOrder = productionOrder
+		resp.QualityEvents = append(resp.QualityEvents, *qualityEventToResponse(&events[i]))
 	}
+	return resp, nil
+}
 
-	return s.qualityInspectionToResponse(&qualityInspection, &productionOrder, &qualityStandard, &inspector), nil
+// GetResultOverrides 获取检测记录的人工改判记录
+func (s *QualityService) GetResultOverrides(inspectionID uint) ([]models.QualityResultOverride, error) {
+	var overrides []models.QualityResultOverride
+	if err := s.db.Preload("Operator").Where("quality_inspection_id = ?", inspectionID).
+		Order("created_at DESC, id DESC").Find(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("获取改判记录失败: %v", err)
+	}
+	return overrides, nil
 }
 
 // DeleteQualityInspection 删除质量检测记录
@@ -566,12 +829,263 @@ func (s *QualityService) DeleteQualityInspection(id uint) error {
 		}
 		return fmt.Errorf("获取质量检测记录失败: %v", err)
 	}
+	if qualityInspection.InspectionSheetID != nil {
+		if err := checkSheetLotEditable(s.db, *qualityInspection.InspectionSheetID); err != nil {
+			return err
+		}
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&qualityInspection).Error; err != nil {
+			return fmt.Errorf("删除质量检测记录失败: %v", err)
+		}
+		if qualityInspection.InspectionSheetID != nil {
+			return refreshInspectionSheetResult(tx, *qualityInspection.InspectionSheetID)
+		}
+		return cancelAutoNcr(tx, "quality_inspection_id", qualityInspection.ID)
+	})
+}
 
-	if err := s.db.Delete(&qualityInspection).Error; err != nil {
-		return fmt.Errorf("删除质量检测记录失败: %v", err)
+// 辅助函数：规范化并验证质量标准的特性类型、规格限类型、边界和数值范围
+func normalizeQualityStandardLimits(req *QualityStandardRequest) error {
+	if req.CharacteristicType == "" {
+		req.CharacteristicType = "variable"
+	}
+	if req.CharacteristicType != "variable" && req.CharacteristicType != "attribute" && req.CharacteristicType != "defect_count" {
+		return errors.New("特性类型必须是 variable、attribute 或 defect_count")
+	}
+	if req.LimitType == "" {
+		req.LimitType = "two_sided"
+	}
+	if req.MinBoundary == "" {
+		req.MinBoundary = "inclusive"
+	}
+	if req.MaxBoundary == "" {
+		req.MaxBoundary = "inclusive"
 	}
+	if req.MinBoundary != "inclusive" && req.MinBoundary != "exclusive" {
+		return errors.New("下限边界必须是 inclusive 或 exclusive")
+	}
+	if req.MaxBoundary != "inclusive" && req.MaxBoundary != "exclusive" {
+		return errors.New("上限边界必须是 inclusive 或 exclusive")
+	}
+
+	// 计数型特性不使用规格限
+	if req.CharacteristicType != "variable" {
+		if req.CharacteristicType != "defect_count" {
+			req.MaxDefects = 0
+		}
+		return nil
+	}
+
+	switch req.LimitType {
+	case "two_sided":
+		if req.MinValue >= req.MaxValue {
+			return errors.New("最小值必须小于最大值")
+		}
+		if req.TargetValue < req.MinValue || req.TargetValue > req.MaxValue {
+			return errors.New("目标值必须在最小值和最大值之间")
+		}
+	case "lower":
+		if req.TargetValue < req.MinValue {
+			return errors.New("目标值不能小于最小值")
+		}
+	case "upper":
+		if req.TargetValue > req.MaxValue {
+			return errors.New("目标值不能大于最大值")
+		}
+	default:
+		return errors.New("规格限类型必须是 two_sided、lower 或 upper")
+	}
+	return nil
+}
+
+// 辅助函数：按质量标准的规格限判定测量值是否合格，单侧规格只检查对应的限值
+func judgeQualityValue(standard *models.QualityStandard, value float64) string {
+	if standard.LimitType != "upper" {
+		if value < standard.MinValue || (value == standard.MinValue && standard.MinBoundary == "exclusive") {
+			return "fail"
+		}
+	}
+	if standard.LimitType != "lower" {
+		if value > standard.MaxValue || (value == standard.MaxValue && standard.MaxBoundary == "exclusive") {
+			return "fail"
+		}
+	}
+	return "pass"
+}
+
+// 辅助函数：确定检测结果，未填写或与系统判定一致时采用系统判定，否则为人工改判，需管理员或主管权限并填写原因
+func resolveInspectionResult(req *QualityInspectionRequest, judged, role string) (string, bool, error) {
+	if req.Result == "" || req.Result == judged {
+		return judged, false, nil
+	}
+	if req.Result != "pass" && req.Result != "fail" {
+		return "", false, errors.New("检测结果必须是 pass 或 fail")
+	}
+	if role != "admin" && role != "manager" {
+		return "", false, fmt.Errorf("系统判定结果为 %s，人工改判需要管理员或主管权限", judged)
+	}
+	if strings.TrimSpace(req.OverrideReason) == "" {
+		return "", false, errors.New("人工改判检测结果必须填写原因")
+	}
+	return req.Result, true, nil
+}
+
+// 辅助函数：修改检测记录时确定检测结果。原记录经人工改判时，未填写结果或结果不变则保留原改判结果；
+// 撤销或变更原改判结果需管理员或主管权限并填写原因，原记录未改判时同新建检测记录
+func resolveUpdatedInspectionResult(req *QualityInspectionRequest, judged, role string, stored *models.QualityInspection) (string, bool, error) {
+	if stored == nil || !stored.Overridden {
+		return resolveInspectionResult(req, judged, role)
+	}
+	if req.Result == "" || req.Result == stored.Result {
+		return stored.Result, stored.Result != judged, nil
+	}
+	if req.Result != "pass" && req.Result != "fail" {
+		return "", false, errors.New("检测结果必须是 pass 或 fail")
+	}
+	if role != "admin" && role != "manager" {
+		return "", false, fmt.Errorf("检测结果已人工改判为 %s，撤销或变更改判需要管理员或主管权限", stored.Result)
+	}
+	if strings.TrimSpace(req.OverrideReason) == "" {
+		return "", false, errors.New("撤销或变更人工改判必须填写原因")
+	}
+	return req.Result, req.Result != judged, nil
+}
+
+// 辅助函数：写入检测结果人工改判记录
+func writeResultOverride(tx *gorm.DB, inspection *models.QualityInspection, reason string, operatorID uint, role string) error {
+	override := &models.QualityResultOverride{
+		QualityInspectionID: inspection.ID,
+		ActualValue:         inspection.ActualValue,
+		JudgedResult:        inspection.JudgedResult,
+		OverrideResult:      inspection.Result,
+		Reason:              strings.TrimSpace(reason),
+		OperatorID:          operatorID,
+		OperatorRole:        role,
+	}
+	if err := tx.Create(override).Error; err != nil {
+		return fmt.Errorf("记录人工改判失败: %v", err)
+	}
+	return nil
+}
+
+// 辅助函数：修改检测记录时记录改判历史，原记录未改判时记录新的人工改判，原记录经人工改判时记录对改判结果的撤销或变更
+func writeOverrideChange(tx *gorm.DB, inspection *models.QualityInspection, wasOverridden bool, previousResult, reason string, operatorID uint, role string) error {
+	if wasOverridden {
+		if inspection.Result == previousResult {
+			return nil
+		}
+	} else if !inspection.Overridden {
+		return nil
+	}
+	return writeResultOverride(tx, inspection, reason, operatorID, role)
+}
+
+// 辅助函数：判断质量标准是否为计量型特性，只有计量型特性参与控制图、过程能力和判异
+func isVariableCharacteristic(standard *models.QualityStandard) bool {
+	return standard.CharacteristicType == "" || standard.CharacteristicType == "variable"
+}
+
+// 辅助函数：按质量特性类型判定检测结果，返回记录的实际值和系统判定结果
+// 计量型按规格限判定测量值；缺陷数型以缺陷明细合计（未填明细时为实际值）与允许缺陷数比较；
+// 合格/不合格型有缺陷明细即判不合格，否则采用检测员填写的结果
+func judgeInspection(standard *models.QualityStandard, req *QualityInspectionRequest) (float64, string, error) {
+	defectTotal := 0
+	for _, defect := range req.Defects {
+		defectTotal += defect.Quantity
+	}
+
+	switch standard.CharacteristicType {
+	case "defect_count":
+		count := req.ActualValue
+		if len(req.Defects) > 0 {
+			count = float64(defectTotal)
+		}
+		if count < 0 || count != math.Trunc(count) {
+			return 0, "", errors.New("缺陷数必须是非负整数")
+		}
+		if count > float64(standard.MaxDefects) {
+			return count, "fail", nil
+		}
+		return count, "pass", nil
+	case "attribute":
+		if defectTotal > 0 {
+			return float64(defectTotal), "fail", nil
+		}
+		if req.Result != "pass" && req.Result != "fail" {
+			return 0, "", errors.New("合格/不合格型特性必须填写检测结果 pass 或 fail")
+		}
+		return 0, req.Result, nil
+	}
+	return req.ActualValue, judgeQualityValue(standard, req.ActualValue), nil
+}
+
+// 辅助函数：验证生产设备是否存在，未填写时返回空
+func validateInspectionEquipment(db *gorm.DB, equipmentID *uint) (*models.Equipment, error) {
+	if equipmentID == nil {
+		return nil, nil
+	}
+	var equipment models.Equipment
+	if err := db.First(&equipment, *equipmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("生产设备不存在")
+		}
+		return nil, fmt.Errorf("验证生产设备失败: %v", err)
+	}
+	return &equipment, nil
+}
 
+// 辅助函数：验证缺陷明细，缺陷代码必须存在、启用且不能重复
+func validateInspectionDefects(db *gorm.DB, defects []InspectionDefectRequest) error {
+	seen := make(map[uint]bool)
+	for _, defect := range defects {
+		if seen[defect.DefectCodeID] {
+			return errors.New("缺陷代码重复")
+		}
+		seen[defect.DefectCodeID] = true
+
+		var code models.DefectCode
+		if err := db.First(&code, defect.DefectCodeID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("缺陷代码 %d 不存在", defect.DefectCodeID)
+			}
+			return fmt.Errorf("验证缺陷代码失败: %v", err)
+		}
+		if !code.IsActive {
+			return fmt.Errorf("缺陷代码 %s 已停用", code.Code)
+		}
+	}
 	return nil
 }
 
-// ... existing code ...
\ No newline at end of file
+// 辅助函数：保存检测记录的缺陷明细，替换原有明细
+func saveInspectionDefects(tx *gorm.DB, inspection *models.QualityInspection, defects []InspectionDefectRequest) error {
+	if err := tx.Where("quality_inspection_id = ?", inspection.ID).Delete(&models.QualityInspectionDefect{}).Error; err != nil {
+		return fmt.Errorf("删除缺陷明细失败: %v", err)
+	}
+
+	inspection.Defects = nil
+	if len(defects) == 0 {
+		return nil
+	}
+
+	rows := make([]models.QualityInspectionDefect, 0, len(defects))
+	for _, defect := range defects {
+		rows = append(rows, models.QualityInspectionDefect{
+			QualityInspectionID: inspection.ID,
+			DefectCodeID:        defect.DefectCodeID,
+			Quantity:            defect.Quantity,
+			Remark:              defect.Remark,
+		})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return fmt.Errorf("保存缺陷明细失败: %v", err)
+	}
+
+	if err := tx.Preload("DefectCode").Where("quality_inspection_id = ?", inspection.ID).
+		Order("id ASC").Find(&inspection.Defects).Error; err != nil {
+		return fmt.Errorf("获取缺陷明细失败: %v", err)
+	}
+	return nil
+}
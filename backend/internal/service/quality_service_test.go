@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+
+	"mes-system/internal/models"
+)
+
+func TestResolveUpdatedInspectionResult(t *testing.T) {
+	overridden := &models.QualityInspection{Result: "pass", Overridden: true}
+	tests := []struct {
+		name       string
+		result     string
+		reason     string
+		role       string
+		judged     string
+		stored     *models.QualityInspection
+		want       string
+		overridden bool
+		wantErr    bool
+	}{
+		{"原记录未改判时采用系统判定", "", "", "user", "fail", &models.QualityInspection{Result: "pass"}, "fail", false, false},
+		{"原记录未改判时普通用户不能改判", "pass", "让步接收", "user", "fail", &models.QualityInspection{Result: "fail"}, "", false, true},
+		{"未填写结果时保留原改判", "", "", "user", "fail", overridden, "pass", true, false},
+		{"结果不变时保留原改判", "pass", "", "user", "fail", overridden, "pass", true, false},
+		{"修改后系统判定与原改判一致", "", "", "user", "pass", overridden, "pass", false, false},
+		{"普通用户不能撤销改判", "fail", "复检不合格", "user", "fail", overridden, "", false, true},
+		{"撤销改判必须填写原因", "fail", " ", "manager", "fail", overridden, "", false, true},
+		{"主管填写原因撤销改判", "fail", "复检不合格", "manager", "fail", overridden, "fail", false, false},
+		{"无效检测结果", "ok", "复检", "admin", "fail", overridden, "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &QualityInspectionRequest{Result: tt.result, OverrideReason: tt.reason}
+			result, overridden, err := resolveUpdatedInspectionResult(req, tt.judged, tt.role, tt.stored)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("错误 = %v，期望返回错误 = %v", err, tt.wantErr)
+			}
+			if result != tt.want || overridden != tt.overridden {
+				t.Errorf("结果=%s 改判=%v，期望 %s %v", result, overridden, tt.want, tt.overridden)
+			}
+		})
+	}
+}
@@ -0,0 +1,495 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mes-system/internal/models"
+)
+
+// ReplenishmentReviewRequest 补货建议审核请求结构体
+type ReplenishmentReviewRequest struct {
+	Quantity float64 `json:"quantity" binding:"min=0"` // 批准数量，0 表示按建议数量批准（驳回时忽略）
+	Remark   string  `json:"remark"`                   // 审核意见
+}
+
+// ReplenishmentProposalResponse 补货建议响应结构体
+type ReplenishmentProposalResponse struct {
+	ID                  uint       `json:"id"`
+	ProposalNo          string     `json:"proposal_no"`
+	MaterialID          uint       `json:"material_id"`
+	MaterialCode        string     `json:"material_code"`
+	MaterialName        string     `json:"material_name"`
+	Unit                string     `json:"unit"`
+	MinStock            float64    `json:"min_stock"`
+	MaxStock            float64    `json:"max_stock"`
+	OnHandStock         float64    `json:"on_hand_stock"`
+	OpenOrderQuantity   float64    `json:"open_order_quantity"`
+	AvgDailyConsumption float64    `json:"avg_daily_consumption"`
+	DaysOfCover         *float64   `json:"days_of_cover"`
+	LeadTimeDays        int        `json:"lead_time_days"`
+	ReorderPoint        float64    `json:"reorder_point"`
+	TargetStock         float64    `json:"target_stock"`
+	ProposedQuantity    float64    `json:"proposed_quantity"`
+	ApprovedQuantity    float64    `json:"approved_quantity"`
+	Status              string     `json:"status"`
+	Trigger             string     `json:"trigger"`
+	GeneratedAt         time.Time  `json:"generated_at"`
+	ReviewedBy          *uint      `json:"reviewed_by"`
+	ReviewerName        string     `json:"reviewer_name"`
+	ReviewedAt          *time.Time `json:"reviewed_at"`
+	Remark              string     `json:"remark"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// ReplenishmentRunResult 补货计算结果
+type ReplenishmentRunResult struct {
+	Trigger          string    `json:"trigger"`
+	ConsumptionDays  int       `json:"consumption_days"`
+	MaterialsChecked int       `json:"materials_checked"`
+	Created          int       `json:"created"` // 新建的补货建议数
+	Updated          int       `json:"updated"` // 按最新库存刷新的待审核建议数
+	Closed           int       `json:"closed"`  // 关闭的建议数（库存已恢复或已下采购订单）
+	GeneratedAt      time.Time `json:"generated_at"`
+}
+
+// ReplenishmentService 补货服务
+type ReplenishmentService struct {
+	db              *gorm.DB
+	consumptionDays int
+	mu              sync.Mutex // 保证手动和定时计算不会同时运行
+}
+
+// NewReplenishmentService 创建补货服务实例，consumptionDays 为计算日均消耗的历史天数
+func NewReplenishmentService(db *gorm.DB, consumptionDays int) *ReplenishmentService {
+	if consumptionDays <= 0 {
+		consumptionDays = 30
+	}
+	return &ReplenishmentService{db: db, consumptionDays: consumptionDays}
+}
+
+// StartScheduler 启动时立即运行一次补货计算，之后按固定间隔在后台运行，ctx 取消时停止
+func (s *ReplenishmentService) StartScheduler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.runScheduled()
+
+			select {
+			case <-ctx.Done():
+				log.Println("定时补货计算已停止")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// 辅助函数：运行一次定时补货计算并记录结果
+func (s *ReplenishmentService) runScheduled() {
+	result, err := s.RunReplenishment("schedule")
+	if err != nil {
+		log.Printf("定时补货计算失败: %v", err)
+		return
+	}
+	log.Printf("定时补货计算完成: 检查物料 %d 个，新建 %d，刷新 %d，关闭 %d",
+		result.MaterialsChecked, result.Created, result.Updated, result.Closed)
+}
+
+// RunReplenishment 运行补货计算：按日均消耗和采购提前期计算再订货点，低于再订货点的物料按最大库存生成补货建议
+func (s *ReplenishmentService) RunReplenishment(trigger string) (*ReplenishmentRunResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	result := &ReplenishmentRunResult{
+		Trigger:         trigger,
+		ConsumptionDays: s.consumptionDays,
+		GeneratedAt:     now,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var materials []models.Material
+		if err := tx.Where("status = ? AND max_stock > 0", 1).Find(&materials).Error; err != nil {
+			return fmt.Errorf("获取物料失败: %v", err)
+		}
+		result.MaterialsChecked = len(materials)
+
+		consumption, err := s.consumptionByMaterial(tx, now.AddDate(0, 0, -s.consumptionDays), now)
+		if err != nil {
+			return err
+		}
+
+		openOrders, err := openOrderQuantityByMaterial(tx)
+		if err != nil {
+			return err
+		}
+
+		var openProposals []models.ReplenishmentProposal
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status IN ?", []string{"pending", "approved"}).
+			Find(&openProposals).Error; err != nil {
+			return fmt.Errorf("获取补货建议失败: %v", err)
+		}
+		proposals := make(map[uint]*models.ReplenishmentProposal, len(openProposals))
+		for i := range openProposals {
+			proposals[openProposals[i].MaterialID] = &openProposals[i]
+		}
+
+		for i := range materials {
+			material := &materials[i]
+			existing := proposals[material.ID]
+
+			// 已批准的建议在下达采购订单后关闭，之前不重复生成建议
+			if existing != nil && existing.Status == "approved" {
+				ordered, err := hasPurchaseOrderSince(tx, material.ID, *existing.ReviewedAt)
+				if err != nil {
+					return err
+				}
+				if !ordered {
+					continue
+				}
+				if err := closeProposal(tx, existing, "已下达采购订单"); err != nil {
+					return err
+				}
+				result.Closed++
+				existing = nil
+			}
+
+			proposal := calculateProposal(material, consumption[material.ID]/float64(s.consumptionDays), openOrders[material.ID])
+			proposal.ProposedQuantity = ceilToPrecision(proposal.ProposedQuantity, unitPrecision(tx, material.Unit))
+
+			if proposal.ProposedQuantity <= 0 {
+				// 库存已恢复，关闭尚未审核的建议
+				if existing != nil {
+					if err := closeProposal(tx, existing, "库存已恢复，无需补货"); err != nil {
+						return err
+					}
+					result.Closed++
+				}
+				continue
+			}
+
+			proposal.Trigger = trigger
+			proposal.GeneratedAt = now
+
+			if existing != nil {
+				proposal.ID = existing.ID
+				proposal.ProposalNo = existing.ProposalNo
+				proposal.Status = existing.Status
+				proposal.Remark = existing.Remark
+				proposal.CreatedAt = existing.CreatedAt
+				if err := tx.Save(proposal).Error; err != nil {
+					return fmt.Errorf("更新补货建议失败: %v", err)
+				}
+				result.Updated++
+				continue
+			}
+
+			proposal.ProposalNo = generateProposalNo(tx)
+			proposal.Status = "pending"
+			if err := tx.Create(proposal).Error; err != nil {
+				return fmt.Errorf("创建补货建议失败: %v", err)
+			}
+			result.Created++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetProposal 获取补货建议详情
+func (s *ReplenishmentService) GetProposal(id uint) (*ReplenishmentProposalResponse, error) {
+	var proposal models.ReplenishmentProposal
+	if err := s.db.Preload("Material").Preload("Reviewer").First(&proposal, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("补货建议不存在")
+		}
+		return nil, fmt.Errorf("获取补货建议失败: %v", err)
+	}
+
+	return s.proposalToResponse(&proposal), nil
+}
+
+// GetProposalList 获取补货建议列表
+func (s *ReplenishmentService) GetProposalList(page, pageSize int, status string, materialID uint) ([]ReplenishmentProposalResponse, int64, error) {
+	var proposals []models.ReplenishmentProposal
+	var total int64
+
+	query := s.proposalQuery(status, materialID)
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取补货建议总数失败: %v", err)
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Material").Preload("Reviewer").
+		Offset(offset).Limit(pageSize).Order("generated_at DESC, id DESC").Find(&proposals).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取补货建议列表失败: %v", err)
+	}
+
+	var responses []ReplenishmentProposalResponse
+	for _, proposal := range proposals {
+		responses = append(responses, *s.proposalToResponse(&proposal))
+	}
+
+	return responses, total, nil
+}
+
+// ExportProposals 获取导出用的补货建议（不分页）
+func (s *ReplenishmentService) ExportProposals(status string, materialID uint) ([]ReplenishmentProposalResponse, error) {
+	var proposals []models.ReplenishmentProposal
+	if err := s.proposalQuery(status, materialID).Preload("Material").Preload("Reviewer").
+		Order("generated_at DESC, id DESC").Find(&proposals).Error; err != nil {
+		return nil, fmt.Errorf("获取补货建议失败: %v", err)
+	}
+
+	var responses []ReplenishmentProposalResponse
+	for _, proposal := range proposals {
+		responses = append(responses, *s.proposalToResponse(&proposal))
+	}
+
+	return responses, nil
+}
+
+// ApproveProposal 批准补货建议，可调整批准数量
+func (s *ReplenishmentService) ApproveProposal(id uint, req *ReplenishmentReviewRequest, reviewerID uint) (*ReplenishmentProposalResponse, error) {
+	return s.review(id, "approved", req, reviewerID)
+}
+
+// RejectProposal 驳回补货建议
+func (s *ReplenishmentService) RejectProposal(id uint, req *ReplenishmentReviewRequest, reviewerID uint) (*ReplenishmentProposalResponse, error) {
+	return s.review(id, "rejected", req, reviewerID)
+}
+
+// 辅助函数：审核补货建议
+func (s *ReplenishmentService) review(id uint, status string, req *ReplenishmentReviewRequest, reviewerID uint) (*ReplenishmentProposalResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var proposal models.ReplenishmentProposal
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&proposal, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("补货建议不存在")
+			}
+			return fmt.Errorf("获取补货建议失败: %v", err)
+		}
+
+		if proposal.Status != "pending" {
+			return errors.New("只有待审核的补货建议可以审核")
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":      status,
+			"reviewed_by": reviewerID,
+			"reviewed_at": now,
+			"remark":      req.Remark,
+		}
+		if status == "approved" {
+			quantity := roundQuantity(req.Quantity)
+			if quantity == 0 {
+				quantity = proposal.ProposedQuantity
+			}
+			updates["approved_quantity"] = quantity
+		}
+
+		if err := tx.Model(&proposal).Updates(updates).Error; err != nil {
+			return fmt.Errorf("审核补货建议失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProposal(id)
+}
+
+// calculateProposal 按最小/最大库存、日均消耗和采购提前期计算补货建议
+// 再订货点 = 最小库存 + 日均消耗 × 提前期；库存位置（在库 + 在途）不高于再订货点时，补货至最大库存并覆盖提前期内的消耗
+func calculateProposal(material *models.Material, avgDailyConsumption, openOrderQuantity float64) *models.ReplenishmentProposal {
+	avgDailyConsumption = roundQuantity(avgDailyConsumption)
+	onHand := roundQuantity(material.CurrentStock - blockedStock(material))
+	position := roundQuantity(onHand + openOrderQuantity)
+	leadTimeDemand := avgDailyConsumption * float64(material.LeadTimeDays)
+	reorderPoint := roundQuantity(material.MinStock + leadTimeDemand)
+
+	proposal := &models.ReplenishmentProposal{
+		MaterialID:          material.ID,
+		OnHandStock:         onHand,
+		OpenOrderQuantity:   openOrderQuantity,
+		AvgDailyConsumption: avgDailyConsumption,
+		LeadTimeDays:        material.LeadTimeDays,
+		ReorderPoint:        reorderPoint,
+		TargetStock:         material.MaxStock,
+	}
+
+	if avgDailyConsumption > 0 {
+		daysOfCover := math.Round(onHand/avgDailyConsumption*100) / 100
+		proposal.DaysOfCover = &daysOfCover
+	}
+
+	if position <= reorderPoint {
+		proposal.ProposedQuantity = roundQuantity(material.MaxStock + leadTimeDemand - position)
+	}
+
+	return proposal
+}
+
+// 辅助函数：按物料汇总期间内的净领用消耗（生产领用减生产退料，不含退供应商和报废）
+func (s *ReplenishmentService) consumptionByMaterial(tx *gorm.DB, start, end time.Time) (map[uint]float64, error) {
+	var rows []struct {
+		MaterialID uint
+		Quantity   float64
+	}
+	if err := tx.Model(&models.MaterialTransaction{}).
+		Select("material_id, COALESCE(SUM(CASE WHEN type = 'out' THEN quantity ELSE -quantity END), 0) AS quantity").
+		Where("type IN ? AND created_at >= ? AND created_at < ?", []string{"out", "return_production"}, start, end).
+		Group("material_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("汇总物料消耗失败: %v", err)
+	}
+
+	consumption := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		// 期间内退回的是期间之前领用的物料时净消耗可能为负，按0计
+		if row.Quantity > 0 {
+			consumption[row.MaterialID] = roundQuantity(row.Quantity)
+		}
+	}
+	return consumption, nil
+}
+
+// openOrderQuantityByMaterial 按物料汇总未收齐的采购订单数量（已下达和部分收货的订单，草稿未下达不计入）
+func openOrderQuantityByMaterial(tx *gorm.DB) (map[uint]float64, error) {
+	var rows []struct {
+		MaterialID uint
+		Quantity   float64
+	}
+	if err := tx.Table("purchase_order_lines AS l").
+		Select("l.material_id, COALESCE(SUM(l.quantity - l.received_quantity), 0) AS quantity").
+		Joins("JOIN purchase_orders AS o ON o.id = l.purchase_order_id AND o.deleted_at IS NULL").
+		Where("l.deleted_at IS NULL AND l.status = ? AND o.status IN ?", "open", []string{"released", "partial"}).
+		Group("l.material_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("汇总采购在途数量失败: %v", err)
+	}
+
+	quantities := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		quantities[row.MaterialID] = roundQuantity(row.Quantity)
+	}
+	return quantities, nil
+}
+
+// 辅助函数：检查批准后是否已为该物料下达采购订单，草稿和已取消的订单不计入
+func hasPurchaseOrderSince(tx *gorm.DB, materialID uint, since time.Time) (bool, error) {
+	var count int64
+	if err := tx.Table("purchase_order_lines AS l").
+		Joins("JOIN purchase_orders AS o ON o.id = l.purchase_order_id AND o.deleted_at IS NULL").
+		Where("l.deleted_at IS NULL AND l.material_id = ? AND l.created_at >= ?", materialID, since).
+		Where("o.status IN ?", []string{"released", "partial", "received"}).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("获取采购订单行失败: %v", err)
+	}
+	return count > 0, nil
+}
+
+// 辅助函数：关闭补货建议
+func closeProposal(tx *gorm.DB, proposal *models.ReplenishmentProposal, reason string) error {
+	remark := reason
+	if proposal.Remark != "" {
+		remark = proposal.Remark + "；" + reason
+	}
+	if err := tx.Model(proposal).Updates(map[string]interface{}{
+		"status": "closed",
+		"remark": remark,
+	}).Error; err != nil {
+		return fmt.Errorf("关闭补货建议失败: %v", err)
+	}
+	return nil
+}
+
+// ceilToPrecision 按指定小数位数向上取整，补货数量不应少于计算需求
+func ceilToPrecision(value float64, precision int) float64 {
+	scale := math.Pow10(precision)
+	return math.Ceil(roundQuantity(value)*scale) / scale
+}
+
+// generateProposalNo 生成补货建议单号
+func generateProposalNo(tx *gorm.DB) string {
+	prefix := fmt.Sprintf("RPL%s", time.Now().Format("20060102"))
+
+	var count int64
+	tx.Unscoped().Model(&models.ReplenishmentProposal{}).
+		Where("proposal_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：构建补货建议查询条件
+func (s *ReplenishmentService) proposalQuery(status string, materialID uint) *gorm.DB {
+	query := s.db.Model(&models.ReplenishmentProposal{})
+
+	// 按状态筛选
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 按物料筛选
+	if materialID > 0 {
+		query = query.Where("material_id = ?", materialID)
+	}
+
+	return query
+}
+
+// 辅助函数：转换为响应结构体
+func (s *ReplenishmentService) proposalToResponse(proposal *models.ReplenishmentProposal) *ReplenishmentProposalResponse {
+	resp := &ReplenishmentProposalResponse{
+		ID:                  proposal.ID,
+		ProposalNo:          proposal.ProposalNo,
+		MaterialID:          proposal.MaterialID,
+		MaterialCode:        proposal.Material.Code,
+		MaterialName:        proposal.Material.Name,
+		Unit:                proposal.Material.Unit,
+		MinStock:            proposal.Material.MinStock,
+		MaxStock:            proposal.Material.MaxStock,
+		OnHandStock:         proposal.OnHandStock,
+		OpenOrderQuantity:   proposal.OpenOrderQuantity,
+		AvgDailyConsumption: proposal.AvgDailyConsumption,
+		DaysOfCover:         proposal.DaysOfCover,
+		LeadTimeDays:        proposal.LeadTimeDays,
+		ReorderPoint:        proposal.ReorderPoint,
+		TargetStock:         proposal.TargetStock,
+		ProposedQuantity:    proposal.ProposedQuantity,
+		ApprovedQuantity:    proposal.ApprovedQuantity,
+		Status:              proposal.Status,
+		Trigger:             proposal.Trigger,
+		GeneratedAt:         proposal.GeneratedAt,
+		ReviewedBy:          proposal.ReviewedBy,
+		ReviewedAt:          proposal.ReviewedAt,
+		Remark:              proposal.Remark,
+		CreatedAt:           proposal.CreatedAt,
+	}
+	if proposal.Reviewer != nil {
+		resp.ReviewerName = proposal.Reviewer.Username
+	}
+	return resp
+}
@@ -0,0 +1,558 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mes-system/internal/models"
+)
+
+// RequisitionLineRequest 领料单行请求结构体
+type RequisitionLineRequest struct {
+	MaterialID uint    `json:"material_id" binding:"required"`   // 物料ID
+	Quantity   float64 `json:"quantity" binding:"required,gt=0"` // 申请数量（按录入单位）
+	Unit       string  `json:"unit"`                             // 录入单位，默认物料基本单位
+	Remark     string  `json:"remark"`                           // 备注
+}
+
+// RequisitionRequest 领料单请求结构体
+type RequisitionRequest struct {
+	ProductionOrderID *uint                    `json:"production_order_id"`                 // 生产工单ID，与成本中心二选一
+	CostCenter        string                   `json:"cost_center"`                         // 成本中心，与生产工单二选一
+	RequiredDate      *time.Time               `json:"required_date"`                       // 需求日期
+	Remark            string                   `json:"remark"`                              // 备注
+	Lines             []RequisitionLineRequest `json:"lines" binding:"required,min=1,dive"` // 领料明细
+}
+
+// RequisitionReviewRequest 领料单审批请求结构体
+type RequisitionReviewRequest struct {
+	Remark string `json:"remark"` // 审批意见
+}
+
+// RequisitionIssueLineRequest 领料单发料行请求结构体
+type RequisitionIssueLineRequest struct {
+	LineID   uint    `json:"line_id" binding:"required"`       // 领料单行ID
+	Quantity float64 `json:"quantity" binding:"required,gt=0"` // 本次发料数量（按录入单位）
+	Unit     string  `json:"unit"`                             // 录入单位，默认物料基本单位
+}
+
+// RequisitionIssueRequest 领料单发料请求结构体
+type RequisitionIssueRequest struct {
+	Lines  []RequisitionIssueLineRequest `json:"lines" binding:"required,min=1,dive"` // 发料明细，允许部分发料
+	Remark string                        `json:"remark"`                              // 备注
+}
+
+// RequisitionLineResponse 领料单行响应结构体
+type RequisitionLineResponse struct {
+	ID             uint    `json:"id"`
+	LineNo         int     `json:"line_no"`
+	MaterialID     uint    `json:"material_id"`
+	MaterialCode   string  `json:"material_code"`
+	MaterialName   string  `json:"material_name"`
+	Unit           string  `json:"unit"`
+	Quantity       float64 `json:"quantity"`
+	IssuedQuantity float64 `json:"issued_quantity"`
+	OpenQuantity   float64 `json:"open_quantity"`
+	Status         string  `json:"status"`
+	Remark         string  `json:"remark"`
+}
+
+// RequisitionIssueResponse 领料单发料记录响应结构体
+type RequisitionIssueResponse struct {
+	TransactionID   uint      `json:"transaction_id"`
+	LineID          uint      `json:"line_id"`
+	MaterialCode    string    `json:"material_code"`
+	MaterialName    string    `json:"material_name"`
+	Quantity        float64   `json:"quantity"`
+	EnteredQuantity float64   `json:"entered_quantity"`
+	EnteredUnit     string    `json:"entered_unit"`
+	TotalAmount     float64   `json:"total_amount"`
+	OperatorID      uint      `json:"operator_id"`
+	OperatorName    string    `json:"operator_name"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// RequisitionResponse 领料单响应结构体
+type RequisitionResponse struct {
+	ID                uint                       `json:"id"`
+	RequisitionNo     string                     `json:"requisition_no"`
+	ProductionOrderID *uint                      `json:"production_order_id"`
+	ProductionOrderNo string                     `json:"production_order_no"`
+	CostCenter        string                     `json:"cost_center"`
+	RequiredDate      *time.Time                 `json:"required_date"`
+	Status            string                     `json:"status"`
+	RequestedBy       uint                       `json:"requested_by"`
+	RequesterName     string                     `json:"requester_name"`
+	ApprovedBy        *uint                      `json:"approved_by"`
+	ApproverName      string                     `json:"approver_name"`
+	ApprovedAt        *time.Time                 `json:"approved_at"`
+	ApprovalRemark    string                     `json:"approval_remark"`
+	Remark            string                     `json:"remark"`
+	Lines             []RequisitionLineResponse  `json:"lines,omitempty"`
+	Issues            []RequisitionIssueResponse `json:"issues,omitempty"`
+	CreatedAt         time.Time                  `json:"created_at"`
+	UpdatedAt         time.Time                  `json:"updated_at"`
+}
+
+// RequisitionService 领料单服务
+type RequisitionService struct {
+	db *gorm.DB
+}
+
+// NewRequisitionService 创建领料单服务实例
+func NewRequisitionService(db *gorm.DB) *RequisitionService {
+	return &RequisitionService{db: db}
+}
+
+// CreateRequisition 创建领料单，任何用户都可以为生产工单或成本中心申请领料
+func (s *RequisitionService) CreateRequisition(req *RequisitionRequest, requestedBy uint) (*RequisitionResponse, error) {
+	if (req.ProductionOrderID == nil) == (req.CostCenter == "") {
+		return nil, errors.New("必须指定生产工单或成本中心中的一个")
+	}
+
+	if req.ProductionOrderID != nil {
+		var order models.ProductionOrder
+		if err := s.db.First(&order, *req.ProductionOrderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("生产工单不存在")
+			}
+			return nil, fmt.Errorf("验证生产工单失败: %v", err)
+		}
+		if order.Status == "completed" || order.Status == "cancelled" {
+			return nil, fmt.Errorf("生产工单 %s 已完成或已取消，不能领料", order.OrderNo)
+		}
+	}
+
+	lines := make([]models.MaterialRequisitionLine, 0, len(req.Lines))
+	for i, lineReq := range req.Lines {
+		var material models.Material
+		if err := s.db.First(&material, lineReq.MaterialID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("第 %d 行物料不存在", i+1)
+			}
+			return nil, fmt.Errorf("验证物料失败: %v", err)
+		}
+
+		quantity, err := normalizeQuantity(s.db, &material, lineReq.Quantity, lineReq.Unit)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %v", i+1, err)
+		}
+
+		lines = append(lines, models.MaterialRequisitionLine{
+			LineNo:     i + 1,
+			MaterialID: material.ID,
+			Quantity:   quantity,
+			Status:     "open",
+			Remark:     lineReq.Remark,
+		})
+	}
+
+	requisition := &models.MaterialRequisition{
+		ProductionOrderID: req.ProductionOrderID,
+		CostCenter:        req.CostCenter,
+		RequiredDate:      req.RequiredDate,
+		Status:            "pending",
+		RequestedBy:       requestedBy,
+		Remark:            req.Remark,
+		Lines:             lines,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		requisition.RequisitionNo = generateRequisitionNo(tx)
+		if err := tx.Create(requisition).Error; err != nil {
+			return fmt.Errorf("创建领料单失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRequisition(requisition.ID)
+}
+
+// GetRequisition 获取领料单详情，包含发料记录
+func (s *RequisitionService) GetRequisition(id uint) (*RequisitionResponse, error) {
+	var requisition models.MaterialRequisition
+	err := s.db.Preload("ProductionOrder").Preload("Requester").Preload("Approver").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("line_no") }).
+		Preload("Lines.Material").
+		First(&requisition, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("领料单不存在")
+		}
+		return nil, fmt.Errorf("获取领料单失败: %v", err)
+	}
+
+	var transactions []models.MaterialTransaction
+	if err := s.db.Preload("Material").Preload("Operator").
+		Where("requisition_id = ?", id).Order("created_at, id").
+		Find(&transactions).Error; err != nil {
+		return nil, fmt.Errorf("获取发料记录失败: %v", err)
+	}
+
+	resp := s.requisitionToResponse(&requisition, true)
+	for _, transaction := range transactions {
+		issue := RequisitionIssueResponse{
+			TransactionID:   transaction.ID,
+			MaterialCode:    transaction.Material.Code,
+			MaterialName:    transaction.Material.Name,
+			Quantity:        transaction.Quantity,
+			EnteredQuantity: transaction.EnteredQuantity,
+			EnteredUnit:     transaction.EnteredUnit,
+			TotalAmount:     transaction.TotalAmount,
+			OperatorID:      transaction.OperatorID,
+			OperatorName:    transaction.Operator.Username,
+			CreatedAt:       transaction.CreatedAt,
+		}
+		if transaction.RequisitionLineID != nil {
+			issue.LineID = *transaction.RequisitionLineID
+		}
+		resp.Issues = append(resp.Issues, issue)
+	}
+
+	return resp, nil
+}
+
+// GetRequisitionList 获取领料单列表
+func (s *RequisitionService) GetRequisitionList(page, pageSize int, status, costCenter, keyword string, productionOrderID, requestedBy uint) ([]RequisitionResponse, int64, error) {
+	var requisitions []models.MaterialRequisition
+	var total int64
+
+	query := s.db.Model(&models.MaterialRequisition{})
+
+	// 按状态筛选
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 按成本中心筛选
+	if costCenter != "" {
+		query = query.Where("cost_center = ?", costCenter)
+	}
+
+	// 按生产工单筛选
+	if productionOrderID > 0 {
+		query = query.Where("production_order_id = ?", productionOrderID)
+	}
+
+	// 按申请人筛选
+	if requestedBy > 0 {
+		query = query.Where("requested_by = ?", requestedBy)
+	}
+
+	// 关键词搜索
+	if keyword != "" {
+		query = query.Where("requisition_no LIKE ?", "%"+keyword+"%")
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取领料单总数失败: %v", err)
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Preload("ProductionOrder").Preload("Requester").Preload("Approver").
+		Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&requisitions).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取领料单列表失败: %v", err)
+	}
+
+	var responses []RequisitionResponse
+	for _, requisition := range requisitions {
+		responses = append(responses, *s.requisitionToResponse(&requisition, false))
+	}
+
+	return responses, total, nil
+}
+
+// ApproveRequisition 批准领料单
+func (s *RequisitionService) ApproveRequisition(id uint, req *RequisitionReviewRequest, approverID uint) (*RequisitionResponse, error) {
+	return s.review(id, "approved", req.Remark, approverID)
+}
+
+// RejectRequisition 驳回领料单
+func (s *RequisitionService) RejectRequisition(id uint, req *RequisitionReviewRequest, approverID uint) (*RequisitionResponse, error) {
+	return s.review(id, "rejected", req.Remark, approverID)
+}
+
+// CancelRequisition 取消尚未发料的领料单，只有申请人或管理员、主管可以取消
+func (s *RequisitionService) CancelRequisition(id, userID uint, role string) (*RequisitionResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		requisition, err := lockRequisition(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if requisition.RequestedBy != userID && role != "admin" && role != "manager" {
+			return errors.New("只有申请人或管理员、主管可以取消领料单")
+		}
+		if requisition.Status != "pending" && requisition.Status != "approved" {
+			return errors.New("只有待审批或已批准且未发料的领料单可以取消")
+		}
+
+		if err := tx.Model(&models.MaterialRequisitionLine{}).
+			Where("requisition_id = ?", id).
+			Update("status", "closed").Error; err != nil {
+			return fmt.Errorf("更新领料单行失败: %v", err)
+		}
+		if err := tx.Model(requisition).Update("status", "cancelled").Error; err != nil {
+			return fmt.Errorf("取消领料单失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRequisition(id)
+}
+
+// IssueRequisition 按已批准的领料单发料，允许部分发料，生成的出库交易关联领料单
+func (s *RequisitionService) IssueRequisition(id uint, req *RequisitionIssueRequest, operatorID uint) (*RequisitionResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		requisition, err := lockRequisition(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if requisition.Status != "approved" && requisition.Status != "partial" {
+			return errors.New("只有已批准或部分发料的领料单可以发料")
+		}
+
+		var lines []models.MaterialRequisitionLine
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Material").
+			Where("requisition_id = ?", id).Find(&lines).Error; err != nil {
+			return fmt.Errorf("获取领料单行失败: %v", err)
+		}
+		linesByID := make(map[uint]*models.MaterialRequisitionLine, len(lines))
+		for i := range lines {
+			linesByID[lines[i].ID] = &lines[i]
+		}
+
+		remark := fmt.Sprintf("领料单 %s 发料", requisition.RequisitionNo)
+		if req.Remark != "" {
+			remark = remark + "：" + req.Remark
+		}
+
+		for _, issueReq := range req.Lines {
+			line, exists := linesByID[issueReq.LineID]
+			if !exists {
+				return fmt.Errorf("领料单行 %d 不属于该领料单", issueReq.LineID)
+			}
+			if line.Status != "open" {
+				return fmt.Errorf("第 %d 行已发齐或已关闭", line.LineNo)
+			}
+
+			unit := issueReq.Unit
+			if unit == "" {
+				unit = line.Material.Unit
+			}
+			quantity, err := normalizeQuantity(tx, &line.Material, issueReq.Quantity, unit)
+			if err != nil {
+				return fmt.Errorf("第 %d 行: %v", line.LineNo, err)
+			}
+
+			openQuantity := roundQuantity(line.Quantity - line.IssuedQuantity)
+			if quantity > openQuantity {
+				return fmt.Errorf("第 %d 行发料数量超过未发数量 %v", line.LineNo, openQuantity)
+			}
+
+			lineID := line.ID
+			transaction := &models.MaterialTransaction{
+				MaterialID:        line.MaterialID,
+				Type:              "out",
+				Quantity:          quantity,
+				EnteredQuantity:   issueReq.Quantity,
+				EnteredUnit:       unit,
+				ProductionOrderID: requisition.ProductionOrderID,
+				RequisitionID:     &requisition.ID,
+				RequisitionLineID: &lineID,
+				Remark:            remark,
+				OperatorID:        operatorID,
+			}
+			if _, err := postMaterialTransaction(tx, transaction); err != nil {
+				return fmt.Errorf("第 %d 行: %v", line.LineNo, err)
+			}
+
+			line.IssuedQuantity = roundQuantity(line.IssuedQuantity + quantity)
+			if line.IssuedQuantity >= line.Quantity {
+				line.Status = "issued"
+			}
+			if err := tx.Model(line).Updates(map[string]interface{}{
+				"issued_quantity": line.IssuedQuantity,
+				"status":          line.Status,
+			}).Error; err != nil {
+				return fmt.Errorf("更新领料单行失败: %v", err)
+			}
+		}
+
+		// 所有行发齐后领料单变为已发料，否则为部分发料
+		status := "issued"
+		for _, line := range lines {
+			if line.Status == "open" {
+				status = "partial"
+				break
+			}
+		}
+		if err := tx.Model(requisition).Update("status", status).Error; err != nil {
+			return fmt.Errorf("更新领料单状态失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRequisition(id)
+}
+
+// CloseRequisition 关闭部分发料的领料单，剩余未发数量不再发料
+func (s *RequisitionService) CloseRequisition(id uint, req *RequisitionReviewRequest) (*RequisitionResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		requisition, err := lockRequisition(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if requisition.Status != "approved" && requisition.Status != "partial" {
+			return errors.New("只有已批准或部分发料的领料单可以关闭")
+		}
+
+		if err := tx.Model(&models.MaterialRequisitionLine{}).
+			Where("requisition_id = ? AND status = ?", id, "open").
+			Update("status", "closed").Error; err != nil {
+			return fmt.Errorf("更新领料单行失败: %v", err)
+		}
+
+		updates := map[string]interface{}{"status": "closed"}
+		if req.Remark != "" {
+			updates["remark"] = req.Remark
+		}
+		if err := tx.Model(requisition).Updates(updates).Error; err != nil {
+			return fmt.Errorf("关闭领料单失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRequisition(id)
+}
+
+// 辅助函数：审批领料单
+func (s *RequisitionService) review(id uint, status, remark string, approverID uint) (*RequisitionResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		requisition, err := lockRequisition(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if requisition.Status != "pending" {
+			return errors.New("只有待审批的领料单可以审批")
+		}
+
+		if status == "rejected" {
+			if err := tx.Model(&models.MaterialRequisitionLine{}).
+				Where("requisition_id = ?", id).
+				Update("status", "closed").Error; err != nil {
+				return fmt.Errorf("更新领料单行失败: %v", err)
+			}
+		}
+
+		now := time.Now()
+		if err := tx.Model(requisition).Updates(map[string]interface{}{
+			"status":          status,
+			"approved_by":     approverID,
+			"approved_at":     now,
+			"approval_remark": remark,
+		}).Error; err != nil {
+			return fmt.Errorf("审批领料单失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRequisition(id)
+}
+
+// 辅助函数：锁定领料单
+func lockRequisition(tx *gorm.DB, id uint) (*models.MaterialRequisition, error) {
+	var requisition models.MaterialRequisition
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&requisition, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("领料单不存在")
+		}
+		return nil, fmt.Errorf("获取领料单失败: %v", err)
+	}
+	return &requisition, nil
+}
+
+// generateRequisitionNo 生成领料单号
+func generateRequisitionNo(tx *gorm.DB) string {
+	prefix := fmt.Sprintf("REQ%s", time.Now().Format("20060102"))
+
+	var count int64
+	tx.Unscoped().Model(&models.MaterialRequisition{}).
+		Where("requisition_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：转换为响应结构体
+func (s *RequisitionService) requisitionToResponse(requisition *models.MaterialRequisition, withLines bool) *RequisitionResponse {
+	resp := &RequisitionResponse{
+		ID:                requisition.ID,
+		RequisitionNo:     requisition.RequisitionNo,
+		ProductionOrderID: requisition.ProductionOrderID,
+		CostCenter:        requisition.CostCenter,
+		RequiredDate:      requisition.RequiredDate,
+		Status:            requisition.Status,
+		RequestedBy:       requisition.RequestedBy,
+		RequesterName:     requisition.Requester.Username,
+		ApprovedBy:        requisition.ApprovedBy,
+		ApprovedAt:        requisition.ApprovedAt,
+		ApprovalRemark:    requisition.ApprovalRemark,
+		Remark:            requisition.Remark,
+		CreatedAt:         requisition.CreatedAt,
+		UpdatedAt:         requisition.UpdatedAt,
+	}
+	if requisition.ProductionOrder != nil {
+		resp.ProductionOrderNo = requisition.ProductionOrder.OrderNo
+	}
+	if requisition.Approver != nil {
+		resp.ApproverName = requisition.Approver.Username
+	}
+
+	if withLines {
+		for _, line := range requisition.Lines {
+			var openQuantity float64
+			if line.Status == "open" {
+				openQuantity = roundQuantity(line.Quantity - line.IssuedQuantity)
+			}
+			resp.Lines = append(resp.Lines, RequisitionLineResponse{
+				ID:             line.ID,
+				LineNo:         line.LineNo,
+				MaterialID:     line.MaterialID,
+				MaterialCode:   line.Material.Code,
+				MaterialName:   line.Material.Name,
+				Unit:           line.Material.Unit,
+				Quantity:       line.Quantity,
+				IssuedQuantity: line.IssuedQuantity,
+				OpenQuantity:   openQuantity,
+				Status:         line.Status,
+				Remark:         line.Remark,
+			})
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,764 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mes-system/internal/models"
+)
+
+// 订单行交付风险原因
+const (
+	SalesRiskOverdue      = "overdue"       // 已过交货日期仍未生产完成
+	SalesRiskUnplanned    = "unplanned"     // 临近交货日期仍有未计划数量
+	SalesRiskLateSchedule = "late_schedule" // 关联工单的计划完工日期晚于交货日期
+	SalesRiskNotStarted   = "not_started"   // 临近交货日期，关联工单已过计划开工日期仍未开工
+)
+
+// defaultFulfillmentHorizonDays 交付风险的默认预警天数
+const defaultFulfillmentHorizonDays = 7
+
+// SalesOrderLineRequest 销售订单行请求结构体
+type SalesOrderLineRequest struct {
+	ProductID uint      `json:"product_id" binding:"required"`    // 产品ID
+	Quantity  float64   `json:"quantity" binding:"required,gt=0"` // 订购数量
+	Price     float64   `json:"price" binding:"min=0"`            // 销售单价，为0时取产品单价
+	DueDate   time.Time `json:"due_date" binding:"required"`      // 交货日期
+	Remark    string    `json:"remark"`                           // 备注
+}
+
+// SalesOrderRequest 销售订单请求结构体
+type SalesOrderRequest struct {
+	CustomerID uint                    `json:"customer_id" binding:"required"`      // 客户ID
+	CustomerPO string                  `json:"customer_po"`                         // 客户采购单号
+	OrderDate  *time.Time              `json:"order_date"`                          // 下单日期，默认为当前时间
+	Remark     string                  `json:"remark"`                              // 备注
+	Lines      []SalesOrderLineRequest `json:"lines" binding:"required,min=1,dive"` // 订单行
+}
+
+// SalesOrderProductionRequest 按销售订单行创建生产工单请求结构体
+type SalesOrderProductionRequest struct {
+	Quantity  float64    `json:"quantity" binding:"min=0"`       // 生产数量，为0时取订单行未计划数量
+	Priority  int        `json:"priority" binding:"min=0,max=5"` // 优先级，默认为3
+	StartDate *time.Time `json:"start_date"`                     // 计划开工日期
+	EndDate   *time.Time `json:"end_date"`                       // 计划完工日期，默认为订单行交货日期
+}
+
+// SalesOrderLineResponse 销售订单行响应结构体
+type SalesOrderLineResponse struct {
+	ID               uint      `json:"id"`
+	LineNo           int       `json:"line_no"`
+	ProductID        uint      `json:"product_id"`
+	ProductCode      string    `json:"product_code"`
+	ProductName      string    `json:"product_name"`
+	Unit             string    `json:"unit"`
+	Quantity         float64   `json:"quantity"`
+	PlannedQuantity  float64   `json:"planned_quantity"`  // 已下达生产工单的数量
+	ProducedQuantity float64   `json:"produced_quantity"` // 关联生产工单的已生产数量
+	ShippedQuantity  float64   `json:"shipped_quantity"`  // 已发货数量
+	Price            float64   `json:"price"`
+	Amount           float64   `json:"amount"`
+	DueDate          time.Time `json:"due_date"`
+	Status           string    `json:"status"`
+	Remark           string    `json:"remark"`
+}
+
+// SalesOrderResponse 销售订单响应结构体
+type SalesOrderResponse struct {
+	ID           uint                     `json:"id"`
+	OrderNo      string                   `json:"order_no"`
+	CustomerID   uint                     `json:"customer_id"`
+	CustomerCode string                   `json:"customer_code"`
+	CustomerName string                   `json:"customer_name"`
+	CustomerPO   string                   `json:"customer_po"`
+	OrderDate    time.Time                `json:"order_date"`
+	Status       string                   `json:"status"`
+	TotalAmount  float64                  `json:"total_amount"`
+	Remark       string                   `json:"remark"`
+	CreatedBy    uint                     `json:"created_by"`
+	CreatorName  string                   `json:"creator_name"`
+	Lines        []SalesOrderLineResponse `json:"lines,omitempty"`
+	CreatedAt    time.Time                `json:"created_at"`
+	UpdatedAt    time.Time                `json:"updated_at"`
+}
+
+// SalesLineFulfillment 销售订单行交付情况
+type SalesLineFulfillment struct {
+	SalesOrderID         uint       `json:"sales_order_id"`
+	OrderNo              string     `json:"order_no"`
+	CustomerID           uint       `json:"customer_id"`
+	CustomerName         string     `json:"customer_name"`
+	LineID               uint       `json:"line_id"`
+	LineNo               int        `json:"line_no"`
+	ProductID            uint       `json:"product_id"`
+	ProductCode          string     `json:"product_code"`
+	ProductName          string     `json:"product_name"`
+	Quantity             float64    `json:"quantity"`             // 订购数量
+	PlannedQuantity      float64    `json:"planned_quantity"`     // 已下达生产工单的数量
+	UnplannedQuantity    float64    `json:"unplanned_quantity"`   // 未计划数量
+	InProgressQuantity   float64    `json:"in_progress_quantity"` // 未完工工单的剩余生产数量
+	ProducedQuantity     float64    `json:"produced_quantity"`    // 已生产数量
+	RemainingQuantity    float64    `json:"remaining_quantity"`   // 尚未生产的数量
+	ShippedQuantity      float64    `json:"shipped_quantity"`     // 已发货数量
+	ProductionOrderCount int        `json:"production_order_count"`
+	DueDate              time.Time  `json:"due_date"`
+	DaysToDue            int        `json:"days_to_due"`         // 距交货日期天数，负数表示已逾期
+	ExpectedCompletion   *time.Time `json:"expected_completion"` // 未完工工单中最晚的计划完工日期
+	AtRisk               bool       `json:"at_risk"`
+	RiskReasons          []string   `json:"risk_reasons"` // 风险原因：overdue/unplanned/late_schedule/not_started
+}
+
+// salesLineProduction 销售订单行关联生产工单的汇总
+type salesLineProduction struct {
+	Planned    float64
+	InProgress float64
+	Produced   float64
+	OrderCount int
+	LatestEnd  *time.Time
+	NotStarted bool // 存在已过计划开工日期仍未开工的工单
+}
+
+// SalesOrderService 销售订单服务
+type SalesOrderService struct {
+	db                *gorm.DB
+	productionService *ProductionService
+}
+
+// NewSalesOrderService 创建销售订单服务实例
+func NewSalesOrderService(db *gorm.DB, productionService *ProductionService) *SalesOrderService {
+	return &SalesOrderService{db: db, productionService: productionService}
+}
+
+// CreateSalesOrder 创建销售订单（草稿状态）
+func (s *SalesOrderService) CreateSalesOrder(req *SalesOrderRequest, createdBy uint) (*SalesOrderResponse, error) {
+	if err := s.checkCustomer(req.CustomerID); err != nil {
+		return nil, err
+	}
+
+	lines, err := s.buildOrderLines(req)
+	if err != nil {
+		return nil, err
+	}
+
+	orderDate := time.Now()
+	if req.OrderDate != nil {
+		orderDate = *req.OrderDate
+	}
+
+	order := &models.SalesOrder{
+		OrderNo:    s.generateOrderNo(),
+		CustomerID: req.CustomerID,
+		CustomerPO: req.CustomerPO,
+		OrderDate:  orderDate,
+		Status:     "draft",
+		Remark:     req.Remark,
+		CreatedBy:  createdBy,
+		Lines:      lines,
+	}
+
+	if err := s.db.Create(order).Error; err != nil {
+		return nil, fmt.Errorf("创建销售订单失败: %v", err)
+	}
+
+	return s.GetSalesOrder(order.ID)
+}
+
+// GetSalesOrder 获取销售订单详情
+func (s *SalesOrderService) GetSalesOrder(id uint) (*SalesOrderResponse, error) {
+	var order models.SalesOrder
+	err := s.db.Preload("Customer").Preload("Creator").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("line_no") }).
+		Preload("Lines.Product").
+		First(&order, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("销售订单不存在")
+		}
+		return nil, fmt.Errorf("获取销售订单失败: %v", err)
+	}
+
+	lineIDs := make([]uint, 0, len(order.Lines))
+	for _, line := range order.Lines {
+		lineIDs = append(lineIDs, line.ID)
+	}
+	production, err := loadSalesLineProduction(s.db, lineIDs, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.orderToResponse(&order, production), nil
+}
+
+// GetSalesOrderList 获取销售订单列表
+func (s *SalesOrderService) GetSalesOrderList(page, pageSize int, status, keyword string, customerID uint) ([]SalesOrderResponse, int64, error) {
+	var orders []models.SalesOrder
+	var total int64
+
+	query := s.db.Model(&models.SalesOrder{})
+
+	// 按状态筛选
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 按客户筛选
+	if customerID > 0 {
+		query = query.Where("customer_id = ?", customerID)
+	}
+
+	// 按订单号或客户采购单号搜索
+	if keyword != "" {
+		query = query.Where("order_no LIKE ? OR customer_po LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取销售订单总数失败: %v", err)
+	}
+
+	// 分页查询，列表的订单金额需要订单行计算
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Customer").Preload("Creator").Preload("Lines").
+		Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&orders).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取销售订单列表失败: %v", err)
+	}
+
+	var responses []SalesOrderResponse
+	for _, order := range orders {
+		resp := s.orderToResponse(&order, nil)
+		resp.Lines = nil
+		responses = append(responses, *resp)
+	}
+
+	return responses, total, nil
+}
+
+// UpdateSalesOrder 更新销售订单，仅草稿状态可修改，订单行整体替换
+func (s *SalesOrderService) UpdateSalesOrder(id uint, req *SalesOrderRequest) (*SalesOrderResponse, error) {
+	var order models.SalesOrder
+	if err := s.db.First(&order, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("销售订单不存在")
+		}
+		return nil, fmt.Errorf("获取销售订单失败: %v", err)
+	}
+
+	if order.Status != "draft" {
+		return nil, errors.New("只有草稿状态的销售订单可以修改")
+	}
+
+	if err := s.checkCustomer(req.CustomerID); err != nil {
+		return nil, err
+	}
+
+	lines, err := s.buildOrderLines(req)
+	if err != nil {
+		return nil, err
+	}
+
+	order.CustomerID = req.CustomerID
+	order.CustomerPO = req.CustomerPO
+	if req.OrderDate != nil {
+		order.OrderDate = *req.OrderDate
+	}
+	order.Remark = req.Remark
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("sales_order_id = ?", order.ID).Delete(&models.SalesOrderLine{}).Error; err != nil {
+			return fmt.Errorf("删除原订单行失败: %v", err)
+		}
+
+		for i := range lines {
+			lines[i].SalesOrderID = order.ID
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return fmt.Errorf("创建订单行失败: %v", err)
+		}
+
+		if err := tx.Save(&order).Error; err != nil {
+			return fmt.Errorf("更新销售订单失败: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetSalesOrder(order.ID)
+}
+
+// DeleteSalesOrder 删除销售订单，仅草稿或已取消的订单可删除
+func (s *SalesOrderService) DeleteSalesOrder(id uint) error {
+	var order models.SalesOrder
+	if err := s.db.First(&order, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("销售订单不存在")
+		}
+		return fmt.Errorf("获取销售订单失败: %v", err)
+	}
+
+	if order.Status != "draft" && order.Status != "cancelled" {
+		return errors.New("只有草稿或已取消的销售订单可以删除")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("sales_order_id = ?", order.ID).Delete(&models.SalesOrderLine{}).Error; err != nil {
+			return fmt.Errorf("删除订单行失败: %v", err)
+		}
+		if err := tx.Delete(&order).Error; err != nil {
+			return fmt.Errorf("删除销售订单失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// ConfirmSalesOrder 确认销售订单，确认后才能按订单行创建生产工单
+func (s *SalesOrderService) ConfirmSalesOrder(id uint) (*SalesOrderResponse, error) {
+	return s.changeStatus(id, "confirmed")
+}
+
+// CloseSalesOrder 关闭销售订单，未结订单行一并关闭
+func (s *SalesOrderService) CloseSalesOrder(id uint) (*SalesOrderResponse, error) {
+	return s.changeStatus(id, "closed")
+}
+
+// CancelSalesOrder 取消销售订单，存在未完工的关联生产工单时不能取消
+func (s *SalesOrderService) CancelSalesOrder(id uint) (*SalesOrderResponse, error) {
+	return s.changeStatus(id, "cancelled")
+}
+
+// CreateProductionOrder 按销售订单行创建生产工单，生产工单保留与订单行的关联
+func (s *SalesOrderService) CreateProductionOrder(lineID uint, req *SalesOrderProductionRequest, createdBy uint) (*models.ProductionOrder, error) {
+	var line models.SalesOrderLine
+	if err := s.db.First(&line, lineID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("销售订单行不存在")
+		}
+		return nil, fmt.Errorf("获取销售订单行失败: %v", err)
+	}
+
+	// 未指定数量时取订单行未计划数量
+	quantity := req.Quantity
+	if quantity == 0 {
+		planned, err := plannedSalesLineQuantity(s.db, line.ID, 0)
+		if err != nil {
+			return nil, err
+		}
+		quantity = roundQuantity(line.Quantity - planned)
+		if quantity <= 0 {
+			return nil, errors.New("销售订单行已全部下达生产工单")
+		}
+	}
+
+	// 未指定计划完工日期时取交货日期
+	endDate := req.EndDate
+	if endDate == nil {
+		dueDate := line.DueDate
+		endDate = &dueDate
+	}
+
+	return s.productionService.CreateProductionOrder(&CreateProductionOrderRequest{
+		ProductID:        line.ProductID,
+		Quantity:         quantity,
+		Priority:         req.Priority,
+		StartDate:        req.StartDate,
+		EndDate:          endDate,
+		SalesOrderLineID: &line.ID,
+	}, createdBy)
+}
+
+// GetFulfillment 获取已确认销售订单行的交付情况：计划、在制和已生产数量，以及相对交货日期的风险
+// horizonDays 为预警天数，交货日期在该天数内仍有未计划数量或未开工工单的订单行视为有风险
+func (s *SalesOrderService) GetFulfillment(customerID, salesOrderID uint, horizonDays int, atRiskOnly bool) ([]SalesLineFulfillment, error) {
+	if horizonDays <= 0 {
+		horizonDays = defaultFulfillmentHorizonDays
+	}
+
+	query := s.db.Model(&models.SalesOrderLine{}).
+		Joins("JOIN sales_orders ON sales_orders.id = sales_order_lines.sales_order_id AND sales_orders.deleted_at IS NULL").
+		Where("sales_orders.status = ?", "confirmed").
+		Where("sales_order_lines.status = ?", "open")
+
+	if customerID > 0 {
+		query = query.Where("sales_orders.customer_id = ?", customerID)
+	}
+	if salesOrderID > 0 {
+		query = query.Where("sales_orders.id = ?", salesOrderID)
+	}
+
+	var lines []models.SalesOrderLine
+	if err := query.Preload("Product").Order("sales_order_lines.due_date, sales_order_lines.id").Find(&lines).Error; err != nil {
+		return nil, fmt.Errorf("获取销售订单行失败: %v", err)
+	}
+
+	// 批量加载订单头
+	orderIDs := make([]uint, 0, len(lines))
+	lineIDs := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		orderIDs = append(orderIDs, line.SalesOrderID)
+		lineIDs = append(lineIDs, line.ID)
+	}
+	orders := make(map[uint]models.SalesOrder)
+	if len(orderIDs) > 0 {
+		var orderList []models.SalesOrder
+		if err := s.db.Preload("Customer").Where("id IN ?", orderIDs).Find(&orderList).Error; err != nil {
+			return nil, fmt.Errorf("获取销售订单失败: %v", err)
+		}
+		for _, order := range orderList {
+			orders[order.ID] = order
+		}
+	}
+
+	now := time.Now()
+	production, err := loadSalesLineProduction(s.db, lineIDs, now)
+	if err != nil {
+		return nil, err
+	}
+
+	today := startOfDay(now)
+	result := make([]SalesLineFulfillment, 0, len(lines))
+	for _, line := range lines {
+		order := orders[line.SalesOrderID]
+		summary := production[line.ID]
+		if summary == nil {
+			summary = &salesLineProduction{}
+		}
+
+		item := SalesLineFulfillment{
+			SalesOrderID:         line.SalesOrderID,
+			OrderNo:              order.OrderNo,
+			CustomerID:           order.CustomerID,
+			CustomerName:         order.Customer.Name,
+			LineID:               line.ID,
+			LineNo:               line.LineNo,
+			ProductID:            line.ProductID,
+			ProductCode:          line.Product.Code,
+			ProductName:          line.Product.Name,
+			Quantity:             line.Quantity,
+			PlannedQuantity:      roundQuantity(summary.Planned),
+			UnplannedQuantity:    math.Max(roundQuantity(line.Quantity-summary.Planned), 0),
+			InProgressQuantity:   roundQuantity(summary.InProgress),
+			ProducedQuantity:     roundQuantity(summary.Produced),
+			RemainingQuantity:    math.Max(roundQuantity(line.Quantity-summary.Produced), 0),
+			ShippedQuantity:      line.ShippedQuantity,
+			ProductionOrderCount: summary.OrderCount,
+			DueDate:              line.DueDate,
+			DaysToDue:            int(startOfDay(line.DueDate).Sub(today).Hours() / 24),
+			ExpectedCompletion:   summary.LatestEnd,
+			RiskReasons:          []string{},
+		}
+
+		// 已生产完成的订单行没有交付风险
+		if item.RemainingQuantity > 0 {
+			if item.DaysToDue < 0 {
+				item.RiskReasons = append(item.RiskReasons, SalesRiskOverdue)
+			}
+			if item.UnplannedQuantity > 0 && item.DaysToDue <= horizonDays {
+				item.RiskReasons = append(item.RiskReasons, SalesRiskUnplanned)
+			}
+			if summary.LatestEnd != nil && startOfDay(*summary.LatestEnd).After(startOfDay(line.DueDate)) {
+				item.RiskReasons = append(item.RiskReasons, SalesRiskLateSchedule)
+			}
+			if summary.NotStarted && item.DaysToDue <= horizonDays {
+				item.RiskReasons = append(item.RiskReasons, SalesRiskNotStarted)
+			}
+		}
+		item.AtRisk = len(item.RiskReasons) > 0
+
+		if atRiskOnly && !item.AtRisk {
+			continue
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+// checkSalesOrderLineForProduction 校验生产工单关联的销售订单行：订单已确认、订单行未关闭、产品一致，且累计下达数量不超过订购数量
+// 需在创建生产工单的同一事务中调用
+func checkSalesOrderLineForProduction(db *gorm.DB, lineID, productID uint, quantity float64) error {
+	// 锁定销售订单行，避免并发下达的生产工单累计超过订购数量
+	var line models.SalesOrderLine
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&line, lineID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("销售订单行不存在")
+		}
+		return fmt.Errorf("获取销售订单行失败: %v", err)
+	}
+
+	var order models.SalesOrder
+	if err := db.First(&order, line.SalesOrderID).Error; err != nil {
+		return fmt.Errorf("获取销售订单失败: %v", err)
+	}
+
+	if order.Status != "confirmed" {
+		return fmt.Errorf("销售订单 %s 未确认或已结束，不能下达生产工单", order.OrderNo)
+	}
+	if line.Status != "open" {
+		return fmt.Errorf("销售订单 %s 第 %d 行已关闭", order.OrderNo, line.LineNo)
+	}
+	if line.ProductID != productID {
+		return errors.New("生产工单产品与销售订单行产品不一致")
+	}
+
+	return checkSalesLinePlannedQuantity(db, &line, quantity, 0)
+}
+
+// checkSalesLinePlannedQuantity 校验销售订单行累计下达的生产数量不超过订购数量，excludeOrderID 为正在修改的生产工单
+func checkSalesLinePlannedQuantity(db *gorm.DB, line *models.SalesOrderLine, quantity float64, excludeOrderID uint) error {
+	planned, err := plannedSalesLineQuantity(db, line.ID, excludeOrderID)
+	if err != nil {
+		return err
+	}
+
+	openQuantity := roundQuantity(line.Quantity - planned)
+	if roundQuantity(quantity) > openQuantity {
+		return fmt.Errorf("生产数量超过销售订单行未计划数量 %v", math.Max(openQuantity, 0))
+	}
+	return nil
+}
+
+// plannedSalesLineQuantity 汇总销售订单行已下达的生产数量，已取消的工单只计已生产数量
+func plannedSalesLineQuantity(db *gorm.DB, lineID, excludeOrderID uint) (float64, error) {
+	query := db.Model(&models.ProductionOrder{}).
+		Select("COALESCE(SUM(CASE WHEN status = ? THEN produced ELSE quantity END), 0)", "cancelled").
+		Where("sales_order_line_id = ?", lineID)
+	if excludeOrderID > 0 {
+		query = query.Where("id != ?", excludeOrderID)
+	}
+
+	var planned float64
+	if err := query.Scan(&planned).Error; err != nil {
+		return 0, fmt.Errorf("汇总销售订单行计划数量失败: %v", err)
+	}
+	return planned, nil
+}
+
+// loadSalesLineProduction 按销售订单行汇总关联生产工单的计划、在制和已生产数量
+func loadSalesLineProduction(db *gorm.DB, lineIDs []uint, asOf time.Time) (map[uint]*salesLineProduction, error) {
+	result := make(map[uint]*salesLineProduction)
+	if len(lineIDs) == 0 {
+		return result, nil
+	}
+
+	var orders []models.ProductionOrder
+	if err := db.Where("sales_order_line_id IN ?", lineIDs).Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("获取关联生产工单失败: %v", err)
+	}
+
+	today := startOfDay(asOf)
+	for _, order := range orders {
+		summary := result[*order.SalesOrderLineID]
+		if summary == nil {
+			summary = &salesLineProduction{}
+			result[*order.SalesOrderLineID] = summary
+		}
+
+		summary.OrderCount++
+		summary.Produced += order.Produced
+
+		// 已取消的工单只计已生产的部分
+		if order.Status == "cancelled" {
+			summary.Planned += order.Produced
+			continue
+		}
+		summary.Planned += order.Quantity
+
+		if order.Status == "pending" || order.Status == "processing" {
+			summary.InProgress += roundQuantity(order.Quantity - order.Produced)
+			if order.EndDate != nil && (summary.LatestEnd == nil || order.EndDate.After(*summary.LatestEnd)) {
+				endDate := *order.EndDate
+				summary.LatestEnd = &endDate
+			}
+			if order.Status == "pending" && order.StartDate != nil && order.StartDate.Before(today) {
+				summary.NotStarted = true
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// 辅助函数：校验客户存在且已启用
+func (s *SalesOrderService) checkCustomer(customerID uint) error {
+	var customer models.Customer
+	if err := s.db.First(&customer, customerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("客户不存在")
+		}
+		return fmt.Errorf("获取客户失败: %v", err)
+	}
+	if customer.Status != 1 {
+		return fmt.Errorf("客户 %s 已停用", customer.Name)
+	}
+	return nil
+}
+
+// 辅助函数：校验产品并构建订单行
+func (s *SalesOrderService) buildOrderLines(orderReq *SalesOrderRequest) ([]models.SalesOrderLine, error) {
+	lines := make([]models.SalesOrderLine, 0, len(orderReq.Lines))
+
+	for i, req := range orderReq.Lines {
+		var product models.Product
+		if err := s.db.First(&product, req.ProductID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("第 %d 行产品不存在", i+1)
+			}
+			return nil, fmt.Errorf("获取产品失败: %v", err)
+		}
+		if product.Status != 1 {
+			return nil, fmt.Errorf("第 %d 行产品 %s 已停用", i+1, product.Name)
+		}
+
+		price := req.Price
+		if price == 0 {
+			price = product.Price
+		}
+
+		lines = append(lines, models.SalesOrderLine{
+			LineNo:    i + 1,
+			ProductID: req.ProductID,
+			Quantity:  roundQuantity(req.Quantity),
+			Price:     price,
+			DueDate:   req.DueDate,
+			Status:    "open",
+			Remark:    req.Remark,
+		})
+	}
+
+	return lines, nil
+}
+
+// 辅助函数：变更销售订单状态
+func (s *SalesOrderService) changeStatus(id uint, to string) (*SalesOrderResponse, error) {
+	var order models.SalesOrder
+	if err := s.db.First(&order, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("销售订单不存在")
+		}
+		return nil, fmt.Errorf("获取销售订单失败: %v", err)
+	}
+
+	if !s.isValidStatusTransition(order.Status, to) {
+		return nil, fmt.Errorf("不能从状态 %s 转换到 %s", order.Status, to)
+	}
+
+	// 取消订单前检查是否存在未完工的关联生产工单
+	if to == "cancelled" {
+		var activeOrders int64
+		s.db.Model(&models.ProductionOrder{}).
+			Joins("JOIN sales_order_lines ON sales_order_lines.id = production_orders.sales_order_line_id").
+			Where("sales_order_lines.sales_order_id = ?", order.ID).
+			Where("production_orders.status IN ?", []string{"pending", "processing"}).
+			Count(&activeOrders)
+		if activeOrders > 0 {
+			return nil, errors.New("销售订单存在未完工的关联生产工单，请先取消或完成生产工单")
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&order).Update("status", to).Error; err != nil {
+			return fmt.Errorf("更新销售订单状态失败: %v", err)
+		}
+
+		// 关闭或取消订单时，未结订单行一并关闭
+		if to == "closed" || to == "cancelled" {
+			if err := tx.Model(&models.SalesOrderLine{}).
+				Where("sales_order_id = ? AND status = ?", order.ID, "open").
+				Update("status", "closed").Error; err != nil {
+				return fmt.Errorf("关闭销售订单行失败: %v", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetSalesOrder(order.ID)
+}
+
+// isValidStatusTransition 验证销售订单状态转换是否合法
+func (s *SalesOrderService) isValidStatusTransition(from, to string) bool {
+	validTransitions := map[string][]string{
+		"draft":     {"confirmed", "cancelled"},
+		"confirmed": {"closed", "cancelled"},
+		"closed":    {}, // 已关闭状态不能转换
+		"cancelled": {}, // 已取消状态不能转换
+	}
+
+	for _, state := range validTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// generateOrderNo 生成销售订单号
+func (s *SalesOrderService) generateOrderNo() string {
+	prefix := fmt.Sprintf("SO%s", time.Now().Format("20060102"))
+
+	var count int64
+	s.db.Unscoped().Model(&models.SalesOrder{}).
+		Where("order_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：转换为响应结构体
+func (s *SalesOrderService) orderToResponse(order *models.SalesOrder, production map[uint]*salesLineProduction) *SalesOrderResponse {
+	resp := &SalesOrderResponse{
+		ID:           order.ID,
+		OrderNo:      order.OrderNo,
+		CustomerID:   order.CustomerID,
+		CustomerCode: order.Customer.Code,
+		CustomerName: order.Customer.Name,
+		CustomerPO:   order.CustomerPO,
+		OrderDate:    order.OrderDate,
+		Status:       order.Status,
+		Remark:       order.Remark,
+		CreatedBy:    order.CreatedBy,
+		CreatorName:  order.Creator.Username,
+		CreatedAt:    order.CreatedAt,
+		UpdatedAt:    order.UpdatedAt,
+	}
+
+	var totalAmount float64
+	for _, line := range order.Lines {
+		amount := roundAmount(line.Quantity * line.Price)
+		totalAmount += amount
+
+		lineResp := SalesOrderLineResponse{
+			ID:              line.ID,
+			LineNo:          line.LineNo,
+			ProductID:       line.ProductID,
+			ProductCode:     line.Product.Code,
+			ProductName:     line.Product.Name,
+			Unit:            line.Product.Unit,
+			Quantity:        line.Quantity,
+			ShippedQuantity: line.ShippedQuantity,
+			Price:           line.Price,
+			Amount:          amount,
+			DueDate:         line.DueDate,
+			Status:          line.Status,
+			Remark:          line.Remark,
+		}
+		if summary := production[line.ID]; summary != nil {
+			lineResp.PlannedQuantity = roundQuantity(summary.Planned)
+			lineResp.ProducedQuantity = roundQuantity(summary.Produced)
+		}
+		resp.Lines = append(resp.Lines, lineResp)
+	}
+	resp.TotalAmount = roundAmount(totalAmount)
+
+	return resp
+}
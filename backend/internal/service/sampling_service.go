@@ -0,0 +1,1018 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"mes-system/internal/models"
+)
+
+// 检验严格度
+const (
+	SamplingSeverityNormal    = "normal"    // 正常检验
+	SamplingSeverityTightened = "tightened" // 加严检验
+	SamplingSeverityReduced   = "reduced"   // 放宽检验
+)
+
+// 检验批判定结果
+const (
+	LotDispositionPending  = "pending"  // 待判定
+	LotDispositionAccepted = "accepted" // 接收
+	LotDispositionRejected = "rejected" // 不接收
+)
+
+// 转移规则参数（ISO 2859-1 第9章）
+const (
+	samplingTightenWindow      = 5  // 正常检验连续5批或少于5批中有2批不接收时转加严
+	samplingTightenRejects     = 2  // 转加严的不接收批数
+	samplingRestoreAccepts     = 5  // 加严检验连续5批接收时恢复正常
+	samplingDiscontinueRejects = 5  // 加严检验累计5批不接收时暂停检验
+	samplingReduceScore        = 30 // 转移得分达到30分可转放宽
+)
+
+// 主抽样表箭头：沿箭头方向使用第一个抽样方案
+const (
+	samplingArrowDown = -1
+	samplingArrowUp   = -2
+)
+
+// samplingLotSizeLimits 批量范围上限，超过最后一档为 500001 及以上
+var samplingLotSizeLimits = []int{8, 15, 25, 50, 90, 150, 280, 500, 1200, 3200, 10000, 35000, 150000, 500000}
+
+// samplingCodeLetterTable 样本量字码表，按批量范围依次列出各检验水平的字码
+var samplingCodeLetterTable = map[string]string{
+	"S-1": "AAAABBBBCCCCDDD",
+	"S-2": "AAABBBCCCDDDEEE",
+	"S-3": "AABBCCDDEEFFGGH",
+	"S-4": "AABCCDEEFGGHJJK",
+	"I":   "AABCCDEFGHJKLMN",
+	"II":  "ABCDEFGHJKLMNPQ",
+	"III": "BCDEFGHJKLMNPQR",
+}
+
+// samplingCodeLetters 样本量字码，S 仅用于加严检验
+const samplingCodeLetters = "ABCDEFGHJKLMNPQRS"
+
+// samplingSampleSizes 各样本量字码对应的样本量
+var samplingSampleSizes = []int{2, 3, 5, 8, 13, 20, 32, 50, 80, 125, 200, 315, 500, 800, 1250, 2000, 3150}
+
+// samplingAQLs 优先的接收质量限，大于10的仅适用于每百单位不合格数
+var samplingAQLs = []float64{0.010, 0.015, 0.025, 0.040, 0.065, 0.10, 0.15, 0.25, 0.40, 0.65, 1.0, 1.5, 2.5, 4.0, 6.5, 10, 15, 25, 40, 65, 100, 150, 250, 400, 650, 1000}
+
+// 主抽样表中字码序号与AQL序号之和相同的格子方案相同，以下为正常和加严检验从首个方案起的接收数序列（拒收数为接收数加1）
+var (
+	samplingNormalDiagonal    = []int{0, samplingArrowUp, samplingArrowDown, 1, 2, 3, 5, 7, 10, 14, 21, 30, 44}
+	samplingTightenedDiagonal = []int{0, samplingArrowUp, samplingArrowDown, 1, 2, 3, 5, 8, 12, 18, 27, 41}
+)
+
+// SamplingSchemeRequest 抽样方案请求结构体
+type SamplingSchemeRequest struct {
+	Name              string  `json:"name" binding:"required"`       // 方案名称
+	ProductID         uint    `json:"product_id" binding:"required"` // 产品ID
+	QualityStandardID *uint   `json:"quality_standard_id"`           // 质量特性ID，为空时按检测单总体结论判定
+	Basis             string  `json:"basis"`                         // 判定依据：nonconforming/nonconformities，默认nonconforming
+	AQL               float64 `json:"aql" binding:"required,gt=0"`   // 接收质量限
+	InspectionLevel   string  `json:"inspection_level"`              // 检验水平：S-1~S-4/I/II/III，默认II
+	AllowReduced      bool    `json:"allow_reduced"`                 // 负责部门批准可转放宽检验
+	IsActive          bool    `json:"is_active"`                     // 是否启用
+	Remark            string  `json:"remark"`                        // 备注
+}
+
+// SamplingSchemeResponse 抽样方案响应结构体
+type SamplingSchemeResponse struct {
+	ID                  uint       `json:"id"`
+	Name                string     `json:"name"`
+	ProductID           uint       `json:"product_id"`
+	ProductCode         string     `json:"product_code"`
+	ProductName         string     `json:"product_name"`
+	QualityStandardID   *uint      `json:"quality_standard_id"`
+	QualityStandardName string     `json:"quality_standard_name"`
+	Basis               string     `json:"basis"`
+	AQL                 float64    `json:"aql"`
+	InspectionLevel     string     `json:"inspection_level"`
+	Severity            string     `json:"severity"`
+	SwitchingScore      int        `json:"switching_score"`
+	AllowReduced        bool       `json:"allow_reduced"`
+	TightenedRejected   int        `json:"tightened_rejected"`
+	Discontinued        bool       `json:"discontinued"`
+	SwitchedAt          *time.Time `json:"switched_at"`
+	IsActive            bool       `json:"is_active"`
+	Remark              string     `json:"remark"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// SwitchSamplingSeverityRequest 手动转移检验严格度请求结构体
+type SwitchSamplingSeverityRequest struct {
+	Severity string `json:"severity" binding:"required,oneof=normal tightened reduced"` // 目标严格度
+	Remark   string `json:"remark"`                                                     // 转移原因
+}
+
+// SamplingPlanResponse 抽样方案查表结果
+type SamplingPlanResponse struct {
+	LotSize         int     `json:"lot_size"`
+	InspectionLevel string  `json:"inspection_level"`
+	AQL             float64 `json:"aql"`
+	Basis           string  `json:"basis"`
+	Severity        string  `json:"severity"`
+	CodeLetter      string  `json:"code_letter"`     // 按批量和检验水平确定的样本量字码
+	SampleSize      int     `json:"sample_size"`     // 样本量
+	AcceptNumber    int     `json:"accept_number"`   // 接收数 Ac
+	RejectNumber    int     `json:"reject_number"`   // 拒收数 Re
+	FullInspection  bool    `json:"full_inspection"` // 样本量不小于批量时进行100%检验
+}
+
+// InspectionLotRequest 检验批请求结构体
+type InspectionLotRequest struct {
+	SamplingSchemeID  uint   `json:"sampling_scheme_id" binding:"required"`  // 抽样方案ID
+	ProductionOrderID uint   `json:"production_order_id" binding:"required"` // 生产工单ID
+	LotSize           int    `json:"lot_size" binding:"required,gt=0"`       // 批量
+	Remark            string `json:"remark"`                                 // 备注
+}
+
+// InspectionLotResponse 检验批响应结构体
+type InspectionLotResponse struct {
+	ID                 uint       `json:"id"`
+	LotNo              string     `json:"lot_no"`
+	SamplingSchemeID   uint       `json:"sampling_scheme_id"`
+	SamplingSchemeName string     `json:"sampling_scheme_name"`
+	Basis              string     `json:"basis"`
+	AQL                float64    `json:"aql"`
+	InspectionLevel    string     `json:"inspection_level"`
+	ProductionOrderID  uint       `json:"production_order_id"`
+	ProductionOrderNo  string     `json:"production_order_no"`
+	ProductID          uint       `json:"product_id"`
+	ProductCode        string     `json:"product_code"`
+	ProductName        string     `json:"product_name"`
+	LotSize            int        `json:"lot_size"`
+	Severity           string     `json:"severity"`
+	CodeLetter         string     `json:"code_letter"`
+	SampleSize         int        `json:"sample_size"`
+	AcceptNumber       int        `json:"accept_number"`
+	RejectNumber       int        `json:"reject_number"`
+	InspectedCount     int        `json:"inspected_count"`
+	NonconformingCount int        `json:"nonconforming_count"`
+	Disposition        string     `json:"disposition"`
+	SeverityAfter      string     `json:"severity_after"`
+	DecidedBy          *uint      `json:"decided_by"`
+	DeciderName        string     `json:"decider_name"`
+	DecidedAt          *time.Time `json:"decided_at"`
+	Remark             string     `json:"remark"`
+	CreatedBy          uint       `json:"created_by"`
+	CreatorName        string     `json:"creator_name"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// SamplingService 抽样检验服务
+type SamplingService struct {
+	db *gorm.DB
+}
+
+// NewSamplingService 创建抽样检验服务实例
+func NewSamplingService(db *gorm.DB) *SamplingService {
+	return &SamplingService{db: db}
+}
+
+// CreateSamplingScheme 创建抽样方案，初始为正常检验
+func (s *SamplingService) CreateSamplingScheme(req *SamplingSchemeRequest) (*SamplingSchemeResponse, error) {
+	if err := s.validateSamplingScheme(req); err != nil {
+		return nil, err
+	}
+
+	scheme := &models.SamplingScheme{
+		Name:              req.Name,
+		ProductID:         req.ProductID,
+		QualityStandardID: req.QualityStandardID,
+		Basis:             req.Basis,
+		AQL:               req.AQL,
+		InspectionLevel:   req.InspectionLevel,
+		Severity:          SamplingSeverityNormal,
+		AllowReduced:      req.AllowReduced,
+		IsActive:          req.IsActive,
+		Remark:            req.Remark,
+	}
+
+	if err := s.db.Create(scheme).Error; err != nil {
+		return nil, fmt.Errorf("创建抽样方案失败: %v", err)
+	}
+
+	return s.GetSamplingScheme(scheme.ID)
+}
+
+// GetSamplingScheme 获取抽样方案详情
+func (s *SamplingService) GetSamplingScheme(id uint) (*SamplingSchemeResponse, error) {
+	scheme, err := s.loadSamplingScheme(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	return samplingSchemeToResponse(scheme), nil
+}
+
+// GetSamplingSchemeList 获取抽样方案列表
+func (s *SamplingService) GetSamplingSchemeList(page, pageSize int, productID, qualityStandardID uint, severity string, isActive *bool) ([]SamplingSchemeResponse, int64, error) {
+	query := s.db.Model(&models.SamplingScheme{})
+	if productID > 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	if qualityStandardID > 0 {
+		query = query.Where("quality_standard_id = ?", qualityStandardID)
+	}
+	if severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+	if isActive != nil {
+		query = query.Where("is_active = ?", *isActive)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取抽样方案总数失败: %v", err)
+	}
+
+	var schemes []models.SamplingScheme
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Product").Preload("QualityStandard").Order("created_at DESC").
+		Offset(offset).Limit(pageSize).Find(&schemes).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取抽样方案列表失败: %v", err)
+	}
+
+	responses := make([]SamplingSchemeResponse, 0, len(schemes))
+	for i := range schemes {
+		responses = append(responses, *samplingSchemeToResponse(&schemes[i]))
+	}
+	return responses, total, nil
+}
+
+// UpdateSamplingScheme 更新抽样方案，严格度和转移状态只能由检验批判定或手动转移改变，
+// 已有检验批的方案不能修改产品，修改AQL、检验水平或判定依据后按新方案从正常检验重新开始转移
+func (s *SamplingService) UpdateSamplingScheme(id uint, req *SamplingSchemeRequest) (*SamplingSchemeResponse, error) {
+	if err := s.validateSamplingScheme(req); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定抽样方案，避免与检验批判定并发更新转移状态
+		var scheme models.SamplingScheme
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&scheme, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("抽样方案不存在")
+			}
+			return fmt.Errorf("获取抽样方案失败: %v", err)
+		}
+
+		if req.ProductID != scheme.ProductID {
+			var count int64
+			if err := tx.Model(&models.InspectionLot{}).Where("sampling_scheme_id = ?", id).Count(&count).Error; err != nil {
+				return fmt.Errorf("检查检验批失败: %v", err)
+			}
+			if count > 0 {
+				return errors.New("该抽样方案存在检验批，不能修改产品")
+			}
+		}
+
+		planChanged := req.AQL != scheme.AQL || req.InspectionLevel != scheme.InspectionLevel || req.Basis != scheme.Basis
+
+		scheme.Name = req.Name
+		scheme.ProductID = req.ProductID
+		scheme.QualityStandardID = req.QualityStandardID
+		scheme.Basis = req.Basis
+		scheme.AQL = req.AQL
+		scheme.InspectionLevel = req.InspectionLevel
+		scheme.AllowReduced = req.AllowReduced
+		scheme.IsActive = req.IsActive
+		scheme.Remark = req.Remark
+
+		switch {
+		case scheme.Discontinued:
+			// 暂停检验的方案仍需手动恢复为加严检验
+		case planChanged:
+			// 原转移得分和批历史按旧方案累计，不再适用
+			switchSamplingSeverity(&scheme, SamplingSeverityNormal, time.Now())
+		case !scheme.AllowReduced && scheme.Severity == SamplingSeverityReduced:
+			// 撤销放宽检验批准时恢复正常检验
+			switchSamplingSeverity(&scheme, SamplingSeverityNormal, time.Now())
+		}
+
+		if err := tx.Omit("Product", "QualityStandard").Save(&scheme).Error; err != nil {
+			return fmt.Errorf("更新抽样方案失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetSamplingScheme(id)
+}
+
+// DeleteSamplingScheme 删除没有检验批的抽样方案
+func (s *SamplingService) DeleteSamplingScheme(id uint) error {
+	if _, err := s.loadSamplingScheme(s.db, id); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.InspectionLot{}).Where("sampling_scheme_id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("检查检验批失败: %v", err)
+	}
+	if count > 0 {
+		return errors.New("该抽样方案存在检验批，无法删除，可停用")
+	}
+
+	if err := s.db.Delete(&models.SamplingScheme{}, id).Error; err != nil {
+		return fmt.Errorf("删除抽样方案失败: %v", err)
+	}
+	return nil
+}
+
+// SwitchSamplingSeverity 手动转移检验严格度，如生产不稳定时放宽转正常、暂停检验后恢复为加严检验
+func (s *SamplingService) SwitchSamplingSeverity(id uint, req *SwitchSamplingSeverityRequest) (*SamplingSchemeResponse, error) {
+	scheme, err := s.loadSamplingScheme(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Severity == SamplingSeverityReduced && !scheme.AllowReduced {
+		return nil, errors.New("抽样方案未经批准放宽检验")
+	}
+	if scheme.Discontinued && req.Severity != SamplingSeverityTightened {
+		return nil, errors.New("暂停检验的抽样方案只能恢复为加严检验")
+	}
+
+	switchSamplingSeverity(scheme, req.Severity, time.Now())
+	scheme.Discontinued = false
+	if strings.TrimSpace(req.Remark) != "" {
+		scheme.Remark = req.Remark
+	}
+
+	if err := s.db.Omit("Product", "QualityStandard").Save(scheme).Error; err != nil {
+		return nil, fmt.Errorf("转移检验严格度失败: %v", err)
+	}
+
+	return s.GetSamplingScheme(id)
+}
+
+// GetSamplingPlan 按抽样方案和批量查表，返回样本量及接收数、拒收数，不指定严格度时使用方案当前严格度
+func (s *SamplingService) GetSamplingPlan(id uint, lotSize int, severity string) (*SamplingPlanResponse, error) {
+	scheme, err := s.loadSamplingScheme(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if severity == "" {
+		severity = scheme.Severity
+	}
+	return lookupSamplingPlan(lotSize, scheme.InspectionLevel, scheme.AQL, scheme.Basis, severity)
+}
+
+// CreateInspectionLot 创建检验批，按抽样方案当前严格度确定样本量和接收数、拒收数
+func (s *SamplingService) CreateInspectionLot(req *InspectionLotRequest, operatorID uint) (*InspectionLotResponse, error) {
+	scheme, err := s.loadSamplingScheme(s.db, req.SamplingSchemeID)
+	if err != nil {
+		return nil, err
+	}
+	if !scheme.IsActive {
+		return nil, errors.New("抽样方案已停用")
+	}
+	if scheme.Discontinued {
+		return nil, errors.New("抽样方案加严检验累计不接收批数已达上限，已暂停检验，需改进质量后恢复")
+	}
+
+	var order models.ProductionOrder
+	if err := s.db.First(&order, req.ProductionOrderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("生产工单不存在")
+		}
+		return nil, fmt.Errorf("验证生产工单失败: %v", err)
+	}
+	if order.ProductID != scheme.ProductID {
+		return nil, errors.New("生产工单的产品与抽样方案不一致")
+	}
+
+	plan, err := lookupSamplingPlan(req.LotSize, scheme.InspectionLevel, scheme.AQL, scheme.Basis, scheme.Severity)
+	if err != nil {
+		return nil, err
+	}
+
+	lot := &models.InspectionLot{
+		SamplingSchemeID:  scheme.ID,
+		ProductionOrderID: order.ID,
+		LotSize:           req.LotSize,
+		Severity:          plan.Severity,
+		CodeLetter:        plan.CodeLetter,
+		SampleSize:        plan.SampleSize,
+		AcceptNumber:      plan.AcceptNumber,
+		RejectNumber:      plan.RejectNumber,
+		Disposition:       LotDispositionPending,
+		Remark:            req.Remark,
+		CreatedBy:         operatorID,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		lot.LotNo = s.generateLotNo(tx)
+		if err := tx.Create(lot).Error; err != nil {
+			return fmt.Errorf("创建检验批失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetInspectionLot(lot.ID)
+}
+
+// GetInspectionLot 获取检验批详情，待判定的检验批实时统计已检样本和不合格数
+func (s *SamplingService) GetInspectionLot(id uint) (*InspectionLotResponse, error) {
+	lot, err := s.loadInspectionLot(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if lot.Disposition == LotDispositionPending {
+		inspected, nonconforming, err := countLotResults(s.db, lot, &lot.SamplingScheme)
+		if err != nil {
+			return nil, err
+		}
+		lot.InspectedCount = inspected
+		lot.NonconformingCount = nonconforming
+	}
+	return inspectionLotToResponse(lot), nil
+}
+
+// GetInspectionLotList 获取检验批列表
+func (s *SamplingService) GetInspectionLotList(page, pageSize int, samplingSchemeID, productionOrderID uint, disposition, keyword string) ([]InspectionLotResponse, int64, error) {
+	query := s.db.Model(&models.InspectionLot{})
+	if samplingSchemeID > 0 {
+		query = query.Where("sampling_scheme_id = ?", samplingSchemeID)
+	}
+	if productionOrderID > 0 {
+		query = query.Where("production_order_id = ?", productionOrderID)
+	}
+	if disposition != "" {
+		query = query.Where("disposition = ?", disposition)
+	}
+	if keyword != "" {
+		query = query.Where("lot_no LIKE ?", "%"+keyword+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取检验批总数失败: %v", err)
+	}
+
+	var lots []models.InspectionLot
+	offset := (page - 1) * pageSize
+	if err := query.Preload("SamplingScheme").Preload("ProductionOrder.Product").Preload("Decider").Preload("Creator").
+		Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&lots).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取检验批列表失败: %v", err)
+	}
+
+	responses := make([]InspectionLotResponse, 0, len(lots))
+	for i := range lots {
+		responses = append(responses, *inspectionLotToResponse(&lots[i]))
+	}
+	return responses, total, nil
+}
+
+// DecideInspectionLot 按已记录的样本检测结果判定检验批，并按转移规则更新抽样方案的检验严格度，不接收时创建不合格品报告
+func (s *SamplingService) DecideInspectionLot(id uint, operatorID uint) (*InspectionLotResponse, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 依次锁定检验批和抽样方案，避免重复判定或并发判定同一方案的检验批时覆盖转移状态
+		var lot models.InspectionLot
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&lot, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("检验批不存在")
+			}
+			return fmt.Errorf("获取检验批失败: %v", err)
+		}
+		if lot.Disposition != LotDispositionPending {
+			return errors.New("检验批已判定")
+		}
+
+		var scheme models.SamplingScheme
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&scheme, lot.SamplingSchemeID).Error; err != nil {
+			return fmt.Errorf("获取抽样方案失败: %v", err)
+		}
+
+		inspected, nonconforming, err := countLotResults(tx, &lot, &scheme)
+		if err != nil {
+			return err
+		}
+
+		// 不合格数达到拒收数即可判定不接收，否则需检完全部样本
+		disposition := LotDispositionAccepted
+		if nonconforming >= lot.RejectNumber {
+			disposition = LotDispositionRejected
+		} else if inspected < lot.SampleSize {
+			return fmt.Errorf("样本尚未检验完成，已检 %d / 应检 %d", inspected, lot.SampleSize)
+		}
+
+		now := time.Now()
+		lot.InspectedCount = inspected
+		lot.NonconformingCount = nonconforming
+		lot.Disposition = disposition
+		lot.DecidedBy = &operatorID
+		lot.DecidedAt = &now
+
+		if lot.Severity == scheme.Severity && !scheme.Discontinued {
+			if err := s.applySwitchingRules(tx, &scheme, &lot, now); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.SamplingScheme{}).Where("id = ?", scheme.ID).Updates(map[string]interface{}{
+				"severity":           scheme.Severity,
+				"switching_score":    scheme.SwitchingScore,
+				"tightened_rejected": scheme.TightenedRejected,
+				"discontinued":       scheme.Discontinued,
+				"switched_at":        scheme.SwitchedAt,
+			}).Error; err != nil {
+				return fmt.Errorf("更新抽样方案失败: %v", err)
+			}
+		}
+		lot.SeverityAfter = scheme.Severity
+
+		if err := tx.Model(&models.InspectionLot{}).Where("id = ?", lot.ID).Updates(map[string]interface{}{
+			"inspected_count":     lot.InspectedCount,
+			"nonconforming_count": lot.NonconformingCount,
+			"disposition":         lot.Disposition,
+			"severity_after":      lot.SeverityAfter,
+			"decided_by":          lot.DecidedBy,
+			"decided_at":          lot.DecidedAt,
+		}).Error; err != nil {
+			return fmt.Errorf("更新检验批失败: %v", err)
+		}
+		return raiseLotNcr(tx, &lot, operatorID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetInspectionLot(id)
+}
+
+// DeleteInspectionLot 删除待判定的检验批，其样本检测单保留并解除关联
+func (s *SamplingService) DeleteInspectionLot(id uint) error {
+	lot, err := s.loadInspectionLot(s.db, id)
+	if err != nil {
+		return err
+	}
+	if lot.Disposition != LotDispositionPending {
+		return errors.New("检验批已判定，无法删除")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.InspectionSheet{}).Where("inspection_lot_id = ?", id).
+			Update("inspection_lot_id", nil).Error; err != nil {
+			return fmt.Errorf("解除样本检测单关联失败: %v", err)
+		}
+		if err := tx.Delete(&models.InspectionLot{}, id).Error; err != nil {
+			return fmt.Errorf("删除检验批失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// 辅助函数：按转移规则更新抽样方案的严格度和转移状态
+func (s *SamplingService) applySwitchingRules(tx *gorm.DB, scheme *models.SamplingScheme, lot *models.InspectionLot, now time.Time) error {
+	var recent []string
+	switch scheme.Severity {
+	case SamplingSeverityNormal, SamplingSeverityTightened:
+		window := samplingTightenWindow
+		if scheme.Severity == SamplingSeverityTightened {
+			window = samplingRestoreAccepts
+		}
+		var err error
+		if recent, err = s.recentLotDispositions(tx, scheme, lot, window); err != nil {
+			return err
+		}
+	}
+	switchSamplingState(scheme, lot, recent, now)
+	return nil
+}
+
+// 辅助函数：按本批结果和当前严格度下最近判定的检验批结果（含本批，按判定时间倒序）转移严格度并更新转移状态
+func switchSamplingState(scheme *models.SamplingScheme, lot *models.InspectionLot, recent []string, now time.Time) {
+	accepted := lot.Disposition == LotDispositionAccepted
+
+	switch scheme.Severity {
+	case SamplingSeverityNormal:
+		// 连续5批或少于5批中有2批不接收，转加严检验
+		rejected := 0
+		for _, disposition := range recent {
+			if disposition == LotDispositionRejected {
+				rejected++
+			}
+		}
+		if rejected >= samplingTightenRejects {
+			switchSamplingSeverity(scheme, SamplingSeverityTightened, now)
+			return
+		}
+
+		// 更新转移得分：接收数不小于2时，AQL加严一档仍可接收加3分；接收数为0或1时，接收加2分；否则清零
+		switch {
+		case !accepted:
+			scheme.SwitchingScore = 0
+		case lot.AcceptNumber >= 2:
+			if samplingAcceptedAtTighterAQL(lot, scheme) {
+				scheme.SwitchingScore += 3
+			} else {
+				scheme.SwitchingScore = 0
+			}
+		default:
+			scheme.SwitchingScore += 2
+		}
+		if scheme.SwitchingScore >= samplingReduceScore && scheme.AllowReduced {
+			switchSamplingSeverity(scheme, SamplingSeverityReduced, now)
+		}
+
+	case SamplingSeverityTightened:
+		if !accepted {
+			scheme.TightenedRejected++
+			if scheme.TightenedRejected >= samplingDiscontinueRejects {
+				scheme.Discontinued = true
+			}
+			return
+		}
+
+		// 连续5批接收，恢复正常检验
+		if len(recent) < samplingRestoreAccepts {
+			return
+		}
+		for _, disposition := range recent {
+			if disposition != LotDispositionAccepted {
+				return
+			}
+		}
+		switchSamplingSeverity(scheme, SamplingSeverityNormal, now)
+
+	case SamplingSeverityReduced:
+		// 放宽检验出现不接收批，恢复正常检验
+		if !accepted {
+			switchSamplingSeverity(scheme, SamplingSeverityNormal, now)
+		}
+	}
+}
+
+// 辅助函数：获取当前严格度下最近判定的检验批结果（含本批），按判定时间倒序
+func (s *SamplingService) recentLotDispositions(tx *gorm.DB, scheme *models.SamplingScheme, lot *models.InspectionLot, limit int) ([]string, error) {
+	query := tx.Model(&models.InspectionLot{}).
+		Where("sampling_scheme_id = ? AND severity = ? AND disposition <> ? AND id <> ?", scheme.ID, scheme.Severity, LotDispositionPending, lot.ID)
+	if scheme.SwitchedAt != nil {
+		query = query.Where("decided_at >= ?", *scheme.SwitchedAt)
+	}
+
+	var dispositions []string
+	if err := query.Order("decided_at DESC, id DESC").Limit(limit-1).Pluck("disposition", &dispositions).Error; err != nil {
+		return nil, fmt.Errorf("获取检验批历史失败: %v", err)
+	}
+	return append([]string{lot.Disposition}, dispositions...), nil
+}
+
+// 辅助函数：判断检验批在AQL加严一档时是否仍可接收
+func samplingAcceptedAtTighterAQL(lot *models.InspectionLot, scheme *models.SamplingScheme) bool {
+	index := samplingAQLIndex(scheme.AQL)
+	if index <= 0 {
+		return false
+	}
+	plan, err := lookupSamplingPlan(lot.LotSize, scheme.InspectionLevel, samplingAQLs[index-1], scheme.Basis, SamplingSeverityNormal)
+	if err != nil {
+		return false
+	}
+	return lot.NonconformingCount <= plan.AcceptNumber
+}
+
+// 辅助函数：转移检验严格度并重置转移状态
+func switchSamplingSeverity(scheme *models.SamplingScheme, severity string, now time.Time) {
+	scheme.Severity = severity
+	scheme.SwitchingScore = 0
+	scheme.TightenedRejected = 0
+	scheme.SwitchedAt = &now
+}
+
+// 辅助函数：统计检验批的已检样本数和不合格数
+// 每张检测单为一个样本单位；按不合格品数判定时统计不合格的样本，按不合格数判定时统计缺陷数量，
+// 缺陷数型特性取缺陷数，其他不合格特性按记录的缺陷数量计且至少计1个
+func countLotResults(db *gorm.DB, lot *models.InspectionLot, scheme *models.SamplingScheme) (int, int, error) {
+	var sheets []models.InspectionSheet
+	if err := db.Preload("Lines.QualityStandard").Preload("Lines.Defects").
+		Where("inspection_lot_id = ?", lot.ID).Find(&sheets).Error; err != nil {
+		return 0, 0, fmt.Errorf("获取样本检测单失败: %v", err)
+	}
+
+	inspected, nonconforming := 0, 0
+	for _, sheet := range sheets {
+		var lines []models.QualityInspection
+		for _, line := range sheet.Lines {
+			if scheme.QualityStandardID == nil || line.QualityStandardID == *scheme.QualityStandardID {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		inspected++
+
+		if scheme.Basis != "nonconformities" {
+			for _, line := range lines {
+				if line.Result == "fail" {
+					nonconforming++
+					break
+				}
+			}
+			continue
+		}
+
+		for _, line := range lines {
+			if line.QualityStandard.CharacteristicType == "defect_count" {
+				nonconforming += int(line.ActualValue)
+				continue
+			}
+			count := 0
+			for _, defect := range line.Defects {
+				count += defect.Quantity
+			}
+			if count == 0 && line.Result == "fail" {
+				count = 1
+			}
+			nonconforming += count
+		}
+	}
+	return inspected, nonconforming, nil
+}
+
+// 辅助函数：检查检测单所属检验批是否仍可修改样本记录
+func checkSheetLotEditable(db *gorm.DB, sheetID uint) error {
+	var sheet models.InspectionSheet
+	if err := db.Select("id", "inspection_lot_id").First(&sheet, sheetID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("获取检测单失败: %v", err)
+	}
+	if sheet.InspectionLotID == nil {
+		return nil
+	}
+	return checkLotPending(db, *sheet.InspectionLotID)
+}
+
+// 辅助函数：检查检验批是否待判定
+func checkLotPending(db *gorm.DB, lotID uint) error {
+	var lot models.InspectionLot
+	if err := db.Clauses(clause.Locking{Strength: "SHARE"}).Select("id", "disposition").First(&lot, lotID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("获取检验批失败: %v", err)
+	}
+	if lot.Disposition != LotDispositionPending {
+		return errors.New("检验批已判定，不能修改样本记录")
+	}
+	return nil
+}
+
+// 辅助函数：按批量、检验水平、AQL和严格度查表确定一次抽样方案
+func lookupSamplingPlan(lotSize int, level string, aql float64, basis, severity string) (*SamplingPlanResponse, error) {
+	if lotSize <= 0 {
+		return nil, errors.New("批量必须大于0")
+	}
+	letters, ok := samplingCodeLetterTable[level]
+	if !ok {
+		return nil, errors.New("无效的检验水平")
+	}
+	aqlIndex := samplingAQLIndex(aql)
+	if aqlIndex < 0 {
+		return nil, errors.New("AQL必须是优先值，如 0.65、1.0、1.5、2.5、4.0、6.5")
+	}
+
+	rangeIndex := len(samplingLotSizeLimits)
+	for i, limit := range samplingLotSizeLimits {
+		if lotSize <= limit {
+			rangeIndex = i
+			break
+		}
+	}
+	codeLetter := letters[rangeIndex]
+	letterIndex := strings.IndexByte(samplingCodeLetters, codeLetter)
+
+	var planIndex, ac int
+	var err error
+	switch severity {
+	case SamplingSeverityNormal:
+		planIndex, ac, err = resolveSamplingDiagonal(letterIndex, aqlIndex, samplingNormalDiagonal, 14, 15)
+	case SamplingSeverityTightened:
+		planIndex, ac, err = resolveSamplingDiagonal(letterIndex, aqlIndex, samplingTightenedDiagonal, 15, 16)
+	case SamplingSeverityReduced:
+		// 放宽检验的样本量约为正常检验的0.4倍，即按小两档字码使用正常检验主表，最大样本量为800
+		planIndex, ac, err = resolveSamplingDiagonal(int(math.Max(float64(letterIndex-2), 0)), aqlIndex, samplingNormalDiagonal, 14, 13)
+	default:
+		return nil, errors.New("无效的检验严格度")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &SamplingPlanResponse{
+		LotSize:         lotSize,
+		InspectionLevel: level,
+		AQL:             aql,
+		Basis:           basis,
+		Severity:        severity,
+		CodeLetter:      string(codeLetter),
+		SampleSize:      samplingSampleSizes[planIndex],
+		AcceptNumber:    ac,
+		RejectNumber:    ac + 1,
+	}
+	if plan.SampleSize >= lotSize {
+		plan.SampleSize = lotSize
+		plan.FullInspection = true
+	}
+	return plan, nil
+}
+
+// 辅助函数：沿主抽样表箭头查找方案，返回方案所在字码序号和接收数；首行向上箭头和末行向下箭头按反方向查找
+func resolveSamplingDiagonal(letterIndex, aqlIndex int, diagonal []int, offset, maxLetter int) (int, int, error) {
+	i := letterIndex
+	for step := 0; step <= len(samplingCodeLetters)*2; step++ {
+		ac := samplingArrowUp
+		k := i + aqlIndex - offset
+		if k < 0 {
+			ac = samplingArrowDown
+		} else if k < len(diagonal) {
+			ac = diagonal[k]
+		}
+
+		switch {
+		case ac >= 0:
+			return i, ac, nil
+		case ac == samplingArrowDown && i < maxLetter, ac == samplingArrowUp && i == 0:
+			i++
+		default:
+			i--
+		}
+	}
+	return 0, 0, errors.New("无适用的抽样方案")
+}
+
+// 辅助函数：获取AQL在优先值中的序号，不是优先值时返回-1
+func samplingAQLIndex(aql float64) int {
+	for i, value := range samplingAQLs {
+		if math.Abs(aql-value) < 1e-9 {
+			return i
+		}
+	}
+	return -1
+}
+
+// 辅助函数：规范化并验证抽样方案
+func (s *SamplingService) validateSamplingScheme(req *SamplingSchemeRequest) error {
+	if req.Basis == "" {
+		req.Basis = "nonconforming"
+	}
+	if req.Basis != "nonconforming" && req.Basis != "nonconformities" {
+		return errors.New("判定依据必须是 nonconforming 或 nonconformities")
+	}
+	if req.InspectionLevel == "" {
+		req.InspectionLevel = "II"
+	}
+	if _, ok := samplingCodeLetterTable[req.InspectionLevel]; !ok {
+		return errors.New("检验水平必须是 S-1、S-2、S-3、S-4、I、II 或 III")
+	}
+	if samplingAQLIndex(req.AQL) < 0 {
+		return errors.New("AQL必须是优先值，如 0.65、1.0、1.5、2.5、4.0、6.5")
+	}
+	if req.AQL > 10 && req.Basis != "nonconformities" {
+		return errors.New("AQL大于10时仅适用于每百单位不合格数")
+	}
+
+	var product models.Product
+	if err := s.db.First(&product, req.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("产品不存在")
+		}
+		return fmt.Errorf("验证产品失败: %v", err)
+	}
+
+	if req.QualityStandardID != nil {
+		var standard models.QualityStandard
+		if err := s.db.First(&standard, *req.QualityStandardID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("质量标准不存在")
+			}
+			return fmt.Errorf("验证质量标准失败: %v", err)
+		}
+		if standard.ProductID != req.ProductID {
+			return errors.New("质量标准不属于该产品")
+		}
+	}
+	return nil
+}
+
+// 辅助函数：加载抽样方案
+func (s *SamplingService) loadSamplingScheme(db *gorm.DB, id uint) (*models.SamplingScheme, error) {
+	var scheme models.SamplingScheme
+	if err := db.Preload("Product").Preload("QualityStandard").First(&scheme, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("抽样方案不存在")
+		}
+		return nil, fmt.Errorf("获取抽样方案失败: %v", err)
+	}
+	return &scheme, nil
+}
+
+// 辅助函数：加载检验批
+func (s *SamplingService) loadInspectionLot(db *gorm.DB, id uint) (*models.InspectionLot, error) {
+	var lot models.InspectionLot
+	if err := db.Preload("SamplingScheme").Preload("ProductionOrder.Product").Preload("Decider").Preload("Creator").
+		First(&lot, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("检验批不存在")
+		}
+		return nil, fmt.Errorf("获取检验批失败: %v", err)
+	}
+	return &lot, nil
+}
+
+// 辅助函数：生成检验批号
+func (s *SamplingService) generateLotNo(tx *gorm.DB) string {
+	prefix := fmt.Sprintf("QL%s", time.Now().Format("20060102"))
+
+	var count int64
+	tx.Unscoped().Model(&models.InspectionLot{}).
+		Where("lot_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：将抽样方案转换为响应结构体
+func samplingSchemeToResponse(scheme *models.SamplingScheme) *SamplingSchemeResponse {
+	resp := &SamplingSchemeResponse{
+		ID:                scheme.ID,
+		Name:              scheme.Name,
+		ProductID:         scheme.ProductID,
+		ProductCode:       scheme.Product.Code,
+		ProductName:       scheme.Product.Name,
+		QualityStandardID: scheme.QualityStandardID,
+		Basis:             scheme.Basis,
+		AQL:               scheme.AQL,
+		InspectionLevel:   scheme.InspectionLevel,
+		Severity:          scheme.Severity,
+		SwitchingScore:    scheme.SwitchingScore,
+		AllowReduced:      scheme.AllowReduced,
+		TightenedRejected: scheme.TightenedRejected,
+		Discontinued:      scheme.Discontinued,
+		SwitchedAt:        scheme.SwitchedAt,
+		IsActive:          scheme.IsActive,
+		Remark:            scheme.Remark,
+		CreatedAt:         scheme.CreatedAt,
+		UpdatedAt:         scheme.UpdatedAt,
+	}
+	if scheme.QualityStandard != nil {
+		resp.QualityStandardName = scheme.QualityStandard.Name
+	}
+	return resp
+}
+
+// 辅助函数：将检验批转换为响应结构体
+func inspectionLotToResponse(lot *models.InspectionLot) *InspectionLotResponse {
+	resp := &InspectionLotResponse{
+		ID:                 lot.ID,
+		LotNo:              lot.LotNo,
+		SamplingSchemeID:   lot.SamplingSchemeID,
+		SamplingSchemeName: lot.SamplingScheme.Name,
+		Basis:              lot.SamplingScheme.Basis,
+		AQL:                lot.SamplingScheme.AQL,
+		InspectionLevel:    lot.SamplingScheme.InspectionLevel,
+		ProductionOrderID:  lot.ProductionOrderID,
+		ProductionOrderNo:  lot.ProductionOrder.OrderNo,
+		ProductID:          lot.ProductionOrder.ProductID,
+		ProductCode:        lot.ProductionOrder.Product.Code,
+		ProductName:        lot.ProductionOrder.Product.Name,
+		LotSize:            lot.LotSize,
+		Severity:           lot.Severity,
+		CodeLetter:         lot.CodeLetter,
+		SampleSize:         lot.SampleSize,
+		AcceptNumber:       lot.AcceptNumber,
+		RejectNumber:       lot.RejectNumber,
+		InspectedCount:     lot.InspectedCount,
+		NonconformingCount: lot.NonconformingCount,
+		Disposition:        lot.Disposition,
+		SeverityAfter:      lot.SeverityAfter,
+		DecidedBy:          lot.DecidedBy,
+		DecidedAt:          lot.DecidedAt,
+		Remark:             lot.Remark,
+		CreatedBy:          lot.CreatedBy,
+		CreatorName:        lot.Creator.Username,
+		CreatedAt:          lot.CreatedAt,
+		UpdatedAt:          lot.UpdatedAt,
+	}
+	if lot.Decider != nil {
+		resp.DeciderName = lot.Decider.Username
+	}
+	return resp
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"mes-system/internal/models"
+)
+
+// 期望值取自 GB/T 2828.1-2012（ISO 2859-1）表1样本量字码和表2-A、2-B、2-C一次抽样方案
+func TestLookupSamplingPlan(t *testing.T) {
+	tests := []struct {
+		name       string
+		lotSize    int
+		level      string
+		aql        float64
+		severity   string
+		codeLetter string
+		sampleSize int
+		accept     int
+		full       bool
+	}{
+		{"正常检验J字码", 1000, "II", 1.0, SamplingSeverityNormal, "J", 80, 2, false},
+		{"正常检验J字码AQL0.65", 1000, "II", 0.65, SamplingSeverityNormal, "J", 80, 1, false},
+		{"正常检验J字码AQL6.5", 1000, "II", 6.5, SamplingSeverityNormal, "J", 80, 10, false},
+		{"正常检验J字码AQL15", 1000, "II", 15, SamplingSeverityNormal, "J", 80, 21, false},
+		{"批量上限500为H字码", 500, "II", 1.0, SamplingSeverityNormal, "H", 50, 1, false},
+		{"批量501为J字码", 501, "II", 1.0, SamplingSeverityNormal, "J", 80, 2, false},
+		{"正常检验K字码", 2000, "II", 1.0, SamplingSeverityNormal, "K", 125, 3, false},
+		{"检验水平I", 1000, "I", 2.5, SamplingSeverityNormal, "G", 32, 2, false},
+		{"特殊检验水平S-1", 1000, "S-1", 2.5, SamplingSeverityNormal, "C", 5, 0, false},
+		{"检验水平III大批量", 600000, "III", 0.65, SamplingSeverityNormal, "R", 2000, 21, false},
+		{"加严检验J字码", 1000, "II", 1.0, SamplingSeverityTightened, "J", 80, 1, false},
+		{"加严检验J字码AQL6.5", 1000, "II", 6.5, SamplingSeverityTightened, "J", 80, 8, false},
+		{"加严检验K字码", 2000, "II", 1.0, SamplingSeverityTightened, "K", 125, 2, false},
+		{"放宽检验K字码", 2000, "II", 1.0, SamplingSeverityReduced, "K", 50, 1, false},
+		{"放宽检验R字码", 600000, "III", 0.65, SamplingSeverityReduced, "R", 800, 10, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := lookupSamplingPlan(tt.lotSize, tt.level, tt.aql, "nonconforming", tt.severity)
+			if err != nil {
+				t.Fatalf("查表失败: %v", err)
+			}
+			if plan.CodeLetter != tt.codeLetter {
+				t.Errorf("样本量字码 = %s，期望 %s", plan.CodeLetter, tt.codeLetter)
+			}
+			if plan.SampleSize != tt.sampleSize || plan.AcceptNumber != tt.accept || plan.RejectNumber != tt.accept+1 {
+				t.Errorf("n=%d Ac=%d Re=%d，期望 n=%d Ac=%d Re=%d",
+					plan.SampleSize, plan.AcceptNumber, plan.RejectNumber, tt.sampleSize, tt.accept, tt.accept+1)
+			}
+			if plan.FullInspection != tt.full {
+				t.Errorf("全检 = %v，期望 %v", plan.FullInspection, tt.full)
+			}
+		})
+	}
+}
+
+// 箭头处按箭头方向使用第一个抽样方案，样本量取该方案的样本量
+func TestLookupSamplingPlanArrows(t *testing.T) {
+	tests := []struct {
+		name       string
+		lotSize    int
+		aql        float64
+		sampleSize int
+		accept     int
+		full       bool
+	}{
+		{"D字码向下箭头", 50, 1.0, 13, 0, false},
+		{"J字码向上箭头", 1000, 0.25, 50, 0, false},
+		{"J字码向下箭头", 1000, 0.40, 125, 1, false},
+		{"样本量不小于批量时全检", 10, 1.0, 10, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := lookupSamplingPlan(tt.lotSize, "II", tt.aql, "nonconforming", SamplingSeverityNormal)
+			if err != nil {
+				t.Fatalf("查表失败: %v", err)
+			}
+			if plan.SampleSize != tt.sampleSize || plan.AcceptNumber != tt.accept || plan.RejectNumber != tt.accept+1 {
+				t.Errorf("n=%d Ac=%d Re=%d，期望 n=%d Ac=%d Re=%d",
+					plan.SampleSize, plan.AcceptNumber, plan.RejectNumber, tt.sampleSize, tt.accept, tt.accept+1)
+			}
+			if plan.FullInspection != tt.full {
+				t.Errorf("全检 = %v，期望 %v", plan.FullInspection, tt.full)
+			}
+		})
+	}
+}
+
+func TestLookupSamplingPlanInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		lotSize  int
+		level    string
+		aql      float64
+		severity string
+	}{
+		{"批量为0", 0, "II", 1.0, SamplingSeverityNormal},
+		{"无效检验水平", 1000, "IV", 1.0, SamplingSeverityNormal},
+		{"非优先AQL", 1000, "II", 0.7, SamplingSeverityNormal},
+		{"无效严格度", 1000, "II", 1.0, "skip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := lookupSamplingPlan(tt.lotSize, tt.level, tt.aql, "nonconforming", tt.severity); err == nil {
+				t.Error("期望返回错误")
+			}
+		})
+	}
+}
+
+// 转移规则按 GB/T 2828.1-2012 第9.3条：正常到加严、加严到正常、正常到放宽（转移得分）、放宽到正常和暂停检验
+func TestSwitchSamplingState(t *testing.T) {
+	accepted, rejected := LotDispositionAccepted, LotDispositionRejected
+	tests := []struct {
+		name         string
+		scheme       models.SamplingScheme
+		lot          models.InspectionLot
+		recent       []string
+		severity     string
+		score        int
+		tightenedRej int
+		discontinued bool
+		switched     bool
+	}{
+		{
+			name:     "正常检验5批中2批不接收转加严",
+			scheme:   models.SamplingScheme{Severity: SamplingSeverityNormal, SwitchingScore: 12},
+			lot:      models.InspectionLot{Disposition: rejected, AcceptNumber: 2},
+			recent:   []string{rejected, accepted, accepted, rejected},
+			severity: SamplingSeverityTightened,
+			switched: true,
+		},
+		{
+			name:     "正常检验1批不接收转移得分清零",
+			scheme:   models.SamplingScheme{Severity: SamplingSeverityNormal, SwitchingScore: 12},
+			lot:      models.InspectionLot{Disposition: rejected, AcceptNumber: 2},
+			recent:   []string{rejected, accepted, accepted, accepted, accepted},
+			severity: SamplingSeverityNormal,
+		},
+		{
+			name:     "接收数为0或1时接收加2分",
+			scheme:   models.SamplingScheme{Severity: SamplingSeverityNormal, SwitchingScore: 10},
+			lot:      models.InspectionLot{Disposition: accepted, AcceptNumber: 1},
+			recent:   []string{accepted},
+			severity: SamplingSeverityNormal,
+			score:    12,
+		},
+		{
+			name:   "接收数不小于2且AQL加严一档仍可接收加3分",
+			scheme: models.SamplingScheme{Severity: SamplingSeverityNormal, SwitchingScore: 10, AQL: 1.0, InspectionLevel: "II"},
+			// 批量1000、AQL 0.65时 Ac=1
+			lot:      models.InspectionLot{Disposition: accepted, LotSize: 1000, AcceptNumber: 2, NonconformingCount: 1},
+			recent:   []string{accepted},
+			severity: SamplingSeverityNormal,
+			score:    13,
+		},
+		{
+			name:     "接收数不小于2但AQL加严一档不可接收时清零",
+			scheme:   models.SamplingScheme{Severity: SamplingSeverityNormal, SwitchingScore: 10, AQL: 1.0, InspectionLevel: "II"},
+			lot:      models.InspectionLot{Disposition: accepted, LotSize: 1000, AcceptNumber: 2, NonconformingCount: 2},
+			recent:   []string{accepted},
+			severity: SamplingSeverityNormal,
+		},
+		{
+			name:     "转移得分达到30且批准放宽时转放宽",
+			scheme:   models.SamplingScheme{Severity: SamplingSeverityNormal, SwitchingScore: 28, AllowReduced: true},
+			lot:      models.InspectionLot{Disposition: accepted, AcceptNumber: 0},
+			recent:   []string{accepted},
+			severity: SamplingSeverityReduced,
+			switched: true,
+		},
+		{
+			name:     "转移得分达到30但未批准放宽时保持正常",
+			scheme:   models.SamplingScheme{Severity: SamplingSeverityNormal, SwitchingScore: 28},
+			lot:      models.InspectionLot{Disposition: accepted, AcceptNumber: 0},
+			recent:   []string{accepted},
+			severity: SamplingSeverityNormal,
+			score:    30,
+		},
+		{
+			name:         "加严检验不接收累计不接收批数",
+			scheme:       models.SamplingScheme{Severity: SamplingSeverityTightened, TightenedRejected: 3},
+			lot:          models.InspectionLot{Disposition: rejected},
+			recent:       []string{rejected},
+			severity:     SamplingSeverityTightened,
+			tightenedRej: 4,
+		},
+		{
+			name:         "加严检验累计5批不接收暂停检验",
+			scheme:       models.SamplingScheme{Severity: SamplingSeverityTightened, TightenedRejected: 4},
+			lot:          models.InspectionLot{Disposition: rejected},
+			recent:       []string{rejected},
+			severity:     SamplingSeverityTightened,
+			tightenedRej: 5,
+			discontinued: true,
+		},
+		{
+			name:     "加严检验连续5批接收恢复正常",
+			scheme:   models.SamplingScheme{Severity: SamplingSeverityTightened, TightenedRejected: 2},
+			lot:      models.InspectionLot{Disposition: accepted},
+			recent:   []string{accepted, accepted, accepted, accepted, accepted},
+			severity: SamplingSeverityNormal,
+			switched: true,
+		},
+		{
+			name:         "加严检验接收不足5批保持加严",
+			scheme:       models.SamplingScheme{Severity: SamplingSeverityTightened, TightenedRejected: 2},
+			lot:          models.InspectionLot{Disposition: accepted},
+			recent:       []string{accepted, accepted, accepted, accepted},
+			severity:     SamplingSeverityTightened,
+			tightenedRej: 2,
+		},
+		{
+			name:     "放宽检验不接收恢复正常",
+			scheme:   models.SamplingScheme{Severity: SamplingSeverityReduced, AllowReduced: true},
+			lot:      models.InspectionLot{Disposition: rejected},
+			severity: SamplingSeverityNormal,
+			switched: true,
+		},
+		{
+			name:     "放宽检验接收保持放宽",
+			scheme:   models.SamplingScheme{Severity: SamplingSeverityReduced, AllowReduced: true},
+			lot:      models.InspectionLot{Disposition: accepted},
+			severity: SamplingSeverityReduced,
+		},
+	}
+
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := tt.scheme
+			switchSamplingState(&scheme, &tt.lot, tt.recent, now)
+			if scheme.Severity != tt.severity {
+				t.Errorf("严格度 = %s，期望 %s", scheme.Severity, tt.severity)
+			}
+			if scheme.SwitchingScore != tt.score {
+				t.Errorf("转移得分 = %d，期望 %d", scheme.SwitchingScore, tt.score)
+			}
+			if scheme.TightenedRejected != tt.tightenedRej {
+				t.Errorf("加严不接收批数 = %d，期望 %d", scheme.TightenedRejected, tt.tightenedRej)
+			}
+			if scheme.Discontinued != tt.discontinued {
+				t.Errorf("暂停检验 = %v，期望 %v", scheme.Discontinued, tt.discontinued)
+			}
+			if switched := scheme.SwitchedAt != nil && scheme.SwitchedAt.Equal(now); switched != tt.switched {
+				t.Errorf("转移严格度 = %v，期望 %v", switched, tt.switched)
+			}
+		})
+	}
+}
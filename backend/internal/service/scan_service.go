@@ -0,0 +1,444 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mes-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ScanPrefixUser 工牌条码前缀，其余前缀与标签打印一致
+const ScanPrefixUser = "U:"
+
+// 扫码对象类型
+const (
+	ScanEntityMaterial        = "material"
+	ScanEntityLot             = "lot"
+	ScanEntityProductionOrder = "production_order"
+	ScanEntityEquipment       = "equipment"
+	ScanEntityUser            = "user"
+)
+
+// scanPrefixes 条码前缀与对象类型的对应关系，按前缀长度从长到短匹配
+var scanPrefixes = []struct {
+	Prefix     string
+	EntityType string
+}{
+	{LabelPrefixProductionOrder, ScanEntityProductionOrder},
+	{LabelPrefixMaterial, ScanEntityMaterial},
+	{LabelPrefixLot, ScanEntityLot},
+	{LabelPrefixEquipment, ScanEntityEquipment},
+	{ScanPrefixUser, ScanEntityUser},
+}
+
+// 扫码后可执行的操作
+const (
+	ScanActionReceive          = "receive"
+	ScanActionIssue            = "issue"
+	ScanActionRequisition      = "requisition"
+	ScanActionReportProduction = "report_production"
+	ScanActionStartMaintenance = "start_maintenance"
+	ScanActionPrintLabel       = "print_label"
+)
+
+// scanActionNames 操作名称
+var scanActionNames = map[string]string{
+	ScanActionReceive:          "收货入库",
+	ScanActionIssue:            "发料",
+	ScanActionRequisition:      "申请领料",
+	ScanActionReportProduction: "报工",
+	ScanActionStartMaintenance: "开始维护",
+	ScanActionPrintLabel:       "打印标签",
+}
+
+// scanActionRoles 手持终端上可执行各操作的角色，未列出的操作所有角色均可执行
+var scanActionRoles = map[string][]string{
+	ScanActionReceive:          {"admin", "warehouse"},
+	ScanActionIssue:            {"admin", "warehouse"},
+	ScanActionReportProduction: {"admin", "manager", "user"},
+	ScanActionStartMaintenance: {"admin", "manager", "user"},
+}
+
+// ScanAction 扫码后可执行的操作
+type ScanAction struct {
+	Action  string                 `json:"action"`  // 操作代码
+	Name    string                 `json:"name"`    // 操作名称
+	Method  string                 `json:"method"`  // 请求方法
+	Path    string                 `json:"path"`    // 接口路径
+	Payload map[string]interface{} `json:"payload"` // 预填的请求参数
+}
+
+// ScanCandidate 条码匹配到多个对象时的候选项
+type ScanCandidate struct {
+	EntityType string `json:"entity_type"` // 对象类型
+	EntityID   uint   `json:"entity_id"`   // 对象ID
+	Code       string `json:"code"`        // 带前缀的条码，可直接再次扫描解析
+	Label      string `json:"label"`       // 显示名称
+}
+
+// ScanResponse 扫码解析结果
+type ScanResponse struct {
+	Code       string          `json:"code"`                 // 扫描的条码
+	EntityType string          `json:"entity_type"`          // 对象类型，匹配到多个对象时为空
+	EntityID   uint            `json:"entity_id"`            // 对象ID
+	Entity     interface{}     `json:"entity"`               // 对象详情
+	Actions    []ScanAction    `json:"actions"`              // 当前角色可执行的操作
+	Candidates []ScanCandidate `json:"candidates,omitempty"` // 匹配到多个对象时的候选项
+}
+
+// ScanLotResponse 批次信息
+type ScanLotResponse struct {
+	LotNo         string    `json:"lot_no"`         // 批次号
+	TransactionID uint      `json:"transaction_id"` // 入库交易ID
+	MaterialID    uint      `json:"material_id"`    // 物料ID
+	MaterialCode  string    `json:"material_code"`  // 物料编码
+	MaterialName  string    `json:"material_name"`  // 物料名称
+	Unit          string    `json:"unit"`           // 单位
+	Supplier      string    `json:"supplier"`       // 供应商
+	ReceivedQty   float64   `json:"received_qty"`   // 入库数量
+	Balance       float64   `json:"balance"`        // 批次结存数量
+	ReceivedAt    time.Time `json:"received_at"`    // 入库时间
+}
+
+// ScanUserResponse 工牌对应的用户信息
+type ScanUserResponse struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	RealName string `json:"real_name"`
+	Role     string `json:"role"`
+	BadgeNo  string `json:"badge_no"`
+}
+
+// ScanService 扫码解析服务
+type ScanService struct {
+	db *gorm.DB
+}
+
+// NewScanService 创建扫码解析服务实例
+func NewScanService(db *gorm.DB) *ScanService {
+	return &ScanService{db: db}
+}
+
+// ResolveCode 解析手持终端扫描的条码，返回对应对象及当前角色可执行的操作
+// 带前缀的条码（M:/L:/PO:/E:/U:）直接按类型查找；不带前缀时依次按物料编码、批次号、工单号、设备编码和工牌号查找
+func (s *ScanService) ResolveCode(code, role string) (*ScanResponse, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, errors.New("条码不能为空")
+	}
+
+	for _, prefix := range scanPrefixes {
+		if strings.HasPrefix(code, prefix.Prefix) {
+			value := strings.TrimSpace(strings.TrimPrefix(code, prefix.Prefix))
+			result, err := s.resolveEntity(prefix.EntityType, value, role)
+			if err != nil {
+				return nil, err
+			}
+			if result == nil {
+				return nil, fmt.Errorf("未找到条码 %s 对应的对象", code)
+			}
+			result.Code = code
+			result.Candidates = nil
+			return result, nil
+		}
+	}
+
+	// 不带前缀时在所有类型中查找
+	var matches []*ScanResponse
+	for _, prefix := range scanPrefixes {
+		result, err := s.resolveEntity(prefix.EntityType, code, role)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			matches = append(matches, result)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("未找到条码 %s 对应的对象", code)
+	case 1:
+		matches[0].Code = code
+		matches[0].Candidates = nil
+		return matches[0], nil
+	}
+
+	candidates := make([]ScanCandidate, 0, len(matches))
+	for _, match := range matches {
+		candidates = append(candidates, match.Candidates[0])
+	}
+	return &ScanResponse{
+		Code:       code,
+		Actions:    []ScanAction{},
+		Candidates: candidates,
+	}, nil
+}
+
+// 辅助函数：按对象类型查找，未找到时返回nil
+func (s *ScanService) resolveEntity(entityType, value, role string) (*ScanResponse, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	switch entityType {
+	case ScanEntityMaterial:
+		return s.resolveMaterial(value, role)
+	case ScanEntityLot:
+		return s.resolveLot(value, role)
+	case ScanEntityProductionOrder:
+		return s.resolveProductionOrder(value, role)
+	case ScanEntityEquipment:
+		return s.resolveEquipment(value, role)
+	case ScanEntityUser:
+		return s.resolveUser(value)
+	}
+	return nil, nil
+}
+
+// 辅助函数：按物料编码查找物料
+func (s *ScanService) resolveMaterial(code, role string) (*ScanResponse, error) {
+	var material models.Material
+	err := s.db.Where("code = ?", code).First(&material).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// 盘点期间暂停收发
+	movable := checkMaterialNotFrozen(s.db, material.ID) == nil
+
+	var actions []ScanAction
+	if movable {
+		actions = appendScanAction(actions, role, ScanActionReceive, "POST", "/api/v1/materials/transactions",
+			map[string]interface{}{"material_id": material.ID, "type": "in"})
+		if issuableStock(&material) > 0 {
+			actions = appendScanAction(actions, role, ScanActionIssue, "POST", "/api/v1/materials/transactions",
+				map[string]interface{}{"material_id": material.ID, "type": "out"})
+		}
+	}
+	actions = appendScanAction(actions, role, ScanActionRequisition, "POST", "/api/v1/requisitions",
+		map[string]interface{}{"lines": []map[string]interface{}{{"material_id": material.ID}}})
+	actions = appendScanAction(actions, role, ScanActionPrintLabel, "POST", "/api/v1/labels/print",
+		map[string]interface{}{"entity_type": ScanEntityMaterial, "ids": []uint{material.ID}})
+
+	return &ScanResponse{
+		EntityType: ScanEntityMaterial,
+		EntityID:   material.ID,
+		Entity:     NewMaterialService(s.db).materialToResponse(&material),
+		Actions:    actions,
+		Candidates: []ScanCandidate{{
+			EntityType: ScanEntityMaterial,
+			EntityID:   material.ID,
+			Code:       LabelPrefixMaterial + material.Code,
+			Label:      fmt.Sprintf("物料 %s %s", material.Code, material.Name),
+		}},
+	}, nil
+}
+
+// 辅助函数：按批次号查找入库批次
+func (s *ScanService) resolveLot(lotNo, role string) (*ScanResponse, error) {
+	var transaction models.MaterialTransaction
+	err := s.db.Preload("Material").Where("lot_no = ? AND type = ?", lotNo, "in").
+		Order("id").First(&transaction).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// 批次结存 = 入库类交易 - 出库类交易
+	var balance float64
+	err = s.db.Model(&models.MaterialTransaction{}).
+		Select("COALESCE(SUM(CASE WHEN type IN ? THEN quantity ELSE -quantity END), 0)", inboundTransactionTypes).
+		Where("material_id = ? AND lot_no = ?", transaction.MaterialID, lotNo).
+		Scan(&balance).Error
+	if err != nil {
+		return nil, err
+	}
+	balance = roundQuantity(balance)
+
+	lot := &ScanLotResponse{
+		LotNo:         lotNo,
+		TransactionID: transaction.ID,
+		MaterialID:    transaction.MaterialID,
+		MaterialCode:  transaction.Material.Code,
+		MaterialName:  transaction.Material.Name,
+		Unit:          transaction.Material.Unit,
+		Supplier:      transaction.Supplier,
+		ReceivedQty:   transaction.Quantity,
+		Balance:       balance,
+		ReceivedAt:    transaction.CreatedAt,
+	}
+
+	var actions []ScanAction
+	if balance > 0 && issuableStock(&transaction.Material) > 0 &&
+		checkMaterialNotFrozen(s.db, transaction.MaterialID) == nil {
+		actions = appendScanAction(actions, role, ScanActionIssue, "POST", "/api/v1/materials/transactions",
+			map[string]interface{}{"material_id": transaction.MaterialID, "type": "out", "lot_no": lotNo})
+	}
+	actions = appendScanAction(actions, role, ScanActionPrintLabel, "POST", "/api/v1/labels/print",
+		map[string]interface{}{"entity_type": ScanEntityLot, "ids": []uint{transaction.ID}})
+
+	return &ScanResponse{
+		EntityType: ScanEntityLot,
+		EntityID:   transaction.ID,
+		Entity:     lot,
+		Actions:    actions,
+		Candidates: []ScanCandidate{{
+			EntityType: ScanEntityLot,
+			EntityID:   transaction.ID,
+			Code:       LabelPrefixLot + lotNo,
+			Label:      fmt.Sprintf("批次 %s（%s %s）", lotNo, transaction.Material.Code, transaction.Material.Name),
+		}},
+	}, nil
+}
+
+// 辅助函数：按工单号查找生产工单
+func (s *ScanService) resolveProductionOrder(orderNo, role string) (*ScanResponse, error) {
+	var order models.ProductionOrder
+	err := s.db.Preload("Product").Where("order_no = ?", orderNo).First(&order).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var actions []ScanAction
+	active := order.Status == "pending" || order.Status == "processing"
+	if active {
+		actions = appendScanAction(actions, role, ScanActionReportProduction, "PUT",
+			fmt.Sprintf("/api/v1/production/orders/%d", order.ID),
+			map[string]interface{}{"produced": order.Produced})
+
+		// 已批准或部分发料的领料单可以按单发料
+		var requisitions []models.MaterialRequisition
+		err = s.db.Where("production_order_id = ? AND status IN ?", order.ID, []string{"approved", "partial"}).
+			Order("id").Find(&requisitions).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, requisition := range requisitions {
+			actions = appendScanAction(actions, role, ScanActionIssue, "POST",
+				fmt.Sprintf("/api/v1/requisitions/%d/issue", requisition.ID),
+				map[string]interface{}{"requisition_no": requisition.RequisitionNo})
+		}
+
+		actions = appendScanAction(actions, role, ScanActionRequisition, "POST", "/api/v1/requisitions",
+			map[string]interface{}{"production_order_id": order.ID})
+	}
+	actions = appendScanAction(actions, role, ScanActionPrintLabel, "POST", "/api/v1/labels/print",
+		map[string]interface{}{"entity_type": ScanEntityProductionOrder, "ids": []uint{order.ID}})
+
+	return &ScanResponse{
+		EntityType: ScanEntityProductionOrder,
+		EntityID:   order.ID,
+		Entity:     order,
+		Actions:    actions,
+		Candidates: []ScanCandidate{{
+			EntityType: ScanEntityProductionOrder,
+			EntityID:   order.ID,
+			Code:       LabelPrefixProductionOrder + order.OrderNo,
+			Label:      fmt.Sprintf("生产工单 %s（%s）", order.OrderNo, order.Product.Name),
+		}},
+	}, nil
+}
+
+// 辅助函数：按设备编码查找设备
+func (s *ScanService) resolveEquipment(code, role string) (*ScanResponse, error) {
+	var equipment models.Equipment
+	err := s.db.Where("code = ?", code).First(&equipment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var actions []ScanAction
+	if equipment.Status != "maintenance" {
+		actions = appendScanAction(actions, role, ScanActionStartMaintenance, "POST", "/api/v1/equipment/maintenance",
+			map[string]interface{}{"equipment_id": equipment.ID})
+	}
+	actions = appendScanAction(actions, role, ScanActionPrintLabel, "POST", "/api/v1/labels/print",
+		map[string]interface{}{"entity_type": ScanEntityEquipment, "ids": []uint{equipment.ID}})
+
+	return &ScanResponse{
+		EntityType: ScanEntityEquipment,
+		EntityID:   equipment.ID,
+		Entity:     equipment,
+		Actions:    actions,
+		Candidates: []ScanCandidate{{
+			EntityType: ScanEntityEquipment,
+			EntityID:   equipment.ID,
+			Code:       LabelPrefixEquipment + equipment.Code,
+			Label:      fmt.Sprintf("设备 %s %s", equipment.Code, equipment.Name),
+		}},
+	}, nil
+}
+
+// 辅助函数：按工牌号查找用户，工牌仅用于识别操作人员，没有可执行的操作
+func (s *ScanService) resolveUser(badgeNo string) (*ScanResponse, error) {
+	var user models.User
+	err := s.db.Where("badge_no = ? AND status = ?", badgeNo, 1).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &ScanResponse{
+		EntityType: ScanEntityUser,
+		EntityID:   user.ID,
+		Entity: &ScanUserResponse{
+			ID:       user.ID,
+			Username: user.Username,
+			RealName: user.RealName,
+			Role:     user.Role,
+			BadgeNo:  badgeNo,
+		},
+		Actions: []ScanAction{},
+		Candidates: []ScanCandidate{{
+			EntityType: ScanEntityUser,
+			EntityID:   user.ID,
+			Code:       ScanPrefixUser + badgeNo,
+			Label:      fmt.Sprintf("用户 %s %s", user.Username, user.RealName),
+		}},
+	}, nil
+}
+
+// 辅助函数：当前角色有权限时追加操作
+func appendScanAction(actions []ScanAction, role, action, method, path string, payload map[string]interface{}) []ScanAction {
+	if actions == nil {
+		actions = []ScanAction{}
+	}
+	if roles, ok := scanActionRoles[action]; ok {
+		allowed := false
+		for _, r := range roles {
+			if r == role {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return actions
+		}
+	}
+
+	return append(actions, ScanAction{
+		Action:  action,
+		Name:    scanActionNames[action],
+		Method:  method,
+		Path:    path,
+		Payload: payload,
+	})
+}
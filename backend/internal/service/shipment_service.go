@@ -0,0 +1,911 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"mes-system/internal/models"
+)
+
+// deliveryNoteTexts 发货单打印文字，未配置中文字体时使用英文
+var deliveryNoteTexts = map[bool]map[string]string{
+	true: {
+		"title":       "发货单",
+		"shipment_no": "发货单号",
+		"ship_date":   "发货日期",
+		"customer":    "客户",
+		"sales_order": "销售订单",
+		"customer_po": "客户采购单号",
+		"ship_to":     "收货地址",
+		"carrier":     "承运商",
+		"tracking_no": "运单号",
+		"line_no":     "行号",
+		"product":     "产品",
+		"lot_no":      "批次号",
+		"quantity":    "数量",
+		"unit":        "单位",
+		"serials":     "序列号",
+		"total":       "合计",
+		"remark":      "备注",
+		"shipper":     "发货人",
+		"receiver":    "收货人签收",
+		"date":        "日期",
+	},
+	false: {
+		"title":       "DELIVERY NOTE",
+		"shipment_no": "Shipment No.",
+		"ship_date":   "Ship Date",
+		"customer":    "Customer",
+		"sales_order": "Sales Order",
+		"customer_po": "Customer PO",
+		"ship_to":     "Ship To",
+		"carrier":     "Carrier",
+		"tracking_no": "Tracking No.",
+		"line_no":     "No.",
+		"product":     "Product",
+		"lot_no":      "Lot No.",
+		"quantity":    "Quantity",
+		"unit":        "Unit",
+		"serials":     "Serial No.",
+		"total":       "Total",
+		"remark":      "Remark",
+		"shipper":     "Shipped by",
+		"receiver":    "Received by",
+		"date":        "Date",
+	},
+}
+
+// ShipmentLineRequest 发货单行请求结构体
+type ShipmentLineRequest struct {
+	ProductionOrderID uint     `json:"production_order_id" binding:"required"` // 发货的生产工单，发货数量不能超过其已生产未发货数量
+	Quantity          float64  `json:"quantity" binding:"required,gt=0"`       // 拣货数量
+	LotNo             string   `json:"lot_no"`                                 // 成品批次号，为空且工单只有一个入库批次时取该批次
+	SerialNos         []string `json:"serial_nos"`                             // 序列号，填写时个数须与发货数量一致
+	Remark            string   `json:"remark"`                                 // 备注
+}
+
+// ShipmentRequest 发货单请求结构体
+type ShipmentRequest struct {
+	CustomerID   uint                  `json:"customer_id" binding:"required"`      // 客户ID
+	SalesOrderID *uint                 `json:"sales_order_id"`                      // 销售订单ID，填写时发货行的生产工单须关联该订单
+	ShipDate     *time.Time            `json:"ship_date"`                           // 发货日期，默认为当前时间
+	ShipTo       string                `json:"ship_to"`                             // 收货地址，默认为客户地址
+	Carrier      string                `json:"carrier"`                             // 承运商
+	TrackingNo   string                `json:"tracking_no"`                         // 运单号
+	Remark       string                `json:"remark"`                              // 备注
+	Lines        []ShipmentLineRequest `json:"lines" binding:"required,min=1,dive"` // 发货行
+}
+
+// ShipmentLineResponse 发货单行响应结构体
+type ShipmentLineResponse struct {
+	ID                uint     `json:"id"`
+	LineNo            int      `json:"line_no"`
+	ProductID         uint     `json:"product_id"`
+	ProductCode       string   `json:"product_code"`
+	ProductName       string   `json:"product_name"`
+	Unit              string   `json:"unit"`
+	ProductionOrderID uint     `json:"production_order_id"`
+	ProductionOrderNo string   `json:"production_order_no"`
+	SalesOrderLineID  *uint    `json:"sales_order_line_id"`
+	Quantity          float64  `json:"quantity"`
+	LotNo             string   `json:"lot_no"`
+	SerialNos         []string `json:"serial_nos"`
+	Remark            string   `json:"remark"`
+}
+
+// ShipmentResponse 发货单响应结构体
+type ShipmentResponse struct {
+	ID            uint                   `json:"id"`
+	ShipmentNo    string                 `json:"shipment_no"`
+	CustomerID    uint                   `json:"customer_id"`
+	CustomerCode  string                 `json:"customer_code"`
+	CustomerName  string                 `json:"customer_name"`
+	SalesOrderID  *uint                  `json:"sales_order_id"`
+	SalesOrderNo  string                 `json:"sales_order_no"`
+	CustomerPO    string                 `json:"customer_po"`
+	ShipDate      time.Time              `json:"ship_date"`
+	Status        string                 `json:"status"`
+	ShipTo        string                 `json:"ship_to"`
+	Carrier       string                 `json:"carrier"`
+	TrackingNo    string                 `json:"tracking_no"`
+	TotalQuantity float64                `json:"total_quantity"`
+	Remark        string                 `json:"remark"`
+	CreatedBy     uint                   `json:"created_by"`
+	CreatorName   string                 `json:"creator_name"`
+	ShippedBy     *uint                  `json:"shipped_by"`
+	ShippedAt     *time.Time             `json:"shipped_at"`
+	Lines         []ShipmentLineResponse `json:"lines,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+}
+
+// ShipmentService 发货服务
+type ShipmentService struct {
+	db       *gorm.DB
+	fontData []byte // TrueType字体，为空时发货单以英文打印
+}
+
+// NewShipmentService 创建发货服务实例，fontPath 为打印中文发货单所需的TrueType字体
+func NewShipmentService(db *gorm.DB, fontPath string) *ShipmentService {
+	s := &ShipmentService{db: db}
+
+	if fontPath != "" {
+		data, err := os.ReadFile(fontPath)
+		if err != nil {
+			log.Printf("加载发货单字体失败，发货单将以英文打印: %v", err)
+		} else {
+			s.fontData = data
+		}
+	}
+
+	return s
+}
+
+// CreateShipment 创建发货单（草稿状态），校验拣货数量不超过生产工单的已生产未发货数量
+func (s *ShipmentService) CreateShipment(req *ShipmentRequest, createdBy uint) (*ShipmentResponse, error) {
+	shipment := &models.Shipment{
+		ShipmentNo: s.generateShipmentNo(),
+		Status:     "draft",
+		CreatedBy:  createdBy,
+	}
+	if err := s.applyShipmentRequest(shipment, req); err != nil {
+		return nil, err
+	}
+
+	lines, err := s.buildShipmentLines(req)
+	if err != nil {
+		return nil, err
+	}
+	shipment.Lines = lines
+
+	if err := s.db.Create(shipment).Error; err != nil {
+		return nil, fmt.Errorf("创建发货单失败: %v", err)
+	}
+
+	return s.GetShipment(shipment.ID)
+}
+
+// GetShipment 获取发货单详情
+func (s *ShipmentService) GetShipment(id uint) (*ShipmentResponse, error) {
+	shipment, err := s.loadShipment(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.shipmentToResponse(shipment), nil
+}
+
+// GetShipmentList 获取发货单列表
+func (s *ShipmentService) GetShipmentList(page, pageSize int, status, keyword string, customerID, salesOrderID uint) ([]ShipmentResponse, int64, error) {
+	var shipments []models.Shipment
+	var total int64
+
+	query := s.db.Model(&models.Shipment{})
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if keyword != "" {
+		query = query.Where("shipment_no LIKE ? OR tracking_no LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	if customerID > 0 {
+		query = query.Where("customer_id = ?", customerID)
+	}
+	if salesOrderID > 0 {
+		query = query.Where("sales_order_id = ?", salesOrderID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取发货单总数失败: %v", err)
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Customer").Preload("Creator").Preload("Lines").
+		Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&shipments).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取发货单列表失败: %v", err)
+	}
+
+	responses := make([]ShipmentResponse, 0, len(shipments))
+	for i := range shipments {
+		resp := s.shipmentToResponse(&shipments[i])
+		resp.Lines = nil
+		responses = append(responses, *resp)
+	}
+
+	return responses, total, nil
+}
+
+// UpdateShipment 更新发货单，仅草稿状态可修改，发货行整体替换
+func (s *ShipmentService) UpdateShipment(id uint, req *ShipmentRequest) (*ShipmentResponse, error) {
+	var shipment models.Shipment
+	if err := s.db.First(&shipment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("发货单不存在")
+		}
+		return nil, fmt.Errorf("获取发货单失败: %v", err)
+	}
+
+	if shipment.Status != "draft" {
+		return nil, errors.New("只有草稿状态的发货单可以修改")
+	}
+
+	if err := s.applyShipmentRequest(&shipment, req); err != nil {
+		return nil, err
+	}
+
+	lines, err := s.buildShipmentLines(req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := deleteShipmentLines(tx, shipment.ID); err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].ShipmentID = shipment.ID
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return fmt.Errorf("创建发货行失败: %v", err)
+		}
+		if err := tx.Select("customer_id", "sales_order_id", "ship_date", "ship_to", "carrier", "tracking_no", "remark").
+			Updates(&shipment).Error; err != nil {
+			return fmt.Errorf("更新发货单失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetShipment(shipment.ID)
+}
+
+// DeleteShipment 删除发货单，仅草稿或已取消的发货单可删除
+func (s *ShipmentService) DeleteShipment(id uint) error {
+	var shipment models.Shipment
+	if err := s.db.First(&shipment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("发货单不存在")
+		}
+		return fmt.Errorf("获取发货单失败: %v", err)
+	}
+
+	if shipment.Status != "draft" && shipment.Status != "cancelled" {
+		return errors.New("只有草稿或已取消的发货单可以删除")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := deleteShipmentLines(tx, shipment.ID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&shipment).Error; err != nil {
+			return fmt.Errorf("删除发货单失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// CancelShipment 取消发货单，仅草稿状态可取消
+func (s *ShipmentService) CancelShipment(id uint) (*ShipmentResponse, error) {
+	var shipment models.Shipment
+	if err := s.db.First(&shipment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("发货单不存在")
+		}
+		return nil, fmt.Errorf("获取发货单失败: %v", err)
+	}
+
+	if shipment.Status != "draft" {
+		return nil, errors.New("只有草稿状态的发货单可以取消")
+	}
+
+	if err := s.db.Model(&shipment).Update("status", "cancelled").Error; err != nil {
+		return nil, fmt.Errorf("取消发货单失败: %v", err)
+	}
+
+	return s.GetShipment(shipment.ID)
+}
+
+// ShipShipment 确认发货：重新校验并累计生产工单和销售订单行的已发货数量，登记成品发货出库
+func (s *ShipmentService) ShipShipment(id, operatorID uint) (*ShipmentResponse, error) {
+	tx := s.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 锁定发货单，避免重复发货
+	var shipment models.Shipment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&shipment, id).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("发货单不存在")
+		}
+		return nil, fmt.Errorf("获取发货单失败: %v", err)
+	}
+	if shipment.Status != "draft" {
+		tx.Rollback()
+		return nil, errors.New("只有草稿状态的发货单可以发货")
+	}
+
+	var lines []models.ShipmentLine
+	if err := tx.Preload("Serials").Where("shipment_id = ?", shipment.ID).Order("line_no").Find(&lines).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("获取发货行失败: %v", err)
+	}
+	if len(lines) == 0 {
+		tx.Rollback()
+		return nil, errors.New("发货单没有发货行")
+	}
+
+	if shipment.SalesOrderID != nil {
+		if _, err := checkShipmentSalesOrder(tx, *shipment.SalesOrderID, shipment.CustomerID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	// 按工单ID顺序锁定生产工单，重新校验可发货数量
+	quantities := make(map[uint]float64)
+	for _, line := range lines {
+		quantities[line.ProductionOrderID] = roundQuantity(quantities[line.ProductionOrderID] + line.Quantity)
+	}
+	orderIDs := make([]uint, 0, len(quantities))
+	for orderID := range quantities {
+		orderIDs = append(orderIDs, orderID)
+	}
+	sort.Slice(orderIDs, func(i, j int) bool { return orderIDs[i] < orderIDs[j] })
+
+	orders := make(map[uint]*models.ProductionOrder)
+	for _, orderID := range orderIDs {
+		var order models.ProductionOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("获取生产工单失败: %v", err)
+		}
+		if err := checkShippableQuantity(&order, quantities[orderID]); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		orders[orderID] = &order
+	}
+
+	for _, line := range lines {
+		serialNos := make([]string, 0, len(line.Serials))
+		for _, serial := range line.Serials {
+			serialNos = append(serialNos, serial.SerialNo)
+		}
+		if err := checkSerialsNotShipped(tx, line.ProductID, serialNos); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("第 %d 行%v", line.LineNo, err)
+		}
+		if err := checkProductLotBalance(tx, line.ProductID, line.LotNo, line.Quantity); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("第 %d 行%v", line.LineNo, err)
+		}
+
+		order := orders[line.ProductionOrderID]
+		orderID, lineID := order.ID, line.ID
+		transaction := &models.ProductTransaction{
+			ProductID:         line.ProductID,
+			Type:              "shipment",
+			Quantity:          line.Quantity,
+			ProductionOrderID: &orderID,
+			ShipmentLineID:    &lineID,
+			LotNo:             line.LotNo,
+			Remark:            fmt.Sprintf("发货单 %s 发货出库", shipment.ShipmentNo),
+			OperatorID:        operatorID,
+		}
+		if _, err := postProductTransaction(tx, transaction); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if line.SalesOrderLineID != nil {
+			if err := tx.Model(&models.SalesOrderLine{}).Where("id = ?", *line.SalesOrderLineID).
+				Update("shipped_quantity", gorm.Expr("shipped_quantity + ?", line.Quantity)).Error; err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("更新销售订单行已发货数量失败: %v", err)
+			}
+		}
+	}
+
+	for _, orderID := range orderIDs {
+		shipped := roundQuantity(orders[orderID].Shipped + quantities[orderID])
+		if err := tx.Model(orders[orderID]).Update("shipped", shipped).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("更新生产工单已发货数量失败: %v", err)
+		}
+	}
+
+	now := time.Now()
+	if err := tx.Model(&shipment).Updates(map[string]interface{}{
+		"status":     "shipped",
+		"shipped_by": operatorID,
+		"shipped_at": now,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("更新发货单状态失败: %v", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("提交事务失败: %v", err)
+	}
+
+	return s.GetShipment(shipment.ID)
+}
+
+// GenerateDeliveryNote 生成发货单PDF，返回文件名和文件内容，已取消的发货单不能打印
+func (s *ShipmentService) GenerateDeliveryNote(id uint) (string, []byte, error) {
+	shipment, err := s.loadShipment(s.db, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if shipment.Status == "cancelled" {
+		return "", nil, errors.New("已取消的发货单不能打印")
+	}
+
+	var salesOrder models.SalesOrder
+	if shipment.SalesOrderID != nil {
+		s.db.First(&salesOrder, *shipment.SalesOrderID)
+	}
+
+	data, err := s.renderDeliveryNote(shipment, &salesOrder)
+	if err != nil {
+		return "", nil, err
+	}
+	return shipment.ShipmentNo + ".pdf", data, nil
+}
+
+// checkShippableQuantity 校验生产工单的已生产未发货数量不小于本次发货数量，返工工单的产出不登记成品入库，不能发货
+func checkShippableQuantity(order *models.ProductionOrder, quantity float64) error {
+	if order.OrderType == ProductionOrderTypeRework {
+		return fmt.Errorf("生产工单 %s 为返工工单，返工的成品已按原工单入库，请按原工单发货", order.OrderNo)
+	}
+	available := roundQuantity(order.Produced - order.Shipped)
+	if quantity > available {
+		return fmt.Errorf("生产工单 %s 可发货数量为 %v（已生产 %v，已发货 %v），不能发货 %v",
+			order.OrderNo, math.Max(available, 0), order.Produced, order.Shipped, quantity)
+	}
+	return nil
+}
+
+// checkShipmentSalesOrder 校验发货关联的销售订单已确认且属于发货客户
+func checkShipmentSalesOrder(db *gorm.DB, salesOrderID, customerID uint) (*models.SalesOrder, error) {
+	var order models.SalesOrder
+	if err := db.First(&order, salesOrderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("销售订单不存在")
+		}
+		return nil, fmt.Errorf("获取销售订单失败: %v", err)
+	}
+	if order.CustomerID != customerID {
+		return nil, fmt.Errorf("销售订单 %s 不属于该客户", order.OrderNo)
+	}
+	if order.Status != "confirmed" {
+		return nil, fmt.Errorf("销售订单 %s 未确认或已关闭，不能发货", order.OrderNo)
+	}
+	return &order, nil
+}
+
+// checkSerialsNotShipped 校验序列号没有在已发货的发货单中发出过
+func checkSerialsNotShipped(db *gorm.DB, productID uint, serialNos []string) error {
+	if len(serialNos) == 0 {
+		return nil
+	}
+
+	var shipped []string
+	err := db.Model(&models.ShipmentSerial{}).
+		Joins("JOIN shipment_lines ON shipment_lines.id = shipment_serials.shipment_line_id AND shipment_lines.deleted_at IS NULL").
+		Joins("JOIN shipments ON shipments.id = shipment_lines.shipment_id AND shipments.deleted_at IS NULL").
+		Where("shipments.status = ?", "shipped").
+		Where("shipment_serials.product_id = ? AND shipment_serials.serial_no IN ?", productID, serialNos).
+		Pluck("shipment_serials.serial_no", &shipped).Error
+	if err != nil {
+		return fmt.Errorf("校验序列号失败: %v", err)
+	}
+	if len(shipped) > 0 {
+		return fmt.Errorf("序列号 %s 已发货", strings.Join(shipped, ", "))
+	}
+	return nil
+}
+
+// checkProductLotBalance 校验成品批次的结存数量足够出库，未指定批次时不校验
+func checkProductLotBalance(db *gorm.DB, productID uint, lotNo string, quantity float64) error {
+	if lotNo == "" {
+		return nil
+	}
+
+	var balance float64
+	err := db.Model(&models.ProductTransaction{}).
+		Select("COALESCE(SUM(CASE WHEN type IN ? THEN quantity ELSE -quantity END), 0)", productInboundTransactionTypes).
+		Where("product_id = ? AND lot_no = ?", productID, lotNo).
+		Scan(&balance).Error
+	if err != nil {
+		return fmt.Errorf("获取批次结存失败: %v", err)
+	}
+	if balance = roundQuantity(balance); balance < quantity {
+		return fmt.Errorf("批次 %s 结存数量为 %v，不足出库数量 %v", lotNo, balance, quantity)
+	}
+	return nil
+}
+
+// 辅助函数：校验客户和销售订单并设置发货单头信息
+func (s *ShipmentService) applyShipmentRequest(shipment *models.Shipment, req *ShipmentRequest) error {
+	var customer models.Customer
+	if err := s.db.First(&customer, req.CustomerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("客户不存在")
+		}
+		return fmt.Errorf("获取客户失败: %v", err)
+	}
+	if customer.Status != 1 {
+		return fmt.Errorf("客户 %s 已停用", customer.Name)
+	}
+
+	if req.SalesOrderID != nil {
+		if _, err := checkShipmentSalesOrder(s.db, *req.SalesOrderID, req.CustomerID); err != nil {
+			return err
+		}
+	}
+
+	shipDate := time.Now()
+	if req.ShipDate != nil {
+		shipDate = *req.ShipDate
+	}
+	shipTo := req.ShipTo
+	if shipTo == "" {
+		shipTo = customer.Address
+	}
+
+	shipment.CustomerID = req.CustomerID
+	shipment.SalesOrderID = req.SalesOrderID
+	shipment.ShipDate = shipDate
+	shipment.ShipTo = shipTo
+	shipment.Carrier = req.Carrier
+	shipment.TrackingNo = req.TrackingNo
+	shipment.Remark = req.Remark
+	return nil
+}
+
+// 辅助函数：校验生产工单、批次和序列号并构建发货行，同一工单多行的合计数量不能超过其已生产未发货数量
+func (s *ShipmentService) buildShipmentLines(req *ShipmentRequest) ([]models.ShipmentLine, error) {
+	lines := make([]models.ShipmentLine, 0, len(req.Lines))
+	orders := make(map[uint]*models.ProductionOrder)
+	quantities := make(map[uint]float64)
+	serialSeen := make(map[string]int)
+
+	for i, lineReq := range req.Lines {
+		lineNo := i + 1
+
+		order, exists := orders[lineReq.ProductionOrderID]
+		if !exists {
+			order = &models.ProductionOrder{}
+			if err := s.db.First(order, lineReq.ProductionOrderID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, fmt.Errorf("第 %d 行生产工单不存在", lineNo)
+				}
+				return nil, fmt.Errorf("获取生产工单失败: %v", err)
+			}
+			orders[order.ID] = order
+		}
+
+		// 按订单生产的成品只能发给下单客户
+		if order.SalesOrderLineID != nil {
+			var salesLine models.SalesOrderLine
+			if err := s.db.First(&salesLine, *order.SalesOrderLineID).Error; err != nil {
+				return nil, fmt.Errorf("获取生产工单关联的销售订单行失败: %v", err)
+			}
+			var salesOrder models.SalesOrder
+			if err := s.db.First(&salesOrder, salesLine.SalesOrderID).Error; err != nil {
+				return nil, fmt.Errorf("获取生产工单关联的销售订单失败: %v", err)
+			}
+			if salesOrder.CustomerID != req.CustomerID {
+				return nil, fmt.Errorf("第 %d 行生产工单 %s 是销售订单 %s 的订单生产，不能发给其他客户", lineNo, order.OrderNo, salesOrder.OrderNo)
+			}
+			if req.SalesOrderID != nil && salesOrder.ID != *req.SalesOrderID {
+				return nil, fmt.Errorf("第 %d 行生产工单 %s 不属于该销售订单", lineNo, order.OrderNo)
+			}
+		} else if req.SalesOrderID != nil {
+			return nil, fmt.Errorf("第 %d 行生产工单 %s 未关联该销售订单", lineNo, order.OrderNo)
+		}
+
+		quantity := roundQuantity(lineReq.Quantity)
+		quantities[order.ID] = roundQuantity(quantities[order.ID] + quantity)
+		if err := checkShippableQuantity(order, quantities[order.ID]); err != nil {
+			return nil, fmt.Errorf("第 %d 行%v", lineNo, err)
+		}
+
+		lotNo, err := s.resolveShipmentLot(order, lineReq.LotNo)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行%v", lineNo, err)
+		}
+
+		// 填写序列号时逐件发货，序列号个数须与发货数量一致
+		var serials []models.ShipmentSerial
+		var serialNos []string
+		for _, serialNo := range lineReq.SerialNos {
+			serialNo = strings.TrimSpace(serialNo)
+			if serialNo == "" {
+				return nil, fmt.Errorf("第 %d 行序列号不能为空", lineNo)
+			}
+			key := fmt.Sprintf("%d:%s", order.ProductID, serialNo)
+			if prev, dup := serialSeen[key]; dup {
+				return nil, fmt.Errorf("第 %d 行序列号 %s 与第 %d 行重复", lineNo, serialNo, prev)
+			}
+			serialSeen[key] = lineNo
+			serialNos = append(serialNos, serialNo)
+			serials = append(serials, models.ShipmentSerial{ProductID: order.ProductID, SerialNo: serialNo})
+		}
+		if len(serials) > 0 && float64(len(serials)) != quantity {
+			return nil, fmt.Errorf("第 %d 行序列号个数 %d 与发货数量 %v 不一致", lineNo, len(serials), quantity)
+		}
+		if err := checkSerialsNotShipped(s.db, order.ProductID, serialNos); err != nil {
+			return nil, fmt.Errorf("第 %d 行%v", lineNo, err)
+		}
+
+		lines = append(lines, models.ShipmentLine{
+			LineNo:            lineNo,
+			ProductID:         order.ProductID,
+			ProductionOrderID: order.ID,
+			SalesOrderLineID:  order.SalesOrderLineID,
+			Quantity:          quantity,
+			LotNo:             lotNo,
+			Serials:           serials,
+			Remark:            lineReq.Remark,
+		})
+	}
+
+	return lines, nil
+}
+
+// 辅助函数：确定发货批次，指定批次时须是该工单的入库批次，未指定且工单只有一个入库批次时取该批次
+func (s *ShipmentService) resolveShipmentLot(order *models.ProductionOrder, lotNo string) (string, error) {
+	var lots []string
+	if err := s.db.Model(&models.ProductTransaction{}).
+		Where("production_order_id = ? AND type = ?", order.ID, "production_receipt").
+		Distinct().Pluck("lot_no", &lots).Error; err != nil {
+		return "", fmt.Errorf("获取工单入库批次失败: %v", err)
+	}
+
+	if lotNo == "" {
+		if len(lots) == 1 {
+			return lots[0], nil
+		}
+		return "", nil
+	}
+
+	for _, lot := range lots {
+		if lot == lotNo {
+			return lotNo, nil
+		}
+	}
+	return "", fmt.Errorf("批次 %s 不是生产工单 %s 的入库批次", lotNo, order.OrderNo)
+}
+
+// 辅助函数：加载发货单及发货行
+func (s *ShipmentService) loadShipment(db *gorm.DB, id uint) (*models.Shipment, error) {
+	var shipment models.Shipment
+	err := db.Preload("Customer").Preload("Creator").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("line_no") }).
+		Preload("Lines.Product").Preload("Lines.ProductionOrder").Preload("Lines.Serials").
+		First(&shipment, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("发货单不存在")
+		}
+		return nil, fmt.Errorf("获取发货单失败: %v", err)
+	}
+	return &shipment, nil
+}
+
+// 辅助函数：删除发货单的发货行及序列号
+func deleteShipmentLines(tx *gorm.DB, shipmentID uint) error {
+	if err := tx.Where("shipment_line_id IN (?)",
+		tx.Model(&models.ShipmentLine{}).Select("id").Where("shipment_id = ?", shipmentID)).
+		Delete(&models.ShipmentSerial{}).Error; err != nil {
+		return fmt.Errorf("删除发货序列号失败: %v", err)
+	}
+	if err := tx.Where("shipment_id = ?", shipmentID).Delete(&models.ShipmentLine{}).Error; err != nil {
+		return fmt.Errorf("删除发货行失败: %v", err)
+	}
+	return nil
+}
+
+// generateShipmentNo 生成发货单号
+func (s *ShipmentService) generateShipmentNo() string {
+	prefix := fmt.Sprintf("SHP%s", time.Now().Format("20060102"))
+
+	var count int64
+	s.db.Unscoped().Model(&models.Shipment{}).
+		Where("shipment_no LIKE ?", prefix+"%").
+		Count(&count)
+
+	return fmt.Sprintf("%s%04d", prefix, count+1)
+}
+
+// 辅助函数：转换为响应结构体
+func (s *ShipmentService) shipmentToResponse(shipment *models.Shipment) *ShipmentResponse {
+	resp := &ShipmentResponse{
+		ID:           shipment.ID,
+		ShipmentNo:   shipment.ShipmentNo,
+		CustomerID:   shipment.CustomerID,
+		CustomerCode: shipment.Customer.Code,
+		CustomerName: shipment.Customer.Name,
+		SalesOrderID: shipment.SalesOrderID,
+		ShipDate:     shipment.ShipDate,
+		Status:       shipment.Status,
+		ShipTo:       shipment.ShipTo,
+		Carrier:      shipment.Carrier,
+		TrackingNo:   shipment.TrackingNo,
+		Remark:       shipment.Remark,
+		CreatedBy:    shipment.CreatedBy,
+		CreatorName:  shipment.Creator.Username,
+		ShippedBy:    shipment.ShippedBy,
+		ShippedAt:    shipment.ShippedAt,
+		CreatedAt:    shipment.CreatedAt,
+		UpdatedAt:    shipment.UpdatedAt,
+	}
+
+	if shipment.SalesOrderID != nil {
+		var salesOrder models.SalesOrder
+		if err := s.db.Select("order_no", "customer_po").First(&salesOrder, *shipment.SalesOrderID).Error; err == nil {
+			resp.SalesOrderNo = salesOrder.OrderNo
+			resp.CustomerPO = salesOrder.CustomerPO
+		}
+	}
+
+	var total float64
+	for _, line := range shipment.Lines {
+		total += line.Quantity
+
+		serialNos := make([]string, 0, len(line.Serials))
+		for _, serial := range line.Serials {
+			serialNos = append(serialNos, serial.SerialNo)
+		}
+		resp.Lines = append(resp.Lines, ShipmentLineResponse{
+			ID:                line.ID,
+			LineNo:            line.LineNo,
+			ProductID:         line.ProductID,
+			ProductCode:       line.Product.Code,
+			ProductName:       line.Product.Name,
+			Unit:              line.Product.Unit,
+			ProductionOrderID: line.ProductionOrderID,
+			ProductionOrderNo: line.ProductionOrder.OrderNo,
+			SalesOrderLineID:  line.SalesOrderLineID,
+			Quantity:          line.Quantity,
+			LotNo:             line.LotNo,
+			SerialNos:         serialNos,
+			Remark:            line.Remark,
+		})
+	}
+	resp.TotalQuantity = roundQuantity(total)
+
+	return resp
+}
+
+// 辅助函数：生成A4发货单PDF，表头为发货和客户信息，表体为发货行，序列号打印在所属行下方
+func (s *ShipmentService) renderDeliveryNote(shipment *models.Shipment, salesOrder *models.SalesOrder) ([]byte, error) {
+	const margin, lineHeight = 15.0, 7.0
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin)
+
+	chinese := s.fontData != nil
+	texts := deliveryNoteTexts[chinese]
+	family := "Helvetica"
+	if chinese {
+		pdf.AddUTF8FontFromBytes("note", "", s.fontData)
+		family = "note"
+	}
+	text := func(value string) string {
+		if chinese {
+			return value
+		}
+		return strings.Map(func(r rune) rune {
+			if r > 126 {
+				return '?'
+			}
+			return r
+		}, value)
+	}
+
+	pdf.AddPage()
+	pageWidth, _ := pdf.GetPageSize()
+	contentWidth := pageWidth - 2*margin
+
+	pdf.SetFont(family, "", 18)
+	pdf.CellFormat(contentWidth, 12, texts["title"], "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	// 表头：左右两列
+	header := [][2]string{
+		{texts["shipment_no"], shipment.ShipmentNo},
+		{texts["ship_date"], shipment.ShipDate.Format("2006-01-02")},
+		{texts["customer"], fmt.Sprintf("%s %s", shipment.Customer.Code, shipment.Customer.Name)},
+		{texts["sales_order"], salesOrder.OrderNo},
+		{texts["customer_po"], salesOrder.CustomerPO},
+		{texts["carrier"], shipment.Carrier},
+		{texts["tracking_no"], shipment.TrackingNo},
+	}
+	pdf.SetFont(family, "", 10)
+	columnWidth := contentWidth / 2
+	for i, item := range header {
+		ln := 0
+		if i%2 == 1 || i == len(header)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(columnWidth, lineHeight, text(item[0]+": "+item[1]), "", ln, "L", false, 0, "")
+	}
+	pdf.MultiCell(contentWidth, lineHeight, text(texts["ship_to"]+": "+shipment.ShipTo), "", "L", false)
+	pdf.Ln(3)
+
+	// 表体
+	widths := []float64{12, 33, 60, 35, 25, 15}
+	titles := []string{texts["line_no"], texts["product"], "", texts["lot_no"], texts["quantity"], texts["unit"]}
+	pdf.SetFillColor(230, 230, 230)
+	pdf.CellFormat(widths[0], lineHeight, titles[0], "1", 0, "C", true, 0, "")
+	pdf.CellFormat(widths[1]+widths[2], lineHeight, titles[1], "1", 0, "C", true, 0, "")
+	for i := 3; i < len(widths); i++ {
+		pdf.CellFormat(widths[i], lineHeight, titles[i], "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	var total float64
+	for _, line := range shipment.Lines {
+		total += line.Quantity
+		pdf.CellFormat(widths[0], lineHeight, fmt.Sprintf("%d", line.LineNo), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(widths[1], lineHeight, fitLabelText(text(line.Product.Code), widths[1]-2, pdf.GetStringWidth), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], lineHeight, fitLabelText(text(line.Product.Name), widths[2]-2, pdf.GetStringWidth), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[3], lineHeight, fitLabelText(text(line.LotNo), widths[3]-2, pdf.GetStringWidth), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[4], lineHeight, fmt.Sprintf("%v", line.Quantity), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], lineHeight, text(line.Product.Unit), "1", 1, "C", false, 0, "")
+
+		if len(line.Serials) > 0 {
+			serialNos := make([]string, 0, len(line.Serials))
+			for _, serial := range line.Serials {
+				serialNos = append(serialNos, serial.SerialNo)
+			}
+			pdf.SetFont(family, "", 8)
+			pdf.CellFormat(widths[0], 5, "", "LB", 0, "", false, 0, "")
+			pdf.MultiCell(contentWidth-widths[0], 5, text(texts["serials"]+": "+strings.Join(serialNos, ", ")), "RB", "L", false)
+			pdf.SetFont(family, "", 10)
+		}
+	}
+
+	totalWidth := widths[0] + widths[1] + widths[2] + widths[3]
+	pdf.CellFormat(totalWidth, lineHeight, texts["total"], "1", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[4], lineHeight, fmt.Sprintf("%v", roundQuantity(total)), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[5], lineHeight, "", "1", 1, "", false, 0, "")
+
+	if shipment.Remark != "" {
+		pdf.Ln(3)
+		pdf.MultiCell(contentWidth, lineHeight, text(texts["remark"]+": "+shipment.Remark), "", "L", false)
+	}
+
+	// 签收栏
+	pdf.Ln(12)
+	signWidth := contentWidth / 3
+	pdf.CellFormat(signWidth, lineHeight, texts["shipper"]+": ________", "", 0, "L", false, 0, "")
+	pdf.CellFormat(signWidth, lineHeight, texts["receiver"]+": ________", "", 0, "L", false, 0, "")
+	pdf.CellFormat(signWidth, lineHeight, texts["date"]+": ________", "", 1, "L", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("生成PDF失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+
+	"mes-system/internal/models"
+)
+
+func TestCheckShippableQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    models.ProductionOrder
+		quantity float64
+		wantErr  bool
+	}{
+		{"可发货数量以内", models.ProductionOrder{OrderType: ProductionOrderTypeStandard, Produced: 100, Shipped: 40}, 60, false},
+		{"超过可发货数量", models.ProductionOrder{OrderType: ProductionOrderTypeStandard, Produced: 100, Shipped: 40}, 60.5, true},
+		{"未填工单类型按标准工单", models.ProductionOrder{Produced: 10}, 10, false},
+		{"返工工单不能发货", models.ProductionOrder{OrderType: ProductionOrderTypeRework, Produced: 20}, 5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkShippableQuantity(&tt.order, tt.quantity); (err != nil) != tt.wantErr {
+				t.Errorf("错误 = %v，期望返回错误 = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,543 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"mes-system/internal/models"
+)
+
+// SPC控制图类型
+const (
+	SpcChartXbarR = "xbar_r" // 均值-极差图
+	SpcChartXbarS = "xbar_s" // 均值-标准差图
+	SpcChartIMR   = "imr"    // 单值-移动极差图
+)
+
+// SPC子组划分方式
+const (
+	SpcSubgroupProductionOrder = "production_order" // 按生产工单
+	SpcSubgroupHour            = "hour"             // 按小时
+	SpcSubgroupDay             = "day"              // 按天
+	SpcSubgroupWeek            = "week"             // 按周（周一开始）
+)
+
+// spcBucketMonth 按月划分时间段，用于过程能力的统计期间
+const spcBucketMonth = "month"
+
+// spcDefaultWindowDays 未指定开始日期时默认统计的天数
+const spcDefaultWindowDays = 30
+
+// spcMinSubgroups 控制限可靠所需的最少子组（单值图为点）数量
+const spcMinSubgroups = 20
+
+// spcValueScale SPC计算结果保留的小数位数
+const spcValueScale = 6
+
+// spcD2 极差系数d2（子组容量2~25）
+var spcD2 = map[int]float64{
+	2: 1.128, 3: 1.693, 4: 2.059, 5: 2.326, 6: 2.534, 7: 2.704, 8: 2.847, 9: 2.970, 10: 3.078,
+	11: 3.173, 12: 3.258, 13: 3.336, 14: 3.407, 15: 3.472, 16: 3.532, 17: 3.588, 18: 3.640,
+	19: 3.689, 20: 3.735, 21: 3.778, 22: 3.819, 23: 3.858, 24: 3.895, 25: 3.931,
+}
+
+// spcD3 极差标准差系数d3（子组容量2~25）
+var spcD3 = map[int]float64{
+	2: 0.853, 3: 0.888, 4: 0.880, 5: 0.864, 6: 0.848, 7: 0.833, 8: 0.820, 9: 0.808, 10: 0.797,
+	11: 0.787, 12: 0.778, 13: 0.770, 14: 0.763, 15: 0.756, 16: 0.750, 17: 0.744, 18: 0.739,
+	19: 0.733, 20: 0.729, 21: 0.724, 22: 0.720, 23: 0.716, 24: 0.712, 25: 0.708,
+}
+
+// SpcChartQuery SPC控制图查询条件
+type SpcChartQuery struct {
+	QualityStandardID uint       // 质量标准ID
+	ChartType         string     // 控制图类型：xbar_r/xbar_s/imr
+	SubgroupBy        string     // 子组划分方式：production_order/hour/day/week，单值图忽略
+	StartDate         *time.Time // 开始时间（含）
+	EndDate           *time.Time // 结束时间（不含）
+}
+
+// SpcPoint 控制图上的一个点
+type SpcPoint struct {
+	Index             int       `json:"index"`
+	Label             string    `json:"label"`                         // 子组标签：工单号或时间段，单值图为检测时间
+	ProductionOrderID *uint     `json:"production_order_id,omitempty"` // 按工单划分子组或单值图时的生产工单
+	InspectionID      *uint     `json:"inspection_id,omitempty"`       // 单值图对应的检测记录
+	StartTime         time.Time `json:"start_time"`                    // 子组内最早检测时间
+	EndTime           time.Time `json:"end_time"`                      // 子组内最晚检测时间
+	SampleSize        int       `json:"sample_size"`
+	Value             float64   `json:"value"`
+	CenterLine        float64   `json:"center_line"`
+	UCL               float64   `json:"ucl"`
+	LCL               float64   `json:"lcl"`
+	OutOfControl      bool      `json:"out_of_control"` // 超出控制限
+}
+
+// SpcChart 控制图
+type SpcChart struct {
+	Name              string     `json:"name"` // X-bar、R、S、I、MR
+	CenterLine        float64    `json:"center_line"`
+	UCL               float64    `json:"ucl"` // 按典型子组容量计算的控制上限，子组容量不同时以各点的控制限为准
+	LCL               float64    `json:"lcl"`
+	OutOfControlCount int        `json:"out_of_control_count"`
+	Points            []SpcPoint `json:"points"`
+}
+
+// SpcChartResponse SPC控制图响应结构体
+type SpcChartResponse struct {
+	QualityStandardID   uint      `json:"quality_standard_id"`
+	QualityStandardName string    `json:"quality_standard_name"`
+	ProductID           uint      `json:"product_id"`
+	ProductCode         string    `json:"product_code"`
+	ProductName         string    `json:"product_name"`
+	Unit                string    `json:"unit"`
+	ChartType           string    `json:"chart_type"`
+	SubgroupBy          string    `json:"subgroup_by,omitempty"`
+	StartDate           time.Time `json:"start_date"`
+	EndDate             time.Time `json:"end_date"`
+	SpecLower           *float64  `json:"spec_lower"` // 规格下限（质量标准最小值），仅上限规格时为空
+	SpecUpper           *float64  `json:"spec_upper"` // 规格上限（质量标准最大值），仅下限规格时为空
+	Target              float64   `json:"target"`
+	SampleCount         int       `json:"sample_count"`       // 参与计算的测量值数量
+	SubgroupCount       int       `json:"subgroup_count"`     // 参与计算的子组数量，单值图为点数
+	SubgroupSize        int       `json:"subgroup_size"`      // 典型子组容量（出现次数最多的容量）
+	Mean                float64   `json:"mean"`               // 总均值
+	SigmaWithin         float64   `json:"sigma_within"`       // 由极差、标准差或移动极差估计的组内标准差
+	LocationChart       SpcChart  `json:"location_chart"`     // 均值图或单值图
+	DispersionChart     SpcChart  `json:"dispersion_chart"`   // 极差图、标准差图或移动极差图
+	Warnings            []string  `json:"warnings,omitempty"` // 数据不足、子组被排除等提示
+}
+
+// spcSubgroup 子组及其测量值
+type spcSubgroup struct {
+	Key               string
+	Label             string
+	ProductionOrderID *uint
+	StartTime         time.Time
+	EndTime           time.Time
+	Values            []float64
+}
+
+// SpcService SPC统计过程控制服务
+type SpcService struct {
+	db *gorm.DB
+}
+
+// NewSpcService 创建SPC服务实例
+func NewSpcService(db *gorm.DB) *SpcService {
+	return &SpcService{db: db}
+}
+
+// GetSpcChart 计算质量标准在时间窗口内的控制图数据和控制限
+func (s *SpcService) GetSpcChart(query *SpcChartQuery) (*SpcChartResponse, error) {
+	switch query.ChartType {
+	case SpcChartXbarR, SpcChartXbarS:
+		if query.SubgroupBy == "" {
+			query.SubgroupBy = SpcSubgroupProductionOrder
+		}
+		if !isValidSpcSubgroupBy(query.SubgroupBy) {
+			return nil, errors.New("无效的子组划分方式")
+		}
+	case SpcChartIMR:
+		query.SubgroupBy = ""
+	default:
+		return nil, errors.New("无效的控制图类型")
+	}
+
+	var standard models.QualityStandard
+	if err := s.db.Preload("Product").First(&standard, query.QualityStandardID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("质量标准不存在")
+		}
+		return nil, fmt.Errorf("获取质量标准失败: %v", err)
+	}
+	if !isVariableCharacteristic(&standard) {
+		return nil, errors.New("计数型质量特性不适用计量控制图")
+	}
+
+	start, end := spcWindow(query.StartDate, query.EndDate)
+	if !start.Before(end) {
+		return nil, errors.New("开始日期不能晚于结束日期")
+	}
+
+	inspections, err := s.loadSpcInspections(standard.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SpcChartResponse{
+		QualityStandardID:   standard.ID,
+		QualityStandardName: standard.Name,
+		ProductID:           standard.ProductID,
+		ProductCode:         standard.Product.Code,
+		ProductName:         standard.Product.Name,
+		Unit:                standard.Unit,
+		ChartType:           query.ChartType,
+		SubgroupBy:          query.SubgroupBy,
+		StartDate:           start,
+		EndDate:             end,
+		Target:              standard.TargetValue,
+	}
+	result.SpecLower, result.SpecUpper = qualitySpecLimits(&standard)
+
+	if query.ChartType == SpcChartIMR {
+		err = buildIndividualsChart(result, inspections)
+	} else {
+		err = buildSubgroupChart(result, groupSpcInspections(inspections, query.SubgroupBy), query.ChartType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// 辅助函数：加载时间窗口内某质量标准的检测记录，按检测时间排序
+func (s *SpcService) loadSpcInspections(standardID uint, start, end time.Time) ([]models.QualityInspection, error) {
+	var inspections []models.QualityInspection
+	if err := s.db.Preload("ProductionOrder").
+		Where("quality_standard_id = ? AND inspection_time >= ? AND inspection_time < ?", standardID, start, end).
+		Order("inspection_time ASC, id ASC").
+		Find(&inspections).Error; err != nil {
+		return nil, fmt.Errorf("获取检测记录失败: %v", err)
+	}
+	return inspections, nil
+}
+
+// 辅助函数：按规格限类型获取规格下限和上限，单侧规格的另一侧为空
+func qualitySpecLimits(standard *models.QualityStandard) (*float64, *float64) {
+	var lower, upper *float64
+	if standard.LimitType != "upper" {
+		value := standard.MinValue
+		lower = &value
+	}
+	if standard.LimitType != "lower" {
+		value := standard.MaxValue
+		upper = &value
+	}
+	return lower, upper
+}
+
+// 辅助函数：确定统计时间窗口，默认截止到今天日终、向前30天
+func spcWindow(startDate, endDate *time.Time) (time.Time, time.Time) {
+	end := endOfDay(time.Now())
+	if endDate != nil {
+		end = *endDate
+	}
+	start := end.AddDate(0, 0, -spcDefaultWindowDays)
+	if startDate != nil {
+		start = *startDate
+	}
+	return start, end
+}
+
+// 辅助函数：验证子组划分方式
+func isValidSpcSubgroupBy(subgroupBy string) bool {
+	switch subgroupBy {
+	case SpcSubgroupProductionOrder, SpcSubgroupHour, SpcSubgroupDay, SpcSubgroupWeek:
+		return true
+	}
+	return false
+}
+
+// 辅助函数：按工单或时间段划分子组，子组按最早检测时间排序
+func groupSpcInspections(inspections []models.QualityInspection, subgroupBy string) []*spcSubgroup {
+	var subgroups []*spcSubgroup
+	index := make(map[string]*spcSubgroup)
+	for _, inspection := range inspections {
+		var key, label string
+		var orderID *uint
+		switch subgroupBy {
+		case SpcSubgroupProductionOrder:
+			key = fmt.Sprintf("%d", inspection.ProductionOrderID)
+			label = inspection.ProductionOrder.OrderNo
+			id := inspection.ProductionOrderID
+			orderID = &id
+		default:
+			key = spcBucketLabel(spcBucketStart(inspection.InspectionTime, subgroupBy), subgroupBy)
+			label = key
+		}
+
+		subgroup, ok := index[key]
+		if !ok {
+			subgroup = &spcSubgroup{
+				Key:               key,
+				Label:             label,
+				ProductionOrderID: orderID,
+				StartTime:         inspection.InspectionTime,
+			}
+			index[key] = subgroup
+			subgroups = append(subgroups, subgroup)
+		}
+		subgroup.EndTime = inspection.InspectionTime
+		subgroup.Values = append(subgroup.Values, inspection.ActualValue)
+	}
+	return subgroups
+}
+
+// 辅助函数：获取时间所在时间段（小时、天、周一开始的周、月）的起点
+func spcBucketStart(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case SpcSubgroupHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case SpcSubgroupWeek:
+		day := startOfDay(t)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case spcBucketMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+	return startOfDay(t)
+}
+
+// 辅助函数：生成时间段标签
+func spcBucketLabel(start time.Time, bucket string) string {
+	switch bucket {
+	case SpcSubgroupHour:
+		return start.Format("2006-01-02 15:00")
+	case spcBucketMonth:
+		return start.Format("2006-01")
+	}
+	return start.Format("2006-01-02")
+}
+
+// 辅助函数：计算均值-极差图或均值-标准差图
+func buildSubgroupChart(result *SpcChartResponse, subgroups []*spcSubgroup, chartType string) error {
+	var usable []*spcSubgroup
+	var singles, oversized int
+	for _, subgroup := range subgroups {
+		n := len(subgroup.Values)
+		switch {
+		case n < 2:
+			singles++
+		case chartType == SpcChartXbarR && n > 25:
+			oversized++
+		default:
+			usable = append(usable, subgroup)
+		}
+	}
+	if singles > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d个子组只有一个测量值，无法计算离散程度，已排除", singles))
+	}
+	if oversized > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d个子组容量超过25，不适用极差图，已排除，建议使用均值-标准差图", oversized))
+	}
+	if len(usable) == 0 {
+		return errors.New("没有可用的子组数据，每个子组至少需要两个测量值")
+	}
+	if len(usable) < spcMinSubgroups {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("子组数量少于%d个，控制限可能不可靠", spcMinSubgroups))
+	}
+
+	// 总均值按全部测量值加权，组内标准差取各子组估计值的平均
+	var total, sigmaSum float64
+	sizes := make(map[int]int)
+	means := make([]float64, len(usable))
+	spreads := make([]float64, len(usable))
+	for i, subgroup := range usable {
+		n := len(subgroup.Values)
+		mean, sd, rng := spcDescribe(subgroup.Values)
+		means[i] = mean
+		total += mean * float64(n)
+		result.SampleCount += n
+		sizes[n]++
+		if chartType == SpcChartXbarR {
+			spreads[i] = rng
+			sigmaSum += rng / spcD2[n]
+		} else {
+			spreads[i] = sd
+			sigmaSum += sd / spcC4(n)
+		}
+	}
+	grandMean := total / float64(result.SampleCount)
+	sigma := sigmaSum / float64(len(usable))
+
+	result.SubgroupCount = len(usable)
+	result.SubgroupSize = spcTypicalSize(sizes)
+	result.Mean = spcRound(grandMean)
+	result.SigmaWithin = spcRound(sigma)
+
+	location := SpcChart{Name: "X-bar"}
+	dispersion := SpcChart{Name: "R"}
+	if chartType == SpcChartXbarS {
+		dispersion.Name = "S"
+	}
+	location.CenterLine, location.UCL, location.LCL = spcMeanLimits(grandMean, sigma, result.SubgroupSize)
+	dispersion.CenterLine, dispersion.UCL, dispersion.LCL = spcDispersionLimits(chartType, sigma, result.SubgroupSize)
+
+	for i, subgroup := range usable {
+		n := len(subgroup.Values)
+		point := SpcPoint{
+			Index:             i + 1,
+			Label:             subgroup.Label,
+			ProductionOrderID: subgroup.ProductionOrderID,
+			StartTime:         subgroup.StartTime,
+			EndTime:           subgroup.EndTime,
+			SampleSize:        n,
+		}
+
+		locationPoint := point
+		locationPoint.Value = spcRound(means[i])
+		locationPoint.CenterLine, locationPoint.UCL, locationPoint.LCL = spcMeanLimits(grandMean, sigma, n)
+		location.addPoint(locationPoint)
+
+		dispersionPoint := point
+		dispersionPoint.Value = spcRound(spreads[i])
+		dispersionPoint.CenterLine, dispersionPoint.UCL, dispersionPoint.LCL = spcDispersionLimits(chartType, sigma, n)
+		dispersion.addPoint(dispersionPoint)
+	}
+
+	result.LocationChart = location
+	result.DispersionChart = dispersion
+	return nil
+}
+
+// 辅助函数：计算单值-移动极差图
+func buildIndividualsChart(result *SpcChartResponse, inspections []models.QualityInspection) error {
+	if len(inspections) < 2 {
+		return errors.New("单值-移动极差图至少需要两个测量值")
+	}
+	if len(inspections) < spcMinSubgroups {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("测量值少于%d个，控制限可能不可靠", spcMinSubgroups))
+	}
+
+	var total, mrSum float64
+	for i, inspection := range inspections {
+		total += inspection.ActualValue
+		if i > 0 {
+			mrSum += math.Abs(inspection.ActualValue - inspections[i-1].ActualValue)
+		}
+	}
+	mean := total / float64(len(inspections))
+	mrBar := mrSum / float64(len(inspections)-1)
+	sigma := mrBar / spcD2[2]
+
+	result.SampleCount = len(inspections)
+	result.SubgroupCount = len(inspections)
+	result.SubgroupSize = 1
+	result.Mean = spcRound(mean)
+	result.SigmaWithin = spcRound(sigma)
+
+	location := SpcChart{Name: "I"}
+	location.CenterLine, location.UCL, location.LCL = spcMeanLimits(mean, sigma, 1)
+	dispersion := SpcChart{Name: "MR"}
+	dispersion.CenterLine, dispersion.UCL, dispersion.LCL = spcDispersionLimits(SpcChartXbarR, sigma, 2)
+
+	for i, inspection := range inspections {
+		inspectionID := inspection.ID
+		orderID := inspection.ProductionOrderID
+		point := SpcPoint{
+			Index:             i + 1,
+			Label:             inspection.InspectionTime.Format("2006-01-02 15:04:05"),
+			ProductionOrderID: &orderID,
+			InspectionID:      &inspectionID,
+			StartTime:         inspection.InspectionTime,
+			EndTime:           inspection.InspectionTime,
+			SampleSize:        1,
+			CenterLine:        location.CenterLine,
+			UCL:               location.UCL,
+			LCL:               location.LCL,
+		}
+
+		locationPoint := point
+		locationPoint.Value = spcRound(inspection.ActualValue)
+		location.addPoint(locationPoint)
+
+		// 移动极差从第二个点开始
+		if i > 0 {
+			dispersionPoint := point
+			dispersionPoint.SampleSize = 2
+			dispersionPoint.Value = spcRound(math.Abs(inspection.ActualValue - inspections[i-1].ActualValue))
+			dispersionPoint.CenterLine = dispersion.CenterLine
+			dispersionPoint.UCL = dispersion.UCL
+			dispersionPoint.LCL = dispersion.LCL
+			dispersion.addPoint(dispersionPoint)
+		}
+	}
+
+	result.LocationChart = location
+	result.DispersionChart = dispersion
+	return nil
+}
+
+// addPoint 添加控制图点并判断是否超出控制限
+func (c *SpcChart) addPoint(point SpcPoint) {
+	point.OutOfControl = point.Value > point.UCL || point.Value < point.LCL
+	if point.OutOfControl {
+		c.OutOfControlCount++
+	}
+	c.Points = append(c.Points, point)
+}
+
+// 辅助函数：计算均值图（或单值图）的中心线和控制限
+func spcMeanLimits(mean, sigma float64, n int) (float64, float64, float64) {
+	width := 3 * sigma / math.Sqrt(float64(n))
+	return spcRound(mean), spcRound(mean + width), spcRound(mean - width)
+}
+
+// 辅助函数：计算极差图或标准差图的中心线和控制限，下限不低于0
+func spcDispersionLimits(chartType string, sigma float64, n int) (float64, float64, float64) {
+	var center, width float64
+	if chartType == SpcChartXbarR {
+		center = spcD2[n] * sigma
+		width = 3 * spcD3[n] * sigma
+	} else {
+		c4 := spcC4(n)
+		center = c4 * sigma
+		width = 3 * math.Sqrt(1-c4*c4) * sigma
+	}
+	return spcRound(center), spcRound(center + width), spcRound(math.Max(0, center-width))
+}
+
+// 辅助函数：计算一组测量值的均值、样本标准差和极差
+func spcDescribe(values []float64) (float64, float64, float64) {
+	var sum float64
+	minValue, maxValue := values[0], values[0]
+	for _, v := range values {
+		sum += v
+		minValue = math.Min(minValue, v)
+		maxValue = math.Max(maxValue, v)
+	}
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	var sd float64
+	if len(values) > 1 {
+		sd = math.Sqrt(squares / float64(len(values)-1))
+	}
+	return mean, sd, maxValue - minValue
+}
+
+// 辅助函数：标准差无偏系数c4 = sqrt(2/(n-1)) * Γ(n/2) / Γ((n-1)/2)
+func spcC4(n int) float64 {
+	a, _ := math.Lgamma(float64(n) / 2)
+	b, _ := math.Lgamma(float64(n-1) / 2)
+	return math.Sqrt(2/float64(n-1)) * math.Exp(a-b)
+}
+
+// 辅助函数：取出现次数最多的子组容量，次数相同时取较大的容量
+func spcTypicalSize(sizes map[int]int) int {
+	keys := make([]int, 0, len(sizes))
+	for n := range sizes {
+		keys = append(keys, n)
+	}
+	sort.Ints(keys)
+	typical := 0
+	for _, n := range keys {
+		if sizes[n] >= sizes[typical] {
+			typical = n
+		}
+	}
+	return typical
+}
+
+// 辅助函数：SPC计算结果舍入
+func spcRound(value float64) float64 {
+	return roundToPrecision(value, spcValueScale)
+}
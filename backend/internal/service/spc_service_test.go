@@ -0,0 +1,201 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"mes-system/internal/models"
+)
+
+// spcConstantTolerance 控制图系数表保留三位小数，比较时允许的误差
+const spcConstantTolerance = 0.002
+
+// 期望值取自 GB/T 17989.2-2020（ISO 7870-2）控制图系数表：平均极差为1时均值图控制限宽度为A2，极差图上下限为D4、D3
+func TestSpcXbarRLimits(t *testing.T) {
+	tests := []struct {
+		n  int
+		a2 float64
+		d3 float64
+		d4 float64
+	}{
+		{2, 1.880, 0, 3.267},
+		{3, 1.023, 0, 2.574},
+		{4, 0.729, 0, 2.282},
+		{5, 0.577, 0, 2.114},
+		{6, 0.483, 0, 2.004},
+		{7, 0.419, 0.076, 1.924},
+		{8, 0.373, 0.136, 1.864},
+		{10, 0.308, 0.223, 1.777},
+		{25, 0.153, 0.459, 1.541},
+	}
+
+	for _, tt := range tests {
+		sigma := 1 / spcD2[tt.n]
+
+		center, ucl, lcl := spcMeanLimits(0, sigma, tt.n)
+		assertNear(t, "均值图中心线", tt.n, center, 0)
+		assertNear(t, "A2", tt.n, ucl, tt.a2)
+		assertNear(t, "-A2", tt.n, lcl, -tt.a2)
+
+		center, ucl, lcl = spcDispersionLimits(SpcChartXbarR, sigma, tt.n)
+		assertNear(t, "极差图中心线", tt.n, center, 1)
+		assertNear(t, "D4", tt.n, ucl, tt.d4)
+		assertNear(t, "D3", tt.n, lcl, tt.d3)
+	}
+}
+
+// 平均标准差为1时均值图控制限宽度为A3，标准差图上下限为B4、B3，中心线与σ之比为c4
+func TestSpcXbarSLimits(t *testing.T) {
+	tests := []struct {
+		n  int
+		c4 float64
+		a3 float64
+		b3 float64
+		b4 float64
+	}{
+		{2, 0.7979, 2.659, 0, 3.267},
+		{5, 0.9400, 1.427, 0, 2.089},
+		{6, 0.9515, 1.287, 0.030, 1.970},
+		{10, 0.9727, 0.975, 0.284, 1.716},
+		{25, 0.9896, 0.606, 0.565, 1.435},
+	}
+
+	for _, tt := range tests {
+		assertNear(t, "c4", tt.n, spcC4(tt.n), tt.c4)
+
+		sigma := 1 / spcC4(tt.n)
+		_, ucl, _ := spcMeanLimits(0, sigma, tt.n)
+		assertNear(t, "A3", tt.n, ucl, tt.a3)
+
+		center, ucl, lcl := spcDispersionLimits(SpcChartXbarS, sigma, tt.n)
+		assertNear(t, "标准差图中心线", tt.n, center, 1)
+		assertNear(t, "B4", tt.n, ucl, tt.b4)
+		assertNear(t, "B3", tt.n, lcl, tt.b3)
+	}
+}
+
+func TestSpcDescribe(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		mean   float64
+		sd     float64
+		rng    float64
+	}{
+		{"样本标准差", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 5, math.Sqrt(32.0 / 7), 7},
+		{"两个测量值", []float64{10.1, 9.9}, 10, math.Sqrt(0.02), 0.2},
+		{"单个测量值", []float64{3}, 3, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mean, sd, rng := spcDescribe(tt.values)
+			if math.Abs(mean-tt.mean) > 1e-9 || math.Abs(sd-tt.sd) > 1e-9 || math.Abs(rng-tt.rng) > 1e-9 {
+				t.Errorf("均值=%v 标准差=%v 极差=%v，期望 %v %v %v", mean, sd, rng, tt.mean, tt.sd, tt.rng)
+			}
+		})
+	}
+}
+
+func TestBuildSubgroupChart(t *testing.T) {
+	subgroups := []*spcSubgroup{
+		{Label: "1", Values: []float64{1, 2, 3}},
+		{Label: "2", Values: []float64{2, 3, 4}},
+		{Label: "3", Values: []float64{3, 4, 8}},
+		{Label: "单值", Values: []float64{5}},
+	}
+
+	var result SpcChartResponse
+	if err := buildSubgroupChart(&result, subgroups, SpcChartXbarR); err != nil {
+		t.Fatalf("计算控制图失败: %v", err)
+	}
+
+	// 单值子组被排除；平均极差 (2+2+5)/3 = 3，总均值 (2+3+5)/3
+	if result.SubgroupCount != 3 || result.SampleCount != 9 || result.SubgroupSize != 3 {
+		t.Errorf("子组数=%d 测量值数=%d 子组容量=%d，期望 3 9 3", result.SubgroupCount, result.SampleCount, result.SubgroupSize)
+	}
+	if len(result.Warnings) != 2 {
+		t.Errorf("提示 = %v，期望单值子组和子组数量不足两条提示", result.Warnings)
+	}
+	assertNear(t, "总均值", 3, result.Mean, 10.0/3)
+	assertNear(t, "均值图上限", 3, result.LocationChart.UCL, 10.0/3+1.023*3)
+	assertNear(t, "均值图下限", 3, result.LocationChart.LCL, 10.0/3-1.023*3)
+	assertNear(t, "极差图中心线", 3, result.DispersionChart.CenterLine, 3)
+	assertNear(t, "极差图上限", 3, result.DispersionChart.UCL, 2.574*3)
+	assertNear(t, "极差图下限", 3, result.DispersionChart.LCL, 0)
+
+	if len(result.LocationChart.Points) != 3 || len(result.DispersionChart.Points) != 3 {
+		t.Fatalf("控制图点数 = %d/%d，期望 3/3", len(result.LocationChart.Points), len(result.DispersionChart.Points))
+	}
+	if result.LocationChart.Points[2].Value != 5 || result.DispersionChart.Points[2].Value != 5 {
+		t.Errorf("第3个子组均值=%v 极差=%v，期望 5 5", result.LocationChart.Points[2].Value, result.DispersionChart.Points[2].Value)
+	}
+}
+
+func TestBuildSubgroupChartNoUsableSubgroups(t *testing.T) {
+	subgroups := []*spcSubgroup{{Label: "1", Values: []float64{1}}, {Label: "2", Values: []float64{2}}}
+
+	var result SpcChartResponse
+	if err := buildSubgroupChart(&result, subgroups, SpcChartXbarR); err == nil {
+		t.Error("期望返回错误")
+	}
+}
+
+// 单值图控制限为均值±E2×平均移动极差（E2=2.660），移动极差图上限为D4×平均移动极差（n=2时D4=3.267）
+func TestBuildIndividualsChart(t *testing.T) {
+	values := []float64{10, 12, 11, 13, 12, 19}
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local)
+	inspections := make([]models.QualityInspection, len(values))
+	for i, v := range values {
+		inspections[i] = models.QualityInspection{ID: uint(i + 1), ActualValue: v, InspectionTime: start.Add(time.Duration(i) * time.Hour)}
+	}
+
+	var result SpcChartResponse
+	if err := buildIndividualsChart(&result, inspections); err != nil {
+		t.Fatalf("计算控制图失败: %v", err)
+	}
+
+	// 均值 77/6，移动极差 2,1,2,1,7，平均移动极差 2.6
+	mean, mrBar := 77.0/6, 2.6
+	assertNear(t, "均值", 1, result.Mean, mean)
+	assertNear(t, "E2", 1, (result.LocationChart.UCL-mean)/mrBar, 2.660)
+	assertNear(t, "-E2", 1, (result.LocationChart.LCL-mean)/mrBar, -2.660)
+	assertNear(t, "移动极差图中心线", 2, result.DispersionChart.CenterLine, mrBar)
+	assertNear(t, "D4", 2, result.DispersionChart.UCL/mrBar, 3.267)
+
+	if len(result.LocationChart.Points) != 6 || len(result.DispersionChart.Points) != 5 {
+		t.Fatalf("控制图点数 = %d/%d，期望 6/5", len(result.LocationChart.Points), len(result.DispersionChart.Points))
+	}
+	// 最后一点的单值19低于上限约19.75，移动极差7低于上限约8.49，均不判为失控
+	if result.LocationChart.OutOfControlCount != 0 || result.DispersionChart.OutOfControlCount != 0 {
+		t.Errorf("失控点数 = %d/%d，期望 0/0", result.LocationChart.OutOfControlCount, result.DispersionChart.OutOfControlCount)
+	}
+}
+
+func TestSpcTypicalSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes map[int]int
+		want  int
+	}{
+		{"取出现次数最多的容量", map[int]int{3: 2, 5: 7, 4: 1}, 5},
+		{"次数相同时取较大的容量", map[int]int{3: 4, 5: 4}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spcTypicalSize(tt.sizes); got != tt.want {
+				t.Errorf("典型子组容量 = %d，期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// 辅助函数：按控制图系数表的精度比较计算结果
+func assertNear(t *testing.T, name string, n int, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > spcConstantTolerance {
+		t.Errorf("n=%d %s = %.4f，期望 %.4f", n, name, got, want)
+	}
+}
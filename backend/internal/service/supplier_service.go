@@ -0,0 +1,485 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"mes-system/internal/models"
+)
+
+// SupplierContactRequest 供应商联系人请求结构体
+type SupplierContactRequest struct {
+	Name      string `json:"name" binding:"required"` // 姓名
+	Title     string `json:"title"`                   // 职务
+	Phone     string `json:"phone"`                   // 电话
+	Email     string `json:"email"`                   // 邮箱
+	IsPrimary bool   `json:"is_primary"`              // 是否主要联系人
+}
+
+// SupplierRequest 供应商请求结构体
+type SupplierRequest struct {
+	Code     string                   `json:"code" binding:"required"` // 供应商编码
+	Name     string                   `json:"name" binding:"required"` // 供应商名称
+	Address  string                   `json:"address"`                 // 地址
+	Status   *int                     `json:"status"`                  // 状态：1合格 0停用，默认为1
+	Remark   string                   `json:"remark"`                  // 备注
+	Contacts []SupplierContactRequest `json:"contacts" binding:"dive"` // 联系人，更新时整体替换
+}
+
+// SupplierMaterialRequest 供应商合格物料请求结构体
+type SupplierMaterialRequest struct {
+	MaterialID           uint   `json:"material_id" binding:"required"` // 物料ID
+	SupplierMaterialCode string `json:"supplier_material_code"`         // 供应商物料编码
+	Remark               string `json:"remark"`                         // 备注
+}
+
+// SupplierMaterialResponse 供应商合格物料响应结构体
+type SupplierMaterialResponse struct {
+	ID                   uint   `json:"id"`
+	MaterialID           uint   `json:"material_id"`
+	MaterialCode         string `json:"material_code"`
+	MaterialName         string `json:"material_name"`
+	SupplierMaterialCode string `json:"supplier_material_code"`
+	Remark               string `json:"remark"`
+}
+
+// SupplierResponse 供应商响应结构体
+type SupplierResponse struct {
+	ID        uint                       `json:"id"`
+	Code      string                     `json:"code"`
+	Name      string                     `json:"name"`
+	Address   string                     `json:"address"`
+	Status    int                        `json:"status"`
+	Remark    string                     `json:"remark"`
+	Contacts  []models.SupplierContact   `json:"contacts"`
+	Materials []SupplierMaterialResponse `json:"materials,omitempty"`
+	CreatedAt time.Time                  `json:"created_at"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+}
+
+// SupplierScorecard 供应商期间绩效
+type SupplierScorecard struct {
+	SupplierID        uint    `json:"supplier_id"`
+	SupplierCode      string  `json:"supplier_code"`
+	SupplierName      string  `json:"supplier_name"`
+	ReceiptCount      int64   `json:"receipt_count"`       // 收货次数
+	ReceivedQuantity  float64 `json:"received_quantity"`   // 到货数量（合格+不合格）
+	AcceptedQuantity  float64 `json:"accepted_quantity"`   // 合格数量
+	RejectedQuantity  float64 `json:"rejected_quantity"`   // 不合格数量
+	RejectionRate     float64 `json:"rejection_rate"`      // 不合格率(%)
+	ReceiptValue      float64 `json:"receipt_value"`       // 收货金额
+	StandardValue     float64 `json:"standard_value"`      // 按物料标准单价计算的金额
+	PriceVariance     float64 `json:"price_variance"`      // 价格差异金额，正数表示高于标准价
+	PriceVarianceRate float64 `json:"price_variance_rate"` // 价格差异率(%)
+}
+
+// SupplierScorecardResponse 供应商绩效响应结构体
+type SupplierScorecardResponse struct {
+	StartDate  string              `json:"start_date"`
+	EndDate    string              `json:"end_date"`
+	Scorecards []SupplierScorecard `json:"scorecards"`
+}
+
+// SupplierService 供应商服务
+type SupplierService struct {
+	db *gorm.DB
+}
+
+// NewSupplierService 创建供应商服务实例
+func NewSupplierService(db *gorm.DB) *SupplierService {
+	return &SupplierService{db: db}
+}
+
+// CreateSupplier 创建供应商
+func (s *SupplierService) CreateSupplier(req *SupplierRequest) (*SupplierResponse, error) {
+	if s.isSupplierCodeExists(req.Code, 0) {
+		return nil, errors.New("供应商编码已存在")
+	}
+
+	status := 1
+	if req.Status != nil {
+		status = *req.Status
+	}
+
+	supplier := &models.Supplier{
+		Code:     req.Code,
+		Name:     req.Name,
+		Address:  req.Address,
+		Status:   status,
+		Remark:   req.Remark,
+		Contacts: buildSupplierContacts(req.Contacts),
+	}
+
+	if err := s.db.Create(supplier).Error; err != nil {
+		return nil, fmt.Errorf("创建供应商失败: %v", err)
+	}
+
+	return s.GetSupplier(supplier.ID)
+}
+
+// GetSupplier 获取供应商详情
+func (s *SupplierService) GetSupplier(id uint) (*SupplierResponse, error) {
+	var supplier models.Supplier
+	if err := s.db.Preload("Contacts").Preload("Materials.Material").First(&supplier, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("供应商不存在")
+		}
+		return nil, fmt.Errorf("获取供应商失败: %v", err)
+	}
+
+	return s.supplierToResponse(&supplier, true), nil
+}
+
+// GetSupplierList 获取供应商列表
+func (s *SupplierService) GetSupplierList(page, pageSize int, keyword string, status *int) ([]SupplierResponse, int64, error) {
+	var suppliers []models.Supplier
+	var total int64
+
+	query := s.db.Model(&models.Supplier{})
+
+	// 关键词搜索
+	if keyword != "" {
+		query = query.Where("code LIKE ? OR name LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+
+	// 按状态筛选
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	// 获取总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取供应商总数失败: %v", err)
+	}
+
+	// 分页查询
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Contacts").Offset(offset).Limit(pageSize).Order("code").Find(&suppliers).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取供应商列表失败: %v", err)
+	}
+
+	var responses []SupplierResponse
+	for _, supplier := range suppliers {
+		responses = append(responses, *s.supplierToResponse(&supplier, false))
+	}
+
+	return responses, total, nil
+}
+
+// UpdateSupplier 更新供应商，联系人整体替换
+func (s *SupplierService) UpdateSupplier(id uint, req *SupplierRequest) (*SupplierResponse, error) {
+	var supplier models.Supplier
+	if err := s.db.First(&supplier, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("供应商不存在")
+		}
+		return nil, fmt.Errorf("获取供应商失败: %v", err)
+	}
+
+	if s.isSupplierCodeExists(req.Code, id) {
+		return nil, errors.New("供应商编码已存在")
+	}
+
+	supplier.Code = req.Code
+	supplier.Name = req.Name
+	supplier.Address = req.Address
+	supplier.Remark = req.Remark
+	if req.Status != nil {
+		supplier.Status = *req.Status
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&supplier).Error; err != nil {
+			return fmt.Errorf("更新供应商失败: %v", err)
+		}
+
+		if err := tx.Where("supplier_id = ?", supplier.ID).Delete(&models.SupplierContact{}).Error; err != nil {
+			return fmt.Errorf("删除原联系人失败: %v", err)
+		}
+
+		contacts := buildSupplierContacts(req.Contacts)
+		if len(contacts) > 0 {
+			for i := range contacts {
+				contacts[i].SupplierID = supplier.ID
+			}
+			if err := tx.Create(&contacts).Error; err != nil {
+				return fmt.Errorf("创建联系人失败: %v", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetSupplier(supplier.ID)
+}
+
+// DeleteSupplier 删除供应商，存在未结采购订单时不能删除
+func (s *SupplierService) DeleteSupplier(id uint) error {
+	var supplier models.Supplier
+	if err := s.db.First(&supplier, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("供应商不存在")
+		}
+		return fmt.Errorf("获取供应商失败: %v", err)
+	}
+
+	var openOrders int64
+	s.db.Model(&models.PurchaseOrder{}).
+		Where("supplier_id = ? AND status IN ?", id, []string{"draft", "released", "partial"}).
+		Count(&openOrders)
+	if openOrders > 0 {
+		return errors.New("供应商存在未结采购订单，不能删除")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("supplier_id = ?", id).Delete(&models.SupplierContact{}).Error; err != nil {
+			return fmt.Errorf("删除联系人失败: %v", err)
+		}
+		if err := tx.Where("supplier_id = ?", id).Delete(&models.SupplierMaterial{}).Error; err != nil {
+			return fmt.Errorf("删除合格物料失败: %v", err)
+		}
+		if err := tx.Delete(&supplier).Error; err != nil {
+			return fmt.Errorf("删除供应商失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// AddSupplierMaterial 添加供应商合格物料
+func (s *SupplierService) AddSupplierMaterial(supplierID uint, req *SupplierMaterialRequest) (*SupplierResponse, error) {
+	var supplier models.Supplier
+	if err := s.db.First(&supplier, supplierID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("供应商不存在")
+		}
+		return nil, fmt.Errorf("获取供应商失败: %v", err)
+	}
+
+	var material models.Material
+	if err := s.db.First(&material, req.MaterialID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("物料不存在")
+		}
+		return nil, fmt.Errorf("获取物料失败: %v", err)
+	}
+
+	var count int64
+	s.db.Model(&models.SupplierMaterial{}).
+		Where("supplier_id = ? AND material_id = ?", supplierID, req.MaterialID).
+		Count(&count)
+	if count > 0 {
+		return nil, errors.New("该物料已在供应商合格物料清单中")
+	}
+
+	supplierMaterial := &models.SupplierMaterial{
+		SupplierID:           supplierID,
+		MaterialID:           req.MaterialID,
+		SupplierMaterialCode: req.SupplierMaterialCode,
+		Remark:               req.Remark,
+	}
+	if err := s.db.Create(supplierMaterial).Error; err != nil {
+		return nil, fmt.Errorf("添加合格物料失败: %v", err)
+	}
+
+	return s.GetSupplier(supplierID)
+}
+
+// RemoveSupplierMaterial 移除供应商合格物料
+func (s *SupplierService) RemoveSupplierMaterial(supplierID, materialID uint) error {
+	result := s.db.Where("supplier_id = ? AND material_id = ?", supplierID, materialID).Delete(&models.SupplierMaterial{})
+	if result.Error != nil {
+		return fmt.Errorf("移除合格物料失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("该物料不在供应商合格物料清单中")
+	}
+
+	return nil
+}
+
+// GetScorecards 获取期间内的供应商绩效，统计区间为 [startDate, endDate] 两端日期均包含
+func (s *SupplierService) GetScorecards(startDate, endDate time.Time, supplierID uint) (*SupplierScorecardResponse, error) {
+	if endDate.Before(startDate) {
+		return nil, errors.New("结束日期不能早于开始日期")
+	}
+
+	type scorecardRow struct {
+		SupplierID        uint
+		ReceiptCount      int64
+		ReceivedIn        float64
+		RejectedAtReceipt float64
+		Returned          float64
+		ReceiptValue      float64
+		StandardValue     float64
+	}
+
+	// 收货时拒收的数量记录在入库交易上，来料检验不合格和因质量缺陷退回的数量记录为退供应商交易；
+	// 退货按其批次的原入库时间计入收货所在期间，未指定批次的退货按退货时间计入
+	receipts := s.db.Model(&models.MaterialTransaction{}).
+		Select("material_id, lot_no, MIN(created_at) AS received_at").
+		Where("type = ? AND lot_no <> ''", "in").
+		Group("material_id, lot_no")
+	attributedAt := "CASE WHEN material_transactions.type = 'return_supplier' THEN COALESCE(receipts.received_at, material_transactions.created_at) ELSE material_transactions.created_at END"
+
+	query := s.db.Model(&models.MaterialTransaction{}).
+		Select(`material_transactions.supplier_id,
+			COUNT(CASE WHEN material_transactions.type = 'in' THEN 1 END) AS receipt_count,
+			COALESCE(SUM(CASE WHEN material_transactions.type = 'in' THEN material_transactions.quantity ELSE 0 END), 0) AS received_in,
+			COALESCE(SUM(CASE WHEN material_transactions.type = 'in' THEN material_transactions.rejected_quantity ELSE 0 END), 0) AS rejected_at_receipt,
+			COALESCE(SUM(CASE WHEN material_transactions.type = 'return_supplier' THEN material_transactions.quantity ELSE 0 END), 0) AS returned,
+			COALESCE(SUM(CASE WHEN material_transactions.type = 'in' THEN material_transactions.total_amount ELSE 0 END), 0) AS receipt_value,
+			COALESCE(SUM(CASE WHEN material_transactions.type = 'in' THEN material_transactions.quantity * materials.price ELSE 0 END), 0) AS standard_value`).
+		Joins("JOIN materials ON materials.id = material_transactions.material_id").
+		Joins("LEFT JOIN (?) AS receipts ON receipts.material_id = material_transactions.material_id AND receipts.lot_no = material_transactions.lot_no AND material_transactions.type = ?", receipts, "return_supplier").
+		Where("material_transactions.type = ? OR (material_transactions.type = ? AND material_transactions.reason_code IN ?)",
+			"in", "return_supplier", []string{ReasonCodeSupplierRejection, ReasonCodeDefective}).
+		Where("material_transactions.supplier_id IS NOT NULL").
+		Where(attributedAt+" >= ? AND "+attributedAt+" < ?", startOfDay(startDate), endOfDay(endDate))
+
+	if supplierID > 0 {
+		query = query.Where("material_transactions.supplier_id = ?", supplierID)
+	}
+
+	var rows []scorecardRow
+	if err := query.Group("material_transactions.supplier_id").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计供应商绩效失败: %v", err)
+	}
+
+	// 批量加载供应商信息
+	supplierIDs := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		supplierIDs = append(supplierIDs, row.SupplierID)
+	}
+	suppliers := make(map[uint]models.Supplier)
+	if len(supplierIDs) > 0 {
+		var supplierList []models.Supplier
+		if err := s.db.Unscoped().Where("id IN ?", supplierIDs).Find(&supplierList).Error; err != nil {
+			return nil, fmt.Errorf("获取供应商失败: %v", err)
+		}
+		for _, supplier := range supplierList {
+			suppliers[supplier.ID] = supplier
+		}
+	}
+
+	scorecards := make([]SupplierScorecard, 0, len(rows))
+	for _, row := range rows {
+		supplier := suppliers[row.SupplierID]
+		received := row.ReceivedIn + row.RejectedAtReceipt
+		rejected := row.RejectedAtReceipt + row.Returned
+		receiptValue := roundAmount(row.ReceiptValue)
+		standardValue := roundAmount(row.StandardValue)
+
+		scorecard := SupplierScorecard{
+			SupplierID:       row.SupplierID,
+			SupplierCode:     supplier.Code,
+			SupplierName:     supplier.Name,
+			ReceiptCount:     row.ReceiptCount,
+			ReceivedQuantity: received,
+			AcceptedQuantity: received - rejected,
+			RejectedQuantity: rejected,
+			ReceiptValue:     receiptValue,
+			StandardValue:    standardValue,
+			PriceVariance:    roundAmount(receiptValue - standardValue),
+		}
+		if received > 0 {
+			scorecard.RejectionRate = roundAmount(rejected / received * 100)
+		}
+		if standardValue > 0 {
+			scorecard.PriceVarianceRate = roundAmount((receiptValue - standardValue) / standardValue * 100)
+		}
+
+		scorecards = append(scorecards, scorecard)
+	}
+
+	return &SupplierScorecardResponse{
+		StartDate:  startDate.Format("2006-01-02"),
+		EndDate:    endDate.Format("2006-01-02"),
+		Scorecards: scorecards,
+	}, nil
+}
+
+// resolveReceiptSupplier 校验收货供应商：供应商须为合格状态且物料在其合格物料清单中，返回供应商信息
+func resolveReceiptSupplier(tx *gorm.DB, supplierID, materialID uint) (*models.Supplier, error) {
+	var supplier models.Supplier
+	if err := tx.First(&supplier, supplierID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("供应商不存在")
+		}
+		return nil, fmt.Errorf("获取供应商失败: %v", err)
+	}
+
+	if supplier.Status != 1 {
+		return nil, fmt.Errorf("供应商 %s 已停用", supplier.Name)
+	}
+
+	var count int64
+	tx.Model(&models.SupplierMaterial{}).
+		Where("supplier_id = ? AND material_id = ?", supplierID, materialID).
+		Count(&count)
+	if count == 0 {
+		return nil, fmt.Errorf("物料不在供应商 %s 的合格物料清单中", supplier.Name)
+	}
+
+	return &supplier, nil
+}
+
+// 辅助函数：构建联系人记录
+func buildSupplierContacts(reqs []SupplierContactRequest) []models.SupplierContact {
+	contacts := make([]models.SupplierContact, 0, len(reqs))
+	for _, req := range reqs {
+		contacts = append(contacts, models.SupplierContact{
+			Name:      req.Name,
+			Title:     req.Title,
+			Phone:     req.Phone,
+			Email:     req.Email,
+			IsPrimary: req.IsPrimary,
+		})
+	}
+	return contacts
+}
+
+// 辅助函数：检查供应商编码是否存在
+func (s *SupplierService) isSupplierCodeExists(code string, excludeID uint) bool {
+	var count int64
+	query := s.db.Model(&models.Supplier{}).Where("code = ?", code)
+	if excludeID > 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+	query.Count(&count)
+	return count > 0
+}
+
+// 辅助函数：转换为响应结构体
+func (s *SupplierService) supplierToResponse(supplier *models.Supplier, withMaterials bool) *SupplierResponse {
+	resp := &SupplierResponse{
+		ID:        supplier.ID,
+		Code:      supplier.Code,
+		Name:      supplier.Name,
+		Address:   supplier.Address,
+		Status:    supplier.Status,
+		Remark:    supplier.Remark,
+		Contacts:  supplier.Contacts,
+		CreatedAt: supplier.CreatedAt,
+		UpdatedAt: supplier.UpdatedAt,
+	}
+
+	if withMaterials {
+		for _, item := range supplier.Materials {
+			resp.Materials = append(resp.Materials, SupplierMaterialResponse{
+				ID:                   item.ID,
+				MaterialID:           item.MaterialID,
+				MaterialCode:         item.Material.Code,
+				MaterialName:         item.Material.Name,
+				SupplierMaterialCode: item.SupplierMaterialCode,
+				Remark:               item.Remark,
+			})
+		}
+	}
+
+	return resp
+}
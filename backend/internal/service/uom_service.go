@@ -0,0 +1,544 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"mes-system/internal/models"
+)
+
+// quantityScale 数量的存储精度（小数位数），与数据库 decimal(16,4) 一致
+const quantityScale = 4
+
+// UnitOfMeasureRequest 计量单位请求结构体
+type UnitOfMeasureRequest struct {
+	Code        string `json:"code" binding:"required"`         // 单位编码
+	Name        string `json:"name" binding:"required"`         // 单位名称
+	Dimension   string `json:"dimension" binding:"required"`    // 量纲：count/mass/length/volume/area
+	Precision   int    `json:"precision" binding:"min=0,max=4"` // 数量保留的小数位数
+	Description string `json:"description"`                     // 描述
+	IsActive    bool   `json:"is_active"`                       // 是否启用
+}
+
+// UnitOfMeasureResponse 计量单位响应结构体
+type UnitOfMeasureResponse struct {
+	ID          uint      `json:"id"`
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	Dimension   string    `json:"dimension"`
+	Precision   int       `json:"precision"`
+	Description string    `json:"description"`
+	IsActive    bool      `json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// UnitConversionRequest 计量单位换算请求结构体
+type UnitConversionRequest struct {
+	MaterialID *uint   `json:"material_id"`                    // 物料ID，为空时为通用换算
+	FromUnit   string  `json:"from_unit" binding:"required"`   // 源单位
+	ToUnit     string  `json:"to_unit" binding:"required"`     // 目标单位
+	Factor     float64 `json:"factor" binding:"required,gt=0"` // 换算系数：1 个源单位等于多少个目标单位
+	Remark     string  `json:"remark"`                         // 备注
+}
+
+// UnitConversionResponse 计量单位换算响应结构体
+type UnitConversionResponse struct {
+	ID           uint      `json:"id"`
+	MaterialID   *uint     `json:"material_id"`
+	MaterialCode string    `json:"material_code"`
+	MaterialName string    `json:"material_name"`
+	FromUnit     string    `json:"from_unit"`
+	ToUnit       string    `json:"to_unit"`
+	Factor       float64   `json:"factor"`
+	Remark       string    `json:"remark"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// QuantityConversionResponse 数量换算结果响应结构体
+type QuantityConversionResponse struct {
+	MaterialID   uint    `json:"material_id"`
+	Quantity     float64 `json:"quantity"`
+	Unit         string  `json:"unit"`
+	Factor       float64 `json:"factor"`
+	BaseQuantity float64 `json:"base_quantity"`
+	BaseUnit     string  `json:"base_unit"`
+}
+
+// validUnitDimensions 支持的量纲
+var validUnitDimensions = []string{"count", "mass", "length", "volume", "area"}
+
+// UnitOfMeasureService 计量单位服务
+type UnitOfMeasureService struct {
+	db *gorm.DB
+}
+
+// NewUnitOfMeasureService 创建计量单位服务实例
+func NewUnitOfMeasureService(db *gorm.DB) *UnitOfMeasureService {
+	return &UnitOfMeasureService{db: db}
+}
+
+// CreateUnit 创建计量单位
+func (s *UnitOfMeasureService) CreateUnit(req *UnitOfMeasureRequest) (*UnitOfMeasureResponse, error) {
+	if !isValidUnitDimension(req.Dimension) {
+		return nil, errors.New("量纲必须是 count、mass、length、volume 或 area")
+	}
+
+	if s.isUnitCodeExists(req.Code, 0) {
+		return nil, errors.New("计量单位编码已存在")
+	}
+
+	unit := &models.UnitOfMeasure{
+		Code:        req.Code,
+		Name:        req.Name,
+		Dimension:   req.Dimension,
+		Precision:   req.Precision,
+		Description: req.Description,
+		IsActive:    req.IsActive,
+	}
+
+	if err := s.db.Create(unit).Error; err != nil {
+		return nil, fmt.Errorf("创建计量单位失败: %v", err)
+	}
+
+	return s.unitToResponse(unit), nil
+}
+
+// GetUnitList 获取计量单位列表
+func (s *UnitOfMeasureService) GetUnitList(dimension string, isActive *bool) ([]UnitOfMeasureResponse, error) {
+	var units []models.UnitOfMeasure
+
+	query := s.db.Model(&models.UnitOfMeasure{})
+
+	// 按量纲筛选
+	if dimension != "" {
+		query = query.Where("dimension = ?", dimension)
+	}
+
+	// 按状态筛选
+	if isActive != nil {
+		query = query.Where("is_active = ?", *isActive)
+	}
+
+	if err := query.Order("dimension, code").Find(&units).Error; err != nil {
+		return nil, fmt.Errorf("获取计量单位列表失败: %v", err)
+	}
+
+	var responses []UnitOfMeasureResponse
+	for _, unit := range units {
+		responses = append(responses, *s.unitToResponse(&unit))
+	}
+
+	return responses, nil
+}
+
+// UpdateUnit 更新计量单位，单位编码被引用后不能修改
+func (s *UnitOfMeasureService) UpdateUnit(id uint, req *UnitOfMeasureRequest) (*UnitOfMeasureResponse, error) {
+	var unit models.UnitOfMeasure
+	if err := s.db.First(&unit, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("计量单位不存在")
+		}
+		return nil, fmt.Errorf("获取计量单位失败: %v", err)
+	}
+
+	if !isValidUnitDimension(req.Dimension) {
+		return nil, errors.New("量纲必须是 count、mass、length、volume 或 area")
+	}
+
+	if req.Code != unit.Code || req.Dimension != unit.Dimension {
+		inUse, err := s.isUnitInUse(unit.Code)
+		if err != nil {
+			return nil, err
+		}
+		if inUse {
+			return nil, errors.New("计量单位已被物料或换算关系引用，不能修改编码或量纲")
+		}
+	}
+
+	if s.isUnitCodeExists(req.Code, id) {
+		return nil, errors.New("计量单位编码已存在")
+	}
+
+	unit.Code = req.Code
+	unit.Name = req.Name
+	unit.Dimension = req.Dimension
+	unit.Precision = req.Precision
+	unit.Description = req.Description
+	unit.IsActive = req.IsActive
+
+	if err := s.db.Save(&unit).Error; err != nil {
+		return nil, fmt.Errorf("更新计量单位失败: %v", err)
+	}
+
+	return s.unitToResponse(&unit), nil
+}
+
+// DeleteUnit 删除未被引用的计量单位
+func (s *UnitOfMeasureService) DeleteUnit(id uint) error {
+	var unit models.UnitOfMeasure
+	if err := s.db.First(&unit, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("计量单位不存在")
+		}
+		return fmt.Errorf("获取计量单位失败: %v", err)
+	}
+
+	inUse, err := s.isUnitInUse(unit.Code)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return errors.New("计量单位已被物料或换算关系引用，无法删除")
+	}
+
+	if err := s.db.Delete(&unit).Error; err != nil {
+		return fmt.Errorf("删除计量单位失败: %v", err)
+	}
+
+	return nil
+}
+
+// CreateConversion 创建计量单位换算，通用换算只能在同一量纲内定义
+func (s *UnitOfMeasureService) CreateConversion(req *UnitConversionRequest) (*UnitConversionResponse, error) {
+	conversion := &models.UnitConversion{
+		MaterialID: req.MaterialID,
+		FromUnit:   req.FromUnit,
+		ToUnit:     req.ToUnit,
+		Factor:     req.Factor,
+		Remark:     req.Remark,
+	}
+
+	if err := s.validateConversion(conversion, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(conversion).Error; err != nil {
+		return nil, fmt.Errorf("创建计量单位换算失败: %v", err)
+	}
+
+	return s.GetConversion(conversion.ID)
+}
+
+// GetConversion 获取计量单位换算详情
+func (s *UnitOfMeasureService) GetConversion(id uint) (*UnitConversionResponse, error) {
+	var conversion models.UnitConversion
+	if err := s.db.Preload("Material").First(&conversion, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("计量单位换算不存在")
+		}
+		return nil, fmt.Errorf("获取计量单位换算失败: %v", err)
+	}
+
+	return s.conversionToResponse(&conversion), nil
+}
+
+// GetConversionList 获取计量单位换算列表，指定物料时同时返回通用换算
+func (s *UnitOfMeasureService) GetConversionList(materialID uint) ([]UnitConversionResponse, error) {
+	var conversions []models.UnitConversion
+
+	query := s.db.Model(&models.UnitConversion{}).Preload("Material")
+	if materialID > 0 {
+		query = query.Where("material_id = ? OR material_id IS NULL", materialID)
+	}
+
+	if err := query.Order("material_id, from_unit, to_unit").Find(&conversions).Error; err != nil {
+		return nil, fmt.Errorf("获取计量单位换算列表失败: %v", err)
+	}
+
+	var responses []UnitConversionResponse
+	for _, conversion := range conversions {
+		responses = append(responses, *s.conversionToResponse(&conversion))
+	}
+
+	return responses, nil
+}
+
+// UpdateConversion 更新计量单位换算
+func (s *UnitOfMeasureService) UpdateConversion(id uint, req *UnitConversionRequest) (*UnitConversionResponse, error) {
+	var conversion models.UnitConversion
+	if err := s.db.First(&conversion, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("计量单位换算不存在")
+		}
+		return nil, fmt.Errorf("获取计量单位换算失败: %v", err)
+	}
+
+	conversion.MaterialID = req.MaterialID
+	conversion.FromUnit = req.FromUnit
+	conversion.ToUnit = req.ToUnit
+	conversion.Factor = req.Factor
+	conversion.Remark = req.Remark
+
+	if err := s.validateConversion(&conversion, id); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Save(&conversion).Error; err != nil {
+		return nil, fmt.Errorf("更新计量单位换算失败: %v", err)
+	}
+
+	return s.GetConversion(id)
+}
+
+// DeleteConversion 删除计量单位换算
+func (s *UnitOfMeasureService) DeleteConversion(id uint) error {
+	result := s.db.Delete(&models.UnitConversion{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除计量单位换算失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("计量单位换算不存在")
+	}
+	return nil
+}
+
+// ConvertQuantity 将数量从指定单位换算为物料的基本单位
+func (s *UnitOfMeasureService) ConvertQuantity(materialID uint, quantity float64, unit string) (*QuantityConversionResponse, error) {
+	var material models.Material
+	if err := s.db.First(&material, materialID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("物料不存在")
+		}
+		return nil, fmt.Errorf("获取物料失败: %v", err)
+	}
+
+	if unit == "" {
+		unit = material.Unit
+	}
+
+	factor, err := unitConversionFactor(s.db, &material, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	baseQuantity, err := normalizeQuantity(s.db, &material, quantity, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuantityConversionResponse{
+		MaterialID:   material.ID,
+		Quantity:     quantity,
+		Unit:         unit,
+		Factor:       factor,
+		BaseQuantity: baseQuantity,
+		BaseUnit:     material.Unit,
+	}, nil
+}
+
+// normalizeQuantity 将录入单位的数量换算为物料基本单位数量，并按基本单位精度舍入
+func normalizeQuantity(tx *gorm.DB, material *models.Material, quantity float64, unit string) (float64, error) {
+	if quantity == 0 {
+		return 0, nil
+	}
+
+	factor, err := unitConversionFactor(tx, material, unit)
+	if err != nil {
+		return 0, err
+	}
+
+	normalized := roundToPrecision(quantity*factor, unitPrecision(tx, material.Unit))
+	if normalized <= 0 {
+		return 0, fmt.Errorf("数量 %v %s 换算后小于基本单位 %s 的精度", quantity, unit, material.Unit)
+	}
+
+	return normalized, nil
+}
+
+// unitConversionFactor 获取录入单位到物料基本单位的换算系数，优先使用物料专用换算
+func unitConversionFactor(tx *gorm.DB, material *models.Material, unit string) (float64, error) {
+	if unit == "" || unit == material.Unit {
+		return 1, nil
+	}
+
+	var unitCount int64
+	if err := tx.Model(&models.UnitOfMeasure{}).Where("code = ? AND is_active = ?", unit, true).Count(&unitCount).Error; err != nil {
+		return 0, fmt.Errorf("验证计量单位失败: %v", err)
+	}
+	if unitCount == 0 {
+		return 0, fmt.Errorf("计量单位 %s 不存在或已停用", unit)
+	}
+
+	scopes := []func(*gorm.DB) *gorm.DB{
+		func(db *gorm.DB) *gorm.DB { return db.Where("material_id = ?", material.ID) },
+		func(db *gorm.DB) *gorm.DB { return db.Where("material_id IS NULL") },
+	}
+	for _, scope := range scopes {
+		var conversions []models.UnitConversion
+		if err := tx.Scopes(scope).
+			Where("(from_unit = ? AND to_unit = ?) OR (from_unit = ? AND to_unit = ?)", unit, material.Unit, material.Unit, unit).
+			Find(&conversions).Error; err != nil {
+			return 0, fmt.Errorf("获取计量单位换算失败: %v", err)
+		}
+
+		for _, conversion := range conversions {
+			if conversion.FromUnit == unit {
+				return conversion.Factor, nil
+			}
+			return 1 / conversion.Factor, nil
+		}
+	}
+
+	return 0, fmt.Errorf("计量单位 %s 无法换算为物料 %s 的基本单位 %s", unit, material.Code, material.Unit)
+}
+
+// unitPrecision 获取计量单位的数量精度，单位不在目录中时使用存储精度
+func unitPrecision(tx *gorm.DB, code string) int {
+	var unit models.UnitOfMeasure
+	if err := tx.Where("code = ?", code).First(&unit).Error; err == nil && unit.Precision < quantityScale {
+		return unit.Precision
+	}
+	return quantityScale
+}
+
+// validateMaterialUnit 校验物料基本单位必须是已启用的计量单位
+func validateMaterialUnit(tx *gorm.DB, unit string) error {
+	var count int64
+	if err := tx.Model(&models.UnitOfMeasure{}).Where("code = ? AND is_active = ?", unit, true).Count(&count).Error; err != nil {
+		return fmt.Errorf("验证计量单位失败: %v", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("计量单位 %s 不存在或已停用，请先在计量单位目录中维护", unit)
+	}
+	return nil
+}
+
+// roundQuantity 数量按存储精度舍入
+func roundQuantity(value float64) float64 {
+	return roundToPrecision(value, quantityScale)
+}
+
+// roundToPrecision 按指定小数位数舍入
+func roundToPrecision(value float64, precision int) float64 {
+	scale := math.Pow10(precision)
+	return math.Round(value*scale) / scale
+}
+
+// isValidUnitDimension 验证量纲
+func isValidUnitDimension(dimension string) bool {
+	for _, d := range validUnitDimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
+
+// 辅助函数：校验换算关系
+func (s *UnitOfMeasureService) validateConversion(conversion *models.UnitConversion, excludeID uint) error {
+	if conversion.FromUnit == conversion.ToUnit {
+		return errors.New("源单位和目标单位不能相同")
+	}
+
+	var units []models.UnitOfMeasure
+	if err := s.db.Where("code IN ?", []string{conversion.FromUnit, conversion.ToUnit}).Find(&units).Error; err != nil {
+		return fmt.Errorf("验证计量单位失败: %v", err)
+	}
+	if len(units) != 2 {
+		return errors.New("源单位或目标单位不存在")
+	}
+
+	if conversion.MaterialID != nil {
+		var material models.Material
+		if err := s.db.First(&material, *conversion.MaterialID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("物料不存在")
+			}
+			return fmt.Errorf("验证物料失败: %v", err)
+		}
+		if conversion.FromUnit != material.Unit && conversion.ToUnit != material.Unit {
+			return fmt.Errorf("物料专用换算必须包含物料基本单位 %s", material.Unit)
+		}
+	} else if units[0].Dimension != units[1].Dimension {
+		return errors.New("通用换算只能在同一量纲的单位之间定义，跨量纲换算请按物料维护")
+	}
+
+	// 同一范围内两个单位之间只能有一条换算（不区分方向）
+	query := s.db.Model(&models.UnitConversion{}).
+		Where("(from_unit = ? AND to_unit = ?) OR (from_unit = ? AND to_unit = ?)",
+			conversion.FromUnit, conversion.ToUnit, conversion.ToUnit, conversion.FromUnit)
+	if conversion.MaterialID != nil {
+		query = query.Where("material_id = ?", *conversion.MaterialID)
+	} else {
+		query = query.Where("material_id IS NULL")
+	}
+	if excludeID > 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("验证计量单位换算失败: %v", err)
+	}
+	if count > 0 {
+		return errors.New("这两个单位之间已存在换算关系")
+	}
+
+	return nil
+}
+
+// 辅助函数：检查计量单位编码是否存在
+func (s *UnitOfMeasureService) isUnitCodeExists(code string, excludeID uint) bool {
+	var count int64
+	query := s.db.Model(&models.UnitOfMeasure{}).Where("code = ?", code)
+	if excludeID > 0 {
+		query = query.Where("id != ?", excludeID)
+	}
+	query.Count(&count)
+	return count > 0
+}
+
+// 辅助函数：检查计量单位是否被物料或换算关系引用
+func (s *UnitOfMeasureService) isUnitInUse(code string) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.Material{}).Where("unit = ?", code).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("检查计量单位引用失败: %v", err)
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if err := s.db.Model(&models.UnitConversion{}).
+		Where("from_unit = ? OR to_unit = ?", code, code).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("检查计量单位引用失败: %v", err)
+	}
+	return count > 0, nil
+}
+
+// 辅助函数：将计量单位模型转换为响应结构体
+func (s *UnitOfMeasureService) unitToResponse(unit *models.UnitOfMeasure) *UnitOfMeasureResponse {
+	return &UnitOfMeasureResponse{
+		ID:          unit.ID,
+		Code:        unit.Code,
+		Name:        unit.Name,
+		Dimension:   unit.Dimension,
+		Precision:   unit.Precision,
+		Description: unit.Description,
+		IsActive:    unit.IsActive,
+		CreatedAt:   unit.CreatedAt,
+		UpdatedAt:   unit.UpdatedAt,
+	}
+}
+
+// 辅助函数：将换算模型转换为响应结构体
+func (s *UnitOfMeasureService) conversionToResponse(conversion *models.UnitConversion) *UnitConversionResponse {
+	resp := &UnitConversionResponse{
+		ID:         conversion.ID,
+		MaterialID: conversion.MaterialID,
+		FromUnit:   conversion.FromUnit,
+		ToUnit:     conversion.ToUnit,
+		Factor:     conversion.Factor,
+		Remark:     conversion.Remark,
+		CreatedAt:  conversion.CreatedAt,
+	}
+	if conversion.Material != nil {
+		resp.MaterialCode = conversion.Material.Code
+		resp.MaterialName = conversion.Material.Name
+	}
+	return resp
+}
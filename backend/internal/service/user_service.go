@@ -265,4 +265,41 @@ func (s *UserService) UpdateProfile(userID uint, req *UpdateProfileRequest) erro
 	}
 
 	return s.db.Model(&user).Updates(updateData).Error
-}
\ No newline at end of file
+}
+
+// UpdateBadgeRequest 设置工牌号请求结构
+type UpdateBadgeRequest struct {
+	BadgeNo string `json:"badge_no"` // 工牌号，为空表示清除
+}
+
+// UpdateBadge 设置用户工牌号
+func (s *UserService) UpdateBadge(userID uint, req *UpdateBadgeRequest) (*models.User, error) {
+	var user models.User
+	err := s.db.Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+
+	var badgeNo *string
+	if req.BadgeNo != "" {
+		// 检查工牌号是否已被其他用户使用
+		var count int64
+		s.db.Model(&models.User{}).Where("badge_no = ? AND id != ?", req.BadgeNo, userID).Count(&count)
+		if count > 0 {
+			return nil, errors.New("工牌号已被其他用户使用")
+		}
+		badgeNo = &req.BadgeNo
+	}
+
+	if err := s.db.Model(&user).Update("badge_no", badgeNo).Error; err != nil {
+		return nil, err
+	}
+
+	// 清除密码字段
+	user.BadgeNo = badgeNo
+	user.Password = ""
+	return &user, nil
+}
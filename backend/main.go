@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"mes-system/configs"
 	"mes-system/internal/controller"
 	"mes-system/internal/service"
 	"mes-system/pkg/jwt"
 	"mes-system/routes"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	// 修正Swagger导入路径
 	_ "mes-system/docs"
@@ -45,6 +52,12 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// 初始化补货引擎配置
+	replenishmentConfig := configs.GetDefaultReplenishmentConfig()
+
+	// 初始化标签打印配置
+	labelConfig := configs.GetDefaultLabelConfig()
+
 	// 初始化JWT配置
 	jwtConfig := jwt.GetDefaultJWTConfig()
 
@@ -52,26 +65,94 @@ func main() {
 	userService := service.NewUserService(db, jwtConfig)
 	productionService := service.NewProductionService(db)
 	productService := service.NewProductService(db)
+	productStockService := service.NewProductStockService(db)
 	materialService := service.NewMaterialService(db)
 	qualityService := service.NewQualityService(db)
+	spcService := service.NewSpcService(db)
+	capabilityService := service.NewCapabilityService(db)
+	qualityEventService := service.NewQualityEventService(db)
+	inspectionSheetService := service.NewInspectionSheetService(db, qualityService)
+	defectCodeService := service.NewDefectCodeService(db)
+	samplingService := service.NewSamplingService(db)
+	ncrService := service.NewNcrService(db, productionService)
 	equipmentService := service.NewEquipmentService(db)
+	inventoryReportService := service.NewInventoryReportService(db)
+	inventoryCountService := service.NewInventoryCountService(db)
+	purchaseOrderService := service.NewPurchaseOrderService(db)
+	supplierService := service.NewSupplierService(db)
+	customerService := service.NewCustomerService(db)
+	salesOrderService := service.NewSalesOrderService(db, productionService)
+	shipmentService := service.NewShipmentService(db, labelConfig.FontPath)
+	incomingInspectionService := service.NewIncomingInspectionService(db)
+	materialHoldService := service.NewMaterialHoldService(db)
+	uomService := service.NewUnitOfMeasureService(db)
+	replenishmentService := service.NewReplenishmentService(db, replenishmentConfig.ConsumptionDays)
+	requisitionService := service.NewRequisitionService(db)
+	mrpService := service.NewMrpService(db, productionService, purchaseOrderService)
+	labelService := service.NewLabelService(db, labelConfig.FontPath, labelConfig.ZPLFont)
+	scanService := service.NewScanService(db)
+	costingService := service.NewCostingService(db)
 
 	// 初始化控制器层
 	userController := controller.NewUserController(userService)
 	productionController := controller.NewProductionController(productionService, productService)
 	productController := controller.NewProductController(productService)
+	productStockController := controller.NewProductStockController(productStockService)
 	materialController := controller.NewMaterialController(materialService)
 	qualityController := controller.NewQualityController(qualityService)
+	spcController := controller.NewSpcController(spcService, capabilityService)
+	qualityEventController := controller.NewQualityEventController(qualityEventService)
+	inspectionSheetController := controller.NewInspectionSheetController(inspectionSheetService)
+	defectCodeController := controller.NewDefectCodeController(defectCodeService)
+	samplingController := controller.NewSamplingController(samplingService)
+	ncrController := controller.NewNcrController(ncrService)
 	equipmentController := controller.NewEquipmentController(equipmentService)
+	inventoryReportController := controller.NewInventoryReportController(inventoryReportService, costingService)
+	inventoryCountController := controller.NewInventoryCountController(inventoryCountService)
+	purchaseOrderController := controller.NewPurchaseOrderController(purchaseOrderService)
+	supplierController := controller.NewSupplierController(supplierService)
+	customerController := controller.NewCustomerController(customerService)
+	salesOrderController := controller.NewSalesOrderController(salesOrderService)
+	shipmentController := controller.NewShipmentController(shipmentService)
+	incomingInspectionController := controller.NewIncomingInspectionController(incomingInspectionService)
+	materialHoldController := controller.NewMaterialHoldController(materialHoldService)
+	uomController := controller.NewUnitOfMeasureController(uomService)
+	replenishmentController := controller.NewReplenishmentController(replenishmentService)
+	requisitionController := controller.NewRequisitionController(requisitionService)
+	mrpController := controller.NewMrpController(mrpService)
+	labelController := controller.NewLabelController(labelService)
+	scanController := controller.NewScanController(scanService)
 
 	// 创建控制器集合
 	controllers := &routes.Controllers{
-		User:       userController,
-		Production: productionController,
-		Product:    productController,
-		Material:   materialController,
-		Quality:    qualityController,
-		Equipment:  equipmentController,
+		User:               userController,
+		Production:         productionController,
+		Product:            productController,
+		ProductStock:       productStockController,
+		Material:           materialController,
+		Quality:            qualityController,
+		Spc:                spcController,
+		QualityEvent:       qualityEventController,
+		InspectionSheet:    inspectionSheetController,
+		DefectCode:         defectCodeController,
+		Sampling:           samplingController,
+		Ncr:                ncrController,
+		Equipment:          equipmentController,
+		Inventory:          inventoryReportController,
+		InventoryCount:     inventoryCountController,
+		PurchaseOrder:      purchaseOrderController,
+		Supplier:           supplierController,
+		Customer:           customerController,
+		SalesOrder:         salesOrderController,
+		Shipment:           shipmentController,
+		IncomingInspection: incomingInspectionController,
+		MaterialHold:       materialHoldController,
+		UnitOfMeasure:      uomController,
+		Replenishment:      replenishmentController,
+		Requisition:        requisitionController,
+		Mrp:                mrpController,
+		Label:              labelController,
+		Scan:               scanController,
 	}
 
 	// 创建Gin引擎
@@ -95,10 +176,30 @@ func main() {
 	// 设置路由
 	routes.SetupRoutes(r, controllers, jwtConfig)
 
+	// 收到退出信号时停止定时任务并关闭服务器
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 启动定时补货计算
+	if replenishmentConfig.Enabled {
+		replenishmentService.StartScheduler(ctx, replenishmentConfig.Interval)
+	}
+
 	// 启动服务器
+	server := &http.Server{Addr: ":8082", Handler: r}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Server shutdown failed: %v", err)
+		}
+	}()
+
 	log.Println("Server starting on :8082")
 	log.Println("Swagger UI available at: http://localhost:8082/swagger/index.html")
-	if err := r.Run(":8082"); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Failed to start server: %v", err)
 	}
+	log.Println("Server stopped")
 }
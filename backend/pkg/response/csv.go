@@ -0,0 +1,30 @@
+package response
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSV 以CSV附件形式输出数据
+func CSV(c *gin.Context, filename string, header []string, rows [][]string) {
+	var buf bytes.Buffer
+	// 写入UTF-8 BOM，避免Excel打开时中文乱码
+	buf.WriteString("\xEF\xBB\xBF")
+
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(header); err != nil {
+		InternalError(c, "生成CSV失败: "+err.Error())
+		return
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		InternalError(c, "生成CSV失败: "+err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
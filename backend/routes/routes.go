@@ -10,12 +10,34 @@ import (
 
 // Controllers 控制器集合
 type Controllers struct {
-	User       *controller.UserController
-	Production *controller.ProductionController
-	Product    *controller.ProductController
-	Material   *controller.MaterialController
-	Quality    *controller.QualityController
-	Equipment  *controller.EquipmentController
+	User               *controller.UserController
+	Production         *controller.ProductionController
+	Product            *controller.ProductController
+	ProductStock       *controller.ProductStockController
+	Material           *controller.MaterialController
+	Quality            *controller.QualityController
+	Spc                *controller.SpcController
+	QualityEvent       *controller.QualityEventController
+	InspectionSheet    *controller.InspectionSheetController
+	DefectCode         *controller.DefectCodeController
+	Sampling           *controller.SamplingController
+	Ncr                *controller.NcrController
+	Equipment          *controller.EquipmentController
+	Inventory          *controller.InventoryReportController
+	InventoryCount     *controller.InventoryCountController
+	PurchaseOrder      *controller.PurchaseOrderController
+	Supplier           *controller.SupplierController
+	Customer           *controller.CustomerController
+	SalesOrder         *controller.SalesOrderController
+	Shipment           *controller.ShipmentController
+	Mrp                *controller.MrpController
+	IncomingInspection *controller.IncomingInspectionController
+	MaterialHold       *controller.MaterialHoldController
+	Replenishment      *controller.ReplenishmentController
+	Requisition        *controller.RequisitionController
+	Label              *controller.LabelController
+	UnitOfMeasure      *controller.UnitOfMeasureController
+	Scan               *controller.ScanController
 }
 
 // SetupRoutes 设置所有路由
@@ -36,11 +58,65 @@ func SetupRoutes(r *gin.Engine, controllers *Controllers, jwtConfig *jwt.JWTConf
 		// 设置产品管理路由
 		setupProductRoutes(auth, controllers.Product)
 
+		// 设置成品库存路由
+		setupProductStockRoutes(auth, controllers.ProductStock)
+
 		// 设置物料管理路由
 		setupMaterialRoutes(auth, controllers.Material)
 
+		// 设置计量单位路由
+		setupUnitOfMeasureRoutes(auth, controllers.UnitOfMeasure)
+
+		// 设置库存报表路由
+		setupInventoryRoutes(auth, controllers.Inventory)
+
+		// 设置库存盘点路由
+		setupInventoryCountRoutes(auth, controllers.InventoryCount)
+
+		// 设置补货管理路由
+		setupReplenishmentRoutes(auth, controllers.Replenishment)
+
+		// 设置MRP路由
+		setupMrpRoutes(auth, controllers.Mrp)
+
+		// 设置领料单路由
+		setupRequisitionRoutes(auth, controllers.Requisition)
+
+		// 设置标签打印路由
+		setupLabelRoutes(auth, controllers.Label)
+
+		// 设置扫码解析路由
+		setupScanRoutes(auth, controllers.Scan)
+
+		// 设置采购管理路由
+		setupPurchaseOrderRoutes(auth, controllers.PurchaseOrder)
+
+		// 设置供应商管理路由
+		setupSupplierRoutes(auth, controllers.Supplier)
+
+		// 设置客户管理路由
+		setupCustomerRoutes(auth, controllers.Customer)
+
+		// 设置销售订单路由
+		setupSalesOrderRoutes(auth, controllers.SalesOrder)
+
+		// 设置发货管理路由
+		setupShipmentRoutes(auth, controllers.Shipment)
+
 		// 设置质量管理路由
 		setupQualityRoutes(auth, controllers.Quality)
+		setupSpcRoutes(auth, controllers.Spc)
+		setupQualityEventRoutes(auth, controllers.QualityEvent)
+		setupInspectionSheetRoutes(auth, controllers.InspectionSheet)
+		setupDefectCodeRoutes(auth, controllers.DefectCode)
+		setupSamplingRoutes(auth, controllers.Sampling)
+		setupNcrRoutes(auth, controllers.Ncr)
+
+		// 设置来料检验路由
+		setupIncomingInspectionRoutes(auth, controllers.IncomingInspection)
+
+		// 设置物料冻结路由
+		setupMaterialHoldRoutes(auth, controllers.MaterialHold)
 
 		// 设置设备管理路由
 		setupEquipmentRoutes(auth, controllers.Equipment)
@@ -63,11 +139,12 @@ func setupUserRoutes(rg *gin.RouterGroup, ctrl *controller.UserController) {
 func setupAuthUserRoutes(rg *gin.RouterGroup, ctrl *controller.UserController) {
 	userGroup := rg.Group("/users")
 	{
-		userGroup.GET("/profile", ctrl.GetProfile)                    // 获取用户信息
-		userGroup.PUT("/profile", ctrl.UpdateProfile)                 // 更新用户信息
-		userGroup.PUT("/password", ctrl.ChangePassword)               // 修改密码
-		userGroup.POST("/refresh", ctrl.RefreshToken)                 // 刷新令牌
-		userGroup.GET("/list", middleware.RoleMiddleware("admin"), ctrl.GetUserList) // 获取用户列表（仅管理员）
+		userGroup.GET("/profile", ctrl.GetProfile)                                        // 获取用户信息
+		userGroup.PUT("/profile", ctrl.UpdateProfile)                                     // 更新用户信息
+		userGroup.PUT("/password", ctrl.ChangePassword)                                   // 修改密码
+		userGroup.POST("/refresh", ctrl.RefreshToken)                                     // 刷新令牌
+		userGroup.GET("/list", middleware.RoleMiddleware("admin"), ctrl.GetUserList)      // 获取用户列表（仅管理员）
+		userGroup.PUT("/:id/badge", middleware.RoleMiddleware("admin"), ctrl.UpdateBadge) // 设置用户工牌号（仅管理员）
 	}
 }
 
@@ -98,6 +175,17 @@ func setupProductRoutes(rg *gin.RouterGroup, ctrl *controller.ProductController)
 	}
 }
 
+// setupProductStockRoutes 设置成品库存路由
+func setupProductStockRoutes(rg *gin.RouterGroup, ctrl *controller.ProductStockController) {
+	productGroup := rg.Group("/products")
+	{
+		productGroup.POST("/transactions", middleware.RoleMiddleware("admin", "manager", "warehouse"), ctrl.CreateTransaction) // 登记成品报废和调整
+		productGroup.GET("/transactions", ctrl.GetTransactionList)                                                             // 获取成品交易列表
+		productGroup.GET("/transaction-types", ctrl.GetTransactionTypes)                                                       // 获取成品交易类型
+		productGroup.GET("/:id/stock", ctrl.GetProductStock)                                                                   // 获取产品成品库存
+	}
+}
+
 // setupMaterialRoutes 设置物料管理路由
 func setupMaterialRoutes(rg *gin.RouterGroup, ctrl *controller.MaterialController) {
 	materialGroup := rg.Group("/materials")
@@ -110,8 +198,9 @@ func setupMaterialRoutes(rg *gin.RouterGroup, ctrl *controller.MaterialControlle
 		materialGroup.DELETE("/:id", ctrl.DeleteMaterial)              // 删除物料
 
 		// 物料交易管理
-		materialGroup.POST("/transactions", ctrl.CreateTransaction)     // 创建物料交易
-		materialGroup.GET("/transactions", ctrl.GetTransactionList)     // 获取交易列表
+		materialGroup.POST("/transactions", ctrl.CreateTransaction)       // 创建物料交易
+		materialGroup.GET("/transactions", ctrl.GetTransactionList)       // 获取交易列表
+		materialGroup.GET("/transaction-types", ctrl.GetTransactionTypes) // 获取交易类型及原因代码
 
 		// 库存管理
 		materialGroup.GET("/low-stock", ctrl.GetLowStockMaterials)     // 获取低库存物料
@@ -119,6 +208,68 @@ func setupMaterialRoutes(rg *gin.RouterGroup, ctrl *controller.MaterialControlle
 	}
 }
 
+// setupInventoryRoutes 设置库存报表路由
+func setupInventoryRoutes(rg *gin.RouterGroup, ctrl *controller.InventoryReportController) {
+	inventoryGroup := rg.Group("/inventory")
+	{
+		// 库存报表
+		inventoryGroup.GET("/stock", ctrl.GetStockAsOf)                    // 获取指定日期库存
+		inventoryGroup.GET("/stock/export", ctrl.ExportStockAsOf)          // 导出指定日期库存
+		inventoryGroup.GET("/movements", ctrl.GetMovementReport)           // 获取收发存报表
+		inventoryGroup.GET("/movements/export", ctrl.ExportMovementReport) // 导出收发存报表
+
+		// 库存估值
+		inventoryGroup.GET("/valuation", ctrl.GetInventoryValuation)        // 获取库存估值
+		inventoryGroup.GET("/cost-layers/:material_id", ctrl.GetCostLayers) // 获取物料成本层
+	}
+}
+
+// setupInventoryCountRoutes 设置库存盘点路由
+func setupInventoryCountRoutes(rg *gin.RouterGroup, ctrl *controller.InventoryCountController) {
+	countGroup := rg.Group("/inventory/counts")
+	{
+		countGroup.POST("", ctrl.CreateCount)                                                             // 创建盘点单
+		countGroup.GET("", ctrl.GetCountList)                                                             // 获取盘点单列表
+		countGroup.GET("/reason-codes", ctrl.GetCountReasonCodes)                                         // 获取差异原因代码
+		countGroup.GET("/:id", ctrl.GetCount)                                                             // 获取盘点单详情
+		countGroup.PUT("/:id/entries", ctrl.RecordCounts)                                                 // 录入实盘数量
+		countGroup.POST("/:id/import", ctrl.ImportCounts)                                                 // 导入实盘数量
+		countGroup.POST("/:id/approve", middleware.RoleMiddleware("admin", "manager"), ctrl.ApproveCount) // 审核盘点单（仅管理员和主管）
+		countGroup.POST("/:id/cancel", middleware.RoleMiddleware("admin", "manager"), ctrl.CancelCount)   // 取消盘点单（仅管理员和主管）
+	}
+}
+
+// setupPurchaseOrderRoutes 设置采购管理路由
+func setupPurchaseOrderRoutes(rg *gin.RouterGroup, ctrl *controller.PurchaseOrderController) {
+	purchaseGroup := rg.Group("/purchase-orders")
+	{
+		purchaseGroup.POST("", ctrl.CreatePurchaseOrder)              // 创建采购订单
+		purchaseGroup.GET("", ctrl.GetPurchaseOrderList)              // 获取采购订单列表
+		purchaseGroup.GET("/overdue-lines", ctrl.GetOverdueLines)     // 获取逾期采购订单行
+		purchaseGroup.GET("/:id", ctrl.GetPurchaseOrder)              // 获取采购订单详情
+		purchaseGroup.PUT("/:id", ctrl.UpdatePurchaseOrder)           // 更新采购订单
+		purchaseGroup.DELETE("/:id", ctrl.DeletePurchaseOrder)        // 删除采购订单
+		purchaseGroup.POST("/:id/release", ctrl.ReleasePurchaseOrder) // 下达采购订单
+		purchaseGroup.POST("/:id/close", ctrl.ClosePurchaseOrder)     // 关闭采购订单
+		purchaseGroup.POST("/:id/cancel", ctrl.CancelPurchaseOrder)   // 取消采购订单
+	}
+}
+
+// setupSupplierRoutes 设置供应商管理路由
+func setupSupplierRoutes(rg *gin.RouterGroup, ctrl *controller.SupplierController) {
+	supplierGroup := rg.Group("/suppliers")
+	{
+		supplierGroup.POST("", ctrl.CreateSupplier)                                      // 创建供应商
+		supplierGroup.GET("", ctrl.GetSupplierList)                                      // 获取供应商列表
+		supplierGroup.GET("/scorecards", ctrl.GetScorecards)                             // 获取供应商绩效
+		supplierGroup.GET("/:id", ctrl.GetSupplier)                                      // 获取供应商详情
+		supplierGroup.PUT("/:id", ctrl.UpdateSupplier)                                   // 更新供应商
+		supplierGroup.DELETE("/:id", ctrl.DeleteSupplier)                                // 删除供应商
+		supplierGroup.POST("/:id/materials", ctrl.AddSupplierMaterial)                   // 添加合格物料
+		supplierGroup.DELETE("/:id/materials/:material_id", ctrl.RemoveSupplierMaterial) // 移除合格物料
+	}
+}
+
 // setupQualityRoutes 设置质量管理路由
 func setupQualityRoutes(rg *gin.RouterGroup, ctrl *controller.QualityController) {
 	qualityGroup := rg.Group("/quality")
@@ -131,17 +282,269 @@ func setupQualityRoutes(rg *gin.RouterGroup, ctrl *controller.QualityController)
 		qualityGroup.DELETE("/standards/:id", ctrl.DeleteQualityStandard)  // 删除质量标准
 
 		// 质量检测管理
-		qualityGroup.POST("/inspections", ctrl.CreateQualityInspection)     // 创建质量检测
-		qualityGroup.GET("/inspections/:id", ctrl.GetQualityInspection)     // 获取质量检测详情
-		qualityGroup.GET("/inspections", ctrl.GetQualityInspectionList)     // 获取质量检测列表
-		qualityGroup.PUT("/inspections/:id", ctrl.UpdateQualityInspection)  // 更新质量检测
-		qualityGroup.DELETE("/inspections/:id", ctrl.DeleteQualityInspection) // 删除质量检测
+		qualityGroup.POST("/inspections", ctrl.CreateQualityInspection)         // 创建质量检测
+		qualityGroup.GET("/inspections/:id", ctrl.GetQualityInspection)         // 获取质量检测详情
+		qualityGroup.GET("/inspections", ctrl.GetQualityInspectionList)         // 获取质量检测列表
+		qualityGroup.PUT("/inspections/:id", ctrl.UpdateQualityInspection)      // 更新质量检测
+		qualityGroup.DELETE("/inspections/:id", ctrl.DeleteQualityInspection)   // 删除质量检测
+		qualityGroup.GET("/inspections/:id/overrides", ctrl.GetResultOverrides) // 获取检测结果改判记录
 
 		// 质量统计
 		qualityGroup.GET("/statistics", ctrl.GetQualityStatistics)          // 获取质量统计
 	}
 }
 
+// setupSpcRoutes 设置SPC统计过程控制路由
+func setupSpcRoutes(rg *gin.RouterGroup, ctrl *controller.SpcController) {
+	qualityGroup := rg.Group("/quality")
+	{
+		qualityGroup.GET("/spc", ctrl.GetSpcChart)                          // 获取SPC控制图
+		qualityGroup.GET("/capability", ctrl.GetCapabilityReport)           // 获取过程能力报表
+		qualityGroup.GET("/capability/export", ctrl.ExportCapabilityReport) // 导出过程能力报表
+	}
+}
+
+// setupQualityEventRoutes 设置质量异常事件路由
+func setupQualityEventRoutes(rg *gin.RouterGroup, ctrl *controller.QualityEventController) {
+	qualityGroup := rg.Group("/quality")
+	{
+		qualityGroup.GET("/events/rules", ctrl.GetSpcRules)                                                                       // 获取判异规则
+		qualityGroup.GET("/events", ctrl.GetQualityEventList)                                                                     // 获取质量异常事件列表
+		qualityGroup.GET("/events/:id", ctrl.GetQualityEvent)                                                                     // 获取质量异常事件详情
+		qualityGroup.POST("/events/:id/acknowledge", middleware.RoleMiddleware("admin", "manager"), ctrl.AcknowledgeQualityEvent) // 确认质量异常事件（仅管理员和主管）
+	}
+}
+
+// setupInspectionSheetRoutes 设置检测单路由
+func setupInspectionSheetRoutes(rg *gin.RouterGroup, ctrl *controller.InspectionSheetController) {
+	qualityGroup := rg.Group("/quality")
+	{
+		qualityGroup.POST("/sheets", ctrl.CreateInspectionSheet)       // 创建检测单
+		qualityGroup.GET("/sheets", ctrl.GetInspectionSheetList)       // 获取检测单列表
+		qualityGroup.GET("/sheets/:id", ctrl.GetInspectionSheet)       // 获取检测单详情
+		qualityGroup.PUT("/sheets/:id", ctrl.UpdateInspectionSheet)    // 更新检测单
+		qualityGroup.DELETE("/sheets/:id", ctrl.DeleteInspectionSheet) // 删除检测单
+	}
+}
+
+// setupDefectCodeRoutes 设置缺陷代码和缺陷排列图路由
+func setupDefectCodeRoutes(rg *gin.RouterGroup, ctrl *controller.DefectCodeController) {
+	qualityGroup := rg.Group("/quality")
+	{
+		// 缺陷代码目录
+		qualityGroup.POST("/defect-codes", ctrl.CreateDefectCode)       // 创建缺陷代码
+		qualityGroup.GET("/defect-codes/tree", ctrl.GetDefectCodeTree)  // 获取缺陷代码目录树
+		qualityGroup.GET("/defect-codes", ctrl.GetDefectCodeList)       // 获取缺陷代码列表
+		qualityGroup.GET("/defect-codes/:id", ctrl.GetDefectCode)       // 获取缺陷代码详情
+		qualityGroup.PUT("/defect-codes/:id", ctrl.UpdateDefectCode)    // 更新缺陷代码
+		qualityGroup.DELETE("/defect-codes/:id", ctrl.DeleteDefectCode) // 删除缺陷代码
+
+		// 缺陷排列图
+		qualityGroup.GET("/defects/pareto", ctrl.GetDefectPareto) // 获取缺陷排列图
+	}
+}
+
+// setupSamplingRoutes 设置抽样方案和检验批路由
+func setupSamplingRoutes(rg *gin.RouterGroup, ctrl *controller.SamplingController) {
+	qualityGroup := rg.Group("/quality")
+	{
+		// 抽样方案
+		qualityGroup.POST("/sampling-schemes", middleware.RoleMiddleware("admin", "manager"), ctrl.CreateSamplingScheme)              // 创建抽样方案（仅管理员和主管）
+		qualityGroup.GET("/sampling-schemes", ctrl.GetSamplingSchemeList)                                                             // 获取抽样方案列表
+		qualityGroup.GET("/sampling-schemes/:id", ctrl.GetSamplingScheme)                                                             // 获取抽样方案详情
+		qualityGroup.GET("/sampling-schemes/:id/plan", ctrl.GetSamplingPlan)                                                          // 按批量查询样本量及接收数、拒收数
+		qualityGroup.PUT("/sampling-schemes/:id", middleware.RoleMiddleware("admin", "manager"), ctrl.UpdateSamplingScheme)           // 更新抽样方案（仅管理员和主管）
+		qualityGroup.DELETE("/sampling-schemes/:id", middleware.RoleMiddleware("admin", "manager"), ctrl.DeleteSamplingScheme)        // 删除抽样方案（仅管理员和主管）
+		qualityGroup.POST("/sampling-schemes/:id/switch", middleware.RoleMiddleware("admin", "manager"), ctrl.SwitchSamplingSeverity) // 手动转移检验严格度（仅管理员和主管）
+
+		// 检验批
+		qualityGroup.POST("/lots", ctrl.CreateInspectionLot)            // 创建检验批
+		qualityGroup.GET("/lots", ctrl.GetInspectionLotList)            // 获取检验批列表
+		qualityGroup.GET("/lots/:id", ctrl.GetInspectionLot)            // 获取检验批详情
+		qualityGroup.DELETE("/lots/:id", ctrl.DeleteInspectionLot)      // 删除待判定的检验批
+		qualityGroup.POST("/lots/:id/decide", ctrl.DecideInspectionLot) // 判定检验批
+	}
+}
+
+// setupNcrRoutes 设置不合格品报告路由
+func setupNcrRoutes(rg *gin.RouterGroup, ctrl *controller.NcrController) {
+	qualityGroup := rg.Group("/quality")
+	{
+		qualityGroup.POST("/ncrs", ctrl.CreateNcr)                                                             // 创建不合格品报告
+		qualityGroup.GET("/ncrs", ctrl.GetNcrList)                                                             // 获取不合格品报告列表
+		qualityGroup.GET("/ncrs/:id", ctrl.GetNcr)                                                             // 获取不合格品报告详情
+		qualityGroup.PUT("/ncrs/:id", ctrl.UpdateNcr)                                                          // 修改不合格品报告
+		qualityGroup.POST("/ncrs/:id/disposition", ctrl.SubmitNcrDisposition)                                  // 提交处置
+		qualityGroup.POST("/ncrs/:id/approve", middleware.RoleMiddleware("admin", "manager"), ctrl.ApproveNcr) // 批准处置（仅管理员和主管，让步接收仅管理员）
+		qualityGroup.POST("/ncrs/:id/reject", middleware.RoleMiddleware("admin", "manager"), ctrl.RejectNcr)   // 驳回处置（仅管理员和主管）
+		qualityGroup.POST("/ncrs/:id/cancel", middleware.RoleMiddleware("admin", "manager"), ctrl.CancelNcr)   // 取消不合格品报告（仅管理员和主管）
+	}
+}
+
+// setupIncomingInspectionRoutes 设置来料检验路由
+func setupIncomingInspectionRoutes(rg *gin.RouterGroup, ctrl *controller.IncomingInspectionController) {
+	qualityGroup := rg.Group("/quality")
+	{
+		// 物料质量标准
+		qualityGroup.POST("/material-standards", ctrl.CreateMaterialStandard)       // 创建物料质量标准
+		qualityGroup.GET("/material-standards/:id", ctrl.GetMaterialStandard)       // 获取物料质量标准详情
+		qualityGroup.GET("/material-standards", ctrl.GetMaterialStandardList)       // 获取物料质量标准列表
+		qualityGroup.PUT("/material-standards/:id", ctrl.UpdateMaterialStandard)    // 更新物料质量标准
+		qualityGroup.DELETE("/material-standards/:id", ctrl.DeleteMaterialStandard) // 删除物料质量标准
+
+		// 来料检验
+		qualityGroup.POST("/incoming-inspections", ctrl.CreateInspection)        // 创建来料检验单
+		qualityGroup.GET("/incoming-inspections/:id", ctrl.GetInspection)        // 获取来料检验单详情
+		qualityGroup.GET("/incoming-inspections", ctrl.GetInspectionList)        // 获取来料检验单列表
+		qualityGroup.POST("/incoming-inspections/:id/result", ctrl.RecordResult) // 录入来料检验结果
+	}
+}
+
+// setupUnitOfMeasureRoutes 设置计量单位路由
+func setupUnitOfMeasureRoutes(rg *gin.RouterGroup, ctrl *controller.UnitOfMeasureController) {
+	uomGroup := rg.Group("/uom")
+	{
+		// 计量单位目录
+		uomGroup.POST("/units", ctrl.CreateUnit)       // 创建计量单位
+		uomGroup.GET("/units", ctrl.GetUnitList)       // 获取计量单位列表
+		uomGroup.PUT("/units/:id", ctrl.UpdateUnit)    // 更新计量单位
+		uomGroup.DELETE("/units/:id", ctrl.DeleteUnit) // 删除计量单位
+
+		// 计量单位换算
+		uomGroup.POST("/conversions", ctrl.CreateConversion)       // 创建计量单位换算
+		uomGroup.GET("/conversions", ctrl.GetConversionList)       // 获取计量单位换算列表
+		uomGroup.PUT("/conversions/:id", ctrl.UpdateConversion)    // 更新计量单位换算
+		uomGroup.DELETE("/conversions/:id", ctrl.DeleteConversion) // 删除计量单位换算
+		uomGroup.GET("/convert", ctrl.ConvertQuantity)             // 换算物料数量
+	}
+}
+
+// setupReplenishmentRoutes 设置补货管理路由
+func setupReplenishmentRoutes(rg *gin.RouterGroup, ctrl *controller.ReplenishmentController) {
+	replenishmentGroup := rg.Group("/replenishment")
+	{
+		replenishmentGroup.POST("/run", middleware.RoleMiddleware("admin", "manager"), ctrl.RunReplenishment)                  // 运行补货计算（仅管理员和主管）
+		replenishmentGroup.GET("/proposals/export", ctrl.ExportProposals)                                                      // 导出补货建议
+		replenishmentGroup.GET("/proposals/:id", ctrl.GetProposal)                                                             // 获取补货建议详情
+		replenishmentGroup.GET("/proposals", ctrl.GetProposalList)                                                             // 获取补货建议列表
+		replenishmentGroup.POST("/proposals/:id/approve", middleware.RoleMiddleware("admin", "manager"), ctrl.ApproveProposal) // 批准补货建议（仅管理员和主管）
+		replenishmentGroup.POST("/proposals/:id/reject", middleware.RoleMiddleware("admin", "manager"), ctrl.RejectProposal)   // 驳回补货建议（仅管理员和主管）
+	}
+}
+
+// setupMrpRoutes 设置MRP路由
+func setupMrpRoutes(rg *gin.RouterGroup, ctrl *controller.MrpController) {
+	mrpGroup := rg.Group("/mrp")
+	{
+		mrpGroup.POST("/demands", ctrl.CreateDemand)                                                                                    // 创建产品需求
+		mrpGroup.GET("/demands", ctrl.GetDemandList)                                                                                    // 获取产品需求列表
+		mrpGroup.PUT("/demands/:id", ctrl.UpdateDemand)                                                                                 // 更新产品需求
+		mrpGroup.DELETE("/demands/:id", ctrl.DeleteDemand)                                                                              // 删除产品需求
+		mrpGroup.GET("/products/:product_id/materials", ctrl.GetProductMaterials)                                                       // 获取产品物料消耗定额
+		mrpGroup.PUT("/products/:product_id/materials", ctrl.SetProductMaterials)                                                       // 设置产品物料消耗定额
+		mrpGroup.POST("/runs", middleware.RoleMiddleware("admin", "manager"), ctrl.RunMrp)                                              // 运行MRP（仅管理员和主管）
+		mrpGroup.GET("/runs", ctrl.GetRunList)                                                                                          // 获取MRP运行记录列表
+		mrpGroup.GET("/runs/:id", ctrl.GetRun)                                                                                          // 获取MRP运行结果
+		mrpGroup.POST("/planned-orders/:id/firm", middleware.RoleMiddleware("admin", "manager"), ctrl.FirmPlannedOrder)                 // 确认计划生产订单（仅管理员和主管）
+		mrpGroup.POST("/planned-orders/:id/cancel", middleware.RoleMiddleware("admin", "manager"), ctrl.CancelPlannedOrder)             // 取消计划生产订单（仅管理员和主管）
+		mrpGroup.POST("/purchase-suggestions/:id/firm", middleware.RoleMiddleware("admin", "manager"), ctrl.FirmPurchaseSuggestion)     // 确认采购建议（仅管理员和主管）
+		mrpGroup.POST("/purchase-suggestions/:id/cancel", middleware.RoleMiddleware("admin", "manager"), ctrl.CancelPurchaseSuggestion) // 取消采购建议（仅管理员和主管）
+	}
+}
+
+// setupRequisitionRoutes 设置领料单路由
+func setupRequisitionRoutes(rg *gin.RouterGroup, ctrl *controller.RequisitionController) {
+	requisitionGroup := rg.Group("/requisitions")
+	{
+		requisitionGroup.POST("", ctrl.CreateRequisition)                                                                      // 创建领料单
+		requisitionGroup.GET("", ctrl.GetRequisitionList)                                                                      // 获取领料单列表
+		requisitionGroup.GET("/:id", ctrl.GetRequisition)                                                                      // 获取领料单详情
+		requisitionGroup.POST("/:id/approve", middleware.RoleMiddleware("admin", "manager"), ctrl.ApproveRequisition)          // 批准领料单（仅管理员和主管）
+		requisitionGroup.POST("/:id/reject", middleware.RoleMiddleware("admin", "manager"), ctrl.RejectRequisition)            // 驳回领料单（仅管理员和主管）
+		requisitionGroup.POST("/:id/cancel", ctrl.CancelRequisition)                                                           // 取消领料单（申请人或管理员、主管）
+		requisitionGroup.POST("/:id/issue", middleware.RoleMiddleware("admin", "warehouse"), ctrl.IssueRequisition)            // 按领料单发料（仅管理员和仓管员）
+		requisitionGroup.POST("/:id/close", middleware.RoleMiddleware("admin", "manager", "warehouse"), ctrl.CloseRequisition) // 关闭领料单
+	}
+}
+
+// setupCustomerRoutes 设置客户管理路由
+func setupCustomerRoutes(rg *gin.RouterGroup, ctrl *controller.CustomerController) {
+	customerGroup := rg.Group("/customers")
+	{
+		customerGroup.POST("", ctrl.CreateCustomer)       // 创建客户
+		customerGroup.GET("", ctrl.GetCustomerList)       // 获取客户列表
+		customerGroup.GET("/:id", ctrl.GetCustomer)       // 获取客户详情
+		customerGroup.PUT("/:id", ctrl.UpdateCustomer)    // 更新客户
+		customerGroup.DELETE("/:id", ctrl.DeleteCustomer) // 删除客户
+	}
+}
+
+// setupSalesOrderRoutes 设置销售订单路由
+func setupSalesOrderRoutes(rg *gin.RouterGroup, ctrl *controller.SalesOrderController) {
+	salesGroup := rg.Group("/sales-orders")
+	{
+		salesGroup.POST("", ctrl.CreateSalesOrder)                                       // 创建销售订单
+		salesGroup.GET("", ctrl.GetSalesOrderList)                                       // 获取销售订单列表
+		salesGroup.GET("/fulfillment", ctrl.GetFulfillment)                              // 获取销售订单交付情况
+		salesGroup.GET("/:id", ctrl.GetSalesOrder)                                       // 获取销售订单详情
+		salesGroup.PUT("/:id", ctrl.UpdateSalesOrder)                                    // 更新销售订单
+		salesGroup.DELETE("/:id", ctrl.DeleteSalesOrder)                                 // 删除销售订单
+		salesGroup.POST("/:id/confirm", ctrl.ConfirmSalesOrder)                          // 确认销售订单
+		salesGroup.POST("/:id/close", ctrl.CloseSalesOrder)                              // 关闭销售订单
+		salesGroup.POST("/:id/cancel", ctrl.CancelSalesOrder)                            // 取消销售订单
+		salesGroup.POST("/lines/:line_id/production-orders", ctrl.CreateProductionOrder) // 按销售订单行创建生产工单
+	}
+}
+
+// setupShipmentRoutes 设置发货管理路由
+func setupShipmentRoutes(rg *gin.RouterGroup, ctrl *controller.ShipmentController) {
+	shipmentGroup := rg.Group("/shipments")
+	{
+		shipmentGroup.POST("", ctrl.CreateShipment)                                                                    // 创建发货单
+		shipmentGroup.GET("", ctrl.GetShipmentList)                                                                    // 获取发货单列表
+		shipmentGroup.GET("/:id", ctrl.GetShipment)                                                                    // 获取发货单详情
+		shipmentGroup.PUT("/:id", ctrl.UpdateShipment)                                                                 // 更新发货单
+		shipmentGroup.DELETE("/:id", ctrl.DeleteShipment)                                                              // 删除发货单
+		shipmentGroup.POST("/:id/ship", middleware.RoleMiddleware("admin", "manager", "warehouse"), ctrl.ShipShipment) // 确认发货
+		shipmentGroup.POST("/:id/cancel", ctrl.CancelShipment)                                                         // 取消发货单
+		shipmentGroup.GET("/:id/delivery-note", ctrl.GetDeliveryNote)                                                  // 打印发货单
+	}
+}
+
+// setupLabelRoutes 设置标签打印路由
+func setupLabelRoutes(rg *gin.RouterGroup, ctrl *controller.LabelController) {
+	labelGroup := rg.Group("/labels")
+	{
+		labelGroup.POST("/templates", ctrl.CreateTemplate)       // 创建标签模板
+		labelGroup.GET("/templates", ctrl.GetTemplateList)       // 获取标签模板列表
+		labelGroup.GET("/templates/:id", ctrl.GetTemplate)       // 获取标签模板详情
+		labelGroup.PUT("/templates/:id", ctrl.UpdateTemplate)    // 更新标签模板
+		labelGroup.DELETE("/templates/:id", ctrl.DeleteTemplate) // 删除标签模板
+		labelGroup.GET("/fields", ctrl.GetLabelFields)           // 获取标签可打印字段
+		labelGroup.POST("/print", ctrl.PrintLabels)              // 打印标签
+	}
+}
+
+// setupScanRoutes 设置扫码解析路由
+func setupScanRoutes(rg *gin.RouterGroup, ctrl *controller.ScanController) {
+	scanGroup := rg.Group("/scan")
+	{
+		scanGroup.GET("/:code", ctrl.ResolveCode) // 解析扫描的条码
+	}
+}
+
+// setupMaterialHoldRoutes 设置物料冻结路由
+func setupMaterialHoldRoutes(rg *gin.RouterGroup, ctrl *controller.MaterialHoldController) {
+	holdGroup := rg.Group("/material-holds")
+	{
+		holdGroup.POST("", ctrl.CreateHold)                                                             // 冻结物料
+		holdGroup.GET("/:id", ctrl.GetHold)                                                             // 获取物料冻结详情
+		holdGroup.GET("", ctrl.GetHoldList)                                                             // 获取物料冻结列表
+		holdGroup.POST("/:id/release", middleware.RoleMiddleware("admin", "manager"), ctrl.ReleaseHold) // 解除冻结（仅管理员和主管）
+		holdGroup.POST("/:id/scrap", middleware.RoleMiddleware("admin", "manager"), ctrl.ScrapHold)     // 报废冻结物料（仅管理员和主管）
+	}
+}
+
 // setupEquipmentRoutes 设置设备管理路由
 func setupEquipmentRoutes(rg *gin.RouterGroup, ctrl *controller.EquipmentController) {
 	equipmentGroup := rg.Group("/equipment")